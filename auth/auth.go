@@ -3,6 +3,7 @@ package auth
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -58,7 +59,7 @@ func cleanUpByServer(server *config.Server) error {
 	if err := config.ReadNodeConfig(); err != nil {
 		return err
 	}
-	if err := config.ReadServerConf(); err != nil {
+	if err := config.ReadServerConf(); err != nil && !errors.Is(err, config.ErrInvalidServer) {
 		return err
 	}
 	if _, err := config.ReadNetclientConfig(); err != nil {
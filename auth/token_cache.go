@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gravitl/netclient/config"
+)
+
+// defaultTokenTTL - seconds a token is reused when AuthTokenTTL is not set
+const defaultTokenTTL = 300
+
+// tokenExpiryMargin - tokens are refreshed this long before the expiry in their claims
+const tokenExpiryMargin = 30 * time.Second
+
+// tokenCache - api tokens indexed by server name, shared by the requests made to the same server
+type tokenCache struct {
+	mutex        sync.Mutex
+	tokens       map[string]cachedToken
+	authenticate func(*config.Server, *config.Config) (string, error)
+	now          func() time.Time
+}
+
+type cachedToken struct {
+	token   string
+	expires time.Time
+}
+
+var serverTokens = newTokenCache(Authenticate)
+
+func newTokenCache(authenticate func(*config.Server, *config.Config) (string, error)) *tokenCache {
+	return &tokenCache{
+		tokens:       make(map[string]cachedToken),
+		authenticate: authenticate,
+		now:          time.Now,
+	}
+}
+
+// GetToken - returns a valid api token for the server, authenticating only if no cached token is valid
+func GetToken(server *config.Server, host *config.Config) (string, error) {
+	return serverTokens.get(server, host)
+}
+
+// InvalidateToken - drops the cached token of the server, to be called when the server rejects it
+func InvalidateToken(serverName string) {
+	serverTokens.invalidate(serverName)
+}
+
+func (c *tokenCache) get(server *config.Server, host *config.Config) (string, error) {
+	// held while authenticating so concurrent callers wait for the token instead of authenticating too
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if cached, ok := c.tokens[server.Name]; ok && c.now().Before(cached.expires) {
+		return cached.token, nil
+	}
+	token, err := c.authenticate(server, host)
+	if err != nil {
+		delete(c.tokens, server.Name)
+		return "", err
+	}
+	c.tokens[server.Name] = cachedToken{token: token, expires: c.expiry(token, host)}
+	return token, nil
+}
+
+func (c *tokenCache) invalidate(serverName string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.tokens, serverName)
+}
+
+// expiry - returns when a token stops being reused, the configured ttl capped by the expiry claim of the token
+func (c *tokenCache) expiry(token string, host *config.Config) time.Time {
+	ttl := defaultTokenTTL
+	if host != nil && host.AuthTokenTTL > 0 {
+		ttl = host.AuthTokenTTL
+	}
+	expires := c.now().Add(time.Duration(ttl) * time.Second)
+	if exp, ok := tokenClaimExpiry(token); ok && exp.Add(-tokenExpiryMargin).Before(expires) {
+		expires = exp.Add(-tokenExpiryMargin)
+	}
+	return expires
+}
+
+// tokenClaimExpiry - reads the exp claim of a jwt, the signature is not verified as the server checks it
+func tokenClaimExpiry(token string) (time.Time, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+	claims := struct {
+		Exp int64 `json:"exp"`
+	}{}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(claims.Exp, 0), true
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gravitl/netclient/config"
+)
+
+func testToken(exp time.Time) string {
+	claims := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp":%d}`, exp.Unix())))
+	return "header." + claims + ".signature"
+}
+
+func TestTokenCacheSharesToken(t *testing.T) {
+	now := time.Now()
+	var calls int32
+	cache := newTokenCache(func(*config.Server, *config.Config) (string, error) {
+		atomic.AddInt32(&calls, 1)
+		return testToken(now.Add(10 * time.Minute)), nil
+	})
+	cache.now = func() time.Time { return now }
+	server := &config.Server{}
+	server.Name = "nm.example.com"
+	host := &config.Config{}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := cache.get(server, host); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if calls != 1 {
+		t.Fatalf("expected a single auth call for concurrent pulls, got %d", calls)
+	}
+
+	// the 5 minute default ttl is shorter than the token expiry
+	now = now.Add(6 * time.Minute)
+	if _, err := cache.get(server, host); err != nil || calls != 2 {
+		t.Fatalf("expected token refresh after ttl, calls %d err %v", calls, err)
+	}
+
+	cache.invalidate(server.Name)
+	if _, err := cache.get(server, host); err != nil || calls != 3 {
+		t.Fatalf("expected token refresh after invalidation, calls %d err %v", calls, err)
+	}
+}
+
+func TestTokenCacheRespectsExpiry(t *testing.T) {
+	now := time.Now()
+	cache := newTokenCache(nil)
+	cache.now = func() time.Time { return now }
+	host := &config.Config{AuthTokenTTL: 3600}
+	expires := cache.expiry(testToken(now.Add(2*time.Minute)), host)
+	if want := now.Add(2*time.Minute - tokenExpiryMargin).Truncate(time.Second); !expires.Truncate(time.Second).Equal(want) {
+		t.Fatalf("expected expiry %v, got %v", want, expires)
+	}
+	if expires := cache.expiry("opaque", host); !expires.Equal(now.Add(time.Hour)) {
+		t.Fatalf("expected configured ttl for opaque token, got %v", expires)
+	}
+}
@@ -0,0 +1,35 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/gravitl/netclient/functions"
+	"github.com/spf13/cobra"
+)
+
+// exportWgCmd represents the export-wg command
+var exportWgCmd = &cobra.Command{
+	Use:   "export-wg",
+	Args:  cobra.NoArgs,
+	Short: "export the wireguard config of a network",
+	Long: `writes a wg-quick config holding only the address and peers of the network,
+peer endpoints are the peers' real endpoints rather than the local proxy
+For example:
+netclient export-wg --network mynet
+netclient export-wg --network mynet --out mynet.conf
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		network, _ := cmd.Flags().GetString("network")
+		out, _ := cmd.Flags().GetString("out")
+		if err := functions.ExportNetworkWgConfig(network, out); err != nil {
+			fmt.Println(err.Error())
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportWgCmd)
+	exportWgCmd.Flags().StringP("network", "n", "", "network to export")
+	exportWgCmd.Flags().StringP("out", "o", "", "file to write the config to (defaults to stdout)")
+	exportWgCmd.MarkFlagRequired("network")
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/gravitl/netclient/functions"
+	"github.com/gravitl/netclient/nmproxy/router"
+	"github.com/spf13/cobra"
+)
+
+// firewallCmd represents the firewall command
+var firewallCmd = &cobra.Command{
+	Use:   "firewall",
+	Short: "firewall support bundles",
+	Long:  `export and analyse the netmaker firewall state of a host`,
+}
+
+// firewallExportCmd represents the firewall export command
+var firewallExportCmd = &cobra.Command{
+	Use:   "export <file>",
+	Args:  cobra.ExactArgs(1),
+	Short: "export the firewall state to a support bundle",
+	Long: `asks the running daemon for its netmaker firewall rule tables and the live netmaker tables of the
+ruleset and writes them to a support bundle, server names, peer keys and addresses are redacted
+For example:
+netclient firewall export bundle.json
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := functions.ExportFirewallBundle(args[0]); err != nil {
+			fmt.Println(err.Error())
+		}
+	},
+}
+
+// firewallImportCmd represents the firewall import command
+var firewallImportCmd = &cobra.Command{
+	Use:   "import <file>",
+	Args:  cobra.ExactArgs(1),
+	Short: "analyse a firewall support bundle",
+	Long: `loads the rule tables of a support bundle without applying them and lists them,
+with --src and --dst evaluates how the rules would handle the packet
+For example:
+netclient firewall import bundle.json
+netclient firewall import bundle.json --src 10.0.0.5 --dst 192.168.1.10
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var q *router.PacketQuery
+		src, _ := cmd.Flags().GetString("src")
+		dst, _ := cmd.Flags().GetString("dst")
+		if src != "" || dst != "" {
+			q = &router.PacketQuery{Src: src, Dst: dst}
+			q.InIface, _ = cmd.Flags().GetString("iif")
+			q.OutIface, _ = cmd.Flags().GetString("oif")
+			q.Proto, _ = cmd.Flags().GetString("proto")
+			q.Port, _ = cmd.Flags().GetInt("port")
+		}
+		if err := functions.ImportFirewallBundle(args[0], q); err != nil {
+			fmt.Println(err.Error())
+		}
+	},
+}
+
+// firewallRulesCmd represents the firewall rules command
+var firewallRulesCmd = &cobra.Command{
+	Use:   "rules [file]",
+	Args:  cobra.MaximumNArgs(1),
+	Short: "export the active firewall rules as an nft script",
+	Long: `asks the running daemon for the netmaker chains and rules it installed and prints them
+as an nft script, or writes them to file; the rules are rebuilt from the daemon's records and
+the firewall is not touched, exporting is supported with nftables only
+For example:
+netclient firewall rules
+netclient firewall rules --server netmaker rules.nft
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		server, _ := cmd.Flags().GetString("server")
+		file := ""
+		if len(args) > 0 {
+			file = args[0]
+		}
+		if err := functions.ExportFirewallRules(server, file); err != nil {
+			fmt.Println(err.Error())
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(firewallCmd)
+	firewallCmd.AddCommand(firewallExportCmd)
+	firewallCmd.AddCommand(firewallImportCmd)
+	firewallCmd.AddCommand(firewallRulesCmd)
+	firewallRulesCmd.Flags().String("server", "", "only export the rules of the server")
+	firewallImportCmd.Flags().String("src", "", "source ip of a packet to evaluate")
+	firewallImportCmd.Flags().String("dst", "", "destination ip of a packet to evaluate")
+	firewallImportCmd.Flags().String("iif", "", "interface the packet arrives on (defaults to the bundle's netmaker interface)")
+	firewallImportCmd.Flags().String("oif", "", "interface the packet leaves on")
+	firewallImportCmd.Flags().String("proto", "", "protocol of the packet")
+	firewallImportCmd.Flags().Int("port", 0, "destination port of the packet")
+}
@@ -4,6 +4,9 @@ Copyright © 2022 Netmaker Team <info@netmaker.io>
 package cmd
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/gravitl/netclient/functions"
 	"github.com/gravitl/netmaker/logger"
 	"github.com/spf13/cobra"
@@ -19,10 +22,20 @@ token: netclient join -t <token> // join using token
 server: netclient join -s <server> // join a specific server via SSO if Oauth configured
 net: netclient join -s <server> -n <net> // attempt to join specified network via auth
 all-networks: netclient join -s <server> -A // attempt to register to all allowed networks on given server via auth
-user: netclient join -s <server> -u <user_name> // attempt to join/register via basic auth`,
+user: netclient join -s <server> -u <user_name> // attempt to join/register via basic auth
+dry-run: netclient join -t <token> --dry-run // validate a join with a token without changing the host`,
 
 	Run: func(cmd *cobra.Command, args []string) {
 		token, err := cmd.Flags().GetString(registerFlags.Token)
+		dryRun, _ := cmd.Flags().GetBool(registerFlags.DryRun)
+		if dryRun {
+			if err != nil || len(token) == 0 {
+				fmt.Println("--dry-run is only supported when joining with a token")
+				os.Exit(1)
+			}
+			dryRunJoin(token)
+			return
+		}
 		if err != nil || len(token) == 0 {
 			if regErr := checkUserRegistration(cmd); regErr != nil {
 				cmd.Usage()
@@ -31,16 +44,45 @@ user: netclient join -s <server> -u <user_name> // attempt to join/register via
 		} else {
 			if err := functions.Register(token); err != nil {
 				logger.Log(0, "registration failed", err.Error())
+				os.Exit(1)
 			}
 		}
 	},
 }
 
+// dryRunJoin - prints the checks and the actions of a join with the token, exits with 1 if a check failed
+func dryRunJoin(token string) {
+	plan, err := functions.DryRunRegister(token)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Printf("dry-run join with server %s\n", plan.Server)
+	for _, check := range plan.Checks {
+		status := "ok"
+		if !check.Passed {
+			status = "FAIL"
+		}
+		fmt.Printf("  [%s] %s %s\n", status, check.Name, check.Detail)
+	}
+	fmt.Println("a join would:")
+	for _, action := range plan.Actions {
+		fmt.Println("  -", action)
+	}
+	for _, note := range plan.Notes {
+		fmt.Println("note:", note)
+	}
+	if !plan.OK() {
+		os.Exit(1)
+	}
+}
+
 func init() {
 	joinCmd.Flags().StringP(registerFlags.Server, "s", "", "server for attempting SSO/Auth registration")
 	joinCmd.Flags().StringP(registerFlags.Token, "t", "", "enrollment token for joining/registering")
 	joinCmd.Flags().StringP(registerFlags.User, "u", "", "user name for attempting Basic Auth join/registration")
 	joinCmd.Flags().StringP(registerFlags.Network, "n", "", "network to attempt to join/register to")
 	joinCmd.Flags().BoolP(registerFlags.AllNetworks, "A", false, "attempts to join/register to all available networks to user")
+	joinCmd.Flags().Bool(registerFlags.DryRun, false, "validate the join and report what it would do, without changing the host")
 	rootCmd.AddCommand(joinCmd)
 }
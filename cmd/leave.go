@@ -5,6 +5,7 @@ package cmd
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/gravitl/netclient/functions"
 	"github.com/gravitl/netmaker/logger"
@@ -19,10 +20,19 @@ var leaveCmd = &cobra.Command{
 	Long: `leave the specified network 
 For example:
 
-netclient leave my-network`,
+netclient leave my-network
+netclient leave my-network --wait-ack`,
 	Run: func(cmd *cobra.Command, args []string) {
 		logger.Log(0, "leave called")
-		faults, err := functions.LeaveNetwork(args[0], false)
+		waitAck, _ := cmd.Flags().GetBool("wait-ack")
+		ackTimeout, _ := cmd.Flags().GetDuration("ack-timeout")
+		var faults []error
+		var err error
+		if waitAck {
+			faults, err = functions.LeaveNetworkWithAck(args[0], false, ackTimeout)
+		} else {
+			faults, err = functions.LeaveNetwork(args[0], false)
+		}
 		if err != nil {
 			fmt.Println(err.Error())
 			for _, fault := range faults {
@@ -36,6 +46,8 @@ netclient leave my-network`,
 
 func init() {
 	rootCmd.AddCommand(leaveCmd)
+	leaveCmd.Flags().BoolP("wait-ack", "w", false, "wait for the server to acknowledge the node removal before local cleanup")
+	leaveCmd.Flags().Duration("ack-timeout", time.Second*30, "time to wait for the server acknowledgement")
 	// Here you will define your flags and configuration settings.
 
 	// Cobra supports Persistent Flags which will work for this command
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/gravitl/netclient/functions"
+	"github.com/spf13/cobra"
+)
+
+// pingPeerCmd represents the ping-peer command
+var pingPeerCmd = &cobra.Command{
+	Use:   "ping-peer <pubkey|address>",
+	Args:  cobra.ExactArgs(1),
+	Short: "test reachability of a peer through the tunnel",
+	Long: `pings a peer through the netmaker interface from the running daemon and reports
+the latency, whether traffic goes direct, through the proxy, a relay or turn, and the handshake state
+For example:
+netclient ping-peer 10.0.0.5
+netclient ping-peer <peer public key>
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := functions.PingPeer(args[0]); err != nil {
+			fmt.Println(err.Error())
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pingPeerCmd)
+}
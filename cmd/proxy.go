@@ -11,7 +11,7 @@ import (
 var proxyCmd = &cobra.Command{
 	Use:       "proxy [ on | off ]",
 	Short:     "proxy on/off",
-	Long:      `switches proxy on/off`,
+	Long:      `switches proxy on/off, for the host or with --server for the peers of a single server`,
 	Args:      cobra.ExactArgs(1),
 	ValidArgs: []string{"on", "off"},
 	Run: func(cmd *cobra.Command, args []string) {
@@ -20,15 +20,53 @@ var proxyCmd = &cobra.Command{
 			fmt.Println(err)
 			return
 		}
-		err = functions.ChangeProxyStatus(args[0] == "on")
+		server, _ := cmd.Flags().GetString("server")
+		if server != "" {
+			err = functions.ChangeServerProxyStatus(server, args[0] == "on")
+		} else {
+			err = functions.ChangeProxyStatus(args[0] == "on")
+		}
 		if err != nil {
 			fmt.Println(err.Error())
 		}
 	},
 }
 
+// proxyListCmd represents the proxy list command
+var proxyListCmd = &cobra.Command{
+	Use:   "list",
+	Args:  cobra.NoArgs,
+	Short: "list the proxy connections",
+	Long:  `lists the proxy connections of the running daemon with their mode, endpoint and traffic stats`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := functions.ListProxyConns(); err != nil {
+			fmt.Println(err.Error())
+		}
+	},
+}
+
+// proxyResetCmd represents the proxy reset command
+var proxyResetCmd = &cobra.Command{
+	Use:   "reset <peer>",
+	Args:  cobra.ExactArgs(1),
+	Short: "reset the proxy connection to a peer",
+	Long: `resets the proxy connection of the running daemon to the peer with the public key,
+without restarting the daemon
+For example:
+netclient proxy reset <peer public key>
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := functions.ResetProxyConn(args[0]); err != nil {
+			fmt.Println(err.Error())
+		}
+	},
+}
+
 func init() {
 	rootCmd.AddCommand(proxyCmd)
+	proxyCmd.AddCommand(proxyListCmd)
+	proxyCmd.AddCommand(proxyResetCmd)
+	proxyCmd.Flags().StringP("server", "s", "", "switch proxy on/off only for the peers of this server")
 
 	// Here you will define your flags and configuration settings.
 
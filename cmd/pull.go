@@ -4,6 +4,9 @@ Copyright © 2022 Netmaker Team <info@netmaker.io>
 package cmd
 
 import (
+	"fmt"
+	"sort"
+
 	"github.com/gravitl/netclient/functions"
 	"github.com/gravitl/netmaker/logger"
 	"github.com/spf13/cobra"
@@ -14,17 +17,74 @@ var pullCmd = &cobra.Command{
 	Use:   "pull",
 	Args:  cobra.ExactArgs(0),
 	Short: "get the latest host configuration",
-	Long:  `get the latest host configuration and peers from all connected servers`,
+	Long: `get the latest host configuration and peers from all connected servers
+dry-run: netclient pull --dry-run // show what a pull would change without applying it`,
 	Run: func(cmd *cobra.Command, args []string) {
-		err := functions.Pull()
+		if err := functions.SetAPIClient(); err != nil {
+			logger.Log(0, "failed to configure api client", err.Error())
+			return
+		}
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			dryRunPull()
+			return
+		}
+		results, err := functions.PullAll()
+		networks := make([]string, 0, len(results))
+		for network := range results {
+			networks = append(networks, network)
+		}
+		sort.Strings(networks)
+		for _, network := range networks {
+			if pullErr := results[network]; pullErr != nil {
+				fmt.Println("network", network, "failed to pull:", pullErr.Error())
+			} else {
+				fmt.Println("network", network, "pulled")
+			}
+		}
 		if err != nil {
 			logger.Log(0, "failed to pull", err.Error())
 		}
 	},
 }
 
+// dryRunPull - prints what a pull would change on the host, by server
+func dryRunPull() {
+	for _, diff := range functions.PullDiff() {
+		fmt.Printf("dry-run pull from server %s\n", diff.Server)
+		for _, err := range diff.Errors {
+			fmt.Println("  error:", err)
+		}
+		if diff.Empty() {
+			fmt.Println("  no changes")
+			continue
+		}
+		for _, change := range diff.Nodes {
+			fmt.Printf("  network %s: %s %q -> %q\n", change.Network, change.Field, change.Old, change.New)
+		}
+		for _, key := range diff.AddedPeers {
+			fmt.Println("  + peer", key)
+		}
+		for _, key := range diff.RemovedPeers {
+			fmt.Println("  - peer", key)
+		}
+		for _, change := range diff.ChangedPeers {
+			fmt.Println("  ~ peer", change.PublicKey)
+			if change.OldEndpoint != change.NewEndpoint {
+				fmt.Printf("      endpoint %q -> %q\n", change.OldEndpoint, change.NewEndpoint)
+			}
+			for _, ip := range change.AddedIPs {
+				fmt.Println("      + allowed ip", ip)
+			}
+			for _, ip := range change.RemovedIPs {
+				fmt.Println("      - allowed ip", ip)
+			}
+		}
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(pullCmd)
+	pullCmd.Flags().Bool("dry-run", false, "show what the pull would change, without applying it")
 
 	// Here you will define your flags and configuration settings.
 
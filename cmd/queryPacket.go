@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/gravitl/netclient/functions"
+	"github.com/gravitl/netclient/nmproxy/router"
+	"github.com/spf13/cobra"
+)
+
+// queryPacketCmd represents the query-packet command
+var queryPacketCmd = &cobra.Command{
+	Use:   "query-packet",
+	Args:  cobra.NoArgs,
+	Short: "show how the netmaker firewall rules would handle a packet",
+	Long: `evaluates the netmaker firewall rules of the running daemon against a packet
+and reports whether it would be accepted, dropped or masqueraded and by which rule
+For example:
+netclient query-packet --src 10.0.0.5 --dst 192.168.1.10
+netclient query-packet --src 10.0.0.5 --dst 192.168.1.10 --proto tcp --port 443
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		var q router.PacketQuery
+		q.Src, _ = cmd.Flags().GetString("src")
+		q.Dst, _ = cmd.Flags().GetString("dst")
+		q.InIface, _ = cmd.Flags().GetString("iif")
+		q.OutIface, _ = cmd.Flags().GetString("oif")
+		q.Proto, _ = cmd.Flags().GetString("proto")
+		q.Port, _ = cmd.Flags().GetInt("port")
+		q.Established, _ = cmd.Flags().GetBool("established")
+		if err := functions.QueryPacket(q); err != nil {
+			fmt.Println(err.Error())
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(queryPacketCmd)
+	queryPacketCmd.Flags().String("src", "", "source ip of the packet")
+	queryPacketCmd.Flags().String("dst", "", "destination ip of the packet")
+	queryPacketCmd.Flags().String("iif", "", "interface the packet arrives on (defaults to the netmaker interface)")
+	queryPacketCmd.Flags().String("oif", "", "interface the packet leaves on")
+	queryPacketCmd.Flags().String("proto", "", "protocol of the packet")
+	queryPacketCmd.Flags().Int("port", 0, "destination port of the packet")
+	queryPacketCmd.Flags().Bool("established", false, "packet belongs to an established connection")
+	queryPacketCmd.MarkFlagRequired("src")
+	queryPacketCmd.MarkFlagRequired("dst")
+}
@@ -5,6 +5,7 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"syscall"
 
 	"github.com/gravitl/netclient/functions"
@@ -19,12 +20,14 @@ var registerFlags = struct {
 	Token       string
 	Network     string
 	AllNetworks string
+	DryRun      string
 }{
 	Server:      "server",
 	User:        "user",
 	Token:       "token",
 	Network:     "net",
 	AllNetworks: "all-networks",
+	DryRun:      "dry-run",
 }
 
 // registerCmd represents the register command
@@ -47,6 +50,7 @@ user: netclient register -s <server> -u <user_name> // attempt to join/register
 		} else {
 			if err := functions.Register(token); err != nil {
 				logger.Log(0, "registration failed", err.Error())
+				os.Exit(1)
 			}
 		}
 	},
@@ -0,0 +1,17 @@
+package config
+
+import (
+	"io"
+	"os"
+
+	"github.com/gravitl/netclient/ncutils"
+	"gopkg.in/yaml.v3"
+)
+
+// writeYAMLAtomic - encodes v to file through ncutils.WriteFileAtomic,
+// so a crash while writing leaves the previous file intact
+func writeYAMLAtomic(file string, v any) error {
+	return ncutils.WriteFileAtomic(file, os.ModePerm, func(w io.Writer) error {
+		return yaml.NewEncoder(w).Encode(v)
+	})
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/matryer/is"
+	"gopkg.in/yaml.v3"
+)
+
+type failingValue struct{}
+
+func (failingValue) MarshalYAML() (interface{}, error) {
+	return nil, errors.New("write interrupted")
+}
+
+func TestWriteYAMLAtomic(t *testing.T) {
+	is := is.New(t)
+	file := filepath.Join(t.TempDir(), "servers.yml")
+	good := map[string]string{"name": "good"}
+	is.NoErr(writeYAMLAtomic(file, good))
+
+	partial := map[string]any{"name": "partial", "broken": failingValue{}}
+	is.True(writeYAMLAtomic(file, partial) != nil)
+
+	data, err := os.ReadFile(file)
+	is.NoErr(err)
+	var read map[string]string
+	is.NoErr(yaml.Unmarshal(data, &read))
+	is.Equal(read, good)
+	_, err = os.Stat(file + ".tmp")
+	is.True(os.IsNotExist(err))
+}
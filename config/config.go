@@ -5,7 +5,9 @@ import (
 	"crypto/rand"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net"
+	"net/url"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -42,6 +44,15 @@ const (
 	DefaultListenPort = 51821
 	// DefaultMTU default MTU for wireguard
 	DefaultMTU = 1420
+	// InetGWPreferV4 - always use the IPv4 internet gateway when both IPv4 and IPv6 gateways are present,
+	// the IPv6 gateway only while it has a recent handshake
+	InetGWPreferV4 = "prefer-v4"
+	// InetGWPreferV6 - always use the IPv6 internet gateway when both IPv4 and IPv6 gateways are present,
+	// the IPv4 gateway only while it has a recent handshake
+	InetGWPreferV6 = "prefer-v6"
+	// InetGWPreferConnectivity - use each internet gateway with a recent handshake when both are present,
+	// the one with the most recent handshake if neither has one
+	InetGWPreferConnectivity = "prefer-connectivity"
 )
 
 var (
@@ -52,7 +63,7 @@ var (
 	GW4PeerDetected bool
 	// GW4Addr - the peer's address for IPv4 gateways
 	GW4Addr net.IPNet
-	// GW6PeerDetected - indicates if an IPv6 gwPeer (::/0) was found, currently unused
+	// GW6PeerDetected - indicates if an IPv6 gwPeer (::/0) was found
 	GW6PeerDetected bool
 	// GW6Addr - the peer's address for IPv6 gateways
 	GW6Addr net.IPNet
@@ -65,6 +76,149 @@ type Config struct {
 	TrafficKeyPrivate []byte                          `json:"traffickeyprivate" yaml:"traffickeyprivate"`
 	InternetGateway   net.UDPAddr                     `json:"internetgateway" yaml:"internetgateway"`
 	HostPeers         map[string][]wgtypes.PeerConfig `json:"peers" yaml:"peers"`
+	// InetGatewayPolicy - selects the internet gateways used when both IPv4 and IPv6 gateway peers are present,
+	// each family is routed through the gateway of its own family, one of prefer-v4 (default), prefer-v6 or prefer-connectivity
+	InetGatewayPolicy string `json:"inetgatewaypolicy,omitempty" yaml:"inetgatewaypolicy,omitempty"`
+	// ProxyLocalPort - source port for the proxy's local connections to the wireguard interface,
+	// validated and persisted on daemon startup
+	ProxyLocalPort int `json:"proxylocalport,omitempty" yaml:"proxylocalport,omitempty"`
+	// VerifyFirewallRules - confirms gateway rules are present in the firewall after they are inserted
+	VerifyFirewallRules bool `json:"verifyfirewallrules,omitempty" yaml:"verifyfirewallrules,omitempty"`
+	// IfaceLookupAttempts - times the interface is looked up before firewall rules are built, defaults to 5
+	IfaceLookupAttempts int `json:"ifacelookupattempts,omitempty" yaml:"ifacelookupattempts,omitempty"`
+	// PeerDSCP - DSCP value to mark proxied packets with, by peer public key
+	PeerDSCP map[string]int `json:"peerdscp,omitempty" yaml:"peerdscp,omitempty"`
+	// MaxProxyConns - limit on concurrent proxy connections, 0 for no limit
+	MaxProxyConns int `json:"maxproxyconns,omitempty" yaml:"maxproxyconns,omitempty"`
+	// ProxyConnLimitPolicy - handling of peers beyond MaxProxyConns, reject-new (default) or evict-idle
+	ProxyConnLimitPolicy string `json:"proxyconnlimitpolicy,omitempty" yaml:"proxyconnlimitpolicy,omitempty"`
+	// ExtClientRuleTTL - minutes without a handshake after which ext client ingress rules are removed, 0 disables
+	ExtClientRuleTTL int `json:"extclientrulettl,omitempty" yaml:"extclientrulettl,omitempty"`
+	// HostUpdateAckTimeout - seconds to wait for a server to apply a critical host update, defaults to 30
+	HostUpdateAckTimeout int `json:"hostupdateacktimeout,omitempty" yaml:"hostupdateacktimeout,omitempty"`
+	// HostUpdateAttempts - times a critical host update is published before giving up, defaults to 3
+	HostUpdateAttempts int `json:"hostupdateattempts,omitempty" yaml:"hostupdateattempts,omitempty"`
+	// NftablesLastingConn - keeps one netlink socket open for all nftables operations instead of one per batch
+	NftablesLastingConn bool `json:"nftableslastingconn,omitempty" yaml:"nftableslastingconn,omitempty"`
+	// EgressNoMasqRanges - destination ranges egress gateway traffic is forwarded to without masquerading
+	EgressNoMasqRanges []string `json:"egressnomasqranges,omitempty" yaml:"egressnomasqranges,omitempty"`
+	// HealthWarmup - seconds after startup during which failing health checks report starting, defaults to 60
+	HealthWarmup int `json:"healthwarmup,omitempty" yaml:"healthwarmup,omitempty"`
+	// ProbePeerMTU - probes the path MTU to peers at checkin and lowers the route MTU of peers below the local MTU
+	ProbePeerMTU bool `json:"probepeermtu,omitempty" yaml:"probepeermtu,omitempty"`
+	// MalformedNodePolicy - handling of nodes pulled from the server that can't be converted, skip (default) or abort
+	MalformedNodePolicy string `json:"malformednodepolicy,omitempty" yaml:"malformednodepolicy,omitempty"`
+	// AuthTokenTTL - seconds an api token is reused for requests to the same server, 300 if not set
+	AuthTokenTTL int `json:"authtokenttl,omitempty" yaml:"authtokenttl,omitempty"`
+	// ServerPriority - names of servers to set up before the others, in order, e.g. the server providing the internet gateway
+	ServerPriority []string `json:"serverpriority,omitempty" yaml:"serverpriority,omitempty"`
+	// DecryptFailureThreshold - consecutive messages of a server failing to decrypt before DecryptFailurePolicy applies, 5 if not set
+	DecryptFailureThreshold int `json:"decryptfailurethreshold,omitempty" yaml:"decryptfailurethreshold,omitempty"`
+	// DecryptFailurePolicy - handling of a server whose messages keep failing to decrypt, log (default), repull or disconnect
+	DecryptFailurePolicy string `json:"decryptfailurepolicy,omitempty" yaml:"decryptfailurepolicy,omitempty"`
+	// StatusPage - serves a html status page at /ui of the daemon http server
+	StatusPage bool `json:"statuspage,omitempty" yaml:"statuspage,omitempty"`
+	// EgressOutboundOnly - egress ranges may only answer connections initiated by peers, connections from the ranges are dropped
+	EgressOutboundOnly bool `json:"egressoutboundonly,omitempty" yaml:"egressoutboundonly,omitempty"`
+	// ResetWindow - seconds reset signals are collected for before the daemon resets once, 2 if not set
+	ResetWindow int `json:"resetwindow,omitempty" yaml:"resetwindow,omitempty"`
+	// ResetMinInterval - minimum seconds between two resets of the daemon, 30 if not set
+	ResetMinInterval int `json:"resetmininterval,omitempty" yaml:"resetmininterval,omitempty"`
+	// FallbackNatType - nat type assumed when no stun server answers, asymmetric (relay through turn) if not set
+	FallbackNatType string `json:"fallbacknattype,omitempty" yaml:"fallbacknattype,omitempty"`
+	// AckTimeout - seconds to wait for the server to answer the initial ACK before sending it again,
+	// the connection to a server is only established once it answered, 0 does not wait
+	AckTimeout int `json:"acktimeout,omitempty" yaml:"acktimeout,omitempty"`
+	// FirewallBackend - firewall backend to manage the gateway rules with, iptables or nftables,
+	// selected from what the host supports if not set
+	FirewallBackend string `json:"firewallbackend,omitempty" yaml:"firewallbackend,omitempty"`
+	// ExtClientIsolation - drops the traffic between the ext. clients of an ingress gateway,
+	// ext. clients only reach the peers and egress ranges behind the gateway
+	ExtClientIsolation bool `json:"extclientisolation,omitempty" yaml:"extclientisolation,omitempty"`
+	// EgressDenyLog - logs the traffic from peers the gateway drops as it is not allowed to any of its ranges,
+	// off by default
+	EgressDenyLog bool `json:"egressdenylog,omitempty" yaml:"egressdenylog,omitempty"`
+	// IfaceUpWait - seconds to wait for the interface to be up with its addresses assigned before applying peers,
+	// defaults to 10
+	IfaceUpWait int `json:"ifaceupwait,omitempty" yaml:"ifaceupwait,omitempty"`
+	// NodeConflictPolicy - handling of a node for a network the host already has a node with a different id for,
+	// keep (default) or replace
+	NodeConflictPolicy string `json:"nodeconflictpolicy,omitempty" yaml:"nodeconflictpolicy,omitempty"`
+	// NatPolicy - connection to use with a peer by nat types of the host and peer, keyed by "hostnat:peernat"
+	// in either order and set to direct, proxy or turn, overriding the default of turn between
+	// asymmetric and double nats and direct otherwise
+	NatPolicy map[string]string `json:"natpolicy,omitempty" yaml:"natpolicy,omitempty"`
+	// MQBackoffMin - seconds to wait before the first broker connection retry, doubled on each failed retry,
+	// defaults to 2
+	MQBackoffMin int `json:"mqbackoffmin,omitempty" yaml:"mqbackoffmin,omitempty"`
+	// MQBackoffMax - maximum seconds to wait between broker connection retries, defaults to 120
+	MQBackoffMax int `json:"mqbackoffmax,omitempty" yaml:"mqbackoffmax,omitempty"`
+	// PrometheusMetrics - serves the peer metrics in prometheus text format at /metrics of the daemon http server,
+	// which only listens on localhost
+	PrometheusMetrics bool `json:"prometheusmetrics,omitempty" yaml:"prometheusmetrics,omitempty"`
+	// StableIPv6 - stable ipv6 address to use for the endpoint and stun instead of a temporary (privacy extension) one,
+	// an eui-64 or other stable address of the same prefix is used when not set
+	StableIPv6 string `json:"stableipv6,omitempty" yaml:"stableipv6,omitempty"`
+	// AllowTemporaryIPv6 - use temporary ipv6 addresses for the endpoint and stun as picked by the system
+	AllowTemporaryIPv6 bool `json:"allowtemporaryipv6,omitempty" yaml:"allowtemporaryipv6,omitempty"`
+	// PeerApplyTimeout - seconds a peer update may take to apply to the interface before the previous peers
+	// are restored, defaults to 30
+	PeerApplyTimeout int `json:"peerapplytimeout,omitempty" yaml:"peerapplytimeout,omitempty"`
+	// ServerRouteRetries - times setting the routes of a server is retried with backoff when it fails,
+	// before the failure is reported in the health status, defaults to 5
+	ServerRouteRetries int `json:"serverrouteretries,omitempty" yaml:"serverrouteretries,omitempty"`
+	// RequireServerRoute - a server is not considered connected by the health checks until its routes are set
+	RequireServerRoute bool `json:"requireserverroute,omitempty" yaml:"requireserverroute,omitempty"`
+	// IngressMasquerade - masquerading of the ext. client traffic of an ingress gateway by ip family, keyed by
+	// ipv4 or ipv6, overriding the masquerade setting the server sends for the ext. clients of that family
+	IngressMasquerade map[string]bool `json:"ingressmasquerade,omitempty" yaml:"ingressmasquerade,omitempty"`
+	// StaleHandshakeInterval - seconds between the checks for proxied peers with a stale handshake, defaults to 60
+	StaleHandshakeInterval int `json:"stalehandshakeinterval,omitempty" yaml:"stalehandshakeinterval,omitempty"`
+	// StaleHandshakeThreshold - seconds since the last handshake after which the proxy connection of a peer
+	// is reset, defaults to 180
+	StaleHandshakeThreshold int `json:"stalehandshakethreshold,omitempty" yaml:"stalehandshakethreshold,omitempty"`
+	// ProxyDSCP - DSCP value to mark the proxied packets of peers without an entry in PeerDSCP with
+	ProxyDSCP int `json:"proxydscp,omitempty" yaml:"proxydscp,omitempty"`
+	// SkipPeerValidation - apply the peers of server updates without checking their keys and allowed ips
+	SkipPeerValidation bool `json:"skippeervalidation,omitempty" yaml:"skippeervalidation,omitempty"`
+	// DisableForwardingWithoutGateway - turn ip forwarding off while the host is not a gateway or relay for any server
+	DisableForwardingWithoutGateway bool `json:"disableforwardingwithoutgateway,omitempty" yaml:"disableforwardingwithoutgateway,omitempty"`
+	// ServerSetupConcurrency - servers whose routes and connection are set up at the same time on startup,
+	// after the priority servers, defaults to 4
+	ServerSetupConcurrency int `json:"serversetupconcurrency,omitempty" yaml:"serversetupconcurrency,omitempty"`
+	// NatDetectInterval - minutes between re-detections of the nat type through the stun servers, defaults to 30
+	NatDetectInterval int `json:"natdetectinterval,omitempty" yaml:"natdetectinterval,omitempty"`
+	// ForwardDropPolicy - sets the policy of the forward chain to drop, traffic not accepted by a rule is dropped,
+	// the policy is applied together with the netmaker jump rules
+	ForwardDropPolicy bool `json:"forwarddroppolicy,omitempty" yaml:"forwarddroppolicy,omitempty"`
+	// PullAttempts - attempts to pull the host config from a server that fails with a network error or 5xx response,
+	// with backoff between attempts, defaults to 3
+	PullAttempts int `json:"pullattempts,omitempty" yaml:"pullattempts,omitempty"`
+	// StunTimeout - seconds a stun server has to answer a query before the next one is used, defaults to 5
+	StunTimeout int `json:"stuntimeout,omitempty" yaml:"stuntimeout,omitempty"`
+	// StunConcurrency - stun servers queried at the same time when detecting the nat type, defaults to 2
+	StunConcurrency int `json:"stunconcurrency,omitempty" yaml:"stunconcurrency,omitempty"`
+	// PinDefaultInterface - keeps the configured default interface, it is not re-detected when the default route moves
+	PinDefaultInterface bool `json:"pindefaultinterface,omitempty" yaml:"pindefaultinterface,omitempty"`
+	// APITimeout - how long a request to the server api may take, e.g. 45s, defaults to 30s
+	APITimeout time.Duration `json:"apitimeout,omitempty" yaml:"apitimeout,omitempty"`
+	// APICAFile - PEM file of the CA certificates the server api certificate is verified against,
+	// instead of the system CAs, for servers with a private CA
+	APICAFile string `json:"apicafile,omitempty" yaml:"apicafile,omitempty"`
+	// APIInsecureSkipVerify - skips verification of the server api certificate
+	APIInsecureSkipVerify bool `json:"apiinsecureskipverify,omitempty" yaml:"apiinsecureskipverify,omitempty"`
+	// StrictMTU - fails configuring the interface when it rejects the MTU, instead of clamping the MTU
+	// to the largest the interface accepts
+	StrictMTU bool `json:"strictmtu,omitempty" yaml:"strictmtu,omitempty"`
+	// APIProxyURL - http(s) or socks5 proxy the requests to the server api go through, when unset the
+	// HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables apply
+	APIProxyURL string `json:"apiproxyurl,omitempty" yaml:"apiproxyurl,omitempty"`
+	// PeerEndpointRouteScope - routes to peer endpoints through the original gateway, host (default) for a route
+	// per endpoint or subnet to route endpoints sharing a /24 (/64 for ipv6) through one covering route
+	PeerEndpointRouteScope string `json:"peerendpointroutescope,omitempty" yaml:"peerendpointroutescope,omitempty"`
+	// HTTPPort - port of the daemon http server on 127.0.0.1, serving the gui, health and metrics, defaults to 18515,
+	// a free port is used when the default is taken
+	HTTPPort int `json:"httpport,omitempty" yaml:"httpport,omitempty"`
 }
 
 func init() {
@@ -235,16 +389,7 @@ func WriteNetclientConfig() error {
 		return errors.New("failed to obtain lockfile")
 	}
 	defer Unlock(lockfile)
-	f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-	err = yaml.NewEncoder(f).Encode(netclient)
-	if err != nil {
-		return err
-	}
-	return f.Sync()
+	return writeYAMLAtomic(file, netclient)
 }
 
 // GetNetclientPath - returns path to netclient config directory
@@ -593,11 +738,35 @@ func CheckConfig() {
 			logger.Log(0, "configuration for", node.Server, "is missing")
 		}
 	}
+	if _, err := ParseAPIProxyURL(netclient.APIProxyURL); err != nil {
+		fail = true
+		logger.Log(0, "apiproxyurl is invalid:", err.Error())
+	}
 	if fail {
 		logger.FatalLog("configuration is invalid, fix before proceeding")
 	}
 }
 
+// ParseAPIProxyURL - parses the proxy of the requests to the server api, nil if none is set
+func ParseAPIProxyURL(raw string) (*url.URL, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	proxyURL, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", proxyURL.Scheme)
+	}
+	if proxyURL.Host == "" {
+		return nil, fmt.Errorf("proxy %s has no host", proxyURL.Redacted())
+	}
+	return proxyURL, nil
+}
+
 // Convert converts netclient host/node struct to netmaker host/node structs
 func Convert(h *Config, n *Node) (models.Host, models.Node) {
 	var host models.Host
@@ -625,12 +794,11 @@ func detectOrFilterGWPeers(server string, peers []wgtypes.PeerConfig) bool {
 		if GW4PeerDetected || GW6PeerDetected { // check if there is a change in GWs before proceeding
 			for i := range peers {
 				peer := peers[i]
-				if peerHasIp(&GW4Addr, peer.AllowedIPs[:]) && peer.Remove { // Indicates a removal of current gw, set detected to false to recalc
+				if GW4PeerDetected && peerHasIp(&GW4Addr, peer.AllowedIPs[:]) && peer.Remove { // Indicates a removal of current gw, set detected to false to recalc
 					GW4PeerDetected = false
-					break
-				} else if peerHasIp(&GW6Addr, peer.AllowedIPs[:]) { // TODO (IPv6)
+				}
+				if GW6PeerDetected && peerHasIp(&GW6Addr, peer.AllowedIPs[:]) && peer.Remove {
 					GW6PeerDetected = false
-					break
 				}
 			}
 		}
@@ -661,7 +829,7 @@ func detectOrFilterGWPeers(server string, peers []wgtypes.PeerConfig) bool {
 						GW6PeerDetected = true
 						foundGW6Again = true
 						GW6Addr = peer.AllowedIPs[j-1]
-					} else if peerHasIp(&ip, peer.AllowedIPs[:]) {
+					} else if peerHasIp(&GW6Addr, peer.AllowedIPs[:]) {
 						foundGW6Again = true
 					}
 				}
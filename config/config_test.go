@@ -0,0 +1,39 @@
+package config
+
+import (
+	"net"
+	"testing"
+
+	"github.com/matryer/is"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestDetectIPv6OnlyGateway(t *testing.T) {
+	is := is.New(t)
+	Servers = map[string]Server{}
+	Nodes = map[string]Node{}
+	key, err := wgtypes.GeneratePrivateKey()
+	is.NoErr(err)
+	_, peerAddr, _ := net.ParseCIDR("fd00::5/128")
+	_, allV6, _ := net.ParseCIDR("::/0")
+	gwPeer := wgtypes.PeerConfig{
+		PublicKey:  key.PublicKey(),
+		AllowedIPs: []net.IPNet{*peerAddr, *allV6},
+	}
+	t.Run("v6 gateway detected", func(t *testing.T) {
+		UpdateHostPeers("server", []wgtypes.PeerConfig{gwPeer})
+		is.True(!GW4PeerDetected)
+		is.True(GW6PeerDetected)
+		is.True(GW6Addr.IP.Equal(peerAddr.IP))
+	})
+	t.Run("v6 gateway still present", func(t *testing.T) {
+		UpdateHostPeers("server", []wgtypes.PeerConfig{gwPeer})
+		is.True(GW6PeerDetected)
+	})
+	t.Run("v6 gateway removed", func(t *testing.T) {
+		removed := gwPeer
+		removed.Remove = true
+		UpdateHostPeers("server", []wgtypes.PeerConfig{removed})
+		is.True(!GW6PeerDetected)
+	})
+}
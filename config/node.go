@@ -4,11 +4,14 @@ package config
 import (
 	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net"
 	"os"
 	"path/filepath"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/gravitl/netclient/ncutils"
 	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/models"
@@ -24,6 +27,17 @@ var Nodes NodeMap
 // NodeLockFile is name of lockfile for controlling access to node config file on disk
 const NodeLockfile = "netclient-nodes.lck"
 
+// policies for a node of a network the node map holds a node with a different id for
+const (
+	// NodeConflictKeep - keep the current node of the network
+	NodeConflictKeep = "keep"
+	// NodeConflictReplace - replace the current node of the network with the new one
+	NodeConflictReplace = "replace"
+)
+
+// ErrNodeConflict - the node map holds a node with a different id for the network
+var ErrNodeConflict = errors.New("network already has a node with a different id")
+
 // Node provides configuration of a node
 type Node struct {
 	models.CommonNode
@@ -45,9 +59,13 @@ func ReadNodeConfig() error {
 	for k := range Nodes {
 		delete(Nodes, k)
 	}
+	for k := range invalidServerNodes {
+		delete(invalidServerNodes, k)
+	}
 	if err := yaml.NewDecoder(f).Decode(&Nodes); err != nil {
 		return err
 	}
+	quarantineNodes()
 	return nil
 }
 
@@ -81,9 +99,25 @@ func UpdateNodeMap(k string, value Node) {
 	Nodes[k] = value
 }
 
+// UpdateNodeMapResolved updates the in memory nodemap for the specified network like UpdateNodeMap,
+// if the nodemap holds a node with a different id for the network the conflict is resolved by the policy:
+// keep (default) leaves the current node in place and returns ErrNodeConflict, replace applies the new node
+func UpdateNodeMapResolved(k string, value Node, policy string) error {
+	current, ok := Nodes[k]
+	if ok && current.ID != value.ID {
+		if policy != NodeConflictReplace {
+			return fmt.Errorf("%w: keeping node %s, ignoring node %s", ErrNodeConflict, current.ID, value.ID)
+		}
+		logger.Log(0, "network", k, "replacing node", current.ID.String(), "with conflicting node", value.ID.String())
+	}
+	Nodes[k] = value
+	return nil
+}
+
 // DeleteNode deletes the node from the nodemap for the specified network
 func DeleteNode(k string) {
 	delete(Nodes, k)
+	delete(invalidServerNodes, k)
 }
 
 // PrimaryAddress returns the primary address of a node
@@ -114,21 +148,19 @@ func WriteNodeConfig() error {
 		return err
 	}
 	defer Unlock(lockfile)
-	f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-	err = yaml.NewEncoder(f).Encode(Nodes)
-	if err != nil {
-		return err
-	}
-	return f.Sync()
+	return writeYAMLAtomic(file, nodesToWrite())
 }
 
-// ConvertNode accepts a netmaker node struct and converts to the structs used by netclient
-func ConvertNode(nodeGet *models.NodeGet) *Node {
+// ConvertNode accepts a netmaker node struct and converts to the structs used by netclient,
+// returns an error if the node is missing fields the client needs to configure the interface
+func ConvertNode(nodeGet *models.NodeGet) (*Node, error) {
+	if nodeGet == nil {
+		return nil, errors.New("node is nil")
+	}
 	netmakerNode := nodeGet.Node
+	if err := validateNode(&netmakerNode); err != nil {
+		return nil, fmt.Errorf("malformed node %s: %w", netmakerNode.ID, err)
+	}
 	//server := GetServer(netmakerNode.Server)
 	//if server == nil {
 	//server = ConvertServerCfg(nodeGet.ServerConfig)
@@ -154,7 +186,51 @@ func ConvertNode(nodeGet *models.NodeGet) *Node {
 	node.DNSOn = nodeGet.Node.DNSOn
 	//node.Peers = nodeGet.Peers
 	//add items not provided by server
-	return &node
+	return &node, nil
+}
+
+// validateNode - checks the fields of a node received from the server are usable
+func validateNode(node *models.Node) error {
+	if node.ID == uuid.Nil {
+		return errors.New("missing id")
+	}
+	if node.Network == "" {
+		return errors.New("missing network")
+	}
+	if node.Server == "" {
+		return errors.New("missing server")
+	}
+	if node.Address.IP == nil && node.Address6.IP == nil {
+		return errors.New("no address")
+	}
+	if err := validateAddress(node.Address, node.NetworkRange, false); err != nil {
+		return fmt.Errorf("address: %w", err)
+	}
+	if err := validateAddress(node.Address6, node.NetworkRange6, true); err != nil {
+		return fmt.Errorf("address6: %w", err)
+	}
+	if node.PersistentKeepalive < 0 {
+		return errors.New("negative persistent keepalive")
+	}
+	return nil
+}
+
+// validateAddress - checks an address has a mask of its ip family and is within the network range if both are set
+func validateAddress(addr, networkRange net.IPNet, isIpv6 bool) error {
+	if addr.IP == nil {
+		return nil
+	}
+	if (addr.IP.To4() == nil) != isIpv6 {
+		return fmt.Errorf("%s is not of the expected ip family", addr.IP)
+	}
+	_, bits := addr.Mask.Size()
+	if bits == 0 || (isIpv6 && bits != net.IPv6len*8) || (!isIpv6 && bits != net.IPv4len*8) {
+		return fmt.Errorf("%s has an invalid mask", addr.IP)
+	}
+	if networkRange.IP != nil && !networkRange.Contains(addr.IP) {
+		return fmt.Errorf("%s is outside network range %s", addr.IP, networkRange.String())
+	}
+	return nil
 }
 
 // ConvertToNetmakerNode converts a netclient node to a netmaker node
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+)
+
+func TestUpdateNodeMapResolved(t *testing.T) {
+	is := is.New(t)
+	newNode := func() Node {
+		node := Node{}
+		node.ID = uuid.New()
+		node.Network = "netmaker"
+		return node
+	}
+	existing, conflicting := newNode(), newNode()
+
+	t.Run("same node", func(t *testing.T) {
+		Nodes = NodeMap{"netmaker": existing}
+		update := existing
+		update.Connected = true
+		is.NoErr(UpdateNodeMapResolved("netmaker", update, ""))
+		is.True(Nodes["netmaker"].Connected)
+	})
+	t.Run("conflict kept", func(t *testing.T) {
+		Nodes = NodeMap{"netmaker": existing}
+		err := UpdateNodeMapResolved("netmaker", conflicting, NodeConflictKeep)
+		is.True(errors.Is(err, ErrNodeConflict))
+		is.Equal(Nodes["netmaker"].ID, existing.ID)
+	})
+	t.Run("conflict replaced", func(t *testing.T) {
+		Nodes = NodeMap{"netmaker": existing}
+		is.NoErr(UpdateNodeMapResolved("netmaker", conflicting, NodeConflictReplace))
+		is.Equal(Nodes["netmaker"].ID, conflicting.ID)
+	})
+}
@@ -2,6 +2,7 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
@@ -28,6 +29,18 @@ type Server struct {
 	MQID      uuid.UUID       `json:"mqid" yaml:"mqid"`
 	Nodes     map[string]bool `json:"nodes" yaml:"nodes"`
 	AccessKey string          `json:"accesskey" yaml:"accesskey"`
+	// TurnFallbacks - additional turn servers to fail over to when the server's turn is unreachable
+	TurnFallbacks []TurnConfig `json:"turnfallbacks,omitempty" yaml:"turnfallbacks,omitempty"`
+	// ProxyDisabled - peers of this server are not proxied even when the host has proxy enabled
+	ProxyDisabled bool `json:"proxydisabled,omitempty" yaml:"proxydisabled,omitempty"`
+	// BrokerFallbacks - additional brokers of the server, tried in order when the broker is unreachable
+	BrokerFallbacks []string `json:"brokerfallbacks,omitempty" yaml:"brokerfallbacks,omitempty"`
+	// MQTLSCert - PEM client certificate to authenticate to the broker with instead of username and password
+	MQTLSCert string `json:"mqtlscert,omitempty" yaml:"mqtlscert,omitempty"`
+	// MQTLSKey - PEM key of the client certificate
+	MQTLSKey string `json:"mqtlskey,omitempty" yaml:"mqtlskey,omitempty"`
+	// MQCAFile - PEM CA certificate(s) the broker certificate is verified with, the system roots if not set
+	MQCAFile string `json:"mqcafile,omitempty" yaml:"mqcafile,omitempty"`
 }
 
 // OldNetmakerServerConfig - pre v0.18.0 server configuration
@@ -41,16 +54,21 @@ type OldNetmakerServerConfig struct {
 	MQPort      string `yaml:"mqport"`
 	Server      string `yaml:"server"`
 	Is_EE       bool   `yaml:"isee"`
+	// BrokerFallbacks - additional brokers of the server
+	BrokerFallbacks []string `yaml:"brokerfallbacks"`
 }
 
 // TurnConfig - struct to hold turn server config
 type TurnConfig struct {
-	Server string
-	Domain string
-	Port   int
+	Server string `json:"server,omitempty" yaml:"server,omitempty"`
+	Domain string `json:"domain" yaml:"domain"`
+	Port   int    `json:"port" yaml:"port"`
 }
 
-// ReadServerConf reads the servers configuration file and populates the server map
+// ReadServerConf reads the servers configuration file and populates the server map,
+// the broker and api of a server are overridden by the NETCLIENT_BROKER_<server> and NETCLIENT_API_<server>
+// environment variables when set (env > file), servers failing validation are left out of the server map
+// and reported in an error wrapping ErrInvalidServer
 func ReadServerConf() error {
 	lockfile := filepath.Join(os.TempDir(), ServerLockfile)
 	file := GetNetclientPath() + "servers.yml"
@@ -69,7 +87,10 @@ func ReadServerConf() error {
 	if err := yaml.NewDecoder(f).Decode(&Servers); err != nil {
 		return err
 	}
-	return nil
+	applyServerEnvOverrides(Servers)
+	err = validateServers(Servers)
+	quarantineNodes()
+	return err
 }
 
 // WriteServerConfig writes server map to disk
@@ -92,16 +113,7 @@ func WriteServerConfig() error {
 		return err
 	}
 	defer Unlock(lockfile)
-	f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-	err = yaml.NewEncoder(f).Encode(Servers)
-	if err != nil {
-		return err
-	}
-	return f.Sync()
+	return writeYAMLAtomic(file, serversToWrite())
 }
 
 // SaveServer updates the server map with current server struct and writes map to disk
@@ -134,10 +146,19 @@ func GetServers() (servers []string) {
 // DeleteServer deletes the specified server name from the server map
 func DeleteServer(k string) {
 	delete(Servers, k)
+	delete(invalidServers, k)
+	for network, node := range invalidServerNodes {
+		if node.Server == k {
+			delete(invalidServerNodes, network)
+		}
+	}
 }
 
-// ConvertServerCfg converts a netmaker ServerConfig to netclient server struct
+// ConvertServerCfg converts a netmaker ServerConfig to netclient server struct, nil if no config is given
 func ConvertServerCfg(cfg *OldNetmakerServerConfig) *Server {
+	if cfg == nil {
+		return nil
+	}
 	var server Server
 	server.Name = strings.Replace(cfg.Server, "broker.", "", 1)
 	server.Version = cfg.Version
@@ -148,10 +169,25 @@ func ConvertServerCfg(cfg *OldNetmakerServerConfig) *Server {
 	server.CoreDNSAddr = cfg.CoreDNSAddr
 	server.Is_EE = cfg.Is_EE
 	server.DNSMode = cfg.DNSMode
+	server.BrokerFallbacks = cfg.BrokerFallbacks
 	server.Nodes = make(map[string]bool)
 	return &server
 }
 
+// Server.Brokers - returns the broker of the server followed by its fallbacks, without duplicates
+func (server *Server) Brokers() []string {
+	brokers := []string{}
+	seen := map[string]bool{}
+	for _, broker := range append([]string{server.Broker}, server.BrokerFallbacks...) {
+		if broker == "" || seen[broker] {
+			continue
+		}
+		seen[broker] = true
+		brokers = append(brokers, broker)
+	}
+	return brokers
+}
+
 // UpdateServerConfig updates the in memory server map with values provided from netmaker server
 func UpdateServerConfig(cfg *models.ServerConfig) {
 	if cfg == nil {
@@ -188,3 +224,27 @@ func GetAllTurnConfigs() (turnList []TurnConfig) {
 	}
 	return
 }
+
+// GetTurnServers - returns the turn servers configured for a server in order of preference,
+// the server's own turn followed by any configured fallbacks
+func GetTurnServers(serverName string) (turnList []TurnConfig) {
+	server := GetServer(serverName)
+	if server == nil || !server.UseTurn {
+		return
+	}
+	seen := make(map[string]struct{})
+	candidates := append([]TurnConfig{{Domain: server.TurnDomain, Port: server.TurnPort}}, server.TurnFallbacks...)
+	for _, candidate := range candidates {
+		if candidate.Domain == "" || candidate.Port == 0 {
+			continue
+		}
+		key := fmt.Sprintf("%s:%d", candidate.Domain, candidate.Port)
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		candidate.Server = serverName
+		turnList = append(turnList, candidate)
+	}
+	return
+}
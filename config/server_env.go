@@ -0,0 +1,60 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/gravitl/netmaker/logger"
+)
+
+const (
+	// brokerEnvPrefix - prefix of the environment variable overriding the broker of a server
+	brokerEnvPrefix = "NETCLIENT_BROKER_"
+	// apiEnvPrefix - prefix of the environment variable overriding the api of a server
+	apiEnvPrefix = "NETCLIENT_API_"
+)
+
+// serverEnvKey - returns the environment variable name for the server, the server name is upper cased
+// and every character other than a letter or digit is replaced by an underscore,
+// eg. NETCLIENT_BROKER_NM_EXAMPLE_COM for server nm.example.com
+func serverEnvKey(prefix, server string) string {
+	return prefix + strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, strings.ToUpper(server))
+}
+
+// applyServerEnvOverrides - overrides the broker and api of the servers with the values of their environment variables,
+// a set environment variable takes precedence over the value from the servers file,
+// a broker override that doesn't parse as a url is ignored
+func applyServerEnvOverrides(servers map[string]Server) {
+	for name, server := range servers {
+		if broker, ok := os.LookupEnv(serverEnvKey(brokerEnvPrefix, name)); ok {
+			if err := validateBrokerURL(broker); err != nil {
+				logger.Log(0, "ignoring broker override for server", name, err.Error())
+			} else {
+				server.Broker = broker
+			}
+		}
+		if api, ok := os.LookupEnv(serverEnvKey(apiEnvPrefix, name)); ok && api != "" {
+			server.API = api
+		}
+		servers[name] = server
+	}
+}
+
+// validateBrokerURL - checks the broker is a url with a scheme and host
+func validateBrokerURL(broker string) error {
+	u, err := url.Parse(broker)
+	if err != nil {
+		return fmt.Errorf("invalid broker url %q: %w", broker, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("invalid broker url %q: missing scheme or host", broker)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestApplyServerEnvOverrides(t *testing.T) {
+	is := is.New(t)
+	is.Equal(serverEnvKey(brokerEnvPrefix, "nm.example-1.com"), "NETCLIENT_BROKER_NM_EXAMPLE_1_COM")
+	newServers := func() map[string]Server {
+		server := Server{}
+		server.Broker = "wss://broker.nm.example.com"
+		server.API = "api.nm.example.com"
+		return map[string]Server{"nm.example.com": server}
+	}
+
+	t.Run("absent", func(t *testing.T) {
+		servers := newServers()
+		applyServerEnvOverrides(servers)
+		is.Equal(servers["nm.example.com"].Broker, "wss://broker.nm.example.com")
+		is.Equal(servers["nm.example.com"].API, "api.nm.example.com")
+	})
+	t.Run("present", func(t *testing.T) {
+		t.Setenv("NETCLIENT_BROKER_NM_EXAMPLE_COM", "mqtts://10.0.0.1:8883")
+		t.Setenv("NETCLIENT_API_NM_EXAMPLE_COM", "10.0.0.1:8081")
+		servers := newServers()
+		applyServerEnvOverrides(servers)
+		is.Equal(servers["nm.example.com"].Broker, "mqtts://10.0.0.1:8883")
+		is.Equal(servers["nm.example.com"].API, "10.0.0.1:8081")
+	})
+	t.Run("malformed broker", func(t *testing.T) {
+		t.Setenv("NETCLIENT_BROKER_NM_EXAMPLE_COM", "broker without scheme")
+		servers := newServers()
+		applyServerEnvOverrides(servers)
+		is.Equal(servers["nm.example.com"].Broker, "wss://broker.nm.example.com")
+	})
+}
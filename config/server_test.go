@@ -0,0 +1,39 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/matryer/is"
+)
+
+func TestConvertServerCfg(t *testing.T) {
+	is := is.New(t)
+	is.True(ConvertServerCfg(nil) == nil)
+	netclient.ID = uuid.New()
+	for _, dnsMode := range []string{"true", "false", ""} {
+		cfg := &OldNetmakerServerConfig{
+			CoreDNSAddr:     "10.0.0.1",
+			API:             "api.example.com:443",
+			DNSMode:         dnsMode,
+			Version:         "v0.17.1",
+			MQPort:          "8883",
+			Server:          "broker.example.com",
+			Is_EE:           true,
+			BrokerFallbacks: []string{"ssl://broker2.example.com:8883"},
+		}
+		server := ConvertServerCfg(cfg)
+		is.True(server != nil)
+		is.Equal(server.Name, "example.com")
+		is.Equal(server.Version, cfg.Version)
+		is.Equal(server.Broker, cfg.Server)
+		is.Equal(server.MQPort, cfg.MQPort)
+		is.Equal(server.MQID, netclient.ID)
+		is.Equal(server.API, cfg.API)
+		is.Equal(server.CoreDNSAddr, cfg.CoreDNSAddr)
+		is.Equal(server.Is_EE, cfg.Is_EE)
+		is.Equal(server.DNSMode, dnsMode)
+		is.Equal(server.BrokerFallbacks, cfg.BrokerFallbacks)
+		is.True(server.Nodes != nil)
+	}
+}
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidServer - one or more servers read from the servers file are misconfigured
+var ErrInvalidServer = errors.New("invalid server config")
+
+// invalidServers - servers read from the servers file that failed validation, by name,
+// left out of the server map but kept when the servers file is written
+var invalidServers = map[string]Server{}
+
+// invalidServerNodes - nodes of the invalid servers, by network, left out of the node map
+// so no caller looks up their server, but kept when the nodes file is written
+var invalidServerNodes = map[string]Node{}
+
+// Server.Validate - checks the server has a name, a broker url the client can connect to, an api address
+// and a numeric mq port, the error lists every field that is wrong
+func (server *Server) Validate() error {
+	issues := []string{}
+	if server.Name == "" {
+		issues = append(issues, "name: missing")
+	}
+	if err := validateBroker(server.Broker); err != nil {
+		issues = append(issues, "broker: "+err.Error())
+	}
+	for _, fallback := range server.BrokerFallbacks {
+		if err := validateBroker(fallback); err != nil {
+			issues = append(issues, "brokerfallbacks: "+err.Error())
+		}
+	}
+	if err := validateAPI(server.API); err != nil {
+		issues = append(issues, "api: "+err.Error())
+	}
+	// the broker url carries the port the client connects to, an empty mq port is accepted
+	if server.MQPort != "" {
+		if port, err := strconv.Atoi(server.MQPort); err != nil || port <= 0 || port > 65535 {
+			issues = append(issues, fmt.Sprintf("mqport: %q is not a port", server.MQPort))
+		}
+	}
+	if len(issues) > 0 {
+		return errors.New(strings.Join(issues, ", "))
+	}
+	return nil
+}
+
+// validateBroker - checks the broker is a url with a scheme and host, tcp based brokers need a port as well,
+// websocket brokers default to the port of their scheme
+func validateBroker(broker string) error {
+	if broker == "" {
+		return errors.New("missing")
+	}
+	if err := validateBrokerURL(broker); err != nil {
+		return err
+	}
+	u, _ := url.Parse(broker)
+	switch u.Scheme {
+	case "ws", "wss":
+	default:
+		if u.Port() == "" {
+			return fmt.Errorf("%q has no port", broker)
+		}
+	}
+	return nil
+}
+
+// validateAPI - checks the api is a host with an optional port
+func validateAPI(api string) error {
+	if api == "" {
+		return errors.New("missing")
+	}
+	if strings.ContainsAny(api, "/ ") {
+		return fmt.Errorf("%q is not host:port", api)
+	}
+	host := api
+	if strings.Contains(api, ":") {
+		h, port, err := net.SplitHostPort(api)
+		if err != nil {
+			return fmt.Errorf("%q is not host:port", api)
+		}
+		if p, err := strconv.Atoi(port); err != nil || p <= 0 || p > 65535 {
+			return fmt.Errorf("%q has an invalid port", api)
+		}
+		host = h
+	}
+	if host == "" {
+		return fmt.Errorf("%q is not host:port", api)
+	}
+	return nil
+}
+
+// validateServers - moves the servers failing validation from the server map to the invalid servers,
+// returns an error naming each of them and their wrong fields
+func validateServers(servers map[string]Server) error {
+	for name := range invalidServers {
+		delete(invalidServers, name)
+	}
+	issues := []string{}
+	for name, server := range servers {
+		server := server
+		if err := server.Validate(); err != nil {
+			issues = append(issues, fmt.Sprintf("server %q: %s", name, err.Error()))
+			invalidServers[name] = server
+			delete(servers, name)
+		}
+	}
+	if len(issues) == 0 {
+		return nil
+	}
+	sort.Strings(issues)
+	return fmt.Errorf("%w: %s", ErrInvalidServer, strings.Join(issues, "; "))
+}
+
+// serversToWrite - returns the servers to write to the servers file, the invalid servers read from it are kept
+func serversToWrite() map[string]Server {
+	servers := make(map[string]Server, len(Servers)+len(invalidServers))
+	for name, server := range invalidServers {
+		servers[name] = server
+	}
+	for name, server := range Servers {
+		servers[name] = server
+	}
+	return servers
+}
+
+// quarantineNodes - moves the nodes of invalid servers out of the node map,
+// and the nodes of servers which are valid again back into it
+func quarantineNodes() {
+	for network, node := range invalidServerNodes {
+		if _, ok := invalidServers[node.Server]; ok {
+			continue
+		}
+		if _, ok := Nodes[network]; !ok {
+			Nodes[network] = node
+		}
+		delete(invalidServerNodes, network)
+	}
+	for network, node := range Nodes {
+		if _, ok := invalidServers[node.Server]; ok {
+			invalidServerNodes[network] = node
+			delete(Nodes, network)
+		}
+	}
+}
+
+// nodesToWrite - returns the nodes to write to the nodes file, the nodes of invalid servers are kept
+func nodesToWrite() NodeMap {
+	nodes := make(NodeMap, len(Nodes)+len(invalidServerNodes))
+	for network, node := range invalidServerNodes {
+		nodes[network] = node
+	}
+	for network, node := range Nodes {
+		nodes[network] = node
+	}
+	return nodes
+}
@@ -0,0 +1,64 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gravitl/netmaker/models"
+	"github.com/matryer/is"
+)
+
+func TestValidateServers(t *testing.T) {
+	is := is.New(t)
+	newServer := func(name, broker, api, mqPort string) Server {
+		server := Server{Name: name}
+		server.Broker = broker
+		server.API = api
+		server.MQPort = mqPort
+		return server
+	}
+	servers := map[string]Server{
+		"good":  newServer("good", "ssl://broker.good.com:8883", "api.good.com:443", "8883"),
+		"ws":    newServer("ws", "wss://broker.ws.com", "api.ws.com", ""),
+		"empty": newServer("empty", "", "api.empty.com", "8883"),
+		"bad":   newServer("", "ssl://broker.bad.com", "https://api.bad.com", "mq"),
+	}
+	Servers = servers
+	Nodes = NodeMap{
+		"net1": {CommonNode: models.CommonNode{Network: "net1", Server: "good"}},
+		"net2": {CommonNode: models.CommonNode{Network: "net2", Server: "empty"}},
+	}
+	defer func() {
+		Servers = map[string]Server{}
+		invalidServers = map[string]Server{}
+		Nodes = NodeMap{}
+		invalidServerNodes = map[string]Node{}
+	}()
+
+	err := validateServers(servers)
+	is.True(errors.Is(err, ErrInvalidServer))
+	for _, want := range []string{
+		`server "empty": broker: missing`,
+		`server "bad": name: missing, broker: "ssl://broker.bad.com" has no port, api: "https://api.bad.com" is not host:port, mqport: "mq" is not a port`,
+	} {
+		is.True(strings.Contains(err.Error(), want))
+	}
+	is.Equal(len(servers), 2)
+	_, ok := servers["good"]
+	is.True(ok)
+	_, ok = servers["ws"]
+	is.True(ok)
+	is.Equal(len(serversToWrite()), 4)
+
+	// the nodes of an invalid server are left out of the node map but still written
+	quarantineNodes()
+	is.Equal(len(Nodes), 1)
+	is.True(GetServer(Nodes["net1"].Server) != nil)
+	is.Equal(len(nodesToWrite()), 2)
+	// and come back once the server is valid again
+	delete(invalidServers, "empty")
+	quarantineNodes()
+	is.Equal(len(Nodes), 2)
+	is.Equal(len(invalidServerNodes), 0)
+}
@@ -0,0 +1,71 @@
+package functions
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gravitl/netmaker/logger"
+)
+
+// ackAttempts - times the initial acknowledgement is published while waiting for the server to answer
+const ackAttempts = 3
+
+var errAckTimeout = errors.New("server did not answer the acknowledgement")
+
+// ackWaiters - signalled when a server answers the acknowledgement of the host, by server name
+var ackWaiters = struct {
+	sync.Mutex
+	m map[string]chan struct{}
+}{m: make(map[string]chan struct{})}
+
+// expectAck - registers a waiter for an answer of the server
+func expectAck(server string) <-chan struct{} {
+	ackWaiters.Lock()
+	defer ackWaiters.Unlock()
+	ch := make(chan struct{}, 1)
+	ackWaiters.m[server] = ch
+	return ch
+}
+
+// stopAckWait - removes the waiter of the server
+func stopAckWait(server string) {
+	ackWaiters.Lock()
+	defer ackWaiters.Unlock()
+	delete(ackWaiters.m, server)
+}
+
+// ackReceived - signals the waiter of the server, if any, the server answers an acknowledgement with an update
+func ackReceived(server string) {
+	ackWaiters.Lock()
+	defer ackWaiters.Unlock()
+	if ch, ok := ackWaiters.m[server]; ok {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// confirmAck - publishes the acknowledgement and waits up to timeout for the server to answer it,
+// publishing it again on timeout up to attempts times
+func confirmAck(server string, publish func() error, timeout time.Duration, attempts int) error {
+	answered := expectAck(server)
+	defer stopAckWait(server)
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err := publish(); err != nil {
+			logger.Log(0, "failed to send ACK to server", server, err.Error())
+		} else {
+			logger.Log(2, "requested ACK on server", server, "attempt", fmt.Sprint(attempt))
+		}
+		select {
+		case <-answered:
+			logger.Log(1, "server", server, "answered ACK")
+			return nil
+		case <-time.After(timeout):
+			logger.Log(0, "no answer to ACK from server", server, "after", timeout.String())
+		}
+	}
+	return errAckTimeout
+}
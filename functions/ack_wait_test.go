@@ -0,0 +1,47 @@
+package functions
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestConfirmAck(t *testing.T) {
+	// the server answers the second acknowledgement
+	published := 0
+	err := confirmAck("server1", func() error {
+		published++
+		if published == 2 {
+			ackReceived("server1")
+		}
+		return nil
+	}, 20*time.Millisecond, ackAttempts)
+	if err != nil {
+		t.Fatalf("expected the ack to be confirmed, got %v", err)
+	}
+	if published != 2 {
+		t.Errorf("expected the ack to be published twice, got %d", published)
+	}
+
+	published = 0
+	err = confirmAck("server2", func() error {
+		published++
+		return errors.New("publish failed")
+	}, 10*time.Millisecond, ackAttempts)
+	if !errors.Is(err, errAckTimeout) {
+		t.Fatalf("expected an ack timeout, got %v", err)
+	}
+	if published != ackAttempts {
+		t.Errorf("expected %d attempts, got %d", ackAttempts, published)
+	}
+
+	// messages from other servers or without a waiter are ignored
+	ackReceived("server3")
+	err = confirmAck("server4", func() error {
+		ackReceived("server3")
+		return nil
+	}, 10*time.Millisecond, 1)
+	if !errors.Is(err, errAckTimeout) {
+		t.Errorf("expected an answer of another server to be ignored, got %v", err)
+	}
+}
@@ -0,0 +1,128 @@
+package functions
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netclient/ncutils"
+	proxy_cfg "github.com/gravitl/netclient/nmproxy/config"
+	"github.com/gravitl/netclient/nmproxy/manager"
+	"github.com/gravitl/netclient/nmproxy/router"
+	"github.com/gravitl/netclient/routes"
+	"github.com/gravitl/netmaker/logger"
+)
+
+// addrFamilyCheckInterval - how often the host interfaces are checked for ipv6 addresses
+const addrFamilyCheckInterval = 30 * time.Second
+
+// addrFamilyWatcher - tracks whether the host has ipv6 connectivity and toggles the ipv6 features on changes
+type addrFamilyWatcher struct {
+	checked bool
+	hasIPv6 bool
+	lookup  func() (bool, error)
+	apply   func(enabled bool) error
+}
+
+// check - looks up the ipv6 addresses of the host and applies a change of address family
+func (w *addrFamilyWatcher) check() {
+	hasIPv6, err := w.lookup()
+	if err != nil {
+		logger.Log(1, "failed to check interface addresses", err.Error())
+		return
+	}
+	if w.checked && hasIPv6 == w.hasIPv6 {
+		return
+	}
+	switch {
+	case !w.checked && !hasIPv6:
+		logger.Log(1, "host has no ipv6 address, ipv6 routes and gateway rules are disabled")
+	case !w.checked:
+		// ipv6 features are enabled by default
+		w.checked, w.hasIPv6 = true, true
+		return
+	case hasIPv6:
+		logger.Log(0, "ipv6 addresses are back, re-enabling ipv6 routes and gateway rules")
+	default:
+		logger.Log(0, "host lost its ipv6 addresses, disabling ipv6 routes and gateway rules until they return")
+	}
+	// a change that could not be applied is applied again on the next check
+	if err := w.apply(hasIPv6); err != nil {
+		logger.Log(0, "failed to toggle ipv6 routes and gateway rules", err.Error())
+		return
+	}
+	w.checked, w.hasIPv6 = true, hasIPv6
+}
+
+// watchAddrFamily - periodically checks the host interfaces for an ipv6 downgrade or restoration
+func watchAddrFamily(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	w := addrFamilyWatcher{lookup: hostHasIPv6, apply: setIPv6Enabled}
+	w.check()
+	ticker := time.NewTicker(addrFamilyCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.check()
+		}
+	}
+}
+
+// hostHasIPv6 - checks if an interface other than the netmaker interface has a global ipv6 address
+func hostHasIPv6() (bool, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return false, err
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 || iface.Name == ncutils.GetInterfaceName() {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok || ipNet.IP.To4() != nil {
+				continue
+			}
+			if ipNet.IP.IsGlobalUnicast() && !ipNet.IP.IsPrivate() {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// setIPv6Enabled - toggles the ipv6 gateway rules and resets the server and peer endpoint routes, the gateway rules
+// are toggled on the proxy manager loop so they don't interleave with the gateway updates it applies
+func setIPv6Enabled(enabled bool) error {
+	toggle := func() { router.SetIPv6Enabled(enabled) }
+	if proxy_cfg.GetCfg().IsProxyRunning() {
+		if err := manager.RunOnLoop(toggle, proxyLoopTimeout); err != nil {
+			return err
+		}
+	} else {
+		toggle()
+	}
+	routes.SetIPv6Enabled(enabled)
+	defaultInterface := config.Netclient().DefaultInterface
+	if err := routes.RemoveServerRoutes(defaultInterface); err != nil {
+		logger.Log(1, "failed to remove server routes", err.Error())
+	}
+	for _, serverName := range config.GetServers() {
+		if err := routes.SetNetmakerServerRoutes(defaultInterface, config.GetServer(serverName)); err != nil {
+			logger.Log(1, "failed to set route(s) for", serverName, err.Error())
+		}
+	}
+	if err := routes.SetNetmakerPeerEndpointRoutes(defaultInterface); err != nil {
+		logger.Log(1, "failed to set peer routes", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package functions
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestAddrFamilyWatcher(t *testing.T) {
+	lookups := []bool{true, true, false, false, true}
+	applied := []bool{}
+	w := addrFamilyWatcher{
+		lookup: func() (bool, error) {
+			hasIPv6 := lookups[0]
+			lookups = lookups[1:]
+			return hasIPv6, nil
+		},
+		apply: func(enabled bool) error {
+			applied = append(applied, enabled)
+			return nil
+		},
+	}
+	// ipv6 present at start and on the next check, nothing to toggle
+	w.check()
+	w.check()
+	if len(applied) != 0 {
+		t.Fatalf("expected no toggling while ipv6 is present, got %v", applied)
+	}
+	// ipv6 lost, features are disabled once
+	w.check()
+	w.check()
+	if len(applied) != 1 || applied[0] {
+		t.Fatalf("expected ipv6 features disabled once, got %v", applied)
+	}
+	// ipv6 restored
+	w.check()
+	if len(applied) != 2 || !applied[1] {
+		t.Fatalf("expected ipv6 features re-enabled, got %v", applied)
+	}
+}
+
+func TestAddrFamilyWatcherNoIPv6AtStart(t *testing.T) {
+	applied := []bool{}
+	w := addrFamilyWatcher{
+		lookup: func() (bool, error) { return false, nil },
+		apply:  func(enabled bool) error { applied = append(applied, enabled); return nil },
+	}
+	w.check()
+	if len(applied) != 1 || applied[0] {
+		t.Fatalf("expected ipv6 features disabled at start, got %v", applied)
+	}
+}
+
+func TestAddrFamilyWatcherRetriesFailedApply(t *testing.T) {
+	applied := []bool{}
+	fail := true
+	w := addrFamilyWatcher{
+		lookup: func() (bool, error) { return false, nil },
+		apply: func(enabled bool) error {
+			applied = append(applied, enabled)
+			if fail {
+				return errors.New("proxy manager is not responding")
+			}
+			return nil
+		},
+	}
+	w.check()
+	fail = false
+	w.check()
+	w.check()
+	if len(applied) != 2 {
+		t.Fatalf("expected the failed toggle to be applied again once, got %v", applied)
+	}
+}
@@ -0,0 +1,82 @@
+package functions
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/devilcove/httpclient"
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netmaker/logger"
+)
+
+// defaultAPITimeout - timeout of the requests to the server api, when not configured
+const defaultAPITimeout = 30 * time.Second
+
+// apiHTTPClient - builds the http client of the requests to the server api from the timeout, tls and proxy
+// settings of the host, the system CAs are trusted and certificates are verified unless configured otherwise
+func apiHTTPClient(cfg *config.Config) (http.Client, error) {
+	client := http.Client{Timeout: defaultAPITimeout}
+	if cfg.APITimeout > 0 {
+		client.Timeout = cfg.APITimeout
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	proxyURL, err := config.ParseAPIProxyURL(cfg.APIProxyURL)
+	if err != nil {
+		return client, fmt.Errorf("invalid api proxy: %w", err)
+	}
+	if proxyURL != nil {
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+	client.Transport = transport
+	if cfg.APICAFile == "" && !cfg.APIInsecureSkipVerify {
+		return client, nil
+	}
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.APIInsecureSkipVerify {
+		logger.Log(0, "WARNING: the certificates of the server api are not verified")
+		tlsCfg.InsecureSkipVerify = true //nolint:gosec // opted into by the host config
+	}
+	if cfg.APICAFile != "" {
+		ca, err := os.ReadFile(cfg.APICAFile)
+		if err != nil {
+			return client, fmt.Errorf("failed to read api CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return client, fmt.Errorf("no certificates found in api CA file %s", cfg.APICAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+	transport.TLSClientConfig = tlsCfg
+	return client, nil
+}
+
+// logAPIProxy - logs the proxy the requests to the server api go through, if any
+func logAPIProxy() {
+	if proxyURL, err := config.ParseAPIProxyURL(config.Netclient().APIProxyURL); err == nil && proxyURL != nil {
+		logger.Log(0, "api requests go through proxy", proxyURL.Redacted())
+		return
+	}
+	for _, env := range []string{"HTTPS_PROXY", "https_proxy"} {
+		if proxy, err := url.Parse(os.Getenv(env)); err == nil && proxy.Host != "" {
+			logger.Log(0, "api requests go through proxy", proxy.Redacted(), "from", env)
+			return
+		}
+	}
+}
+
+// SetAPIClient - applies the timeout, tls and proxy settings of the host to the requests to the server api,
+// called once when the daemon or a command talking to the servers starts
+func SetAPIClient() error {
+	client, err := apiHTTPClient(config.Netclient())
+	if err != nil {
+		return err
+	}
+	httpclient.Client = client
+	return nil
+}
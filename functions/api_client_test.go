@@ -0,0 +1,138 @@
+package functions
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/devilcove/httpclient"
+	"github.com/gravitl/netclient/auth"
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netmaker/models"
+)
+
+// writeCAFile - writes the certificate of the test server to a CA file
+func writeCAFile(t *testing.T, srv *httptest.Server) string {
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return caFile
+}
+
+func TestSetAPIClient(t *testing.T) {
+	client, cfg := httpclient.Client, *config.Netclient()
+	defer func() {
+		httpclient.Client = client
+		config.Netclient().APITimeout, config.Netclient().APICAFile = cfg.APITimeout, cfg.APICAFile
+	}()
+	hang := make(chan struct{})
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/host" {
+			json.NewEncoder(w).Encode(models.HostPull{ServerConfig: models.ServerConfig{Server: "nm.example.com"}})
+			return
+		}
+		select {
+		case <-hang:
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+	defer close(hang)
+	config.Netclient().APITimeout = time.Second
+	config.Netclient().APICAFile = writeCAFile(t, srv)
+	if err := SetAPIClient(); err != nil {
+		t.Fatal(err)
+	}
+	server := &config.Server{ServerConfig: models.ServerConfig{API: srv.Listener.Addr().String()}}
+
+	pull, _, err := fetchHostPull(server, "token", 1, newMQBackoff(time.Millisecond, time.Millisecond))
+	if err != nil || pull.ServerConfig.Server != "nm.example.com" {
+		t.Fatalf("expected the server certificate to be trusted through the CA file, got %v", err)
+	}
+
+	start := time.Now()
+	_, err = httpclient.GetResponse(nil, http.MethodGet, "https://"+server.API+"/api/v1/stuck", "", nil)
+	if err == nil {
+		t.Fatal("expected the request to a non-responsive server to time out")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected the request to time out after 1s, took %s", elapsed)
+	}
+
+	config.Netclient().APICAFile = filepath.Join(t.TempDir(), "missing.pem")
+	if err := SetAPIClient(); err == nil {
+		t.Fatal("expected error for a missing CA file")
+	}
+}
+
+func TestAPIProxy(t *testing.T) {
+	client, cfg := httpclient.Client, *config.Netclient()
+	defer func() {
+		httpclient.Client = client
+		config.Netclient().APICAFile, config.Netclient().APIProxyURL = cfg.APICAFile, cfg.APIProxyURL
+	}()
+	api := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(models.SuccessResponse{Response: map[string]string{"AuthToken": "token"}})
+	}))
+	defer api.Close()
+	// the proxy tunnels CONNECT requests to their target, recording the targets
+	var tunneled []string
+	var tunneledMutex sync.Mutex
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "only CONNECT is supported", http.StatusMethodNotAllowed)
+			return
+		}
+		tunneledMutex.Lock()
+		tunneled = append(tunneled, r.Host)
+		tunneledMutex.Unlock()
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			target.Close()
+			return
+		}
+		go func() {
+			io.Copy(target, conn)
+			target.Close()
+		}()
+		io.Copy(conn, target)
+		conn.Close()
+	}))
+	defer proxy.Close()
+	config.Netclient().APICAFile = writeCAFile(t, api)
+	config.Netclient().APIProxyURL = proxy.URL
+	if err := SetAPIClient(); err != nil {
+		t.Fatal(err)
+	}
+	server := &config.Server{ServerConfig: models.ServerConfig{API: api.Listener.Addr().String()}}
+
+	token, err := auth.Authenticate(server, config.Netclient())
+	if err != nil || token != "token" {
+		t.Fatalf("expected to authenticate through the proxy, got %q, %v", token, err)
+	}
+	tunneledMutex.Lock()
+	defer tunneledMutex.Unlock()
+	if len(tunneled) != 1 || tunneled[0] != server.API {
+		t.Fatalf("expected the proxy to tunnel the request to %s, got %v", server.API, tunneled)
+	}
+
+	config.Netclient().APIProxyURL = "ftp://proxy.example.com"
+	if err := SetAPIClient(); err == nil {
+		t.Fatal("expected error for an unsupported proxy scheme")
+	}
+}
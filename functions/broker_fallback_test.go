@@ -0,0 +1,21 @@
+package functions
+
+import (
+	"testing"
+
+	"github.com/gravitl/netclient/config"
+	"github.com/matryer/is"
+)
+
+func TestBrokerOptions(t *testing.T) {
+	is := is.New(t)
+	server := &config.Server{BrokerFallbacks: []string{"ssl://broker2.example.com:8883", "ssl://broker1.example.com:8883"}}
+	server.Broker = "ssl://broker1.example.com:8883"
+	opts, currentBroker := brokerOptions(server)
+	is.Equal(len(opts.Servers), 2)
+	is.Equal(opts.Servers[0].String(), "ssl://broker1.example.com:8883")
+	is.Equal(opts.Servers[1].String(), "ssl://broker2.example.com:8883")
+	is.Equal(currentBroker(), server.Broker)
+	opts.OnConnectAttempt(opts.Servers[1], nil)
+	is.Equal(currentBroker(), "ssl://broker2.example.com:8883")
+}
@@ -0,0 +1,131 @@
+package functions
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// parts of a server the host connects to
+const (
+	connTargetAPI    = "api"
+	connTargetBroker = "broker"
+)
+
+// ConnErrorClass - kind of failure connecting to a server
+type ConnErrorClass string
+
+// classes of connection failures
+const (
+	// ConnErrDNS - the name of the api or broker could not be resolved
+	ConnErrDNS ConnErrorClass = "dns resolution failed"
+	// ConnErrTLS - the tls handshake or the certificate of the server failed
+	ConnErrTLS ConnErrorClass = "tls/certificate error"
+	// ConnErrAuth - the server rejected the credentials of the host
+	ConnErrAuth ConnErrorClass = "authentication rejected"
+	// ConnErrTimeout - the server did not answer in time
+	ConnErrTimeout ConnErrorClass = "timed out"
+	// ConnErrServer - the server failed to handle the request
+	ConnErrServer ConnErrorClass = "server error"
+	// ConnErrOther - any other failure
+	ConnErrOther ConnErrorClass = "connection failed"
+)
+
+// ConnError - a classified failure to reach the api or the broker of a server
+type ConnError struct {
+	Class  ConnErrorClass
+	Target string
+	Host   string
+	Err    error
+}
+
+// ConnError.Error - describes the failure with a hint on how to fix it
+func (e *ConnError) Error() string {
+	return fmt.Sprintf("%s %s: %s: %v (hint: %s)", e.Target, e.Host, e.Class, e.Err, e.Hint())
+}
+
+// ConnError.Unwrap - returns the underlying error
+func (e *ConnError) Unwrap() error {
+	return e.Err
+}
+
+// ConnError.Hint - returns the remediation for the failure
+func (e *ConnError) Hint() string {
+	switch e.Class {
+	case ConnErrDNS:
+		return fmt.Sprintf("check %s resolves from this host, e.g. the dns servers of the host and the dns records of the server", e.Host)
+	case ConnErrTLS:
+		return fmt.Sprintf("check the certificate of %s is valid and issued for it, and the clock of the host is correct", e.Host)
+	case ConnErrAuth:
+		if e.Target == connTargetBroker {
+			return "the broker rejected the credentials of the host, leave and join the network again"
+		}
+		return "the enrollment key or the credentials of the host were rejected, use a valid enrollment key or join again"
+	case ConnErrTimeout:
+		return fmt.Sprintf("check the host can reach %s and no firewall or proxy blocks the connection", e.Host)
+	case ConnErrServer:
+		return "the server failed to handle the request, check the server logs or retry later"
+	}
+	return "check the network connection of the host and that the server is running"
+}
+
+// classifyConnError - wraps an error connecting to the api or the broker of a server in a ConnError, nil stays nil
+func classifyConnError(target, host string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var connErr *ConnError
+	if errors.As(err, &connErr) {
+		return err
+	}
+	return &ConnError{Class: connErrorClass(err), Target: target, Host: host, Err: err}
+}
+
+// statusConnError - classifies the error status the api answered with
+func statusConnError(host string, code int, message string) error {
+	class := ConnErrOther
+	switch {
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		class = ConnErrAuth
+	case code >= http.StatusInternalServerError:
+		class = ConnErrServer
+	}
+	return &ConnError{Class: class, Target: connTargetAPI, Host: host, Err: fmt.Errorf("%d %s", code, message)}
+}
+
+// connErrorClass - returns the class of a connection error, broker errors are only available as text
+func connErrorClass(err error) ConnErrorClass {
+	var (
+		dnsErr       *net.DNSError
+		authorityErr x509.UnknownAuthorityError
+		hostnameErr  x509.HostnameError
+		invalidErr   x509.CertificateInvalidError
+		recordErr    tls.RecordHeaderError
+		netErr       net.Error
+	)
+	switch {
+	case errors.As(err, &dnsErr):
+		return ConnErrDNS
+	case errors.As(err, &authorityErr), errors.As(err, &hostnameErr), errors.As(err, &invalidErr), errors.As(err, &recordErr):
+		return ConnErrTLS
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return ConnErrTimeout
+	}
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.Contains(msg, "no such host"):
+		return ConnErrDNS
+	case strings.Contains(msg, "x509:"), strings.Contains(msg, "tls:"):
+		return ConnErrTLS
+	case strings.Contains(msg, "timeout"), strings.Contains(msg, "timed out"):
+		return ConnErrTimeout
+	case errors.Is(err, errPullAuth), strings.Contains(msg, "not authorized"), strings.Contains(msg, "bad user name or password"):
+		return ConnErrAuth
+	}
+	return ConnErrOther
+}
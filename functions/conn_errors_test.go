@@ -0,0 +1,64 @@
+package functions
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// timeoutErr - a net.Error that timed out
+type timeoutErr struct{}
+
+func (timeoutErr) Error() string   { return "i/o deadline reached" }
+func (timeoutErr) Timeout() bool   { return true }
+func (timeoutErr) Temporary() bool { return true }
+
+func TestClassifyConnError(t *testing.T) {
+	urlErr := func(err error) error {
+		return &url.Error{Op: "Post", URL: "https://api.example.com/api/v1/host", Err: err}
+	}
+	tests := []struct {
+		name   string
+		err    error
+		class  ConnErrorClass
+		target string
+		hint   string
+	}{
+		{"api dns", classifyConnError(connTargetAPI, "api.example.com",
+			urlErr(&net.DNSError{Err: "no such host", Name: "api.example.com", IsNotFound: true})), ConnErrDNS, connTargetAPI, "resolves"},
+		{"api certificate", classifyConnError(connTargetAPI, "api.example.com",
+			urlErr(x509.UnknownAuthorityError{})), ConnErrTLS, connTargetAPI, "certificate"},
+		{"api timeout", classifyConnError(connTargetAPI, "api.example.com", urlErr(timeoutErr{})), ConnErrTimeout, connTargetAPI, "firewall"},
+		{"api auth status", statusConnError("api.example.com", 401, "unauthorized"), ConnErrAuth, connTargetAPI, "enrollment key"},
+		{"api token", classifyConnError(connTargetAPI, "api.example.com",
+			fmt.Errorf("%w: %v", errPullAuth, errors.New("invalid password"))), ConnErrAuth, connTargetAPI, "enrollment key"},
+		{"api server error", statusConnError("api.example.com", 503, "unavailable"), ConnErrServer, connTargetAPI, "server logs"},
+		{"broker dns", classifyConnError(connTargetBroker, "wss://broker.example.com",
+			errors.New("network Error : dial tcp: lookup broker.example.com: no such host")), ConnErrDNS, connTargetBroker, "resolves"},
+		{"broker auth", classifyConnError(connTargetBroker, "wss://broker.example.com",
+			errors.New("not Authorized")), ConnErrAuth, connTargetBroker, "broker rejected"},
+		{"broker timeout", classifyConnError(connTargetBroker, "wss://broker.example.com",
+			errors.New("connect timeout")), ConnErrTimeout, connTargetBroker, "firewall"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var connErr *ConnError
+			if !errors.As(tc.err, &connErr) {
+				t.Fatalf("expected a ConnError, got %v", tc.err)
+			}
+			if connErr.Class != tc.class || connErr.Target != tc.target {
+				t.Errorf("got class %q target %q, want %q %q", connErr.Class, connErr.Target, tc.class, tc.target)
+			}
+			if !strings.Contains(tc.err.Error(), tc.hint) || !strings.HasPrefix(tc.err.Error(), tc.target) {
+				t.Errorf("message %q should name the %s and hint %q", tc.err.Error(), tc.target, tc.hint)
+			}
+		})
+	}
+	if classifyConnError(connTargetAPI, "api.example.com", nil) != nil {
+		t.Error("nil error should stay nil")
+	}
+}
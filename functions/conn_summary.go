@@ -0,0 +1,42 @@
+package functions
+
+import (
+	"github.com/gravitl/netclient/config"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// ConnModeSummary - number of peers by the path their traffic takes, reported at checkin
+type ConnModeSummary struct {
+	Total  int `json:"total"`
+	Direct int `json:"direct"`
+	Proxy  int `json:"proxy"`
+	Relay  int `json:"relay"`
+	Turn   int `json:"turn"`
+}
+
+// summarizeConnModes - counts the peers by connection mode
+func summarizeConnModes(peers []wgtypes.PeerConfig, pathOf peerPathFunc) ConnModeSummary {
+	summary := ConnModeSummary{}
+	for _, peer := range peers {
+		if peer.Remove {
+			continue
+		}
+		summary.Total++
+		switch pathOf(peer.PublicKey.String()) {
+		case PeerPathProxy:
+			summary.Proxy++
+		case PeerPathRelay:
+			summary.Relay++
+		case PeerPathTurn:
+			summary.Turn++
+		default:
+			summary.Direct++
+		}
+	}
+	return summary
+}
+
+// serverConnModes - connection mode summary of the peers of a server
+func serverConnModes(server string) ConnModeSummary {
+	return summarizeConnModes(config.Netclient().HostPeers[server], proxyPeerPath)
+}
@@ -0,0 +1,29 @@
+package functions
+
+import (
+	"testing"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestSummarizeConnModes(t *testing.T) {
+	modes := []string{PeerPathDirect, PeerPathDirect, PeerPathDirect, PeerPathProxy, PeerPathProxy, PeerPathRelay, PeerPathTurn}
+	peers := []wgtypes.PeerConfig{}
+	pathByKey := map[string]string{}
+	for _, mode := range modes {
+		key, err := wgtypes.GeneratePrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		peers = append(peers, wgtypes.PeerConfig{PublicKey: key.PublicKey()})
+		pathByKey[key.PublicKey().String()] = mode
+	}
+	removed, _ := wgtypes.GeneratePrivateKey()
+	peers = append(peers, wgtypes.PeerConfig{PublicKey: removed.PublicKey(), Remove: true})
+
+	summary := summarizeConnModes(peers, func(key string) string { return pathByKey[key] })
+	want := ConnModeSummary{Total: 7, Direct: 3, Proxy: 2, Relay: 1, Turn: 1}
+	if summary != want {
+		t.Fatalf("expected %+v, got %+v", want, summary)
+	}
+}
@@ -18,12 +18,15 @@ func Disconnect(network string) error {
 	if !node.Connected {
 		return errors.New("node is already disconnected")
 	}
+	server := config.GetServer(node.Server)
+	if server == nil {
+		return errors.New("no server config for " + node.Network)
+	}
 	node.Connected = false
 	config.UpdateNodeMap(node.Network, node)
 	if err := config.WriteNodeConfig(); err != nil {
 		return fmt.Errorf("error writing node config %w", err)
 	}
-	server := config.GetServer(node.Server)
 	if err := setupMQTTSingleton(server, true); err != nil {
 		return err
 	}
@@ -49,12 +52,15 @@ func Connect(network string) error {
 	if node.Connected {
 		return errors.New("node already connected")
 	}
+	server := config.GetServer(node.Server)
+	if server == nil {
+		return errors.New("no server config for " + node.Network)
+	}
 	node.Connected = true
 	config.UpdateNodeMap(node.Network, node)
 	if err := config.WriteNodeConfig(); err != nil {
 		return fmt.Errorf("error writing node config %w", err)
 	}
-	server := config.GetServer(node.Server)
 	if err := setupMQTTSingleton(server, true); err != nil {
 		return err
 	}
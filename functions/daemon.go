@@ -2,11 +2,15 @@ package functions
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
 	"os/signal"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -32,11 +36,16 @@ const (
 	lastNodeUpdate   = "lnu"
 	lastDNSUpdate    = "ldu"
 	lastALLDNSUpdate = "ladu"
+	// natWriteAttempts - number of attempts to persist an updated nat type
+	natWriteAttempts = 5
+	// singletonConnectAttempts - connection attempts of a single use broker connection, backed off between attempts
+	singletonConnectAttempts = 3
 )
 
 var (
 	messageCache     = new(sync.Map)
-	ServerSet        = make(map[string]mqtt.Client)
+	ServerSet        = make(map[string]mqtt.Client) // guarded by serverSetMutex
+	serverSetMutex   sync.RWMutex
 	ProxyManagerChan = make(chan *models.HostPeerUpdate, 50)
 	hostNatInfo      *ncmodels.HostInfo
 )
@@ -49,13 +58,20 @@ type cachedMessage struct {
 func startProxy(wg *sync.WaitGroup) context.CancelFunc {
 	ctx, cancel := context.WithCancel(context.Background())
 	wg.Add(1)
-	go nmproxy.Start(ctx, wg, ProxyManagerChan, hostNatInfo, config.Netclient().ProxyListenPort)
+	setProxyLocalPort()
+	natInfoMutex.Lock()
+	natInfo := hostNatInfo
+	natInfoMutex.Unlock()
+	go nmproxy.Start(ctx, wg, ProxyManagerChan, natInfo, config.Netclient().ProxyListenPort, config.Netclient().ProxyLocalPort)
 	return cancel
 }
 
 // Daemon runs netclient daemon
 func Daemon() {
 	logger.Log(0, "netclient daemon started -- version:", config.Version)
+	if err := checkDuplicateDaemon(); err != nil {
+		logger.FatalLog(err.Error())
+	}
 	if err := ncutils.SavePID(); err != nil {
 		logger.FatalLog("unable to save PID on daemon startup")
 	}
@@ -67,12 +83,17 @@ func Daemon() {
 	reset := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGTERM, os.Interrupt)
 	signal.Notify(reset, syscall.SIGHUP)
+	resets := make(chan struct{})
+	resetCtx, resetCancel := context.WithCancel(context.Background())
+	go newResetCoalescer().run(resetCtx, reset, resets)
 
+	startHealthWarmup()
+	loadServerActivity()
+	loadMessageCache()
+	logAPIProxy()
 	shouldUpdateNat := getNatInfo()
 	if shouldUpdateNat { // will be reported on check-in
-		if err := config.WriteNetclientConfig(); err == nil {
-			logger.Log(1, "updated NAT type to", hostNatInfo.NatType)
-		}
+		persistNatType()
 	}
 	cancel := startGoRoutines(&wg)
 	stopProxy := startProxy(&wg)
@@ -91,20 +112,21 @@ func Daemon() {
 			}, &wg)
 			httpCancel()
 			httpWg.Wait()
+			resetCancel()
+			flushMessageCache()
 			logger.Log(0, "shutdown complete")
 			return
-		case <-reset:
+		case <-resets:
 			logger.Log(0, "received reset")
 			closeRoutines([]context.CancelFunc{
 				cancel,
 				stopProxy,
 			}, &wg)
 			logger.Log(0, "restarting daemon")
+			startHealthWarmup()
 			shouldUpdateNat := getNatInfo()
 			if shouldUpdateNat { // will be reported on check-in
-				if err := config.WriteNetclientConfig(); err == nil {
-					logger.Log(1, "updated NAT type to", hostNatInfo.NatType)
-				}
+				persistNatType()
 			}
 			cleanUpRoutes()
 			cancel = startGoRoutines(&wg)
@@ -115,11 +137,44 @@ func Daemon() {
 	}
 }
 
+// getServerClient - returns the broker client of the server
+func getServerClient(server string) (mqtt.Client, bool) {
+	serverSetMutex.RLock()
+	defer serverSetMutex.RUnlock()
+	client, ok := ServerSet[server]
+	return client, ok
+}
+
+// setServerClient - sets the broker client of the server
+func setServerClient(server string, client mqtt.Client) {
+	serverSetMutex.Lock()
+	defer serverSetMutex.Unlock()
+	ServerSet[server] = client
+}
+
+// deleteServerClient - forgets the broker client of the server
+func deleteServerClient(server string) {
+	serverSetMutex.Lock()
+	defer serverSetMutex.Unlock()
+	delete(ServerSet, server)
+}
+
+// serverClients - returns a copy of the broker clients by server
+func serverClients() map[string]mqtt.Client {
+	serverSetMutex.RLock()
+	defer serverSetMutex.RUnlock()
+	clients := make(map[string]mqtt.Client, len(ServerSet))
+	for server, client := range ServerSet {
+		clients[server] = client
+	}
+	return clients
+}
+
 func closeRoutines(closers []context.CancelFunc, wg *sync.WaitGroup) {
 	for i := range closers {
 		closers[i]()
 	}
-	for _, mqclient := range ServerSet {
+	for _, mqclient := range serverClients() {
 		if mqclient != nil {
 			mqclient.Disconnect(250)
 		}
@@ -137,6 +192,9 @@ func startGoRoutines(wg *sync.WaitGroup) context.CancelFunc {
 		logger.Log(0, "error reading neclient config file", err.Error())
 	}
 	config.UpdateNetclient(*config.Netclient())
+	if err := SetAPIClient(); err != nil {
+		logger.Log(0, "failed to configure api client", err.Error())
+	}
 	if err := config.ReadNodeConfig(); err != nil {
 		logger.Log(0, "error reading node map from disk", err.Error())
 	}
@@ -154,16 +212,17 @@ func startGoRoutines(wg *sync.WaitGroup) context.CancelFunc {
 			},
 		}
 	}
-	for _, server := range config.Servers {
+	routeRetriers.bind(ctx, wg)
+	startServers(config.Servers, config.Netclient().ServerPriority, func(server *config.Server) {
 		logger.Log(1, "started daemon for server ", server.Name)
-		server := server
-		networking.StoreServerAddresses(&server)
-		err := routes.SetNetmakerServerRoutes(config.Netclient().DefaultInterface, &server)
-		if err != nil {
-			logger.Log(2, "failed to set route(s) for", server.Name, err.Error())
-		}
+		networking.StoreServerAddresses(server)
+		setServerRoutes(server)
+	}, func(server *config.Server, connected chan<- struct{}) {
 		wg.Add(1)
-		go messageQueue(ctx, wg, &server)
+		go messageQueue(ctx, wg, server, connected)
+	}, priorityConnectTimeout, serverSetupConcurrency())
+	if err := nc.WaitReady(); err != nil {
+		logger.Log(0, "applying peers to interface", nc.Name, "before it is ready:", err.Error())
 	}
 	wireguard.SetPeers()
 	if err := routes.SetNetmakerPeerEndpointRoutes(config.Netclient().DefaultInterface); err != nil {
@@ -172,40 +231,74 @@ func startGoRoutines(wg *sync.WaitGroup) context.CancelFunc {
 	wg.Add(1)
 	go Checkin(ctx, wg)
 	wg.Add(1)
+	go watchConnModeChanges(ctx, wg)
+	wg.Add(1)
 	go networking.StartIfaceDetection(ctx, wg, config.Netclient().ProxyListenPort)
+	wg.Add(1)
+	go watchAddrFamily(ctx, wg)
+	wg.Add(1)
+	go watchNatInfo(ctx, wg)
+	wg.Add(1)
+	go watchPeerMTUs(ctx, wg)
 	return cancel
 }
 
 // sets up Message Queue and subsribes/publishes updates to/from server
 // the client should subscribe to ALL nodes that exist on server locally
-func messageQueue(ctx context.Context, wg *sync.WaitGroup, server *config.Server) {
+// connected is closed once the connection to the broker is set up or has failed
+func messageQueue(ctx context.Context, wg *sync.WaitGroup, server *config.Server, connected chan<- struct{}) {
 	defer wg.Done()
 	logger.Log(0, "netclient message queue started for server:", server.Name)
-	err := setupMQTT(server)
+	err := setupMQTT(ctx, server)
+	close(connected)
 	if err != nil {
 		logger.Log(0, "unable to connect to broker", server.Broker, err.Error())
+		recordError("unable to connect to broker", server.Broker, err.Error())
 		return
 	}
-	defer ServerSet[server.Name].Disconnect(250)
+	if mqclient, ok := getServerClient(server.Name); ok {
+		defer mqclient.Disconnect(250)
+	}
 	<-ctx.Done()
 	logger.Log(0, "shutting down message queue for server", server.Name)
 }
 
-// setupMQTT creates a connection to broker
-func setupMQTT(server *config.Server) error {
+// brokerOptions - creates client options with the brokers of the server registered in order,
+// paho rotates among them when connecting, the returned func reports the broker last connected to
+func brokerOptions(server *config.Server) (*mqtt.ClientOptions, func() string) {
 	opts := mqtt.NewClientOptions()
-	opts.AddBroker(server.Broker)
-	opts.SetUsername(server.MQUserName)
-	opts.SetPassword(server.MQPassword)
+	for _, broker := range server.Brokers() {
+		opts.AddBroker(broker)
+	}
+	var current atomic.Value
+	current.Store(server.Broker)
+	opts.SetConnectionAttemptHandler(func(broker *url.URL, tlsCfg *tls.Config) *tls.Config {
+		current.Store(broker.String())
+		return tlsCfg
+	})
+	return opts, func() string {
+		return current.Load().(string)
+	}
+}
+
+// setupMQTT creates a connection to broker, retrying with exponential backoff until it is established or ctx is done
+func setupMQTT(ctx context.Context, server *config.Server) error {
+	opts, currentBroker := brokerOptions(server)
+	if err := setMQAuth(opts, server); err != nil {
+		return err
+	}
 	//opts.SetClientID(ncutils.MakeRandomString(23))
 	opts.SetClientID(server.MQID.String())
+	backoff := configuredMQBackoff()
 	opts.SetAutoReconnect(true)
-	opts.SetConnectRetry(true)
-	opts.SetConnectRetryInterval(time.Second << 2)
+	opts.SetMaxReconnectInterval(backoff.max)
+	// connection retries are backed off by the loop below, paho only supports a fixed retry interval
+	opts.SetConnectRetry(false)
 	opts.SetKeepAlive(time.Second * 10)
 	opts.SetWriteTimeout(time.Minute)
 	opts.SetOnConnectHandler(func(client mqtt.Client) {
 		logger.Log(0, "mqtt connect handler")
+		serverActivity.connected(server.Name, time.Now())
 		nodes := config.GetNodes()
 		for _, node := range nodes {
 			node := node
@@ -216,7 +309,7 @@ func setupMQTT(server *config.Server) error {
 	opts.SetOrderMatters(true)
 	opts.SetResumeSubs(true)
 	opts.SetConnectionLostHandler(func(c mqtt.Client, e error) {
-		logger.Log(0, "detected broker connection lost for", server.Broker)
+		logger.Log(0, "detected broker connection lost for", currentBroker())
 		if ok := resetServerRoutes(); ok {
 			logger.Log(0, "detected default gw change, reset routes")
 			if err := UpdateHostSettings(); err != nil {
@@ -225,34 +318,48 @@ func setupMQTT(server *config.Server) error {
 			}
 
 			handlePeerInetGateways(
-				!config.GW4PeerDetected && !config.GW6PeerDetected,
-				config.IsHostInetGateway(), false,
-				nil,
+				false, false,
+				config.IsHostInetGateway(),
+				nil, nil,
 			)
 		}
 	})
 	mqclient := mqtt.NewClient(opts)
-	ServerSet[server.Name] = mqclient
-	var connecterr error
-	for count := 0; count < 3; count++ {
-		connecterr = nil
-		if token := mqclient.Connect(); !token.WaitTimeout(30*time.Second) || token.Error() != nil {
-			logger.Log(0, "unable to connect to broker, retrying ...")
-			if token.Error() == nil {
-				connecterr = errors.New("connect timeout")
-			} else {
-				connecterr = token.Error()
+	setServerClient(server.Name, mqclient)
+	for {
+		token := mqclient.Connect()
+		connected := token.WaitTimeout(30*time.Second) && token.Error() == nil
+		if connected {
+			// the connection is only considered established once the server answered, a server that does
+			// not answer is connected to again after the backoff like a broker that could not be reached
+			ackErr := sendInitialAck(server)
+			if ackErr == nil {
+				break
+			}
+			mqclient.Disconnect(250)
+			delay := backoff.next()
+			logger.Log(0, "server", server.Name, "did not answer the ACK, reconnecting in", delay.Round(time.Second).String())
+			recordError("server did not answer the ACK", server.Broker, ackErr.Error())
+			select {
+			case <-ctx.Done():
+				return ackErr
+			case <-time.After(delay):
 			}
+			continue
+		}
+		connecterr := token.Error()
+		if connecterr == nil {
+			connecterr = errors.New("connect timeout")
+		}
+		connecterr = classifyConnError(connTargetBroker, server.Broker, connecterr)
+		delay := backoff.next()
+		logger.Log(0, "unable to connect to broker:", connecterr.Error(), "retrying in", delay.Round(time.Second).String())
+		recordError("unable to connect to broker", server.Broker, connecterr.Error())
+		select {
+		case <-ctx.Done():
+			return connecterr
+		case <-time.After(delay):
 		}
-	}
-	if connecterr != nil {
-		logger.Log(0, "failed to establish connection to broker: ", connecterr.Error())
-		return connecterr
-	}
-	if err := PublishHostUpdate(server.Name, models.Acknowledgement); err != nil {
-		logger.Log(0, "failed to send initial ACK to server", server.Name, err.Error())
-	} else {
-		logger.Log(2, "successfully requested ACK on server", server.Name)
 	}
 	// send register signal with turn to server
 	if server.UseTurn {
@@ -266,17 +373,40 @@ func setupMQTT(server *config.Server) error {
 	return nil
 }
 
+// sendInitialAck - requests an ACK on the server, waiting for the server to answer it when an ack timeout is configured
+func sendInitialAck(server *config.Server) error {
+	if ackTimeout := config.Netclient().AckTimeout; ackTimeout > 0 {
+		return confirmAck(server.Name, func() error {
+			return PublishHostUpdate(server.Name, models.Acknowledgement)
+		}, time.Second*time.Duration(ackTimeout), ackAttempts)
+	}
+	if err := PublishHostUpdate(server.Name, models.Acknowledgement); err != nil {
+		logger.Log(0, "failed to send initial ACK to server", server.Name, err.Error())
+	} else {
+		logger.Log(2, "successfully requested ACK on server", server.Name)
+	}
+	return nil
+}
+
 // func setMQTTSingenton creates a connection to broker for single use (ie to publish a message)
 // only to be called from cli (eg. connect/disconnect, join, leave) and not from daemon ---
 func setupMQTTSingleton(server *config.Server, publishOnly bool) error {
-	opts := mqtt.NewClientOptions()
-	opts.AddBroker(server.Broker)
-	opts.SetUsername(server.MQUserName)
-	opts.SetPassword(server.MQPassword)
-	opts.SetClientID(server.MQID.String())
+	return setupMQTTSingletonWithID(server, server.MQID.String(), publishOnly)
+}
+
+// setupMQTTSingletonWithID - setupMQTTSingleton connecting with the given client id, the broker drops an existing
+// session of the same id, so a connection made while the daemon stays connected needs an id of its own
+func setupMQTTSingletonWithID(server *config.Server, clientID string, publishOnly bool) error {
+	opts, currentBroker := brokerOptions(server)
+	if err := setMQAuth(opts, server); err != nil {
+		return err
+	}
+	opts.SetClientID(clientID)
+	backoff := configuredMQBackoff()
 	opts.SetAutoReconnect(true)
-	opts.SetConnectRetry(true)
-	opts.SetConnectRetryInterval(time.Second << 2)
+	opts.SetMaxReconnectInterval(backoff.max)
+	// connection retries are backed off by the loop below, paho only supports a fixed retry interval
+	opts.SetConnectRetry(false)
 	opts.SetKeepAlive(time.Minute >> 1)
 	opts.SetWriteTimeout(time.Minute)
 	opts.SetOnConnectHandler(func(client mqtt.Client) {
@@ -289,25 +419,32 @@ func setupMQTTSingleton(server *config.Server, publishOnly bool) error {
 			}
 			setHostSubscription(client, server.Name)
 		}
-		logger.Log(1, "successfully connected to", server.Broker)
+		logger.Log(1, "successfully connected to", currentBroker())
 	})
 	opts.SetOrderMatters(true)
 	opts.SetResumeSubs(true)
 	opts.SetConnectionLostHandler(func(c mqtt.Client, e error) {
-		logger.Log(0, "detected broker connection lost for", server.Broker)
+		logger.Log(0, "detected broker connection lost for", currentBroker())
 	})
 	mqclient := mqtt.NewClient(opts)
-	ServerSet[server.Name] = mqclient
+	setServerClient(server.Name, mqclient)
 	var connecterr error
-	if token := mqclient.Connect(); !token.WaitTimeout(30*time.Second) || token.Error() != nil {
-		logger.Log(0, "unable to connect to broker,", server.Broker+",", "retrying...")
-		if token.Error() == nil {
+	for attempt := 1; attempt <= singletonConnectAttempts; attempt++ {
+		token := mqclient.Connect()
+		if token.WaitTimeout(30*time.Second) && token.Error() == nil {
+			return nil
+		}
+		if connecterr = token.Error(); connecterr == nil {
 			connecterr = errors.New("connect timeout")
-		} else {
-			connecterr = token.Error()
 		}
+		if attempt == singletonConnectAttempts {
+			break
+		}
+		delay := backoff.next()
+		logger.Log(0, "unable to connect to broker,", server.Broker+",", "retrying in", delay.Round(time.Second).String())
+		time.Sleep(delay)
 	}
-	return connecterr
+	return classifyConnError(connTargetBroker, server.Broker, connecterr)
 }
 
 // setHostSubscription sets MQ client subscriptions for host
@@ -370,7 +507,13 @@ func decryptMsg(serverName string, msg []byte) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	return DeChunk(msg, serverPubKey, diskKey)
+	data, err := DeChunk(msg, serverPubKey, diskKey)
+	if err != nil {
+		handleDecryptFailure(&decryptFailures, serverName, host, defaultDecryptFailureActions)
+		return nil, err
+	}
+	decryptFailures.succeeded(serverName)
+	return data, nil
 }
 
 func read(network, which string) string {
@@ -380,7 +523,7 @@ func read(network, which string) string {
 		if readMessage.LastSeen.IsZero() {
 			return ""
 		}
-		if time.Now().After(readMessage.LastSeen.Add(time.Hour * 24)) { // check if message has been there over a minute
+		if time.Now().After(readMessage.LastSeen.Add(messageCacheTTL)) { // check if message has expired
 			messageCache.Delete(fmt.Sprintf("%s%s", network, which)) // remove old message if expired
 			return ""
 		}
@@ -395,6 +538,7 @@ func insert(network, which, cache string) {
 		LastSeen: time.Now(),
 	}
 	messageCache.Store(fmt.Sprintf("%s%s", network, which), newMessage)
+	flushMessageCache()
 }
 
 // on a delete usually, pass in the nodecfg to unsubscribe client broker communications
@@ -449,15 +593,24 @@ func UpdateKeys() error {
 	if err := config.WriteNetclientConfig(); err != nil {
 		logger.Log(0, "error saving netclient config", err.Error())
 	}
-	PublishGlobalHostUpdate(models.UpdateHost)
-	daemon.Restart()
+	publicKey := host.PublicKey.String()
+	// waiting for the servers takes up to the confirmation timeout per attempt, it must not hold up the mq handler
+	go func() {
+		if err := PublishConfirmedHostUpdate(models.UpdateHost, func(h *models.Host) bool {
+			return h.PublicKey.String() == publicKey
+		}); err != nil {
+			logger.Log(0, "ERROR: key rotation may not have reached all servers:", err.Error())
+		}
+		daemon.Restart()
+	}()
 	return nil
 }
 
 // RemoveServer - removes a server from server conf given a specific node
 func RemoveServer(node *config.Node) {
 	logger.Log(0, "removing server", node.Server, "from mq")
-	delete(ServerSet, node.Server)
+	deleteServerClient(node.Server)
+	forgetNetworkMessages(node.Network)
 }
 
 func getNatInfo() (natUpdated bool) {
@@ -469,37 +622,50 @@ func getNatInfo() (natUpdated bool) {
 	err = config.ReadServerConf()
 	if err != nil {
 		logger.Log(0, "errors reading server map from disk", err.Error())
-		return
+		if !errors.Is(err, config.ErrInvalidServer) {
+			return
+		}
 	}
 
+	natInfoMutex.Lock()
+	defer natInfoMutex.Unlock()
 	for _, server := range config.Servers {
 		server := server
 		if hostNatInfo == nil {
-			portToStun, err := ncutils.GetFreePort(config.Netclient().ProxyListenPort)
-			if portToStun == 0 || err != nil {
-				portToStun = config.Netclient().ListenPort
-			}
+			portToStun := nmproxy.ValidateProxyListenPort()
 
-			hostNatInfo = stun.GetHostNatInfo(
-				server.StunList,
-				config.Netclient().EndpointIP.String(),
+			endpointIP, endpointChanged := hostEndpointIP(&server)
+			hostNatInfo = detectNatInfo(
+				stun.GetHostNatInfo,
+				stunServers(server.Name, config.Servers),
+				endpointIP.String(),
 				portToStun,
+				config.Netclient().FallbackNatType,
 			)
 			if len(ncConf.Host.NatType) == 0 || ncConf.Host.NatType != hostNatInfo.NatType {
 				config.Netclient().Host.NatType = hostNatInfo.NatType
 				return true
 			}
+			if endpointChanged {
+				return true
+			}
 		}
 	}
 	return
 }
 
-func cleanUpRoutes() {
-	gwAddr := config.GW4Addr
-	if gwAddr.IP == nil {
-		gwAddr = config.GW6Addr
+// persistNatType - writes the updated nat type to disk, retrying with backoff on failures
+func persistNatType() {
+	if err := ncutils.RetryWithBackoff(natWriteAttempts, time.Second, config.WriteNetclientConfig); err != nil {
+		logger.Log(0, "ERROR: failed to persist updated NAT type", config.Netclient().Host.NatType, "after",
+			strconv.Itoa(natWriteAttempts), "attempts, it will be re-detected on restart:", err.Error())
+		return
 	}
-	if err := routes.CleanUp(config.Netclient().DefaultInterface, &gwAddr); err != nil {
+	logger.Log(1, "updated NAT type to", config.Netclient().Host.NatType)
+}
+
+func cleanUpRoutes() {
+	if err := routes.CleanUp(config.Netclient().DefaultInterface, inetGatewaysInUse()...); err != nil {
 		logger.Log(0, "routes not completely cleaned up", err.Error())
 	}
 }
@@ -507,15 +673,7 @@ func cleanUpRoutes() {
 func resetServerRoutes() bool {
 	if routes.HasGatewayChanged() {
 		cleanUpRoutes()
-		for _, server := range config.Servers {
-			server := server
-			if err := routes.SetNetmakerServerRoutes(config.Netclient().DefaultInterface, &server); err != nil {
-				logger.Log(2, "failed to set route(s) for", server.Name, err.Error())
-			}
-			if err := routes.SetNetmakerPeerEndpointRoutes(config.Netclient().DefaultInterface); err != nil {
-				logger.Log(2, "failed to set route(s) for", server.Name, err.Error())
-			}
-		}
+		resetDefaultIfaceRoutes(getDefaultInterface, defaultIfaceRoutes)
 		return true
 	}
 	return false
@@ -0,0 +1,99 @@
+package functions
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netmaker/logger"
+)
+
+// policies applied once messages of a server failed to decrypt DecryptFailureThreshold times in a row
+const (
+	// DecryptFailureLog - keep the connection and log the failures (default)
+	DecryptFailureLog = "log"
+	// DecryptFailureRepull - pull the config of the host again from the servers
+	DecryptFailureRepull = "repull"
+	// DecryptFailureDisconnect - disconnect from the server's broker
+	DecryptFailureDisconnect = "disconnect"
+)
+
+// defaultDecryptFailureThreshold - consecutive failures before the policy is applied, when not configured
+const defaultDecryptFailureThreshold = 5
+
+// decryptFailureTracker - counts consecutive decryption failures per server
+type decryptFailureTracker struct {
+	mutex  sync.Mutex
+	counts map[string]int
+}
+
+// decryptFailureActions - what the repull and disconnect policies do for a server
+type decryptFailureActions struct {
+	repull     func(server string)
+	disconnect func(server string)
+}
+
+var decryptFailures = decryptFailureTracker{counts: make(map[string]int)}
+
+// failed - records a failure for the server and returns true when the threshold is reached,
+// the count restarts so the policy is applied again after another threshold of failures
+func (t *decryptFailureTracker) failed(server string, threshold int) (int, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.counts[server]++
+	count := t.counts[server]
+	if count < threshold {
+		return count, false
+	}
+	t.counts[server] = 0
+	return count, true
+}
+
+// succeeded - clears the failures of the server
+func (t *decryptFailureTracker) succeeded(server string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.counts, server)
+}
+
+// handleDecryptFailure - records a decryption failure and applies the configured policy at the threshold
+func handleDecryptFailure(t *decryptFailureTracker, server string, host *config.Config, actions decryptFailureActions) {
+	threshold := defaultDecryptFailureThreshold
+	if host.DecryptFailureThreshold > 0 {
+		threshold = host.DecryptFailureThreshold
+	}
+	count, reached := t.failed(server, threshold)
+	if !reached {
+		logger.Log(1, "failed to decrypt message from server", server, "consecutive failures:", strconv.Itoa(count))
+		return
+	}
+	recordError("messages from server", server, "failed to decrypt", strconv.Itoa(count), "times")
+	switch host.DecryptFailurePolicy {
+	case DecryptFailureRepull:
+		logger.Log(0, "messages from server", server, "failed to decrypt", strconv.Itoa(count), "times, pulling config again")
+		actions.repull(server)
+	case DecryptFailureDisconnect:
+		logger.Log(0, "ERROR: messages from server", server, "failed to decrypt", strconv.Itoa(count),
+			"times, disconnecting from it; the traffic keys are out of sync, re-join or pull to reconnect")
+		actions.disconnect(server)
+	default:
+		logger.Log(0, "WARNING: messages from server", server, "failed to decrypt", strconv.Itoa(count),
+			"times, the traffic keys may be out of sync")
+	}
+}
+
+// defaultDecryptFailureActions - pulls in the background, as the daemon restarts after the pull
+var defaultDecryptFailureActions = decryptFailureActions{
+	repull: func(server string) {
+		go func() {
+			if err := Pull(); err != nil {
+				logger.Log(0, "pull after decryption failures of", server, "failed", err.Error())
+			}
+		}()
+	},
+	disconnect: func(server string) {
+		if client, _ := getServerClient(server); client != nil {
+			client.Disconnect(250)
+		}
+	},
+}
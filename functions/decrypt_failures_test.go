@@ -0,0 +1,42 @@
+package functions
+
+import (
+	"testing"
+
+	"github.com/gravitl/netclient/config"
+)
+
+func TestHandleDecryptFailure(t *testing.T) {
+	for _, policy := range []string{DecryptFailureLog, DecryptFailureRepull, DecryptFailureDisconnect} {
+		t.Run(policy, func(t *testing.T) {
+			tracker := decryptFailureTracker{counts: make(map[string]int)}
+			host := &config.Config{DecryptFailureThreshold: 3, DecryptFailurePolicy: policy}
+			repulls, disconnects := 0, 0
+			actions := decryptFailureActions{
+				repull:     func(string) { repulls++ },
+				disconnect: func(string) { disconnects++ },
+			}
+			handleDecryptFailure(&tracker, "nm.example.com", host, actions)
+			handleDecryptFailure(&tracker, "nm.example.com", host, actions)
+			// a successful decryption restarts the count
+			tracker.succeeded("nm.example.com")
+			handleDecryptFailure(&tracker, "nm.example.com", host, actions)
+			handleDecryptFailure(&tracker, "nm.example.com", host, actions)
+			handleDecryptFailure(&tracker, "other.example.com", host, actions)
+			if repulls != 0 || disconnects != 0 {
+				t.Fatalf("policy applied below threshold: repulls %d disconnects %d", repulls, disconnects)
+			}
+			handleDecryptFailure(&tracker, "nm.example.com", host, actions)
+			wantRepulls, wantDisconnects := 0, 0
+			switch policy {
+			case DecryptFailureRepull:
+				wantRepulls = 1
+			case DecryptFailureDisconnect:
+				wantDisconnects = 1
+			}
+			if repulls != wantRepulls || disconnects != wantDisconnects {
+				t.Fatalf("expected repulls %d disconnects %d, got %d %d", wantRepulls, wantDisconnects, repulls, disconnects)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package functions
+
+import (
+	"sync/atomic"
+
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netclient/ncutils"
+	"github.com/gravitl/netclient/routes"
+	"github.com/gravitl/netmaker/logger"
+)
+
+// ifaceRoutes - sets and removes the server and peer endpoint routes of a default interface
+type ifaceRoutes struct {
+	remove func(iface string) error
+	set    func(iface string) error
+}
+
+// defaultIfaceMoved - set when the default interface moved while the routes were reset after a gateway change,
+// reported by the next defaultIfaceChanged so the move is saved and published
+var defaultIfaceMoved atomic.Bool
+
+// defaultIfaceRoutes - the routes the daemon keeps on the default interface
+var defaultIfaceRoutes = ifaceRoutes{
+	remove: func(iface string) error {
+		serverErr := routes.RemoveServerRoutes(iface)
+		if err := routes.RemovePeerRoutes(iface); err != nil {
+			return err
+		}
+		return serverErr
+	},
+	set: func(iface string) error {
+		for _, server := range config.Servers {
+			server := server
+			setServerRoutes(&server)
+		}
+		return routes.SetNetmakerPeerEndpointRoutes(iface)
+	},
+}
+
+// detectDefaultInterface - re-detects the default interface and updates the host when it moved,
+// returns the previous interface and if it changed, a pinned default interface is never changed
+func detectDefaultInterface(detect func() (string, error)) (string, bool) {
+	previous := config.Netclient().DefaultInterface
+	if config.Netclient().PinDefaultInterface {
+		return previous, false
+	}
+	iface, err := detect()
+	if err != nil {
+		logger.Log(0, "default gateway not found", err.Error())
+		return previous, false
+	}
+	if iface == previous || iface == ncutils.GetInterfaceName() {
+		return previous, false
+	}
+	config.Netclient().DefaultInterface = iface
+	logger.Log(0, "default interface has changed from", previous, "to", iface)
+	return previous, true
+}
+
+// resetDefaultIfaceRoutes - sets the routes again after the gateway changed and the old routes were cleaned up,
+// on the interface the default route moved to
+func resetDefaultIfaceRoutes(detect func() (string, error), ifRoutes ifaceRoutes) {
+	if _, changed := detectDefaultInterface(detect); changed {
+		defaultIfaceMoved.Store(true)
+	}
+	if err := ifRoutes.set(config.Netclient().DefaultInterface); err != nil {
+		logger.Log(1, "failed to set routes on", config.Netclient().DefaultInterface, err.Error())
+	}
+}
+
+// defaultIfaceChanged - checks if the default interface changed since the last check, either now or
+// while the routes were reset, moving the routes when it changes now
+func defaultIfaceChanged(detect func() (string, error), ifRoutes ifaceRoutes) bool {
+	moved := defaultIfaceMoved.Swap(false)
+	return rehomeDefaultInterface(detect, ifRoutes) || moved
+}
+
+// rehomeDefaultInterface - re-detects the default interface, moving the routes from the previous interface
+// to the new one when it changed
+func rehomeDefaultInterface(detect func() (string, error), ifRoutes ifaceRoutes) bool {
+	previous, changed := detectDefaultInterface(detect)
+	if !changed {
+		return false
+	}
+	if previous != "" {
+		if err := ifRoutes.remove(previous); err != nil {
+			logger.Log(1, "failed to remove routes from", previous, err.Error())
+		}
+	}
+	if err := ifRoutes.set(config.Netclient().DefaultInterface); err != nil {
+		logger.Log(1, "failed to set routes on", config.Netclient().DefaultInterface, err.Error())
+	}
+	return true
+}
@@ -0,0 +1,96 @@
+package functions
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netclient/ncutils"
+)
+
+func TestRehomeDefaultInterface(t *testing.T) {
+	prev := config.Netclient().DefaultInterface
+	defer func() {
+		config.Netclient().DefaultInterface = prev
+		config.Netclient().PinDefaultInterface = false
+	}()
+	// routesOn - the interface the fake routes are set on
+	routesOn := map[string]bool{"eth0": true}
+	fakeRoutes := ifaceRoutes{
+		remove: func(iface string) error {
+			delete(routesOn, iface)
+			return nil
+		},
+		set: func(iface string) error {
+			routesOn[iface] = true
+			return nil
+		},
+	}
+	detected := func(iface string, err error) func() (string, error) {
+		return func() (string, error) { return iface, err }
+	}
+	config.Netclient().DefaultInterface = "eth0"
+
+	if rehomeDefaultInterface(detected("eth0", nil), fakeRoutes) {
+		t.Fatal("expected no change while the default route stays on eth0")
+	}
+	if rehomeDefaultInterface(detected("", errors.New("no default route")), fakeRoutes) ||
+		rehomeDefaultInterface(detected(ncutils.GetInterfaceName(), nil), fakeRoutes) {
+		t.Fatal("expected a failed detection or the netmaker interface to be ignored")
+	}
+
+	// failover to the wifi link
+	if !rehomeDefaultInterface(detected("wlan0", nil), fakeRoutes) {
+		t.Fatal("expected the default interface change to be detected")
+	}
+	if config.Netclient().DefaultInterface != "wlan0" || len(routesOn) != 1 || !routesOn["wlan0"] {
+		t.Fatalf("expected the routes to move to wlan0, default %q routes on %v", config.Netclient().DefaultInterface, routesOn)
+	}
+
+	// a pinned default interface is kept
+	config.Netclient().PinDefaultInterface = true
+	if rehomeDefaultInterface(detected("eth0", nil), fakeRoutes) || config.Netclient().DefaultInterface != "wlan0" || !routesOn["wlan0"] {
+		t.Fatalf("expected the pinned default interface to be kept, got %q routes on %v", config.Netclient().DefaultInterface, routesOn)
+	}
+}
+
+func TestResetDefaultIfaceRoutes(t *testing.T) {
+	prev := config.Netclient().DefaultInterface
+	defer func() {
+		config.Netclient().DefaultInterface = prev
+		defaultIfaceMoved.Store(false)
+	}()
+	routesOn := map[string]bool{}
+	fakeRoutes := ifaceRoutes{
+		remove: func(iface string) error {
+			delete(routesOn, iface)
+			return nil
+		},
+		set: func(iface string) error {
+			routesOn[iface] = true
+			return nil
+		},
+	}
+	detected := func(iface string) func() (string, error) {
+		return func() (string, error) { return iface, nil }
+	}
+	config.Netclient().DefaultInterface = "eth0"
+
+	// a new gateway on the same interface sets the routes again without a change to publish
+	resetDefaultIfaceRoutes(detected("eth0"), fakeRoutes)
+	if !routesOn["eth0"] || defaultIfaceChanged(detected("eth0"), fakeRoutes) {
+		t.Fatalf("expected the routes set again on eth0 without a change, routes on %v", routesOn)
+	}
+	// the reset moving the default interface is reported once to the host settings check
+	delete(routesOn, "eth0")
+	resetDefaultIfaceRoutes(detected("wlan0"), fakeRoutes)
+	if !routesOn["wlan0"] || config.Netclient().DefaultInterface != "wlan0" {
+		t.Fatalf("expected the routes on wlan0, default %q routes on %v", config.Netclient().DefaultInterface, routesOn)
+	}
+	if !defaultIfaceChanged(detected("wlan0"), fakeRoutes) {
+		t.Fatal("expected the move during the reset to be reported")
+	}
+	if defaultIfaceChanged(detected("wlan0"), fakeRoutes) {
+		t.Fatal("expected the move to be reported only once")
+	}
+}
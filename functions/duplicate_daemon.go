@@ -0,0 +1,84 @@
+package functions
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netclient/ncutils"
+	"github.com/gravitl/netclient/wireguard"
+	"golang.zx2c4.com/wireguard/wgctrl"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// ifaceInspector - looks up the state of the netmaker interface and the daemon recorded as running it
+type ifaceInspector interface {
+	ifaceExists(name string) bool
+	ifacePublicKey(name string) (wgtypes.Key, error)
+	ownerPID() (int, error)
+	processAlive(pid int) bool
+}
+
+// systemIfaceInspector - ifaceInspector backed by the host
+type systemIfaceInspector struct{}
+
+func (systemIfaceInspector) ifaceExists(name string) bool {
+	return wireguard.IfaceExists(name)
+}
+
+func (systemIfaceInspector) ifacePublicKey(name string) (wgtypes.Key, error) {
+	wg, err := wgctrl.New()
+	if err != nil {
+		return wgtypes.Key{}, err
+	}
+	defer wg.Close()
+	dev, err := wg.Device(name)
+	if err != nil {
+		return wgtypes.Key{}, err
+	}
+	return dev.PublicKey, nil
+}
+
+func (systemIfaceInspector) ownerPID() (int, error) {
+	return ncutils.ReadPID()
+}
+
+func (systemIfaceInspector) processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return p.Signal(syscall.Signal(0)) == nil
+}
+
+// errDuplicateDaemon - the netmaker interface is already managed by another daemon
+var errDuplicateDaemon = errors.New("netmaker interface is already in use")
+
+// checkIfaceOwner - returns an error if the netmaker interface exists and belongs to another running daemon
+// or is configured with a key other than this host's
+func checkIfaceOwner(inspector ifaceInspector, ifaceName string, publicKey wgtypes.Key, self int) error {
+	if !inspector.ifaceExists(ifaceName) {
+		return nil
+	}
+	if pid, err := inspector.ownerPID(); err == nil && pid > 0 && pid != self && inspector.processAlive(pid) {
+		return fmt.Errorf("%w: interface %s is managed by netclient daemon with pid %d, stop it before starting another",
+			errDuplicateDaemon, ifaceName, pid)
+	}
+	key, err := inspector.ifacePublicKey(ifaceName)
+	if err != nil {
+		// not a wireguard interface we can read, leave it to interface setup to report
+		return nil
+	}
+	if key != (wgtypes.Key{}) && key != publicKey {
+		return fmt.Errorf("%w: interface %s is configured with public key %s instead of this host's %s, "+
+			"remove the interface if no other netclient is running", errDuplicateDaemon, ifaceName, key, publicKey)
+	}
+	return nil
+}
+
+// checkDuplicateDaemon - refuses to run when another daemon owns the netmaker interface
+func checkDuplicateDaemon() error {
+	return checkIfaceOwner(systemIfaceInspector{}, ncutils.GetInterfaceName(), config.Netclient().PublicKey, os.Getpid())
+}
@@ -0,0 +1,47 @@
+package functions
+
+import (
+	"errors"
+	"testing"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+type mockIfaceInspector struct {
+	exists bool
+	key    wgtypes.Key
+	keyErr error
+	pid    int
+	alive  bool
+}
+
+func (m mockIfaceInspector) ifaceExists(string) bool                    { return m.exists }
+func (m mockIfaceInspector) ifacePublicKey(string) (wgtypes.Key, error) { return m.key, m.keyErr }
+func (m mockIfaceInspector) ownerPID() (int, error)                     { return m.pid, nil }
+func (m mockIfaceInspector) processAlive(int) bool                      { return m.alive }
+
+func TestCheckIfaceOwner(t *testing.T) {
+	priv, _ := wgtypes.GeneratePrivateKey()
+	other, _ := wgtypes.GeneratePrivateKey()
+	ours := priv.PublicKey()
+	cases := []struct {
+		name      string
+		inspector mockIfaceInspector
+		duplicate bool
+	}{
+		{"no interface", mockIfaceInspector{pid: 100, alive: true}, false},
+		{"owned by another live process", mockIfaceInspector{exists: true, key: ours, pid: 100, alive: true}, true},
+		{"owner is dead", mockIfaceInspector{exists: true, key: ours, pid: 100}, false},
+		{"owned by self", mockIfaceInspector{exists: true, key: ours, pid: 42, alive: true}, false},
+		{"unexpected key", mockIfaceInspector{exists: true, key: other.PublicKey(), pid: 100}, true},
+		{"not readable", mockIfaceInspector{exists: true, keyErr: errors.New("no device")}, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkIfaceOwner(tc.inspector, "netmaker", ours, 42)
+			if errors.Is(err, errDuplicateDaemon) != tc.duplicate {
+				t.Errorf("expected duplicate %v, got %v", tc.duplicate, err)
+			}
+		})
+	}
+}
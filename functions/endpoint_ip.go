@@ -0,0 +1,87 @@
+package functions
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netclient/ncutils"
+	"github.com/gravitl/netmaker/logger"
+)
+
+// endpointDetector - returns the local address the host reaches the target from
+type endpointDetector func(target string) (net.IP, error)
+
+// localSourceIP - returns the source address the host uses to reach the target, no packets are sent
+func localSourceIP(target string) (net.IP, error) {
+	conn, err := net.Dial("udp", target)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	addr, ok := conn.LocalAddr().(*net.UDPAddr)
+	if !ok || addr.IP == nil || addr.IP.IsUnspecified() {
+		return nil, errors.New("no source address for " + target)
+	}
+	if !config.Netclient().AllowTemporaryIPv6 {
+		return ncutils.StableSourceIP(addr.IP, config.Netclient().StableIPv6), nil
+	}
+	return addr.IP, nil
+}
+
+// endpointTarget - returns the address to detect the endpoint ip against, the first stun server of the server,
+// its broker otherwise
+func endpointTarget(server *config.Server) string {
+	for _, stunServer := range server.StunList {
+		if stunServer.Domain != "" && stunServer.Port != 0 {
+			return net.JoinHostPort(stunServer.Domain, fmt.Sprint(stunServer.Port))
+		}
+	}
+	u, err := url.Parse(server.Broker)
+	if err != nil || u.Hostname() == "" {
+		return ""
+	}
+	port := u.Port()
+	if port == "" {
+		port = "443"
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// resolveEndpointIP - returns the endpoint ip of the host: the endpoint ip configured for a static host,
+// or the one detected against the target otherwise; detected reports whether it was detected
+func resolveEndpointIP(isStatic bool, current net.IP, target string, detect endpointDetector) (ip net.IP, detected bool, err error) {
+	if isStatic && current != nil && !current.IsUnspecified() {
+		return current, false, nil
+	}
+	if target == "" {
+		return nil, false, errors.New("no server to detect the endpoint ip against")
+	}
+	if ip, err = detect(target); err != nil {
+		return nil, false, fmt.Errorf("failed to detect endpoint ip: %w", err)
+	}
+	return ip, true, nil
+}
+
+// hostEndpointIP - resolves the endpoint ip of the host for nat detection against the server, a detected
+// endpoint ip is set on the host config when it has none, so it is persisted with the nat type; the endpoint ip
+// of a host that has one is kept up to date with its public ip on checkin
+func hostEndpointIP(server *config.Server) (ip net.IP, changed bool) {
+	host := config.Netclient()
+	ip, detected, err := resolveEndpointIP(host.IsStatic, host.EndpointIP, endpointTarget(server), localSourceIP)
+	if err != nil {
+		logger.Log(0, "endpoint ip:", err.Error())
+		return host.EndpointIP, false
+	}
+	if !detected {
+		return ip, false
+	}
+	logger.Log(0, "detected endpoint ip", ip.String())
+	if host.EndpointIP == nil || host.EndpointIP.IsUnspecified() {
+		host.EndpointIP = ip
+		return ip, true
+	}
+	return ip, false
+}
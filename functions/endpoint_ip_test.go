@@ -0,0 +1,60 @@
+package functions
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netmaker/models"
+	"github.com/matryer/is"
+)
+
+func TestResolveEndpointIP(t *testing.T) {
+	is := is.New(t)
+	detected := net.ParseIP("192.168.1.10")
+	detect := func(target string) (net.IP, error) {
+		if target == "" {
+			return nil, errors.New("no target")
+		}
+		return detected, nil
+	}
+
+	t.Run("unset detected", func(t *testing.T) {
+		ip, wasDetected, err := resolveEndpointIP(false, nil, "stun.example.com:3478", detect)
+		is.NoErr(err)
+		is.True(wasDetected)
+		is.True(ip.Equal(detected))
+	})
+	t.Run("dynamic detected", func(t *testing.T) {
+		ip, wasDetected, err := resolveEndpointIP(false, net.ParseIP("203.0.113.5"), "stun.example.com:3478", detect)
+		is.NoErr(err)
+		is.True(wasDetected)
+		is.True(ip.Equal(detected))
+	})
+	t.Run("static kept", func(t *testing.T) {
+		ip, wasDetected, err := resolveEndpointIP(true, net.ParseIP("198.51.100.7"), "stun.example.com:3478", detect)
+		is.NoErr(err)
+		is.True(!wasDetected)
+		is.True(ip.Equal(net.ParseIP("198.51.100.7")))
+	})
+	t.Run("static unset detected", func(t *testing.T) {
+		ip, wasDetected, err := resolveEndpointIP(true, nil, "stun.example.com:3478", detect)
+		is.NoErr(err)
+		is.True(wasDetected)
+		is.True(ip.Equal(detected))
+	})
+	t.Run("no target", func(t *testing.T) {
+		_, _, err := resolveEndpointIP(false, nil, "", detect)
+		is.True(err != nil)
+	})
+}
+
+func TestEndpointTarget(t *testing.T) {
+	is := is.New(t)
+	server := &config.Server{}
+	server.Broker = "wss://broker.example.com"
+	is.Equal(endpointTarget(server), "broker.example.com:443")
+	server.StunList = []models.StunServer{{Domain: "stun.example.com", Port: 3478}}
+	is.Equal(endpointTarget(server), "stun.example.com:3478")
+}
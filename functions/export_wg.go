@@ -0,0 +1,117 @@
+package functions
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gravitl/netclient/config"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// ExportNetworkWgConfig - writes a wg-quick config of a single network to the file, or stdout if file is empty
+func ExportNetworkWgConfig(network, file string) error {
+	node, ok := config.GetNodes()[network]
+	if !ok {
+		return fmt.Errorf("not a member of network %s", network)
+	}
+	others := []config.Node{}
+	for name, other := range config.GetNodes() {
+		if name != network {
+			others = append(others, other)
+		}
+	}
+	host := config.Netclient()
+	if file == "" {
+		return writeNetworkWgConfig(os.Stdout, host, node, others, host.HostPeers[node.Server])
+	}
+	// the config holds the private key of the host
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return writeNetworkWgConfig(f, host, node, others, host.HostPeers[node.Server])
+}
+
+// writeNetworkWgConfig - writes the interface of the node and the peers within its network,
+// peers are limited to allowed ips which are not within the range of another network of the host
+func writeNetworkWgConfig(w io.Writer, host *config.Config, node config.Node, others []config.Node, serverPeers []wgtypes.PeerConfig) error {
+	var b strings.Builder
+	b.WriteString("# netmaker network " + node.Network + "\n")
+	b.WriteString("[Interface]\n")
+	b.WriteString("PrivateKey = " + host.PrivateKey.String() + "\n")
+	addrs := []string{}
+	for _, addr := range []net.IPNet{node.Address, node.Address6} {
+		if addr.IP != nil {
+			addrs = append(addrs, addr.String())
+		}
+	}
+	if len(addrs) > 0 {
+		b.WriteString("Address = " + strings.Join(addrs, ", ") + "\n")
+	}
+	if host.ListenPort != 0 {
+		b.WriteString("ListenPort = " + strconv.Itoa(host.ListenPort) + "\n")
+	}
+	if host.MTU != 0 {
+		b.WriteString("MTU = " + strconv.Itoa(host.MTU) + "\n")
+	}
+	for _, peer := range serverPeers {
+		if peer.Remove {
+			continue
+		}
+		allowed := networkAllowedIPs(peer.AllowedIPs, node, others)
+		if len(allowed) == 0 {
+			continue
+		}
+		b.WriteString("\n[Peer]\n")
+		b.WriteString("PublicKey = " + peer.PublicKey.String() + "\n")
+		b.WriteString("AllowedIPs = " + strings.Join(allowed, ", ") + "\n")
+		// loopback endpoints point at the local proxy, not the peer
+		if peer.Endpoint != nil && !peer.Endpoint.IP.IsLoopback() {
+			b.WriteString("Endpoint = " + peer.Endpoint.String() + "\n")
+		}
+		if peer.PersistentKeepaliveInterval != nil && *peer.PersistentKeepaliveInterval > 0 {
+			b.WriteString("PersistentKeepalive = " + strconv.Itoa(int(peer.PersistentKeepaliveInterval.Seconds())) + "\n")
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// networkAllowedIPs - returns the allowed ips of a peer belonging to the node's network, nil if the peer
+// has no address in the network; allowed ips outside every network range, i.e. egress ranges, are kept
+func networkAllowedIPs(allowedIPs []net.IPNet, node config.Node, others []config.Node) []string {
+	inNetwork := false
+	allowed := []string{}
+	for _, ip := range allowedIPs {
+		if inRange(node, ip.IP) {
+			inNetwork = true
+			allowed = append(allowed, ip.String())
+			continue
+		}
+		other := false
+		for _, o := range others {
+			if inRange(o, ip.IP) {
+				other = true
+				break
+			}
+		}
+		if !other {
+			allowed = append(allowed, ip.String())
+		}
+	}
+	if !inNetwork {
+		return nil
+	}
+	return allowed
+}
+
+// inRange - checks if the ip is within the ipv4 or ipv6 range of the node's network
+func inRange(node config.Node, ip net.IP) bool {
+	return (node.NetworkRange.IP != nil && node.NetworkRange.Contains(ip)) ||
+		(node.NetworkRange6.IP != nil && node.NetworkRange6.Contains(ip))
+}
@@ -0,0 +1,82 @@
+package functions
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gravitl/netclient/config"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestWriteNetworkWgConfig(t *testing.T) {
+	hostKey, _ := wgtypes.GeneratePrivateKey()
+	host := &config.Config{}
+	host.PrivateKey = hostKey
+	host.ListenPort = 51821
+	host.MTU = 1420
+
+	netA := config.Node{}
+	netA.Network = "neta"
+	netA.NetworkRange = net.IPNet{IP: net.ParseIP("10.10.0.0"), Mask: net.CIDRMask(16, 32)}
+	netA.Address = net.IPNet{IP: net.ParseIP("10.10.0.2"), Mask: net.CIDRMask(16, 32)}
+	netB := config.Node{}
+	netB.Network = "netb"
+	netB.NetworkRange = net.IPNet{IP: net.ParseIP("10.20.0.0"), Mask: net.CIDRMask(16, 32)}
+	netB.Address = net.IPNet{IP: net.ParseIP("10.20.0.2"), Mask: net.CIDRMask(16, 32)}
+
+	keepalive := 20 * time.Second
+	onlyA, _ := wgtypes.GeneratePrivateKey()
+	both, _ := wgtypes.GeneratePrivateKey()
+	onlyB, _ := wgtypes.GeneratePrivateKey()
+	peers := []wgtypes.PeerConfig{
+		{
+			PublicKey:                   onlyA.PublicKey(),
+			Endpoint:                    &net.UDPAddr{IP: net.ParseIP("203.0.113.5"), Port: 51821},
+			PersistentKeepaliveInterval: &keepalive,
+			AllowedIPs: []net.IPNet{
+				{IP: net.ParseIP("10.10.0.3"), Mask: net.CIDRMask(32, 32)},
+				{IP: net.ParseIP("192.168.1.0"), Mask: net.CIDRMask(24, 32)},
+			},
+		},
+		{
+			PublicKey: both.PublicKey(),
+			Endpoint:  &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 40000},
+			AllowedIPs: []net.IPNet{
+				{IP: net.ParseIP("10.10.0.4"), Mask: net.CIDRMask(32, 32)},
+				{IP: net.ParseIP("10.20.0.4"), Mask: net.CIDRMask(32, 32)},
+			},
+		},
+		{
+			PublicKey:  onlyB.PublicKey(),
+			AllowedIPs: []net.IPNet{{IP: net.ParseIP("10.20.0.5"), Mask: net.CIDRMask(32, 32)}},
+		},
+	}
+
+	var b strings.Builder
+	if err := writeNetworkWgConfig(&b, host, netA, []config.Node{netB}, peers); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		"PrivateKey = " + hostKey.String(),
+		"Address = 10.10.0.2/16",
+		"ListenPort = 51821",
+		"PublicKey = " + onlyA.PublicKey().String(),
+		"AllowedIPs = 10.10.0.3/32, 192.168.1.0/24",
+		"Endpoint = 203.0.113.5:51821",
+		"PersistentKeepalive = 20",
+		"PublicKey = " + both.PublicKey().String(),
+		"AllowedIPs = 10.10.0.4/32\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("exported config is missing %q:\n%s", want, out)
+		}
+	}
+	for _, unwanted := range []string{onlyB.PublicKey().String(), "10.20.0", "127.0.0.1"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("exported config of neta contains %q:\n%s", unwanted, out)
+		}
+	}
+}
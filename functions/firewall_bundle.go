@@ -0,0 +1,65 @@
+package functions
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/gravitl/netclient/nmproxy/router"
+)
+
+// ExportFirewallBundle - writes the firewall support bundle of the running daemon to file
+func ExportFirewallBundle(file string) error {
+	var bundle json.RawMessage
+	if err := daemonRequest(http.MethodGet, "/firewall/bundle", url.Values{}, &bundle); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(bundle); err != nil {
+		return err
+	}
+	fmt.Println("firewall support bundle written to", file)
+	return f.Sync()
+}
+
+// ImportFirewallBundle - loads a firewall support bundle for analysis and prints its rules,
+// evaluating the packet if one is given; nothing is applied to this host's firewall
+func ImportFirewallBundle(file string, q *router.PacketQuery) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	analysis, err := router.ImportSupportBundle(f)
+	if err != nil {
+		return err
+	}
+	bundle := analysis.Bundle
+	fmt.Printf("bundle from netclient %s, %s backend, interface %s, created %s\n",
+		bundle.Version, bundle.Backend, bundle.Interface, bundle.CreatedAt.Format("2006-01-02 15:04:05 MST"))
+	if bundle.RulesetError != "" {
+		fmt.Println("live ruleset was not captured:", bundle.RulesetError)
+	}
+	for _, spec := range analysis.RuleSpecs() {
+		fmt.Println(spec)
+	}
+	if q == nil {
+		return nil
+	}
+	verdict := analysis.Query(*q)
+	fmt.Println("verdict:", verdict.Verdict)
+	fmt.Println("reason: ", verdict.Reason)
+	if verdict.Rule != "" {
+		fmt.Println("rule:   ", verdict.Rule)
+	}
+	if verdict.Masquerade {
+		fmt.Println("masqueraded by:", verdict.MasqRule)
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package functions
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// firewallRules - the firewall rules of the daemon as an nft script
+type firewallRules struct {
+	Script string `json:"script"`
+}
+
+// ExportFirewallRules - asks the running daemon for the firewall rules it installed for the server, or for all
+// servers when server is empty, as an nft script written to file or printed when file is empty
+func ExportFirewallRules(server, file string) error {
+	params := url.Values{}
+	if server != "" {
+		params.Set("server", server)
+	}
+	var rules firewallRules
+	if err := daemonRequest(http.MethodGet, "/firewall/rules", params, &rules); err != nil {
+		return err
+	}
+	if file == "" {
+		fmt.Print(rules.Script)
+		return nil
+	}
+	if err := os.WriteFile(file, []byte(rules.Script), 0600); err != nil {
+		return err
+	}
+	fmt.Println("firewall rules written to", file)
+	return nil
+}
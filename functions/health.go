@@ -0,0 +1,133 @@
+package functions
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/gin-gonic/gin"
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netclient/ncutils"
+)
+
+// health states of the host
+const (
+	// HealthStarting - host is within its warm-up period and not yet healthy
+	HealthStarting = "starting"
+	// HealthHealthy - all health checks pass
+	HealthHealthy = "healthy"
+	// HealthUnhealthy - a health check failed after warm-up
+	HealthUnhealthy = "unhealthy"
+	// defaultHealthWarmup - seconds after startup during which failing checks report starting, when not configured
+	defaultHealthWarmup = 60
+)
+
+// healthTracker - turns health check results into a health state, reporting failures as starting
+// until either the warm-up period expires or the checks pass for the first time
+type healthTracker struct {
+	mutex     sync.Mutex
+	startedAt time.Time
+	warmup    time.Duration
+	warmedUp  bool
+}
+
+var hostHealth = &healthTracker{}
+
+// start - begins a new warm-up period
+func (h *healthTracker) start(now time.Time, warmup time.Duration) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.startedAt = now
+	h.warmup = warmup
+	h.warmedUp = false
+}
+
+// state - returns the health state given the failed checks at time now
+func (h *healthTracker) state(now time.Time, failures []string) string {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if len(failures) == 0 {
+		h.warmedUp = true
+		return HealthHealthy
+	}
+	if !h.warmedUp && now.Sub(h.startedAt) < h.warmup {
+		return HealthStarting
+	}
+	h.warmedUp = true
+	return HealthUnhealthy
+}
+
+// startHealthWarmup - begins the warm-up period configured for the host
+func startHealthWarmup() {
+	warmup := config.Netclient().HealthWarmup
+	if warmup <= 0 {
+		warmup = defaultHealthWarmup
+	}
+	hostHealth.start(time.Now(), time.Duration(warmup)*time.Second)
+}
+
+// healthStatus - health of the host, with the failed checks, the servers failing them and the interface when it is down
+type healthStatus struct {
+	Status    string           `json:"status"`
+	Failures  []string         `json:"failures"`
+	Unhealthy []string         `json:"unhealthy_servers,omitempty"`
+	Interface string           `json:"interface,omitempty"`
+	Servers   []ServerActivity `json:"servers"`
+}
+
+// checkHealth - checks the broker connection and routes of every server and, once the host has nodes, that the
+// interface is up, a server whose routes are not set is not considered connected when routes are required
+func checkHealth(servers []string, clients map[string]mqtt.Client, routeFailures map[string]error, requireRoute bool,
+	ifaceName string, hasNodes bool, isUp func(string) bool) healthStatus {
+	status := healthStatus{Failures: []string{}}
+	for _, server := range servers {
+		healthy := true
+		if mqclient, ok := clients[server]; !ok || mqclient == nil || !mqclient.IsConnected() {
+			status.Failures = append(status.Failures, "not connected to broker of "+server)
+			healthy = false
+		} else if _, unrouted := routeFailures[server]; unrouted && requireRoute {
+			status.Failures = append(status.Failures, "not connected to broker of "+server+", its routes are not set")
+			healthy = false
+		}
+		if err, ok := routeFailures[server]; ok {
+			status.Failures = append(status.Failures, "failed to set routes of "+server+": "+err.Error())
+			healthy = false
+		}
+		if !healthy {
+			status.Unhealthy = append(status.Unhealthy, server)
+		}
+	}
+	sort.Strings(status.Unhealthy)
+	if hasNodes && (ifaceName == "" || !isUp(ifaceName)) {
+		status.Interface = ifaceName
+		if status.Interface == "" {
+			status.Interface = "not configured"
+		}
+		status.Failures = append(status.Failures, "interface "+status.Interface+" is not up")
+	}
+	return status
+}
+
+// ifaceUp - checks the interface exists and is up
+func ifaceUp(name string) bool {
+	iface, err := net.InterfaceByName(name)
+	return err == nil && iface.Flags&net.FlagUp != 0
+}
+
+// health - liveness/readiness check, 200 while starting or healthy and 503 once a check fails after the warm-up,
+// listing the failed checks
+func health(c *gin.Context) {
+	requireRoute := config.Netclient().RequireServerRoute
+	status := checkHealth(config.GetServers(), serverClients(), serverRoutes.failures(requireRoute), requireRoute,
+		ncutils.GetInterfaceName(), len(config.GetNodes()) > 0, ifaceUp)
+	status.Status = hostHealth.state(time.Now(), status.Failures)
+	status.Servers = serverActivity.list()
+	code := http.StatusOK
+	if status.Status == HealthUnhealthy {
+		code = http.StatusServiceUnavailable
+	}
+	c.JSON(code, status)
+}
@@ -0,0 +1,102 @@
+package functions
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/matryer/is"
+)
+
+// fakeMQClient - mqtt client reporting a fixed connection state
+type fakeMQClient struct {
+	mqtt.Client
+	connected bool
+}
+
+func (f fakeMQClient) IsConnected() bool {
+	return f.connected
+}
+
+func TestHealthTrackerWarmup(t *testing.T) {
+	start := time.Now()
+	failing := []string{"not connected to broker of netmaker.example.com"}
+	steps := []struct {
+		name     string
+		after    time.Duration
+		failures []string
+		want     string
+	}{
+		{"failing during warm-up", 10 * time.Second, failing, HealthStarting},
+		{"failing after warm-up", time.Minute, failing, HealthUnhealthy},
+		{"passing after warm-up", time.Minute, nil, HealthHealthy},
+		{"failing again", 2 * time.Minute, failing, HealthUnhealthy},
+	}
+	h := &healthTracker{}
+	h.start(start, 30*time.Second)
+	for _, step := range steps {
+		if got := h.state(start.Add(step.after), step.failures); got != step.want {
+			t.Errorf("%s: expected %s, got %s", step.name, step.want, got)
+		}
+	}
+
+	// passing checks end the warm-up early
+	h.start(start, 30*time.Second)
+	if got := h.state(start.Add(time.Second), nil); got != HealthHealthy {
+		t.Errorf("expected healthy once checks pass, got %s", got)
+	}
+	if got := h.state(start.Add(2*time.Second), failing); got != HealthUnhealthy {
+		t.Errorf("expected unhealthy after being healthy within warm-up, got %s", got)
+	}
+
+	// a restart begins a new warm-up
+	h.start(start.Add(time.Hour), 30*time.Second)
+	if got := h.state(start.Add(time.Hour+time.Second), failing); got != HealthStarting {
+		t.Errorf("expected starting after restart, got %s", got)
+	}
+}
+
+func TestCheckHealth(t *testing.T) {
+	is := is.New(t)
+	up := func(string) bool { return true }
+	down := func(string) bool { return false }
+	servers := []string{"a.example.com", "b.example.com", "c.example.com"}
+
+	status := checkHealth(servers, map[string]mqtt.Client{
+		"a.example.com": fakeMQClient{connected: true},
+		"b.example.com": fakeMQClient{connected: true},
+		"c.example.com": fakeMQClient{connected: true},
+	}, nil, false, "netmaker", true, up)
+	is.Equal(len(status.Failures), 0)
+	is.Equal(len(status.Unhealthy), 0)
+	is.Equal(status.Interface, "")
+
+	status = checkHealth(servers, map[string]mqtt.Client{
+		"a.example.com": fakeMQClient{connected: true},
+		"c.example.com": nil,
+		"b.example.com": fakeMQClient{connected: false},
+	}, nil, false, "netmaker", true, up)
+	is.Equal(status.Unhealthy, []string{"b.example.com", "c.example.com"})
+	is.Equal(len(status.Failures), 2)
+
+	clients := map[string]mqtt.Client{"a.example.com": fakeMQClient{connected: true}}
+	status = checkHealth([]string{"a.example.com"}, clients, nil, false, "netmaker", true, down)
+	is.Equal(status.Interface, "netmaker")
+	is.Equal(status.Failures, []string{"interface netmaker is not up"})
+
+	// the interface is only checked once the host has nodes
+	status = checkHealth([]string{"a.example.com"}, clients, nil, false, "", false, down)
+	is.Equal(len(status.Failures), 0)
+	status = checkHealth([]string{"a.example.com"}, clients, nil, false, "", true, up)
+	is.Equal(status.Interface, "not configured")
+
+	// a connected server without routes is unhealthy when routes are required
+	routeFailures := map[string]error{"a.example.com": errors.New("no default gateway")}
+	status = checkHealth([]string{"a.example.com"}, clients, routeFailures, true, "netmaker", true, up)
+	is.Equal(status.Unhealthy, []string{"a.example.com"})
+	is.Equal(status.Failures, []string{
+		"not connected to broker of a.example.com, its routes are not set",
+		"failed to set routes of a.example.com: no default gateway",
+	})
+}
@@ -0,0 +1,134 @@
+package functions
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/devilcove/httpclient"
+	"github.com/gravitl/netclient/auth"
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/models"
+)
+
+const (
+	// defaultHostUpdateAckTimeout - seconds to wait for the server to apply a confirmed host update, when not configured
+	defaultHostUpdateAckTimeout = 30
+	// defaultHostUpdateAttempts - times a confirmed host update is published, when not configured
+	defaultHostUpdateAttempts = 3
+	// hostUpdateAckPoll - interval at which the server is checked for a confirmed host update
+	hostUpdateAckPoll = 5 * time.Second
+)
+
+// errHostUpdateNotConfirmed - the server did not apply the host update within the timeout
+var errHostUpdateNotConfirmed = errors.New("host update was not confirmed by server")
+
+// hostUpdateConfirmer - publishes a host update to a server and checks whether the server applied it
+type hostUpdateConfirmer struct {
+	publish   func(server string, action models.HostMqAction) error
+	fetchHost func(server string) (*models.Host, error)
+	timeout   time.Duration
+	poll      time.Duration
+	attempts  int
+}
+
+func newHostUpdateConfirmer() *hostUpdateConfirmer {
+	timeout := config.Netclient().HostUpdateAckTimeout
+	if timeout <= 0 {
+		timeout = defaultHostUpdateAckTimeout
+	}
+	attempts := config.Netclient().HostUpdateAttempts
+	if attempts <= 0 {
+		attempts = defaultHostUpdateAttempts
+	}
+	return &hostUpdateConfirmer{
+		publish:   PublishHostUpdate,
+		fetchHost: getServerHost,
+		timeout:   time.Duration(timeout) * time.Second,
+		poll:      hostUpdateAckPoll,
+		attempts:  attempts,
+	}
+}
+
+// PublishConfirmedHostUpdate - publishes a host update to all servers and waits for each of them to apply it,
+// republishing when the server doesn't reflect the update within the timeout
+func PublishConfirmedHostUpdate(hostAction models.HostMqAction, applied func(*models.Host) bool) error {
+	confirmer := newHostUpdateConfirmer()
+	failed := []string{}
+	for _, server := range config.GetServers() {
+		if err := confirmer.confirm(server, hostAction, applied); err != nil {
+			logger.Log(0, "host update to server", server, "failed:", err.Error())
+			failed = append(failed, server)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%w: %s", errHostUpdateNotConfirmed, strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// confirm - publishes the host update to the server until the host returned by the server satisfies applied
+func (h *hostUpdateConfirmer) confirm(server string, hostAction models.HostMqAction, applied func(*models.Host) bool) error {
+	var err error
+	for attempt := 1; attempt <= h.attempts; attempt++ {
+		if err = h.publish(server, hostAction); err != nil {
+			logger.Log(1, "attempt", strconv.Itoa(attempt), "to publish host update to", server, "failed:", err.Error())
+			continue
+		}
+		if err = h.waitApplied(server, applied); err == nil {
+			return nil
+		}
+		logger.Log(1, "attempt", strconv.Itoa(attempt), "host update to", server, "not confirmed:", err.Error())
+	}
+	return err
+}
+
+// waitApplied - polls the server until the host reflects the update or the timeout expires
+func (h *hostUpdateConfirmer) waitApplied(server string, applied func(*models.Host) bool) error {
+	deadline := time.Now().Add(h.timeout)
+	for {
+		host, err := h.fetchHost(server)
+		if err == nil && applied(host) {
+			return nil
+		}
+		if err != nil {
+			logger.Log(3, "could not fetch host from", server, err.Error())
+		}
+		if time.Now().Add(h.poll).After(deadline) {
+			return errHostUpdateNotConfirmed
+		}
+		time.Sleep(h.poll)
+	}
+}
+
+// getServerHost - returns the host as it is known to the server
+func getServerHost(serverName string) (*models.Host, error) {
+	server := config.GetServer(serverName)
+	if server == nil {
+		return nil, errors.New("server config not found")
+	}
+	token, err := auth.Authenticate(server, config.Netclient())
+	if err != nil {
+		return nil, err
+	}
+	endpoint := httpclient.JSONEndpoint[models.HostPull, models.ErrorResponse]{
+		URL:           "https://" + server.API,
+		Route:         "/api/v1/host",
+		Method:        http.MethodGet,
+		Authorization: "Bearer " + token,
+		Response:      models.HostPull{},
+		ErrorResponse: models.ErrorResponse{},
+	}
+	pullResponse, errData, err := endpoint.GetJSON(models.HostPull{}, models.ErrorResponse{})
+	if err != nil {
+		if errors.Is(err, httpclient.ErrStatus) {
+			return nil, fmt.Errorf("%d %s", errData.Code, errData.Message)
+		}
+		return nil, err
+	}
+	return &pullResponse.Host, nil
+}
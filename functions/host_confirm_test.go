@@ -0,0 +1,57 @@
+package functions
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/models"
+)
+
+func TestHostUpdateConfirmer(t *testing.T) {
+	applied := func(h *models.Host) bool { return h.Name == "updated" }
+	newConfirmer := func(publishes *int, ackOn int) *hostUpdateConfirmer {
+		return &hostUpdateConfirmer{
+			publish: func(server string, action models.HostMqAction) error {
+				*publishes++
+				if *publishes == 1 && ackOn < 0 {
+					return errors.New("broker unavailable")
+				}
+				return nil
+			},
+			fetchHost: func(server string) (*models.Host, error) {
+				if ackOn != 0 && *publishes >= ackOn {
+					return &models.Host{Name: "updated"}, nil
+				}
+				return &models.Host{Name: "stale"}, nil
+			},
+			timeout:  5 * time.Millisecond,
+			poll:     time.Millisecond,
+			attempts: 3,
+		}
+	}
+	t.Run("acked on first publish", func(t *testing.T) {
+		publishes := 0
+		if err := newConfirmer(&publishes, 1).confirm("server", models.UpdateHost, applied); err != nil || publishes != 1 {
+			t.Errorf("expected confirmation after one publish, got %v after %d", err, publishes)
+		}
+	})
+	t.Run("retried after timeout", func(t *testing.T) {
+		publishes := 0
+		if err := newConfirmer(&publishes, 2).confirm("server", models.UpdateHost, applied); err != nil || publishes != 2 {
+			t.Errorf("expected confirmation after two publishes, got %v after %d", err, publishes)
+		}
+	})
+	t.Run("retried after publish failure", func(t *testing.T) {
+		publishes := 0
+		if err := newConfirmer(&publishes, -1).confirm("server", models.UpdateHost, applied); err != nil || publishes != 2 {
+			t.Errorf("expected confirmation after a failed publish, got %v after %d", err, publishes)
+		}
+	})
+	t.Run("never acked", func(t *testing.T) {
+		publishes := 0
+		if err := newConfirmer(&publishes, 0).confirm("server", models.UpdateHost, applied); !errors.Is(err, errHostUpdateNotConfirmed) || publishes != 3 {
+			t.Errorf("expected unconfirmed error after three publishes, got %v after %d", err, publishes)
+		}
+	})
+}
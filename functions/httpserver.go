@@ -1,16 +1,22 @@
 package functions
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"log"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gravitl/netclient/config"
-	"github.com/gravitl/netclient/ncutils"
+	proxy_cfg "github.com/gravitl/netclient/nmproxy/config"
+	nmrouter "github.com/gravitl/netclient/nmproxy/router"
+	"github.com/gravitl/netclient/wireguard"
 	"github.com/gravitl/netmaker/logger"
 )
 
@@ -19,15 +25,19 @@ type Network struct {
 	Server config.Server
 }
 
+// defaultHTTPPort - port of the daemon http server when not configured
+const defaultHTTPPort = 18515
+
 func HttpServer(ctx context.Context, wg *sync.WaitGroup) {
 	defer wg.Done()
-	port, err := ncutils.GetFreeTCPPort()
+	listener, err := listenHTTP(config.Netclient().HTTPPort)
 	if err != nil {
-		logger.Log(0, "failed to get free port", err.Error())
+		logger.Log(0, "failed to listen for http server", err.Error())
 		logger.Log(0, "unable to start http server", "exiting")
 		logger.Log(0, "netclient-gui will not be available")
 		return
 	}
+	port := strconv.Itoa(listener.Addr().(*net.TCPAddr).Port)
 	config.SetGUI("127.0.0.1", port)
 	config.WriteGUIConfig()
 
@@ -38,7 +48,7 @@ func HttpServer(ctx context.Context, wg *sync.WaitGroup) {
 	}
 	logger.Log(3, "starting http server on port ", port)
 	go func() {
-		if err := svr.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		if err := svr.Serve(listener); err != nil && err != http.ErrServerClosed {
 			logger.Log(0, "https server err", err.Error())
 		}
 	}()
@@ -49,10 +59,26 @@ func HttpServer(ctx context.Context, wg *sync.WaitGroup) {
 	}
 }
 
+// listenHTTP - listens on the configured port of 127.0.0.1, or on the default port, falling back to a free port
+// when the default is taken
+func listenHTTP(port int) (net.Listener, error) {
+	if port > 0 {
+		return net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(port)))
+	}
+	listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(defaultHTTPPort)))
+	if err == nil {
+		return listener, nil
+	}
+	logger.Log(0, "default http port", strconv.Itoa(defaultHTTPPort), "is not available, using a free port", err.Error())
+	return net.Listen("tcp", "127.0.0.1:0")
+}
+
 // SetupRoute - sets routes for http server
 func SetupRouter() *gin.Engine {
 	router := gin.Default()
 	router.GET("/status", status)
+	router.GET("/health", health)
+	router.GET("/healthz", health)
 	router.POST("/register", register)
 	router.GET("/network/:net", getNetwork)
 	router.GET("/allnetworks", getAllNetworks)
@@ -63,11 +89,29 @@ func SetupRouter() *gin.Engine {
 	router.POST("/uninstall", uninstall)
 	router.GET("/pull/:net", pull)
 	router.POST("nodepeers", nodePeers)
+	router.GET("/firewall/query", queryPacket)
+	router.GET("/firewall/rules", firewallRulesHandler)
+	router.GET("/firewall/bundle", firewallBundleHandler)
+	router.GET("/peer/ping", pingPeerHandler)
+	router.GET("/proxy/conns", proxyConnsHandler)
+	router.POST("/proxy/reset", resetProxyConnHandler)
+	if config.Netclient().StatusPage {
+		router.GET("/ui", statusPage)
+	}
+	if config.Netclient().PrometheusMetrics {
+		router.GET("/metrics", prometheusMetrics)
+	}
 	return router
 }
 
 func status(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	c.JSON(http.StatusOK, gin.H{
+		"status":           "ok",
+		"proxy_conns":      proxy_cfg.GetCfg().GetProxyConnCount(),
+		"proxy_conn_limit": config.Netclient().MaxProxyConns,
+		"mtu_mismatches":   wireguard.GetMTUMismatches(),
+		"iface_mtu":        wireguard.GetIfaceMTU(),
+	})
 }
 
 func register(c *gin.Context) {
@@ -95,8 +139,12 @@ func getNetwork(c *gin.Context) {
 	for _, node := range nodes {
 		node := node
 		if node.Network == network {
-			server := *config.GetServer(node.Server)
-			c.JSON(http.StatusOK, Network{node, server})
+			server := config.GetServer(node.Server)
+			if server == nil {
+				c.JSON(http.StatusNotFound, gin.H{"error": "no server config for network"})
+				return
+			}
+			c.JSON(http.StatusOK, Network{node, *server})
 			return
 		}
 	}
@@ -109,6 +157,9 @@ func getAllNetworks(c *gin.Context) {
 	for _, node := range nodes {
 		node := node
 		server := config.GetServer(node.Server)
+		if server == nil {
+			continue
+		}
 		configs = append(configs, Network{node, *server})
 	}
 	c.JSON(http.StatusOK, configs)
@@ -192,6 +243,10 @@ func pull(c *gin.Context) {
 	}
 	node := config.GetNode(net)
 	server := config.GetServer(node.Server)
+	if server == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no server config for network"})
+		return
+	}
 	network := Network{
 		Node:   node,
 		Server: *server,
@@ -213,3 +268,73 @@ func nodePeers(c *gin.Context) {
 	c.JSON(http.StatusOK, peers)
 
 }
+
+func queryPacket(c *gin.Context) {
+	port, _ := strconv.Atoi(c.Query("port"))
+	verdict, err := nmrouter.QueryPacket(nmrouter.PacketQuery{
+		Src:         c.Query("src"),
+		Dst:         c.Query("dst"),
+		InIface:     c.Query("iif"),
+		OutIface:    c.Query("oif"),
+		Proto:       c.Query("proto"),
+		Port:        port,
+		Established: c.Query("established") == "true",
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, verdict)
+}
+
+func firewallRulesHandler(c *gin.Context) {
+	script, err := nmrouter.ExportRules(c.Query("server"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, firewallRules{Script: script})
+}
+
+// firewallBundleHandler - serves the firewall support bundle of the running daemon
+func firewallBundleHandler(c *gin.Context) {
+	var buf bytes.Buffer
+	if err := nmrouter.ExportSupportBundle(&buf); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Data(http.StatusOK, "application/json", buf.Bytes())
+}
+
+func pingPeerHandler(c *gin.Context) {
+	result, err := localPingPeer(c.Query("peer"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func proxyConnsHandler(c *gin.Context) {
+	conns, err := localProxyConns()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, conns)
+}
+
+func resetProxyConnHandler(c *gin.Context) {
+	if err := localResetProxyConn(c.Query("peer")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, nil)
+}
+
+func statusPage(c *gin.Context) {
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	if err := renderStatusPage(c.Writer, collectStatusPageData(time.Now())); err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+	}
+}
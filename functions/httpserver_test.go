@@ -0,0 +1,35 @@
+package functions
+
+import (
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/matryer/is"
+)
+
+func TestListenHTTP(t *testing.T) {
+	is := is.New(t)
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	is.NoErr(err)
+	defer taken.Close()
+	takenPort := taken.Addr().(*net.TCPAddr).Port
+
+	// a configured port is used as is, failing when it is taken
+	_, err = listenHTTP(takenPort)
+	is.True(err != nil)
+	taken.Close()
+	listener, err := listenHTTP(takenPort)
+	is.NoErr(err)
+	is.Equal(listener.Addr().String(), "127.0.0.1:"+strconv.Itoa(takenPort))
+	listener.Close()
+
+	// the default port falls back to a free port when taken
+	if def, err := net.Listen("tcp", "127.0.0.1:"+strconv.Itoa(defaultHTTPPort)); err == nil {
+		defer def.Close()
+	}
+	listener, err = listenHTTP(0)
+	is.NoErr(err)
+	defer listener.Close()
+	is.True(listener.Addr().(*net.TCPAddr).Port != defaultHTTPPort)
+}
@@ -0,0 +1,166 @@
+package functions
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netclient/ncutils"
+	"github.com/gravitl/netclient/nmproxy/router"
+)
+
+// JoinCheck - a check made by a dry-run join
+type JoinCheck struct {
+	Name   string
+	Passed bool
+	Detail string
+}
+
+// JoinPlan - what a dry-run join checked and what a join would change on the host
+type JoinPlan struct {
+	Server  string
+	Checks  []JoinCheck
+	Actions []string
+	Notes   []string
+}
+
+// JoinPlan.OK - true if all checks passed
+func (p *JoinPlan) OK() bool {
+	for _, check := range p.Checks {
+		if !check.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *JoinPlan) check(name string, passed bool, detail string) {
+	p.Checks = append(p.Checks, JoinCheck{Name: name, Passed: passed, Detail: detail})
+}
+
+// joinEnv - lookups of the host made by a dry-run join, none of them change the system
+type joinEnv struct {
+	reachServer     func(server string) error
+	servers         func() []string
+	portFree        func(port int) bool
+	firewall        func() router.FirewallInfo
+	interfaceExists func(name string) bool
+}
+
+func defaultJoinEnv() joinEnv {
+	return joinEnv{
+		reachServer: func(server string) error {
+			client := http.Client{Timeout: time.Second * 10}
+			resp, err := client.Get("https://" + server + "/api/getip")
+			if err != nil {
+				return err
+			}
+			resp.Body.Close()
+			return nil
+		},
+		servers: config.GetServers,
+		portFree: func(port int) bool {
+			conn, err := net.ListenUDP("udp", &net.UDPAddr{Port: port})
+			if err != nil {
+				return false
+			}
+			conn.Close()
+			return true
+		},
+		firewall: router.GetFirewallInfo,
+		interfaceExists: func(name string) bool {
+			_, err := net.InterfaceByName(name)
+			return err == nil
+		},
+	}
+}
+
+// DryRunRegister - validates a join with the enrollment token and reports what the join would do,
+// without registering with the server or changing the host
+func DryRunRegister(token string) (*JoinPlan, error) {
+	env := defaultJoinEnv()
+	return registerWithToken(token, config.Netclient(), &env)
+}
+
+// planJoin - checks a join of the host with the server and lists what the join would change
+func planJoin(server string, host *config.Config, env joinEnv) *JoinPlan {
+	plan := &JoinPlan{Server: server}
+	if err := env.reachServer(server); err != nil {
+		plan.check("server reachable", false, err.Error())
+	} else {
+		plan.check("server reachable", true, server)
+	}
+
+	servers := env.servers()
+	registered := false
+	for _, registeredServer := range servers {
+		if registeredServer == server {
+			registered = true
+		}
+	}
+	if registered {
+		plan.check("not registered", false, "host is already registered with "+server)
+	} else {
+		plan.check("not registered", true, "")
+	}
+
+	iface := ncutils.GetInterfaceName()
+	if len(servers) == 0 && env.interfaceExists(iface) {
+		plan.check("interface", false, "interface "+iface+" exists but the host is not registered with any server")
+	} else {
+		plan.check("interface", true, iface)
+	}
+
+	port := host.ListenPort
+	if port == 0 {
+		port = ncutils.NetclientDefaultPort
+	}
+	if env.portFree(port) {
+		plan.check("listen port", true, strconv.Itoa(port))
+	} else {
+		plan.check("listen port", true, fmt.Sprintf("%d is in use, a free port would be picked", port))
+	}
+
+	fw := env.firewall()
+	if fw.Backend == router.FirewallNone {
+		plan.check("firewall", false, "no supported firewall found, the host can not act as an ingress or egress gateway")
+	} else {
+		plan.check("firewall", true, fw.Backend)
+	}
+
+	if len(servers) == 0 {
+		plan.Actions = append(plan.Actions, firstJoinHostChanges(host)...)
+	}
+	plan.Actions = append(plan.Actions,
+		"register host with "+server+" using the enrollment token",
+		"save the server configuration and the host as returned by the server",
+		"restart the daemon, which creates interface "+iface+" and sets up peers, routes and firewall rules",
+	)
+	plan.Notes = append(plan.Notes, "node addresses are assigned by the server on registration, they can not be checked for conflicts before joining")
+	return plan
+}
+
+// firstJoinHostChanges - the host values a first join would generate, see doubleCheck
+func firstJoinHostChanges(host *config.Config) []string {
+	var changes []string
+	if len(host.Name) == 0 {
+		changes = append(changes, "set the host name")
+	}
+	if host.ID == uuid.Nil {
+		changes = append(changes, "generate a host id")
+	}
+	if len(host.HostPass) == 0 {
+		changes = append(changes, "generate a host password")
+	}
+	if host.EndpointIP == nil {
+		changes = append(changes, "detect the public endpoint of the host")
+	}
+	if len(changes) > 0 {
+		changes = append(changes, "write the host configuration")
+	}
+	return changes
+}
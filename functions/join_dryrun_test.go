@@ -0,0 +1,67 @@
+package functions
+
+import (
+	b64 "encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netclient/nmproxy/router"
+	"github.com/gravitl/netmaker/models"
+)
+
+func TestDryRunRegister(t *testing.T) {
+	data, err := json.Marshal(models.EnrollmentToken{Server: "api.example.com", Value: "abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := b64.StdEncoding.EncodeToString(data)
+	env := joinEnv{
+		reachServer:     func(string) error { return nil },
+		servers:         func() []string { return nil },
+		portFree:        func(int) bool { return true },
+		firewall:        func() router.FirewallInfo { return router.FirewallInfo{Backend: router.FirewallNftables} },
+		interfaceExists: func(string) bool { return false },
+	}
+	host := &config.Config{}
+	before := *host
+	plan, err := registerWithToken(token, host, &env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(*host, before) {
+		t.Error("dry-run changed the host configuration")
+	}
+	if !plan.OK() || plan.Server != "api.example.com" {
+		t.Errorf("unexpected plan %+v", plan)
+	}
+	// a first join generates the host identity
+	if len(plan.Actions) == 0 || plan.Actions[0] != "set the host name" {
+		t.Errorf("expected the host changes of a first join, got %v", plan.Actions)
+	}
+
+	env.reachServer = func(string) error { return errors.New("connection refused") }
+	env.servers = func() []string { return []string{"api.example.com"} }
+	env.firewall = func() router.FirewallInfo { return router.FirewallInfo{Backend: router.FirewallNone} }
+	plan, err = registerWithToken(token, host, &env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	failed := map[string]bool{}
+	for _, check := range plan.Checks {
+		if !check.Passed {
+			failed[check.Name] = true
+		}
+	}
+	for _, name := range []string{"server reachable", "not registered", "firewall"} {
+		if !failed[name] {
+			t.Errorf("expected check %q to fail", name)
+		}
+	}
+
+	if _, err := registerWithToken("not a token", host, &env); err == nil {
+		t.Error("expected an invalid token to be refused")
+	}
+}
@@ -94,6 +94,9 @@ func List(net string, long bool) {
 func GetNodePeers(node config.Node) ([]wgtypes.PeerConfig, error) {
 
 	server := config.GetServer(node.Server)
+	if server == nil {
+		return nil, fmt.Errorf("no server config for network %s", node.Network)
+	}
 	host := config.Netclient()
 	if host == nil {
 		return nil, fmt.Errorf("no configured host found")
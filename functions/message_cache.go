@@ -0,0 +1,93 @@
+package functions
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netclient/ncutils"
+	"github.com/gravitl/netmaker/logger"
+)
+
+const (
+	// messageCacheFile - file in the netclient path the message cache is persisted to
+	messageCacheFile = "message_cache.json"
+	// messageCacheTTL - time after which a cached message is expired
+	messageCacheTTL = time.Hour * 24
+)
+
+// messageCachePath - path the message cache is persisted to, empty until the cache is loaded
+var messageCachePath string
+
+// messageCacheMutex - serializes writes of the message cache file
+var messageCacheMutex sync.Mutex
+
+// networkMessages - kinds of messages cached per network
+var networkMessages = []string{lastNodeUpdate}
+
+// forgetNetworkMessages - drops the cached messages of a network the host left, so they don't pile up
+// over join/leave cycles
+func forgetNetworkMessages(network string) {
+	for _, which := range networkMessages {
+		messageCache.Delete(fmt.Sprintf("%s%s", network, which))
+	}
+	flushMessageCache()
+}
+
+// loadMessageCache - loads the persisted message cache into memory, skipping expired messages
+func loadMessageCache() {
+	messageCacheMutex.Lock()
+	messageCachePath = config.GetNetclientPath() + messageCacheFile
+	messageCacheMutex.Unlock()
+	loadMessageCacheFrom(messageCachePath, time.Now())
+}
+
+// loadMessageCacheFrom - loads the message cache from file, skipping messages expired at now
+func loadMessageCacheFrom(file string, now time.Time) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Log(0, "failed to read message cache", err.Error())
+		}
+		return
+	}
+	cached := map[string]cachedMessage{}
+	if err := json.Unmarshal(data, &cached); err != nil {
+		logger.Log(0, "failed to decode message cache", err.Error())
+		return
+	}
+	for key, msg := range cached {
+		if msg.LastSeen.IsZero() || now.After(msg.LastSeen.Add(messageCacheTTL)) {
+			continue
+		}
+		messageCache.Store(key, msg)
+	}
+}
+
+// flushMessageCache - writes the message cache to disk once it is loaded
+func flushMessageCache() {
+	messageCacheMutex.Lock()
+	defer messageCacheMutex.Unlock()
+	if messageCachePath == "" {
+		return
+	}
+	if err := writeMessageCache(messageCachePath); err != nil {
+		logger.Log(0, "failed to persist message cache", err.Error())
+	}
+}
+
+// writeMessageCache - writes the cached messages to file, readable by the owner only as they hold node configs
+func writeMessageCache(file string) error {
+	cached := map[string]cachedMessage{}
+	messageCache.Range(func(key, value any) bool {
+		cached[key.(string)] = value.(cachedMessage)
+		return true
+	})
+	return ncutils.WriteFileAtomic(file, 0600, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(cached)
+	})
+}
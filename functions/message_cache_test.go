@@ -0,0 +1,48 @@
+package functions
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestMessageCachePersistence(t *testing.T) {
+	is := is.New(t)
+	defer func() { messageCache = new(sync.Map) }()
+	file := filepath.Join(t.TempDir(), messageCacheFile)
+	now := time.Now()
+	messageCache = new(sync.Map)
+	messageCache.Store("netmaker"+lastNodeUpdate, cachedMessage{Message: "node update", LastSeen: now})
+	messageCache.Store("netmaker"+lastDNSUpdate, cachedMessage{Message: "dns update", LastSeen: now.Add(-messageCacheTTL - time.Minute)})
+	is.NoErr(writeMessageCache(file))
+
+	messageCache = new(sync.Map)
+	loadMessageCacheFrom(file, now)
+	is.Equal(read("netmaker", lastNodeUpdate), "node update")
+	_, ok := messageCache.Load("netmaker" + lastDNSUpdate)
+	is.True(!ok) // expired messages are not loaded
+}
+
+func TestForgetNetworkMessages(t *testing.T) {
+	is := is.New(t)
+	defer func() { messageCache = new(sync.Map) }()
+	messageCache = new(sync.Map)
+	insert("dns", lastDNSUpdate, "dns update")
+	for i := 0; i < 10; i++ {
+		network := fmt.Sprintf("net%d", i)
+		insert(network, lastNodeUpdate, "node update")
+		is.Equal(read(network, lastNodeUpdate), "node update")
+		forgetNetworkMessages(network)
+	}
+	entries := 0
+	messageCache.Range(func(key, value any) bool {
+		entries++
+		return true
+	})
+	is.Equal(entries, 1) // only the dns message survives the left networks
+	is.Equal(read("dns", lastDNSUpdate), "dns update")
+}
@@ -0,0 +1,61 @@
+package functions
+
+import (
+	"math/rand"
+	"time"
+
+	"github.com/gravitl/netclient/config"
+)
+
+const (
+	// defaultMQBackoffMin - seconds to wait before the first broker connection retry, when not configured
+	defaultMQBackoffMin = 2
+	// defaultMQBackoffMax - maximum seconds to wait between broker connection retries, when not configured
+	defaultMQBackoffMax = 120
+	// mqBackoffJitter - fraction of the delay that is randomly added or removed
+	mqBackoffJitter = 0.2
+)
+
+// mqBackoff - exponential backoff between broker connection retries
+type mqBackoff struct {
+	min, max time.Duration
+	current  time.Duration
+	jitter   func() float64
+}
+
+// newMQBackoff - returns a backoff starting at min and doubling up to max
+func newMQBackoff(min, max time.Duration) *mqBackoff {
+	if min <= 0 {
+		min = defaultMQBackoffMin * time.Second
+	}
+	if max < min {
+		max = min
+	}
+	return &mqBackoff{min: min, max: max, jitter: rand.Float64}
+}
+
+// configuredMQBackoff - returns the backoff given by the netclient config
+func configuredMQBackoff() *mqBackoff {
+	min, max := config.Netclient().MQBackoffMin, config.Netclient().MQBackoffMax
+	if min <= 0 {
+		min = defaultMQBackoffMin
+	}
+	if max <= 0 {
+		max = defaultMQBackoffMax
+	}
+	return newMQBackoff(time.Duration(min)*time.Second, time.Duration(max)*time.Second)
+}
+
+// mqBackoff.next - returns the delay before the next retry, with jitter applied, and doubles the delay up to the cap
+func (b *mqBackoff) next() time.Duration {
+	if b.current == 0 {
+		b.current = b.min
+	} else if b.current < b.max {
+		b.current *= 2
+		if b.current > b.max {
+			b.current = b.max
+		}
+	}
+	delta := time.Duration(float64(b.current) * mqBackoffJitter * (2*b.jitter() - 1))
+	return b.current + delta
+}
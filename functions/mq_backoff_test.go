@@ -0,0 +1,43 @@
+package functions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestMQBackoffSequence(t *testing.T) {
+	is := is.New(t)
+	b := newMQBackoff(2*time.Second, 2*time.Minute)
+	b.jitter = func() float64 { return 0.5 } // no jitter
+	want := []time.Duration{2, 4, 8, 16, 32, 64, 120, 120}
+	for i := range want {
+		is.Equal(b.next(), want[i]*time.Second)
+	}
+}
+
+func TestMQBackoffJitter(t *testing.T) {
+	is := is.New(t)
+	b := newMQBackoff(10*time.Second, time.Minute)
+	b.jitter = func() float64 { return 0 }
+	is.Equal(b.next(), 8*time.Second)
+	b.jitter = func() float64 { return 1 }
+	is.Equal(b.next(), 24*time.Second)
+	for i := 0; i < 100; i++ {
+		b := newMQBackoff(10*time.Second, time.Minute)
+		d := b.next()
+		is.True(d >= 8*time.Second && d <= 12*time.Second)
+	}
+}
+
+func TestMQBackoffBounds(t *testing.T) {
+	is := is.New(t)
+	b := newMQBackoff(0, 0)
+	is.Equal(b.min, defaultMQBackoffMin*time.Second)
+	is.Equal(b.max, b.min)
+	b = newMQBackoff(time.Minute, time.Second)
+	b.jitter = func() float64 { return 0.5 }
+	is.Equal(b.next(), time.Minute)
+	is.Equal(b.next(), time.Minute)
+}
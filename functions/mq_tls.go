@@ -0,0 +1,60 @@
+package functions
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/gravitl/netclient/config"
+)
+
+// mqTLSConfig - builds the tls config for the broker of the server from its client certificate and CA file,
+// nil if neither is configured
+func mqTLSConfig(server *config.Server) (*tls.Config, error) {
+	if server.MQTLSCert == "" && server.MQTLSKey == "" && server.MQCAFile == "" {
+		return nil, nil
+	}
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if server.MQTLSCert != "" || server.MQTLSKey != "" {
+		if server.MQTLSCert == "" || server.MQTLSKey == "" {
+			return nil, errors.New("mq client certificate and key must both be set")
+		}
+		cert, err := tls.LoadX509KeyPair(server.MQTLSCert, server.MQTLSKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load mq client certificate: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+	if server.MQCAFile != "" {
+		ca, err := os.ReadFile(server.MQCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read mq CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates found in mq CA file %s", server.MQCAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+	return tlsCfg, nil
+}
+
+// setMQAuth - authenticates to the broker of the server with its client certificate when configured,
+// with username and password otherwise
+func setMQAuth(opts *mqtt.ClientOptions, server *config.Server) error {
+	tlsCfg, err := mqTLSConfig(server)
+	if err != nil {
+		return err
+	}
+	if tlsCfg != nil {
+		opts.SetTLSConfig(tlsCfg)
+	}
+	if tlsCfg == nil || len(tlsCfg.Certificates) == 0 {
+		opts.SetUsername(server.MQUserName)
+		opts.SetPassword(server.MQPassword)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package functions
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"github.com/gravitl/netclient/config"
+	"github.com/matryer/is"
+)
+
+// writeTestCert - writes a self signed certificate and its key to dir
+func writeTestCert(t *testing.T, dir string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "netclient"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		IsCA:         true,
+		KeyUsage:     x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestSetMQAuth(t *testing.T) {
+	is := is.New(t)
+	certFile, keyFile := writeTestCert(t, t.TempDir())
+	newServer := func() *config.Server {
+		server := &config.Server{}
+		server.MQUserName = "user"
+		server.MQPassword = "pass"
+		return server
+	}
+
+	t.Run("password", func(t *testing.T) {
+		opts := mqtt.NewClientOptions()
+		is.NoErr(setMQAuth(opts, newServer()))
+		is.Equal(opts.Username, "user")
+		is.True(opts.TLSConfig == nil)
+	})
+	t.Run("client certificate", func(t *testing.T) {
+		server := newServer()
+		server.MQTLSCert, server.MQTLSKey, server.MQCAFile = certFile, keyFile, certFile
+		opts := mqtt.NewClientOptions()
+		is.NoErr(setMQAuth(opts, server))
+		is.Equal(opts.Username, "")
+		is.Equal(len(opts.TLSConfig.Certificates), 1)
+		is.True(opts.TLSConfig.RootCAs != nil)
+	})
+	t.Run("key missing", func(t *testing.T) {
+		server := newServer()
+		server.MQTLSCert = certFile
+		is.True(setMQAuth(mqtt.NewClientOptions(), server) != nil)
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"net"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	mqtt "github.com/eclipse/paho.mqtt.golang"
@@ -15,7 +16,9 @@ import (
 	"github.com/gravitl/netclient/ncutils"
 	"github.com/gravitl/netclient/networking"
 	proxyCfg "github.com/gravitl/netclient/nmproxy/config"
+	"github.com/gravitl/netclient/nmproxy/manager"
 	"github.com/gravitl/netclient/nmproxy/turn"
+	"github.com/gravitl/netclient/nmproxy/wg"
 	"github.com/gravitl/netclient/routes"
 	"github.com/gravitl/netclient/wireguard"
 	"github.com/gravitl/netmaker/logger"
@@ -27,6 +30,15 @@ import (
 // MQTimeout - time out for mqtt connections
 const MQTimeout = 30
 
+var (
+	inetGWMutex  sync.Mutex
+	inetGW4InUse bool // indicates if the default route is set through the IPv4 gateway peer
+	inetGW6InUse bool // indicates if the default route is set through the IPv6 gateway peer
+)
+
+// inetGWHandshakeTimeout - age of the latest handshake after which an internet gateway peer is not taken to be connected
+const inetGWHandshakeTimeout = 3 * time.Minute
+
 // All -- mqtt message hander for all ('#') topics
 var All mqtt.MessageHandler = func(client mqtt.Client, msg mqtt.Message) {
 	logger.Log(0, "default message handler -- received message but not handling")
@@ -67,6 +79,11 @@ func NodeUpdate(client mqtt.Client, msg mqtt.Message) {
 	switch newNode.Action {
 	case models.NODE_DELETE:
 		logger.Log(0, "network:", newNode.Network, "received delete request for", newNode.ID.String())
+		if leavePending(config.GetNetclientPath(), newNode.ID.String(), time.Now()) {
+			// an acknowledged leave is in progress and handles the local teardown
+			unsubscribeNode(client, &newNode)
+			return
+		}
 		unsubscribeNode(client, &newNode)
 		if _, err = LeaveNetwork(newNode.Network, true); err != nil {
 			if !strings.Contains("rpc error", err.Error()) {
@@ -83,7 +100,10 @@ func NodeUpdate(client mqtt.Client, msg mqtt.Message) {
 	}
 	// Save new config
 	newNode.Action = models.NODE_NOOP
-	config.UpdateNodeMap(network, newNode)
+	if err := config.UpdateNodeMapResolved(network, newNode, config.Netclient().NodeConflictPolicy); err != nil {
+		logger.Log(0, "network", network, err.Error())
+		return
+	}
 	if err := config.WriteNodeConfig(); err != nil {
 		logger.Log(0, newNode.Network, "error updating node configuration: ", err.Error())
 	}
@@ -100,6 +120,10 @@ func NodeUpdate(client mqtt.Client, msg mqtt.Message) {
 	}
 	if keepaliveChange {
 		wireguard.UpdateKeepAlive(int(newNode.PersistentKeepalive.Seconds()))
+		// apply the keepalive of the network to the peers on the interface
+		if err := wireguard.SetPeers(); err != nil {
+			logger.Log(0, "network:", newNode.Network, "failed to apply keepalive to peers", err.Error())
+		}
 	}
 	time.Sleep(time.Second)
 	if ifaceDelta { // if a change caused an ifacedelta we need to notify the server to update the peers
@@ -131,6 +155,7 @@ func HostPeerUpdate(client mqtt.Client, msg mqtt.Message) {
 	if err != nil {
 		return
 	}
+	ackReceived(serverName)
 	err = json.Unmarshal([]byte(data), &peerUpdate)
 	if err != nil {
 		logger.Log(0, "error unmarshalling peer data")
@@ -157,7 +182,8 @@ func HostPeerUpdate(client mqtt.Client, msg mqtt.Message) {
 		return
 	}
 
-	gwDetected := config.GW4PeerDetected || config.GW6PeerDetected
+	gw4Detected := config.GW4PeerDetected
+	gw6Detected := config.GW6PeerDetected
 	currentGW4 := config.GW4Addr
 	currentGW6 := config.GW6Addr
 	isInetGW := config.UpdateHostPeers(serverName, peerUpdate.Peers)
@@ -168,17 +194,12 @@ func HostPeerUpdate(client mqtt.Client, msg mqtt.Message) {
 		logger.Log(0, "error when setting peer routes after peer update", err.Error())
 	}
 	_ = wireguard.GetInterface().ApplyAddrs(true)
-	gwDelta := (currentGW4.IP != nil && !currentGW4.IP.Equal(config.GW4Addr.IP)) ||
-		(currentGW6.IP != nil && !currentGW6.IP.Equal(config.GW6Addr.IP))
-	originalGW := currentGW4
-	if originalGW.IP != nil {
-		originalGW = currentGW6
-	}
 	handlePeerInetGateways(
-		gwDetected,
+		gw4Detected,
+		gw6Detected,
 		isInetGW,
-		gwDelta,
-		&originalGW,
+		&currentGW4,
+		&currentGW6,
 	)
 
 	go handleEndpointDetection(&peerUpdate)
@@ -203,6 +224,7 @@ func HostUpdate(client mqtt.Client, msg mqtt.Message) {
 	if err != nil {
 		return
 	}
+	ackReceived(serverName)
 	err = json.Unmarshal([]byte(data), &hostUpdate)
 	if err != nil {
 		logger.Log(0, "error unmarshalling host update data")
@@ -216,7 +238,11 @@ func HostUpdate(client mqtt.Client, msg mqtt.Message) {
 		nodeCfg := config.Node{
 			CommonNode: commonNode,
 		}
-		config.UpdateNodeMap(hostUpdate.Node.Network, nodeCfg)
+		if err := config.UpdateNodeMapResolved(hostUpdate.Node.Network, nodeCfg, config.Netclient().NodeConflictPolicy); err != nil {
+			logger.Log(0, "not joining network", hostUpdate.Node.Network, "on server", serverName, err.Error())
+			clearRetainedMsg(client, msg.Topic())
+			return
+		}
 		server := config.GetServer(serverName)
 		if server == nil {
 			return
@@ -332,11 +358,25 @@ func deleteHostCfg(client mqtt.Client, server string) {
 		if node.Server == server {
 			unsubscribeNode(client, &node)
 			config.DeleteNode(k)
+			forgetNetworkMessages(node.Network)
 		}
 	}
 	config.DeleteServer(server)
 	// delete mq client from ServerSet map
-	delete(ServerSet, server)
+	deleteServerClient(server)
+	forgetGatewayRoles(server)
+}
+
+// forgetGatewayRoles - drops the gateway roles of a removed server, on the proxy manager loop if it is running
+func forgetGatewayRoles(server string) {
+	forget := func() { manager.ForgetServer(server) }
+	if !proxyCfg.GetCfg().IsProxyRunning() {
+		forget()
+		return
+	}
+	if err := manager.RunOnLoop(forget, proxyLoopTimeout); err != nil {
+		logger.Log(0, "failed to drop the gateway roles of server", server, err.Error())
+	}
 }
 
 func updateHostConfig(host *models.Host) (resetInterface, restart bool) {
@@ -522,37 +562,120 @@ func isAddressInPeers(ip net.IP, cidrs []net.IPNet) bool {
 	return false
 }
 
-func handlePeerInetGateways(gwDetected, isHostInetGateway, gwDelta bool, originalGW *net.IPNet) { // isHostInetGateway indicates if host should worry about setting gateways
-	if gwDelta { // handle switching gateway IP to other GW peer
-		if config.GW4PeerDetected {
-			if err := routes.RemoveDefaultGW(originalGW); err != nil {
-				logger.Log(3, "failed to remove default gateway from peer", originalGW.String(), err.Error())
-			}
-			if err := routes.SetDefaultGateway(&config.GW4Addr); err != nil {
-				logger.Log(3, "failed to change default gateway to peer", config.GW4Addr.String(), err.Error())
-			}
-		} else if config.GW6PeerDetected {
-			if err := routes.SetDefaultGateway(&config.GW6Addr); err != nil {
-				logger.Log(3, "failed to set default gateway to peer", config.GW4Addr.String(), err.Error())
-			}
+// handlePeerInetGateways - sets, switches or removes the default routes through the internet gateway peers,
+// IPv4 and IPv6 gateways are handled independently of each other
+func handlePeerInetGateways(gw4WasDetected, gw6WasDetected, isHostInetGateway bool, originalGW4, originalGW6 *net.IPNet) { // isHostInetGateway indicates if host should worry about setting gateways
+	inetGWMutex.Lock()
+	defer inetGWMutex.Unlock()
+	useGW4, useGW6 := selectInetGateways(
+		config.Netclient().InetGatewayPolicy,
+		config.GW4PeerDetected,
+		config.GW6PeerDetected,
+		getPeerHandshake(config.GW4Addr),
+		getPeerHandshake(config.GW6Addr),
+		time.Now(),
+	)
+	routes.SetInetGatewaysInUse(useGW4, useGW6)
+	changed := useGW4 != inetGW4InUse || useGW6 != inetGW6InUse
+	handleInetGateway(gw4WasDetected && inetGW4InUse, useGW4, isHostInetGateway, originalGW4, &config.GW4Addr)
+	handleInetGateway(gw6WasDetected && inetGW6InUse, useGW6, isHostInetGateway, originalGW6, &config.GW6Addr)
+	inetGW4InUse, inetGW6InUse = useGW4, useGW6
+	if changed {
+		go setInetGatewayRoutes()
+	}
+}
+
+// setInetGatewayRoutes - sets the server and peer endpoint routes for the internet gateways in use
+func setInetGatewayRoutes() {
+	if err := routes.SetNetmakerPeerEndpointRoutes(config.Netclient().DefaultInterface); err != nil {
+		logger.Log(0, "error when setting peer routes for the internet gateways", err.Error())
+	}
+	for _, name := range config.GetServers() {
+		if server := config.GetServer(name); server != nil {
+			setServerRoutes(server)
 		}
-	} else {
-		if !gwDetected && config.GW4PeerDetected && !isHostInetGateway { // ipv4 gateways take priority
-			if err := routes.SetDefaultGateway(&config.GW4Addr); err != nil {
-				logger.Log(3, "failed to set default gateway to peer", config.GW4Addr.String(), err.Error())
-			}
-		} else if gwDetected && !config.GW4PeerDetected {
-			if err := routes.RemoveDefaultGW(&config.GW4Addr); err != nil {
-				logger.Log(3, "failed to remove default gateway to peer", config.GW4Addr.String())
-			}
-		} else if !gwDetected && config.GW6PeerDetected && !isHostInetGateway {
-			if err := routes.SetDefaultGateway(&config.GW6Addr); err != nil {
-				logger.Log(3, "failed to set default gateway to peer", config.GW6Addr.String())
-			}
-		} else if gwDetected && !config.GW6PeerDetected {
-			if err := routes.RemoveDefaultGW(&config.GW6Addr); err != nil {
-				logger.Log(3, "failed to remove default gateway to peer", config.GW6Addr.String())
+	}
+}
+
+// inetGatewaysInUse - returns the addresses of the internet gateway peers the default routes go through
+func inetGatewaysInUse() []*net.IPNet {
+	inetGWMutex.Lock()
+	defer inetGWMutex.Unlock()
+	gwAddrs := []*net.IPNet{}
+	if inetGW4InUse {
+		gwAddrs = append(gwAddrs, &config.GW4Addr)
+	}
+	if inetGW6InUse {
+		gwAddrs = append(gwAddrs, &config.GW6Addr)
+	}
+	return gwAddrs
+}
+
+// selectInetGateways - decides which of the detected internet gateways are used as default gateway of their family,
+// when both IPv4 and IPv6 gateways are present the policy decides which are used without a recent handshake
+func selectInetGateways(policy string, gw4Detected, gw6Detected bool, gw4Handshake, gw6Handshake, now time.Time) (useGW4, useGW6 bool) {
+	if !gw4Detected || !gw6Detected {
+		return gw4Detected, gw6Detected
+	}
+	connected := func(handshake time.Time) bool {
+		return !handshake.IsZero() && now.Sub(handshake) < inetGWHandshakeTimeout
+	}
+	switch policy {
+	case config.InetGWPreferV6:
+		return connected(gw4Handshake), true
+	case config.InetGWPreferConnectivity:
+		useGW4, useGW6 = connected(gw4Handshake), connected(gw6Handshake)
+		if !useGW4 && !useGW6 {
+			return !gw6Handshake.After(gw4Handshake), gw6Handshake.After(gw4Handshake)
+		}
+		return useGW4, useGW6
+	case config.InetGWPreferV4, "":
+		return true, connected(gw6Handshake)
+	default:
+		logger.Log(0, "unknown internet gateway policy", policy, "preferring IPv4 gateway")
+		return true, connected(gw6Handshake)
+	}
+}
+
+// getPeerHandshake - fetches the latest handshake of the peer having the given address
+func getPeerHandshake(addr net.IPNet) time.Time {
+	if addr.IP == nil {
+		return time.Time{}
+	}
+	iface, err := wg.GetWgIface(ncutils.GetInterfaceName())
+	if err != nil {
+		return time.Time{}
+	}
+	for _, peer := range iface.Device.Peers {
+		for _, allowedIP := range peer.AllowedIPs {
+			if allowedIP.IP.Equal(addr.IP) {
+				return peer.LastHandshakeTime
 			}
 		}
 	}
+	return time.Time{}
+}
+
+// handleInetGateway - applies the default route changes for the internet gateway peer of a single address family
+func handleInetGateway(wasDetected, detected, isHostInetGateway bool, originalGW, currentGW *net.IPNet) {
+	if originalGW == nil || originalGW.IP == nil {
+		originalGW = currentGW
+	}
+	switch {
+	case detected && wasDetected && !originalGW.IP.Equal(currentGW.IP): // handle switching gateway IP to other GW peer
+		if err := routes.RemoveDefaultGW(originalGW); err != nil {
+			logger.Log(3, "failed to remove default gateway from peer", originalGW.String(), err.Error())
+		}
+		if err := routes.SetDefaultGateway(currentGW); err != nil {
+			logger.Log(3, "failed to change default gateway to peer", currentGW.String(), err.Error())
+		}
+	case detected && !wasDetected && !isHostInetGateway:
+		if err := routes.SetDefaultGateway(currentGW); err != nil {
+			logger.Log(3, "failed to set default gateway to peer", currentGW.String(), err.Error())
+		}
+	case !detected && wasDetected:
+		if err := routes.RemoveDefaultGW(originalGW); err != nil {
+			logger.Log(3, "failed to remove default gateway to peer", originalGW.String())
+		}
+	}
 }
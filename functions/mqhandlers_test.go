@@ -0,0 +1,57 @@
+package functions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gravitl/netclient/config"
+	"github.com/matryer/is"
+)
+
+func TestSelectInetGateways(t *testing.T) {
+	is := is.New(t)
+	now := time.Now()
+	recent := now.Add(-time.Minute)
+	stale := now.Add(-time.Hour)
+	t.Run("single gateway ignores policy", func(t *testing.T) {
+		useGW4, useGW6 := selectInetGateways(config.InetGWPreferV4, false, true, time.Time{}, stale, now)
+		is.Equal(useGW4, false)
+		is.Equal(useGW6, true)
+	})
+	t.Run("both present default policy", func(t *testing.T) {
+		useGW4, useGW6 := selectInetGateways("", true, true, stale, recent, now)
+		is.Equal(useGW4, true)
+		is.Equal(useGW6, true)
+		useGW4, useGW6 = selectInetGateways("", true, true, recent, stale, now)
+		is.Equal(useGW4, true)
+		is.Equal(useGW6, false)
+	})
+	t.Run("both present prefer v4", func(t *testing.T) {
+		useGW4, useGW6 := selectInetGateways(config.InetGWPreferV4, true, true, recent, recent, now)
+		is.Equal(useGW4, true)
+		is.Equal(useGW6, true)
+		useGW4, useGW6 = selectInetGateways(config.InetGWPreferV4, true, true, stale, time.Time{}, now)
+		is.Equal(useGW4, true)
+		is.Equal(useGW6, false)
+	})
+	t.Run("both present prefer v6", func(t *testing.T) {
+		useGW4, useGW6 := selectInetGateways(config.InetGWPreferV6, true, true, recent, recent, now)
+		is.Equal(useGW4, true)
+		is.Equal(useGW6, true)
+		useGW4, useGW6 = selectInetGateways(config.InetGWPreferV6, true, true, time.Time{}, stale, now)
+		is.Equal(useGW4, false)
+		is.Equal(useGW6, true)
+	})
+	t.Run("both present prefer connectivity", func(t *testing.T) {
+		useGW4, useGW6 := selectInetGateways(config.InetGWPreferConnectivity, true, true, recent, recent, now)
+		is.Equal(useGW4, true)
+		is.Equal(useGW6, true)
+		useGW4, useGW6 = selectInetGateways(config.InetGWPreferConnectivity, true, true, stale, recent, now)
+		is.Equal(useGW4, false)
+		is.Equal(useGW6, true)
+		// neither connected, the most recent handshake wins
+		useGW4, useGW6 = selectInetGateways(config.InetGWPreferConnectivity, true, true, stale, stale.Add(time.Minute), now)
+		is.Equal(useGW4, false)
+		is.Equal(useGW6, true)
+	})
+}
@@ -16,7 +16,12 @@ import (
 	"github.com/gravitl/netclient/auth"
 	"github.com/gravitl/netclient/config"
 	"github.com/gravitl/netclient/ncutils"
+	"github.com/gravitl/netclient/nmproxy"
 	proxyCfg "github.com/gravitl/netclient/nmproxy/config"
+	"github.com/gravitl/netclient/nmproxy/manager"
+	ncmodels "github.com/gravitl/netclient/nmproxy/models"
+	"github.com/gravitl/netclient/nmproxy/router"
+	"github.com/gravitl/netclient/routes"
 	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/logic/metrics"
 	"github.com/gravitl/netmaker/models"
@@ -24,6 +29,9 @@ import (
 
 var metricsCache = new(sync.Map)
 
+// checkinRequests - signals the checkin routine to check in ahead of its interval, holds at most one request
+var checkinRequests = make(chan struct{}, 1)
+
 const (
 	// ACK - acknowledgement signal for MQ
 	ACK = 1
@@ -46,8 +54,12 @@ func Checkin(ctx context.Context, wg *sync.WaitGroup) {
 		case <-ctx.Done():
 			logger.Log(0, "checkin routine closed")
 			return
+		case <-checkinRequests:
+			if len(config.GetServers()) > 0 {
+				checkin()
+			}
 		case <-ticker.C:
-			for server, mqclient := range ServerSet {
+			for server, mqclient := range serverClients() {
 				mqclient := mqclient
 				if mqclient == nil || !mqclient.IsConnected() {
 					logger.Log(0, "MQ client is not connected, skipping checkin for server", server)
@@ -57,7 +69,31 @@ func Checkin(ctx context.Context, wg *sync.WaitGroup) {
 			if len(config.GetServers()) > 0 {
 				checkin()
 			}
+			if ttl := config.Netclient().ExtClientRuleTTL; ttl > 0 {
+				expireIdleExtClients(time.Duration(ttl) * time.Minute)
+			}
+		}
+	}
+}
+
+// expireIdleExtClients - expires the ingress rules of idle ext clients, on the proxy manager loop when the proxy
+// is running so the rule changes don't interleave with the gateway updates it applies
+func expireIdleExtClients(ttl time.Duration) {
+	expire := func() { router.ExpireIdleExtClients(ttl) }
+	if proxyCfg.GetCfg().IsProxyRunning() {
+		if err := manager.RunOnLoop(expire, proxyLoopTimeout); err != nil {
+			logger.Log(1, "failed to expire idle ext clients", err.Error())
 		}
+		return
+	}
+	expire()
+}
+
+// requestCheckin - asks the checkin routine to check in now, a request already pending covers this one
+func requestCheckin() {
+	select {
+	case checkinRequests <- struct{}{}:
+	default:
 	}
 }
 
@@ -65,6 +101,7 @@ func checkin() {
 	// check/update host settings; publish if changed
 	if err := UpdateHostSettings(); err != nil {
 		logger.Log(0, "failed to update host settings", err.Error())
+		recordError("failed to update host settings", err.Error())
 		return
 	}
 	if err := PublishGlobalHostUpdate(models.HostMqAction(models.CheckIn)); err != nil {
@@ -76,7 +113,7 @@ func checkin() {
 // PublishNodeUpdate -- pushes node to broker
 func PublishNodeUpdate(node *config.Node) error {
 	server := config.GetServer(node.Server)
-	if server.Name == "" {
+	if server == nil || server.Name == "" {
 		return errors.New("no server for " + node.Network)
 	}
 	data, err := json.Marshal(node)
@@ -91,23 +128,58 @@ func PublishNodeUpdate(node *config.Node) error {
 	return nil
 }
 
+// hostUpdate - host update along with the firewall backend in use on the host
+// and, on checkin, the state of the host's routes to the server and how its peers are connected
+type hostUpdate struct {
+	models.HostUpdate
+	Firewall  router.FirewallInfo      `json:"firewall"`
+	Routes    *routes.ServerRouteState `json:"routes,omitempty"`
+	ConnModes *ConnModeSummary         `json:"conn_modes,omitempty"`
+}
+
+// firewallInfo - returns the firewall reported in host updates
+var firewallInfo = router.GetFirewallInfo
+
+// newHostUpdate - builds the host update payload for the action
+func newHostUpdate(server string, hostAction models.HostMqAction) hostUpdate {
+	host := config.Netclient().Host
+	if serverCfg := config.GetServer(server); serverCfg != nil && serverCfg.ProxyDisabled {
+		// peers of a server with proxy disabled reach the host directly
+		host.ProxyEnabled = false
+		host.ProxyListenPort = 0
+	}
+	return hostUpdate{
+		HostUpdate: models.HostUpdate{
+			Action: hostAction,
+			Host:   host,
+		},
+		Firewall: firewallInfo(),
+	}
+}
+
 // PublishGlobalHostUpdate - publishes host updates to all the servers host is registered.
 func PublishGlobalHostUpdate(hostAction models.HostMqAction) error {
 	servers := config.GetServers()
 	hostCfg := config.Netclient()
-	hostUpdate := models.HostUpdate{
-		Action: hostAction,
-		Host:   hostCfg.Host,
-	}
-	data, err := json.Marshal(hostUpdate)
-	if err != nil {
-		return err
-	}
 	for _, server := range servers {
+		update := newHostUpdate(server, hostAction)
+		if hostAction == models.HostMqAction(models.CheckIn) {
+			routeState := routes.GetServerRouteState(server)
+			update.Routes = &routeState
+			connModes := serverConnModes(server)
+			update.ConnModes = &connModes
+		}
+		data, err := json.Marshal(update)
+		if err != nil {
+			return err
+		}
 		if err = publish(server, fmt.Sprintf("host/serverupdate/%s/%s", server, hostCfg.ID.String()), data, 1); err != nil {
 			logger.Log(1, "failed to publish host update to: ", server, err.Error())
 			continue
 		}
+		if hostAction == models.HostMqAction(models.CheckIn) {
+			serverActivity.checkedIn(server, time.Now())
+		}
 	}
 	return nil
 }
@@ -115,11 +187,7 @@ func PublishGlobalHostUpdate(hostAction models.HostMqAction) error {
 // PublishHostUpdate - publishes host updates to server
 func PublishHostUpdate(server string, hostAction models.HostMqAction) error {
 	hostCfg := config.Netclient()
-	hostUpdate := models.HostUpdate{
-		Action: hostAction,
-		Host:   hostCfg.Host,
-	}
-	data, err := json.Marshal(hostUpdate)
+	data, err := json.Marshal(newHostUpdate(server, hostAction))
 	if err != nil {
 		return err
 	}
@@ -132,6 +200,10 @@ func PublishHostUpdate(server string, hostAction models.HostMqAction) error {
 // publishMetrics - publishes the metrics of a given nodecfg
 func publishMetrics(node *config.Node) {
 	server := config.GetServer(node.Server)
+	if server == nil {
+		logger.Log(1, "no server config to publish metrics for network", node.Network)
+		return
+	}
 	token, err := auth.Authenticate(server, config.Netclient())
 	if err != nil {
 		logger.Log(1, "failed to authenticate when publishing metrics", err.Error())
@@ -200,9 +272,36 @@ func publishMetrics(node *config.Node) {
 	}
 }
 
+// watchConnModeChanges - publishes the connection mode changes of proxied peers to the server
+func watchConnModeChanges(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case change := <-proxyCfg.ConnModeChangeChan:
+			if err := publishConnModeChange(change); err != nil {
+				logger.Log(1, "failed to publish connection mode change for peer", change.PeerKey, err.Error())
+			}
+		}
+	}
+}
+
+// publishConnModeChange - publishes a connection mode change of a peer to the server
+func publishConnModeChange(change ncmodels.ConnModeChange) error {
+	data, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	return publish(change.Server, fmt.Sprintf("host/connmode/%s/%s", change.Server, config.Netclient().ID.String()), data, 0)
+}
+
 func publish(serverName, dest string, msg []byte, qos byte) error {
 	// setup the keys
 	server := config.GetServer(serverName)
+	if server == nil {
+		return errors.New("no server config for " + serverName)
+	}
 	serverPubKey, err := ncutils.ConvertBytesToKey(server.TrafficKey)
 	if err != nil {
 		return err
@@ -215,7 +314,7 @@ func publish(serverName, dest string, msg []byte, qos byte) error {
 	if err != nil {
 		return err
 	}
-	mqclient, ok := ServerSet[serverName]
+	mqclient, ok := getServerClient(serverName)
 	if !ok {
 		return errors.New("unable to publish ... no mqclient")
 	}
@@ -321,16 +420,11 @@ func UpdateHostSettings() error {
 			}
 		}
 	}
-	defaultInterface, err := getDefaultInterface()
-	if err != nil {
-		logger.Log(0, "default gateway not found", err.Error())
-	} else {
-		if defaultInterface != config.Netclient().DefaultInterface &&
-			defaultInterface != ncutils.GetInterfaceName() {
-			publishMsg = true
-			config.Netclient().DefaultInterface = defaultInterface
-			logger.Log(0, "default interface has changed to", defaultInterface)
-		}
+	if config.Netclient().ProxyEnabled {
+		nmproxy.CheckLocalAddr()
+	}
+	if defaultIfaceChanged(getDefaultInterface, defaultIfaceRoutes) {
+		publishMsg = true
 	}
 	if config.FirewallHasChanged() {
 		config.SetFirewall()
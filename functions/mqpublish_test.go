@@ -0,0 +1,67 @@
+package functions
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netclient/nmproxy/router"
+	"github.com/gravitl/netmaker/models"
+)
+
+func TestNewHostUpdateIncludesFirewall(t *testing.T) {
+	prev := firewallInfo
+	defer func() { firewallInfo = prev }()
+	firewallInfo = func() router.FirewallInfo {
+		return router.FirewallInfo{Backend: router.FirewallIptables, Capabilities: []string{"ingress", "egress", "nat"}}
+	}
+	data, err := json.Marshal(newHostUpdate("", models.HostMqAction(models.CheckIn)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var payload struct {
+		Action   models.HostMqAction
+		Firewall json.RawMessage `json:"firewall"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.Action != models.HostMqAction(models.CheckIn) {
+		t.Fatalf("expected checkin action, got %s", payload.Action)
+	}
+	if expected := `{"backend":"iptables","capabilities":["ingress","egress","nat"]}`; string(payload.Firewall) != expected {
+		t.Fatalf("expected firewall info %s, got %s", expected, payload.Firewall)
+	}
+}
+
+func TestNewHostUpdateServerProxyDisabled(t *testing.T) {
+	config.Netclient().ProxyEnabled = true
+	config.Netclient().ProxyListenPort = 51722
+	config.UpdateServer("enabled", config.Server{Name: "enabled"})
+	config.UpdateServer("disabled", config.Server{Name: "disabled", ProxyDisabled: true})
+	defer func() {
+		delete(config.Servers, "enabled")
+		delete(config.Servers, "disabled")
+	}()
+	if host := newHostUpdate("enabled", models.UpdateHost).Host; !host.ProxyEnabled || host.ProxyListenPort != 51722 {
+		t.Fatalf("expected the proxy to be advertised to the server, got %v %d", host.ProxyEnabled, host.ProxyListenPort)
+	}
+	if host := newHostUpdate("disabled", models.UpdateHost).Host; host.ProxyEnabled || host.ProxyListenPort != 0 {
+		t.Fatalf("expected no proxy to be advertised to the server, got %v %d", host.ProxyEnabled, host.ProxyListenPort)
+	}
+	if !config.Netclient().ProxyEnabled {
+		t.Fatal("expected the host config to be left untouched")
+	}
+}
+
+func TestRequestCheckin(t *testing.T) {
+	// requests made while one is pending are coalesced, the requester never blocks
+	requestCheckin()
+	requestCheckin()
+	<-checkinRequests
+	select {
+	case <-checkinRequests:
+		t.Fatal("expected the requests to be coalesced into one checkin")
+	default:
+	}
+}
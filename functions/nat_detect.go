@@ -0,0 +1,139 @@
+package functions
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netclient/ncutils"
+	proxy_cfg "github.com/gravitl/netclient/nmproxy/config"
+	"github.com/gravitl/netclient/nmproxy/stun"
+	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/models"
+)
+
+// defaultNatDetectInterval - interval of the nat type re-detection, when not configured
+const defaultNatDetectInterval = 30 * time.Minute
+
+// natChangeReadings - consecutive detections of a new nat type required before it is applied
+const natChangeReadings = 2
+
+// natInfoMutex - guards hostNatInfo, which is re-detected in the background
+var natInfoMutex sync.Mutex
+
+// natChangeGuard - keeps a detection flapping between two results from republishing the nat type,
+// a new nat type is applied once it is read on natChangeReadings consecutive detections
+type natChangeGuard struct {
+	pending  string
+	readings int
+}
+
+// natChangeGuard.observe - records a detection, reports if the detected nat type should replace the current one
+func (g *natChangeGuard) observe(current, detected string) bool {
+	if detected == current {
+		g.pending, g.readings = "", 0
+		return false
+	}
+	if detected != g.pending {
+		g.pending, g.readings = detected, 0
+	}
+	g.readings++
+	if g.readings < natChangeReadings {
+		logger.Log(1, "nat type", detected, "detected, waiting for it to be confirmed")
+		return false
+	}
+	g.pending, g.readings = "", 0
+	return true
+}
+
+// natDetectInterval - returns the configured interval of the nat type re-detection
+func natDetectInterval() time.Duration {
+	if minutes := config.Netclient().NatDetectInterval; minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return defaultNatDetectInterval
+}
+
+// stunServers - returns the stun servers of the first server followed by those of the others,
+// so detection goes on when the stun servers of one server are down
+func stunServers(first string, servers map[string]config.Server) []models.StunServer {
+	list := []models.StunServer{}
+	seen := make(map[string]bool)
+	for _, server := range orderServers(servers, []string{first}) {
+		for _, stunServer := range server.StunList {
+			key := fmt.Sprintf("%s:%d", stunServer.Domain, stunServer.Port)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			list = append(list, stunServer)
+		}
+	}
+	return list
+}
+
+// watchNatInfo - re-detects the nat type of the host on an interval, a confirmed change is persisted
+// and published by the checkin routine
+func watchNatInfo(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	ticker := time.NewTicker(natDetectInterval())
+	defer ticker.Stop()
+	guard := &natChangeGuard{}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if redetectNatInfo(stun.GetHostNatInfo, guard) {
+				persistNatType()
+				requestCheckin()
+			}
+		}
+	}
+}
+
+// redetectNatInfo - detects the nat type again, updating the host and the running proxy when the guard
+// confirms the change, the stun query uses a free port as the proxy holds its listen port
+func redetectNatInfo(detect natDetector, guard *natChangeGuard) (changed bool) {
+	servers := config.GetServers()
+	if len(servers) == 0 {
+		return false
+	}
+	serverMap := make(map[string]config.Server)
+	for _, name := range servers {
+		if server := config.GetServer(name); server != nil {
+			serverMap[name] = *server
+		}
+	}
+	ordered := orderServers(serverMap, config.Netclient().ServerPriority)
+	if len(ordered) == 0 {
+		return false
+	}
+	port, err := ncutils.GetFreePort(config.Netclient().ProxyListenPort + 1)
+	if err != nil {
+		logger.Log(0, "failed to find a port for nat detection: ", err.Error())
+		return false
+	}
+	info := detectNatInfo(detect, stunServers(ordered[0].Name, serverMap), config.Netclient().EndpointIP.String(),
+		port, config.Netclient().FallbackNatType)
+	natInfoMutex.Lock()
+	defer natInfoMutex.Unlock()
+	if hostNatInfo == nil || !guard.observe(hostNatInfo.NatType, info.NatType) {
+		return false
+	}
+	logger.Log(0, "nat type changed from", hostNatInfo.NatType, "to", info.NatType)
+	updated := *hostNatInfo
+	updated.NatType = info.NatType
+	updated.PublicIp = info.PublicIp
+	hostNatInfo = &updated
+	config.Netclient().Host.NatType = info.NatType
+	if proxy_cfg.GetCfg() != nil && proxy_cfg.GetCfg().IsProxyRunning() {
+		proxyInfo := proxy_cfg.GetCfg().GetHostInfo()
+		proxyInfo.NatType = info.NatType
+		proxyInfo.PublicIp = info.PublicIp
+		proxy_cfg.GetCfg().SetHostInfo(proxyInfo)
+	}
+	return true
+}
@@ -0,0 +1,51 @@
+package functions
+
+import (
+	"net"
+
+	ncmodels "github.com/gravitl/netclient/nmproxy/models"
+	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/models"
+)
+
+// defaultFallbackNatType - nat type assumed when no stun server answers, asymmetric
+// is the most conservative as it makes the proxy relay peers through turn
+var defaultFallbackNatType = models.NAT_Types.Asymmetric
+
+// natDetector - queries the stun servers for the nat info of the host
+type natDetector func(stunList []models.StunServer, currentPublicIP string, stunPort int) *ncmodels.HostInfo
+
+// fallbackNatType - returns the configured fallback nat type, or the default if unset or unknown
+func fallbackNatType(configured string) string {
+	switch configured {
+	case models.NAT_Types.Public, models.NAT_Types.Symmetric, models.NAT_Types.Asymmetric, models.NAT_Types.Double:
+		return configured
+	case "":
+	default:
+		logger.Log(0, "unknown fallback nat type", configured, "using", defaultFallbackNatType)
+	}
+	return defaultFallbackNatType
+}
+
+// detectNatInfo - detects the nat info of the host through the stun servers, when none answers
+// the fallback nat type is assumed so the host info is never left unset
+func detectNatInfo(detect natDetector, stunList []models.StunServer, currentPublicIP string, stunPort int, fallback string) *ncmodels.HostInfo {
+	info := detect(stunList, currentPublicIP, stunPort)
+	if info != nil && info.PubPort != 0 {
+		return info
+	}
+	natType := fallbackNatType(fallback)
+	logger.Log(0, "nat type detection failed, no stun server answered; assuming nat type", natType)
+	info = &ncmodels.HostInfo{
+		PublicIp: net.ParseIP(currentPublicIP),
+		// the proxy listens on all addresses as the local address of the stun connection is unknown
+		PrivIp:   net.IPv4zero,
+		PubPort:  stunPort,
+		PrivPort: stunPort,
+		NatType:  natType,
+	}
+	if info.PublicIp == nil {
+		info.PublicIp = net.IPv4zero
+	}
+	return info
+}
@@ -0,0 +1,100 @@
+package functions
+
+import (
+	"net"
+	"testing"
+
+	"github.com/gravitl/netclient/config"
+	ncmodels "github.com/gravitl/netclient/nmproxy/models"
+	"github.com/gravitl/netmaker/models"
+)
+
+func TestDetectNatInfo(t *testing.T) {
+	stunList := []models.StunServer{{Domain: "stun.example.com", Port: 3478}}
+	// stun.GetHostNatInfo returns info without a public port when no server answers
+	unreachable := func([]models.StunServer, string, int) *ncmodels.HostInfo {
+		return &ncmodels.HostInfo{NatType: models.NAT_Types.Double}
+	}
+	t.Run("all stun servers down", func(t *testing.T) {
+		info := detectNatInfo(unreachable, stunList, "203.0.113.5", 51821, "")
+		if info == nil {
+			t.Fatal("expected fallback nat info")
+		}
+		if info.NatType != models.NAT_Types.Asymmetric {
+			t.Errorf("nat type = %s, want %s", info.NatType, models.NAT_Types.Asymmetric)
+		}
+		if !info.PublicIp.Equal(net.ParseIP("203.0.113.5")) || info.PubPort != 51821 {
+			t.Errorf("unexpected endpoint %s:%d", info.PublicIp, info.PubPort)
+		}
+		if info.PrivIp == nil {
+			t.Error("private ip not set")
+		}
+	})
+	t.Run("configured fallback", func(t *testing.T) {
+		info := detectNatInfo(unreachable, nil, "", 51821, models.NAT_Types.Public)
+		if info.NatType != models.NAT_Types.Public {
+			t.Errorf("nat type = %s, want %s", info.NatType, models.NAT_Types.Public)
+		}
+		if info.PublicIp == nil {
+			t.Error("public ip not set")
+		}
+	})
+	t.Run("unknown fallback", func(t *testing.T) {
+		info := detectNatInfo(unreachable, stunList, "", 51821, "bogus")
+		if info.NatType != models.NAT_Types.Asymmetric {
+			t.Errorf("nat type = %s, want %s", info.NatType, models.NAT_Types.Asymmetric)
+		}
+	})
+	t.Run("detected", func(t *testing.T) {
+		detected := &ncmodels.HostInfo{PubPort: 40000, NatType: models.NAT_Types.Symmetric}
+		info := detectNatInfo(func([]models.StunServer, string, int) *ncmodels.HostInfo {
+			return detected
+		}, stunList, "", 51821, models.NAT_Types.Public)
+		if info != detected {
+			t.Error("detected nat info replaced by fallback")
+		}
+	})
+}
+
+func TestStunServers(t *testing.T) {
+	shared := models.StunServer{Domain: "stun.example.com", Port: 3478}
+	servers := map[string]config.Server{}
+	for name, stunList := range map[string][]models.StunServer{
+		"a.example.com": {shared, {Domain: "stun.a.example.com", Port: 3478}},
+		"b.example.com": {{Domain: "stun.b.example.com", Port: 3478}, shared},
+	} {
+		server := config.Server{}
+		server.Name = name
+		server.StunList = stunList
+		servers[name] = server
+	}
+	got := stunServers("b.example.com", servers)
+	want := []string{"stun.b.example.com", "stun.example.com", "stun.a.example.com"}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d stun servers, got %v", len(want), got)
+	}
+	for i := range want {
+		if got[i].Domain != want[i] {
+			t.Fatalf("expected the stun servers of the first server followed by the others, got %v", got)
+		}
+	}
+}
+
+func TestNatChangeGuard(t *testing.T) {
+	guard := &natChangeGuard{}
+	symmetric, asymmetric := models.NAT_Types.Symmetric, models.NAT_Types.Asymmetric
+	// a single differing reading is not applied
+	if guard.observe(symmetric, asymmetric) {
+		t.Fatal("expected the first reading of a new nat type to wait for confirmation")
+	}
+	// flapping back resets the pending change
+	if guard.observe(symmetric, symmetric) || guard.observe(symmetric, asymmetric) {
+		t.Fatal("expected a flapping detection not to be applied")
+	}
+	if !guard.observe(symmetric, asymmetric) {
+		t.Fatal("expected the nat type to change after two consecutive readings")
+	}
+	if guard.observe(asymmetric, asymmetric) {
+		t.Fatal("expected no change once the nat type is applied")
+	}
+}
@@ -0,0 +1,66 @@
+package functions
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netclient/nmproxy/router"
+)
+
+// QueryPacket - asks the running daemon how its netmaker rules would handle the packet and prints the verdict
+func QueryPacket(q router.PacketQuery) error {
+	gui, err := config.ReadGUIConfig()
+	if err != nil {
+		return fmt.Errorf("could not read daemon address, is the daemon running? %w", err)
+	}
+	params := url.Values{}
+	params.Set("src", q.Src)
+	params.Set("dst", q.Dst)
+	if q.InIface != "" {
+		params.Set("iif", q.InIface)
+	}
+	if q.OutIface != "" {
+		params.Set("oif", q.OutIface)
+	}
+	if q.Proto != "" {
+		params.Set("proto", q.Proto)
+	}
+	if q.Port != 0 {
+		params.Set("port", strconv.Itoa(q.Port))
+	}
+	if q.Established {
+		params.Set("established", "true")
+	}
+	res, err := http.Get(fmt.Sprintf("http://%s:%s/firewall/query?%s", gui.Address, gui.Port, params.Encode()))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&errResp); err == nil && errResp.Error != "" {
+			return errors.New(errResp.Error)
+		}
+		return fmt.Errorf("error making HTTP request Code: %d", res.StatusCode)
+	}
+	var verdict router.PacketVerdict
+	if err := json.NewDecoder(res.Body).Decode(&verdict); err != nil {
+		return err
+	}
+	fmt.Println("verdict:", verdict.Verdict)
+	fmt.Println("reason: ", verdict.Reason)
+	if verdict.Rule != "" {
+		fmt.Println("rule:   ", verdict.Rule)
+	}
+	if verdict.Masquerade {
+		fmt.Println("masqueraded by:", verdict.MasqRule)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package functions
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netclient/wireguard"
+)
+
+// peerMTUCheckInterval - interval between the path MTU checks of the peers
+const peerMTUCheckInterval = time.Minute * 5
+
+// watchPeerMTUs - checks the path MTU of the peers when probing is enabled, apart from the checkin
+// as probing many peers takes a while
+func watchPeerMTUs(ctx context.Context, wg *sync.WaitGroup) {
+	defer wg.Done()
+	ticker := time.NewTicker(peerMTUCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if config.Netclient().ProbePeerMTU {
+				wireguard.CheckPeerMTUs()
+			}
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package functions
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"time"
+
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netclient/ncutils"
+	proxy_cfg "github.com/gravitl/netclient/nmproxy/config"
+	"github.com/gravitl/netclient/nmproxy/wg"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// paths traffic to a peer can take
+const (
+	PeerPathDirect = "direct"
+	PeerPathProxy  = "proxy"
+	PeerPathRelay  = "relay"
+	PeerPathTurn   = "turn"
+)
+
+// handshake states of a peer
+const (
+	HandshakeNone   = "none"
+	HandshakeActive = "active"
+	// HandshakeStale - no handshake within the wireguard session lifetime
+	HandshakeStale = "stale"
+)
+
+// handshakeTimeout - wireguard rejects a session after 180s without a new handshake
+const handshakeTimeout = 180 * time.Second
+
+// PeerPing - result of a reachability test to a peer through the tunnel
+type PeerPing struct {
+	PeerKey        string    `json:"peer_key"`
+	Address        string    `json:"address"`
+	Reachable      bool      `json:"reachable"`
+	Latency        string    `json:"latency,omitempty"`
+	Error          string    `json:"error,omitempty"`
+	Path           string    `json:"path"`
+	Endpoint       string    `json:"endpoint,omitempty"`
+	HandshakeState string    `json:"handshake_state"`
+	LastHandshake  time.Time `json:"last_handshake,omitempty"`
+}
+
+// peerProber - sends a probe to the address through the tunnel and returns the round trip time
+type peerProber func(addr net.IP) (time.Duration, error)
+
+// peerPathFunc - returns the path traffic to the peer takes
+type peerPathFunc func(peerKey string) string
+
+// pingPeer - finds the peer by public key or tunnel address and probes it
+func pingPeer(target string, peers []wgtypes.Peer, probe peerProber, pathOf peerPathFunc, now time.Time) (PeerPing, error) {
+	peer, addr, err := findPeer(target, peers)
+	if err != nil {
+		return PeerPing{}, err
+	}
+	result := PeerPing{
+		PeerKey:        peer.PublicKey.String(),
+		Address:        addr.String(),
+		Path:           pathOf(peer.PublicKey.String()),
+		HandshakeState: HandshakeNone,
+		LastHandshake:  peer.LastHandshakeTime,
+	}
+	if peer.Endpoint != nil {
+		result.Endpoint = peer.Endpoint.String()
+	}
+	if !peer.LastHandshakeTime.IsZero() {
+		result.HandshakeState = HandshakeActive
+		if now.Sub(peer.LastHandshakeTime) > handshakeTimeout {
+			result.HandshakeState = HandshakeStale
+		}
+	}
+	latency, err := probe(addr)
+	if err != nil {
+		result.Error = err.Error()
+		return result, nil
+	}
+	result.Reachable = true
+	result.Latency = latency.String()
+	return result, nil
+}
+
+// findPeer - returns the peer with the public key, or whose allowed ips contain the address,
+// and the address to probe it on
+func findPeer(target string, peers []wgtypes.Peer) (wgtypes.Peer, net.IP, error) {
+	if ip := net.ParseIP(target); ip != nil {
+		for _, peer := range peers {
+			for _, allowed := range peer.AllowedIPs {
+				if allowed.Contains(ip) {
+					return peer, ip, nil
+				}
+			}
+		}
+		return wgtypes.Peer{}, nil, fmt.Errorf("no peer routes %s", target)
+	}
+	key, err := wgtypes.ParseKey(target)
+	if err != nil {
+		return wgtypes.Peer{}, nil, errors.New("peer must be a public key or an address")
+	}
+	for _, peer := range peers {
+		if peer.PublicKey != key {
+			continue
+		}
+		for _, allowed := range peer.AllowedIPs {
+			if ones, bits := allowed.Mask.Size(); ones == bits {
+				return peer, allowed.IP, nil
+			}
+		}
+		return wgtypes.Peer{}, nil, fmt.Errorf("peer %s has no tunnel address", target)
+	}
+	return wgtypes.Peer{}, nil, fmt.Errorf("peer %s not found", target)
+}
+
+// proxyPeerPath - returns the path to the peer from the proxy config
+func proxyPeerPath(peerKey string) string {
+	conn, found := proxy_cfg.GetCfg().GetPeer(peerKey)
+	if !found || !proxy_cfg.GetCfg().IsPeerProxyEnabled(conn) {
+		return PeerPathDirect
+	}
+	conn.Mutex.RLock()
+	defer conn.Mutex.RUnlock()
+	switch {
+	case conn.Config.UsingTurn:
+		return PeerPathTurn
+	case conn.IsRelayed:
+		return PeerPathRelay
+	default:
+		return PeerPathProxy
+	}
+}
+
+// icmpProbe - pings the address once and times the round trip
+func icmpProbe(addr net.IP) (time.Duration, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "windows":
+		cmd = exec.Command("ping", "-n", "1", "-w", "2000", addr.String())
+	case "darwin", "freebsd":
+		cmd = exec.Command("ping", "-c", "1", "-t", "2", addr.String())
+	default:
+		cmd = exec.Command("ping", "-c", "1", "-W", "2", addr.String())
+	}
+	start := time.Now()
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return 0, fmt.Errorf("no reply from %s: %s", addr, string(out))
+	}
+	return time.Since(start), nil
+}
+
+// localPingPeer - tests reachability of the peer from the running daemon
+func localPingPeer(target string) (PeerPing, error) {
+	peers, err := wg.GetPeers(ncutils.GetInterfaceName())
+	if err != nil {
+		return PeerPing{}, err
+	}
+	return pingPeer(target, peers, icmpProbe, proxyPeerPath, time.Now())
+}
+
+// PingPeer - asks the running daemon to test reachability of a peer and prints the result
+func PingPeer(target string) error {
+	gui, err := config.ReadGUIConfig()
+	if err != nil {
+		return fmt.Errorf("could not read daemon address, is the daemon running? %w", err)
+	}
+	params := url.Values{}
+	params.Set("peer", target)
+	res, err := http.Get(fmt.Sprintf("http://%s:%s/peer/ping?%s", gui.Address, gui.Port, params.Encode()))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&errResp); err == nil && errResp.Error != "" {
+			return errors.New(errResp.Error)
+		}
+		return fmt.Errorf("error making HTTP request Code: %d", res.StatusCode)
+	}
+	var result PeerPing
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return err
+	}
+	fmt.Println("peer:     ", result.PeerKey)
+	fmt.Println("address:  ", result.Address)
+	if result.Reachable {
+		fmt.Println("reachable: yes, latency", result.Latency)
+	} else {
+		fmt.Println("reachable: no,", result.Error)
+	}
+	fmt.Println("path:     ", result.Path)
+	if result.Endpoint != "" {
+		fmt.Println("endpoint: ", result.Endpoint)
+	}
+	handshake := result.HandshakeState
+	if !result.LastHandshake.IsZero() {
+		handshake += ", last " + result.LastHandshake.Format(time.RFC3339)
+	}
+	fmt.Println("handshake:", handshake)
+	return nil
+}
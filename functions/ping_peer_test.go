@@ -0,0 +1,67 @@
+package functions
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestPingPeer(t *testing.T) {
+	reachableKey, _ := wgtypes.GeneratePrivateKey()
+	unreachableKey, _ := wgtypes.GeneratePrivateKey()
+	now := time.Now()
+	peers := []wgtypes.Peer{
+		{
+			PublicKey:         reachableKey.PublicKey(),
+			Endpoint:          &net.UDPAddr{IP: net.ParseIP("203.0.113.5"), Port: 51821},
+			LastHandshakeTime: now.Add(-time.Minute),
+			AllowedIPs:        []net.IPNet{{IP: net.ParseIP("10.0.0.5"), Mask: net.CIDRMask(32, 32)}},
+		},
+		{
+			PublicKey:         unreachableKey.PublicKey(),
+			LastHandshakeTime: now.Add(-10 * time.Minute),
+			AllowedIPs: []net.IPNet{
+				{IP: net.ParseIP("10.0.0.6"), Mask: net.CIDRMask(32, 32)},
+				{IP: net.ParseIP("192.168.10.0"), Mask: net.CIDRMask(24, 32)},
+			},
+		},
+	}
+	probe := func(addr net.IP) (time.Duration, error) {
+		if addr.Equal(net.ParseIP("10.0.0.5")) {
+			return 20 * time.Millisecond, nil
+		}
+		return 0, errors.New("timeout")
+	}
+	pathOf := func(key string) string {
+		if key == reachableKey.PublicKey().String() {
+			return PeerPathProxy
+		}
+		return PeerPathDirect
+	}
+
+	result, err := pingPeer(reachableKey.PublicKey().String(), peers, probe, pathOf, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Reachable || result.Latency != "20ms" || result.Path != PeerPathProxy ||
+		result.HandshakeState != HandshakeActive || result.Address != "10.0.0.5" {
+		t.Fatalf("unexpected result for reachable peer %+v", result)
+	}
+
+	// an address in the egress range of the peer selects it
+	result, err = pingPeer("192.168.10.20", peers, probe, pathOf, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Reachable || result.Error == "" || result.PeerKey != unreachableKey.PublicKey().String() ||
+		result.HandshakeState != HandshakeStale || result.Path != PeerPathDirect {
+		t.Fatalf("unexpected result for unreachable peer %+v", result)
+	}
+
+	if _, err := pingPeer("10.1.0.1", peers, probe, pathOf, now); err == nil {
+		t.Fatal("expected error for unknown address")
+	}
+}
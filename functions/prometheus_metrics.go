@@ -0,0 +1,111 @@
+package functions
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netclient/ncutils"
+	proxy_cfg "github.com/gravitl/netclient/nmproxy/config"
+	"github.com/gravitl/netclient/nmproxy/wg"
+	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/metrics"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// prometheusContentType - content type of the prometheus text exposition format
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// peerMetricSample - the metrics of a peer of a server
+type peerMetricSample struct {
+	Server        string
+	Peer          string
+	SentBytes     int64
+	ReceivedBytes int64
+	LastHandshake time.Time
+}
+
+// prometheusMetrics - serves the peer metrics in prometheus text format
+func prometheusMetrics(c *gin.Context) {
+	peers, err := wg.GetPeers(ncutils.GetInterfaceName())
+	if err != nil {
+		logger.Log(1, "failed to get peers for metrics", err.Error())
+	}
+	var buf bytes.Buffer
+	writePrometheusMetrics(&buf, collectPeerMetrics(config.GetServers(), peers), time.Now())
+	writeProxyConnMetrics(&buf, proxy_cfg.GetCfg().GetProxyConnCount(), config.Netclient().MaxProxyConns)
+	c.Data(http.StatusOK, prometheusContentType, buf.Bytes())
+}
+
+// collectPeerMetrics - returns the metrics collected for the peers of each server, with the last handshake
+// of the peer on the interface
+func collectPeerMetrics(servers []string, peers []wgtypes.Peer) []peerMetricSample {
+	handshakes := make(map[string]time.Time, len(peers))
+	for _, peer := range peers {
+		handshakes[peer.PublicKey.String()] = peer.LastHandshakeTime
+	}
+	samples := []peerMetricSample{}
+	for _, server := range servers {
+		for key := range metrics.GetMetricByServer(server) {
+			metric := metrics.GetMetric(server, key)
+			samples = append(samples, peerMetricSample{
+				Server:        server,
+				Peer:          key,
+				SentBytes:     metric.TrafficSent,
+				ReceivedBytes: metric.TrafficRecieved,
+				LastHandshake: handshakes[key],
+			})
+		}
+	}
+	return samples
+}
+
+// writePrometheusMetrics - renders the samples in prometheus text format, the handshake age of a peer
+// without a handshake is left out
+func writePrometheusMetrics(w io.Writer, samples []peerMetricSample, now time.Time) {
+	sort.Slice(samples, func(i, j int) bool {
+		if samples[i].Server != samples[j].Server {
+			return samples[i].Server < samples[j].Server
+		}
+		return samples[i].Peer < samples[j].Peer
+	})
+	fmt.Fprintln(w, "# HELP netclient_peer_traffic_sent_bytes Bytes sent to the peer.")
+	fmt.Fprintln(w, "# TYPE netclient_peer_traffic_sent_bytes counter")
+	for _, sample := range samples {
+		fmt.Fprintf(w, "netclient_peer_traffic_sent_bytes{%s} %d\n", promLabels(sample), sample.SentBytes)
+	}
+	fmt.Fprintln(w, "# HELP netclient_peer_traffic_received_bytes Bytes received from the peer.")
+	fmt.Fprintln(w, "# TYPE netclient_peer_traffic_received_bytes counter")
+	for _, sample := range samples {
+		fmt.Fprintf(w, "netclient_peer_traffic_received_bytes{%s} %d\n", promLabels(sample), sample.ReceivedBytes)
+	}
+	fmt.Fprintln(w, "# HELP netclient_peer_handshake_age_seconds Seconds since the last handshake with the peer.")
+	fmt.Fprintln(w, "# TYPE netclient_peer_handshake_age_seconds gauge")
+	for _, sample := range samples {
+		if sample.LastHandshake.IsZero() {
+			continue
+		}
+		fmt.Fprintf(w, "netclient_peer_handshake_age_seconds{%s} %d\n", promLabels(sample),
+			int64(now.Sub(sample.LastHandshake).Seconds()))
+	}
+}
+
+// writeProxyConnMetrics - renders the number of proxy connections and their limit, a limit of 0 is unlimited
+func writeProxyConnMetrics(w io.Writer, conns, limit int) {
+	fmt.Fprintln(w, "# HELP netclient_proxy_connections Active proxy connections.")
+	fmt.Fprintln(w, "# TYPE netclient_proxy_connections gauge")
+	fmt.Fprintf(w, "netclient_proxy_connections %d\n", conns)
+	fmt.Fprintln(w, "# HELP netclient_proxy_connections_limit Limit on the active proxy connections, 0 when unlimited.")
+	fmt.Fprintln(w, "# TYPE netclient_proxy_connections_limit gauge")
+	fmt.Fprintf(w, "netclient_proxy_connections_limit %d\n", limit)
+}
+
+// promLabels - returns the labels of a sample, %q escapes the values as the format requires
+func promLabels(sample peerMetricSample) string {
+	return fmt.Sprintf("server=%q,peer=%q", sample.Server, sample.Peer)
+}
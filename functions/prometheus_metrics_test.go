@@ -0,0 +1,65 @@
+package functions
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/metrics"
+	"github.com/gravitl/netmaker/models"
+	"github.com/matryer/is"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestWritePrometheusMetrics(t *testing.T) {
+	is := is.New(t)
+	now := time.Now()
+	key1, err := wgtypes.ParseKey("mFxRAaE5dsuVLbkGzUpRvmSfAmE0oQ5SMSpiVaWgz1w=")
+	is.NoErr(err)
+	key2, err := wgtypes.ParseKey("aGVsbG8gd29ybGQgaGVsbG8gd29ybGQgaGVsbG8gd28=")
+	is.NoErr(err)
+	metrics.UpdateMetric("prom.test", key1.String(), &models.ProxyMetric{TrafficSent: 100, TrafficRecieved: 200})
+	metrics.UpdateMetric("prom.test", key2.String(), &models.ProxyMetric{TrafficSent: 5})
+	defer metrics.ResetMetricsForPeer("prom.test", key1.String())
+	defer metrics.ResetMetricsForPeer("prom.test", key2.String())
+	// a peer of another server is only reported for that server
+	key3, err := wgtypes.ParseKey("b3RoZXIgc2VydmVyIHBlZXIga2V5IGZvciB0ZXN0cyE=")
+	is.NoErr(err)
+	metrics.UpdateMetric("prom.other", key3.String(), &models.ProxyMetric{TrafficSent: 7})
+	defer metrics.ResetMetricsForPeer("prom.other", key3.String())
+
+	samples := collectPeerMetrics([]string{"prom.test"}, []wgtypes.Peer{
+		{PublicKey: key1, LastHandshakeTime: now.Add(-90 * time.Second)},
+		{PublicKey: key2},
+		{PublicKey: key3},
+	})
+	var buf bytes.Buffer
+	writePrometheusMetrics(&buf, samples, now)
+	want := `# HELP netclient_peer_traffic_sent_bytes Bytes sent to the peer.
+# TYPE netclient_peer_traffic_sent_bytes counter
+netclient_peer_traffic_sent_bytes{server="prom.test",peer="aGVsbG8gd29ybGQgaGVsbG8gd29ybGQgaGVsbG8gd28="} 5
+netclient_peer_traffic_sent_bytes{server="prom.test",peer="mFxRAaE5dsuVLbkGzUpRvmSfAmE0oQ5SMSpiVaWgz1w="} 100
+# HELP netclient_peer_traffic_received_bytes Bytes received from the peer.
+# TYPE netclient_peer_traffic_received_bytes counter
+netclient_peer_traffic_received_bytes{server="prom.test",peer="aGVsbG8gd29ybGQgaGVsbG8gd29ybGQgaGVsbG8gd28="} 0
+netclient_peer_traffic_received_bytes{server="prom.test",peer="mFxRAaE5dsuVLbkGzUpRvmSfAmE0oQ5SMSpiVaWgz1w="} 200
+# HELP netclient_peer_handshake_age_seconds Seconds since the last handshake with the peer.
+# TYPE netclient_peer_handshake_age_seconds gauge
+netclient_peer_handshake_age_seconds{server="prom.test",peer="mFxRAaE5dsuVLbkGzUpRvmSfAmE0oQ5SMSpiVaWgz1w="} 90
+`
+	is.Equal(buf.String(), want)
+}
+
+func TestWriteProxyConnMetrics(t *testing.T) {
+	is := is.New(t)
+	var buf bytes.Buffer
+	writeProxyConnMetrics(&buf, 3, 100)
+	want := `# HELP netclient_proxy_connections Active proxy connections.
+# TYPE netclient_proxy_connections gauge
+netclient_proxy_connections 3
+# HELP netclient_proxy_connections_limit Limit on the active proxy connections, 0 when unlimited.
+# TYPE netclient_proxy_connections_limit gauge
+netclient_proxy_connections_limit 100
+`
+	is.Equal(buf.String(), want)
+}
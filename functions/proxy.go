@@ -1,10 +1,13 @@
 package functions
 
 import (
+	"errors"
 	"fmt"
+	"strconv"
 
 	"github.com/gravitl/netclient/config"
 	"github.com/gravitl/netclient/daemon"
+	"github.com/gravitl/netclient/ncutils"
 	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/models"
 )
@@ -40,3 +43,67 @@ func ChangeProxyStatus(status bool) error {
 	}
 	return nil
 }
+
+// ChangeServerProxyStatus - enables or disables proxying of the peers of a single server
+func ChangeServerProxyStatus(serverName string, status bool) error {
+	server := config.GetServer(serverName)
+	if server == nil {
+		return fmt.Errorf("server %s not found", serverName)
+	}
+	logger.Log(1, fmt.Sprint("changing proxy status of server ", serverName, " to ", status))
+	server.ProxyDisabled = !status
+	if err := config.SaveServer(serverName, *server); err != nil {
+		return err
+	}
+	if status {
+		fmt.Println("proxy is switched on for server", serverName)
+		if !config.Netclient().ProxyEnabled {
+			fmt.Println("proxy is switched off for the host, switch it on to proxy the peers of", serverName)
+		}
+	} else {
+		fmt.Println("proxy is switched off for server", serverName)
+	}
+	if err := daemon.Restart(); err != nil {
+		logger.Log(0, "failed to restart daemon: ", err.Error())
+	}
+	return nil
+}
+
+// setProxyLocalPort - validates the configured local proxy port on startup and persists the port in use
+func setProxyLocalPort() {
+	current := config.Netclient().ProxyLocalPort
+	port, err := validateProxyLocalPort(current, config.Netclient().ProxyListenPort)
+	if err != nil {
+		logger.Log(0, "failed to find a free local proxy port", err.Error())
+		return
+	}
+	if port == current {
+		return
+	}
+	if current != 0 {
+		logger.Log(0, "proxy local port", strconv.Itoa(current), "is unavailable, using", strconv.Itoa(port))
+	}
+	config.Netclient().ProxyLocalPort = port
+	if err := config.WriteNetclientConfig(); err != nil {
+		logger.Log(0, "failed to persist proxy local port", err.Error())
+	}
+}
+
+// validateProxyLocalPort - returns the port if it is free and does not clash with the proxy listen port,
+// otherwise the next available port
+func validateProxyLocalPort(port, proxyListenPort int) (int, error) {
+	if port == 0 {
+		port = models.NmProxyPort
+	}
+	for port <= 65535 {
+		free, err := ncutils.GetFreePort(port)
+		if err != nil {
+			return 0, err
+		}
+		if free != proxyListenPort {
+			return free, nil
+		}
+		port = free + 1
+	}
+	return 0, errors.New("no free ports")
+}
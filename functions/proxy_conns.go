@@ -0,0 +1,181 @@
+package functions
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netclient/ncutils"
+	proxy_cfg "github.com/gravitl/netclient/nmproxy/config"
+	"github.com/gravitl/netclient/nmproxy/manager"
+	"github.com/gravitl/netclient/nmproxy/models"
+	"github.com/gravitl/netclient/nmproxy/wg"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// errProxyNotRunning - returned when proxy connections are requested while the proxy is off
+var errProxyNotRunning = errors.New("proxy is not running")
+
+// proxyLoopTimeout - how long a list or reset waits for the proxy manager loop to pick it up
+const proxyLoopTimeout = 5 * time.Second
+
+// ProxyConn - state of the proxy connection to a peer
+type ProxyConn struct {
+	PeerKey       string    `json:"peer_key"`
+	Mode          string    `json:"mode"`
+	Endpoint      string    `json:"endpoint,omitempty"`
+	LocalAddr     string    `json:"local_addr,omitempty"`
+	RxBytes       int64     `json:"rx_bytes"`
+	TxBytes       int64     `json:"tx_bytes"`
+	LastHandshake time.Time `json:"last_handshake,omitempty"`
+}
+
+// proxyConns - lists the proxy connections sorted by peer key, with the traffic stats of the wireguard peers
+func proxyConns(conns models.PeerConnMap, wgPeers []wgtypes.Peer) []ProxyConn {
+	stats := make(map[string]wgtypes.Peer, len(wgPeers))
+	for _, peer := range wgPeers {
+		stats[peer.PublicKey.String()] = peer
+	}
+	list := []ProxyConn{}
+	for key, conn := range conns {
+		conn.Mutex.RLock()
+		pc := ProxyConn{
+			PeerKey: key,
+			Mode:    proxy_cfg.ConnMode(conn.IsRelayed, conn.Config.UsingTurn),
+		}
+		endpoint := conn.Config.RemoteConnAddr
+		if conn.IsRelayed {
+			endpoint = conn.RelayedEndpoint
+		}
+		if endpoint != nil {
+			pc.Endpoint = endpoint.String()
+		}
+		if conn.Config.LocalConnAddr != nil {
+			pc.LocalAddr = conn.Config.LocalConnAddr.String()
+		}
+		conn.Mutex.RUnlock()
+		if peer, ok := stats[key]; ok {
+			pc.RxBytes = peer.ReceiveBytes
+			pc.TxBytes = peer.TransmitBytes
+			pc.LastHandshake = peer.LastHandshakeTime
+		}
+		list = append(list, pc)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].PeerKey < list[j].PeerKey })
+	return list
+}
+
+// resetProxyConn - resets the proxy connection of the peer, leaving the connections to other peers untouched
+func resetProxyConn(target string, conns models.PeerConnMap) error {
+	key, err := wgtypes.ParseKey(target)
+	if err != nil {
+		return errors.New("peer must be a public key")
+	}
+	conn, ok := conns[key.String()]
+	if !ok {
+		return fmt.Errorf("no proxy connection to peer %s", target)
+	}
+	conn.Mutex.Lock()
+	defer conn.Mutex.Unlock()
+	conn.ResetConn()
+	return nil
+}
+
+// localProxyConns - lists the proxy connections of the running daemon, read on the proxy manager loop
+func localProxyConns() ([]ProxyConn, error) {
+	if !proxy_cfg.GetCfg().IsProxyRunning() {
+		return nil, errProxyNotRunning
+	}
+	peers, err := wg.GetPeers(ncutils.GetInterfaceName())
+	if err != nil {
+		return nil, err
+	}
+	var list []ProxyConn
+	if err := manager.RunOnLoop(func() {
+		list = proxyConns(proxy_cfg.GetCfg().GetAllProxyPeers(), peers)
+	}, proxyLoopTimeout); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// localResetProxyConn - resets the proxy connection of the peer in the running daemon, on the proxy manager loop
+func localResetProxyConn(target string) error {
+	if !proxy_cfg.GetCfg().IsProxyRunning() {
+		return errProxyNotRunning
+	}
+	var err error
+	if loopErr := manager.RunOnLoop(func() {
+		err = resetProxyConn(target, proxy_cfg.GetCfg().GetAllProxyPeers())
+	}, proxyLoopTimeout); loopErr != nil {
+		return loopErr
+	}
+	return err
+}
+
+// daemonRequest - sends a request to the http server of the running daemon and decodes the response into result
+func daemonRequest(method, path string, params url.Values, result interface{}) error {
+	gui, err := config.ReadGUIConfig()
+	if err != nil {
+		return fmt.Errorf("could not read daemon address, is the daemon running? %w", err)
+	}
+	req, err := http.NewRequest(method, fmt.Sprintf("http://%s:%s%s?%s", gui.Address, gui.Port, path, params.Encode()), nil)
+	if err != nil {
+		return err
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		var errResp struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(res.Body).Decode(&errResp); err == nil && errResp.Error != "" {
+			return errors.New(errResp.Error)
+		}
+		return fmt.Errorf("error making HTTP request Code: %d", res.StatusCode)
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(result)
+}
+
+// ListProxyConns - asks the running daemon for its proxy connections and prints them
+func ListProxyConns() error {
+	var conns []ProxyConn
+	if err := daemonRequest(http.MethodGet, "/proxy/conns", url.Values{}, &conns); err != nil {
+		return err
+	}
+	if len(conns) == 0 {
+		fmt.Println("no active proxy connections")
+		return nil
+	}
+	for _, conn := range conns {
+		handshake := HandshakeNone
+		if !conn.LastHandshake.IsZero() {
+			handshake = conn.LastHandshake.Format(time.RFC3339)
+		}
+		fmt.Printf("%s mode: %s endpoint: %s local: %s rx: %d tx: %d handshake: %s\n",
+			conn.PeerKey, conn.Mode, conn.Endpoint, conn.LocalAddr, conn.RxBytes, conn.TxBytes, handshake)
+	}
+	return nil
+}
+
+// ResetProxyConn - asks the running daemon to reset the proxy connection of a peer
+func ResetProxyConn(target string) error {
+	params := url.Values{}
+	params.Set("peer", target)
+	if err := daemonRequest(http.MethodPost, "/proxy/reset", params, nil); err != nil {
+		return err
+	}
+	fmt.Println("reset proxy connection to", target)
+	return nil
+}
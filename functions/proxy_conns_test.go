@@ -0,0 +1,68 @@
+package functions
+
+import (
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/gravitl/netclient/nmproxy/models"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestResetProxyConn(t *testing.T) {
+	stuckKey, _ := wgtypes.GeneratePrivateKey()
+	otherKey, _ := wgtypes.GeneratePrivateKey()
+	resets := map[string]int{}
+	conns := models.PeerConnMap{}
+	for _, key := range []wgtypes.Key{stuckKey.PublicKey(), otherKey.PublicKey()} {
+		key := key
+		conns[key.String()] = &models.Conn{
+			Key:       key,
+			Mutex:     &sync.RWMutex{},
+			ResetConn: func() { resets[key.String()]++ },
+		}
+	}
+
+	if err := resetProxyConn(stuckKey.PublicKey().String(), conns); err != nil {
+		t.Fatal(err)
+	}
+	if resets[stuckKey.PublicKey().String()] != 1 || resets[otherKey.PublicKey().String()] != 0 {
+		t.Fatalf("expected only the named peer to be reset, got %v", resets)
+	}
+
+	unknownKey, _ := wgtypes.GeneratePrivateKey()
+	if err := resetProxyConn(unknownKey.PublicKey().String(), conns); err == nil {
+		t.Fatal("expected error for a peer without a proxy connection")
+	}
+	if err := resetProxyConn("10.0.0.5", conns); err == nil {
+		t.Fatal("expected error for a target that is not a public key")
+	}
+	if len(resets) != 1 {
+		t.Fatalf("expected failed resets to leave the connections untouched, got %v", resets)
+	}
+}
+
+func TestProxyConns(t *testing.T) {
+	relayedKey, _ := wgtypes.GeneratePrivateKey()
+	relayed := relayedKey.PublicKey()
+	conns := models.PeerConnMap{
+		relayed.String(): {
+			Key:             relayed,
+			IsRelayed:       true,
+			RelayedEndpoint: &net.UDPAddr{IP: net.ParseIP("203.0.113.9"), Port: 51722},
+			Mutex:           &sync.RWMutex{},
+			Config: models.Proxy{
+				RemoteConnAddr: &net.UDPAddr{IP: net.ParseIP("203.0.113.5"), Port: 51722},
+			},
+		},
+	}
+	peers := []wgtypes.Peer{{PublicKey: relayed, ReceiveBytes: 10, TransmitBytes: 20}}
+	list := proxyConns(conns, peers)
+	if len(list) != 1 {
+		t.Fatalf("expected one connection, got %v", list)
+	}
+	if list[0].Mode != models.ConnModeRelay || list[0].Endpoint != "203.0.113.9:51722" ||
+		list[0].RxBytes != 10 || list[0].TxBytes != 20 {
+		t.Fatalf("unexpected connection %+v", list[0])
+	}
+}
@@ -0,0 +1,32 @@
+package functions
+
+import (
+	"net"
+	"testing"
+
+	"github.com/gravitl/netclient/ncutils"
+)
+
+func TestValidateProxyLocalPort(t *testing.T) {
+	port, err := ncutils.GetFreePort(41000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := validateProxyLocalPort(port, 0)
+	if err != nil || got != port {
+		t.Fatalf("expected free port %d to be kept, got %d (%v)", port, got, err)
+	}
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{Port: port})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	got, err = validateProxyLocalPort(port, 0)
+	if err != nil || got == port {
+		t.Fatalf("expected conflict on port %d to be detected, got %d (%v)", port, got, err)
+	}
+	got, _ = validateProxyLocalPort(port+1, port+1)
+	if got == port+1 {
+		t.Fatalf("expected proxy listen port %d to be skipped", port+1)
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"strconv"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/devilcove/httpclient"
 	"github.com/gravitl/netclient/auth"
@@ -15,32 +17,56 @@ import (
 	"github.com/gravitl/netmaker/models"
 )
 
+// policies for nodes pulled from the server that can't be converted
+const (
+	// MalformedNodeSkip - keep the current config of the network and continue the pull
+	MalformedNodeSkip = "skip"
+	// MalformedNodeAbort - fail the pull without updating any node
+	MalformedNodeAbort = "abort"
+)
+
+const (
+	// defaultPullAttempts - attempts to pull the host config from a server, when not configured
+	defaultPullAttempts = 3
+	// pullBackoffMin - delay before the first retry of a failed pull
+	pullBackoffMin = time.Second
+	// pullBackoffMax - maximum delay between retries of a failed pull
+	pullBackoffMax = 10 * time.Second
+)
+
 // Pull - pulls the latest config from the server, if manual it will overwrite
 func Pull() error {
+	results, err := PullAll()
+	if err != nil {
+		return err
+	}
+	return pullFailures(results)
+}
 
+// PullAll - pulls the latest config of every network from the servers of the host, a failing server or network
+// doesn't stop the pull of the others, the peers are applied and the daemon restarted once at the end,
+// returns the outcome of the pull by network
+func PullAll() (map[string]error, error) {
+	results := make(map[string]error)
+	nodesUpdated := false
 	currentServers := config.GetServers()
 	for i := range currentServers {
 		serverName := currentServers[i]
 		server := config.GetServer(serverName)
-		token, err := auth.Authenticate(server, config.Netclient())
+		nodes := config.GetNodesByServer(serverName)
+		pullResponse, _, err := pullServer(server)
 		if err != nil {
-			return err
-		}
-		endpoint := httpclient.JSONEndpoint[models.HostPull, models.ErrorResponse]{
-			URL:           "https://" + server.API,
-			Route:         "/api/v1/host",
-			Method:        http.MethodGet,
-			Authorization: "Bearer " + token,
-			Response:      models.HostPull{},
-			ErrorResponse: models.ErrorResponse{},
-		}
-		pullResponse, errData, err := endpoint.GetJSON(models.HostPull{}, models.ErrorResponse{})
-		if err != nil {
-			if errors.Is(err, httpclient.ErrStatus) {
-				logger.Log(0, "error pulling server", serverName, strconv.Itoa(errData.Code), errData.Message)
+			logger.Log(0, "error pulling server", serverName, err.Error())
+			for _, node := range nodes {
+				results[node.Network] = err
 			}
 			continue
 		}
+		applied, err := applyServerPull(pullResponse, nodes, results, config.Netclient().MalformedNodePolicy)
+		if err != nil {
+			return results, err
+		}
+		nodesUpdated = nodesUpdated || applied
 		_ = config.UpdateHostPeers(server.Server, pullResponse.Peers)
 		pullResponse.ServerConfig.MQPassword = server.MQPassword // pwd can't change currently
 		config.UpdateServerConfig(&pullResponse.ServerConfig)
@@ -51,8 +77,162 @@ func Pull() error {
 	if internetGateway != nil && err != nil {
 		config.Netclient().InternetGateway = *internetGateway
 	}
+	if nodesUpdated {
+		_ = config.WriteNodeConfig()
+	}
 	_ = config.WriteServerConfig()
 	_ = config.WriteNetclientConfig()
 	logger.Log(3, "restarting daemon")
-	return daemon.Restart()
+	return results, daemon.Restart()
+}
+
+// pullFailures - returns an error naming the networks that failed to pull, nil if all of them succeeded
+func pullFailures(results map[string]error) error {
+	failed := []string{}
+	for network, err := range results {
+		if err != nil {
+			failed = append(failed, network)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	sort.Strings(failed)
+	return fmt.Errorf("failed to pull network(s): %s", strings.Join(failed, ", "))
+}
+
+// errPullAuth - the host could not authenticate with the server
+var errPullAuth = errors.New("failed to authenticate")
+
+// hostPull - the host pull response, with the nodes of the host for servers that send them along
+type hostPull struct {
+	models.HostPull
+	Nodes []models.Node `json:"nodes,omitempty"`
+}
+
+// errNodeNotOnServer - the pulled host no longer lists the node of the network
+var errNodeNotOnServer = errors.New("node is no longer registered on the server")
+
+// pullServer - pulls the host config from the server with the cached api token of the server,
+// the token is refreshed once if the server rejects it
+func pullServer(server *config.Server) (hostPull, string, error) {
+	token, err := auth.GetToken(server, config.Netclient())
+	if err != nil {
+		return hostPull{}, "", classifyConnError(connTargetAPI, server.API, fmt.Errorf("%w: %v", errPullAuth, err))
+	}
+	attempts := pullAttempts()
+	pullResponse, errData, err := fetchHostPull(server, token, attempts, newMQBackoff(pullBackoffMin, pullBackoffMax))
+	if errors.Is(err, httpclient.ErrStatus) && errData.Code == http.StatusUnauthorized {
+		auth.InvalidateToken(server.Name)
+		token, err = auth.GetToken(server, config.Netclient())
+		if err != nil {
+			return hostPull{}, "", classifyConnError(connTargetAPI, server.API, fmt.Errorf("%w: %v", errPullAuth, err))
+		}
+		pullResponse, errData, err = fetchHostPull(server, token, attempts, newMQBackoff(pullBackoffMin, pullBackoffMax))
+	}
+	if err == nil {
+		return pullResponse, token, nil
+	}
+	if errors.Is(err, httpclient.ErrStatus) {
+		return pullResponse, "", statusConnError(server.API, errData.Code, errData.Message)
+	}
+	return pullResponse, "", classifyConnError(connTargetAPI, server.API, err)
+}
+
+// pullAttempts - returns the attempts to pull the host config from a server
+func pullAttempts() int {
+	if attempts := config.Netclient().PullAttempts; attempts > 0 {
+		return attempts
+	}
+	return defaultPullAttempts
+}
+
+// fetchHostPull - fetches the host config from the server, network errors and 5xx responses are retried
+// with backoff up to attempts times, other responses are returned at once
+func fetchHostPull(server *config.Server, token string, attempts int, backoff *mqBackoff) (hostPull, models.ErrorResponse, error) {
+	endpoint := httpclient.JSONEndpoint[hostPull, models.ErrorResponse]{
+		URL:           "https://" + server.API,
+		Route:         "/api/v1/host",
+		Method:        http.MethodGet,
+		Authorization: "Bearer " + token,
+		Response:      hostPull{},
+		ErrorResponse: models.ErrorResponse{},
+	}
+	for attempt := 1; ; attempt++ {
+		pullResponse, errData, err := endpoint.GetJSON(hostPull{}, models.ErrorResponse{})
+		if err == nil || attempt >= attempts {
+			return pullResponse, errData, err
+		}
+		if errors.Is(err, httpclient.ErrStatus) && errData.Code < http.StatusInternalServerError {
+			return pullResponse, errData, err
+		}
+		delay := backoff.next()
+		logger.Log(1, "pull from", server.API, "failed, retrying in", delay.String(), err.Error())
+		time.Sleep(delay)
+	}
+}
+
+// missingNodes - returns the nodes the pulled host no longer lists
+func missingNodes(host models.Host, nodes []config.Node) []config.Node {
+	listed := make(map[string]bool, len(host.Nodes))
+	for _, id := range host.Nodes {
+		listed[id] = true
+	}
+	missing := []config.Node{}
+	for _, node := range nodes {
+		if !listed[node.ID.String()] {
+			missing = append(missing, node)
+		}
+	}
+	return missing
+}
+
+// applyServerPull - applies the nodes of the pull response of a server, recording the outcome of its networks
+// in results: nodes the server sent are validated and applied with applyPulledNodes, nodes the pulled host
+// no longer lists fail; returns whether any node was updated, and an error if the pull is aborted
+func applyServerPull(pullResponse hostPull, nodes []config.Node, results map[string]error, policy string) (bool, error) {
+	nodeGets := []models.NodeGet{}
+	for _, node := range pullResponse.Nodes {
+		nodeGets = append(nodeGets, models.NodeGet{Node: node, Host: pullResponse.Host, ServerConfig: pullResponse.ServerConfig})
+	}
+	skipped, err := applyPulledNodes(nodeGets, policy)
+	if err != nil {
+		return false, err
+	}
+	for _, node := range nodes {
+		results[node.Network] = skipped[node.Network]
+	}
+	for _, node := range missingNodes(pullResponse.Host, nodes) {
+		logger.Log(0, "network", node.Network, errNodeNotOnServer.Error())
+		results[node.Network] = errNodeNotOnServer
+	}
+	return len(nodeGets) > len(skipped), nil
+}
+
+// applyPulledNodes - converts the pulled nodes and updates the node config with them,
+// malformed nodes are never applied: with the abort policy the pull fails before any node
+// is updated, otherwise (skip) the current config of that network is kept,
+// a node conflicting with the current node of its network is handled by the node conflict policy,
+// returns the conversion errors of the skipped networks
+func applyPulledNodes(nodeGets []models.NodeGet, policy string) (map[string]error, error) {
+	nodes := []*config.Node{}
+	skipped := make(map[string]error)
+	for i := range nodeGets {
+		node, err := config.ConvertNode(&nodeGets[i])
+		if err != nil {
+			if policy == MalformedNodeAbort {
+				return nil, fmt.Errorf("pull aborted: %w", err)
+			}
+			logger.Log(0, "skipping update of network", nodeGets[i].Node.Network, err.Error())
+			skipped[nodeGets[i].Node.Network] = err
+			continue
+		}
+		nodes = append(nodes, node)
+	}
+	for _, node := range nodes {
+		if err := config.UpdateNodeMapResolved(node.Network, *node, config.Netclient().NodeConflictPolicy); err != nil {
+			logger.Log(0, "network", node.Network, err.Error())
+		}
+	}
+	return skipped, nil
 }
@@ -0,0 +1,200 @@
+package functions
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/devilcove/httpclient"
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netmaker/models"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// NodeChange - a setting of the node of a network a pull would change
+type NodeChange struct {
+	Network string
+	Field   string
+	Old     string
+	New     string
+}
+
+// PeerChange - a peer whose endpoint or allowed ips a pull would change
+type PeerChange struct {
+	PublicKey   string
+	OldEndpoint string
+	NewEndpoint string
+	AddedIPs    []string
+	RemovedIPs  []string
+}
+
+// PullChanges - what a pull from a server would change on the host
+type PullChanges struct {
+	Server       string
+	Nodes        []NodeChange
+	AddedPeers   []string
+	RemovedPeers []string
+	ChangedPeers []PeerChange
+	Errors       []string
+}
+
+// PullChanges.Empty - true if the pull would change nothing
+func (d *PullChanges) Empty() bool {
+	return len(d.Nodes) == 0 && len(d.AddedPeers) == 0 && len(d.RemovedPeers) == 0 && len(d.ChangedPeers) == 0
+}
+
+// PullDiff - fetches the latest config from the servers and compares it with the current config,
+// without writing config, changing the interface or restarting the daemon
+func PullDiff() []PullChanges {
+	diffs := []PullChanges{}
+	for _, serverName := range config.GetServers() {
+		server := config.GetServer(serverName)
+		diff := PullChanges{Server: serverName}
+		pullResponse, token, err := pullServer(server)
+		if err != nil {
+			diff.Errors = append(diff.Errors, err.Error())
+			diffs = append(diffs, diff)
+			continue
+		}
+		for _, node := range config.GetNodesByServer(serverName) {
+			nodeGet, err := getServerNode(server, token, node)
+			if err != nil {
+				diff.Errors = append(diff.Errors, fmt.Sprintf("network %s: %s", node.Network, err.Error()))
+				continue
+			}
+			pulled, err := config.ConvertNode(&nodeGet)
+			if err != nil {
+				diff.Errors = append(diff.Errors, fmt.Sprintf("network %s: %s", node.Network, err.Error()))
+				continue
+			}
+			diff.Nodes = append(diff.Nodes, diffNodes(node, *pulled)...)
+		}
+		diff.AddedPeers, diff.RemovedPeers, diff.ChangedPeers = diffPeers(config.Netclient().HostPeers[serverName], pullResponse.Peers)
+		diffs = append(diffs, diff)
+	}
+	return diffs
+}
+
+// getServerNode - fetches the node of a network from the server
+func getServerNode(server *config.Server, token string, node config.Node) (models.NodeGet, error) {
+	endpoint := httpclient.JSONEndpoint[models.NodeGet, models.ErrorResponse]{
+		URL:           "https://" + server.API,
+		Route:         "/api/nodes/" + node.Network + "/" + node.ID.String(),
+		Method:        http.MethodGet,
+		Authorization: "Bearer " + token,
+		Response:      models.NodeGet{},
+		ErrorResponse: models.ErrorResponse{},
+	}
+	nodeGet, errData, err := endpoint.GetJSON(models.NodeGet{}, models.ErrorResponse{})
+	if err != nil && errors.Is(err, httpclient.ErrStatus) {
+		return nodeGet, statusConnError(server.API, errData.Code, errData.Message)
+	}
+	return nodeGet, classifyConnError(connTargetAPI, server.API, err)
+}
+
+// nodeDiffFields - the settings of a node compared by a pull diff
+var nodeDiffFields = []struct {
+	name  string
+	value func(config.Node) string
+}{
+	{"address", func(n config.Node) string { return ipNetString(n.Address) }},
+	{"address6", func(n config.Node) string { return ipNetString(n.Address6) }},
+	{"network range", func(n config.Node) string { return ipNetString(n.NetworkRange) }},
+	{"network range6", func(n config.Node) string { return ipNetString(n.NetworkRange6) }},
+	{"connected", func(n config.Node) string { return fmt.Sprint(n.Connected) }},
+	{"egress gateway", func(n config.Node) string { return fmt.Sprint(n.IsEgressGateway) }},
+	{"egress ranges", func(n config.Node) string { return strings.Join(n.EgressGatewayRanges, ",") }},
+	{"ingress gateway", func(n config.Node) string { return fmt.Sprint(n.IsIngressGateway) }},
+	{"dns", func(n config.Node) string { return fmt.Sprint(n.DNSOn) }},
+	{"persistent keepalive", func(n config.Node) string { return n.PersistentKeepalive.String() }},
+	{"internet gateway", func(n config.Node) string {
+		if n.InternetGateway == nil {
+			return ""
+		}
+		return n.InternetGateway.String()
+	}},
+}
+
+// diffNodes - returns the settings of the node that differ in the pulled node
+func diffNodes(current, pulled config.Node) []NodeChange {
+	changes := []NodeChange{}
+	for _, field := range nodeDiffFields {
+		if was, now := field.value(current), field.value(pulled); was != now {
+			changes = append(changes, NodeChange{Network: pulled.Network, Field: field.name, Old: was, New: now})
+		}
+	}
+	return changes
+}
+
+// diffPeers - returns the keys of the peers the pull adds and removes, and the peers whose endpoint or
+// allowed ips change, peers marked for removal count as absent
+func diffPeers(current, pulled []wgtypes.PeerConfig) (added, removed []string, changed []PeerChange) {
+	byKey := func(peers []wgtypes.PeerConfig) map[string]wgtypes.PeerConfig {
+		m := make(map[string]wgtypes.PeerConfig)
+		for _, peer := range peers {
+			if !peer.Remove {
+				m[peer.PublicKey.String()] = peer
+			}
+		}
+		return m
+	}
+	currentPeers, pulledPeers := byKey(current), byKey(pulled)
+	for key, peer := range pulledPeers {
+		old, ok := currentPeers[key]
+		if !ok {
+			added = append(added, key)
+			continue
+		}
+		change := PeerChange{PublicKey: key}
+		if oldEndpoint, newEndpoint := udpAddrString(old.Endpoint), udpAddrString(peer.Endpoint); oldEndpoint != newEndpoint {
+			change.OldEndpoint, change.NewEndpoint = oldEndpoint, newEndpoint
+		}
+		change.AddedIPs = missingIPNets(peer.AllowedIPs, old.AllowedIPs)
+		change.RemovedIPs = missingIPNets(old.AllowedIPs, peer.AllowedIPs)
+		if change.OldEndpoint != change.NewEndpoint || len(change.AddedIPs) > 0 || len(change.RemovedIPs) > 0 {
+			changed = append(changed, change)
+		}
+	}
+	for key := range currentPeers {
+		if _, ok := pulledPeers[key]; !ok {
+			removed = append(removed, key)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	sort.Slice(changed, func(i, j int) bool { return changed[i].PublicKey < changed[j].PublicKey })
+	return added, removed, changed
+}
+
+// missingIPNets - returns the sorted ranges of from that are not in of
+func missingIPNets(from, of []net.IPNet) []string {
+	present := make(map[string]bool, len(of))
+	for _, ipNet := range of {
+		present[ipNet.String()] = true
+	}
+	missing := []string{}
+	for _, ipNet := range from {
+		if !present[ipNet.String()] {
+			missing = append(missing, ipNet.String())
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+func ipNetString(ipNet net.IPNet) string {
+	if ipNet.IP == nil {
+		return ""
+	}
+	return ipNet.String()
+}
+
+func udpAddrString(addr *net.UDPAddr) string {
+	if addr == nil {
+		return ""
+	}
+	return addr.String()
+}
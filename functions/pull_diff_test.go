@@ -0,0 +1,82 @@
+package functions
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gravitl/netclient/config"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestDiffNodes(t *testing.T) {
+	current := config.Node{}
+	current.Network = "netmaker"
+	current.Address = net.IPNet{IP: net.ParseIP("10.10.0.2"), Mask: net.CIDRMask(16, 32)}
+	current.Connected = true
+	current.PersistentKeepalive = 20 * time.Second
+	pulled := current
+	pulled.Address = net.IPNet{IP: net.ParseIP("10.10.0.3"), Mask: net.CIDRMask(16, 32)}
+	pulled.IsEgressGateway = true
+
+	changes := diffNodes(current, pulled)
+	if len(changes) != 2 {
+		t.Fatalf("expected the address and egress gateway to change, got %+v", changes)
+	}
+	if changes[0].Field != "address" || changes[0].Old != "10.10.0.2/16" || changes[0].New != "10.10.0.3/16" {
+		t.Errorf("unexpected address change %+v", changes[0])
+	}
+	if changes[1].Field != "egress gateway" || changes[1].Old != "false" || changes[1].New != "true" {
+		t.Errorf("unexpected egress gateway change %+v", changes[1])
+	}
+	if changes := diffNodes(current, current); len(changes) != 0 {
+		t.Errorf("expected no changes for the same node, got %+v", changes)
+	}
+}
+
+func TestDiffPeers(t *testing.T) {
+	keys := make([]wgtypes.Key, 4)
+	for i := range keys {
+		private, _ := wgtypes.GeneratePrivateKey()
+		keys[i] = private.PublicKey()
+	}
+	ipNet := func(cidr string) net.IPNet {
+		_, n, _ := net.ParseCIDR(cidr)
+		return *n
+	}
+	endpoint := &net.UDPAddr{IP: net.ParseIP("203.0.113.5"), Port: 51821}
+	current := []wgtypes.PeerConfig{
+		{PublicKey: keys[0], Endpoint: endpoint, AllowedIPs: []net.IPNet{ipNet("10.10.0.5/32")}},
+		{PublicKey: keys[1], Endpoint: endpoint, AllowedIPs: []net.IPNet{ipNet("10.10.0.6/32"), ipNet("192.168.1.0/24")}},
+		{PublicKey: keys[2], AllowedIPs: []net.IPNet{ipNet("10.10.0.7/32")}},
+	}
+	pulled := []wgtypes.PeerConfig{
+		// unchanged
+		{PublicKey: keys[0], Endpoint: endpoint, AllowedIPs: []net.IPNet{ipNet("10.10.0.5/32")}},
+		// new endpoint, egress range swapped
+		{PublicKey: keys[1], Endpoint: &net.UDPAddr{IP: net.ParseIP("203.0.113.9"), Port: 51821},
+			AllowedIPs: []net.IPNet{ipNet("10.10.0.6/32"), ipNet("192.168.2.0/24")}},
+		// marked for removal
+		{PublicKey: keys[2], Remove: true},
+		{PublicKey: keys[3], AllowedIPs: []net.IPNet{ipNet("10.10.0.8/32")}},
+	}
+
+	added, removed, changed := diffPeers(current, pulled)
+	if len(added) != 1 || added[0] != keys[3].String() {
+		t.Errorf("expected the new peer to be added, got %v", added)
+	}
+	if len(removed) != 1 || removed[0] != keys[2].String() {
+		t.Errorf("expected the peer marked for removal to be removed, got %v", removed)
+	}
+	if len(changed) != 1 {
+		t.Fatalf("expected one changed peer, got %+v", changed)
+	}
+	change := changed[0]
+	if change.PublicKey != keys[1].String() || change.OldEndpoint != "203.0.113.5:51821" || change.NewEndpoint != "203.0.113.9:51821" {
+		t.Errorf("unexpected endpoint change %+v", change)
+	}
+	if len(change.AddedIPs) != 1 || change.AddedIPs[0] != "192.168.2.0/24" ||
+		len(change.RemovedIPs) != 1 || change.RemovedIPs[0] != "192.168.1.0/24" {
+		t.Errorf("unexpected allowed ip change %+v", change)
+	}
+}
@@ -0,0 +1,152 @@
+package functions
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/devilcove/httpclient"
+	"github.com/google/uuid"
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netmaker/models"
+)
+
+const validNodeGet = `{"node": {"id": "5e2a1a47-5d3c-4a5e-9d5b-0d7c5c1b3e11", "network": "netmaker", "server": "nm.example.com",
+	"networkrange": {"IP": "10.10.0.0", "Mask": "//8AAA=="}, "address": {"IP": "10.10.0.2", "Mask": "//8AAA=="}}}`
+
+func TestConvertNodeMalformed(t *testing.T) {
+	malformed := map[string]string{
+		"missing id": `{"node": {"network": "netmaker", "server": "nm.example.com", "address": {"IP": "10.10.0.2", "Mask": "//8AAA=="}}}`,
+		"no address": `{"node": {"id": "6f3b2b58-6e4d-4b6f-8e6c-1e8d6d2c4f22", "network": "netmaker", "server": "nm.example.com"}}`,
+		"bad mask": `{"node": {"id": "6f3b2b58-6e4d-4b6f-8e6c-1e8d6d2c4f22", "network": "netmaker", "server": "nm.example.com",
+			"address": {"IP": "10.10.0.2", "Mask": "/w=="}}}`,
+		"outside range": `{"node": {"id": "6f3b2b58-6e4d-4b6f-8e6c-1e8d6d2c4f22", "network": "netmaker", "server": "nm.example.com",
+			"networkrange": {"IP": "10.10.0.0", "Mask": "//8AAA=="}, "address": {"IP": "10.20.0.2", "Mask": "//8AAA=="}}}`,
+		"v6 in address": `{"node": {"id": "6f3b2b58-6e4d-4b6f-8e6c-1e8d6d2c4f22", "network": "netmaker", "server": "nm.example.com",
+			"address": {"IP": "fd00::2", "Mask": "//////////8AAAAAAAAAAA=="}}}`,
+	}
+	var good models.NodeGet
+	if err := json.Unmarshal([]byte(validNodeGet), &good); err != nil {
+		t.Fatal(err)
+	}
+	if node, err := config.ConvertNode(&good); err != nil || node.Address.IP.String() != "10.10.0.2" {
+		t.Fatalf("expected the valid node to convert, got %v", err)
+	}
+	for name, payload := range malformed {
+		t.Run(name, func(t *testing.T) {
+			var bad models.NodeGet
+			if err := json.Unmarshal([]byte(payload), &bad); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := config.ConvertNode(&bad); err == nil {
+				t.Fatal("expected conversion error")
+			}
+		})
+	}
+}
+
+func TestApplyServerPull(t *testing.T) {
+	defer func() { config.Nodes = config.NodeMap{} }()
+	var good, bad models.NodeGet
+	if err := json.Unmarshal([]byte(validNodeGet), &good); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(`{"node": {"id": "6f3b2b58-6e4d-4b6f-8e6c-1e8d6d2c4f22", "network": "office",
+		"server": "nm.example.com", "address": {"IP": "10.10.0.2", "Mask": "/w=="}}}`), &bad); err != nil {
+		t.Fatal(err)
+	}
+	office := config.Node{}
+	office.ID, office.Network = bad.Node.ID, "office"
+	pull := hostPull{Nodes: []models.Node{good.Node, bad.Node}}
+	pull.Host.Nodes = []string{good.Node.ID.String(), bad.Node.ID.String()}
+
+	config.Nodes = config.NodeMap{"office": office}
+	results := map[string]error{}
+	if _, err := applyServerPull(pull, []config.Node{office}, results, MalformedNodeAbort); err == nil {
+		t.Fatal("expected the pull to abort on the malformed node")
+	}
+	if node := config.GetNode("office"); node.Address.IP != nil || len(config.GetNodes()) != 1 {
+		t.Fatalf("expected no node to be applied on an aborted pull, got %v", config.GetNodes())
+	}
+
+	applied, err := applyServerPull(pull, []config.Node{office}, results, MalformedNodeSkip)
+	if err != nil || !applied {
+		t.Fatalf("expected the valid node to be applied, got %v", err)
+	}
+	if results["office"] == nil {
+		t.Fatal("expected the network of the malformed node to fail the pull")
+	}
+	if node := config.GetNode("office"); node.Address.IP != nil {
+		t.Fatalf("malformed node applied: %+v", node)
+	}
+	if node := config.GetNode("netmaker"); node.Address.IP.String() != "10.10.0.2" {
+		t.Fatalf("valid node not applied: %+v", node)
+	}
+}
+
+func TestMissingNodes(t *testing.T) {
+	listed, unlisted := config.Node{}, config.Node{}
+	listed.ID, listed.Network = uuid.New(), "netmaker"
+	unlisted.ID, unlisted.Network = uuid.New(), "office"
+	missing := missingNodes(models.Host{Nodes: []string{listed.ID.String()}}, []config.Node{listed, unlisted})
+	if len(missing) != 1 || missing[0].Network != "office" {
+		t.Fatalf("expected only the unlisted node to be missing, got %v", missing)
+	}
+}
+
+func TestFetchHostPullRetries(t *testing.T) {
+	client := httpclient.Client
+	defer func() { httpclient.Client = client }()
+	// mockAPI - answers the pull with the status codes in order, the last one repeated, counting the requests
+	mockAPI := func(codes ...int) (*config.Server, *int) {
+		requests := 0
+		srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			code := codes[len(codes)-1]
+			if requests < len(codes) {
+				code = codes[requests]
+			}
+			requests++
+			w.WriteHeader(code)
+			if code == http.StatusOK {
+				json.NewEncoder(w).Encode(models.HostPull{ServerConfig: models.ServerConfig{Server: "nm.example.com"}})
+				return
+			}
+			json.NewEncoder(w).Encode(models.ErrorResponse{Code: code, Message: http.StatusText(code)})
+		}))
+		t.Cleanup(srv.Close)
+		httpclient.Client = *srv.Client()
+		return &config.Server{ServerConfig: models.ServerConfig{API: srv.Listener.Addr().String()}}, &requests
+	}
+	backoff := func() *mqBackoff { return newMQBackoff(time.Millisecond, time.Millisecond) }
+
+	server, requests := mockAPI(http.StatusServiceUnavailable, http.StatusOK)
+	pull, _, err := fetchHostPull(server, "token", 3, backoff())
+	if err != nil || pull.ServerConfig.Server != "nm.example.com" || *requests != 2 {
+		t.Fatalf("expected the pull to succeed on the retry, got %v after %d requests", err, *requests)
+	}
+
+	server, requests = mockAPI(http.StatusNotFound, http.StatusOK)
+	_, errData, err := fetchHostPull(server, "token", 3, backoff())
+	if !errors.Is(err, httpclient.ErrStatus) || errData.Code != http.StatusNotFound || *requests != 1 {
+		t.Fatalf("expected a 404 to be returned without retrying, got %v after %d requests", err, *requests)
+	}
+
+	server, requests = mockAPI(http.StatusBadGateway)
+	_, errData, err = fetchHostPull(server, "token", 3, backoff())
+	if !errors.Is(err, httpclient.ErrStatus) || errData.Code != http.StatusBadGateway || *requests != 3 {
+		t.Fatalf("expected the pull to give up after 3 attempts, got %v after %d requests", err, *requests)
+	}
+}
+
+func TestPullFailures(t *testing.T) {
+	if err := pullFailures(map[string]error{"netmaker": nil}); err != nil {
+		t.Fatalf("expected no error when every network pulled, got %v", err)
+	}
+	err := pullFailures(map[string]error{"netmaker": nil, "office": errPullAuth, "lab": errors.New("503")})
+	if err == nil || err.Error() != "failed to pull network(s): lab, office" {
+		t.Fatalf("expected the failed networks to be named, got %v", err)
+	}
+}
@@ -8,7 +8,6 @@ import (
 	"net"
 	"net/http"
 	"os"
-	"strconv"
 
 	"github.com/devilcove/httpclient"
 	"github.com/google/uuid"
@@ -22,15 +21,32 @@ import (
 
 // Register - should be simple to register with a token
 func Register(token string) error {
+	_, err := registerWithToken(token, config.Netclient(), nil)
+	return err
+}
+
+// registerWithToken - registers the host with the enrollment token, with dryRun set the join stops before the first write
+// and returns the plan of the join checked with the lookups of dryRun, nothing is written or sent to the server
+func registerWithToken(token string, host *config.Config, dryRun *joinEnv) (*JoinPlan, error) {
 	data, err := b64.StdEncoding.DecodeString(token)
 	if err != nil {
+		if dryRun != nil {
+			return nil, fmt.Errorf("could not read enrollment token: %w", err)
+		}
 		logger.FatalLog("could not read enrollment token")
 	}
 	var serverData models.EnrollmentToken
 	if err = json.Unmarshal(data, &serverData); err != nil {
+		if dryRun != nil {
+			return nil, fmt.Errorf("could not read enrollment token: %w", err)
+		}
 		logger.FatalLog("could not read enrollment token")
 	}
-	host := config.Netclient()
+	if dryRun != nil {
+		// the host values looked up below are only set on a copy
+		hostCopy := *host
+		host = &hostCopy
+	}
 	ip, err := getInterfaces()
 	if err != nil {
 		logger.Log(0, "failed to retrieve local interfaces", err.Error())
@@ -46,6 +62,9 @@ func Register(token string) error {
 	} else if defaultInterface != ncutils.GetInterfaceName() {
 		host.DefaultInterface = defaultInterface
 	}
+	if dryRun != nil {
+		return planJoin(serverData.Server, host, *dryRun), nil
+	}
 	shouldUpdateHost, err := doubleCheck(host, serverData.Server)
 	if err != nil {
 		logger.FatalLog(fmt.Sprintf("error when checking host values - %v", err.Error()))
@@ -64,12 +83,12 @@ func Register(token string) error {
 	registerResponse, errData, err := api.GetJSON(models.RegisterResponse{}, models.ErrorResponse{})
 	if err != nil {
 		if errors.Is(err, httpclient.ErrStatus) {
-			logger.FatalLog("error registering with server", strconv.Itoa(errData.Code), errData.Message)
+			return nil, statusConnError(serverData.Server, errData.Code, errData.Message)
 		}
-		return err
+		return nil, classifyConnError(connTargetAPI, serverData.Server, err)
 	}
 	handleRegisterResponse(&registerResponse)
-	return nil
+	return nil, nil
 }
 
 func doubleCheck(host *config.Config, apiServer string) (shouldUpdate bool, err error) {
@@ -0,0 +1,80 @@
+package functions
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netmaker/logger"
+)
+
+// defaults of the reset signal handling, when not configured
+const (
+	defaultResetWindow      = 2 * time.Second
+	defaultResetMinInterval = 30 * time.Second
+)
+
+// resetCoalescer - collapses reset signals received within a window into one reset
+// and spaces out resets by at least minInterval
+type resetCoalescer struct {
+	window      time.Duration
+	minInterval time.Duration
+}
+
+// newResetCoalescer - returns a coalescer using the configured window and interval
+func newResetCoalescer() *resetCoalescer {
+	r := &resetCoalescer{window: defaultResetWindow, minInterval: defaultResetMinInterval}
+	if window := config.Netclient().ResetWindow; window > 0 {
+		r.window = time.Duration(window) * time.Second
+	}
+	if interval := config.Netclient().ResetMinInterval; interval > 0 {
+		r.minInterval = time.Duration(interval) * time.Second
+	}
+	return r
+}
+
+// run - forwards the signals received on signals as resets, until the context is done
+func (r *resetCoalescer) run(ctx context.Context, signals <-chan os.Signal, resets chan<- struct{}) {
+	var (
+		lastReset time.Time
+		pending   int
+		timer     *time.Timer
+		fire      <-chan time.Time
+	)
+	for {
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-signals:
+			pending++
+			if pending > 1 {
+				continue
+			}
+			wait := r.window
+			if !lastReset.IsZero() {
+				if untilAllowed := time.Until(lastReset.Add(r.minInterval)); untilAllowed > wait {
+					logger.Log(0, "reset requested within", r.minInterval.String(), "of the last reset, delaying it by", untilAllowed.Round(time.Second).String())
+					wait = untilAllowed
+				}
+			}
+			timer = time.NewTimer(wait)
+			fire = timer.C
+		case <-fire:
+			if pending > 1 {
+				logger.Log(0, "coalesced", strconv.Itoa(pending), "reset signals into one reset")
+			}
+			pending, timer, fire = 0, nil, nil
+			lastReset = time.Now()
+			select {
+			case resets <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
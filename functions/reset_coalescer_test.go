@@ -0,0 +1,50 @@
+package functions
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestResetCoalescer(t *testing.T) {
+	r := &resetCoalescer{window: 20 * time.Millisecond, minInterval: 300 * time.Millisecond}
+	signals := make(chan os.Signal, 10)
+	resets := make(chan struct{}, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.run(ctx, signals, resets)
+
+	for i := 0; i < 5; i++ {
+		signals <- syscall.SIGHUP
+	}
+	select {
+	case <-resets:
+	case <-time.After(time.Second):
+		t.Fatal("expected a reset")
+	}
+	select {
+	case <-resets:
+		t.Fatal("rapid signals should collapse into a single reset")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// a reset right after the last one is delayed until the minimum interval has passed
+	start := time.Now()
+	signals <- syscall.SIGHUP
+	signals <- syscall.SIGHUP
+	select {
+	case <-resets:
+		if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+			t.Fatalf("reset happened %v after the previous one, expected it to be rate limited", elapsed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the delayed reset")
+	}
+	select {
+	case <-resets:
+		t.Fatal("expected a single delayed reset")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,106 @@
+package functions
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netclient/ncutils"
+	"github.com/gravitl/netmaker/logger"
+)
+
+// serverActivityFile - file in the netclient path the server activity is persisted to
+const serverActivityFile = "server_activity.json"
+
+// ServerActivity - when the host last successfully connected to the broker of a server and checked in with it
+type ServerActivity struct {
+	Server      string    `json:"server"`
+	LastConnect time.Time `json:"lastconnect,omitempty"`
+	LastCheckin time.Time `json:"lastcheckin,omitempty"`
+}
+
+// activityTracker - records the server activity and persists it to a file so it survives restarts
+type activityTracker struct {
+	mutex   sync.Mutex
+	file    string
+	servers map[string]ServerActivity
+}
+
+var serverActivity = &activityTracker{}
+
+// newActivityTracker - creates a tracker persisting to file, loading the activity already in it
+func newActivityTracker(file string) *activityTracker {
+	t := &activityTracker{file: file, servers: map[string]ServerActivity{}}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Log(0, "failed to read server activity", err.Error())
+		}
+		return t
+	}
+	if err := json.Unmarshal(data, &t.servers); err != nil {
+		logger.Log(0, "failed to decode server activity", err.Error())
+		t.servers = map[string]ServerActivity{}
+	}
+	return t
+}
+
+// loadServerActivity - loads the persisted server activity of the host
+func loadServerActivity() {
+	t := newActivityTracker(config.GetNetclientPath() + serverActivityFile)
+	serverActivity.mutex.Lock()
+	defer serverActivity.mutex.Unlock()
+	serverActivity.file, serverActivity.servers = t.file, t.servers
+}
+
+// connected - records a successful broker connect to the server
+func (t *activityTracker) connected(server string, now time.Time) {
+	t.update(server, func(a *ServerActivity) { a.LastConnect = now })
+}
+
+// checkedIn - records a successful checkin with the server
+func (t *activityTracker) checkedIn(server string, now time.Time) {
+	t.update(server, func(a *ServerActivity) { a.LastCheckin = now })
+}
+
+func (t *activityTracker) update(server string, set func(*ServerActivity)) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.servers == nil {
+		t.servers = map[string]ServerActivity{}
+	}
+	activity := t.servers[server]
+	activity.Server = server
+	set(&activity)
+	t.servers[server] = activity
+	if t.file == "" {
+		return
+	}
+	data, err := json.Marshal(t.servers)
+	if err != nil {
+		logger.Log(0, "failed to encode server activity", err.Error())
+		return
+	}
+	if err := ncutils.WriteFileAtomic(t.file, 0600, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	}); err != nil {
+		logger.Log(0, "failed to persist server activity", err.Error())
+	}
+}
+
+// list - returns the activity of the servers, sorted by server name
+func (t *activityTracker) list() []ServerActivity {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	activity := make([]ServerActivity, 0, len(t.servers))
+	for _, a := range t.servers {
+		activity = append(activity, a)
+	}
+	sort.Slice(activity, func(i, j int) bool { return activity[i].Server < activity[j].Server })
+	return activity
+}
@@ -0,0 +1,35 @@
+package functions
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/matryer/is"
+)
+
+func TestServerActivity(t *testing.T) {
+	is := is.New(t)
+	file := filepath.Join(t.TempDir(), serverActivityFile)
+	connectedAt := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	checkedInAt := connectedAt.Add(time.Minute)
+
+	tracker := newActivityTracker(file)
+	is.Equal(len(tracker.list()), 0)
+	tracker.connected("b.example.com", connectedAt)
+	tracker.connected("a.example.com", connectedAt)
+	tracker.checkedIn("a.example.com", checkedInAt)
+
+	activity := tracker.list()
+	is.Equal(len(activity), 2)
+	is.Equal(activity[0].Server, "a.example.com")
+	is.True(activity[0].LastConnect.Equal(connectedAt))
+	is.True(activity[0].LastCheckin.Equal(checkedInAt))
+	is.True(activity[1].LastCheckin.IsZero())
+
+	restored := newActivityTracker(file).list()
+	is.Equal(len(restored), 2)
+	is.True(restored[0].LastConnect.Equal(connectedAt))
+	is.True(restored[0].LastCheckin.Equal(checkedInAt))
+	is.True(restored[1].LastConnect.Equal(connectedAt))
+}
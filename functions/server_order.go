@@ -0,0 +1,86 @@
+package functions
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netmaker/logger"
+)
+
+// priorityConnectTimeout - how long a priority server's connection is waited on before starting the next server
+const priorityConnectTimeout = 30 * time.Second
+
+// defaultServerSetupConcurrency - servers set up at the same time on startup, when not configured
+const defaultServerSetupConcurrency = 4
+
+// orderServers - returns the servers in the order given by priority, followed by the rest sorted by name
+func orderServers(servers map[string]config.Server, priority []string) []config.Server {
+	ordered := []config.Server{}
+	seen := make(map[string]bool)
+	for _, name := range priority {
+		if server, ok := servers[name]; ok && !seen[name] {
+			ordered = append(ordered, server)
+			seen[name] = true
+		}
+	}
+	rest := []string{}
+	for name := range servers {
+		if !seen[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	for _, name := range rest {
+		ordered = append(ordered, servers[name])
+	}
+	return ordered
+}
+
+// startServers - sets the routes of each server and starts its connection in priority order,
+// the connection of a priority server is established before the next server is started,
+// the rest are set up in parallel, at most concurrency at a time, and are done when it returns
+func startServers(servers map[string]config.Server, priority []string, setRoutes func(*config.Server),
+	connect func(*config.Server, chan<- struct{}), timeout time.Duration, concurrency int) {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	prioritized := make(map[string]bool)
+	for _, name := range priority {
+		prioritized[name] = true
+	}
+	sem := make(chan struct{}, concurrency)
+	setup := sync.WaitGroup{}
+	for _, server := range orderServers(servers, priority) {
+		server := server
+		if !prioritized[server.Name] {
+			setup.Add(1)
+			sem <- struct{}{}
+			go func() {
+				defer setup.Done()
+				defer func() { <-sem }()
+				setRoutes(&server)
+				connect(&server, make(chan struct{}))
+			}()
+			continue
+		}
+		setRoutes(&server)
+		connected := make(chan struct{})
+		connect(&server, connected)
+		select {
+		case <-connected:
+		case <-time.After(timeout):
+			logger.Log(0, "timed out waiting for priority server", server.Name, "to connect, starting remaining servers")
+		}
+	}
+	setup.Wait()
+}
+
+// serverSetupConcurrency - returns the number of servers set up at the same time on startup
+func serverSetupConcurrency() int {
+	if n := config.Netclient().ServerSetupConcurrency; n > 0 {
+		return n
+	}
+	return defaultServerSetupConcurrency
+}
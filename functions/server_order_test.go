@@ -0,0 +1,96 @@
+package functions
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gravitl/netclient/config"
+)
+
+func TestStartServersPriority(t *testing.T) {
+	servers := map[string]config.Server{}
+	for _, name := range []string{"c.example.com", "primary.example.com", "a.example.com"} {
+		server := config.Server{}
+		server.Name = name
+		servers[name] = server
+	}
+	events := make(chan string, 10)
+	setRoutes := func(server *config.Server) {
+		events <- "route " + server.Name
+	}
+	connect := func(server *config.Server, connected chan<- struct{}) {
+		go func() {
+			// the primary connects slowly, secondaries must not start before it is connected
+			if server.Name == "primary.example.com" {
+				time.Sleep(50 * time.Millisecond)
+			}
+			events <- "connected " + server.Name
+			close(connected)
+		}()
+	}
+	startServers(servers, []string{"primary.example.com", "missing.example.com"}, setRoutes, connect, time.Second, 1)
+
+	want := []string{"route primary.example.com", "connected primary.example.com", "route a.example.com"}
+	for _, w := range want {
+		if got := <-events; got != w {
+			t.Fatalf("expected %q, got %q", w, got)
+		}
+	}
+}
+
+func TestOrderServers(t *testing.T) {
+	servers := map[string]config.Server{}
+	for _, name := range []string{"b", "c", "a", "d"} {
+		server := config.Server{}
+		server.Name = name
+		servers[name] = server
+	}
+	ordered := orderServers(servers, []string{"c", "a"})
+	names := []string{}
+	for _, server := range ordered {
+		names = append(names, server.Name)
+	}
+	if got := names; len(got) != 4 || got[0] != "c" || got[1] != "a" || got[2] != "b" || got[3] != "d" {
+		t.Fatalf("unexpected order %v", got)
+	}
+}
+
+func TestStartServersConcurrency(t *testing.T) {
+	servers := map[string]config.Server{}
+	for i := 0; i < 8; i++ {
+		server := config.Server{}
+		server.Name = fmt.Sprintf("server%d.example.com", i)
+		servers[server.Name] = server
+	}
+	var inFlight, maxInFlight int32
+	mu := sync.Mutex{}
+	routed := map[string]bool{}
+	setRoutes := func(server *config.Server) {
+		n := atomic.AddInt32(&inFlight, 1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		mu.Lock()
+		routed[server.Name] = true
+		mu.Unlock()
+		atomic.AddInt32(&inFlight, -1)
+	}
+	connect := func(server *config.Server, connected chan<- struct{}) {
+		close(connected)
+	}
+	startServers(servers, nil, setRoutes, connect, time.Second, 3)
+
+	if len(routed) != len(servers) {
+		t.Fatalf("expected every server to be set up before returning, got %d", len(routed))
+	}
+	if max := atomic.LoadInt32(&maxInFlight); max < 2 || max > 3 {
+		t.Fatalf("expected servers to be set up in parallel at most 3 at a time, got %d", max)
+	}
+}
@@ -0,0 +1,164 @@
+package functions
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netclient/routes"
+	"github.com/gravitl/netmaker/logger"
+)
+
+const (
+	// defaultServerRouteRetries - times setting the routes of a server is retried, when not configured
+	defaultServerRouteRetries = 5
+	// serverRouteBackoffMin - delay before the first retry of setting the routes of a server
+	serverRouteBackoffMin = 2 * time.Second
+	// serverRouteBackoffMax - maximum delay between retries of setting the routes of a server
+	serverRouteBackoffMax = time.Minute
+)
+
+// serverRouteStatus - outcome of setting the routes of a server
+type serverRouteStatus struct {
+	err        error
+	persistent bool // retries are exhausted
+}
+
+// serverRouteTracker - tracks the servers whose routes could not be set
+type serverRouteTracker struct {
+	mutex    sync.Mutex
+	statuses map[string]serverRouteStatus
+}
+
+var serverRoutes = &serverRouteTracker{statuses: make(map[string]serverRouteStatus)}
+
+// serverRouteRetriers - the retries of setting server routes, at most one per server, bound to the daemon routines
+type serverRouteRetriers struct {
+	mutex   sync.Mutex
+	ctx     context.Context
+	wg      *sync.WaitGroup
+	retries map[string]*routeRetry
+}
+
+// routeRetry - a retry of setting the routes of a server in progress
+type routeRetry struct {
+	cancel context.CancelFunc
+}
+
+var routeRetriers = &serverRouteRetriers{retries: make(map[string]*routeRetry)}
+
+// serverRouteRetriers.bind - binds the retries to the routines of the daemon, they stop when ctx is done
+// and wg waits for them
+func (r *serverRouteRetriers) bind(ctx context.Context, wg *sync.WaitGroup) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.ctx, r.wg = ctx, wg
+}
+
+// serverRouteRetriers.start - retries setting the routes of the server in the background, replacing
+// the retry of the server in progress
+func (r *serverRouteRetriers) start(server *config.Server, set func(*config.Server) error, retries int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.ctx == nil || r.ctx.Err() != nil {
+		logger.Log(2, "daemon is not running, not retrying route(s) for", server.Name)
+		return
+	}
+	if retry, ok := r.retries[server.Name]; ok {
+		retry.cancel()
+	}
+	ctx, cancel := context.WithCancel(r.ctx)
+	retry := &routeRetry{cancel: cancel}
+	r.retries[server.Name] = retry
+	retryServer := *server
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		retryServerRoutes(ctx, &retryServer, set, newMQBackoff(serverRouteBackoffMin, serverRouteBackoffMax), retries)
+		r.mutex.Lock()
+		if r.retries[retryServer.Name] == retry {
+			delete(r.retries, retryServer.Name)
+		}
+		r.mutex.Unlock()
+		cancel()
+	}()
+}
+
+// serverRouteRetriers.stop - stops the retry of the server in progress, if any
+func (r *serverRouteRetriers) stop(server string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if retry, ok := r.retries[server]; ok {
+		retry.cancel()
+		delete(r.retries, server)
+	}
+}
+
+// serverRouteTracker.record - records the outcome of an attempt to set the routes of the server
+func (t *serverRouteTracker) record(server string, err error, persistent bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if err == nil {
+		delete(t.statuses, server)
+		return
+	}
+	t.statuses[server] = serverRouteStatus{err: err, persistent: persistent}
+}
+
+// serverRouteTracker.failures - returns the servers whose routes could not be set, only those with
+// exhausted retries unless all is set
+func (t *serverRouteTracker) failures(all bool) map[string]error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	failures := make(map[string]error)
+	for server, status := range t.statuses {
+		if all || status.persistent {
+			failures[server] = status.err
+		}
+	}
+	return failures
+}
+
+// setServerRoutes - sets the routes of the server, retrying with backoff in the background when it fails
+func setServerRoutes(server *config.Server) {
+	set := func(server *config.Server) error {
+		return routes.SetNetmakerServerRoutes(config.Netclient().DefaultInterface, server)
+	}
+	err := set(server)
+	serverRoutes.record(server.Name, err, false)
+	if err == nil {
+		routeRetriers.stop(server.Name)
+		return
+	}
+	logger.Log(2, "failed to set route(s) for", server.Name, err.Error())
+	retries := config.Netclient().ServerRouteRetries
+	if retries <= 0 {
+		retries = defaultServerRouteRetries
+	}
+	routeRetriers.start(server, set, retries)
+}
+
+// retryServerRoutes - retries setting the routes of the server until it succeeds, the retries are exhausted
+// or ctx is done, recording each outcome
+func retryServerRoutes(ctx context.Context, server *config.Server, set func(*config.Server) error, backoff *mqBackoff, retries int) error {
+	var err error
+	for retry := 1; retry <= retries; retry++ {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff.next()):
+		}
+		err = set(server)
+		serverRoutes.record(server.Name, err, err != nil && retry == retries)
+		if err == nil {
+			logger.Log(0, "set route(s) for", server.Name, "after", fmt.Sprint(retry), "retries")
+			return nil
+		}
+		logger.Log(2, "failed to set route(s) for", server.Name, err.Error())
+	}
+	logger.Log(0, "giving up setting route(s) for", server.Name, err.Error())
+	recordError("failed to set route(s) for", server.Name, err.Error())
+	return err
+}
@@ -0,0 +1,91 @@
+package functions
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gravitl/netclient/config"
+	"github.com/matryer/is"
+)
+
+func TestRetryServerRoutes(t *testing.T) {
+	is := is.New(t)
+	server := &config.Server{}
+	server.Name = "routes.example.com"
+	defer serverRoutes.record(server.Name, nil, false)
+
+	// a backend that fails then succeeds
+	calls := 0
+	set := func(*config.Server) error {
+		calls++
+		if calls > 1 {
+			// unrouted while retrying, but not yet reported as a persistent failure
+			_, unrouted := serverRoutes.failures(true)[server.Name]
+			is.True(unrouted)
+			is.Equal(len(serverRoutes.failures(false)), 0)
+		}
+		if calls < 3 {
+			return errors.New("network is unreachable")
+		}
+		return nil
+	}
+	is.NoErr(retryServerRoutes(context.Background(), server, set, newMQBackoff(time.Millisecond, time.Millisecond), 5))
+	is.Equal(calls, 3)
+	is.Equal(len(serverRoutes.failures(true)), 0)
+
+	// a backend that keeps failing is reported once the retries are exhausted
+	calls = 0
+	fail := func(*config.Server) error {
+		calls++
+		return errors.New("network is unreachable")
+	}
+	is.True(retryServerRoutes(context.Background(), server, fail, newMQBackoff(time.Millisecond, time.Millisecond), 2) != nil)
+	is.Equal(calls, 2)
+	failures := serverRoutes.failures(false)
+	is.True(failures[server.Name] != nil)
+}
+
+func TestServerRouteRetriers(t *testing.T) {
+	is := is.New(t)
+	server := &config.Server{}
+	server.Name = "retriers.example.com"
+	defer serverRoutes.record(server.Name, nil, false)
+	retriers := &serverRouteRetriers{retries: make(map[string]*routeRetry)}
+
+	// not retried before the daemon routines run
+	retriers.start(server, func(*config.Server) error { return errors.New("network is unreachable") }, 1)
+	is.Equal(len(retriers.retries), 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	wg := &sync.WaitGroup{}
+	retriers.bind(ctx, wg)
+	first := make(chan struct{})
+	retriers.start(server, func(*config.Server) error {
+		close(first)
+		return errors.New("network is unreachable")
+	}, 1000)
+	<-first
+	// a new retry of the server replaces the one in progress
+	retriers.start(server, func(*config.Server) error { return errors.New("network is unreachable") }, 1000)
+	retriers.mutex.Lock()
+	is.Equal(len(retriers.retries), 1)
+	retriers.mutex.Unlock()
+
+	// the retries stop with the daemon routines
+	cancel()
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the retries to stop with the daemon routines")
+	}
+	retriers.start(server, func(*config.Server) error { return nil }, 1)
+	is.Equal(len(retriers.retries), 0)
+}
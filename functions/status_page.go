@@ -0,0 +1,134 @@
+package functions
+
+import (
+	"embed"
+	"html/template"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netclient/ncutils"
+	proxy_cfg "github.com/gravitl/netclient/nmproxy/config"
+	"github.com/gravitl/netclient/nmproxy/router"
+	"github.com/gravitl/netclient/nmproxy/wg"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+//go:embed templates/status.html
+var statusPageFS embed.FS
+
+var statusPageTemplate = template.Must(template.ParseFS(statusPageFS, "templates/status.html"))
+
+// maxRecentErrors - errors kept for the status page
+const maxRecentErrors = 20
+
+// RecentError - an error shown on the status page
+type RecentError struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+var (
+	recentErrors      []RecentError
+	recentErrorsMutex sync.Mutex
+)
+
+// recordError - keeps the error for the status page, dropping the oldest beyond maxRecentErrors
+func recordError(msg ...string) {
+	recentErrorsMutex.Lock()
+	defer recentErrorsMutex.Unlock()
+	recentErrors = append(recentErrors, RecentError{Time: time.Now(), Message: strings.Join(msg, " ")})
+	if len(recentErrors) > maxRecentErrors {
+		recentErrors = recentErrors[len(recentErrors)-maxRecentErrors:]
+	}
+}
+
+// getRecentErrors - returns the recorded errors, newest first
+func getRecentErrors() []RecentError {
+	recentErrorsMutex.Lock()
+	defer recentErrorsMutex.Unlock()
+	errs := make([]RecentError, 0, len(recentErrors))
+	for i := len(recentErrors) - 1; i >= 0; i-- {
+		errs = append(errs, recentErrors[i])
+	}
+	return errs
+}
+
+// statusPageNetwork - network membership shown on the status page
+type statusPageNetwork struct {
+	Network   string
+	Server    string
+	Address   string
+	Connected bool
+}
+
+// statusPageData - state rendered on the status page
+type statusPageData struct {
+	Host      string
+	Version   string
+	NatType   string
+	Firewall  router.FirewallInfo
+	Generated time.Time
+	Networks  []statusPageNetwork
+	Servers   []ServerActivity
+	Peers     []PeerPing
+	Errors    []RecentError
+}
+
+// renderStatusPage - writes the status page for the state
+func renderStatusPage(w io.Writer, data statusPageData) error {
+	return statusPageTemplate.Execute(w, data)
+}
+
+// collectStatusPageData - gathers the state of the running daemon for the status page
+func collectStatusPageData(now time.Time) statusPageData {
+	data := statusPageData{
+		Host:      config.Netclient().Name,
+		Version:   config.Netclient().Version,
+		NatType:   proxy_cfg.GetCfg().GetHostInfo().NatType,
+		Firewall:  router.GetFirewallInfo(),
+		Generated: now,
+		Networks:  []statusPageNetwork{},
+		Servers:   serverActivity.list(),
+		Peers:     []PeerPing{},
+		Errors:    getRecentErrors(),
+	}
+	for _, node := range config.GetNodes() {
+		address := node.Address.IP.String()
+		if node.Address.IP == nil {
+			address = node.Address6.IP.String()
+		}
+		data.Networks = append(data.Networks, statusPageNetwork{
+			Network:   node.Network,
+			Server:    node.Server,
+			Address:   address,
+			Connected: node.Connected,
+		})
+	}
+	sort.Slice(data.Networks, func(i, j int) bool { return data.Networks[i].Network < data.Networks[j].Network })
+	peers, err := wg.GetPeers(ncutils.GetInterfaceName())
+	if err != nil {
+		return data
+	}
+	data.Peers = statusPagePeers(peers, proxyPeerPath, now)
+	return data
+}
+
+// statusPagePeers - connection status of each peer, without probing them
+func statusPagePeers(peers []wgtypes.Peer, pathOf peerPathFunc, now time.Time) []PeerPing {
+	noProbe := func(net.IP) (time.Duration, error) { return 0, nil }
+	statuses := []PeerPing{}
+	for _, peer := range peers {
+		status, err := pingPeer(peer.PublicKey.String(), []wgtypes.Peer{peer}, noProbe, pathOf, now)
+		if err != nil {
+			status = PeerPing{PeerKey: peer.PublicKey.String(), Path: pathOf(peer.PublicKey.String()), HandshakeState: HandshakeNone}
+		}
+		status.Reachable, status.Latency = false, ""
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
@@ -0,0 +1,63 @@
+package functions
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gravitl/netclient/nmproxy/router"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestRenderStatusPage(t *testing.T) {
+	now := time.Now()
+	// fixed key, the escaping of + and / in generated keys would fail the match
+	key, err := wgtypes.ParseKey("mFxRAaE5dsuVLbkGzUpRvmSfAmE0oQ5SMSpiVaWgz1w=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	peers := statusPagePeers([]wgtypes.Peer{{
+		PublicKey:         key,
+		Endpoint:          &net.UDPAddr{IP: net.ParseIP("203.0.113.5"), Port: 51821},
+		LastHandshakeTime: now.Add(-time.Minute),
+		AllowedIPs:        []net.IPNet{{IP: net.ParseIP("10.0.0.5"), Mask: net.CIDRMask(32, 32)}},
+	}}, func(string) string { return PeerPathTurn }, now)
+	data := statusPageData{
+		Host:      "host-1",
+		Version:   "v0.19.0",
+		NatType:   "asymmetric",
+		Firewall:  router.FirewallInfo{Backend: router.FirewallNftables},
+		Generated: now,
+		Networks: []statusPageNetwork{
+			{Network: "netmaker", Server: "nm.example.com", Address: "10.0.0.2", Connected: true},
+		},
+		Peers:  peers,
+		Errors: []RecentError{{Time: now, Message: "unable to connect to broker <broker>"}},
+	}
+	var buf bytes.Buffer
+	if err := renderStatusPage(&buf, data); err != nil {
+		t.Fatal(err)
+	}
+	page := buf.String()
+	for _, want := range []string{"host-1", "asymmetric", "nftables", "netmaker", "nm.example.com",
+		key.String(), "203.0.113.5:51821", "turn", "active",
+		"unable to connect to broker &lt;broker&gt;"} {
+		if !strings.Contains(page, want) {
+			t.Errorf("status page is missing %q", want)
+		}
+	}
+}
+
+func TestRenderStatusPageEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := renderStatusPage(&buf, statusPageData{Generated: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"not a member of any network", "no peers", "unknown"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("status page is missing %q", want)
+		}
+	}
+}
@@ -1,13 +1,18 @@
 package functions
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/devilcove/httpclient"
+	mqtt "github.com/eclipse/paho.mqtt.golang"
 	"github.com/gravitl/netclient/auth"
 	"github.com/gravitl/netclient/config"
 	"github.com/gravitl/netclient/daemon"
@@ -15,8 +20,15 @@ import (
 	"github.com/gravitl/netclient/wireguard"
 	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/models"
+	"github.com/gravitl/netmaker/mq"
 )
 
+// leaveAcks - waiters for the server's acknowledgement of node removals, indexed by node id
+var leaveAcks = new(sync.Map)
+
+// leaveTeardownGrace - time allowed for the local teardown after the leave stopped waiting for the acknowledgement
+const leaveTeardownGrace = time.Minute
+
 // Uninstall - uninstalls networks from client
 func Uninstall() ([]error, error) {
 	allfaults := []error{}
@@ -28,7 +40,9 @@ func Uninstall() ([]error, error) {
 			allfaults = append(allfaults, err)
 			continue
 		}
-		defer ServerSet[v.Name].Disconnect(250)
+		if mqclient, ok := getServerClient(v.Name); ok {
+			defer mqclient.Disconnect(250)
+		}
 		if err = PublishHostUpdate(v.Name, models.DeleteHost); err != nil {
 			logger.Log(0, "failed to notify server", v.Name, "of host removal")
 			allfaults = append(allfaults, err)
@@ -54,8 +68,75 @@ func LeaveNetwork(network string, isDaemon bool) ([]error, error) {
 	if err := deleteNodeFromServer(&node); err != nil {
 		faults = append(faults, fmt.Errorf("error deleting nodes from server %w", err))
 	}
+	return teardownNetwork(&node, isDaemon, faults)
+}
+
+// LeaveNetworkWithAck - client exits a network after the server acknowledges the node removal,
+// local teardown is performed regardless if no acknowledgement is received within the timeout
+func LeaveNetworkWithAck(network string, isDaemon bool, timeout time.Duration) ([]error, error) {
+	faults := []error{}
+	node, ok := config.Nodes[network]
+	if !ok {
+		return faults, fmt.Errorf("not connected to network: %s", network)
+	}
+	server := config.GetServer(node.Server)
+	if server == nil {
+		return faults, fmt.Errorf("server config not found for network: %s", network)
+	}
+	if !isDaemon {
+		// the daemon receives the removal too, mark the leave pending so it leaves the teardown to this leave
+		dir := config.GetNetclientPath()
+		if err := markLeavePending(dir, node.ID.String(), time.Now().Add(timeout+leaveTeardownGrace)); err != nil {
+			logger.Log(0, "failed to mark leave of network", network, "pending", err.Error())
+		}
+		defer clearLeavePending(dir, node.ID.String())
+		// listen for the ack with a client id of its own so the daemon stays connected
+		if err := setupMQTTSingletonWithID(server, leaveClientID(server), true); err != nil {
+			logger.Log(0, "failed to connect to broker, can not wait for leave acknowledgement", err.Error())
+		} else {
+			mqclient, _ := getServerClient(server.Name)
+			defer mqclient.Disconnect(250)
+			if token := mqclient.Subscribe(fmt.Sprintf("node/update/%s/%s", node.Network, node.ID), 0,
+				mqtt.MessageHandler(leaveAckHandler)); !token.WaitTimeout(mq.MQ_TIMEOUT*time.Second) || token.Error() != nil {
+				logger.Log(0, "failed to subscribe for leave acknowledgement on network", node.Network)
+			}
+		}
+	}
+	acked, err := removeNodeWithAck(node.ID.String(), func() error {
+		return deleteNodeFromServer(&node)
+	}, timeout)
+	if err != nil {
+		faults = append(faults, fmt.Errorf("error deleting nodes from server %w", err))
+	} else if !acked {
+		logger.Log(0, "WARNING: server did not acknowledge removal of node", node.ID.String(), "from network", network,
+			"within", timeout.String(), "- performing local teardown only")
+	} else {
+		logger.Log(0, "server acknowledged removal of node", node.ID.String(), "from network", network)
+	}
+	return teardownNetwork(&node, isDaemon, faults)
+}
+
+// leaveClientID - the mq client id of a leave waiting for the acknowledgement outside of the daemon
+func leaveClientID(server *config.Server) string {
+	return server.MQID.String() + "-leave"
+}
+
+// removeNodeWithAck - removes the node from the server with remove and waits up to timeout for the server
+// to acknowledge it, returns whether it was acknowledged
+func removeNodeWithAck(nodeID string, remove func() error, timeout time.Duration) (bool, error) {
+	ackCh := registerLeaveAck(nodeID)
+	defer leaveAcks.Delete(nodeID)
+	if err := remove(); err != nil {
+		return false, err
+	}
+	return waitForLeaveAck(ackCh, timeout), nil
+}
+
+// teardownNetwork - removes the local interface config, dns entries and routes of the node
+func teardownNetwork(node *config.Node, isDaemon bool, faults []error) ([]error, error) {
+	network := node.Network
 	// remove node from config
-	if err := deleteLocalNetwork(&node); err != nil {
+	if err := deleteLocalNetwork(node); err != nil {
 		faults = append(faults, fmt.Errorf("error deleting wireguard interface %w", err))
 	}
 	if err := deleteNetworkDNS(network); err != nil {
@@ -89,8 +170,89 @@ func LeaveNetwork(network string, isDaemon bool) ([]error, error) {
 	return faults, nil
 }
 
+// leavePendingFile - file marking a leave of the node in progress outside of the daemon
+func leavePendingFile(dir, nodeID string) string {
+	return filepath.Join(dir, "leave-"+nodeID)
+}
+
+// markLeavePending - marks the leave of the node in progress until the given time
+func markLeavePending(dir, nodeID string, until time.Time) error {
+	return os.WriteFile(leavePendingFile(dir, nodeID), []byte(until.Format(time.RFC3339Nano)), 0600)
+}
+
+// clearLeavePending - removes the mark of the leave of the node
+func clearLeavePending(dir, nodeID string) {
+	if err := os.Remove(leavePendingFile(dir, nodeID)); err != nil && !os.IsNotExist(err) {
+		logger.Log(0, "failed to clear pending leave of node", nodeID, err.Error())
+	}
+}
+
+// leavePending - checks if a leave of the node is in progress at now, the mark of a leave that
+// did not finish in time is ignored and removed
+func leavePending(dir, nodeID string, now time.Time) bool {
+	data, err := os.ReadFile(leavePendingFile(dir, nodeID))
+	if err != nil {
+		return false
+	}
+	until, err := time.Parse(time.RFC3339Nano, string(data))
+	if err != nil || now.After(until) {
+		clearLeavePending(dir, nodeID)
+		return false
+	}
+	return true
+}
+
+// registerLeaveAck - registers a waiter for the server's acknowledgement of the node removal
+func registerLeaveAck(nodeID string) chan struct{} {
+	ackCh := make(chan struct{}, 1)
+	leaveAcks.Store(nodeID, ackCh)
+	return ackCh
+}
+
+// ackLeave - signals the waiter of the node removal, returns false if no leave is waiting for an ack
+func ackLeave(nodeID string) bool {
+	ackCh, ok := leaveAcks.LoadAndDelete(nodeID)
+	if !ok {
+		return false
+	}
+	ackCh.(chan struct{}) <- struct{}{}
+	return true
+}
+
+// waitForLeaveAck - waits for the server's acknowledgement of the node removal until timeout
+func waitForLeaveAck(ackCh chan struct{}, timeout time.Duration) bool {
+	select {
+	case <-ackCh:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// leaveAckHandler - mqtt message handler acknowledging node removals while leaving outside of the daemon
+func leaveAckHandler(client mqtt.Client, msg mqtt.Message) {
+	network := parseNetworkFromTopic(msg.Topic())
+	node := config.GetNode(network)
+	data, err := decryptMsg(node.Server, msg.Payload())
+	if err != nil {
+		logger.Log(0, "error decrypting message", err.Error())
+		return
+	}
+	serverNode := models.Node{}
+	if err = json.Unmarshal(data, &serverNode); err != nil {
+		logger.Log(0, "error unmarshalling node update data"+err.Error())
+		return
+	}
+	if serverNode.Action == models.NODE_DELETE {
+		ackLeave(serverNode.ID.String())
+	}
+}
+
 func deleteNodeFromServer(node *config.Node) error {
 	server := config.GetServer(node.Server)
+	if server == nil {
+		return fmt.Errorf("no server config for network %s", node.Network)
+	}
 	token, err := auth.Authenticate(server, config.Netclient())
 	if err != nil {
 		return fmt.Errorf("unable to authenticate %w", err)
@@ -129,6 +291,7 @@ func deleteLocalNetwork(node *config.Node) error {
 	}
 	//remove node from nodes map
 	config.DeleteNode(node.Network)
+	forgetNetworkMessages(node.Network)
 	server := config.GetServer(node.Server)
 	//remove node from server node map
 	if server != nil {
@@ -137,6 +300,7 @@ func deleteLocalNetwork(node *config.Node) error {
 	if len(server.Nodes) == 0 {
 		logger.Log(3, "removing server peers", server.Name)
 		config.DeleteServerHostPeerCfg(node.Server)
+		forgetGatewayRoles(node.Server)
 	}
 	config.WriteNetclientConfig()
 	config.WriteNodeConfig()
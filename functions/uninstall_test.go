@@ -0,0 +1,87 @@
+package functions
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gravitl/netclient/config"
+)
+
+func TestRemoveNodeWithAck(t *testing.T) {
+	nodeID := uuid.New().String()
+	// the server acknowledges the removal while it is being waited for
+	acked, err := removeNodeWithAck(nodeID, func() error {
+		go ackLeave(nodeID)
+		return nil
+	}, time.Second)
+	if err != nil || !acked {
+		t.Fatalf("expected the removal to be acknowledged, got %v %v", acked, err)
+	}
+	if _, waiting := leaveAcks.Load(nodeID); waiting {
+		t.Error("expected the waiter to be removed after the leave")
+	}
+
+	// no acknowledgement within the timeout
+	start := time.Now()
+	acked, err = removeNodeWithAck(nodeID, func() error { return nil }, 20*time.Millisecond)
+	if err != nil || acked {
+		t.Fatalf("expected the removal to time out unacknowledged, got %v %v", acked, err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("expected to wait for the timeout, returned after %s", elapsed)
+	}
+	// a delete arriving after the leave gave up is handled like any other delete
+	if ackLeave(nodeID) {
+		t.Error("expected no waiter after the timeout")
+	}
+
+	// a failed removal is not waited for
+	acked, err = removeNodeWithAck(nodeID, func() error { return errors.New("server unavailable") }, time.Minute)
+	if err == nil || acked {
+		t.Fatalf("expected the removal error, got %v %v", acked, err)
+	}
+}
+
+func TestLeaveClientID(t *testing.T) {
+	server := &config.Server{MQID: uuid.New()}
+	if leaveClientID(server) == server.MQID.String() {
+		t.Fatal("expected the leave to connect with a client id other than the daemon's")
+	}
+}
+
+func TestLeavePending(t *testing.T) {
+	dir := t.TempDir()
+	nodeID := uuid.New().String()
+	now := time.Now()
+	if leavePending(dir, nodeID, now) {
+		t.Fatal("expected no leave to be pending")
+	}
+	if err := markLeavePending(dir, nodeID, now.Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	// the daemon runs in a process of its own and holds no waiter, it sees the leave through the mark
+	if _, waiting := leaveAcks.Load(nodeID); waiting {
+		t.Fatal("expected no waiter in this process")
+	}
+	if !leavePending(dir, nodeID, now) {
+		t.Fatal("expected the leave to be pending")
+	}
+	clearLeavePending(dir, nodeID)
+	if leavePending(dir, nodeID, now) {
+		t.Fatal("expected the leave not to be pending once cleared")
+	}
+
+	// the mark of a leave that did not finish in time is ignored and removed
+	if err := markLeavePending(dir, nodeID, now.Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if leavePending(dir, nodeID, now) {
+		t.Fatal("expected an expired leave not to be pending")
+	}
+	if _, err := os.Stat(leavePendingFile(dir, nodeID)); !os.IsNotExist(err) {
+		t.Fatalf("expected the expired mark to be removed, got %v", err)
+	}
+}
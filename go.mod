@@ -18,6 +18,7 @@ require (
 	github.com/hashicorp/go-version v1.6.0
 	github.com/kr/pretty v0.3.1
 	github.com/matryer/is v1.4.1
+	github.com/mdlayher/netlink v1.6.2
 	github.com/pion/logging v0.2.2
 	github.com/pion/turn/v2 v2.1.1-0.20230418114227-f880e55089ad
 	github.com/rhysd/go-github-selfupdate v1.2.3
@@ -83,7 +84,6 @@ require (
 	github.com/mattn/go-isatty v0.0.17 // indirect
 	github.com/mattn/go-sqlite3 v1.14.16 // indirect
 	github.com/mdlayher/genetlink v1.2.0 // indirect
-	github.com/mdlayher/netlink v1.6.2 // indirect
 	github.com/mdlayher/socket v0.2.3 // indirect
 	github.com/mitchellh/mapstructure v1.5.0 // indirect
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
@@ -56,6 +56,10 @@ func SetIPForwardingFreeBSD() error {
 		logger.Log(0, "WARNING: Error encountered setting ip forwarding. You may want to investigate this.")
 		return err
 	}
+	if _, err := ncutils.RunCmd("sysctl -w net.inet6.ip6.forwarding=1", true); err != nil {
+		logger.Log(0, "WARNING: Error encountered setting ipv6 forwarding. You may want to investigate this.")
+		return err
+	}
 	return nil
 }
 
@@ -64,6 +68,11 @@ func SetIPForwardingMac() error {
 	_, err := ncutils.RunCmd("sysctl -w net.inet.ip.forwarding=1", true)
 	if err != nil {
 		logger.Log(0, "WARNING: Error encountered setting ip forwarding. This can break functionality.")
+		return err
+	}
+	_, err = ncutils.RunCmd("sysctl -w net.inet6.ip6.forwarding=1", true)
+	if err != nil {
+		logger.Log(0, "WARNING: Error encountered setting ipv6 forwarding. This can break functionality.")
 	}
 	return err
 }
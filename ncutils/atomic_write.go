@@ -0,0 +1,53 @@
+package ncutils
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// WriteFileAtomic - writes to a temp file next to file and renames it over file once it is synced,
+// so a crash while writing leaves the previous file intact; an existing file keeps its permissions
+func WriteFileAtomic(file string, perm os.FileMode, write func(io.Writer) error) error {
+	tmp := file + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if err := writeAndSync(f, write); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	if info, err := os.Stat(file); err == nil {
+		_ = os.Chmod(tmp, info.Mode().Perm())
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	syncDir(filepath.Dir(file))
+	return nil
+}
+
+// writeAndSync - runs write against f, syncs and closes it
+func writeAndSync(f *os.File, write func(io.Writer) error) error {
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// syncDir - syncs the directory so a rename in it is persisted, not supported on all platforms
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+	_ = d.Sync()
+}
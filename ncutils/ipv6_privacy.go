@@ -0,0 +1,87 @@
+package ncutils
+
+import (
+	"net"
+
+	"github.com/gravitl/netmaker/logger"
+)
+
+// IPv6Addr - an ipv6 address of an interface and whether it is a temporary (privacy extension) address
+type IPv6Addr struct {
+	IP        net.IP
+	Temporary bool
+}
+
+// IsEUI64 - checks if the interface id of an ipv6 address is derived from a mac address
+func IsEUI64(ip net.IP) bool {
+	if ip.To4() != nil || ip.To16() == nil {
+		return false
+	}
+	ip = ip.To16()
+	return ip[11] == 0xff && ip[12] == 0xfe
+}
+
+// PreferStableIPv6 - returns a stable address to use instead of ip when ip is a temporary address of the addrs:
+// the configured address when the interface has it, an eui-64 address of the same /64 otherwise,
+// or any other stable global address of the same /64; ip is returned when it is not temporary or no stable address exists
+func PreferStableIPv6(ip net.IP, addrs []IPv6Addr, configured net.IP) net.IP {
+	temporary := false
+	for _, addr := range addrs {
+		if addr.IP.Equal(ip) {
+			temporary = addr.Temporary
+			break
+		}
+	}
+	if !temporary {
+		return ip
+	}
+	prefix := net.CIDRMask(64, 128)
+	var fallback net.IP
+	for _, addr := range addrs {
+		if addr.Temporary || !addr.IP.IsGlobalUnicast() || addr.IP.To4() != nil {
+			continue
+		}
+		if configured != nil && addr.IP.Equal(configured) {
+			return addr.IP
+		}
+		if !addr.IP.Mask(prefix).Equal(ip.Mask(prefix)) {
+			continue
+		}
+		if IsEUI64(addr.IP) {
+			if configured == nil {
+				return addr.IP
+			}
+			fallback = addr.IP
+		} else if fallback == nil {
+			fallback = addr.IP
+		}
+	}
+	if fallback != nil {
+		return fallback
+	}
+	return ip
+}
+
+// StableSourceIP - returns the stable address to use instead of a temporary ipv6 source address,
+// configured is the preferred stable address, if any
+func StableSourceIP(ip net.IP, configured string) net.IP {
+	if ip == nil || ip.To4() != nil {
+		return ip
+	}
+	addrs, err := ifaceIPv6Addrs(ip)
+	if err != nil {
+		logger.Log(2, "failed to get ipv6 addresses of", ip.String(), err.Error())
+		return ip
+	}
+	stable := PreferStableIPv6(ip, addrs, net.ParseIP(configured))
+	if stable.Equal(ip) {
+		for _, addr := range addrs {
+			if addr.Temporary && addr.IP.Equal(ip) {
+				logger.Log(0, "using temporary ipv6 address", ip.String(), "as no stable address is available")
+			}
+		}
+		return ip
+	}
+	logger.Log(1, "temporary ipv6 address", ip.String(), "would be used, using stable address", stable.String())
+	return stable
+}
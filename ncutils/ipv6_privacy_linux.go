@@ -0,0 +1,33 @@
+package ncutils
+
+import (
+	"errors"
+	"net"
+
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// ifaceIPv6Addrs - returns the ipv6 addresses of the interface holding ip, flagging the temporary ones
+func ifaceIPv6Addrs(ip net.IP) ([]IPv6Addr, error) {
+	links, err := netlink.LinkList()
+	if err != nil {
+		return nil, err
+	}
+	for _, link := range links {
+		linkAddrs, err := netlink.AddrList(link, netlink.FAMILY_V6)
+		if err != nil {
+			continue
+		}
+		addrs := []IPv6Addr{}
+		found := false
+		for _, addr := range linkAddrs {
+			found = found || addr.IP.Equal(ip)
+			addrs = append(addrs, IPv6Addr{IP: addr.IP, Temporary: addr.Flags&unix.IFA_F_TEMPORARY != 0})
+		}
+		if found {
+			return addrs, nil
+		}
+	}
+	return nil, errors.New("no interface has the address")
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package ncutils
+
+import "net"
+
+// ifaceIPv6Addrs - temporary addresses are not detected on this platform, no addresses are returned
+func ifaceIPv6Addrs(ip net.IP) ([]IPv6Addr, error) {
+	return nil, nil
+}
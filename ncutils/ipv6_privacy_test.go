@@ -0,0 +1,40 @@
+package ncutils
+
+import (
+	"net"
+	"testing"
+)
+
+func TestPreferStableIPv6(t *testing.T) {
+	temporary := net.ParseIP("2001:db8:1:2:6d1c:98a1:3b2f:10e4")
+	eui64 := net.ParseIP("2001:db8:1:2:211:22ff:fe33:4455")
+	stablePrivacy := net.ParseIP("2001:db8:1:2:a8b3:2c4d:9e01:7f22")
+	otherPrefix := net.ParseIP("2001:db8:9:9::10")
+	addrs := []IPv6Addr{
+		{IP: net.ParseIP("fe80::211:22ff:fe33:4455")},
+		{IP: temporary, Temporary: true},
+		{IP: stablePrivacy},
+		{IP: eui64},
+		{IP: otherPrefix},
+	}
+	if !IsEUI64(eui64) || IsEUI64(temporary) {
+		t.Fatal("eui-64 detection failed")
+	}
+	if got := PreferStableIPv6(temporary, addrs, nil); !got.Equal(eui64) {
+		t.Errorf("expected eui-64 address %s, got %s", eui64, got)
+	}
+	if got := PreferStableIPv6(temporary, addrs, otherPrefix); !got.Equal(otherPrefix) {
+		t.Errorf("expected configured address %s, got %s", otherPrefix, got)
+	}
+	if got := PreferStableIPv6(stablePrivacy, addrs, nil); !got.Equal(stablePrivacy) {
+		t.Errorf("expected stable address to be kept, got %s", got)
+	}
+	// no eui-64 address, any stable address of the prefix is used
+	if got := PreferStableIPv6(temporary, addrs[:3], nil); !got.Equal(stablePrivacy) {
+		t.Errorf("expected stable address %s, got %s", stablePrivacy, got)
+	}
+	// only temporary addresses, the temporary one is used
+	if got := PreferStableIPv6(temporary, addrs[:2], nil); !got.Equal(temporary) {
+		t.Errorf("expected temporary address %s, got %s", temporary, got)
+	}
+}
@@ -0,0 +1,75 @@
+package ncutils
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gravitl/netmaker/logger"
+)
+
+// maxLimitedMessages - distinct messages tracked by a log limiter before messages outside their window are dropped
+const maxLimitedMessages = 1024
+
+// LogLimiter - collapses identical log messages logged within a window into a single line,
+// a repeated message is logged once and reported as "message (repeated N times)" with the next
+// occurrence after the window
+type LogLimiter struct {
+	window time.Duration
+	mu     sync.Mutex
+	seen   map[string]*limitedMessage
+	now    func() time.Time
+	log    func(level int, message ...string)
+}
+
+type limitedMessage struct {
+	since    time.Time
+	repeated int
+}
+
+// NewLogLimiter - creates a log limiter collapsing identical messages within the window
+func NewLogLimiter(window time.Duration) *LogLimiter {
+	return &LogLimiter{
+		window: window,
+		seen:   make(map[string]*limitedMessage),
+		now:    time.Now,
+		log:    logger.Log,
+	}
+}
+
+// LogLimiter.Log - logs the message at the level unless it was already logged within the window
+func (l *LogLimiter) Log(level int, message ...string) {
+	text := strings.Join(message, " ")
+	key := fmt.Sprint(level, ":", text)
+	l.mu.Lock()
+	now := l.now()
+	entry, ok := l.seen[key]
+	if ok && now.Sub(entry.since) < l.window {
+		entry.repeated++
+		l.mu.Unlock()
+		return
+	}
+	repeated := 0
+	if ok {
+		repeated = entry.repeated
+	}
+	if !ok && len(l.seen) >= maxLimitedMessages {
+		l.expire(now)
+	}
+	l.seen[key] = &limitedMessage{since: now}
+	l.mu.Unlock()
+	if repeated > 0 {
+		text = fmt.Sprintf("%s (repeated %d times)", text, repeated)
+	}
+	l.log(level, text)
+}
+
+// LogLimiter.expire - drops the messages outside their window, caller holds the lock
+func (l *LogLimiter) expire(now time.Time) {
+	for key, entry := range l.seen {
+		if now.Sub(entry.since) >= l.window {
+			delete(l.seen, key)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package ncutils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLogLimiter(t *testing.T) {
+	now := time.Now()
+	var logged []string
+	l := NewLogLimiter(time.Minute)
+	l.now = func() time.Time { return now }
+	l.log = func(level int, message ...string) {
+		logged = append(logged, message...)
+	}
+
+	for i := 0; i < 5; i++ {
+		l.Log(1, "failed to add rule:", "netlink down")
+	}
+	l.Log(1, "failed to send to remote")
+	if len(logged) != 2 || logged[0] != "failed to add rule: netlink down" {
+		t.Fatalf("expected repeated messages to be collapsed, logged %q", logged)
+	}
+
+	now = now.Add(time.Minute)
+	l.Log(1, "failed to add rule:", "netlink down")
+	if len(logged) != 3 || logged[2] != "failed to add rule: netlink down (repeated 4 times)" {
+		t.Fatalf("expected the repeat count after the window, logged %q", logged)
+	}
+	l.Log(1, "failed to add rule:", "netlink down")
+	if len(logged) != 3 {
+		t.Fatalf("expected a new window to be started, logged %q", logged)
+	}
+}
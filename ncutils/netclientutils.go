@@ -290,6 +290,22 @@ func GetFileWithRetry(path string, retryCount int) ([]byte, error) {
 	return data, err
 }
 
+// RetryWithBackoff - calls fn until it succeeds or the attempts are exhausted, doubling the wait between attempts
+func RetryWithBackoff(attempts int, backoff time.Duration, fn func() error) error {
+	var err error
+	for count := 0; count < attempts; count++ {
+		if err = fn(); err == nil {
+			return nil
+		}
+		if count < attempts-1 {
+			logger.Log(1, "attempt", strconv.Itoa(count+1), "failed:", err.Error(), "retrying in", backoff.String())
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+	}
+	return err
+}
+
 func CheckIPAddress(ip string) error {
 	if net.ParseIP(ip) == nil {
 		return fmt.Errorf("ip address %s is invalid", ip)
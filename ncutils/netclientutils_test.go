@@ -1,6 +1,10 @@
 package ncutils
 
-import "testing"
+import (
+	"errors"
+	"testing"
+	"time"
+)
 
 func TestRadomMacAddress(t *testing.T) {
 	mac := RandomMacAddress()
@@ -8,3 +12,25 @@ func TestRadomMacAddress(t *testing.T) {
 		t.Error("empty mac Address")
 	}
 }
+
+func TestRetryWithBackoff(t *testing.T) {
+	calls := 0
+	err := RetryWithBackoff(3, time.Millisecond, func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("write failed")
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("expected success after 3 calls, got %d calls, err %v", calls, err)
+	}
+	calls = 0
+	err = RetryWithBackoff(2, time.Millisecond, func() error {
+		calls++
+		return errors.New("write failed")
+	})
+	if err == nil || calls != 2 {
+		t.Errorf("expected failure after 2 calls, got %d calls, err %v", calls, err)
+	}
+}
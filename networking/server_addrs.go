@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net"
 	"strings"
+	"sync"
 
 	"github.com/gravitl/netclient/cache"
 	"github.com/gravitl/netclient/config"
@@ -12,6 +13,8 @@ import (
 var (
 	foundIPSet = make(map[string]struct{}, 0)
 	addresses  = []net.IPNet{}
+	// addrMutex - guards the found addresses, servers are set up in parallel
+	addrMutex sync.Mutex
 )
 
 // StoreServerAddresses - given a server,
@@ -69,7 +72,9 @@ func StoreServerAddresses(server *config.Server) {
 			}
 		}
 	}
-	cache.ServerAddrCache.Store(server.Name, addresses)
+	addrMutex.Lock()
+	defer addrMutex.Unlock()
+	cache.ServerAddrCache.Store(server.Name, append([]net.IPNet{}, addresses...))
 }
 
 // GetServerAddrs - retrieves the addresses of given server
@@ -84,6 +89,8 @@ func GetServerAddrs(serverName string) []net.IPNet {
 }
 
 func processIPv4(ipv4 net.IP) {
+	addrMutex.Lock()
+	defer addrMutex.Unlock()
 	if _, ok := foundIPSet[ipv4.String()]; !ok {
 		_, cidr6, err := net.ParseCIDR(fmt.Sprintf("%s/32", ipv4.String()))
 		if err == nil {
@@ -94,6 +101,8 @@ func processIPv4(ipv4 net.IP) {
 }
 
 func processIPv6(ipv6 net.IP) {
+	addrMutex.Lock()
+	defer addrMutex.Unlock()
 	if _, ok := foundIPSet[ipv6.String()]; !ok {
 		_, cidr6, err := net.ParseCIDR(fmt.Sprintf("%s/128", ipv6.String()))
 		if err == nil {
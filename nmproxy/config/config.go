@@ -33,6 +33,7 @@ type Config struct {
 	serverConn              *net.UDPConn
 	fireWallStatus          bool
 	fireWallClose           func()
+	localProxyPort          int
 }
 type proxyPeerConn struct {
 	PeerPublicKey       string `json:"peer_public_key"`
@@ -191,6 +192,31 @@ func (c *Config) GetRelayedStatus(server string) bool {
 	return c.GetSettings(server).IsRelayed
 }
 
+// Config.SetServerProxyStatus - enables or disables proxying of the peers of a server
+func (c *Config) SetServerProxyStatus(server string, enabled bool) {
+	settings := c.GetSettings(server)
+	settings.ProxyDisabled = !enabled
+	c.UpdateSettings(server, settings)
+}
+
+// Config.IsServerProxyEnabled - checks if the peers of a server may be proxied
+func (c *Config) IsServerProxyEnabled(server string) bool {
+	return !c.GetSettings(server).ProxyDisabled
+}
+
+// Config.IsPeerProxyEnabled - checks if any of the servers the peer belongs to allows proxying it
+func (c *Config) IsPeerProxyEnabled(peer proxyModels.Conn) bool {
+	if peer.IsRelayed || len(peer.ServerMap) == 0 {
+		return true
+	}
+	for server := range peer.ServerMap {
+		if c.IsServerProxyEnabled(server) {
+			return true
+		}
+	}
+	return false
+}
+
 // NatAutoSwitchDone - check if nat automatically switched on already for devices behind NAT
 func NatAutoSwitchDone() bool {
 	return natAutoSwitch
@@ -216,6 +242,19 @@ func (c *Config) SetServerConn(conn *net.UDPConn) {
 	c.serverConn = conn
 }
 
+// Config.SetLocalProxyPort - sets the source port for local proxy connections
+func (c *Config) SetLocalProxyPort(port int) {
+	c.localProxyPort = port
+}
+
+// Config.GetLocalProxyPort - gets the source port for local proxy connections
+func (c *Config) GetLocalProxyPort() int {
+	if c.localProxyPort == 0 {
+		return proxyModels.NmProxyPort
+	}
+	return c.localProxyPort
+}
+
 // Config.SetFwStatus - sets the firewall status
 func (c *Config) SetFwStatus(s bool) {
 	c.fireWallStatus = s
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// policies for peers beyond the proxy connection limit
+const (
+	// ProxyConnLimitRejectNew - new peers are not proxied and stay on direct/turn connections
+	ProxyConnLimitRejectNew = "reject-new"
+	// ProxyConnLimitEvictIdle - the least recently active proxied peer is evicted for the new peer
+	ProxyConnLimitEvictIdle = "evict-idle"
+)
+
+// ErrProxyConnLimit - returned when a peer is not proxied because of the connection limit
+var ErrProxyConnLimit = errors.New("proxy connection limit reached")
+
+// proxyPeerActivity - last activity of the proxied peers as *atomic.Int64 unix nanoseconds, indexed by peer key,
+// it is updated for every packet so the peers don't share a lock
+var proxyPeerActivity = new(sync.Map)
+
+// MarkPeerActive - records traffic on the peer's proxy connection
+func MarkPeerActive(peerKey string) {
+	now := time.Now().UnixNano()
+	if lastActive, ok := proxyPeerActivity.Load(peerKey); ok {
+		lastActive.(*atomic.Int64).Store(now)
+		return
+	}
+	lastActive := new(atomic.Int64)
+	lastActive.Store(now)
+	proxyPeerActivity.Store(peerKey, lastActive)
+}
+
+func forgetPeerActivity(peerKey string) {
+	proxyPeerActivity.Delete(peerKey)
+}
+
+// peerActivitySnapshot - returns the last activity of the given peers
+func peerActivitySnapshot(peerKeys []string) map[string]time.Time {
+	snapshot := make(map[string]time.Time, len(peerKeys))
+	for _, key := range peerKeys {
+		if lastActive, ok := proxyPeerActivity.Load(key); ok {
+			snapshot[key] = time.Unix(0, lastActive.(*atomic.Int64).Load())
+		}
+	}
+	return snapshot
+}
+
+// selectEviction - decides if a new peer can be proxied alongside the active peers,
+// returning the peer to evict when the policy frees up a slot
+func selectEviction(peerKey string, active []string, lastActive map[string]time.Time,
+	maxConns int, policy string) (admit bool, evict string) {
+	if maxConns <= 0 || len(active) < maxConns {
+		return true, ""
+	}
+	for _, key := range active {
+		if key == peerKey {
+			return true, ""
+		}
+	}
+	if policy != ProxyConnLimitEvictIdle {
+		return false, ""
+	}
+	for _, key := range active {
+		if evict == "" || lastActive[key].Before(lastActive[evict]) {
+			evict = key
+		}
+	}
+	return true, evict
+}
+
+// Config.AdmitProxyPeer - checks the connection limit for a new proxy peer,
+// returns whether it can be proxied and the peer to evict for it, if any
+func (c *Config) AdmitProxyPeer(peerKey string, maxConns int, policy string) (bool, string) {
+	c.mutex.RLock()
+	active := make([]string, 0, len(c.ifaceConfig.proxyPeerMap))
+	for key := range c.ifaceConfig.proxyPeerMap {
+		active = append(active, key)
+	}
+	c.mutex.RUnlock()
+	admit, evict := selectEviction(peerKey, active, peerActivitySnapshot(active), maxConns, policy)
+	if admit {
+		MarkPeerActive(peerKey)
+	}
+	return admit, evict
+}
+
+// Config.GetProxyConnCount - returns the number of active proxy connections
+func (c *Config) GetProxyConnCount() int {
+	if c == nil || c.mutex == nil {
+		return 0
+	}
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return len(c.ifaceConfig.proxyPeerMap)
+}
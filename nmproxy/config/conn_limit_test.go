@@ -0,0 +1,58 @@
+package config
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gravitl/netclient/nmproxy/models"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestSelectEviction(t *testing.T) {
+	now := time.Now()
+	active := []string{"a", "b", "c"}
+	lastActive := map[string]time.Time{
+		"a": now,
+		"b": now.Add(-time.Hour),
+		"c": now.Add(-time.Minute),
+	}
+	if admit, evict := selectEviction("d", active, lastActive, 0, ProxyConnLimitRejectNew); !admit || evict != "" {
+		t.Fatal("expected no limit when the cap is unset")
+	}
+	if admit, evict := selectEviction("d", active, lastActive, 4, ProxyConnLimitRejectNew); !admit || evict != "" {
+		t.Fatal("expected peer to be admitted below the cap")
+	}
+	if admit, _ := selectEviction("d", active, lastActive, 3, ProxyConnLimitRejectNew); admit {
+		t.Fatal("expected peer beyond the cap to be rejected")
+	}
+	if admit, evict := selectEviction("a", active, lastActive, 3, ProxyConnLimitRejectNew); !admit || evict != "" {
+		t.Fatal("expected an already proxied peer to be admitted at the cap")
+	}
+	admit, evict := selectEviction("d", active, lastActive, 3, ProxyConnLimitEvictIdle)
+	if !admit || evict != "b" {
+		t.Fatalf("expected least recently active peer b to be evicted, got %v %s", admit, evict)
+	}
+}
+
+func TestProxyConnCountConcurrent(t *testing.T) {
+	InitializeCfg()
+	defer Reset()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			GetCfg().GetProxyConnCount()
+			GetCfg().AdmitProxyPeer("reader", 10, ProxyConnLimitEvictIdle)
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		priv, _ := wgtypes.GeneratePrivateKey()
+		GetCfg().SavePeer(&models.Conn{Key: priv.PublicKey(), Mutex: &sync.RWMutex{}})
+		MarkPeerActive(priv.PublicKey().String())
+	}
+	<-done
+	if count := GetCfg().GetProxyConnCount(); count != 100 {
+		t.Fatalf("expected 100 proxy connections, got %d", count)
+	}
+}
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"sync"
+	"time"
+
+	proxyModels "github.com/gravitl/netclient/nmproxy/models"
+	"github.com/gravitl/netmaker/logger"
+)
+
+// ConnModeChangeInterval - minimum interval between connection mode changes reported for a peer
+const ConnModeChangeInterval = time.Second * 30
+
+var (
+	// ConnModeChangeChan - channel to report connection mode changes of peers to the server
+	ConnModeChangeChan = make(chan proxyModels.ConnModeChange, 50)
+	connModeLimiter    = newConnModeRateLimiter(queueConnModeChange)
+)
+
+// connModeRateLimiter - reports at most one connection mode change per peer and interval,
+// changes within the interval are coalesced into the latest one and reported when it expires
+type connModeRateLimiter struct {
+	mutex    sync.Mutex
+	lastSent map[string]time.Time
+	pending  map[string]proxyModels.ConnModeChange
+	timers   map[string]*time.Timer
+	send     func(proxyModels.ConnModeChange)
+}
+
+func newConnModeRateLimiter(send func(proxyModels.ConnModeChange)) *connModeRateLimiter {
+	return &connModeRateLimiter{
+		lastSent: make(map[string]time.Time),
+		pending:  make(map[string]proxyModels.ConnModeChange),
+		timers:   make(map[string]*time.Timer),
+		send:     send,
+	}
+}
+
+// connModeRateLimiter.offer - reports the change if the peer's interval allows it, otherwise keeps it as the
+// peer's pending change, starting from the mode of an earlier pending change, until the interval expires
+func (r *connModeRateLimiter) offer(change proxyModels.ConnModeChange, now time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	last, ok := r.lastSent[change.PeerKey]
+	if !ok || now.Sub(last) >= ConnModeChangeInterval {
+		r.lastSent[change.PeerKey] = now
+		r.send(change)
+		return
+	}
+	logger.Log(3, "rate limited connection mode change for peer", change.PeerKey)
+	if prev, ok := r.pending[change.PeerKey]; ok {
+		change.OldMode = prev.OldMode
+	}
+	r.pending[change.PeerKey] = change
+	if _, ok := r.timers[change.PeerKey]; !ok {
+		peerKey := change.PeerKey
+		r.timers[peerKey] = time.AfterFunc(last.Add(ConnModeChangeInterval).Sub(now), func() {
+			r.flush(peerKey, time.Now())
+		})
+	}
+}
+
+// connModeRateLimiter.flush - reports the pending change of the peer, unless the peer returned to the mode
+// it was last reported in
+func (r *connModeRateLimiter) flush(peerKey string, now time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.timers, peerKey)
+	change, ok := r.pending[peerKey]
+	if !ok {
+		return
+	}
+	delete(r.pending, peerKey)
+	if change.OldMode == change.NewMode {
+		return
+	}
+	r.lastSent[peerKey] = now
+	r.send(change)
+}
+
+// connModeRateLimiter.forget - drops the state of a removed peer
+func (r *connModeRateLimiter) forget(peerKey string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if timer, ok := r.timers[peerKey]; ok {
+		timer.Stop()
+		delete(r.timers, peerKey)
+	}
+	delete(r.pending, peerKey)
+	delete(r.lastSent, peerKey)
+}
+
+// queueConnModeChange - queues the change to be reported to the server without blocking
+func queueConnModeChange(change proxyModels.ConnModeChange) {
+	select {
+	case ConnModeChangeChan <- change:
+	default:
+		logger.Log(1, "connection mode change channel is full, dropping change for peer", change.PeerKey)
+	}
+}
+
+// ConnMode - returns the connection mode of a proxied peer
+func ConnMode(isRelayed, usingTurn bool) string {
+	if usingTurn {
+		return proxyModels.ConnModeTurn
+	}
+	if isRelayed {
+		return proxyModels.ConnModeRelay
+	}
+	return proxyModels.ConnModeProxy
+}
+
+// NotifyConnModeChange - queues a connection mode change of the peer to be reported to the server,
+// changes are rate limited and coalesced per peer
+func NotifyConnModeChange(change proxyModels.ConnModeChange) {
+	if change.OldMode == change.NewMode {
+		return
+	}
+	logger.Log(1, "peer", change.PeerKey, "connection mode changed from", change.OldMode, "to", change.NewMode, ":", change.Reason)
+	connModeLimiter.offer(change, time.Now())
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	proxyModels "github.com/gravitl/netclient/nmproxy/models"
+	"github.com/matryer/is"
+)
+
+func TestNotifyConnModeChange(t *testing.T) {
+	is := is.New(t)
+	drain := func() (changes []proxyModels.ConnModeChange) {
+		for {
+			select {
+			case change := <-ConnModeChangeChan:
+				changes = append(changes, change)
+			default:
+				return
+			}
+		}
+	}
+	drain()
+	t.Run("transition is published", func(t *testing.T) {
+		NotifyConnModeChange(proxyModels.ConnModeChange{Server: "server", PeerKey: "peer1",
+			OldMode: proxyModels.ConnModeDirect, NewMode: proxyModels.ConnModeProxy, Reason: "test"})
+		changes := drain()
+		is.Equal(len(changes), 1)
+		is.Equal(changes[0].NewMode, proxyModels.ConnModeProxy)
+	})
+	t.Run("unchanged mode is not published", func(t *testing.T) {
+		NotifyConnModeChange(proxyModels.ConnModeChange{Server: "server", PeerKey: "peer2",
+			OldMode: proxyModels.ConnModeProxy, NewMode: proxyModels.ConnModeProxy})
+		is.Equal(len(drain()), 0)
+	})
+	t.Run("transitions are rate limited per peer", func(t *testing.T) {
+		NotifyConnModeChange(proxyModels.ConnModeChange{Server: "server", PeerKey: "peer1",
+			OldMode: proxyModels.ConnModeProxy, NewMode: proxyModels.ConnModeTurn})
+		is.Equal(len(drain()), 0)
+		NotifyConnModeChange(proxyModels.ConnModeChange{Server: "server", PeerKey: "peer3",
+			OldMode: proxyModels.ConnModeProxy, NewMode: proxyModels.ConnModeTurn})
+		is.Equal(len(drain()), 1)
+	})
+	t.Run("rate limited transitions are coalesced", func(t *testing.T) {
+		sent := []proxyModels.ConnModeChange{}
+		limiter := newConnModeRateLimiter(func(change proxyModels.ConnModeChange) { sent = append(sent, change) })
+		now := time.Now()
+		limiter.offer(proxyModels.ConnModeChange{PeerKey: "peer", OldMode: proxyModels.ConnModeDirect, NewMode: proxyModels.ConnModeProxy}, now)
+		limiter.offer(proxyModels.ConnModeChange{PeerKey: "peer", OldMode: proxyModels.ConnModeProxy, NewMode: proxyModels.ConnModeTurn}, now)
+		limiter.offer(proxyModels.ConnModeChange{PeerKey: "peer", OldMode: proxyModels.ConnModeTurn, NewMode: proxyModels.ConnModeRelay}, now)
+		is.Equal(len(sent), 1)
+		limiter.flush("peer", now.Add(ConnModeChangeInterval))
+		is.Equal(len(sent), 2)
+		is.Equal(sent[1].OldMode, proxyModels.ConnModeProxy) // from the last reported mode
+		is.Equal(sent[1].NewMode, proxyModels.ConnModeRelay) // to the latest one
+		// a peer flapping back to the reported mode within the interval reports nothing
+		now = now.Add(ConnModeChangeInterval)
+		limiter.offer(proxyModels.ConnModeChange{PeerKey: "peer", OldMode: proxyModels.ConnModeRelay, NewMode: proxyModels.ConnModeTurn}, now)
+		limiter.offer(proxyModels.ConnModeChange{PeerKey: "peer", OldMode: proxyModels.ConnModeTurn, NewMode: proxyModels.ConnModeRelay}, now)
+		limiter.flush("peer", now.Add(ConnModeChangeInterval))
+		is.Equal(len(sent), 2)
+		// after the interval the change is reported right away
+		limiter.offer(proxyModels.ConnModeChange{PeerKey: "peer", OldMode: proxyModels.ConnModeRelay, NewMode: proxyModels.ConnModeDirect}, now.Add(ConnModeChangeInterval))
+		is.Equal(len(sent), 3)
+	})
+	t.Run("removed peers are forgotten", func(t *testing.T) {
+		limiter := newConnModeRateLimiter(func(proxyModels.ConnModeChange) {})
+		now := time.Now()
+		limiter.offer(proxyModels.ConnModeChange{PeerKey: "peer", OldMode: proxyModels.ConnModeDirect, NewMode: proxyModels.ConnModeProxy}, now)
+		limiter.offer(proxyModels.ConnModeChange{PeerKey: "peer", OldMode: proxyModels.ConnModeProxy, NewMode: proxyModels.ConnModeTurn}, now)
+		limiter.forget("peer")
+		is.Equal(len(limiter.lastSent), 0)
+		is.Equal(len(limiter.pending), 0)
+		is.Equal(len(limiter.timers), 0)
+	})
+}
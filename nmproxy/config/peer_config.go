@@ -97,12 +97,16 @@ func (c *Config) GetAllProxyPeers() models.PeerConnMap {
 // Config.UpdateProxyPeers - updates all peers in the network
 func (c *Config) UpdateProxyPeers(peers *models.PeerConnMap) {
 	if peers != nil {
+		c.mutex.Lock()
 		c.ifaceConfig.proxyPeerMap = *peers
+		c.mutex.Unlock()
 	}
 }
 
 // Config.SavePeer - saves peer to the config
 func (c *Config) SavePeer(connConf *models.Conn) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
 	c.ifaceConfig.proxyPeerMap[connConf.Key.String()] = connConf
 }
 
@@ -121,7 +125,9 @@ func (c *Config) UpdatePeer(updatedPeer *models.Conn) {
 
 	if peerConf, found := c.ifaceConfig.proxyPeerMap[updatedPeer.Key.String()]; found {
 		peerConf.Mutex.Lock()
+		c.mutex.Lock()
 		c.ifaceConfig.proxyPeerMap[updatedPeer.Key.String()] = updatedPeer
+		c.mutex.Unlock()
 		peerConf.Mutex.Unlock()
 	}
 }
@@ -146,14 +152,25 @@ func (c *Config) RemovePeer(peerPubKey string) {
 		peerConf.Mutex.Lock()
 		peerConf.StopConn()
 		peerConf.Mutex.Unlock()
+		c.mutex.Lock()
 		delete(c.ifaceConfig.proxyPeerMap, peerPubKey)
+		c.mutex.Unlock()
 		GetCfg().DeletePeerHash(peerConf.Key.String())
 		GetCfg().DeletePeerTurnCfg(peerPubKey)
+		forgetPeerActivity(peerPubKey)
+		connModeLimiter.forget(peerPubKey)
 
 	}
 
 }
 
+// Config.DeleteProxyPeer - removes the peer from the proxy peer map, its connection is stopped by the caller
+func (c *Config) DeleteProxyPeer(peerPubKey string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.ifaceConfig.proxyPeerMap, peerPubKey)
+}
+
 // Config.UpdatePeerNetwork - updates the peer network settings map
 func (c *Config) UpdatePeerNetwork(peerPubKey, network string, setting models.Settings) {
 	if peerConf, found := c.ifaceConfig.proxyPeerMap[peerPubKey]; found {
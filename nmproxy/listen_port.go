@@ -0,0 +1,65 @@
+package nmproxy
+
+import (
+	"errors"
+	"fmt"
+
+	ncconfig "github.com/gravitl/netclient/config"
+	"github.com/gravitl/netclient/ncutils"
+	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/models"
+)
+
+// ValidateProxyListenPort - returns the configured proxy listen port if it can be bound, otherwise a free port
+// which is written back to the config, so stun and the proxy server use the same port
+func ValidateProxyListenPort() int {
+	return ensureListenPort(ncconfig.Netclient().ProxyListenPort)
+}
+
+// ensureListenPort - returns port if it can be bound, otherwise picks a free one and records it as the
+// host's proxy listen port, so the next check-in reports it to the server
+func ensureListenPort(port int) int {
+	if port == 0 {
+		port = models.NmProxyPort
+	}
+	chosen, err := selectListenPort(port, ncconfig.Netclient().ListenPort, ncconfig.Netclient().ProxyLocalPort)
+	if err != nil {
+		logger.Log(0, "failed to find a free proxy listen port: ", err.Error())
+		return port
+	}
+	if chosen == port && ncconfig.Netclient().ProxyListenPort == port {
+		return port
+	}
+	if chosen != port {
+		logger.Log(0, fmt.Sprintf("proxy listen port %d is in use, using %d", port, chosen))
+	}
+	ncconfig.Netclient().ProxyListenPort = chosen
+	if err := ncconfig.WriteNetclientConfig(); err != nil {
+		logger.Log(0, "failed to persist proxy listen port: ", err.Error())
+	}
+	return chosen
+}
+
+// selectListenPort - returns port if it can be bound, otherwise the next free port which is not reserved
+func selectListenPort(port int, reserved ...int) (int, error) {
+	for port <= 65535 {
+		free, err := ncutils.GetFreePort(port)
+		if err != nil {
+			return 0, err
+		}
+		if !isReservedPort(free, reserved) {
+			return free, nil
+		}
+		port = free + 1
+	}
+	return 0, errors.New("no free ports")
+}
+
+func isReservedPort(port int, reserved []int) bool {
+	for _, r := range reserved {
+		if r != 0 && r == port {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,145 @@
+package manager
+
+import (
+	ncconfig "github.com/gravitl/netclient/config"
+	"github.com/gravitl/netclient/local"
+	"github.com/gravitl/netclient/nmproxy/config"
+	"github.com/gravitl/netclient/nmproxy/packet"
+	"github.com/gravitl/netclient/nmproxy/router"
+	"github.com/gravitl/netmaker/logger"
+)
+
+// gateway roles the server assigns to the host
+const (
+	roleIngress = "ingress"
+	roleEgress  = "egress"
+)
+
+// gwRoles - the gateway roles of the host for a server
+type gwRoles struct {
+	ingress bool
+	egress  bool
+}
+
+// roleTransition - a gateway role the host gained or lost with an update
+type roleTransition struct {
+	role  string
+	added bool
+}
+
+// roleState - gateway roles of the host by server as of the last applied update
+var roleState = struct {
+	roles map[string]gwRoles
+	// forwardingOff - ip forwarding was turned off as the host was no longer a gateway
+	forwardingOff bool
+}{
+	roles: make(map[string]gwRoles),
+}
+
+// roleTransitions - returns the roles added and removed between two updates
+func roleTransitions(prev, next gwRoles) []roleTransition {
+	transitions := []roleTransition{}
+	if prev.ingress != next.ingress {
+		transitions = append(transitions, roleTransition{role: roleIngress, added: next.ingress})
+	}
+	if prev.egress != next.egress {
+		transitions = append(transitions, roleTransition{role: roleEgress, added: next.egress})
+	}
+	return transitions
+}
+
+// prevRoles - returns the roles of the server as of the last update, a server without one is assumed
+// to have had every role so the rules left by a previous run are torn down
+func prevRoles(server string) gwRoles {
+	if roles, ok := roleState.roles[server]; ok {
+		return roles
+	}
+	return gwRoles{ingress: true, egress: true}
+}
+
+// anyGateway - checks if the host is a gateway for any server
+func anyGateway(roles map[string]gwRoles) bool {
+	for _, r := range roles {
+		if r.ingress || r.egress {
+			return true
+		}
+	}
+	return false
+}
+
+// applyRoleTransitions - records the roles of the server, tearing down the rules of the removed roles,
+// teardown is called with the role for each one removed
+func applyRoleTransitions(server string, next gwRoles, teardown func(role string)) []roleTransition {
+	transitions := roleTransitions(prevRoles(server), next)
+	roleState.roles[server] = next
+	for _, t := range transitions {
+		if t.added {
+			logger.Log(0, "server", server, "added gateway role:", t.role)
+			continue
+		}
+		logger.Log(0, "server", server, "removed gateway role:", t.role)
+		teardown(t.role)
+	}
+	return transitions
+}
+
+// forgetServerRoles - drops the roles recorded for a removed server, tearing down the rules of the roles it had,
+// returns false if no roles were recorded for the server
+func forgetServerRoles(server string, teardown func(role string)) bool {
+	roles, ok := roleState.roles[server]
+	if !ok {
+		return false
+	}
+	delete(roleState.roles, server)
+	for _, t := range roleTransitions(roles, gwRoles{}) {
+		logger.Log(0, "server", server, "removed, dropping gateway role:", t.role)
+		teardown(t.role)
+	}
+	return true
+}
+
+// ForgetServer - drops the gateway roles of a removed server so the host stops counting as its gateway,
+// must be run on the proxy manager loop
+func ForgetServer(server string) {
+	teardown := func(string) {}
+	if config.GetCfg().GetFwStatus() {
+		teardown = teardownRole(server)
+	}
+	if forgetServerRoles(server, teardown) {
+		updateForwarding(config.GetCfg().IsGlobalRelay())
+	}
+}
+
+// teardownRole - removes the rules of a gateway role of the server
+func teardownRole(server string) func(role string) {
+	return func(role string) {
+		switch role {
+		case roleIngress:
+			router.DeleteIngressRules(server)
+		case roleEgress:
+			router.DeleteEgressGwRoutes(server)
+		}
+	}
+}
+
+// updateForwarding - when configured, turns ip forwarding off once the host is no longer a gateway or relay
+// and back on when it becomes one again
+func updateForwarding(isRelay bool) {
+	if !ncconfig.Netclient().DisableForwardingWithoutGateway {
+		return
+	}
+	needed := isRelay || anyGateway(roleState.roles)
+	switch {
+	case !needed && !roleState.forwardingOff:
+		logger.Log(0, "host is no longer a gateway, turning off ip forwarding")
+		packet.TurnOffIpFowarding()
+		roleState.forwardingOff = true
+	case needed && roleState.forwardingOff:
+		logger.Log(0, "host is a gateway again, turning on ip forwarding")
+		if err := local.SetIPForwarding(); err != nil {
+			logger.Log(0, "failed to turn on ip forwarding: ", err.Error())
+			return
+		}
+		roleState.forwardingOff = false
+	}
+}
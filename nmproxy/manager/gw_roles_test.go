@@ -0,0 +1,55 @@
+package manager
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyRoleTransitions(t *testing.T) {
+	defer func() { delete(roleState.roles, "server") }()
+	torn := []string{}
+	teardown := func(role string) { torn = append(torn, role) }
+
+	// rules of a previous run are torn down on the first update without the roles
+	applyRoleTransitions("server", gwRoles{ingress: true}, teardown)
+	if !reflect.DeepEqual(torn, []string{roleEgress}) {
+		t.Fatalf("expected egress teardown on the first update, got %v", torn)
+	}
+	torn = nil
+	transitions := applyRoleTransitions("server", gwRoles{ingress: true, egress: true}, teardown)
+	if len(torn) != 0 || !reflect.DeepEqual(transitions, []roleTransition{{role: roleEgress, added: true}}) {
+		t.Fatalf("expected egress to be added without teardown, got %v torn %v", transitions, torn)
+	}
+	transitions = applyRoleTransitions("server", gwRoles{ingress: true, egress: true}, teardown)
+	if len(transitions) != 0 || len(torn) != 0 {
+		t.Fatalf("expected no transitions for unchanged roles, got %v", transitions)
+	}
+	applyRoleTransitions("server", gwRoles{}, teardown)
+	if !reflect.DeepEqual(torn, []string{roleIngress, roleEgress}) {
+		t.Fatalf("expected both roles to be torn down, got %v", torn)
+	}
+	if anyGateway(roleState.roles) {
+		t.Fatal("expected the host to no longer be a gateway")
+	}
+}
+
+func TestForgetServerRoles(t *testing.T) {
+	defer func() { delete(roleState.roles, "server"); delete(roleState.roles, "other") }()
+	torn := []string{}
+	teardown := func(role string) { torn = append(torn, role) }
+	roleState.roles["server"] = gwRoles{egress: true}
+	roleState.roles["other"] = gwRoles{}
+
+	if !forgetServerRoles("server", teardown) || !reflect.DeepEqual(torn, []string{roleEgress}) {
+		t.Fatalf("expected the egress role of the removed server to be torn down, got %v", torn)
+	}
+	if _, ok := roleState.roles["server"]; ok {
+		t.Fatal("expected the roles of the removed server to be dropped")
+	}
+	if anyGateway(roleState.roles) {
+		t.Fatal("expected the host to no longer be a gateway once the server was removed")
+	}
+	if forgetServerRoles("server", teardown) || len(torn) != 1 {
+		t.Fatalf("expected nothing to be dropped for a server without roles, got %v", torn)
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"fmt"
 	"net"
 	"sync"
+	"time"
 
+	ncconfig "github.com/gravitl/netclient/config"
 	"github.com/gravitl/netclient/ncutils"
 	"github.com/gravitl/netclient/nmproxy/config"
 	"github.com/gravitl/netclient/nmproxy/models"
@@ -39,15 +41,48 @@ func dumpProxyConnsInfo(ctx context.Context, wg *sync.WaitGroup) {
 	}
 }
 
+// loopRequests - functions run on the manager loop, so reads and resets of the proxy peers don't race with peer updates
+var loopRequests = make(chan func())
+
+// RunOnLoop - runs f on the proxy manager loop and waits for it to return,
+// fails if the loop doesn't pick it up before the timeout
+func RunOnLoop(f func(), timeout time.Duration) error {
+	done := make(chan struct{})
+	req := func() {
+		defer close(done)
+		f()
+	}
+	select {
+	case loopRequests <- req:
+	case <-time.After(timeout):
+		return errors.New("proxy manager is not responding")
+	}
+	<-done
+	return nil
+}
+
 // Start - starts the proxy manager loop and listens for events on the Channel provided
 func Start(ctx context.Context, wg *sync.WaitGroup, managerChan chan *nm_models.HostPeerUpdate) {
 	defer wg.Done()
 	wg.Add(1)
 	go dumpProxyConnsInfo(ctx, wg)
+	// stale peers are checked in the manager loop so resets don't race with peer updates
+	interval, threshold := staleHandshakeSettings()
+	staleTicker := time.NewTicker(interval)
+	defer staleTicker.Stop()
+	lastReset := make(map[string]time.Time)
+	// failed firewall rules are retried on the loop as well, atomic with the firewall updates
+	retryTicker := time.NewTicker(router.RuleRetryInterval)
+	defer retryTicker.Stop()
+	reconcileTicker := time.NewTicker(router.RuleReconcileInterval)
+	defer reconcileTicker.Stop()
 	for {
 		select {
 		case <-ctx.Done():
 			logger.Log(0, "shutting down proxy manager...")
+			if n := drainCleanup(managerChan, configureProxy); n > 0 {
+				logger.Log(1, "applied", fmt.Sprint(n), "pending cleanup update(s) on shutdown")
+			}
 			return
 		case mI := <-managerChan:
 			if mI == nil {
@@ -58,6 +93,40 @@ func Start(ctx context.Context, wg *sync.WaitGroup, managerChan chan *nm_models.
 			if err != nil {
 				logger.Log(1, "failed to configure proxy:  ", err.Error())
 			}
+		case <-staleTicker.C:
+			resetStalePeers(lastReset, threshold)
+		case now := <-retryTicker.C:
+			router.RetryFailedRules(now)
+		case <-reconcileTicker.C:
+			router.ReconcileRules()
+		case req := <-loopRequests:
+			req()
+		}
+	}
+}
+
+// isCleanup - checks if the update removes all proxied peers, such updates are applied even on shutdown
+func isCleanup(update *nm_models.HostPeerUpdate) bool {
+	return update != nil && update.ProxyUpdate.Action == nm_models.ProxyDeleteAllPeers
+}
+
+// drainCleanup - empties the channel without blocking, applying the pending cleanup updates
+// and dropping the regular ones, returns the number of cleanup updates applied
+func drainCleanup(managerChan chan *nm_models.HostPeerUpdate, apply func(*nm_models.HostPeerUpdate) error) int {
+	applied := 0
+	for {
+		select {
+		case mI := <-managerChan:
+			if !isCleanup(mI) {
+				continue
+			}
+			if err := apply(mI); err != nil {
+				logger.Log(1, "failed to apply cleanup update on shutdown: ", err.Error())
+				continue
+			}
+			applied++
+		default:
+			return applied
 		}
 	}
 }
@@ -83,6 +152,7 @@ func configureProxy(payload *nm_models.HostPeerUpdate) error {
 	}
 	config.GetCfg().SetIface(wgIface)
 	config.GetCfg().SetPeersIDsAndAddrs(m.Server, payload.HostPeerIDs)
+	setServerProxyStatus(m)
 	startMetricsThread(payload) // starts or stops the metrics collection based on host proxy setting
 	fwUpdate(payload)
 	switch m.Action {
@@ -95,6 +165,19 @@ func configureProxy(payload *nm_models.HostPeerUpdate) error {
 	return err
 }
 
+// setServerProxyStatus - applies the proxy setting of the payload's server, peers of a server
+// with proxy disabled are handled as if the server sent no proxy
+func setServerProxyStatus(m *proxyPayload) {
+	if m.Action != nm_models.ProxyUpdate && m.Action != nm_models.NoProxy {
+		return
+	}
+	if server := ncconfig.GetServer(m.Server); server != nil && server.ProxyDisabled && m.Action == nm_models.ProxyUpdate {
+		logger.Log(1, "proxy is disabled for server", m.Server)
+		m.Action = nm_models.NoProxy
+	}
+	config.GetCfg().SetServerProxyStatus(m.Server, m.Action == nm_models.ProxyUpdate)
+}
+
 func fwUpdate(payload *nm_models.HostPeerUpdate) {
 	isIngressGw := len(payload.IngressInfo.ExtPeers) > 0
 	isEgressGw := len(payload.EgressInfo) > 0
@@ -123,13 +206,12 @@ func fwUpdate(payload *nm_models.HostPeerUpdate) {
 	if isEgressGw {
 		router.SetEgressRoutes(payload.Server, payload.EgressInfo)
 	}
-	if config.GetCfg().GetFwStatus() && !isIngressGw {
-		router.DeleteIngressRules(payload.Server)
-	}
-	if config.GetCfg().GetFwStatus() && !isEgressGw {
-		router.DeleteEgressGwRoutes(payload.Server)
+	teardown := func(string) {}
+	if config.GetCfg().GetFwStatus() {
+		teardown = teardownRole(payload.Server)
 	}
-
+	applyRoleTransitions(payload.Server, gwRoles{ingress: isIngressGw, egress: isEgressGw}, teardown)
+	updateForwarding(config.GetCfg().IsGlobalRelay())
 }
 
 func startMetricsThread(peerUpdate *nm_models.HostPeerUpdate) {
@@ -185,6 +267,7 @@ func (m *proxyPayload) setRelayedPeers() {
 
 func cleanUpInterface() {
 	logger.Log(1, "cleaning up proxy peer connections")
+	peerpkg.ResetAdmissionQueue()
 	peerConnMap := config.GetCfg().GetAllProxyPeers()
 	for _, peerI := range peerConnMap {
 		config.GetCfg().RemovePeer(peerI.Key.String())
@@ -220,7 +303,7 @@ func (m *proxyPayload) processPayload() error {
 					continue
 				} else {
 					delete(peerConn.ServerMap, m.Server)
-					peerConnMap[m.Peers[i].PublicKey.String()] = &peerConn
+					gCfg.SavePeer(&peerConn)
 					if len(peerConn.ServerMap) > 0 {
 						continue
 					}
@@ -236,11 +319,18 @@ func (m *proxyPayload) processPayload() error {
 			currentPeer.Mutex.Lock()
 			if currentPeer.Config.UsingTurn {
 				// check if using turn is no longer required
-				if !(turn.ShouldUseTurn(config.GetCfg().HostInfo.NatType) &&
-					turn.ShouldUseTurn(m.PeerMap[m.Peers[i].PublicKey.String()].NatType)) {
+				if turn.NatDecision(config.GetCfg().HostInfo.NatType,
+					m.PeerMap[m.Peers[i].PublicKey.String()].NatType) != turn.NatTurn {
 					// cleanup proxy connections for the peer
+					config.NotifyConnModeChange(models.ConnModeChange{
+						Server:  m.Server,
+						PeerKey: currentPeer.Key.String(),
+						OldMode: models.ConnModeTurn,
+						NewMode: models.ConnModeDirect,
+						Reason:  "turn is no longer required",
+					})
 					currentPeer.StopConn()
-					delete(peerConnMap, currentPeer.Key.String())
+					gCfg.DeleteProxyPeer(currentPeer.Key.String())
 					config.GetCfg().DeletePeerTurnCfg(currentPeer.Key.String())
 					wireguard.UpdatePeer(&m.Peers[i])
 					currentPeer.Mutex.Unlock()
@@ -249,8 +339,15 @@ func (m *proxyPayload) processPayload() error {
 				}
 				if m.IsRelayed || m.PeerMap[m.Peers[i].PublicKey.String()].IsRelayed {
 					// cleanup turn connections for the peer since it is being relayed already
+					config.NotifyConnModeChange(models.ConnModeChange{
+						Server:  m.Server,
+						PeerKey: currentPeer.Key.String(),
+						OldMode: models.ConnModeTurn,
+						NewMode: models.ConnModeRelay,
+						Reason:  "peer is relayed",
+					})
 					currentPeer.StopConn()
-					delete(peerConnMap, currentPeer.Key.String())
+					gCfg.DeleteProxyPeer(currentPeer.Key.String())
 					config.GetCfg().DeletePeerTurnCfg(currentPeer.Key.String())
 					wireguard.UpdatePeer(&m.Peers[i])
 					currentPeer.Mutex.Unlock()
@@ -259,11 +356,19 @@ func (m *proxyPayload) processPayload() error {
 				currentPeer.Mutex.Unlock()
 				continue
 			} else {
+				// a peer shared with a server that has proxy enabled stays proxied
 				if (m.Action == nm_models.NoProxy) && !m.PeerMap[m.Peers[i].PublicKey.String()].IsRelayed &&
-					!currentPeer.Config.UsingTurn {
+					!currentPeer.Config.UsingTurn && !config.GetCfg().IsPeerProxyEnabled(*currentPeer) {
 					// cleanup proxy connections for the peer
+					config.NotifyConnModeChange(models.ConnModeChange{
+						Server:  m.Server,
+						PeerKey: currentPeer.Key.String(),
+						OldMode: config.ConnMode(currentPeer.IsRelayed, false),
+						NewMode: models.ConnModeDirect,
+						Reason:  "proxy is disabled",
+					})
 					currentPeer.StopConn()
-					delete(peerConnMap, currentPeer.Key.String())
+					gCfg.DeleteProxyPeer(currentPeer.Key.String())
 					wireguard.UpdatePeer(&m.Peers[i])
 					currentPeer.Mutex.Unlock()
 					m.Peers = append(m.Peers[:i], m.Peers[i+1:]...)
@@ -272,8 +377,15 @@ func (m *proxyPayload) processPayload() error {
 				// check if proxy is not required for the peer anymore
 				if !m.IsRelayed && (m.Action == nm_models.ProxyUpdate) && !m.PeerMap[m.Peers[i].PublicKey.String()].Proxy {
 					// cleanup proxy connections for the peer
+					config.NotifyConnModeChange(models.ConnModeChange{
+						Server:  m.Server,
+						PeerKey: currentPeer.Key.String(),
+						OldMode: config.ConnMode(currentPeer.IsRelayed, false),
+						NewMode: models.ConnModeDirect,
+						Reason:  "proxy is not required for the peer",
+					})
 					currentPeer.StopConn()
-					delete(peerConnMap, currentPeer.Key.String())
+					gCfg.DeleteProxyPeer(currentPeer.Key.String())
 					wireguard.UpdatePeer(&m.Peers[i])
 					currentPeer.Mutex.Unlock()
 					m.Peers = append(m.Peers[:i], m.Peers[i+1:]...)
@@ -290,7 +402,7 @@ func (m *proxyPayload) processPayload() error {
 					logger.Log(1, "---------> endpoint is not set to proxy: ", currentPeer.Key.String())
 					currentPeer.StopConn()
 					currentPeer.Mutex.Unlock()
-					delete(peerConnMap, currentPeer.Key.String())
+					gCfg.DeleteProxyPeer(currentPeer.Key.String())
 					continue
 				}
 			}
@@ -300,7 +412,7 @@ func (m *proxyPayload) processPayload() error {
 				logger.Log(1, "---------> peer relay status has been changed: ", currentPeer.Key.String())
 				currentPeer.StopConn()
 				currentPeer.Mutex.Unlock()
-				delete(peerConnMap, currentPeer.Key.String())
+				gCfg.DeleteProxyPeer(currentPeer.Key.String())
 				continue
 			}
 
@@ -311,7 +423,7 @@ func (m *proxyPayload) processPayload() error {
 				logger.Log(1, "---------> peer relay endpoint has been changed: ", currentPeer.Key.String())
 				currentPeer.StopConn()
 				currentPeer.Mutex.Unlock()
-				delete(peerConnMap, currentPeer.Key.String())
+				gCfg.DeleteProxyPeer(currentPeer.Key.String())
 				continue
 			}
 
@@ -324,7 +436,7 @@ func (m *proxyPayload) processPayload() error {
 				logger.Log(1, "--------> peer proxy listen port has been changed", currentPeer.Key.String())
 				currentPeer.StopConn()
 				currentPeer.Mutex.Unlock()
-				delete(peerConnMap, currentPeer.Key.String())
+				gCfg.DeleteProxyPeer(currentPeer.Key.String())
 				continue
 			}
 
@@ -334,7 +446,7 @@ func (m *proxyPayload) processPayload() error {
 				logger.Log(1, "----------> Resetting proxy for Peer: ", currentPeer.Key.String())
 				currentPeer.StopConn()
 				currentPeer.Mutex.Unlock()
-				delete(peerConnMap, currentPeer.Key.String())
+				gCfg.DeleteProxyPeer(currentPeer.Key.String())
 				continue
 
 			}
@@ -344,7 +456,7 @@ func (m *proxyPayload) processPayload() error {
 				logger.Log(1, "----------> Resetting proxy for Peer: ", currentPeer.Key.String())
 				currentPeer.StopConn()
 				currentPeer.Mutex.Unlock()
-				delete(peerConnMap, currentPeer.Key.String())
+				gCfg.DeleteProxyPeer(currentPeer.Key.String())
 				continue
 			}
 			// delete the peer from the list
@@ -366,7 +478,12 @@ func (m *proxyPayload) processPayload() error {
 
 // ProxyManagerPayload.peerUpdate - processes the peer update
 func (m *proxyPayload) peerUpdate() error {
-
+	prevConnModes := make(map[string]string)
+	for peerKey, peerConn := range config.GetCfg().GetAllProxyPeers() {
+		peerConn.Mutex.RLock()
+		prevConnModes[peerKey] = config.ConnMode(peerConn.IsRelayed, peerConn.Config.UsingTurn)
+		peerConn.Mutex.RUnlock()
+	}
 	err := m.processPayload()
 	if err != nil {
 		return err
@@ -374,6 +491,8 @@ func (m *proxyPayload) peerUpdate() error {
 	for _, peerI := range m.Peers {
 
 		peerConf := m.PeerMap[peerI.PublicKey.String()]
+		// peers still beyond the connection limit are queued again below
+		peerpkg.DequeueForAdmission(peerI.PublicKey.String())
 		if peerI.Endpoint == nil {
 			logger.Log(1, "Endpoint nil for peer: ", peerI.PublicKey.String())
 			continue
@@ -401,7 +520,11 @@ func (m *proxyPayload) peerUpdate() error {
 		if peerConf.Proxy && m.Action == nm_models.ProxyUpdate {
 			shouldUseProxy = true
 		}
-		if !isRelayed && turn.ShouldUseTurn(config.GetCfg().HostInfo.NatType) && turn.ShouldUseTurn(peerConf.NatType) {
+		natDecision := turn.NatDecision(config.GetCfg().HostInfo.NatType, peerConf.NatType)
+		if natDecision == turn.NatProxy {
+			shouldUseProxy = true
+		}
+		if !isRelayed && natDecision == turn.NatTurn {
 			if t, ok := config.GetCfg().GetTurnCfg(m.Server); ok && t.TurnConn != nil {
 				go func(serverName string, peer wgtypes.PeerConfig, peerConf nm_models.PeerConf, t models.TurnCfg) {
 					var err error
@@ -433,10 +556,36 @@ func (m *proxyPayload) peerUpdate() error {
 
 		}
 		if shouldUseProxy {
-			peerpkg.AddNew(m.Server, peerI, peerConf, isRelayed, relayedTo, false)
+			if err := peerpkg.AddNew(m.Server, peerI, peerConf, isRelayed, relayedTo, false); err != nil {
+				if errors.Is(err, config.ErrProxyConnLimit) {
+					peerpkg.QueueForAdmission(m.Server, peerI, peerConf, isRelayed, relayedTo)
+				}
+				logger.Log(0, "failed to add proxy peer: ", err.Error())
+				continue
+			}
+			oldMode, ok := prevConnModes[peerI.PublicKey.String()]
+			if !ok {
+				oldMode = models.ConnModeDirect
+			}
+			reason := "proxy is enabled for the peer"
+			if natDecision == turn.NatProxy {
+				reason = "nat policy proxies the peer"
+			}
+			if isRelayed {
+				reason = "peer is relayed"
+			}
+			config.NotifyConnModeChange(models.ConnModeChange{
+				Server:  m.Server,
+				PeerKey: peerI.PublicKey.String(),
+				OldMode: oldMode,
+				NewMode: config.ConnMode(isRelayed, false),
+				Reason:  reason,
+			})
 		}
 
 	}
+	// peers removed by the update free up slots for the queued peers
+	peerpkg.AdmitQueued()
 	/* after processing peer update proxy connections
 	are dumped to a file under netclient data path */
 	config.DumpSignalChan <- struct{}{}
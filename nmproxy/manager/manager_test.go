@@ -0,0 +1,94 @@
+package manager
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	ncconfig "github.com/gravitl/netclient/config"
+	"github.com/gravitl/netclient/nmproxy/config"
+	"github.com/gravitl/netclient/nmproxy/models"
+	nm_models "github.com/gravitl/netmaker/models"
+)
+
+func TestDrainCleanup(t *testing.T) {
+	managerChan := make(chan *nm_models.HostPeerUpdate, 5)
+	managerChan <- &nm_models.HostPeerUpdate{Server: "a", ProxyUpdate: nm_models.ProxyManagerPayload{Action: nm_models.ProxyUpdate}}
+	managerChan <- nil
+	managerChan <- &nm_models.HostPeerUpdate{Server: "b", ProxyUpdate: nm_models.ProxyManagerPayload{Action: nm_models.ProxyDeleteAllPeers}}
+	managerChan <- &nm_models.HostPeerUpdate{Server: "c", ProxyUpdate: nm_models.ProxyManagerPayload{Action: nm_models.NoProxy}}
+	applied := []string{}
+	n := drainCleanup(managerChan, func(update *nm_models.HostPeerUpdate) error {
+		applied = append(applied, update.Server)
+		return nil
+	})
+	if n != 1 || len(applied) != 1 || applied[0] != "b" {
+		t.Fatalf("expected only the cleanup update to be applied, got %v", applied)
+	}
+	if len(managerChan) != 0 {
+		t.Fatalf("expected the channel to be drained, %d updates left", len(managerChan))
+	}
+}
+
+func TestDrainCleanupEmpty(t *testing.T) {
+	managerChan := make(chan *nm_models.HostPeerUpdate, 1)
+	if n := drainCleanup(managerChan, func(*nm_models.HostPeerUpdate) error { return nil }); n != 0 {
+		t.Fatalf("expected no updates applied, got %d", n)
+	}
+}
+
+func TestRunOnLoop(t *testing.T) {
+	if err := RunOnLoop(func() {}, 10*time.Millisecond); err == nil {
+		t.Fatal("expected an error without a manager loop")
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case req := <-loopRequests:
+				req()
+			case <-stop:
+				return
+			}
+		}
+	}()
+	ran := false
+	if err := RunOnLoop(func() { ran = true }, time.Second); err != nil || !ran {
+		t.Fatalf("expected the request to run on the loop, ran %v err %v", ran, err)
+	}
+}
+
+func TestSetServerProxyStatusSharedPeer(t *testing.T) {
+	config.InitializeCfg()
+	defer config.Reset()
+	ncconfig.UpdateServer("disabled.example.com", ncconfig.Server{Name: "disabled.example.com", ProxyDisabled: true})
+	defer delete(ncconfig.Servers, "disabled.example.com")
+	peer := models.Conn{
+		Mutex: &sync.RWMutex{},
+		ServerMap: map[string]struct{}{
+			"proxied.example.com":  {},
+			"disabled.example.com": {},
+			"noproxy.example.com":  {},
+		},
+	}
+	for _, m := range []*proxyPayload{
+		{Server: "proxied.example.com", Action: nm_models.ProxyUpdate},
+		{Server: "disabled.example.com", Action: nm_models.ProxyUpdate},
+		{Server: "noproxy.example.com", Action: nm_models.NoProxy},
+	} {
+		setServerProxyStatus(m)
+	}
+	if !config.GetCfg().IsPeerProxyEnabled(peer) {
+		t.Fatal("expected the peer to stay proxied while a server has proxy enabled")
+	}
+	setServerProxyStatus(&proxyPayload{Server: "proxied.example.com", Action: nm_models.NoProxy})
+	if config.GetCfg().IsPeerProxyEnabled(peer) {
+		t.Fatal("expected the peer not to be proxied once no server has proxy enabled")
+	}
+	m := &proxyPayload{Server: "disabled.example.com", Action: nm_models.ProxyUpdate}
+	setServerProxyStatus(m)
+	if m.Action != nm_models.NoProxy {
+		t.Fatalf("expected the update of a proxy disabled server to be handled as no proxy, got %s", m.Action)
+	}
+}
@@ -0,0 +1,81 @@
+package manager
+
+import (
+	"time"
+
+	ncconfig "github.com/gravitl/netclient/config"
+	"github.com/gravitl/netclient/nmproxy/config"
+	"github.com/gravitl/netclient/nmproxy/models"
+	"github.com/gravitl/netclient/nmproxy/wg"
+	"github.com/gravitl/netmaker/logger"
+)
+
+const (
+	// defaultStaleHandshakeInterval - interval of the stale handshake checks, when not configured
+	defaultStaleHandshakeInterval = time.Minute
+	// defaultStaleHandshakeThreshold - handshake age after which a proxied peer is reset, when not configured
+	defaultStaleHandshakeThreshold = 3 * time.Minute
+)
+
+// staleHandshakeSettings - returns the configured interval of the checks and the staleness threshold
+func staleHandshakeSettings() (interval, threshold time.Duration) {
+	interval, threshold = defaultStaleHandshakeInterval, defaultStaleHandshakeThreshold
+	if secs := ncconfig.Netclient().StaleHandshakeInterval; secs > 0 {
+		interval = time.Duration(secs) * time.Second
+	}
+	if secs := ncconfig.Netclient().StaleHandshakeThreshold; secs > 0 {
+		threshold = time.Duration(secs) * time.Second
+	}
+	return interval, threshold
+}
+
+// staleProxyPeers - returns the keys of the proxied peers with an endpoint whose last handshake is older
+// than threshold, peers which never handshaked are left alone and a peer is not reset again within threshold
+func staleProxyPeers(peers models.PeerConnMap, handshakes map[string]time.Time, lastReset map[string]time.Time,
+	now time.Time, threshold time.Duration) []string {
+	stale := []string{}
+	for key, peer := range peers {
+		if peer == nil || peer.Config.PeerEndpoint == nil {
+			continue
+		}
+		handshake, ok := handshakes[key]
+		if !ok || handshake.IsZero() || now.Sub(handshake) <= threshold {
+			continue
+		}
+		if now.Sub(lastReset[key]) <= threshold {
+			continue
+		}
+		stale = append(stale, key)
+	}
+	return stale
+}
+
+// resetStalePeers - resets the proxy connections of the peers with a stale handshake,
+// so they pull the latest config and rebind
+func resetStalePeers(lastReset map[string]time.Time, threshold time.Duration) {
+	if config.GetCfg().IsIfaceNil() {
+		return
+	}
+	ifacePeers, err := wg.GetPeers(config.GetCfg().GetIface().Name)
+	if err != nil {
+		logger.Log(1, "failed to fetch peers for stale handshake check: ", err.Error())
+		return
+	}
+	handshakes := make(map[string]time.Time, len(ifacePeers))
+	for _, peer := range ifacePeers {
+		handshakes[peer.PublicKey.String()] = peer.LastHandshakeTime
+	}
+	now := time.Now()
+	peers := config.GetCfg().GetAllProxyPeers()
+	for key := range lastReset {
+		if _, ok := peers[key]; !ok {
+			delete(lastReset, key)
+		}
+	}
+	for _, key := range staleProxyPeers(peers, handshakes, lastReset, now, threshold) {
+		logger.Log(0, "resetting proxy connection of peer with stale handshake: ", key,
+			"last handshake: ", handshakes[key].String())
+		lastReset[key] = now
+		config.GetCfg().ResetPeer(key)
+	}
+}
@@ -0,0 +1,39 @@
+package manager
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gravitl/netclient/nmproxy/models"
+)
+
+func TestStaleProxyPeers(t *testing.T) {
+	now := time.Now()
+	threshold := 3 * time.Minute
+	endpoint := &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 51821}
+	peers := models.PeerConnMap{
+		"stale":       {Config: models.Proxy{PeerEndpoint: endpoint}},
+		"fresh":       {Config: models.Proxy{PeerEndpoint: endpoint}},
+		"no-endpoint": {},
+		"never":       {Config: models.Proxy{PeerEndpoint: endpoint}},
+		"reset":       {Config: models.Proxy{PeerEndpoint: endpoint}},
+	}
+	handshakes := map[string]time.Time{
+		"stale":       now.Add(-5 * time.Minute),
+		"fresh":       now.Add(-time.Minute),
+		"no-endpoint": now.Add(-5 * time.Minute),
+		"never":       {},
+		"reset":       now.Add(-5 * time.Minute),
+	}
+	lastReset := map[string]time.Time{"reset": now.Add(-time.Minute)}
+	stale := staleProxyPeers(peers, handshakes, lastReset, now, threshold)
+	if len(stale) != 1 || stale[0] != "stale" {
+		t.Fatalf("expected only the stale peer to be reset, got %v", stale)
+	}
+	// a peer reset earlier is reset again once the threshold passed since
+	lastReset["reset"] = now.Add(-4 * time.Minute)
+	if stale := staleProxyPeers(peers, handshakes, lastReset, now, threshold); len(stale) != 2 {
+		t.Fatalf("expected the peer to be reset again after the threshold, got %v", stale)
+	}
+}
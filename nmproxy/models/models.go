@@ -35,6 +35,7 @@ type Proxy struct {
 	ProxyListenPort int
 	ProxyStatus     bool
 	UsingTurn       bool
+	DSCP            int
 }
 
 // Conn is a peer Connection configuration
@@ -59,6 +60,8 @@ type RemotePeer struct {
 	LocalConn  net.Conn
 	CancelFunc context.CancelFunc
 	CommChan   chan *net.UDPAddr
+	// Deliver - hands a packet received from the peer to its proxy, false if the packet was dropped
+	Deliver func(pkt []byte) bool
 }
 
 // HostInfo - struct for host information
@@ -83,6 +86,29 @@ type Settings struct {
 	IsEgressGateway  bool
 	IsRelayed        bool
 	RelayedTo        *net.UDPAddr
+	// ProxyDisabled - peers of the server are not proxied, except when relayed
+	ProxyDisabled bool
+}
+
+// connection modes of a peer
+const (
+	// ConnModeDirect - peer is reached directly over wireguard
+	ConnModeDirect = "direct"
+	// ConnModeProxy - peer is reached through the proxy
+	ConnModeProxy = "proxy"
+	// ConnModeRelay - peer is reached through a relay
+	ConnModeRelay = "relay"
+	// ConnModeTurn - peer is reached through the turn server
+	ConnModeTurn = "turn"
+)
+
+// ConnModeChange - struct for a change in the connection mode of a peer
+type ConnModeChange struct {
+	Server  string `json:"server"`
+	PeerKey string `json:"peer_key"`
+	OldMode string `json:"old_mode"`
+	NewMode string `json:"new_mode"`
+	Reason  string `json:"reason"`
 }
 
 // TurnCfg - struct to hold turn conn details
@@ -3,6 +3,7 @@ package nmproxy
 import (
 	"context"
 	"fmt"
+	"net"
 	"sync"
 	"time"
 
@@ -18,7 +19,7 @@ import (
 
 // Start - setups the global cfg for proxy and starts the proxy server
 func Start(ctx context.Context, wg *sync.WaitGroup,
-	mgmChan chan *models.HostPeerUpdate, hostNatInfo *ncmodels.HostInfo, proxyPort int) {
+	mgmChan chan *models.HostPeerUpdate, hostNatInfo *ncmodels.HostInfo, proxyPort, localProxyPort int) {
 
 	if config.GetCfg().IsProxyRunning() {
 		logger.Log(1, "Proxy is running already...")
@@ -36,10 +37,16 @@ func Start(ctx context.Context, wg *sync.WaitGroup,
 	if proxyPort == 0 {
 		proxyPort = models.NmProxyPort
 	}
+	if port := ensureListenPort(proxyPort); port != proxyPort {
+		// the port was taken since stun ran, the check-in reports the private port in use
+		hostNatInfo.PrivPort = port
+		proxyPort = port
+	}
 	config.InitializeCfg()
 	defer config.Reset()
 	logger.Log(0, fmt.Sprintf("set nat info: %v", hostNatInfo))
 	config.GetCfg().SetHostInfo(*hostNatInfo)
+	config.GetCfg().SetLocalProxyPort(localProxyPort)
 
 	// start the netclient proxy server
 	err := server.NmProxyServer.CreateProxyServer(proxyPort, 0, config.GetCfg().GetHostInfo().PrivIp.String())
@@ -47,13 +54,81 @@ func Start(ctx context.Context, wg *sync.WaitGroup,
 		logger.FatalLog("failed to create proxy: ", err.Error())
 	}
 	config.GetCfg().SetServerConn(server.NmProxyServer.Server)
+	managerDone := make(chan struct{})
 	wg.Add(1)
-	go manager.Start(ctx, wg, mgmChan)
+	go func() {
+		manager.Start(ctx, wg, mgmChan)
+		close(managerDone)
+	}()
 	wg.Add(1)
 	go turn.WatchPeerSignals(ctx, wg)
-	if turn.ShouldUseTurn(hostNatInfo.NatType) {
+	if turn.HostMayUseTurn(hostNatInfo.NatType) {
 		time.Sleep(time.Second * 2) // add a delay for clients to send turn register message to server
 		turn.Init(ctx, wg, ncconfig.GetAllTurnConfigs())
 	}
 	server.NmProxyServer.Listen(ctx)
+	<-managerDone // pending cleanup updates are applied before the config is reset
+}
+
+// CheckLocalAddr - rebinds the proxy when the host no longer has the address the proxy is bound to,
+// called from the interface detection at checkin so the proxy survives the host roaming
+func CheckLocalAddr() {
+	if config.GetCfg() == nil || !config.GetCfg().IsProxyRunning() {
+		return
+	}
+	ifaceAddrs, err := net.InterfaceAddrs()
+	if err != nil {
+		logger.Log(0, "failed to fetch local addresses: ", err.Error())
+		return
+	}
+	addrs := []net.IP{}
+	for _, addr := range ifaceAddrs {
+		if ip, _, err := net.ParseCIDR(addr.String()); err == nil {
+			addrs = append(addrs, ip)
+		}
+	}
+	handleLocalAddrChange(addrs)
+}
+
+// handleLocalAddrChange - rebinds the proxy server to a valid local address if its address was lost
+func handleLocalAddrChange(addrs []net.IP) {
+	hostInfo := config.GetCfg().GetHostInfo()
+	if hostInfo.PrivIp == nil {
+		return
+	}
+	for _, addr := range addrs {
+		if addr.Equal(hostInfo.PrivIp) {
+			return
+		}
+	}
+	newAddr := selectLocalAddr(addrs, hostInfo.PrivIp)
+	if newAddr == nil {
+		logger.Log(0, "proxy address", hostInfo.PrivIp.String(), "was lost and no local address is available")
+		return
+	}
+	logger.Log(0, "proxy address", hostInfo.PrivIp.String(), "was lost, rebinding for", newAddr.String())
+	if err := server.NmProxyServer.Rebind(); err != nil {
+		logger.Log(0, "failed to rebind proxy: ", err.Error())
+		return
+	}
+	hostInfo.PrivIp = newAddr
+	config.GetCfg().SetHostInfo(hostInfo)
+	config.GetCfg().SetServerConn(server.NmProxyServer.GetConn())
+}
+
+// selectLocalAddr - picks a usable local address, preferring the family of the lost address
+func selectLocalAddr(addrs []net.IP, lost net.IP) net.IP {
+	var fallback net.IP
+	for _, addr := range addrs {
+		if addr.IsLoopback() || addr.IsLinkLocalUnicast() {
+			continue
+		}
+		if (addr.To4() != nil) == (lost.To4() != nil) {
+			return addr
+		}
+		if fallback == nil {
+			fallback = addr
+		}
+	}
+	return fallback
 }
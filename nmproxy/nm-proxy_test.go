@@ -0,0 +1,79 @@
+package nmproxy
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gravitl/netclient/ncutils"
+	"github.com/gravitl/netclient/nmproxy/config"
+	"github.com/gravitl/netclient/nmproxy/models"
+	"github.com/gravitl/netclient/nmproxy/server"
+)
+
+func TestHandleLocalAddrChange(t *testing.T) {
+	port, err := ncutils.GetFreePort(42000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.InitializeCfg()
+	defer config.Reset()
+	if err := server.NmProxyServer.CreateProxyServer(port, 0, ""); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { server.NmProxyServer.GetConn().Close() }()
+	oldConn := server.NmProxyServer.Server
+	// the host roamed and lost the address the proxy was bound to
+	lost := net.ParseIP("198.51.100.7")
+	config.GetCfg().SetHostInfo(models.HostInfo{PrivIp: lost})
+	current := net.ParseIP("192.0.2.2")
+	handleLocalAddrChange([]net.IP{net.ParseIP("127.0.0.1"), current})
+
+	if !config.GetCfg().GetHostInfo().PrivIp.Equal(current) {
+		t.Fatalf("expected proxy address to move to %s, got %s", current, config.GetCfg().GetHostInfo().PrivIp)
+	}
+	if server.NmProxyServer.GetConn() == oldConn {
+		t.Fatal("expected proxy to be rebound")
+	}
+	// the rebound proxy listens on all addresses
+	sender, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: port})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+	if _, err := sender.Write([]byte("hello-proxy")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 32)
+	conn := server.NmProxyServer.GetConn()
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFromUDP(buf)
+	if err != nil || string(buf[:n]) != "hello-proxy" {
+		t.Fatalf("expected rebound proxy to receive packets, got %q %v", buf[:n], err)
+	}
+}
+
+func TestSelectListenPort(t *testing.T) {
+	port, err := ncutils.GetFreePort(43000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := selectListenPort(port)
+	if err != nil || got != port {
+		t.Fatalf("expected free port %d to be used, got %d (%v)", port, got, err)
+	}
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{Port: port})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	got, err = selectListenPort(port)
+	if err != nil || got == port {
+		t.Fatalf("expected a fallback for the bound port %d, got %d (%v)", port, got, err)
+	}
+	// the fallback skips the wireguard listen port
+	reserved, err := selectListenPort(port, got)
+	if err != nil || reserved == port || reserved == got {
+		t.Fatalf("expected ports %d and %d to be skipped, got %d (%v)", port, got, reserved, err)
+	}
+}
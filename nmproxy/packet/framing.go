@@ -0,0 +1,61 @@
+package packet
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+// frameHeaderSize - size of the length prefix of a datagram carried over a stream
+const frameHeaderSize = 2
+
+// isStreamConn - checks if the connection is stream oriented, reads on it may return part of a
+// datagram or several of them, so datagrams are carried with a length prefix
+func isStreamConn(conn net.Conn) bool {
+	network := conn.LocalAddr().Network()
+	return !strings.HasPrefix(network, "udp") && !strings.HasPrefix(network, "ip") && network != "unixgram"
+}
+
+// ReadPacket - reads one whole datagram from the local connection of a proxy into buf,
+// reassembling it on stream transports
+func ReadPacket(conn net.Conn, buf []byte) (int, error) {
+	if !isStreamConn(conn) {
+		return conn.Read(buf)
+	}
+	var header [frameHeaderSize]byte
+	if _, err := io.ReadFull(conn, header[:]); err != nil {
+		return 0, err
+	}
+	size := int(binary.BigEndian.Uint16(header[:]))
+	if size > len(buf) {
+		// skip the datagram so the next read starts at a frame boundary
+		if _, err := io.CopyN(io.Discard, conn, int64(size)); err != nil {
+			return 0, err
+		}
+		return 0, fmt.Errorf("datagram of %d bytes exceeds the buffer of %d bytes: %w", size, len(buf), io.ErrShortBuffer)
+	}
+	return io.ReadFull(conn, buf[:size])
+}
+
+// WritePacket - writes one datagram to the local connection of a proxy, framing it on stream transports
+func WritePacket(conn net.Conn, pkt []byte) (int, error) {
+	if !isStreamConn(conn) {
+		return conn.Write(pkt)
+	}
+	if len(pkt) > 0xffff {
+		return 0, fmt.Errorf("datagram of %d bytes is too large to be framed", len(pkt))
+	}
+	// header and datagram are written at once, so concurrent writers don't interleave them
+	frame := make([]byte, frameHeaderSize+len(pkt))
+	binary.BigEndian.PutUint16(frame, uint16(len(pkt)))
+	copy(frame[frameHeaderSize:], pkt)
+	n, err := conn.Write(frame)
+	if n > frameHeaderSize {
+		n -= frameHeaderSize
+	} else {
+		n = 0
+	}
+	return n, err
+}
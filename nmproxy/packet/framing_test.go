@@ -0,0 +1,92 @@
+package packet
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+)
+
+func TestReadPacketDatagram(t *testing.T) {
+	server, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	conn, err := net.Dial("udp4", server.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	for _, pkt := range []string{"first", "second datagram"} {
+		if _, err := WritePacket(conn, []byte(pkt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	buf := make([]byte, 1500)
+	for _, want := range []string{"first", "second datagram"} {
+		n, _, err := server.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(buf[:n]) != want {
+			t.Errorf("datagrams should be sent unframed, got %q want %q", buf[:n], want)
+		}
+	}
+}
+
+func TestReadPacketStream(t *testing.T) {
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	client, err := net.Dial("tcp4", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	server, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	// two datagrams arriving in one read, followed by one arriving in pieces
+	for _, pkt := range []string{"first", "second"} {
+		if _, err := WritePacket(client, []byte(pkt)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	go func() {
+		for _, part := range [][]byte{{0}, {5, 't', 'h'}, []byte("ird")} {
+			client.Write(part)
+		}
+	}()
+	buf := make([]byte, 1500)
+	for _, want := range []string{"first", "second", "third"} {
+		n, err := ReadPacket(server, buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(buf[:n]) != want {
+			t.Errorf("got %q, want %q", buf[:n], want)
+		}
+	}
+
+	// a datagram larger than the buffer is skipped without losing the framing
+	if _, err := WritePacket(client, []byte("too large")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := WritePacket(client, []byte("ok")); err != nil {
+		t.Fatal(err)
+	}
+	small := make([]byte, 4)
+	if _, err := ReadPacket(server, small); !errors.Is(err, io.ErrShortBuffer) {
+		t.Fatalf("expected a short buffer error, got %v", err)
+	}
+	n, err := ReadPacket(server, small)
+	if err != nil || string(small[:n]) != "ok" {
+		t.Fatalf("expected the next datagram, got %q %v", small[:n], err)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/md5"
 	"encoding/base64"
 	"encoding/binary"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"time"
@@ -139,31 +140,25 @@ func ConsumeMetricPacket(buf []byte) (*MetricMessage, error) {
 	return &msg, nil
 }
 
-// ProcessPacketBeforeSending - encodes data required for proxy transport message
+// ProcessPacketBeforeSending - encodes data required for proxy transport message,
+// appending it in place when the capacity of buf has room for it
 func ProcessPacketBeforeSending(buf []byte, n int, srckey, dstKey string) ([]byte, int, string, string) {
 	srcKeymd5 := md5.Sum([]byte(srckey))
 	dstKeymd5 := md5.Sum([]byte(dstKey))
-	m := ProxyMessage{
-		Type:     MessageProxyTransportType,
-		Sender:   srcKeymd5,
-		Reciever: dstKeymd5,
-	}
-	var msgBuffer [MessageProxyTransportSize]byte
-	writer := bytes.NewBuffer(msgBuffer[:0])
-	err := binary.Write(writer, binary.LittleEndian, m)
-	if err != nil {
-		logger.Log(1, "error writing msg to bytes: ", err.Error())
-		return buf, n, "", ""
-	}
-	if n > len(buf)-MessageProxyTransportSize {
-		buf = append(buf, msgBuffer[:]...)
-
-	} else {
-		copy(buf[n:n+MessageProxyTransportSize], msgBuffer[:])
-	}
+	if n+MessageProxyTransportSize > cap(buf) {
+		grown := make([]byte, n+MessageProxyTransportSize)
+		copy(grown, buf[:n])
+		buf = grown
+	}
+	buf = buf[:n+MessageProxyTransportSize]
+	// same layout as the binary encoding of ProxyMessage
+	msg := buf[n:]
+	binary.LittleEndian.PutUint32(msg, uint32(MessageProxyTransportType))
+	copy(msg[4:], srcKeymd5[:])
+	copy(msg[4+PeerKeyHashSize:], dstKeymd5[:])
 	n += MessageProxyTransportSize
 
-	return buf, n, fmt.Sprintf("%x", srcKeymd5), fmt.Sprintf("%x", dstKeymd5)
+	return buf, n, hex.EncodeToString(srcKeymd5[:]), hex.EncodeToString(dstKeymd5[:])
 }
 
 // ExtractInfo - extracts proxy transport message from the  data buffer
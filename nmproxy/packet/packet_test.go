@@ -0,0 +1,45 @@
+package packet
+
+import (
+	"testing"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestProcessPacketBeforeSending(t *testing.T) {
+	src, _ := wgtypes.GeneratePrivateKey()
+	dst, _ := wgtypes.GeneratePrivateKey()
+	srcKey, dstKey := src.PublicKey().String(), dst.PublicKey().String()
+	payload := []byte("wireguard payload")
+
+	buf := make([]byte, len(payload), len(payload)+MessageProxyTransportSize)
+	copy(buf, payload)
+	out, n, srcHash, dstHash := ProcessPacketBeforeSending(buf, len(payload), srcKey, dstKey)
+	if &out[0] != &buf[0] {
+		t.Fatal("expected the buffer to be reused when it has room")
+	}
+	m, gotSrc, gotDst, err := ExtractInfo(out, n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m != len(payload) || gotSrc != srcHash || gotDst != dstHash || string(out[:m]) != string(payload) {
+		t.Fatalf("proxy message does not round trip: %d %s %s", m, gotSrc, gotDst)
+	}
+
+	// a buffer without room is grown
+	out, n, _, _ = ProcessPacketBeforeSending(payload, len(payload), srcKey, dstKey)
+	if n != len(payload)+MessageProxyTransportSize || len(out) != n || string(out[:len(payload)]) != string(payload) {
+		t.Fatalf("expected the payload followed by the proxy message, got %d bytes", n)
+	}
+}
+
+func BenchmarkProcessPacketBeforeSending(b *testing.B) {
+	src, _ := wgtypes.GeneratePrivateKey()
+	dst, _ := wgtypes.GeneratePrivateKey()
+	srcKey, dstKey := src.PublicKey().String(), dst.PublicKey().String()
+	buf := make([]byte, 1420+MessageProxyTransportSize)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ProcessPacketBeforeSending(buf, 1420, srcKey, dstKey)
+	}
+}
@@ -0,0 +1,89 @@
+package peer
+
+import (
+	"errors"
+	"net"
+
+	"github.com/gravitl/netclient/nmproxy/config"
+	"github.com/gravitl/netclient/nmproxy/models"
+	"github.com/gravitl/netmaker/logger"
+	nm_models "github.com/gravitl/netmaker/models"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// queuedPeer - peer rejected by the proxy connection limit, waiting on a direct/turn connection for a free slot
+type queuedPeer struct {
+	server    string
+	peer      wgtypes.PeerConfig
+	peerConf  nm_models.PeerConf
+	isRelayed bool
+	relayTo   *net.UDPAddr
+}
+
+// admissionQueue - peers waiting for a proxy connection slot in the order they were rejected,
+// only accessed from the proxy manager loop
+var admissionQueue = []queuedPeer{}
+
+// addQueuedPeer - starts proxying a queued peer
+var addQueuedPeer = func(q queuedPeer) error {
+	return AddNew(q.server, q.peer, q.peerConf, q.isRelayed, q.relayTo, false)
+}
+
+// QueueForAdmission - queues a peer rejected by the proxy connection limit to be proxied once a slot is free,
+// a peer already queued keeps its place with the latest settings
+func QueueForAdmission(server string, peer wgtypes.PeerConfig, peerConf nm_models.PeerConf,
+	isRelayed bool, relayTo *net.UDPAddr) {
+	q := queuedPeer{
+		server:    server,
+		peer:      peer,
+		peerConf:  peerConf,
+		isRelayed: isRelayed,
+		relayTo:   relayTo,
+	}
+	for i := range admissionQueue {
+		if admissionQueue[i].peer.PublicKey == peer.PublicKey {
+			admissionQueue[i] = q
+			return
+		}
+	}
+	logger.Log(1, "proxy connection limit reached, queueing peer: ", peer.PublicKey.String())
+	admissionQueue = append(admissionQueue, q)
+}
+
+// DequeueForAdmission - removes the peer from the admission queue
+func DequeueForAdmission(peerKey string) {
+	for i := range admissionQueue {
+		if admissionQueue[i].peer.PublicKey.String() == peerKey {
+			admissionQueue = append(admissionQueue[:i], admissionQueue[i+1:]...)
+			return
+		}
+	}
+}
+
+// ResetAdmissionQueue - drops all the queued peers
+func ResetAdmissionQueue() {
+	admissionQueue = []queuedPeer{}
+}
+
+// AdmitQueued - proxies the queued peers while proxy connection slots are free
+func AdmitQueued() {
+	for len(admissionQueue) > 0 {
+		q := admissionQueue[0]
+		err := addQueuedPeer(q)
+		if errors.Is(err, config.ErrProxyConnLimit) {
+			return
+		}
+		admissionQueue = admissionQueue[1:]
+		if err != nil {
+			logger.Log(0, "failed to add queued proxy peer: ", err.Error())
+			continue
+		}
+		config.NotifyConnModeChange(models.ConnModeChange{
+			Server:  q.server,
+			PeerKey: q.peer.PublicKey.String(),
+			OldMode: models.ConnModeDirect,
+			NewMode: config.ConnMode(q.isRelayed, false),
+			Reason:  "proxy connection slot is free",
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package peer
+
+import (
+	"testing"
+
+	"github.com/gravitl/netclient/nmproxy/config"
+	nm_models "github.com/gravitl/netmaker/models"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestAdmitQueued(t *testing.T) {
+	defer ResetAdmissionQueue()
+	newPeer := func() wgtypes.PeerConfig {
+		priv, _ := wgtypes.GeneratePrivateKey()
+		return wgtypes.PeerConfig{PublicKey: priv.PublicKey()}
+	}
+	a, b, c := newPeer(), newPeer(), newPeer()
+	QueueForAdmission("server.example.com", a, nm_models.PeerConf{}, false, nil)
+	QueueForAdmission("server.example.com", b, nm_models.PeerConf{}, false, nil)
+	QueueForAdmission("server.example.com", c, nm_models.PeerConf{}, false, nil)
+	// a peer queued again keeps its place
+	QueueForAdmission("server.example.com", a, nm_models.PeerConf{Proxy: true}, false, nil)
+	DequeueForAdmission(c.PublicKey.String())
+	if len(admissionQueue) != 2 || admissionQueue[0].peer.PublicKey != a.PublicKey || !admissionQueue[0].peerConf.Proxy {
+		t.Fatalf("unexpected admission queue %+v", admissionQueue)
+	}
+
+	slots := 1
+	admitted := []wgtypes.Key{}
+	addQueuedPeer = func(q queuedPeer) error {
+		if slots == 0 {
+			return config.ErrProxyConnLimit
+		}
+		slots--
+		admitted = append(admitted, q.peer.PublicKey)
+		return nil
+	}
+	defer func() {
+		addQueuedPeer = func(q queuedPeer) error {
+			return AddNew(q.server, q.peer, q.peerConf, q.isRelayed, q.relayTo, false)
+		}
+	}()
+	AdmitQueued()
+	if len(admitted) != 1 || admitted[0] != a.PublicKey {
+		t.Fatalf("expected only the first queued peer to be admitted, got %v", admitted)
+	}
+	if len(admissionQueue) != 1 || admissionQueue[0].peer.PublicKey != b.PublicKey {
+		t.Fatal("expected the rejected peer to stay queued")
+	}
+	// a freed slot admits the next queued peer
+	slots = 1
+	AdmitQueued()
+	if len(admitted) != 2 || admitted[1] != b.PublicKey || len(admissionQueue) != 0 {
+		t.Fatalf("expected the queued peer to be admitted once a slot is free, got %v", admitted)
+	}
+}
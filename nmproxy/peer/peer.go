@@ -10,6 +10,7 @@ import (
 
 	"github.com/google/uuid"
 
+	ncconfig "github.com/gravitl/netclient/config"
 	"github.com/gravitl/netclient/nmproxy/config"
 	"github.com/gravitl/netclient/nmproxy/models"
 	"github.com/gravitl/netclient/nmproxy/packet"
@@ -29,6 +30,14 @@ func AddNew(server string, peer wgtypes.PeerConfig, peerConf nm_models.PeerConf,
 		d := nm_models.DefaultPersistentKeepaliveInterval
 		peer.PersistentKeepaliveInterval = &d
 	}
+	admit, evict := config.GetCfg().AdmitProxyPeer(peer.PublicKey.String(),
+		ncconfig.Netclient().MaxProxyConns, ncconfig.Netclient().ProxyConnLimitPolicy)
+	if !admit {
+		return config.ErrProxyConnLimit
+	}
+	if evict != "" {
+		evictPeer(evict)
+	}
 	c := models.Proxy{
 		PeerPublicKey:   peer.PublicKey,
 		IsExtClient:     peerConf.IsExtClient,
@@ -37,6 +46,7 @@ func AddNew(server string, peer wgtypes.PeerConfig, peerConf nm_models.PeerConf,
 		ProxyListenPort: peerConf.ProxyListenPort,
 		ProxyStatus:     peerConf.Proxy || isRelayed,
 		UsingTurn:       usingTurn,
+		DSCP:            peerDSCP(peer.PublicKey.String()),
 	}
 	p := proxy.New(c)
 	peerPort := int(peerConf.PublicListenPort)
@@ -92,6 +102,7 @@ func AddNew(server string, peer wgtypes.PeerConfig, peerConf nm_models.PeerConf,
 		PeerKey:   peer.PublicKey.String(),
 		Endpoint:  peerEndpoint,
 		LocalConn: p.LocalConn,
+		Deliver:   p.Deliver,
 	}
 
 	logger.Log(1, "-----> saving as proxy peer: ", connConf.Key.String())
@@ -100,12 +111,36 @@ func AddNew(server string, peer wgtypes.PeerConfig, peerConf nm_models.PeerConf,
 	return nil
 }
 
+// peerDSCP - DSCP value of the peer's proxied packets, the host's ProxyDSCP unless one is set for the peer
+func peerDSCP(peerKey string) int {
+	if dscp, ok := ncconfig.Netclient().PeerDSCP[peerKey]; ok {
+		return dscp
+	}
+	return ncconfig.Netclient().ProxyDSCP
+}
+
+// evictPeer - stops proxying the peer and points its wireguard endpoint back to the peer
+func evictPeer(peerKey string) {
+	peerConn, found := config.GetCfg().GetPeer(peerKey)
+	if !found {
+		return
+	}
+	logger.Log(0, "proxy connection limit reached, evicting idle peer: ", peerKey)
+	peerConn.Mutex.RLock()
+	peerConf := peerConn.Config.PeerConf
+	peerConn.Mutex.RUnlock()
+	config.GetCfg().RemovePeer(peerKey)
+	if peerConf.Endpoint != nil {
+		config.GetCfg().GetIface().UpdatePeerEndpoint(peerConf)
+	}
+}
+
 // SetPeersEndpointToProxy - sets peer endpoints to local addresses connected to proxy
 func SetPeersEndpointToProxy(peers []wgtypes.PeerConfig) []wgtypes.PeerConfig {
 	logger.Log(1, "Setting peers endpoints to proxy...")
 	for i := range peers {
 		proxyPeer, found := config.GetCfg().GetPeer(peers[i].PublicKey.String())
-		if found {
+		if found && config.GetCfg().IsPeerProxyEnabled(proxyPeer) {
 			proxyPeer.Mutex.RLock()
 			peers[i].Endpoint = proxyPeer.Config.LocalConnAddr
 			proxyPeer.Mutex.RUnlock()
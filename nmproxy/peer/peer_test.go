@@ -0,0 +1,64 @@
+package peer
+
+import (
+	"net"
+	"sync"
+	"testing"
+
+	ncconfig "github.com/gravitl/netclient/config"
+	"github.com/gravitl/netclient/nmproxy/config"
+	"github.com/gravitl/netclient/nmproxy/models"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestSetPeersEndpointToProxyPerServer(t *testing.T) {
+	config.InitializeCfg()
+	defer config.Reset()
+	proxyAddr := &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: 51722}
+	newPeer := func(server string, relayed bool) wgtypes.PeerConfig {
+		priv, _ := wgtypes.GeneratePrivateKey()
+		key := priv.PublicKey()
+		config.GetCfg().SavePeer(&models.Conn{
+			Key:       key,
+			IsRelayed: relayed,
+			Config:    models.Proxy{LocalConnAddr: proxyAddr},
+			Mutex:     &sync.RWMutex{},
+			ServerMap: map[string]struct{}{server: {}},
+		})
+		return wgtypes.PeerConfig{
+			PublicKey: key,
+			Endpoint:  &net.UDPAddr{IP: net.ParseIP("198.51.100.10"), Port: 51821},
+		}
+	}
+	config.GetCfg().SetServerProxyStatus("proxied.example.com", true)
+	config.GetCfg().SetServerProxyStatus("direct.example.com", false)
+	peers := []wgtypes.PeerConfig{
+		newPeer("proxied.example.com", false),
+		newPeer("direct.example.com", false),
+		newPeer("direct.example.com", true),
+	}
+	peers = SetPeersEndpointToProxy(peers)
+	if peers[0].Endpoint != proxyAddr {
+		t.Errorf("expected peer of proxy enabled server to use the proxy, got %s", peers[0].Endpoint)
+	}
+	if peers[1].Endpoint.String() != "198.51.100.10:51821" {
+		t.Errorf("expected peer of proxy disabled server to keep its endpoint, got %s", peers[1].Endpoint)
+	}
+	if peers[2].Endpoint != proxyAddr {
+		t.Errorf("expected relayed peer to use the proxy, got %s", peers[2].Endpoint)
+	}
+}
+
+func TestPeerDSCP(t *testing.T) {
+	defer func() {
+		ncconfig.Netclient().ProxyDSCP = 0
+		ncconfig.Netclient().PeerDSCP = nil
+	}()
+	ncconfig.Netclient().ProxyDSCP = 10
+	ncconfig.Netclient().PeerDSCP = map[string]int{"voice": 46, "bulk": 0}
+	for key, want := range map[string]int{"voice": 46, "bulk": 0, "other": 10} {
+		if got := peerDSCP(key); got != want {
+			t.Errorf("expected dscp %d for peer %s, got %d", want, key, got)
+		}
+	}
+}
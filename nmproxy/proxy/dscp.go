@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/gravitl/netmaker/logger"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+// maxDSCP - largest value of the 6 bit DSCP field
+const maxDSCP = 63
+
+// socketMarks - marking of the sockets marked by changing their TOS, indexed by socket
+var socketMarks = new(sync.Map)
+
+// socketMark - DSCP value currently set on a socket, its mutex serializes the writes on the socket
+// of peers with different values
+type socketMark struct {
+	mutex sync.Mutex
+	dscp  int
+}
+
+// setDSCP - sets the DSCP/traffic class of the packets sent on the socket,
+// it succeeds if either the IPv4 TOS or the IPv6 traffic class could be set
+func setDSCP(conn net.PacketConn, dscp int) error {
+	if dscp < 0 || dscp > maxDSCP {
+		return errors.New("dscp value out of range")
+	}
+	tos := dscp << 2
+	err4 := ipv4.NewPacketConn(conn).SetTOS(tos)
+	err6 := ipv6.NewPacketConn(conn).SetTrafficClass(tos)
+	if err4 != nil && err6 != nil {
+		return err4
+	}
+	return nil
+}
+
+// writeWithDSCP - writes the packet to addr marked with the peer's DSCP value, udp sockets carry the marking
+// with the packet where the platform allows it and are left untouched, other sockets are marked
+func writeWithDSCP(conn net.PacketConn, dscp int, buf []byte, addr net.Addr) (int, error) {
+	if udpConn, ok := conn.(*net.UDPConn); ok && dscp >= 0 && dscp <= maxDSCP {
+		if udpAddr, ok := addr.(*net.UDPAddr); ok {
+			if dscp == 0 {
+				return udpConn.WriteToUDP(buf, udpAddr)
+			}
+			if oob := dscpOOB(dscp, udpAddr.IP.To4() == nil); oob != nil {
+				n, _, err := udpConn.WriteMsgUDP(buf, oob, udpAddr)
+				return n, err
+			}
+		}
+	}
+	return writeWithSocketTOS(conn, dscp, buf, addr)
+}
+
+// writeWithSocketTOS - writes the packet to addr after setting the peer's DSCP value on the socket, a value of 0
+// resets the marking another peer left on the socket, the socket is only updated when its current marking
+// differs and is forgotten once it is closed
+func writeWithSocketTOS(conn net.PacketConn, dscp int, buf []byte, addr net.Addr) (int, error) {
+	value, _ := socketMarks.LoadOrStore(conn, &socketMark{})
+	mark := value.(*socketMark)
+	mark.mutex.Lock()
+	defer mark.mutex.Unlock()
+	if mark.dscp != dscp {
+		if err := setDSCP(conn, dscp); err != nil {
+			// marking isn't possible on every transport, send unmarked
+			logger.Log(3, "failed to mark packet with dscp: ", err.Error())
+		} else {
+			mark.dscp = dscp
+		}
+	}
+	n, err := conn.WriteTo(buf, addr)
+	if errors.Is(err, net.ErrClosed) {
+		socketMarks.Delete(conn)
+	}
+	return n, err
+}
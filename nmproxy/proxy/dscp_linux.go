@@ -0,0 +1,36 @@
+package proxy
+
+import (
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// dscpOOBs - control messages marking a packet with each DSCP value, for ipv4 and ipv6 destinations
+var dscpOOBs [2][maxDSCP + 1][]byte
+
+func init() {
+	for dscp := 0; dscp <= maxDSCP; dscp++ {
+		dscpOOBs[0][dscp] = tosControlMessage(unix.IPPROTO_IP, unix.IP_TOS, dscp<<2)
+		dscpOOBs[1][dscp] = tosControlMessage(unix.IPPROTO_IPV6, unix.IPV6_TCLASS, dscp<<2)
+	}
+}
+
+// tosControlMessage - builds the control message setting the TOS or traffic class of a single packet
+func tosControlMessage(level, typ, tos int) []byte {
+	oob := make([]byte, unix.CmsgSpace(4))
+	h := (*unix.Cmsghdr)(unsafe.Pointer(&oob[0]))
+	h.Level = int32(level)
+	h.Type = int32(typ)
+	h.SetLen(unix.CmsgLen(4))
+	*(*int32)(unsafe.Pointer(&oob[unix.CmsgLen(0)])) = int32(tos)
+	return oob
+}
+
+// dscpOOB - returns the control message marking a packet to a destination of the family with the DSCP value
+func dscpOOB(dscp int, v6 bool) []byte {
+	if v6 {
+		return dscpOOBs[1][dscp]
+	}
+	return dscpOOBs[0][dscp]
+}
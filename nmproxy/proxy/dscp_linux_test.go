@@ -0,0 +1,77 @@
+package proxy
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/ipv4"
+	"golang.org/x/sys/unix"
+)
+
+// receivedTOS - reads a packet from the socket and returns the TOS it was received with
+func receivedTOS(t *testing.T, conn *net.UDPConn) int {
+	t.Helper()
+	buf := make([]byte, 64)
+	oob := make([]byte, unix.CmsgSpace(4))
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, oobn, _, _, err := conn.ReadMsgUDP(buf, oob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msgs, err := unix.ParseSocketControlMessage(oob[:oobn])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range msgs {
+		if msg.Header.Level == unix.IPPROTO_IP && msg.Header.Type == unix.IP_TOS && len(msg.Data) > 0 {
+			return int(msg.Data[0])
+		}
+	}
+	t.Fatal("packet received without tos")
+	return 0
+}
+
+func TestWriteWithDSCPMarksPackets(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	dst, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	rawConn, err := dst.SyscallConn()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rawConn.Control(func(fd uintptr) {
+		err = unix.SetsockoptInt(int(fd), unix.IPPROTO_IP, unix.IP_RECVTOS, 1)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// expedited forwarding, as used for voice peers
+	if _, err := writeWithDSCP(conn, 46, []byte("hello"), dst.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	if tos := receivedTOS(t, dst); tos != 46<<2 {
+		t.Fatalf("expected tos %d, got %d", 46<<2, tos)
+	}
+	// a peer without dscp on the same socket sends unmarked
+	if _, err := writeWithDSCP(conn, 0, []byte("hello"), dst.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	if tos := receivedTOS(t, dst); tos != 0 {
+		t.Fatalf("expected unmarked packet, got tos %d", tos)
+	}
+	// the marking is carried by the packet, the socket is left untouched
+	if tos, _ := ipv4.NewPacketConn(conn).TOS(); tos != 0 {
+		t.Fatalf("expected the socket tos to be untouched, got %d", tos)
+	}
+	if _, tracked := socketMarks.Load(conn); tracked {
+		t.Fatal("expected udp sockets not to be tracked")
+	}
+}
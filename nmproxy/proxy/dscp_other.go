@@ -0,0 +1,9 @@
+//go:build !linux
+// +build !linux
+
+package proxy
+
+// dscpOOB - packets can't be marked individually on this platform, the socket is marked instead
+func dscpOOB(dscp int, v6 bool) []byte {
+	return nil
+}
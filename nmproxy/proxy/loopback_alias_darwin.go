@@ -0,0 +1,28 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/gravitl/netclient/nmproxy/common"
+)
+
+// ErrLoopbackAlias - the loopback address for a proxied peer could not be aliased on lo0
+var ErrLoopbackAlias = errors.New("loopback aliasing is not permitted")
+
+// runCmd - runs the commands aliasing loopback addresses
+var runCmd = common.RunCmd
+
+// addLoopbackAlias - aliases the address on lo0 so the proxy can bind to it
+func addLoopbackAlias(addr string) error {
+	cmd := fmt.Sprintf("ifconfig lo0 alias %s 255.255.255.255", addr)
+	if ip := net.ParseIP(addr); ip != nil && ip.To4() == nil {
+		cmd = fmt.Sprintf("ifconfig lo0 inet6 %s prefixlen 128 alias", addr)
+	}
+	if _, err := runCmd(cmd, true); err != nil {
+		return fmt.Errorf("%w: failed to alias %s on lo0 (%v), run netclient with root privileges "+
+			"or pre-create the lo0 aliases of the proxy range", ErrLoopbackAlias, addr, err)
+	}
+	return nil
+}
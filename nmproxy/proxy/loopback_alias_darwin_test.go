@@ -0,0 +1,40 @@
+package proxy
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGetFreeIpAliasFailure(t *testing.T) {
+	defer func(run func(string, bool) (string, error)) { runCmd = run }(runCmd)
+	calls := 0
+	runCmd = func(string, bool) (string, error) {
+		calls++
+		return "", errors.New("operation not permitted")
+	}
+	if _, err := GetFreeIp("127.0.0.0/8", 51821); !errors.Is(err, ErrLoopbackAlias) {
+		t.Fatalf("expected ErrLoopbackAlias, got %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("expected a single alias attempt, got %d", calls)
+	}
+}
+
+func TestLoopbackAliasIPv6(t *testing.T) {
+	defer func(run func(string, bool) (string, error)) { runCmd = run }(runCmd)
+	cmds := []string{}
+	runCmd = func(cmd string, _ bool) (string, error) {
+		cmds = append(cmds, cmd)
+		return "", nil
+	}
+	if err := addLoopbackAlias("127.0.0.2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := addLoopbackAlias("::1"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"ifconfig lo0 alias 127.0.0.2 255.255.255.255", "ifconfig lo0 inet6 ::1 prefixlen 128 alias"}
+	if len(cmds) != 2 || cmds[0] != want[0] || cmds[1] != want[1] {
+		t.Fatalf("expected %v, got %v", want, cmds)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !darwin
+// +build !darwin
+
+package proxy
+
+// addLoopbackAlias - loopback addresses are bindable without aliasing on this platform
+func addLoopbackAlias(addr string) error {
+	return nil
+}
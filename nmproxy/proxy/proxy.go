@@ -17,6 +17,13 @@ type Proxy struct {
 	Config     models.Proxy
 	RemoteConn *net.UDPAddr
 	LocalConn  net.Conn
+	inbound    chan inboundPacket
+}
+
+// inboundPacket - a packet received from the remote peer in a pooled buffer
+type inboundPacket struct {
+	bufp *[]byte
+	n    int
 }
 
 // Proxy.Start - starts proxying the peer
@@ -48,7 +55,8 @@ func (p *Proxy) Start() error {
 	return nil
 }
 
-// Proxy.Close - removes peer conn from proxy and closes all the opened connections locally
+// Proxy.Close - removes peer conn from proxy and closes all the opened connections locally,
+// ending both directions of ProxyPeer
 func (p *Proxy) Close() {
 	logger.Log(0, "------> Closing Proxy for ", p.Config.PeerPublicKey.String())
 	p.Cancel()
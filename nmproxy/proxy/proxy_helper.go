@@ -4,15 +4,15 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net"
-	"runtime"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/c-robinson/iplib"
 	nc_config "github.com/gravitl/netclient/config"
-	"github.com/gravitl/netclient/nmproxy/common"
+	"github.com/gravitl/netclient/ncutils"
 	"github.com/gravitl/netclient/nmproxy/config"
 	"github.com/gravitl/netclient/nmproxy/models"
 	"github.com/gravitl/netclient/nmproxy/packet"
@@ -22,70 +22,179 @@ import (
 	"github.com/gravitl/netmaker/metrics"
 )
 
+// packetLog - collapses the repeated per packet failures of a proxy
+var packetLog = ncutils.NewLogLimiter(time.Minute)
+
 // New - gets new proxy config
 func New(config models.Proxy) *Proxy {
-	p := &Proxy{Config: config}
+	p := &Proxy{Config: config, inbound: make(chan inboundPacket, inboundQueueSize)}
 	p.Ctx, p.Cancel = context.WithCancel(context.Background())
 	return p
 }
 
+const (
+	// proxyBufSize - largest datagram read from the interface
+	proxyBufSize = 65535
+	// inboundQueueSize - packets from the remote peer queued for the interface before they are dropped
+	inboundQueueSize = 256
+	// inboundBufSize - pooled buffer of a packet from the remote peer, fits a wireguard packet at the usual mtu,
+	// larger packets get a buffer of their own so the queues don't hold on to datagram sized buffers
+	inboundBufSize = 2048
+)
+
+// bufPool - buffers of the packets read from the interface, with room to append the proxy transport message in place
+var bufPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, proxyBufSize+packet.MessageProxyTransportSize)
+		return &buf
+	},
+}
+
+// inboundPool - buffers of the packets queued from the remote peers
+var inboundPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, inboundBufSize)
+		return &buf
+	},
+}
+
+// getInboundBuf - returns a buffer for a packet of size n from the remote peer
+func getInboundBuf(n int) *[]byte {
+	if n > inboundBufSize {
+		buf := make([]byte, n)
+		return &buf
+	}
+	return inboundPool.Get().(*[]byte)
+}
+
+// putInboundBuf - returns the buffer of a packet from the remote peer to the pool, unless it was sized for the packet
+func putInboundBuf(bufp *[]byte) {
+	if cap(*bufp) == inboundBufSize {
+		inboundPool.Put(bufp)
+	}
+}
+
 // Proxy.toRemote - proxies data from the interface to remote peer
 func (p *Proxy) toRemote(wg *sync.WaitGroup) {
 	ticker := time.NewTicker(time.Minute)
 	defer ticker.Stop()
-	buf := make([]byte, 65000)
 	defer wg.Done()
 	for {
 		select {
 		case <-p.Ctx.Done():
 			return
 		default:
-
-			n, err := p.LocalConn.Read(buf)
+			err := p.forwardToRemote()
+			if errors.Is(err, io.ErrShortBuffer) {
+				packetLog.Log(1, "dropping packet: ", err.Error())
+				continue
+			}
 			if err != nil {
 				logger.Log(1, "error reading: ", err.Error())
 				return
 			}
-			go func(n int, cfg models.Proxy) {
-				peerConnCfg := models.Conn{}
-				if p.Config.ProxyStatus {
-					peerConnCfg, _ = config.GetCfg().GetPeer(cfg.PeerPublicKey.String())
-				}
-				for server := range peerConnCfg.ServerMap {
-					metric := metrics.GetMetric(server, cfg.PeerPublicKey.String())
-					metric.TrafficSent += int64(n)
-					metrics.UpdateMetric(server, cfg.PeerPublicKey.String(), &metric)
-				}
-
-			}(n, p.Config)
-
-			var srcPeerKeyHash, dstPeerKeyHash string
-			if p.Config.ProxyStatus || p.Config.UsingTurn {
-				buf, n, srcPeerKeyHash, dstPeerKeyHash = packet.ProcessPacketBeforeSending(buf, n,
-					config.GetCfg().GetDevicePubKey().String(), p.Config.PeerPublicKey.String())
-				if err != nil {
-					logger.Log(1, "failed to process pkt before sending: ", err.Error())
-				}
-			}
-			if nc_config.Netclient().Debug {
-				logger.Log(3, fmt.Sprintf("PROXING TO REMOTE!!!---> %s >>>>> %s >>>>> %s [[ SrcPeerHash: %s, DstPeerHash: %s ]]\n",
-					p.LocalConn.LocalAddr().String(), server.NmProxyServer.Server.LocalAddr().String(), p.RemoteConn.String(), srcPeerKeyHash, dstPeerKeyHash))
-			}
-			if p.Config.UsingTurn {
-				_, err = p.Config.TurnConn.WriteTo(buf[:n], p.RemoteConn)
-				if err != nil {
-					logger.Log(0, "failed to write to remote conn: ", err.Error())
-				}
-				continue
-			}
-			_, err = server.NmProxyServer.Server.WriteToUDP(buf[:n], p.RemoteConn)
-			if err != nil {
-				logger.Log(1, "Failed to send to remote: ", err.Error())
+		}
+	}
+
+}
+
+// Proxy.forwardToRemote - reads a packet from the interface into a pooled buffer and sends it to the remote peer,
+// returns the read error
+func (p *Proxy) forwardToRemote() error {
+	bufp := bufPool.Get().(*[]byte)
+	defer bufPool.Put(bufp)
+	buf := (*bufp)[:proxyBufSize]
+	n, err := packet.ReadPacket(p.LocalConn, buf)
+	if err != nil {
+		return err
+	}
+	// only the packet size is passed on, the buffer is returned to the pool once sent
+	go func(n int, cfg models.Proxy) {
+		config.MarkPeerActive(cfg.PeerPublicKey.String())
+		peerConnCfg := models.Conn{}
+		if p.Config.ProxyStatus {
+			peerConnCfg, _ = config.GetCfg().GetPeer(cfg.PeerPublicKey.String())
+		}
+		for server := range peerConnCfg.ServerMap {
+			metric := metrics.GetMetric(server, cfg.PeerPublicKey.String())
+			metric.TrafficSent += int64(n)
+			metrics.UpdateMetric(server, cfg.PeerPublicKey.String(), &metric)
+		}
+
+	}(n, p.Config)
+
+	var srcPeerKeyHash, dstPeerKeyHash string
+	if p.Config.ProxyStatus || p.Config.UsingTurn {
+		buf, n, srcPeerKeyHash, dstPeerKeyHash = packet.ProcessPacketBeforeSending(buf, n,
+			config.GetCfg().GetDevicePubKey().String(), p.Config.PeerPublicKey.String())
+	}
+	if nc_config.Netclient().Debug {
+		logger.Log(3, fmt.Sprintf("PROXING TO REMOTE!!!---> %s >>>>> %s >>>>> %s [[ SrcPeerHash: %s, DstPeerHash: %s ]]\n",
+			p.LocalConn.LocalAddr().String(), server.NmProxyServer.GetConn().LocalAddr().String(), p.RemoteConn.String(), srcPeerKeyHash, dstPeerKeyHash))
+	}
+	if p.Config.UsingTurn {
+		if _, err = p.writeToRemote(p.Config.TurnConn, buf[:n]); err != nil {
+			packetLog.Log(0, "failed to write to remote conn: ", err.Error())
+		}
+		return nil
+	}
+	if _, err = p.writeToRemote(server.NmProxyServer.GetConn(), buf[:n]); err != nil {
+		packetLog.Log(1, "Failed to send to remote: ", err.Error())
+		server.NmProxyServer.CheckWriteErr(err)
+	}
+	return nil
+}
+
+// Proxy.Deliver - queues a packet received from the remote peer for fromRemote, the packet is copied
+// so the caller may reuse it; returns false when the proxy is closed or the queue is full
+func (p *Proxy) Deliver(pkt []byte) bool {
+	if p.Ctx.Err() != nil || len(pkt) > proxyBufSize {
+		return false
+	}
+	bufp := getInboundBuf(len(pkt))
+	n := copy(*bufp, pkt)
+	select {
+	case p.inbound <- inboundPacket{bufp: bufp, n: n}:
+		return true
+	default:
+		putInboundBuf(bufp)
+		return false
+	}
+}
+
+// Proxy.fromRemote - proxies data received from the remote peer, directly or over turn, to the interface
+func (p *Proxy) fromRemote(wg *sync.WaitGroup) {
+	defer wg.Done()
+	for {
+		select {
+		case <-p.Ctx.Done():
+			p.drainInbound()
+			return
+		case pkt := <-p.inbound:
+			if _, err := packet.WritePacket(p.LocalConn, (*pkt.bufp)[:pkt.n]); err != nil {
+				packetLog.Log(1, "Failed to proxy to Wg local interface: ", err.Error())
 			}
+			putInboundBuf(pkt.bufp)
+		}
+	}
+}
 
+// Proxy.drainInbound - returns the buffers of the queued packets to the pool
+func (p *Proxy) drainInbound() {
+	for {
+		select {
+		case pkt := <-p.inbound:
+			putInboundBuf(pkt.bufp)
+		default:
+			return
 		}
 	}
+}
 
+// Proxy.writeToRemote - writes the packet to the remote peer marked with the peer's DSCP value, the socket may be
+// shared with other peers so a peer without one still goes through the marking to reset theirs
+func (p *Proxy) writeToRemote(conn net.PacketConn, buf []byte) (int, error) {
+	return writeWithDSCP(conn, p.Config.DSCP, buf, p.RemoteConn)
 }
 
 // Proxy.Reset - resets peer's conn
@@ -115,6 +224,7 @@ func (p *Proxy) Reset() {
 	}
 	if peer, found := config.GetCfg().GetPeerInfoByHash(models.ConvPeerKeyToHash(p.Config.PeerPublicKey.String())); found {
 		peer.LocalConn = p.LocalConn
+		peer.Deliver = p.Deliver
 		config.GetCfg().SavePeerByHash(&peer)
 	}
 	config.DumpSignalChan <- struct{}{}
@@ -137,8 +247,9 @@ func (p *Proxy) pullLatestConfig() error {
 func (p *Proxy) ProxyPeer() {
 
 	wg := &sync.WaitGroup{}
-	wg.Add(1)
+	wg.Add(2)
 	go p.toRemote(wg)
+	go p.fromRemote(wg)
 	wg.Wait()
 
 }
@@ -157,51 +268,68 @@ func (p *Proxy) updateEndpoint() error {
 	return nil
 }
 
-// GetFreeIp - gets available free ip from the cidr provided
+// GetFreeIp - gets available free ip from the cidr provided, ipv4 or ipv6
 func GetFreeIp(cidrAddr string, dstPort int) (string, error) {
 	//ensure AddressRange is valid
 	if dstPort == 0 {
 		return "", errors.New("dst port should be set")
 	}
-	if _, _, err := net.ParseCIDR(cidrAddr); err != nil {
+	ip, _, err := net.ParseCIDR(cidrAddr)
+	if err != nil {
 		logger.Log(1, "UniqueAddress encountered  an error")
 		return "", err
 	}
-	net4 := iplib.Net4FromStr(cidrAddr)
-	newAddrs := net4.FirstAddress()
-	for {
-		if runtime.GOOS == "darwin" {
-			_, err := common.RunCmd(fmt.Sprintf("ifconfig lo0 alias %s 255.255.255.255", newAddrs.String()), true)
-			if err != nil {
-				logger.Log(1, "Failed to add alias: ", err.Error())
-			}
+	var first net.IP
+	var next func(net.IP) (net.IP, error)
+	dst := net.ParseIP("127.0.0.1")
+	if ip.To4() == nil {
+		net6 := iplib.Net6FromStr(cidrAddr)
+		first, next = net6.FirstAddress(), net6.NextIP
+		dst = net.IPv6loopback
+	} else {
+		net4 := iplib.Net4FromStr(cidrAddr)
+		first, next = net4.FirstAddress(), net4.NextIP
+	}
+	freeIp, err := probeFreeIp(first, next, func(addr net.IP) error {
+		if err := addLoopbackAlias(addr.String()); err != nil {
+			return err
 		}
-
 		conn, err := net.DialUDP("udp", &net.UDPAddr{
-			IP:   net.ParseIP(newAddrs.String()),
-			Port: models.NmProxyPort,
+			IP:   addr,
+			Port: config.GetCfg().GetLocalProxyPort(),
 		}, &net.UDPAddr{
-			IP:   net.ParseIP("127.0.0.1"),
+			IP:   dst,
 			Port: dstPort,
 		})
 		if err != nil {
-			logger.Log(1, "----> GetFreeIP err: ", err.Error())
-			if strings.Contains(err.Error(), "can't assign requested address") ||
-				strings.Contains(err.Error(), "address already in use") || strings.Contains(err.Error(), "cannot assign requested address") {
-				var nErr error
-				newAddrs, nErr = net4.NextIP(newAddrs)
-				if nErr != nil {
-					return "", nErr
-				}
-			} else {
-				return "", err
-			}
+			return err
 		}
+		conn.Close()
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return freeIp.String(), nil
+}
+
+// probeFreeIp - probes the addresses from first on until one is free, moving on to the next address
+// while the probed one can't be assigned or is in use
+func probeFreeIp(first net.IP, next func(net.IP) (net.IP, error), probe func(net.IP) error) (net.IP, error) {
+	newAddrs := first
+	for {
+		err := probe(newAddrs)
 		if err == nil {
-			conn.Close()
-			return newAddrs.String(), nil
+			return newAddrs, nil
+		}
+		logger.Log(1, "----> GetFreeIP err: ", err.Error())
+		if !strings.Contains(err.Error(), "can't assign requested address") &&
+			!strings.Contains(err.Error(), "address already in use") && !strings.Contains(err.Error(), "cannot assign requested address") {
+			return nil, err
+		}
+		if newAddrs, err = next(newAddrs); err != nil {
+			return nil, err
 		}
-
 	}
 }
 
@@ -0,0 +1,265 @@
+package proxy
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/c-robinson/iplib"
+	"github.com/gravitl/netclient/ncutils"
+	"github.com/gravitl/netclient/nmproxy/config"
+	"github.com/gravitl/netclient/nmproxy/models"
+	"golang.org/x/net/ipv4"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestGetFreeIpUsesLocalProxyPort(t *testing.T) {
+	port, err := ncutils.GetFreePort(40000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.InitializeCfg()
+	defer config.Reset()
+	config.GetCfg().SetLocalProxyPort(port)
+	// occupy the custom port on the first address so the dial has to move on
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: port})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ip, err := GetFreeIp("127.0.0.1/8", 51821)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip != "127.0.0.2" {
+		t.Fatalf("expected 127.0.0.2, got %s", ip)
+	}
+}
+
+func TestGetFreeIpIPv6(t *testing.T) {
+	// guarded to hosts with ipv6 loopback
+	probe, err := net.ListenUDP("udp6", &net.UDPAddr{IP: net.IPv6loopback})
+	if err != nil {
+		t.Skip("ipv6 loopback is not available:", err)
+	}
+	probe.Close()
+	port, err := ncutils.GetFreePort(41000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.InitializeCfg()
+	defer config.Reset()
+	config.GetCfg().SetLocalProxyPort(port)
+	ip, err := GetFreeIp("::1/128", 51821)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip != "::1" {
+		t.Fatalf("expected ::1, got %s", ip)
+	}
+}
+
+func TestProbeFreeIpIPv6Collision(t *testing.T) {
+	net6 := iplib.Net6FromStr("fd00::/64")
+	probed := []string{}
+	ip, err := probeFreeIp(net6.FirstAddress(), net6.NextIP, func(addr net.IP) error {
+		probed = append(probed, addr.String())
+		if len(probed) < 3 {
+			return errors.New("bind: address already in use")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ip.String() != "fd00::2" || strings.Join(probed, ",") != "fd00::,fd00::1,fd00::2" {
+		t.Fatalf("expected fd00::2 after probing fd00::, fd00::1, got %s after %v", ip, probed)
+	}
+	// other errors are returned
+	if _, err := probeFreeIp(net6.FirstAddress(), net6.NextIP, func(net.IP) error {
+		return errors.New("network is unreachable")
+	}); err == nil {
+		t.Fatal("expected the probe error")
+	}
+}
+
+func TestProxyPeerFromRemote(t *testing.T) {
+	iface, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer iface.Close()
+	key, _ := wgtypes.GeneratePrivateKey()
+	p := New(models.Proxy{PeerPublicKey: key.PublicKey()})
+	if p.LocalConn, err = net.DialUDP("udp4", nil, iface.LocalAddr().(*net.UDPAddr)); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		p.ProxyPeer()
+		close(done)
+	}()
+
+	// a packet from the remote peer is written to the interface
+	pkt := []byte("handshake response")
+	if !p.Deliver(pkt) {
+		t.Fatal("expected the packet to be queued")
+	}
+	pkt[0] = 'X' // the caller's buffer is reused by the demux
+	buf := make([]byte, 64)
+	iface.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := iface.ReadFromUDP(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(buf[:n]) != "handshake response" {
+		t.Fatalf("expected the delivered packet, got %q", buf[:n])
+	}
+
+	// closing ends both directions
+	p.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("proxy goroutines did not exit after close")
+	}
+	if p.Deliver(pkt) {
+		t.Fatal("expected packets to be dropped after close")
+	}
+}
+
+func TestWriteWithSocketTOS(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	dst, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	// expedited forwarding, as used for voice peers
+	if _, err := writeWithSocketTOS(conn, 46, []byte("hello"), dst.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	tos, err := ipv4.NewPacketConn(conn).TOS()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tos != 46<<2 {
+		t.Fatalf("expected tos %d, got %d", 46<<2, tos)
+	}
+	// a peer without dscp on the same socket resets the marking
+	if _, err := writeWithSocketTOS(conn, 0, []byte("hello"), dst.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	if tos, _ = ipv4.NewPacketConn(conn).TOS(); tos != 0 {
+		t.Fatalf("expected tos to be reset, got %d", tos)
+	}
+	if err := setDSCP(conn, 64); err == nil {
+		t.Fatal("expected out of range dscp to be rejected")
+	}
+}
+
+func TestWriteToRemoteResetsDSCP(t *testing.T) {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	dst, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Close()
+	marked := &Proxy{Config: models.Proxy{DSCP: 46}, RemoteConn: dst.LocalAddr().(*net.UDPAddr)}
+	unmarked := &Proxy{RemoteConn: dst.LocalAddr().(*net.UDPAddr)}
+	if _, err := marked.writeToRemote(conn, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	// a peer without dscp writing after a marked peer on the shared socket sends unmarked
+	if _, err := unmarked.writeToRemote(conn, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if tos, _ := ipv4.NewPacketConn(conn).TOS(); tos != 0 {
+		t.Fatalf("expected the marking of the previous peer to be reset, got tos %d", tos)
+	}
+	if _, err := marked.writeToRemote(conn, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if _, err := marked.writeToRemote(conn, []byte("hello")); err == nil {
+		t.Fatal("expected the write on a closed socket to fail")
+	}
+	if _, tracked := socketMarks.Load(conn); tracked {
+		t.Fatal("expected the closed socket to be forgotten")
+	}
+}
+
+func BenchmarkForwardToRemote(b *testing.B) {
+	config.InitializeCfg()
+	defer config.Reset()
+	// loopback peer: the interface side, the proxy's local conn and the remote peer behind a turn conn
+	iface, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer iface.Close()
+	local, err := net.DialUDP("udp4", nil, iface.LocalAddr().(*net.UDPAddr))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer local.Close()
+	remote, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer remote.Close()
+	turnConn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer turnConn.Close()
+	key, _ := wgtypes.GeneratePrivateKey()
+	p := &Proxy{
+		Config:     models.Proxy{PeerPublicKey: key.PublicKey(), UsingTurn: true, TurnConn: turnConn},
+		RemoteConn: remote.LocalAddr().(*net.UDPAddr),
+		LocalConn:  local,
+	}
+	go func() {
+		buf := make([]byte, 2048)
+		for {
+			if _, err := remote.Read(buf); err != nil {
+				return
+			}
+		}
+	}()
+	pkt := make([]byte, 1420)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := iface.WriteToUDP(pkt, local.LocalAddr().(*net.UDPAddr)); err != nil {
+			b.Fatal(err)
+		}
+		if err := p.forwardToRemote(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestInboundBufs(t *testing.T) {
+	if bufp := getInboundBuf(1500); cap(*bufp) != inboundBufSize {
+		t.Fatalf("expected a pooled buffer for an mtu sized packet, got capacity %d", cap(*bufp))
+	}
+	bufp := getInboundBuf(9000)
+	if len(*bufp) != 9000 {
+		t.Fatalf("expected a buffer sized for a jumbo packet, got %d", len(*bufp))
+	}
+	putInboundBuf(bufp)
+	if pooled := getInboundBuf(1); cap(*pooled) != inboundBufSize {
+		t.Fatalf("expected the jumbo buffer to stay out of the pool, got capacity %d", cap(*pooled))
+	}
+}
@@ -0,0 +1,16 @@
+package router
+
+import "github.com/gravitl/netclient/config"
+
+// denyLogPrefix - prefix of the kernel log entries of the traffic dropped by the netmaker filter chain
+const denyLogPrefix = "netmaker-deny: "
+
+// egressDenyLog - checks if the traffic dropped by the netmaker filter chain is logged
+func egressDenyLog() bool {
+	return config.Netclient().EgressDenyLog
+}
+
+// denyLogRuleSpec - returns the filter chain rule logging the traffic that falls through to the chain's drop
+func denyLogRuleSpec() []string {
+	return []string{"-j", "LOG", "--log-prefix", denyLogPrefix}
+}
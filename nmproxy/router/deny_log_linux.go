@@ -0,0 +1,43 @@
+package router
+
+import (
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+	"golang.org/x/sys/unix"
+)
+
+// nfDenyLogRule - returns the netmaker filter chain rule logging the traffic from the interface before it is dropped
+func nfDenyLogRule(ifaceName string) ruleInfo {
+	ruleSpec := append([]string{"-i", ifaceName}, denyLogRuleSpec()...)
+	return ruleInfo{
+		nfRule: &nftables.Rule{
+			Table: filterTable,
+			Chain: &nftables.Chain{Name: netmakerFilterChain},
+			Exprs: []expr.Any{
+				&expr.Meta{Key: expr.MetaKeyIIFNAME, Register: 1},
+				&expr.Cmp{
+					Op:       expr.CmpOpEq,
+					Register: 1,
+					Data:     []byte(ifaceName + "\x00"),
+				},
+				&expr.Counter{},
+				&expr.Log{
+					Key:  1 << unix.NFTA_LOG_PREFIX,
+					Data: []byte(denyLogPrefix),
+				},
+			},
+			UserData: []byte(genRuleKey(ruleSpec...)),
+		},
+		rule:  ruleSpec,
+		table: defaultIpTable,
+		chain: netmakerFilterChain,
+	}
+}
+
+// withDenyLogRule - places the deny log rule before the drop of the netmaker filter chain if deny logging is enabled
+func withDenyLogRule(rules []ruleInfo, logRule ruleInfo) []ruleInfo {
+	if !egressDenyLog() {
+		return rules
+	}
+	return append([]ruleInfo{logRule}, rules...)
+}
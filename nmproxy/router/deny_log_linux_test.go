@@ -0,0 +1,44 @@
+package router
+
+import (
+	"testing"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+	"github.com/gravitl/netclient/config"
+)
+
+func TestDenyLogRule(t *testing.T) {
+	defer func() {
+		config.Netclient().EgressDenyLog = false
+		nfJumpRules, nfFilterJumpRules, nfNatJumpRules = nil, nil, nil
+	}()
+	hasLog := func(rule ruleInfo) bool {
+		for _, e := range rule.nfRule.(*nftables.Rule).Exprs {
+			if _, ok := e.(*expr.Log); ok {
+				return true
+			}
+		}
+		return false
+	}
+	for _, enabled := range []bool{false, true} {
+		config.Netclient().EgressDenyLog = enabled
+		if err := buildNfJumpRules("netmaker"); err != nil {
+			t.Fatal(err)
+		}
+		logRules := 0
+		for i, rule := range nfFilterJumpRules {
+			if !hasLog(rule) {
+				continue
+			}
+			logRules++
+			next := nfFilterJumpRules[i+1].rule
+			if next[len(next)-1] != targetDrop {
+				t.Errorf("deny log rule should be placed before the drop, followed by %v", next)
+			}
+		}
+		if (logRules == 1) != enabled || logRules > 1 {
+			t.Errorf("deny logging enabled %v, got %d log rules", enabled, logRules)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package router
 
 import (
+	"github.com/gravitl/netclient/config"
 	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/models"
 )
@@ -8,12 +9,18 @@ import (
 // SetEgressRoutes - sets the egress route for the gateway
 func SetEgressRoutes(server string, egressUpdate map[string]models.EgressInfo) error {
 	logger.Log(0, "----> setting egress routes")
+	storeEgressUpdate(server, egressUpdate)
+	for _, overlap := range findEgressOverlaps(egressUpdate) {
+		logger.Log(0, "egress range", overlap.ranges[0], "of", overlap.egressIDs[0], "overlaps", overlap.ranges[1], "of",
+			overlap.egressIDs[1], "- shared rules are kept until both gateways are removed")
+	}
 	ruleTable := fwCrtl.FetchRuleTable(server, egressTable)
 	for egressNodeID, ruleCfg := range ruleTable {
 
 		if _, ok := egressUpdate[egressNodeID]; !ok {
 			// egress GW is deleted, flush out all rules
 			fwCrtl.RemoveRoutingRules(server, egressTable, egressNodeID)
+			forgetRuleRetry(server, egressTable, egressNodeID)
 			continue
 		}
 		egressInfo := egressUpdate[egressNodeID]
@@ -26,9 +33,25 @@ func SetEgressRoutes(server string, egressUpdate map[string]models.EgressInfo) e
 	}
 
 	for egressNodeID, egressInfo := range egressUpdate {
+		if skipGateway(egressInfo.EgressGwAddr.String()) {
+			continue
+		}
 		if _, ok := ruleTable[egressNodeID]; !ok {
 			// set up rules for the GW on first time creation
-			fwCrtl.InsertEgressRoutingRules(server, egressInfo)
+			if err := fwCrtl.InsertEgressRoutingRules(server, egressInfo); err != nil {
+				logger.Log(0, "failed to set egress routes: ", err.Error())
+				egressNodeID := egressNodeID
+				queueIfRulesNotAdded(err, server, egressTable, egressNodeID, func() error {
+					// the gateway as of the latest update, the failed one may be outdated by the time of the retry
+					egressInfo, ok := latestEgressInfo(server, egressNodeID)
+					if !ok || skipGateway(egressInfo.EgressGwAddr.String()) {
+						return errGatewayRemoved
+					}
+					return fwCrtl.InsertEgressRoutingRules(server, egressInfo)
+				})
+			} else if config.Netclient().VerifyFirewallRules && !verifyRoutingRules(server, egressTable, egressNodeID) {
+				logger.Log(0, "egress routes not fully configured for gateway: ", egressNodeID)
+			}
 		} else {
 			peerRules := ruleTable[egressNodeID]
 			for _, peer := range egressInfo.GwPeers {
@@ -45,5 +68,7 @@ func SetEgressRoutes(server string, egressUpdate map[string]models.EgressInfo) e
 
 // DeleteEgressGwRoutes - deletes egress routes for the gateway
 func DeleteEgressGwRoutes(server string) {
+	forgetGatewayUpdates(server, egressTable)
+	forgetRuleRetries(server, egressTable)
 	fwCrtl.CleanRoutingRules(server, egressTable)
 }
@@ -0,0 +1,19 @@
+package router
+
+import "github.com/gravitl/netclient/config"
+
+// ctReturnStates - conntrack states of traffic belonging to connections initiated by peers
+const ctReturnStates = "ESTABLISHED,RELATED"
+
+// outboundOnlyEgress - checks if egress ranges may only answer connections initiated by peers
+func outboundOnlyEgress() bool {
+	return config.Netclient().EgressOutboundOnly
+}
+
+// outboundOnlyRuleSpecs - returns the forward rules for traffic from an egress range into the netmaker interface:
+// return traffic of peer initiated connections is accepted, everything else is dropped
+func outboundOnlyRuleSpecs(egressRange, ifaceName string) (accept, drop []string) {
+	accept = []string{"-s", egressRange, "-o", ifaceName, "-m", "conntrack", "--ctstate", ctReturnStates, "-j", targetAccept}
+	drop = []string{"-s", egressRange, "-o", ifaceName, "-j", targetDrop}
+	return accept, drop
+}
@@ -0,0 +1,69 @@
+package router
+
+import (
+	"net"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/binaryutil"
+	"github.com/google/nftables/expr"
+	"golang.org/x/sys/unix"
+)
+
+// nfOutboundOnlyRule - returns the nftables forward rule for traffic from the egress range into the interface,
+// matching only return traffic of established connections when accept is set
+func nfOutboundOnlyRule(ruleSpec []string, egressIP net.IP, cidr *net.IPNet, ifaceName string, isIpv4, accept bool) *nftables.Rule {
+	proto, offset, length, xor, ip := byte(unix.NFPROTO_IPV4), uint32(ipv4SrcOffset), uint32(ipv4Len), zeroXor, []byte(egressIP.To4())
+	if !isIpv4 {
+		proto, offset, length, xor, ip = unix.NFPROTO_IPV6, ipv6SrcOffset, ipv6Len, zeroXor6, egressIP.To16()
+	}
+	exprs := []expr.Any{
+		&expr.Meta{Key: expr.MetaKeyNFPROTO, Register: 1},
+		&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{proto}},
+		&expr.Meta{Key: expr.MetaKeyOIFNAME, Register: 1},
+		&expr.Cmp{
+			Op:       expr.CmpOpEq,
+			Register: 1,
+			Data:     []byte(ifaceName + "\x00"),
+		},
+		&expr.Payload{
+			DestRegister: 1,
+			Base:         expr.PayloadBaseNetworkHeader,
+			Offset:       offset,
+			Len:          length,
+		},
+		&expr.Bitwise{
+			DestRegister:   1,
+			SourceRegister: 1,
+			Len:            length,
+			Mask:           cidr.Mask,
+			Xor:            xor,
+		},
+		&expr.Cmp{
+			Register: 1,
+			Data:     ip,
+		},
+	}
+	verdict := expr.VerdictDrop
+	if accept {
+		verdict = expr.VerdictAccept
+		states := binaryutil.NativeEndian.PutUint32(expr.CtStateBitESTABLISHED | expr.CtStateBitRELATED)
+		exprs = append(exprs,
+			&expr.Ct{Register: 1, Key: expr.CtKeySTATE},
+			&expr.Bitwise{
+				SourceRegister: 1,
+				DestRegister:   1,
+				Len:            4,
+				Mask:           states,
+				Xor:            []byte{0, 0, 0, 0},
+			},
+			&expr.Cmp{Op: expr.CmpOpNeq, Register: 1, Data: []byte{0, 0, 0, 0}},
+		)
+	}
+	exprs = append(exprs, &expr.Counter{}, &expr.Verdict{Kind: verdict})
+	return &nftables.Rule{
+		Table:    filterTable,
+		Chain:    &nftables.Chain{Name: iptableFWDChain, Table: filterTable},
+		UserData: []byte(genRuleKey(ruleSpec...)),
+		Exprs:    exprs,
+	}
+}
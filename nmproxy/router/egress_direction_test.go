@@ -0,0 +1,30 @@
+package router
+
+import "testing"
+
+func TestOutboundOnlyEgress(t *testing.T) {
+	accept, drop := outboundOnlyRuleSpecs("192.168.10.0/24", "netmaker")
+	rules := []ruleInfo{
+		{table: "filter", chain: "FORWARD", rule: []string{"-i", "netmaker", "-d", "192.168.10.0/24", "-j", "netmakerfilter"}},
+		{table: "filter", chain: "FORWARD", rule: drop},
+		{table: "filter", chain: "FORWARD", rule: accept},
+		{table: "filter", chain: "netmakerfilter", rule: []string{"-s", "10.0.0.2", "-d", "192.168.10.0/24", "-j", "ACCEPT"}},
+	}
+	cases := []struct {
+		name    string
+		q       PacketQuery
+		verdict string
+	}{
+		{"peer initiated", PacketQuery{Src: "10.0.0.2", Dst: "192.168.10.4", InIface: "netmaker", OutIface: "eth1"}, PacketAccept},
+		{"return traffic", PacketQuery{Src: "192.168.10.4", Dst: "10.0.0.2", InIface: "eth1", OutIface: "netmaker", Established: true}, PacketAccept},
+		{"inbound initiated", PacketQuery{Src: "192.168.10.4", Dst: "10.0.0.2", InIface: "eth1", OutIface: "netmaker"}, PacketDrop},
+		{"other range not affected", PacketQuery{Src: "192.168.20.4", Dst: "10.0.0.2", InIface: "eth1", OutIface: "netmaker"}, PacketAccept},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if v := evaluatePacket(rules, tc.q, "netmaker"); v.Verdict != tc.verdict {
+				t.Errorf("got %+v, want verdict %s", v, tc.verdict)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package router
+
+import (
+	"net"
+	"sort"
+
+	"github.com/gravitl/netmaker/models"
+)
+
+// egressOverlap - ranges of two egress gateways that overlap
+type egressOverlap struct {
+	egressIDs [2]string
+	ranges    [2]string
+}
+
+// findEgressOverlaps - returns the ranges advertised by more than one egress gateway of the update
+func findEgressOverlaps(update map[string]models.EgressInfo) []egressOverlap {
+	ids := make([]string, 0, len(update))
+	for id := range update {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	overlaps := []egressOverlap{}
+	for i, id := range ids {
+		for _, otherID := range ids[i+1:] {
+			for _, r := range update[id].EgressGWCfg.Ranges {
+				for _, otherR := range update[otherID].EgressGWCfg.Ranges {
+					if rangesOverlap(r, otherR) {
+						overlaps = append(overlaps, egressOverlap{
+							egressIDs: [2]string{id, otherID},
+							ranges:    [2]string{r, otherR},
+						})
+					}
+				}
+			}
+		}
+	}
+	return overlaps
+}
+
+// rangesOverlap - checks if two cidrs share any address
+func rangesOverlap(a, b string) bool {
+	_, netA, err := net.ParseCIDR(a)
+	if err != nil {
+		return false
+	}
+	_, netB, err := net.ParseCIDR(b)
+	if err != nil {
+		return false
+	}
+	return netA.Contains(netB.IP) || netB.Contains(netA.IP)
+}
@@ -0,0 +1,23 @@
+package router
+
+// sharedNatRule - checks if another egress gateway holds the same nat rule, gateways with overlapping ranges
+// on one interface share their masquerade rules, which are only added once and kept until the last of them is removed
+func sharedNatRule(rt ruletable, egressID string, rule ruleInfo) bool {
+	if rule.table != defaultNatTable || rule.chain != nattablePRTChain {
+		return false
+	}
+	key := genRuleKey(rule.rule...)
+	for id, cfg := range rt {
+		if id == egressID {
+			continue
+		}
+		for _, rules := range cfg.rulesMap {
+			for _, r := range rules {
+				if r.table == rule.table && r.chain == rule.chain && genRuleKey(r.rule...) == key {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
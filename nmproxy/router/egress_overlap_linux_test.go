@@ -0,0 +1,68 @@
+package router
+
+import (
+	"testing"
+
+	"github.com/google/nftables"
+	"github.com/gravitl/netmaker/models"
+	"golang.org/x/sys/unix"
+)
+
+func TestFindEgressOverlaps(t *testing.T) {
+	egress := func(ranges ...string) models.EgressInfo {
+		return models.EgressInfo{EgressGWCfg: models.EgressGatewayRequest{Ranges: ranges}}
+	}
+	update := map[string]models.EgressInfo{
+		"egB": egress("10.20.1.0/24"),
+		"egA": egress("10.20.0.0/16", "192.168.1.0/24"),
+		"egC": egress("172.16.0.0/24"),
+	}
+	overlaps := findEgressOverlaps(update)
+	if len(overlaps) != 1 {
+		t.Fatalf("expected a single overlap, got %+v", overlaps)
+	}
+	want := egressOverlap{egressIDs: [2]string{"egA", "egB"}, ranges: [2]string{"10.20.0.0/16", "10.20.1.0/24"}}
+	if overlaps[0] != want {
+		t.Errorf("got %+v, want %+v", overlaps[0], want)
+	}
+}
+
+func TestSharedNatRuleOverlappingEgress(t *testing.T) {
+	masq := ruleInfo{table: defaultNatTable, chain: nattablePRTChain,
+		rule: []string{"-s", "10.10.0.0/24", "-o", "eth1", "-j", "MASQUERADE"}}
+	jump := ruleInfo{table: defaultIpTable, chain: iptableFWDChain,
+		rule: []string{"-i", "netmaker", "-d", "10.20.0.0/16", "-j", netmakerFilterChain}}
+	rt := ruletable{
+		"egA": {isIpv4: true, rulesMap: map[string][]ruleInfo{"egA": {jump, masq}}},
+		"egB": {isIpv4: true, rulesMap: map[string][]ruleInfo{"egB": {jump, masq}}},
+	}
+	if !sharedNatRule(rt, "egA", masq) || !sharedNatRule(rt, "egB", masq) {
+		t.Fatal("masquerade rule held by both gateways should be shared")
+	}
+	if sharedNatRule(rt, "egA", jump) {
+		t.Error("filter rules are added per gateway and should not be shared")
+	}
+	delete(rt, "egB")
+	if sharedNatRule(rt, "egA", masq) {
+		t.Error("masquerade rule should be removed with the last gateway holding it")
+	}
+
+	// a gateway advertising an overlapping range must not replace the rule of the other one
+	var sent []int
+	n := newTestNftManager(t, fakeNftKernel(nil, nil, &sent))
+	rt["egB"] = rulesCfg{isIpv4: true, rulesMap: map[string][]ruleInfo{"egB": {masq}}}
+	rule := &nftables.Rule{Table: natTable, Chain: &nftables.Chain{Name: nattablePRTChain, Table: natTable}}
+	if err := n.insertEgressNatRule(rt, "egA", rule, masq.rule); err != nil {
+		t.Fatal(err)
+	}
+	if sentMsg(sent, unix.NFT_MSG_NEWRULE) || sentMsg(sent, unix.NFT_MSG_DELRULE) {
+		t.Errorf("shared masquerade rule should be left untouched, sent %v", sent)
+	}
+	delete(rt, "egB")
+	if err := n.insertEgressNatRule(rt, "egA", rule, masq.rule); err != nil {
+		t.Fatal(err)
+	}
+	if !sentMsg(sent, unix.NFT_MSG_NEWRULE) {
+		t.Errorf("masquerade rule should be added when no other gateway holds it, sent %v", sent)
+	}
+}
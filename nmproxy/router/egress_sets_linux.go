@@ -0,0 +1,193 @@
+package router
+
+import (
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"strings"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+	"github.com/gravitl/netclient/ncutils"
+	"golang.org/x/sys/unix"
+)
+
+// nfSetNameMaxLen - longest set name accepted by all kernels, NFT_SET_MAXNAMELEN was 32 bytes including
+// the terminating null before it was raised in 4.15
+const nfSetNameMaxLen = 31
+
+// egressSetName - name of the set holding the ranges of an egress gateway of one ip family,
+// derived from the egress id as set names are limited in length
+func egressSetName(egressID string, isIpv4 bool) string {
+	family := "6"
+	if isIpv4 {
+		family = "4"
+	}
+	return fmt.Sprintf("%s%s_%x", egressSetPrefix, family, sha1.Sum([]byte(egressID)))[:nfSetNameMaxLen]
+}
+
+// splitRangesByFamily - parses the ranges into ipv4 and ipv6 networks, invalid ranges are skipped
+func splitRangesByFamily(ranges []string) (v4, v6 []*net.IPNet) {
+	for _, r := range ranges {
+		_, cidr, err := net.ParseCIDR(r)
+		if err != nil {
+			continue
+		}
+		if cidr.IP.To4() != nil {
+			v4 = append(v4, cidr)
+		} else {
+			v6 = append(v6, cidr)
+		}
+	}
+	return
+}
+
+// nfIntervalElements - the elements of an interval set matching the networks, each network is
+// its first address and the address following its last one, marked as the end of the interval
+func nfIntervalElements(cidrs []*net.IPNet, isIpv4 bool) []nftables.SetElement {
+	addrLen := net.IPv6len
+	if isIpv4 {
+		addrLen = net.IPv4len
+	}
+	var elements []nftables.SetElement
+	for _, cidr := range cidrs {
+		start := cidr.IP.Mask(cidr.Mask)
+		if isIpv4 {
+			start = start.To4()
+		} else {
+			start = start.To16()
+		}
+		elements = append(elements, nftables.SetElement{Key: start})
+		ones, bits := cidr.Mask.Size()
+		end := new(big.Int).SetBytes(start)
+		end.Add(end, new(big.Int).Lsh(big.NewInt(1), uint(bits-ones)))
+		if end.BitLen() > addrLen*8 {
+			// the network reaches the last address, the interval stays open
+			continue
+		}
+		key := make([]byte, addrLen)
+		end.FillBytes(key)
+		elements = append(elements, nftables.SetElement{Key: key, IntervalEnd: true})
+	}
+	return elements
+}
+
+// nfEgressSetRule - jump to the netmaker filter chain for traffic from the interface to any range in the set
+func nfEgressSetRule(set *nftables.Set, ruleSpec []string, isIpv4 bool) *nftables.Rule {
+	proto, offset, addrLen := byte(unix.NFPROTO_IPV4), uint32(ipv4DestOffset), uint32(ipv4Len)
+	if !isIpv4 {
+		proto, offset, addrLen = unix.NFPROTO_IPV6, ipv6DestOffset, ipv6Len
+	}
+	return &nftables.Rule{
+		Table:    filterTable,
+		Chain:    &nftables.Chain{Name: iptableFWDChain, Table: filterTable},
+		UserData: []byte(genRuleKey(ruleSpec...)),
+		Exprs: []expr.Any{
+			&expr.Meta{Key: expr.MetaKeyNFPROTO, Register: 1},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{proto}},
+			&expr.Meta{Key: expr.MetaKeyIIFNAME, Register: 1},
+			&expr.Cmp{
+				Op:       expr.CmpOpEq,
+				Register: 1,
+				Data:     []byte(ncutils.GetInterfaceName() + "\x00"),
+			},
+			&expr.Payload{
+				DestRegister: 1,
+				Base:         expr.PayloadBaseNetworkHeader,
+				Offset:       offset,
+				Len:          addrLen,
+			},
+			&expr.Lookup{SourceRegister: 1, SetName: set.Name, SetID: set.ID},
+			&expr.Counter{},
+			&expr.Verdict{
+				Kind:  expr.VerdictJump,
+				Chain: netmakerFilterChain,
+			},
+		},
+	}
+}
+
+// nftables.insertEgressRangeSets - adds a set per ip family holding the egress ranges and a single jump rule
+// matching each set, replacing the sets of a previous update; all of it is flushed at once so a failure
+// leaves the previous sets and rules in place
+func (n *nftablesManager) insertEgressRangeSets(egressID string, ranges []string) ([]ruleInfo, error) {
+	v4, v6 := splitRangesByFamily(ranges)
+	var routes []ruleInfo
+	for _, family := range []struct {
+		isIpv4  bool
+		cidrs   []*net.IPNet
+		keyType nftables.SetDatatype
+	}{
+		{isIpv4: true, cidrs: v4, keyType: nftables.TypeIPAddr},
+		{isIpv4: false, cidrs: v6, keyType: nftables.TypeIP6Addr},
+	} {
+		if len(family.cidrs) == 0 {
+			continue
+		}
+		set := &nftables.Set{
+			Table:    filterTable,
+			Name:     egressSetName(egressID, family.isIpv4),
+			KeyType:  family.keyType,
+			Interval: true,
+		}
+		n.stageEgressRangeSetRemoval(set.Name)
+		if err := n.conn.AddSet(set, nfIntervalElements(family.cidrs, family.isIpv4)); err != nil {
+			return nil, err
+		}
+		cidrs := make([]string, len(family.cidrs))
+		for i := range family.cidrs {
+			cidrs[i] = family.cidrs[i].String()
+		}
+		ruleSpec := []string{"-i", ncutils.GetInterfaceName(), "-d", strings.Join(cidrs, ","), "-j", netmakerFilterChain}
+		rule := nfEgressSetRule(set, ruleSpec, family.isIpv4)
+		n.conn.InsertRule(rule)
+		routes = append(routes, ruleInfo{
+			nfRule: rule,
+			table:  defaultIpTable,
+			chain:  iptableFWDChain,
+			rule:   ruleSpec,
+			set:    set.Name,
+		})
+	}
+	if err := n.flush(); err != nil {
+		return nil, err
+	}
+	return routes, nil
+}
+
+// nftables.stageEgressRangeSetRemoval - stages the removal of a set left behind by a previous egress update
+// and of the rules using it, sent with the caller's next flush
+func (n *nftablesManager) stageEgressRangeSetRemoval(name string) {
+	set, err := n.conn.GetSetByName(filterTable, name)
+	if err != nil || set == nil {
+		return
+	}
+	if rules, err := n.conn.GetRules(filterTable, &nftables.Chain{Name: iptableFWDChain}); err == nil {
+		for _, rule := range rules {
+			for _, e := range rule.Exprs {
+				if lookup, ok := e.(*expr.Lookup); ok && lookup.SetName == name {
+					_ = n.conn.DelRule(rule)
+				}
+			}
+		}
+	}
+	n.conn.DelSet(set)
+}
+
+// nftables.deleteRuleInfo - deletes a rule and the set it looks up, if any
+func (n *nftablesManager) deleteRuleInfo(rule ruleInfo) error {
+	err := n.deleteRule(rule.table, rule.chain, genRuleKey(rule.rule...))
+	if err != nil && !errors.Is(err, ErrRuleNotFound) {
+		return err
+	}
+	if rule.set != "" {
+		// the set of an already removed rule is removed as well
+		n.conn.DelSet(&nftables.Set{Table: filterTable, Name: rule.set})
+		if flushErr := n.flush(); flushErr != nil && err == nil {
+			return flushErr
+		}
+	}
+	return err
+}
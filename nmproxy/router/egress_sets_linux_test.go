@@ -0,0 +1,72 @@
+package router
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/google/nftables"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nltest"
+	"golang.org/x/sys/unix"
+)
+
+func TestNfIntervalElements(t *testing.T) {
+	v4, v6 := splitRangesByFamily([]string{"10.10.0.0/16", "192.168.1.7/32", "0.0.0.0/0", "fd00::/64", "bogus"})
+	if len(v4) != 3 || len(v6) != 1 {
+		t.Fatalf("unexpected split %v %v", v4, v6)
+	}
+	elements := nfIntervalElements(v4, true)
+	want := []nftables.SetElement{
+		{Key: net.ParseIP("10.10.0.0").To4()},
+		{Key: net.ParseIP("10.11.0.0").To4(), IntervalEnd: true},
+		{Key: net.ParseIP("192.168.1.7").To4()},
+		{Key: net.ParseIP("192.168.1.8").To4(), IntervalEnd: true},
+		// the default route has no end
+		{Key: net.ParseIP("0.0.0.0").To4()},
+	}
+	if len(elements) != len(want) {
+		t.Fatalf("expected %d elements, got %d", len(want), len(elements))
+	}
+	for i := range want {
+		if !bytes.Equal(elements[i].Key, want[i].Key) || elements[i].IntervalEnd != want[i].IntervalEnd {
+			t.Errorf("element %d: expected %v %v, got %v %v", i, want[i].Key, want[i].IntervalEnd, elements[i].Key, elements[i].IntervalEnd)
+		}
+	}
+	elements = nfIntervalElements(v6, false)
+	if len(elements) != 2 || !bytes.Equal(elements[1].Key, net.ParseIP("fd00:0:0:1::")) {
+		t.Errorf("unexpected ipv6 elements %v", elements)
+	}
+	if name := egressSetName("6f1c4d1e-7b8f-4d6f-a1c6-0b2e3f4a5b6c", true); len(name) != nfSetNameMaxLen || name == egressSetName("6f1c4d1e-7b8f-4d6f-a1c6-0b2e3f4a5b6c", false) {
+		t.Errorf("unexpected set name %s", name)
+	}
+}
+
+func TestInsertEgressRangeSets(t *testing.T) {
+	var sent []int
+	n := newTestNftManager(t, fakeNftKernel(nil, nil, &sent))
+	routes, err := n.insertEgressRangeSets("egress1", []string{"10.10.0.0/16", "10.20.0.0/16", "fd00::/64"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 2 {
+		t.Fatalf("expected a rule per ip family, got %d", len(routes))
+	}
+	if routes[0].set != egressSetName("egress1", true) || routes[1].set != egressSetName("egress1", false) {
+		t.Errorf("rules do not point at their sets: %q %q", routes[0].set, routes[1].set)
+	}
+	if !matchRule(routes[0].rule, PacketQuery{Dst: "10.20.3.4", InIface: routes[0].rule[1]}) {
+		t.Errorf("rule spec %v does not match an address of the second range", routes[0].rule)
+	}
+	if !sentMsg(sent, unix.NFT_MSG_NEWSET) || !sentMsg(sent, unix.NFT_MSG_NEWRULE) {
+		t.Errorf("expected sets and rules to be added, sent %v", sent)
+	}
+
+	// a failing flush leaves the caller to fall back to a rule per range
+	n = newTestNftManager(t, nftables.WithTestDial(func(req []netlink.Message) ([]netlink.Message, error) {
+		return nltest.Error(int(unix.EEXIST), req)
+	}))
+	if _, err := n.insertEgressRangeSets("egress1", []string{"10.10.0.0/16"}); err == nil {
+		t.Error("expected an error when the sets can not be added")
+	}
+}
@@ -0,0 +1,127 @@
+package router
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gravitl/netclient/ncutils"
+	"github.com/gravitl/netclient/nmproxy/wg"
+	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/models"
+)
+
+// trackedExtClient - ingress rule state of an ext client used for expiry
+type trackedExtClient struct {
+	addedAt   time.Time
+	expired   bool
+	expiredAt time.Time
+}
+
+// extClients - ext clients with ingress rules by server and ext peer key
+var extClients = struct {
+	mutex   sync.Mutex
+	tracked map[string]map[string]*trackedExtClient
+}{
+	tracked: make(map[string]map[string]*trackedExtClient),
+}
+
+// extClientIdle - checks if an ext client had no handshake within the ttl since its rules were added
+func extClientIdle(lastHandshake, addedAt, now time.Time, ttl time.Duration) bool {
+	lastActive := addedAt
+	if lastHandshake.After(lastActive) {
+		lastActive = lastHandshake
+	}
+	return now.Sub(lastActive) > ttl
+}
+
+// trackExtClient - records when the rules of the ext client were added if not known yet
+func trackExtClient(server, extPeerKey string, now time.Time) {
+	extClients.mutex.Lock()
+	defer extClients.mutex.Unlock()
+	if extClients.tracked[server] == nil {
+		extClients.tracked[server] = make(map[string]*trackedExtClient)
+	}
+	if _, ok := extClients.tracked[server][extPeerKey]; !ok {
+		extClients.tracked[server][extPeerKey] = &trackedExtClient{addedAt: now}
+	}
+}
+
+// isExtClientExpired - checks if the ingress rules of the ext client were removed for being idle
+func isExtClientExpired(server, extPeerKey string) bool {
+	extClients.mutex.Lock()
+	defer extClients.mutex.Unlock()
+	tracked, ok := extClients.tracked[server][extPeerKey]
+	return ok && tracked.expired
+}
+
+// pruneExtClients - forgets ext clients no longer present on the ingress gateway
+func pruneExtClients(server string, extPeers map[string]models.ExtClientInfo) {
+	extClients.mutex.Lock()
+	defer extClients.mutex.Unlock()
+	for extPeerKey := range extClients.tracked[server] {
+		if _, ok := extPeers[extPeerKey]; !ok {
+			delete(extClients.tracked[server], extPeerKey)
+		}
+	}
+}
+
+// ExpireIdleExtClients - removes the ingress rules of ext clients without a handshake for the ttl,
+// and reinstates the rules of expired ext clients which handshake again, it is to run on the proxy
+// manager loop as it changes the rules of the gateway updates applied there
+func ExpireIdleExtClients(ttl time.Duration) {
+	if fwCrtl == nil || ttl <= 0 {
+		return
+	}
+	peers, err := wg.GetPeers(ncutils.GetInterfaceName())
+	if err != nil {
+		logger.Log(1, "failed to fetch peers for ext client expiry: ", err.Error())
+		return
+	}
+	handshakes := make(map[string]time.Time)
+	for _, peer := range peers {
+		handshakes[peer.PublicKey.String()] = peer.LastHandshakeTime
+	}
+	expireIdleExtClients(handshakes, time.Now(), ttl)
+}
+
+func expireIdleExtClients(handshakes map[string]time.Time, now time.Time, ttl time.Duration) {
+	extClients.mutex.Lock()
+	defer extClients.mutex.Unlock()
+	for server, tracked := range extClients.tracked {
+		for extPeerKey, extClient := range tracked {
+			if extClient.expired {
+				if !handshakes[extPeerKey].After(extClient.expiredAt) {
+					continue
+				}
+				// the ext client as of the latest ingress update, it may have changed while expired
+				extInfo, egressRanges, ok := latestExtInfo(server, extPeerKey)
+				if !ok {
+					delete(tracked, extPeerKey)
+					continue
+				}
+				if skipGateway(extInfo.ExtPeerAddr.String()) {
+					continue
+				}
+				logger.Log(0, "reinstating ingress rules of active ext client: ", extPeerKey)
+				if err := fwCrtl.InsertIngressRoutingRules(server, extInfo, egressRanges); err != nil {
+					logger.Log(0, "failed to reinstate ingress rules of ext client: ", err.Error())
+					continue
+				}
+				extClient.expired = false
+				extClient.addedAt = now
+				continue
+			}
+			if !extClientIdle(handshakes[extPeerKey], extClient.addedAt, now, ttl) {
+				continue
+			}
+			logger.Log(0, "removing ingress rules of idle ext client: ", extPeerKey)
+			if err := fwCrtl.RemoveRoutingRules(server, ingressTable, extPeerKey); err != nil {
+				logger.Log(0, "failed to remove ingress rules of idle ext client: ", err.Error())
+				continue
+			}
+			forgetRuleRetry(server, ingressTable, extPeerKey)
+			extClient.expired = true
+			extClient.expiredAt = now
+		}
+	}
+}
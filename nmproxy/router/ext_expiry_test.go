@@ -0,0 +1,84 @@
+package router
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/models"
+)
+
+// expiryFirewall - in memory ingress rule table for ext client expiry
+type expiryFirewall struct {
+	firewallController
+	rules    ruletable
+	inserted map[string]models.ExtClientInfo
+}
+
+func (f *expiryFirewall) FetchRuleTable(server, tableName string) ruletable {
+	return f.rules
+}
+
+func (f *expiryFirewall) RemoveRoutingRules(server, tableName, peerKey string) error {
+	delete(f.rules, peerKey)
+	return nil
+}
+
+func (f *expiryFirewall) InsertIngressRoutingRules(server string, extInfo models.ExtClientInfo, egressRanges []string) error {
+	f.rules[extInfo.ExtPeerKey] = rulesCfg{rulesMap: map[string][]ruleInfo{}}
+	f.inserted[extInfo.ExtPeerKey] = extInfo
+	return nil
+}
+
+func TestExpireIdleExtClients(t *testing.T) {
+	fake := &expiryFirewall{rules: ruletable{}, inserted: map[string]models.ExtClientInfo{}}
+	prev := fwCrtl
+	fwCrtl = fake
+	defer func() {
+		fwCrtl = prev
+		pruneExtClients("server", nil)
+		forgetGatewayUpdates("server", ingressTable)
+	}()
+	ttl := time.Hour
+	added := time.Now().Add(-2 * ttl)
+	update := models.IngressInfo{ExtPeers: map[string]models.ExtClientInfo{}}
+	for _, key := range []string{"idle", "active", "deleted"} {
+		info := models.ExtClientInfo{ExtPeerKey: key, ExtPeerAddr: net.IPNet{IP: net.ParseIP("10.0.0.1")}}
+		_ = fake.InsertIngressRoutingRules("server", info, nil)
+		trackExtClient("server", key, added)
+		update.ExtPeers[key] = info
+	}
+	storeIngressUpdate("server", update)
+	now := time.Now()
+	handshakes := map[string]time.Time{"active": now.Add(-time.Minute)}
+	expireIdleExtClients(handshakes, now, ttl)
+
+	if _, ok := fake.rules["idle"]; ok {
+		t.Fatal("expected rules of the idle ext client to be removed")
+	}
+	if !isExtClientExpired("server", "idle") {
+		t.Fatal("expected idle ext client to be marked expired")
+	}
+	if _, ok := fake.rules["active"]; !ok {
+		t.Fatal("expected rules of the active ext client to be retained")
+	}
+	// the idle client changes its address while expired and comes back, the deleted one is gone from the update
+	update = models.IngressInfo{ExtPeers: map[string]models.ExtClientInfo{
+		"idle":   {ExtPeerKey: "idle", ExtPeerAddr: net.IPNet{IP: net.ParseIP("10.0.0.2")}},
+		"active": update.ExtPeers["active"],
+	}}
+	storeIngressUpdate("server", update)
+	later := now.Add(time.Minute)
+	handshakes["idle"] = later
+	handshakes["deleted"] = later
+	expireIdleExtClients(handshakes, later, ttl)
+	if _, ok := fake.rules["idle"]; !ok || isExtClientExpired("server", "idle") {
+		t.Fatal("expected rules to be reinstated after a new handshake")
+	}
+	if addr := fake.inserted["idle"].ExtPeerAddr.IP.String(); addr != "10.0.0.2" {
+		t.Fatalf("expected rules to be reinstated from the latest ingress update, got address %s", addr)
+	}
+	if _, ok := fake.rules["deleted"]; ok || isExtClientExpired("server", "deleted") {
+		t.Fatal("expected an ext client removed from the ingress update not to be reinstated")
+	}
+}
@@ -0,0 +1,64 @@
+package router
+
+import (
+	"net"
+
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netmaker/models"
+)
+
+// extClientIsolation - checks if the traffic between the ext. clients of an ingress gateway is dropped
+func extClientIsolation() bool {
+	return config.Netclient().ExtClientIsolation
+}
+
+// isolatedExtClients - returns the addresses of the other ext. clients of the server the ext. client is isolated from,
+// nil if isolation is disabled
+func isolatedExtClients(server string, extinfo models.ExtClientInfo) []net.IPNet {
+	if !extClientIsolation() {
+		return nil
+	}
+	ipv6StateMutex.Lock()
+	defer ipv6StateMutex.Unlock()
+	return otherExtClients(lastIngressUpdates[server], extinfo)
+}
+
+// otherExtClients - returns the addresses of the ext. clients in the update, other than the given one, of its ip family
+func otherExtClients(update models.IngressInfo, extinfo models.ExtClientInfo) []net.IPNet {
+	isIpv4 := isAddrIpv4(extinfo.ExtPeerAddr.String())
+	others := []net.IPNet{}
+	for key, ext := range update.ExtPeers {
+		if key == extinfo.ExtPeerKey || ext.ExtPeerAddr.IP == nil ||
+			isAddrIpv4(ext.ExtPeerAddr.String()) != isIpv4 {
+			continue
+		}
+		others = append(others, ext.ExtPeerAddr)
+	}
+	return others
+}
+
+// isolatedPeer - checks if the peer is an ext. client of the server that may not be reached by other ext. clients
+func isolatedPeer(server, peerKey string) bool {
+	if !extClientIsolation() {
+		return false
+	}
+	ipv6StateMutex.Lock()
+	defer ipv6StateMutex.Unlock()
+	_, ok := lastIngressUpdates[server].ExtPeers[peerKey]
+	return ok
+}
+
+// extIsolationPairs - returns the source and destination pairs of the traffic dropped between the ext. client and
+// the other ext. clients, both directions are covered so the drops precede the accept rules of whichever client was added first
+func extIsolationPairs(extAddr net.IPNet, others []net.IPNet) [][2]net.IPNet {
+	pairs := [][2]net.IPNet{}
+	for _, other := range others {
+		pairs = append(pairs, [2]net.IPNet{extAddr, other}, [2]net.IPNet{other, extAddr})
+	}
+	return pairs
+}
+
+// extIsolationRuleSpec - returns the rule dropping the traffic from src to dst
+func extIsolationRuleSpec(src, dst net.IPNet) []string {
+	return []string{"-s", src.String(), "-d", dst.String(), "-j", targetDrop}
+}
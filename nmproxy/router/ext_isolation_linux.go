@@ -0,0 +1,52 @@
+package router
+
+import (
+	"net"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+	"golang.org/x/sys/unix"
+)
+
+// nfExtIsolationRule - returns the nftables filter chain rule dropping the traffic from src to dst
+func nfExtIsolationRule(ruleSpec []string, src, dst net.IP, isIpv4 bool) *nftables.Rule {
+	proto, srcOffset, dstOffset, length := byte(unix.NFPROTO_IPV4), uint32(ipv4SrcOffset), uint32(ipv4DestOffset), uint32(ipv4Len)
+	srcIP, dstIP := []byte(src.To4()), []byte(dst.To4())
+	if !isIpv4 {
+		proto, srcOffset, dstOffset, length = unix.NFPROTO_IPV6, ipv6SrcOffset, ipv6DestOffset, ipv6Len
+		srcIP, dstIP = src.To16(), dst.To16()
+	}
+	return &nftables.Rule{
+		Table:    filterTable,
+		Chain:    &nftables.Chain{Name: netmakerFilterChain, Table: filterTable},
+		UserData: []byte(genRuleKey(ruleSpec...)),
+		Exprs: []expr.Any{
+			&expr.Meta{Key: expr.MetaKeyNFPROTO, Register: 1},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{proto}},
+			&expr.Payload{
+				DestRegister: 1,
+				Base:         expr.PayloadBaseNetworkHeader,
+				Offset:       srcOffset,
+				Len:          length,
+			},
+			&expr.Cmp{
+				Op:       expr.CmpOpEq,
+				Register: 1,
+				Data:     srcIP,
+			},
+			&expr.Payload{
+				DestRegister: 1,
+				Base:         expr.PayloadBaseNetworkHeader,
+				Offset:       dstOffset,
+				Len:          length,
+			},
+			&expr.Cmp{
+				Op:       expr.CmpOpEq,
+				Register: 1,
+				Data:     dstIP,
+			},
+			&expr.Counter{},
+			&expr.Verdict{Kind: expr.VerdictDrop},
+		},
+	}
+}
@@ -0,0 +1,76 @@
+package router
+
+import (
+	"net"
+	"testing"
+
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netmaker/models"
+)
+
+// insertedIngressRules - adds the ingress rules of the ext. clients with the nftables manager against a kernel
+// accepting every batch, returns the rules recorded for them along with their forward jump rules
+func insertedIngressRules(t *testing.T, server string, exts ...models.ExtClientInfo) []ruleInfo {
+	t.Helper()
+	jumpRules := nfJumpRules
+	defer func() { nfJumpRules = jumpRules }()
+	var batches int
+	n := newTestNftManager(t, failingRuleDial("", &batches))
+	n.ingRules = make(serverrulestable)
+	for _, ext := range exts {
+		if err := n.InsertIngressRoutingRules(server, ext, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rules := append([]ruleInfo{}, nfJumpRules[len(jumpRules):]...)
+	for _, ext := range exts {
+		for _, recorded := range n.ingRules[server][ext.ExtPeerKey].rulesMap {
+			rules = append(rules, recorded...)
+		}
+	}
+	return rules
+}
+
+func TestExtClientIsolation(t *testing.T) {
+	const server = "isolation.test"
+	toNet := func(cidr string) net.IPNet {
+		ip, n, _ := net.ParseCIDR(cidr)
+		n.IP = ip
+		return *n
+	}
+	peers := map[string]models.PeerRouteInfo{
+		"node": {PeerKey: "node", PeerAddr: toNet("10.10.0.1/32"), Allow: true},
+		"extA": {PeerKey: "extA", PeerAddr: toNet("10.10.0.5/32"), Allow: true},
+		"extB": {PeerKey: "extB", PeerAddr: toNet("10.10.0.6/32"), Allow: true},
+	}
+	ext := func(key, addr string) models.ExtClientInfo {
+		return models.ExtClientInfo{IngGwAddr: toNet("10.10.0.2/32"), Network: toNet("10.10.0.0/24"),
+			ExtPeerAddr: toNet(addr), ExtPeerKey: key, Peers: peers}
+	}
+	update := models.IngressInfo{ExtPeers: map[string]models.ExtClientInfo{
+		"extA": ext("extA", "10.10.0.5/32"),
+		"extB": ext("extB", "10.10.0.6/32"),
+	}}
+	storeIngressUpdate(server, update)
+	defer forgetGatewayUpdates(server, ingressTable)
+	defer func() { config.Netclient().ExtClientIsolation = false }()
+
+	for _, isolated := range []bool{false, true} {
+		config.Netclient().ExtClientIsolation = isolated
+		rules := insertedIngressRules(t, server, update.ExtPeers["extA"], update.ExtPeers["extB"])
+		for _, q := range []PacketQuery{{Src: "10.10.0.5", Dst: "10.10.0.6"}, {Src: "10.10.0.6", Dst: "10.10.0.5"}} {
+			q.InIface = "netmaker"
+			want := PacketAccept
+			if isolated {
+				want = PacketDrop
+			}
+			if v := evaluatePacket(rules, q, "netmaker"); v.Verdict != want {
+				t.Errorf("isolation %v: %s -> %s got %+v, want %s", isolated, q.Src, q.Dst, v, want)
+			}
+		}
+		q := PacketQuery{Src: "10.10.0.5", Dst: "10.10.0.1", InIface: "netmaker"}
+		if v := evaluatePacket(rules, q, "netmaker"); v.Verdict != PacketAccept {
+			t.Errorf("isolation %v: ext client to node got %+v, want accept", isolated, v)
+		}
+	}
+}
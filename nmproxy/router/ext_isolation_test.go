@@ -0,0 +1,20 @@
+package router
+
+import (
+	"net"
+	"testing"
+
+	"github.com/gravitl/netmaker/models"
+)
+
+func TestOtherExtClientsFamily(t *testing.T) {
+	update := models.IngressInfo{ExtPeers: map[string]models.ExtClientInfo{
+		"a": {ExtPeerKey: "a", ExtPeerAddr: net.IPNet{IP: net.ParseIP("10.10.0.5"), Mask: net.CIDRMask(32, 32)}},
+		"b": {ExtPeerKey: "b", ExtPeerAddr: net.IPNet{IP: net.ParseIP("10.10.0.6"), Mask: net.CIDRMask(32, 32)}},
+		"c": {ExtPeerKey: "c", ExtPeerAddr: net.IPNet{IP: net.ParseIP("fd00::6"), Mask: net.CIDRMask(128, 128)}},
+	}}
+	others := otherExtClients(update, update.ExtPeers["a"])
+	if len(others) != 1 || others[0].IP.String() != "10.10.0.6" {
+		t.Fatalf("expected only the other ipv4 ext client, got %v", others)
+	}
+}
@@ -1,13 +1,27 @@
 package router
 
 import (
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netclient/ncutils"
 	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/models"
 )
 
 var (
-	fwCrtl              firewallController
+	fwCrtl firewallController
+	// fwCrtlMutex - guards setting fwCrtl against the readers outside of the proxy manager loop
+	fwCrtlMutex         sync.RWMutex
 	currEgressRangesMap = make(map[string][]string)
+	// ErrRuleNotFound - the rule to delete is not in the firewall, callers treat it as already removed
+	ErrRuleNotFound = errors.New("no such rule exists")
+	// ruleLog - collapses the repeated rule failures of a firewall that keeps failing
+	ruleLog = ncutils.NewLogLimiter(time.Minute)
 )
 
 type rulesCfg struct {
@@ -21,6 +35,8 @@ type ruleInfo struct {
 	table         string
 	chain         string
 	egressExtRule bool
+	// set - name of the set the rule looks up, removed along with the rule
+	set string
 }
 type ruletable map[string]rulesCfg
 
@@ -29,8 +45,57 @@ type serverrulestable map[string]ruletable
 const (
 	ingressTable = "ingress"
 	egressTable  = "egress"
+	// defaultIfaceLookupAttempts - attempts to find the interface before building rules, when not configured
+	defaultIfaceLookupAttempts = 5
 )
 
+// firewall backends reported to the server
+const (
+	// FirewallIptables - rules are managed through iptables
+	FirewallIptables = "iptables"
+	// FirewallNftables - rules are managed through nftables
+	FirewallNftables = "nftables"
+	// FirewallNone - no supported firewall was found
+	FirewallNone = "none"
+)
+
+// FirewallInfo - the firewall backend of the host and what it supports
+type FirewallInfo struct {
+	Backend      string   `json:"backend"`
+	Capabilities []string `json:"capabilities"`
+}
+
+// GetFirewallInfo - returns the firewall backend selected for the host and its capabilities
+func GetFirewallInfo() FirewallInfo {
+	backend := firewallBackend()
+	return FirewallInfo{
+		Backend:      backend,
+		Capabilities: firewallCapabilities(backend, ipv6Enabled() && backendSupportsIPv6(backend)),
+	}
+}
+
+// firewallCapabilities - returns the capabilities of the backend, ipv6 only while ipv6 rules can be added
+func firewallCapabilities(backend string, ipv6 bool) []string {
+	if backend == FirewallNone {
+		return []string{}
+	}
+	capabilities := []string{"ingress", "egress", "nat"}
+	if ipv6 {
+		capabilities = append(capabilities, "ipv6")
+	}
+	return capabilities
+}
+
+// activeFirewall - returns the firewall controller set by Init, nil before it
+func activeFirewall() firewallController {
+	fwCrtlMutex.RLock()
+	defer fwCrtlMutex.RUnlock()
+	return fwCrtl
+}
+
+// errEmptyIfaceName - returned instead of building rules which match no interface
+var errEmptyIfaceName = errors.New("interface name is empty, refusing to build firewall rules")
+
 type firewallController interface {
 	// CreateChains  creates a firewall chains and jump rules
 	CreateChains() error
@@ -60,19 +125,46 @@ type firewallController interface {
 	SaveRules(server, ruleTableName string, ruleTable ruletable)
 	// FlushAll - clears all rules from netmaker chains and deletes the chains
 	FlushAll()
+	// RestoreRules - restores the rule tables persisted by a previous run
+	RestoreRules() error
+	// MissingRules - returns the recorded rules of a peer which are not present in the firewall
+	MissingRules(server, tableName, peerKey string) []ruleInfo
+	// RuleState - returns a copy of the rule tables
+	RuleState() savedRuleState
+}
+
+// RuleReconcileInterval - how often the rule tables are reconciled with the rules in the kernel
+const RuleReconcileInterval = 5 * time.Minute
+
+// ReconcileRules - repairs the drift between the rule tables and the firewall, e.g. after the ruleset was flushed
+// outside of netclient, called from the proxy manager loop so it doesn't race with the firewall updates
+func ReconcileRules() {
+	if fwCrtl == nil {
+		return
+	}
+	reconcileRules()
 }
 
-// Init - initialises the firewall controller,return a close func to flush all rules
+// Init - initialises the firewall controller, return a close func to flush all rules,
+// the retries of failed rules are run by the caller with RetryFailedRules
 func Init() (func(), error) {
-	var err error
 	logger.Log(0, "Starting firewall...")
-	fwCrtl, err = newFirewall()
+	controller, err := newFirewall()
 	if err != nil {
 		return nil, err
 	}
+	fwCrtlMutex.Lock()
+	fwCrtl = controller
+	fwCrtlMutex.Unlock()
+	if err := waitForInterface(); err != nil {
+		return nil, err
+	}
 	if err := fwCrtl.CreateChains(); err != nil {
 		return fwCrtl.FlushAll, err
 	}
+	if err := fwCrtl.RestoreRules(); err != nil {
+		logger.Log(0, "failed to restore firewall rules: ", err.Error())
+	}
 	return fwCrtl.FlushAll, nil
 }
 
@@ -82,8 +174,62 @@ func EnableForwardRule() error {
 	if err != nil {
 		return err
 	}
-	if controller.ForwardRule(); err != nil {
+	if err := controller.ForwardRule(); err != nil {
 		return err
 	}
 	return nil
 }
+
+// validateIfaceName - ensures rules are not built with an empty interface name
+func validateIfaceName(ifaceName string) error {
+	if ifaceName == "" {
+		return errEmptyIfaceName
+	}
+	return nil
+}
+
+// waitForInterface - retries the interface lookup until the interface exists,
+// the number of attempts is set by the host's IfaceLookupAttempts
+func waitForInterface() error {
+	attempts := config.Netclient().IfaceLookupAttempts
+	if attempts <= 0 {
+		attempts = defaultIfaceLookupAttempts
+	}
+	return ncutils.RetryWithBackoff(attempts, time.Second, func() error {
+		ifaceName := ncutils.GetInterfaceName()
+		if err := validateIfaceName(ifaceName); err != nil {
+			return err
+		}
+		_, err := net.InterfaceByName(ifaceName)
+		return err
+	})
+}
+
+// missingRules - returns the rules of cfg for which present reports false
+func missingRules(cfg rulesCfg, present func(isIpv4 bool, rule ruleInfo) bool) []ruleInfo {
+	missing := []ruleInfo{}
+	for _, rules := range cfg.rulesMap {
+		for _, rule := range rules {
+			if !present(cfg.isIpv4, rule) {
+				missing = append(missing, rule)
+			}
+		}
+	}
+	return missing
+}
+
+// verifyRoutingRules - confirms the recorded rules of a peer are present in the firewall,
+// on a discrepancy the peer's rules are removed so the next update reconciles them
+func verifyRoutingRules(server, tableName, peerKey string) bool {
+	missing := fwCrtl.MissingRules(server, tableName, peerKey)
+	if len(missing) == 0 {
+		return true
+	}
+	for _, rule := range missing {
+		logger.Log(0, "rule recorded but not present in firewall: ", rule.table, rule.chain, strings.Join(rule.rule, " "))
+	}
+	if err := fwCrtl.RemoveRoutingRules(server, tableName, peerKey); err != nil {
+		logger.Log(0, "failed to remove rules for reconcile: ", err.Error())
+	}
+	return false
+}
@@ -2,8 +2,11 @@ package router
 
 import (
 	"errors"
+	"fmt"
 	"net"
+	"os"
 	"os/exec"
+	"strings"
 
 	"github.com/coreos/go-iptables/iptables"
 	"github.com/google/nftables"
@@ -11,13 +14,13 @@ import (
 	"github.com/vishvananda/netlink"
 )
 
-// newFirewall if supported, returns an iptables manager, otherwise returns a nftables manager
+// newFirewall - returns the manager of the firewall backend selected for the host
 func newFirewall() (firewallController, error) {
 
 	var manager firewallController
 
-	if isIptablesSupported() {
-		logger.Log(0, "iptables is supported")
+	switch hostFirewallBackend() {
+	case FirewallIptables:
 		ipv4Client, _ := iptables.NewWithProtocol(iptables.ProtocolIPv4)
 		ipv6Client, _ := iptables.NewWithProtocol(iptables.ProtocolIPv6)
 		manager = &iptablesManager{
@@ -27,12 +30,14 @@ func newFirewall() (firewallController, error) {
 			engressRules: make(serverrulestable),
 		}
 		return manager, nil
-	}
-	logger.Log(0, "iptables is not supported, using nftables")
-	if isNftablesSupported() {
-		logger.Log(0, "nftables is supported")
+	case FirewallNftables:
+		conn, err := newNftConn(nftConnOptionsFromConfig())
+		if err != nil {
+			logger.Log(0, "failed to open nftables connection with configured options, using defaults:", netlinkErr(err).Error())
+			conn = &nftables.Conn{}
+		}
 		manager = &nftablesManager{
-			conn:         &nftables.Conn{},
+			conn:         conn,
 			ingRules:     make(serverrulestable),
 			engressRules: make(serverrulestable),
 		}
@@ -42,6 +47,88 @@ func newFirewall() (firewallController, error) {
 	return manager, errors.New("firewall support not found")
 }
 
+// firewallBackend - returns the backend in use, or the one newFirewall selects on this host
+func firewallBackend() string {
+	switch activeFirewall().(type) {
+	case *iptablesManager:
+		return FirewallIptables
+	case *nftablesManager:
+		return FirewallNftables
+	}
+	return hostFirewallBackend()
+}
+
+// backendSupportsIPv6 - checks if the backend can add ipv6 rules, the inet tables of nftables always can
+// while iptables needs ip6tables
+func backendSupportsIPv6(backend string) bool {
+	switch backend {
+	case FirewallNftables:
+		return true
+	case FirewallIptables:
+		if i, ok := activeFirewall().(*iptablesManager); ok {
+			return i.ipv6Client != nil
+		}
+		_, err := exec.LookPath("ip6tables")
+		_, errnft := exec.LookPath("ip6tables-nft")
+		return err == nil || errnft == nil
+	}
+	return false
+}
+
+// reconcileRules - reconciles the rule tables of every server with the kernel, only nftables supports it
+func reconcileRules() {
+	n, ok := fwCrtl.(*nftablesManager)
+	if !ok {
+		return
+	}
+	for _, table := range n.reconcileTargets() {
+		summary, err := n.Reconcile(table[0], table[1])
+		if err != nil {
+			logger.Log(0, "failed to reconcile rule table", table[1], "of server", table[0], err.Error())
+			continue
+		}
+		if summary != (ReconcileSummary{}) {
+			logger.Log(0, "reconciled rule table", table[1], "of server", table[0], fmt.Sprintf("%+v", summary))
+		}
+	}
+}
+
+// probeFirewall - probes the host for the ip_tables module and the firewall binaries
+func probeFirewall() firewallProbe {
+	_, err := os.Stat("/proc/net/ip_tables_names")
+	return firewallProbe{
+		legacyIptables: err == nil,
+		iptables:       isIptablesSupported(),
+		nftables:       isNftablesSupported(),
+	}
+}
+
+// captureRuleset - returns the live ruleset of the firewall backend in use
+func captureRuleset() (string, error) {
+	var cmds [][]string
+	switch firewallBackend() {
+	case FirewallNftables:
+		// only the tables netclient adds its chains to
+		cmds = [][]string{
+			{"nft", "list", "table", "inet", filterTable.Name},
+			{"nft", "list", "table", "inet", natTable.Name},
+		}
+	case FirewallIptables:
+		cmds = [][]string{{"iptables-save"}, {"ip6tables-save"}}
+	default:
+		return "", errors.New("firewall support not found")
+	}
+	var ruleset strings.Builder
+	for _, cmd := range cmds {
+		out, err := exec.Command(cmd[0], cmd[1:]...).CombinedOutput()
+		if err != nil {
+			return ruleset.String(), fmt.Errorf("%s failed: %w %s", cmd[0], err, out)
+		}
+		ruleset.Write(out)
+	}
+	return ruleset.String(), nil
+}
+
 func isIptablesSupported() bool {
 	_, err4 := exec.LookPath("iptables")
 	_, err6 := exec.LookPath("ip6tables")
@@ -55,10 +142,7 @@ func getInterfaceName(dst net.IPNet) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	if dst.String() == "0.0.0.0/0" || dst.String() == "::/0" {
-		dst.IP = net.ParseIP("1.1.1.1")
-	}
-	routes, err := h.RouteGet(dst.IP)
+	routes, err := h.RouteGet(routeLookupIP(dst))
 	if err != nil {
 		return "", err
 	}
@@ -71,6 +155,18 @@ func getInterfaceName(dst net.IPNet) (string, error) {
 	return "", errors.New("interface not found for: " + dst.String())
 }
 
+// routeLookupIP - the address the route to dst is looked up with, a public address of the family for default ranges
+// so ipv6 default ranges resolve to the ipv6 default route
+func routeLookupIP(dst net.IPNet) net.IP {
+	if ones, _ := dst.Mask.Size(); ones != 0 {
+		return dst.IP
+	}
+	if dst.IP.To4() == nil {
+		return net.ParseIP("2606:4700:4700::1111")
+	}
+	return net.ParseIP("1.1.1.1")
+}
+
 func isNftablesSupported() bool {
 	_, err := exec.LookPath("nft")
 	return err == nil
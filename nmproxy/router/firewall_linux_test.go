@@ -0,0 +1,25 @@
+package router
+
+import (
+	"net"
+	"testing"
+)
+
+func TestRouteLookupIP(t *testing.T) {
+	for dst, isIPv4 := range map[string]bool{
+		"0.0.0.0/0":       true,
+		"::/0":            false,
+		"10.10.0.0/16":    true,
+		"2001:db8::/64":   false,
+		"198.51.100.0/24": true,
+	} {
+		_, ipNet, _ := net.ParseCIDR(dst)
+		ip := routeLookupIP(*ipNet)
+		if (ip.To4() != nil) != isIPv4 {
+			t.Errorf("expected the route to %s to be looked up in its own family, got %s", dst, ip)
+		}
+		if ones, _ := ipNet.Mask.Size(); ones != 0 && !ip.Equal(ipNet.IP) {
+			t.Errorf("expected the route to %s to be looked up with its address, got %s", dst, ip)
+		}
+	}
+}
@@ -4,6 +4,8 @@
 package router
 
 import (
+	"errors"
+
 	"github.com/gravitl/netmaker/models"
 )
 
@@ -57,6 +59,40 @@ func (unimplementedFirewall) DeleteRuleTable(server, ruleTableName string) {
 
 }
 
+func (unimplementedFirewall) RestoreRules() error {
+	return nil
+}
+
+func (unimplementedFirewall) MissingRules(server, tableName, peerKey string) []ruleInfo {
+	return []ruleInfo{}
+}
+
+func (unimplementedFirewall) RuleState() savedRuleState {
+	return savedRuleState{}
+}
+
+// reconcileRules - no firewall backend is supported on this platform
+func reconcileRules() {}
+
+// probeFirewall - no firewall backend is supported on this platform
+func probeFirewall() firewallProbe {
+	return firewallProbe{}
+}
+
+// firewallBackend - no firewall backend is supported on this platform
+func firewallBackend() string {
+	return FirewallNone
+}
+
+// backendSupportsIPv6 - no firewall backend is supported on this platform
+func backendSupportsIPv6(backend string) bool {
+	return false
+}
+
+func captureRuleset() (string, error) {
+	return "", errors.New("firewall is not supported on this platform")
+}
+
 // newFirewall returns an unimplemented Firewall manager
 func newFirewall() (firewallController, error) {
 	return unimplementedFirewall{}, nil
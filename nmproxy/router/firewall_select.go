@@ -0,0 +1,66 @@
+package router
+
+import (
+	"sync"
+
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netmaker/logger"
+)
+
+// firewallProbe - what the host offers to the firewall backends
+type firewallProbe struct {
+	// legacyIptables - the ip_tables kernel module is loaded, /proc/net/ip_tables_names exists
+	legacyIptables bool
+	// iptables - the iptables and ip6tables binaries are installed
+	iptables bool
+	// nftables - the nft binary is installed
+	nftables bool
+}
+
+// firewallProber - probes the host for the firewall backends
+type firewallProber func() firewallProbe
+
+// selectedFirewall - the backend selected for the host, selected once per run
+var selectedFirewall struct {
+	once    sync.Once
+	backend string
+}
+
+// hostFirewallBackend - returns the backend selected for the host, selecting and logging it on first use
+func hostFirewallBackend() string {
+	selectedFirewall.once.Do(func() {
+		selectedFirewall.backend = selectFirewallBackend(config.Netclient().FirewallBackend, probeFirewall)
+		logger.Log(0, "selected firewall backend:", selectedFirewall.backend)
+	})
+	return selectedFirewall.backend
+}
+
+// selectFirewallBackend - picks the firewall backend, a configured backend is used if the host supports it,
+// otherwise iptables is preferred on hosts with the legacy ip_tables module loaded, where nftables rules may not match
+func selectFirewallBackend(override string, probe firewallProber) string {
+	p := probe()
+	switch override {
+	case "":
+	case FirewallIptables:
+		if p.iptables {
+			return FirewallIptables
+		}
+		logger.Log(0, "configured firewall backend iptables is not available on the host")
+	case FirewallNftables:
+		if p.nftables {
+			return FirewallNftables
+		}
+		logger.Log(0, "configured firewall backend nftables is not available on the host")
+	default:
+		logger.Log(0, "unknown firewall backend configured:", override)
+	}
+	switch {
+	case p.legacyIptables && p.iptables:
+		return FirewallIptables
+	case p.nftables:
+		return FirewallNftables
+	case p.iptables:
+		return FirewallIptables
+	}
+	return FirewallNone
+}
@@ -0,0 +1,37 @@
+package router
+
+import "testing"
+
+func TestSelectFirewallBackend(t *testing.T) {
+	tests := []struct {
+		name     string
+		override string
+		probe    firewallProbe
+		want     string
+	}{
+		{name: "legacy ip_tables module", probe: firewallProbe{legacyIptables: true, iptables: true, nftables: true}, want: FirewallIptables},
+		{name: "nftables preferred", probe: firewallProbe{iptables: true, nftables: true}, want: FirewallNftables},
+		{name: "only iptables", probe: firewallProbe{iptables: true}, want: FirewallIptables},
+		{name: "legacy module without binaries", probe: firewallProbe{legacyIptables: true, nftables: true}, want: FirewallNftables},
+		{name: "nothing", probe: firewallProbe{}, want: FirewallNone},
+		{name: "override iptables", override: FirewallIptables, probe: firewallProbe{iptables: true, nftables: true}, want: FirewallIptables},
+		{name: "override nftables", override: FirewallNftables, probe: firewallProbe{legacyIptables: true, iptables: true, nftables: true}, want: FirewallNftables},
+		{name: "override unavailable", override: FirewallNftables, probe: firewallProbe{iptables: true}, want: FirewallIptables},
+		{name: "override unknown", override: "pf", probe: firewallProbe{nftables: true}, want: FirewallNftables},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			probed := 0
+			got := selectFirewallBackend(tt.override, func() firewallProbe {
+				probed++
+				return tt.probe
+			})
+			if got != tt.want {
+				t.Errorf("expected %s, got %s", tt.want, got)
+			}
+			if probed != 1 {
+				t.Errorf("expected the host to be probed once, got %d", probed)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package router
+
+import (
+	"strings"
+	"testing"
+)
+
+// fakeFirewall - records rule tables in memory, rules listed in kernel are treated as present
+type fakeFirewall struct {
+	firewallController
+	rules   ruletable
+	kernel  map[string]bool
+	removed []string
+}
+
+func (f *fakeFirewall) MissingRules(server, tableName, peerKey string) []ruleInfo {
+	return missingRules(f.rules[peerKey], func(isIpv4 bool, rule ruleInfo) bool {
+		return f.kernel[rule.rule[0]]
+	})
+}
+
+func (f *fakeFirewall) RemoveRoutingRules(server, tableName, peerKey string) error {
+	f.removed = append(f.removed, peerKey)
+	delete(f.rules, peerKey)
+	return nil
+}
+
+func TestVerifyRoutingRules(t *testing.T) {
+	fake := &fakeFirewall{
+		rules: ruletable{
+			"peer": rulesCfg{
+				isIpv4: true,
+				rulesMap: map[string][]ruleInfo{
+					"peer":  {{rule: []string{"fwd"}}},
+					"other": {{rule: []string{"nat"}}},
+				},
+			},
+		},
+		kernel: map[string]bool{"fwd": true, "nat": true},
+	}
+	prev := fwCrtl
+	fwCrtl = fake
+	defer func() { fwCrtl = prev }()
+
+	if !verifyRoutingRules("server", "ingress", "peer") {
+		t.Fatal("expected all rules to be verified")
+	}
+	// the nat rule silently disappears from the kernel
+	delete(fake.kernel, "nat")
+	if missing := fake.MissingRules("server", "ingress", "peer"); len(missing) != 1 || missing[0].rule[0] != "nat" {
+		t.Fatalf("expected the nat rule to be reported missing, got %+v", missing)
+	}
+	if verifyRoutingRules("server", "ingress", "peer") {
+		t.Fatal("expected verification to catch the missing rule")
+	}
+	if len(fake.removed) != 1 || fake.removed[0] != "peer" {
+		t.Fatalf("expected peer rules to be removed for reconcile, got %v", fake.removed)
+	}
+}
+
+func TestFirewallCapabilities(t *testing.T) {
+	for _, tc := range []struct {
+		backend  string
+		ipv6     bool
+		expected []string
+	}{
+		{backend: FirewallNftables, ipv6: true, expected: []string{"ingress", "egress", "nat", "ipv6"}},
+		{backend: FirewallIptables, ipv6: false, expected: []string{"ingress", "egress", "nat"}},
+		{backend: FirewallNone, ipv6: true, expected: []string{}},
+	} {
+		got := firewallCapabilities(tc.backend, tc.ipv6)
+		if strings.Join(got, ",") != strings.Join(tc.expected, ",") || got == nil {
+			t.Errorf("%s ipv6=%v: expected %v, got %v", tc.backend, tc.ipv6, tc.expected, got)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+package router
+
+import "github.com/gravitl/netclient/config"
+
+// forwardDropPolicy - checks if the forward chain drops the traffic no rule accepts
+func forwardDropPolicy() bool {
+	return config.Netclient().ForwardDropPolicy
+}
@@ -0,0 +1,125 @@
+package router
+
+import (
+	"testing"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/binaryutil"
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netclient/ncutils"
+	"github.com/mdlayher/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// fakePolicyKernel - tracks the policy of the forward chain and whether it holds the netmaker jump rule,
+// applying every batch at once, and counts the batches leaving the policy dropping without the jump rule
+func fakePolicyKernel(jumpKey string, dropWindows *int) nftables.ConnOption {
+	nftMsg := func(msgType int, req netlink.Message, attrs []netlink.Attribute) netlink.Message {
+		data, _ := netlink.MarshalAttributes(attrs)
+		return netlink.Message{
+			Header: netlink.Header{
+				Type:     netlink.HeaderType((unix.NFNL_SUBSYS_NFTABLES << 8) | msgType),
+				Sequence: req.Header.Sequence,
+				PID:      req.Header.PID,
+			},
+			Data: append([]byte{unix.NFPROTO_INET, 0, 0, 0}, data...),
+		}
+	}
+	attrs := func(m netlink.Message) map[uint16][]byte {
+		parsed := map[uint16][]byte{}
+		decoded, _ := netlink.UnmarshalAttributes(m.Data[4:])
+		for _, a := range decoded {
+			parsed[a.Type] = a.Data
+		}
+		return parsed
+	}
+	// the state a previous run with the drop policy left behind
+	policy, jumpPresent := uint32(nftables.ChainPolicyDrop), true
+	return nftables.WithTestDial(func(req []netlink.Message) ([]netlink.Message, error) {
+		var replies []netlink.Message
+		batch := false
+		for _, m := range req {
+			switch int(m.Header.Type) & 0xff {
+			case unix.NFT_MSG_GETCHAIN:
+				for _, chain := range [][2]string{{defaultIpTable, iptableFWDChain}, {defaultIpTable, netmakerFilterChain}} {
+					replies = append(replies, nftMsg(unix.NFT_MSG_NEWCHAIN, m, []netlink.Attribute{
+						{Type: unix.NFTA_CHAIN_TABLE, Data: []byte(chain[0] + "\x00")},
+						{Type: unix.NFTA_CHAIN_NAME, Data: []byte(chain[1] + "\x00")},
+					}))
+				}
+			case unix.NFT_MSG_GETRULE:
+				if string(attrs(m)[unix.NFTA_RULE_CHAIN]) == iptableFWDChain+"\x00" && jumpPresent {
+					replies = append(replies, nftMsg(unix.NFT_MSG_NEWRULE, m, []netlink.Attribute{
+						{Type: unix.NFTA_RULE_TABLE, Data: []byte(defaultIpTable + "\x00")},
+						{Type: unix.NFTA_RULE_CHAIN, Data: []byte(iptableFWDChain + "\x00")},
+						{Type: unix.NFTA_RULE_HANDLE, Data: binaryutil.BigEndian.PutUint64(1)},
+						{Type: unix.NFTA_RULE_USERDATA, Data: []byte(jumpKey)},
+					}))
+				}
+			case unix.NFT_MSG_GETSET:
+			case unix.NFT_MSG_NEWCHAIN:
+				batch = true
+				a := attrs(m)
+				if p, ok := a[unix.NFTA_CHAIN_POLICY]; ok && string(a[unix.NFTA_CHAIN_NAME]) == iptableFWDChain+"\x00" {
+					policy = binaryutil.BigEndian.Uint32(p)
+				}
+				replies = append(replies, m)
+			case unix.NFT_MSG_NEWRULE:
+				batch = true
+				if string(attrs(m)[unix.NFTA_RULE_USERDATA]) == jumpKey {
+					jumpPresent = true
+				}
+				replies = append(replies, m)
+			case unix.NFT_MSG_DELRULE:
+				batch = true
+				if binaryutil.BigEndian.Uint64(attrs(m)[unix.NFTA_RULE_HANDLE]) == 1 {
+					jumpPresent = false
+				}
+				replies = append(replies, m)
+			default:
+				replies = append(replies, m)
+			}
+		}
+		if batch && policy == uint32(nftables.ChainPolicyDrop) && !jumpPresent {
+			*dropWindows++
+		}
+		return replies, nil
+	})
+}
+
+func TestCreateChainsDropPolicyOrdering(t *testing.T) {
+	defer func() {
+		config.Netclient().ForwardDropPolicy = false
+		nfJumpRules, nfFilterJumpRules, nfNatJumpRules = nil, nil, nil
+	}()
+	config.Netclient().ForwardDropPolicy = true
+	jumpKey := string(nfForwardJumpRule(ncutils.GetInterfaceName()).nfRule.(*nftables.Rule).UserData)
+
+	var dropWindows int
+	n := newTestNftManager(t, fakePolicyKernel(jumpKey, &dropWindows))
+	if err := n.CreateChains(); err != nil {
+		t.Fatal(err)
+	}
+	if dropWindows != 0 {
+		t.Errorf("expected the forward chain to never drop without the jump rule, %d batches did", dropWindows)
+	}
+}
+
+func TestNftFlushAllResetsDropPolicy(t *testing.T) {
+	defer func() {
+		config.Netclient().ForwardDropPolicy = false
+		nfJumpRules, nfFilterJumpRules, nfNatJumpRules = nil, nil, nil
+	}()
+	config.Netclient().ForwardDropPolicy = true
+	if err := buildNfJumpRules(ncutils.GetInterfaceName()); err != nil {
+		t.Fatal(err)
+	}
+	jumpKey := string(nfForwardJumpRule(ncutils.GetInterfaceName()).nfRule.(*nftables.Rule).UserData)
+
+	var dropWindows int
+	n := newTestNftManager(t, fakePolicyKernel(jumpKey, &dropWindows))
+	n.FlushAll()
+	if dropWindows != 0 {
+		t.Errorf("expected the forward chain to accept once the jump rule is removed, %d batches dropped", dropWindows)
+	}
+}
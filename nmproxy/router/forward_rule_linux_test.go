@@ -0,0 +1,114 @@
+package router
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/nftables"
+	"github.com/gravitl/netclient/ncutils"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nltest"
+	"golang.org/x/sys/unix"
+)
+
+// fakeNftKernel - answers chain and rule dumps with the given chains of the filter table and the
+// user data of the rules in its forward chain, recording the type of every other message sent
+func fakeNftKernel(chains, fwdRuleKeys []string, sent *[]int) nftables.ConnOption {
+	nftMsg := func(msgType int, req netlink.Message, attrs []netlink.Attribute) netlink.Message {
+		data, _ := netlink.MarshalAttributes(attrs)
+		return netlink.Message{
+			Header: netlink.Header{
+				Type:     netlink.HeaderType((unix.NFNL_SUBSYS_NFTABLES << 8) | msgType),
+				Sequence: req.Header.Sequence,
+				PID:      req.Header.PID,
+			},
+			Data: append([]byte{unix.NFPROTO_INET, 0, 0, 0}, data...),
+		}
+	}
+	return nftables.WithTestDial(func(req []netlink.Message) ([]netlink.Message, error) {
+		var replies []netlink.Message
+		for _, m := range req {
+			switch int(m.Header.Type) & 0xff {
+			case unix.NFT_MSG_GETCHAIN:
+				for _, chain := range chains {
+					replies = append(replies, nftMsg(unix.NFT_MSG_NEWCHAIN, m, []netlink.Attribute{
+						{Type: unix.NFTA_CHAIN_TABLE, Data: []byte(defaultIpTable + "\x00")},
+						{Type: unix.NFTA_CHAIN_NAME, Data: []byte(chain + "\x00")},
+					}))
+				}
+			case unix.NFT_MSG_GETRULE:
+				for _, key := range fwdRuleKeys {
+					replies = append(replies, nftMsg(unix.NFT_MSG_NEWRULE, m, []netlink.Attribute{
+						{Type: unix.NFTA_RULE_TABLE, Data: []byte(defaultIpTable + "\x00")},
+						{Type: unix.NFTA_RULE_CHAIN, Data: []byte(iptableFWDChain + "\x00")},
+						{Type: unix.NFTA_RULE_USERDATA, Data: []byte(key)},
+					}))
+				}
+			default:
+				*sent = append(*sent, int(m.Header.Type)&0xff)
+				replies = append(replies, m)
+			}
+		}
+		return replies, nil
+	})
+}
+
+func newTestNftManager(t *testing.T, opt nftables.ConnOption) *nftablesManager {
+	t.Helper()
+	conn, err := newNftConn(nftConnOptions{lasting: true}, opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &nftablesManager{conn: conn}
+}
+
+func sentMsg(sent []int, msgType int) bool {
+	for _, s := range sent {
+		if s == msgType {
+			return true
+		}
+	}
+	return false
+}
+
+func TestNftForwardRule(t *testing.T) {
+	jumpKey := genRuleKey("-i", ncutils.GetInterfaceName(), "-j", netmakerFilterChain)
+	tests := []struct {
+		name       string
+		chains     []string
+		ruleKeys   []string
+		wantChains bool
+		wantRule   bool
+	}{
+		{name: "chains and rule exist", chains: []string{iptableFWDChain, netmakerFilterChain}, ruleKeys: []string{jumpKey}},
+		{name: "rule missing", chains: []string{iptableFWDChain, netmakerFilterChain}, ruleKeys: []string{"other"}, wantRule: true},
+		{name: "chains missing", chains: []string{iptableFWDChain}, wantChains: true, wantRule: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent []int
+			n := newTestNftManager(t, fakeNftKernel(tt.chains, tt.ruleKeys, &sent))
+			if err := n.ForwardRule(); err != nil {
+				t.Fatal(err)
+			}
+			if sentMsg(sent, unix.NFT_MSG_DELCHAIN) || sentMsg(sent, unix.NFT_MSG_DELRULE) {
+				t.Fatalf("existing chains or rules were removed: %v", sent)
+			}
+			if got := sentMsg(sent, unix.NFT_MSG_NEWCHAIN); got != tt.wantChains {
+				t.Errorf("chains created = %v, want %v", got, tt.wantChains)
+			}
+			if got := sentMsg(sent, unix.NFT_MSG_NEWRULE); got != tt.wantRule {
+				t.Errorf("rule inserted = %v, want %v", got, tt.wantRule)
+			}
+		})
+	}
+
+	n := newTestNftManager(t, nftables.WithTestDial(func(req []netlink.Message) ([]netlink.Message, error) {
+		return nltest.Error(int(unix.EPERM), req)
+	}))
+	err := n.ForwardRule()
+	if !errors.Is(err, unix.EPERM) || !strings.Contains(err.Error(), "forward rule") {
+		t.Errorf("expected wrapped EPERM, got %v", err)
+	}
+}
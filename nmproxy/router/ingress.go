@@ -1,6 +1,9 @@
 package router
 
 import (
+	"time"
+
+	"github.com/gravitl/netclient/config"
 	"github.com/gravitl/netmaker/logger"
 	"github.com/gravitl/netmaker/models"
 )
@@ -8,12 +11,14 @@ import (
 // SetIngressRoutes - feed ingress update to firewall controller to add/remove routing rules
 func SetIngressRoutes(server string, ingressUpdate models.IngressInfo) error {
 	logger.Log(1, "----> setting ingress routes")
+	storeIngressUpdate(server, ingressUpdate)
 	ruleTable := fwCrtl.FetchRuleTable(server, ingressTable)
 	for extPeerKey, ruleCfg := range ruleTable {
 
 		if _, ok := ingressUpdate.ExtPeers[extPeerKey]; !ok {
 			// ext peer is deleted, flush out all rules
 			fwCrtl.RemoveRoutingRules(server, ingressTable, extPeerKey)
+			forgetRuleRetry(server, ingressTable, extPeerKey)
 			continue
 		}
 		extPeers := ingressUpdate.ExtPeers[extPeerKey]
@@ -25,17 +30,41 @@ func SetIngressRoutes(server string, ingressUpdate models.IngressInfo) error {
 		}
 	}
 
+	pruneExtClients(server, ingressUpdate.ExtPeers)
 	for _, extInfo := range ingressUpdate.ExtPeers {
+		if skipGateway(extInfo.ExtPeerAddr.String()) {
+			continue
+		}
 		if _, ok := ruleTable[extInfo.ExtPeerKey]; !ok {
+			if isExtClientExpired(server, extInfo.ExtPeerKey) {
+				// rules are reinstated once the ext client handshakes again
+				trackExtClient(server, extInfo.ExtPeerKey, time.Now())
+				continue
+			}
 			err := fwCrtl.InsertIngressRoutingRules(server, extInfo, ingressUpdate.EgressRanges)
 			if err != nil {
 				logger.Log(0, "falied to set ingress routes: ", err.Error())
+				extPeerKey := extInfo.ExtPeerKey
+				queueIfRulesNotAdded(err, server, ingressTable, extPeerKey, func() error {
+					// the ext client as of the latest update, the failed one may be outdated by the time of the retry
+					extInfo, egressRanges, ok := latestExtInfo(server, extPeerKey)
+					if !ok || skipGateway(extInfo.ExtPeerAddr.String()) {
+						return errGatewayRemoved
+					}
+					return fwCrtl.InsertIngressRoutingRules(server, extInfo, egressRanges)
+				})
+				continue
+			}
+			trackExtClient(server, extInfo.ExtPeerKey, time.Now())
+			if config.Netclient().VerifyFirewallRules && !verifyRoutingRules(server, ingressTable, extInfo.ExtPeerKey) {
+				logger.Log(0, "ingress routes not fully configured for ext client: ", extInfo.ExtPeerKey)
 			}
 		} else {
+			trackExtClient(server, extInfo.ExtPeerKey, time.Now())
 			peerRules := ruleTable[extInfo.ExtPeerKey]
 			for _, peer := range extInfo.Peers {
 				if _, ok := peerRules.rulesMap[peer.PeerKey]; !ok &&
-					peer.PeerKey != extInfo.ExtPeerKey {
+					peer.PeerKey != extInfo.ExtPeerKey && !isolatedPeer(server, peer.PeerKey) {
 					fwCrtl.AddIngressRoutingRule(server, extInfo.ExtPeerKey,
 						extInfo.ExtPeerAddr.String(), peer)
 				}
@@ -49,5 +78,8 @@ func SetIngressRoutes(server string, ingressUpdate models.IngressInfo) error {
 
 // DeleteIngressRules - removes the rules of ingressGW
 func DeleteIngressRules(server string) {
+	forgetGatewayUpdates(server, ingressTable)
+	forgetRuleRetries(server, ingressTable)
+	pruneExtClients(server, nil)
 	fwCrtl.CleanRoutingRules(server, ingressTable)
 }
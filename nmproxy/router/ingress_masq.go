@@ -0,0 +1,25 @@
+package router
+
+import (
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netmaker/models"
+)
+
+// ip families of the ingress masquerade setting
+const (
+	familyIPv4 = "ipv4"
+	familyIPv6 = "ipv6"
+)
+
+// ingressMasquerade - checks if the traffic of the ext. client is masqueraded, the configured setting of its
+// ip family overrides the one sent by the server
+func ingressMasquerade(extinfo models.ExtClientInfo) bool {
+	family := familyIPv6
+	if isAddrIpv4(extinfo.ExtPeerAddr.String()) {
+		family = familyIPv4
+	}
+	if masquerade, ok := config.Netclient().IngressMasquerade[family]; ok {
+		return masquerade
+	}
+	return extinfo.Masquerade
+}
@@ -0,0 +1,51 @@
+package router
+
+import (
+	"net"
+	"testing"
+
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netmaker/models"
+)
+
+// masqueraded - checks if the nftables manager adds masquerade rules for the ext. client
+func masqueraded(t *testing.T, ext models.ExtClientInfo) bool {
+	t.Helper()
+	for _, rule := range insertedIngressRules(t, "masq.test", ext) {
+		if rule.chain == netmakerNatChain && ruleTarget(rule.rule) == targetMasquerade {
+			return true
+		}
+	}
+	return false
+}
+
+func TestIngressMasqueradeByFamily(t *testing.T) {
+	defer func() { config.Netclient().IngressMasquerade = nil }()
+	ext := func(addr string, masquerade bool) models.ExtClientInfo {
+		ip, n, _ := net.ParseCIDR(addr)
+		n.IP = ip
+		return models.ExtClientInfo{ExtPeerKey: "ext", ExtPeerAddr: *n, IngGwAddr: *n, Network: *n, Masquerade: masquerade}
+	}
+	tests := []struct {
+		name       string
+		families   map[string]bool
+		masquerade bool
+		want4      bool
+		want6      bool
+	}{
+		{name: "server setting", masquerade: true, want4: true, want6: true},
+		{name: "server setting off", masquerade: false},
+		{name: "ipv4 only", families: map[string]bool{familyIPv4: true, familyIPv6: false}, masquerade: true, want4: true},
+		{name: "ipv6 only", families: map[string]bool{familyIPv6: true}, masquerade: false, want6: true},
+		{name: "ipv6 disabled", families: map[string]bool{familyIPv6: false}, masquerade: true, want4: true},
+	}
+	for _, test := range tests {
+		config.Netclient().IngressMasquerade = test.families
+		if got := masqueraded(t, ext("10.10.0.5/32", test.masquerade)); got != test.want4 {
+			t.Errorf("%s: expected ipv4 masquerade rules %v, got %v", test.name, test.want4, got)
+		}
+		if got := masqueraded(t, ext("fd00::5/128", test.masquerade)); got != test.want6 {
+			t.Errorf("%s: expected ipv6 masquerade rules %v, got %v", test.name, test.want6, got)
+		}
+	}
+}
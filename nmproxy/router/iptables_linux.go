@@ -49,10 +49,13 @@ var (
 			chain: netmakerFilterChain,
 		},
 	}
-	// nat table nm jump rules
-	natNmJumpRules = []ruleInfo{
+)
+
+// natNmJumpRulesFor - nat table nm jump rules for the interface
+func natNmJumpRulesFor(ifaceName string) []ruleInfo {
+	return []ruleInfo{
 		{
-			rule: []string{"-o", ncutils.GetInterfaceName(), "-j", netmakerNatChain,
+			rule: []string{"-o", ifaceName, "-j", netmakerNatChain,
 				"-m", "comment", "--comment", netmakerSignature},
 			table: defaultNatTable,
 			chain: nattablePRTChain,
@@ -63,7 +66,7 @@ var (
 			chain: netmakerNatChain,
 		},
 	}
-)
+}
 
 func createChain(iptables *iptables.IPTables, table, newChain string) error {
 
@@ -103,7 +106,7 @@ func (i *iptablesManager) ForwardRule() error {
 	}
 	if !ok {
 		if err := iptablesClient.Insert(defaultIpTable, iptableFWDChain, 1, ruleSpec...); err != nil {
-			logger.Log(1, fmt.Sprintf("failed to add rule: %v Err: %v", ruleSpec, err.Error()))
+			ruleLog.Log(1, fmt.Sprintf("failed to add rule: %v Err: %v", ruleSpec, err.Error()))
 			return err
 		}
 	}
@@ -137,6 +140,15 @@ func (i *iptablesManager) CleanRoutingRules(server, ruleTableName string) {
 func (i *iptablesManager) CreateChains() error {
 	i.mux.Lock()
 	defer i.mux.Unlock()
+	ifaceName := ncutils.GetInterfaceName()
+	if err := validateIfaceName(ifaceName); err != nil {
+		return err
+	}
+	if forwardDropPolicy() {
+		// accept while the chains are rebuilt, the drop policy would drop the traffic of the interface
+		// once its jump rules are removed
+		i.setForwardPolicy("ACCEPT")
+	}
 	// remove jump rules
 	i.removeJumpRules()
 	i.cleanup(defaultIpTable, netmakerFilterChain)
@@ -166,29 +178,42 @@ func (i *iptablesManager) CreateChains() error {
 		return err
 	}
 	// add jump rules
-	i.addJumpRules()
+	i.addJumpRules(ifaceName)
+	if forwardDropPolicy() {
+		i.setForwardPolicy("DROP")
+	}
 	return nil
 }
 
-func (i *iptablesManager) addJumpRules() {
-	for _, rule := range filterNmJumpRules {
+// iptablesManager.setForwardPolicy - sets the policy of the forward chain of both families
+func (i *iptablesManager) setForwardPolicy(target string) {
+	for _, client := range []*iptables.IPTables{i.ipv4Client, i.ipv6Client} {
+		if err := client.ChangePolicy(defaultIpTable, iptableFWDChain, target); err != nil {
+			logger.Log(0, "failed to set forward chain policy to ", target, ": ", err.Error())
+		}
+	}
+}
+
+func (i *iptablesManager) addJumpRules(ifaceName string) {
+	denyLogRule := ruleInfo{rule: denyLogRuleSpec(), table: defaultIpTable, chain: netmakerFilterChain}
+	for _, rule := range withDenyLogRule(filterNmJumpRules, denyLogRule) {
 		err := i.ipv4Client.Append(rule.table, rule.chain, rule.rule...)
 		if err != nil {
-			logger.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", rule.rule, err.Error()))
+			ruleLog.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", rule.rule, err.Error()))
 		}
 		err = i.ipv6Client.Append(rule.table, rule.chain, rule.rule...)
 		if err != nil {
-			logger.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", rule.rule, err.Error()))
+			ruleLog.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", rule.rule, err.Error()))
 		}
 	}
-	for _, rule := range natNmJumpRules {
+	for _, rule := range natNmJumpRulesFor(ifaceName) {
 		err := i.ipv4Client.Append(rule.table, rule.chain, rule.rule...)
 		if err != nil {
-			logger.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", rule.rule, err.Error()))
+			ruleLog.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", rule.rule, err.Error()))
 		}
 		err = i.ipv6Client.Append(rule.table, rule.chain, rule.rule...)
 		if err != nil {
-			logger.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", rule.rule, err.Error()))
+			ruleLog.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", rule.rule, err.Error()))
 		}
 	}
 }
@@ -267,7 +292,7 @@ func (i *iptablesManager) AddIngressRoutingRule(server, extPeerKey, extPeerAddr
 	ruleSpec := []string{"-s", extPeerAddr, "-d", peerInfo.PeerAddr.String(), "-j", "ACCEPT"}
 	err := iptablesClient.Insert(defaultIpTable, netmakerFilterChain, 1, ruleSpec...)
 	if err != nil {
-		logger.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
+		ruleLog.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
 	}
 	ruleTable[extPeerKey].rulesMap[peerInfo.PeerKey] = []ruleInfo{
 		{
@@ -305,7 +330,7 @@ func (i *iptablesManager) InsertIngressRoutingRules(server string, extinfo model
 	logger.Log(2, fmt.Sprintf("-----> adding rule: %+v", ruleSpec))
 	err := iptablesClient.Insert(defaultIpTable, iptableFWDChain, 1, ruleSpec...)
 	if err != nil {
-		logger.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
+		ruleLog.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
 	}
 	fwdJumpRule := ruleInfo{
 		rule:  ruleSpec,
@@ -316,7 +341,7 @@ func (i *iptablesManager) InsertIngressRoutingRules(server string, extinfo model
 	logger.Log(2, fmt.Sprintf("-----> adding rule: %+v", ruleSpec))
 	err = iptablesClient.Insert(defaultIpTable, netmakerFilterChain, 1, ruleSpec...)
 	if err != nil {
-		logger.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
+		ruleLog.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
 	}
 	ruleTable[extinfo.ExtPeerKey].rulesMap[extinfo.ExtPeerKey] = []ruleInfo{
 		fwdJumpRule,
@@ -328,14 +353,15 @@ func (i *iptablesManager) InsertIngressRoutingRules(server string, extinfo model
 	}
 	routes := ruleTable[extinfo.ExtPeerKey].rulesMap[extinfo.ExtPeerKey]
 	for _, peerInfo := range extinfo.Peers {
-		if !peerInfo.Allow || peerInfo.PeerKey == extinfo.ExtPeerKey {
+		if !peerInfo.Allow || peerInfo.PeerKey == extinfo.ExtPeerKey ||
+			isolatedPeer(server, peerInfo.PeerKey) {
 			continue
 		}
 		ruleSpec := []string{"-s", extinfo.ExtPeerAddr.String(), "-d", peerInfo.PeerAddr.String(), "-j", "ACCEPT"}
 		logger.Log(2, fmt.Sprintf("-----> adding rule: %+v", ruleSpec))
 		err := iptablesClient.Insert(defaultIpTable, netmakerFilterChain, 1, ruleSpec...)
 		if err != nil {
-			logger.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
+			ruleLog.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
 			continue
 		}
 		ruleTable[extinfo.ExtPeerKey].rulesMap[peerInfo.PeerKey] = []ruleInfo{
@@ -352,7 +378,7 @@ func (i *iptablesManager) InsertIngressRoutingRules(server string, extinfo model
 		logger.Log(2, fmt.Sprintf("-----> adding rule: %+v", ruleSpec))
 		err := iptablesClient.Insert(defaultIpTable, netmakerFilterChain, 1, ruleSpec...)
 		if err != nil {
-			logger.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
+			ruleLog.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
 			continue
 		} else {
 			routes = append(routes, ruleInfo{
@@ -367,7 +393,7 @@ func (i *iptablesManager) InsertIngressRoutingRules(server string, extinfo model
 		logger.Log(2, fmt.Sprintf("-----> adding rule: %+v", ruleSpec))
 		err = iptablesClient.Insert(defaultIpTable, netmakerFilterChain, 1, ruleSpec...)
 		if err != nil {
-			logger.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
+			ruleLog.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
 			continue
 		} else {
 			routes = append(routes, ruleInfo{
@@ -378,8 +404,22 @@ func (i *iptablesManager) InsertIngressRoutingRules(server string, extinfo model
 			})
 		}
 	}
+	// drop rules are inserted last, on top of the accept rules of the chain
+	for _, pair := range extIsolationPairs(extinfo.ExtPeerAddr, isolatedExtClients(server, extinfo)) {
+		ruleSpec := extIsolationRuleSpec(pair[0], pair[1])
+		logger.Log(2, fmt.Sprintf("-----> adding rule: %+v", ruleSpec))
+		if err := iptablesClient.Insert(defaultIpTable, netmakerFilterChain, 1, ruleSpec...); err != nil {
+			ruleLog.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
+			continue
+		}
+		routes = append(routes, ruleInfo{
+			rule:  ruleSpec,
+			chain: netmakerFilterChain,
+			table: defaultIpTable,
+		})
+	}
 	ruleTable[extinfo.ExtPeerKey].rulesMap[extinfo.ExtPeerKey] = routes
-	if !extinfo.Masquerade {
+	if !ingressMasquerade(extinfo) {
 		return nil
 	}
 	routes = ruleTable[extinfo.ExtPeerKey].rulesMap[extinfo.ExtPeerKey]
@@ -387,7 +427,7 @@ func (i *iptablesManager) InsertIngressRoutingRules(server string, extinfo model
 	logger.Log(2, fmt.Sprintf("----->[NAT] adding rule: %+v", ruleSpec))
 	err = iptablesClient.Insert(defaultNatTable, netmakerNatChain, 1, ruleSpec...)
 	if err != nil {
-		logger.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
+		ruleLog.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
 	} else {
 		routes = append(routes, ruleInfo{
 			rule:  ruleSpec,
@@ -400,7 +440,7 @@ func (i *iptablesManager) InsertIngressRoutingRules(server string, extinfo model
 	logger.Log(2, fmt.Sprintf("----->[NAT] adding rule: %+v", ruleSpec))
 	err = iptablesClient.Insert(defaultNatTable, netmakerNatChain, 1, ruleSpec...)
 	if err != nil {
-		logger.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
+		ruleLog.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
 	} else {
 		routes = append(routes, ruleInfo{
 			rule:  ruleSpec,
@@ -475,7 +515,7 @@ func (i *iptablesManager) RefreshEgressRangesOnIngressGw(server string, ingressU
 			logger.Log(2, fmt.Sprintf("-----> adding rule: %+v", ruleSpec))
 			err := iptablesClient.Insert(defaultIpTable, netmakerFilterChain, 1, ruleSpec...)
 			if err != nil {
-				logger.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
+				ruleLog.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
 				continue
 			} else {
 				routes = append(routes, ruleInfo{
@@ -490,7 +530,7 @@ func (i *iptablesManager) RefreshEgressRangesOnIngressGw(server string, ingressU
 			logger.Log(2, fmt.Sprintf("-----> adding rule: %+v", ruleSpec))
 			err = iptablesClient.Insert(defaultIpTable, netmakerFilterChain, 1, ruleSpec...)
 			if err != nil {
-				logger.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
+				ruleLog.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
 				continue
 			} else {
 				routes = append(routes, ruleInfo{
@@ -530,7 +570,7 @@ func (i *iptablesManager) InsertEgressRoutingRules(server string, egressInfo mod
 
 		err := iptablesClient.Insert(defaultIpTable, iptableFWDChain, 1, ruleSpec...)
 		if err != nil {
-			logger.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
+			ruleLog.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
 		} else {
 			egressGwRoutes = append(egressGwRoutes, ruleInfo{
 				table: defaultIpTable,
@@ -538,6 +578,22 @@ func (i *iptablesManager) InsertEgressRoutingRules(server string, egressInfo mod
 				rule:  ruleSpec,
 			})
 		}
+		if outboundOnlyEgress() {
+			accept, drop := outboundOnlyRuleSpecs(egressGwRange, ncutils.GetInterfaceName())
+			// drop is inserted first so the accept of return traffic ends up ahead of it
+			for _, ruleSpec := range [][]string{drop, accept} {
+				ruleSpec = appendNetmakerCommentToRule(ruleSpec)
+				if err := iptablesClient.Insert(defaultIpTable, iptableFWDChain, 1, ruleSpec...); err != nil {
+					ruleLog.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
+					continue
+				}
+				egressGwRoutes = append(egressGwRoutes, ruleInfo{
+					table: defaultIpTable,
+					chain: iptableFWDChain,
+					rule:  ruleSpec,
+				})
+			}
+		}
 
 		if egressInfo.EgressGWCfg.NatEnabled == "yes" {
 			egressRangeIface, err := getInterfaceName(config.ToIPNet(egressGwRange))
@@ -546,11 +602,9 @@ func (i *iptablesManager) InsertEgressRoutingRules(server string, egressInfo mod
 			} else {
 				ruleSpec := []string{"-s", egressInfo.Network.String(), "-o", egressRangeIface, "-j", "MASQUERADE"}
 				ruleSpec = appendNetmakerCommentToRule(ruleSpec)
-				// to avoid duplicate iface route rule,delete if exists
-				iptablesClient.DeleteIfExists(defaultNatTable, nattablePRTChain, ruleSpec...)
-				err := iptablesClient.Insert(defaultNatTable, nattablePRTChain, 1, ruleSpec...)
+				err := i.insertEgressNatRule(iptablesClient, ruleTable, egressInfo.EgressID, ruleSpec)
 				if err != nil {
-					logger.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
+					ruleLog.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
 				} else {
 					egressGwRoutes = append(egressGwRoutes, ruleInfo{
 						table: defaultNatTable,
@@ -558,13 +612,23 @@ func (i *iptablesManager) InsertEgressRoutingRules(server string, egressInfo mod
 						rule:  ruleSpec,
 					})
 				}
+				for _, ruleSpec := range iptMasqExclusionRules(egressInfo.Network.String(), egressRangeIface, masqExcludedRanges(isIpv4)) {
+					ruleSpec = appendNetmakerCommentToRule(ruleSpec)
+					if err := i.insertEgressNatRule(iptablesClient, ruleTable, egressInfo.EgressID, ruleSpec); err != nil {
+						ruleLog.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
+						continue
+					}
+					egressGwRoutes = append(egressGwRoutes, ruleInfo{
+						table: defaultNatTable,
+						chain: nattablePRTChain,
+						rule:  ruleSpec,
+					})
+				}
 				ruleSpec = []string{"-d", egressInfo.Network.String(), "-o", egressRangeIface, "-j", "MASQUERADE"}
 				ruleSpec = appendNetmakerCommentToRule(ruleSpec)
-				// to avoid duplicate iface route rule,delete if exists
-				iptablesClient.DeleteIfExists(defaultNatTable, nattablePRTChain, ruleSpec...)
-				err = iptablesClient.Insert(defaultNatTable, nattablePRTChain, 1, ruleSpec...)
+				err = i.insertEgressNatRule(iptablesClient, ruleTable, egressInfo.EgressID, ruleSpec)
 				if err != nil {
-					logger.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
+					ruleLog.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
 				} else {
 					egressGwRoutes = append(egressGwRoutes, ruleInfo{
 						table: defaultNatTable,
@@ -584,7 +648,7 @@ func (i *iptablesManager) InsertEgressRoutingRules(server string, egressInfo mod
 		ruleSpec := []string{"-s", peer.PeerAddr.String(), "-d", strings.Join(egressInfo.EgressGWCfg.Ranges, ","), "-j", "ACCEPT"}
 		err := iptablesClient.Insert(defaultIpTable, netmakerFilterChain, 1, ruleSpec...)
 		if err != nil {
-			logger.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
+			ruleLog.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
 		} else {
 			ruleTable[egressInfo.EgressID].rulesMap[peer.PeerKey] = []ruleInfo{
 				{
@@ -601,6 +665,16 @@ func (i *iptablesManager) InsertEgressRoutingRules(server string, egressInfo mod
 	return nil
 }
 
+// iptablesManager.insertEgressNatRule - inserts a nat rule of an egress gateway unless another gateway already holds it
+func (i *iptablesManager) insertEgressNatRule(iptablesClient *iptables.IPTables, ruleTable ruletable, egressID string, ruleSpec []string) error {
+	if sharedNatRule(ruleTable, egressID, ruleInfo{table: defaultNatTable, chain: nattablePRTChain, rule: ruleSpec}) {
+		return nil
+	}
+	// to avoid duplicate iface route rule,delete if exists
+	iptablesClient.DeleteIfExists(defaultNatTable, nattablePRTChain, ruleSpec...)
+	return iptablesClient.Insert(defaultNatTable, nattablePRTChain, 1, ruleSpec...)
+}
+
 // iptablesManager.AddEgressRoutingRule - inserts iptable rule for gateway peer
 func (i *iptablesManager) AddEgressRoutingRule(server string, egressInfo models.EgressInfo,
 	peer models.PeerRouteInfo) error {
@@ -620,7 +694,7 @@ func (i *iptablesManager) AddEgressRoutingRule(server string, egressInfo models.
 	ruleSpec := []string{"-s", peer.PeerAddr.String(), "-d", strings.Join(egressInfo.EgressGWCfg.Ranges, ","), "-j", "ACCEPT"}
 	err := iptablesClient.Insert(defaultIpTable, netmakerFilterChain, 1, ruleSpec...)
 	if err != nil {
-		logger.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
+		ruleLog.Log(1, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
 	} else {
 
 		ruleTable[egressInfo.EgressID].rulesMap[peer.PeerKey] = []ruleInfo{
@@ -679,6 +753,7 @@ func (i *iptablesManager) DeleteRuleTable(server, ruleTableName string) {
 	case egressTable:
 		delete(i.engressRules, server)
 	}
+	i.persistRules()
 }
 
 // iptablesManager.SaveRules - saves the rule table by tablename
@@ -692,6 +767,69 @@ func (i *iptablesManager) SaveRules(server, tableName string, rules ruletable) {
 	case egressTable:
 		i.engressRules[server] = rules
 	}
+	i.persistRules()
+}
+
+// iptablesManager.RestoreRules - restores the persisted rule tables, inserting the rules missing from the chains
+// recreated by CreateChains again, rules that can't be inserted are dropped from the tables
+func (i *iptablesManager) RestoreRules() error {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	ingRules, egressRules, err := loadRuleState(ruleStateFile(), func(isIpv4 bool, rule ruleInfo) (any, error) {
+		iptablesClient := i.ipv4Client
+		if !isIpv4 {
+			iptablesClient = i.ipv6Client
+		}
+		exists, err := iptablesClient.Exists(rule.table, rule.chain, rule.rule...)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			// inserted on top of the chain, like the manager inserts the rules
+			if err := iptablesClient.Insert(rule.table, rule.chain, 1, rule.rule...); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return err
+	}
+	i.ingRules = ingRules
+	i.engressRules = egressRules
+	return nil
+}
+
+// iptablesManager.MissingRules - returns the recorded rules of a peer which are not present in iptables
+func (i *iptablesManager) MissingRules(server, ruletableName, peerKey string) []ruleInfo {
+	rulesTable := i.FetchRuleTable(server, ruletableName)
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	return missingRules(rulesTable[peerKey], func(isIpv4 bool, rule ruleInfo) bool {
+		iptablesClient := i.ipv4Client
+		if !isIpv4 {
+			iptablesClient = i.ipv6Client
+		}
+		exists, err := iptablesClient.Exists(rule.table, rule.chain, rule.rule...)
+		return err == nil && exists
+	})
+}
+
+// iptablesManager.RuleState - returns a copy of the rule tables, taken under the lock
+func (i *iptablesManager) RuleState() savedRuleState {
+	i.mux.Lock()
+	defer i.mux.Unlock()
+	return savedRuleState{
+		Ingress: toSavedServerRules(i.ingRules),
+		Egress:  toSavedServerRules(i.engressRules),
+	}
+}
+
+// iptablesManager.persistRules - writes the rule tables to disk, must be called with the lock held
+func (i *iptablesManager) persistRules() {
+	if err := saveRuleState(ruleStateFile(), i.ingRules, i.engressRules); err != nil {
+		logger.Log(0, "failed to persist firewall rules: ", err.Error())
+	}
 }
 
 // iptablesManager.RemoveRoutingRules removes an iptables rules related to a peer
@@ -710,6 +848,9 @@ func (i *iptablesManager) RemoveRoutingRules(server, ruletableName, peerKey stri
 
 	for _, rules := range rulesTable[peerKey].rulesMap {
 		for _, rule := range rules {
+			if sharedNatRule(rulesTable, peerKey, rule) {
+				continue
+			}
 			err := iptablesClient.DeleteIfExists(rule.table, rule.chain, rule.rule...)
 			if err != nil {
 				return fmt.Errorf("iptables: error while removing existing %s rules [%v] for %s: %v",
@@ -755,6 +896,11 @@ func (i *iptablesManager) DeleteRoutingRule(server, ruletableName, srcPeerKey, d
 func (i *iptablesManager) FlushAll() {
 	i.mux.Lock()
 	defer i.mux.Unlock()
+	if forwardDropPolicy() {
+		// accept while the chains are rebuilt, the drop policy would drop the traffic of the interface
+		// once its jump rules are removed
+		i.setForwardPolicy("ACCEPT")
+	}
 	// remove jump rules
 	i.removeJumpRules()
 	i.cleanup(defaultIpTable, netmakerFilterChain)
@@ -0,0 +1,130 @@
+package router
+
+import (
+	"sync"
+
+	"github.com/gravitl/netmaker/logger"
+	"github.com/gravitl/netmaker/models"
+)
+
+var (
+	ipv6Disabled bool
+	// last gateway updates per server, reapplied when ipv6 returns
+	lastEgressUpdates  = make(map[string]map[string]models.EgressInfo)
+	lastIngressUpdates = make(map[string]models.IngressInfo)
+	ipv6StateMutex     sync.Mutex
+)
+
+// SetIPv6Enabled - removes the ipv6 gateway rules while the host has no ipv6 connectivity,
+// and reinstates them from the last gateway updates once it's back
+func SetIPv6Enabled(enabled bool) {
+	ipv6StateMutex.Lock()
+	if ipv6Disabled == !enabled {
+		ipv6StateMutex.Unlock()
+		return
+	}
+	ipv6Disabled = !enabled
+	egressUpdates := make(map[string]map[string]models.EgressInfo, len(lastEgressUpdates))
+	for server, update := range lastEgressUpdates {
+		egressUpdates[server] = update
+	}
+	ingressUpdates := make(map[string]models.IngressInfo, len(lastIngressUpdates))
+	for server, update := range lastIngressUpdates {
+		ingressUpdates[server] = update
+	}
+	ipv6StateMutex.Unlock()
+	if fwCrtl == nil {
+		return
+	}
+	if !enabled {
+		removeIPv6Rules()
+		return
+	}
+	logger.Log(0, "reinstating ipv6 gateway rules")
+	for server, update := range egressUpdates {
+		SetEgressRoutes(server, update)
+	}
+	for server, update := range ingressUpdates {
+		SetIngressRoutes(server, update)
+	}
+}
+
+// ipv6Enabled - checks if ipv6 gateway rules may be added
+func ipv6Enabled() bool {
+	ipv6StateMutex.Lock()
+	defer ipv6StateMutex.Unlock()
+	return !ipv6Disabled
+}
+
+// skipGateway - checks if the rules of a gateway on the address are not to be added
+func skipGateway(addr string) bool {
+	return !isAddrIpv4(addr) && !ipv6Enabled()
+}
+
+func storeEgressUpdate(server string, update map[string]models.EgressInfo) {
+	ipv6StateMutex.Lock()
+	defer ipv6StateMutex.Unlock()
+	lastEgressUpdates[server] = update
+}
+
+func storeIngressUpdate(server string, update models.IngressInfo) {
+	ipv6StateMutex.Lock()
+	defer ipv6StateMutex.Unlock()
+	lastIngressUpdates[server] = update
+}
+
+// latestEgressInfo - returns the egress gateway from the last egress update of the server
+func latestEgressInfo(server, egressNodeID string) (models.EgressInfo, bool) {
+	ipv6StateMutex.Lock()
+	defer ipv6StateMutex.Unlock()
+	info, ok := lastEgressUpdates[server][egressNodeID]
+	return info, ok
+}
+
+// latestExtInfo - returns the ext client and the egress ranges from the last ingress update of the server
+func latestExtInfo(server, extPeerKey string) (models.ExtClientInfo, []string, bool) {
+	ipv6StateMutex.Lock()
+	defer ipv6StateMutex.Unlock()
+	update, ok := lastIngressUpdates[server]
+	if !ok {
+		return models.ExtClientInfo{}, nil, false
+	}
+	info, ok := update.ExtPeers[extPeerKey]
+	return info, update.EgressRanges, ok
+}
+
+// forgetGatewayUpdates - drops the stored update of a table so deleted gateways aren't reinstated
+func forgetGatewayUpdates(server, tableName string) {
+	ipv6StateMutex.Lock()
+	defer ipv6StateMutex.Unlock()
+	if tableName == egressTable {
+		delete(lastEgressUpdates, server)
+	} else {
+		delete(lastIngressUpdates, server)
+	}
+}
+
+// removeIPv6Rules - removes the rules of all ipv6 gateways and ext clients
+func removeIPv6Rules() {
+	ipv6StateMutex.Lock()
+	servers := []string{}
+	for server := range lastEgressUpdates {
+		servers = append(servers, server)
+	}
+	for server := range lastIngressUpdates {
+		if _, ok := lastEgressUpdates[server]; !ok {
+			servers = append(servers, server)
+		}
+	}
+	ipv6StateMutex.Unlock()
+	for _, server := range servers {
+		for _, tableName := range []string{ingressTable, egressTable} {
+			for key, cfg := range fwCrtl.FetchRuleTable(server, tableName) {
+				if !cfg.isIpv4 {
+					logger.Log(0, "removing ipv6", tableName, "rules for", key)
+					fwCrtl.RemoveRoutingRules(server, tableName, key)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package router
+
+import (
+	"testing"
+)
+
+func TestSkipGatewayIPv6(t *testing.T) {
+	defer SetIPv6Enabled(true)
+	if skipGateway("fd00::1/64") || skipGateway("10.0.0.1/24") {
+		t.Fatal("no gateway should be skipped while ipv6 is enabled")
+	}
+	SetIPv6Enabled(false)
+	if !skipGateway("fd00::1/64") {
+		t.Fatal("ipv6 gateway should be skipped while ipv6 is disabled")
+	}
+	if skipGateway("10.0.0.1/24") {
+		t.Fatal("ipv4 gateway should not be skipped while ipv6 is disabled")
+	}
+	SetIPv6Enabled(true)
+	if skipGateway("fd00::1/64") {
+		t.Fatal("ipv6 gateway should not be skipped once ipv6 returns")
+	}
+}
@@ -0,0 +1,45 @@
+package router
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+)
+
+func TestBuildNfJumpRules(t *testing.T) {
+	defer func() {
+		nfJumpRules, nfFilterJumpRules, nfNatJumpRules = nil, nil, nil
+	}()
+	if err := buildNfJumpRules(""); !errors.Is(err, errEmptyIfaceName) {
+		t.Fatalf("expected empty interface name to be refused, got %v", err)
+	}
+	if len(nfJumpRules) != 0 {
+		t.Fatalf("expected no rules to be built, got %d", len(nfJumpRules))
+	}
+	if err := buildNfJumpRules("netmaker"); err != nil {
+		t.Fatal(err)
+	}
+	if len(nfJumpRules) != len(nfFilterJumpRules)+len(nfNatJumpRules) {
+		t.Fatalf("unexpected jump rules: %d", len(nfJumpRules))
+	}
+	for _, rule := range nfJumpRules {
+		for _, e := range rule.nfRule.(*nftables.Rule).Exprs {
+			if cmp, ok := e.(*expr.Cmp); ok && bytes.Equal(cmp.Data, []byte("\x00")) {
+				t.Fatalf("rule %v matches an empty interface name", rule.rule)
+			}
+		}
+	}
+}
+
+func TestNatNmJumpRulesFor(t *testing.T) {
+	if err := validateIfaceName(""); !errors.Is(err, errEmptyIfaceName) {
+		t.Fatalf("expected empty interface name to be refused, got %v", err)
+	}
+	rules := natNmJumpRulesFor("netmaker")
+	if rules[0].rule[1] != "netmaker" {
+		t.Fatalf("expected nat jump rule on netmaker, got %v", rules[0].rule)
+	}
+}
@@ -0,0 +1,34 @@
+package router
+
+import (
+	"net"
+
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netmaker/logger"
+)
+
+// masqExcludedRanges - returns the configured destination ranges of the given ip family that egress traffic
+// is not masqueraded to
+func masqExcludedRanges(isIpv4 bool) []*net.IPNet {
+	excluded := []*net.IPNet{}
+	for _, r := range config.Netclient().EgressNoMasqRanges {
+		_, cidr, err := net.ParseCIDR(r)
+		if err != nil {
+			logger.Log(0, "ignoring invalid no-masquerade range: ", r, err.Error())
+			continue
+		}
+		if (cidr.IP.To4() != nil) == isIpv4 {
+			excluded = append(excluded, cidr)
+		}
+	}
+	return excluded
+}
+
+// masqExclusionSpec - returns the rule spec conditions excluding the ranges as destinations
+func masqExclusionSpec(excluded []*net.IPNet) []string {
+	spec := []string{}
+	for _, cidr := range excluded {
+		spec = append(spec, "!", "-d", cidr.String())
+	}
+	return spec
+}
@@ -0,0 +1,49 @@
+package router
+
+import (
+	"net"
+
+	"github.com/google/nftables/expr"
+)
+
+// nfMasqExclusionExprs - returns expressions that only match packets whose destination is outside all the ranges
+func nfMasqExclusionExprs(excluded []*net.IPNet, isIpv4 bool) []expr.Any {
+	exprs := []expr.Any{}
+	for _, cidr := range excluded {
+		offset, length, xor, ip := uint32(ipv4DestOffset), uint32(ipv4Len), zeroXor, []byte(cidr.IP.To4())
+		if !isIpv4 {
+			offset, length, xor, ip = ipv6DestOffset, ipv6Len, zeroXor6, cidr.IP.To16()
+		}
+		exprs = append(exprs,
+			&expr.Payload{
+				DestRegister: 1,
+				Base:         expr.PayloadBaseNetworkHeader,
+				Offset:       offset,
+				Len:          length,
+			},
+			&expr.Bitwise{
+				DestRegister:   1,
+				SourceRegister: 1,
+				Len:            length,
+				Mask:           cidr.Mask,
+				Xor:            xor,
+			},
+			&expr.Cmp{
+				Op:       expr.CmpOpNeq,
+				Register: 1,
+				Data:     ip,
+			},
+		)
+	}
+	return exprs
+}
+
+// iptMasqExclusionRules - returns the rules returning early from the nat chain for traffic of the network
+// to the ranges, inserted ahead of the masquerade rule so those destinations keep the peer's source
+func iptMasqExclusionRules(network, iface string, excluded []*net.IPNet) [][]string {
+	rules := [][]string{}
+	for _, cidr := range excluded {
+		rules = append(rules, []string{"-s", network, "-d", cidr.String(), "-o", iface, "-j", "RETURN"})
+	}
+	return rules
+}
@@ -0,0 +1,44 @@
+package router
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/gravitl/netclient/config"
+)
+
+func TestMasqExclusion(t *testing.T) {
+	config.Netclient().EgressNoMasqRanges = []string{"192.168.50.0/24", "fd00:50::/64", "not-a-cidr"}
+	defer func() { config.Netclient().EgressNoMasqRanges = nil }()
+	excluded := masqExcludedRanges(true)
+	if len(excluded) != 1 || excluded[0].String() != "192.168.50.0/24" {
+		t.Fatalf("expected only the ipv4 range, got %v", excluded)
+	}
+	masq := append([]string{"-s", "10.0.0.0/24", "-o", "eth0"}, masqExclusionSpec(excluded)...)
+	masq = append(masq, "-j", "MASQUERADE")
+	_, extra, _ := net.ParseCIDR("172.16.0.0/16")
+	returnRules := [][]string{}
+	for _, cidr := range []*net.IPNet{excluded[0], extra} {
+		returnRules = append(returnRules, []string{"-s", "10.0.0.0/24", "-d", cidr.String(), "-o", "eth0", "-j", "RETURN"})
+	}
+	rulesets := map[string][]ruleInfo{
+		"negated match": {{table: "nat", chain: "POSTROUTING", rule: masq}},
+		"return rules": {
+			{table: "nat", chain: "POSTROUTING", rule: []string{"-s", "10.0.0.0/24", "-o", "eth0", "-j", "MASQUERADE"}},
+			{table: "nat", chain: "POSTROUTING", rule: returnRules[0]},
+			{table: "nat", chain: "POSTROUTING", rule: returnRules[1]},
+		},
+	}
+	for name, rules := range rulesets {
+		t.Run(name, func(t *testing.T) {
+			if v := evaluatePacket(rules, PacketQuery{Src: "10.0.0.2", Dst: "192.168.50.7", OutIface: "eth0"}, "netmaker"); v.Masquerade {
+				t.Errorf("expected excluded destination to bypass masquerade, got %+v", v)
+			}
+			if v := evaluatePacket(rules, PacketQuery{Src: "10.0.0.2", Dst: "192.168.60.7", OutIface: "eth0"}, "netmaker"); !v.Masquerade ||
+				!strings.Contains(v.MasqRule, "MASQUERADE") {
+				t.Errorf("expected other destinations to be masqueraded, got %+v", v)
+			}
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// nftScriptHeader - first line of an exported script, so it can be run or loaded with nft -f
+const nftScriptHeader = "#!/usr/sbin/nft -f"
+
+// targetLog - iptables target of the deny log rule
+const targetLog = "LOG"
+
+// nftVerdicts - nft verdicts and statements of the iptables targets used in rule specs,
+// other targets are chains and become jumps
+var nftVerdicts = map[string]string{
+	targetAccept:     "accept",
+	targetDrop:       "drop",
+	targetReturn:     "return",
+	targetMasquerade: "masquerade",
+}
+
+// ruleExporter - a firewall whose rules can be exported as an nft script
+type ruleExporter interface {
+	ExportRules(server string) (string, error)
+}
+
+// ExportRules - returns an nft script of the netmaker chains and the rules installed for the server,
+// or for all servers when server is empty, the firewall is not touched
+func ExportRules(server string) (string, error) {
+	if fwCrtl == nil {
+		return "", errors.New("firewall is not initialized on this host")
+	}
+	exporter, ok := fwCrtl.(ruleExporter)
+	if !ok {
+		return "", fmt.Errorf("exporting rules needs the nftables firewall, the firewall of this host is %s", firewallBackend())
+	}
+	return exporter.ExportRules(server)
+}
+
+// nftRuleLine - the nft command adding a rule with the iptables style spec to the chain, verb is add to append
+// the rule or insert to prepend it, like the rule was installed
+func nftRuleLine(verb, table, chain string, spec []string) (string, error) {
+	statement, err := nftRuleStatement(spec)
+	if err != nil {
+		return "", fmt.Errorf("rule %q: %w", strings.Join(spec, " "), err)
+	}
+	return fmt.Sprintf("%s rule inet %s %s %s", verb, table, chain, statement), nil
+}
+
+// nftRuleStatement - translates an iptables style rule spec into the matches and statement of an nft rule
+func nftRuleStatement(spec []string) (string, error) {
+	var (
+		matches                    []string
+		target, logPrefix, comment string
+		negate                     bool
+	)
+	for i := 0; i < len(spec); i++ {
+		flag := spec[i]
+		if flag == "!" {
+			negate = true
+			continue
+		}
+		if i+1 >= len(spec) {
+			return "", fmt.Errorf("%s has no value", flag)
+		}
+		value := spec[i+1]
+		i++
+		op := ""
+		if negate {
+			op = "!= "
+		}
+		negate = false
+		switch flag {
+		case "-s":
+			matches = append(matches, fmt.Sprintf("%s saddr %s%s", nftAddrFamily(value), op, nftAddrs(value)))
+		case "-d":
+			matches = append(matches, fmt.Sprintf("%s daddr %s%s", nftAddrFamily(value), op, nftAddrs(value)))
+		case "-i":
+			matches = append(matches, fmt.Sprintf("iifname %s%q", op, value))
+		case "-o":
+			matches = append(matches, fmt.Sprintf("oifname %s%q", op, value))
+		case "-p":
+			matches = append(matches, fmt.Sprintf("meta l4proto %s%s", op, value))
+		case "--dport":
+			matches = append(matches, fmt.Sprintf("th dport %s%s", op, value))
+		case "--ctstate":
+			matches = append(matches, fmt.Sprintf("ct state %s%s", op, strings.ToLower(value)))
+		case "-m":
+			// match modules are implied by their options
+		case "--comment":
+			comment = value
+		case "--log-prefix":
+			logPrefix = value
+		case "-j":
+			target = value
+		default:
+			return "", fmt.Errorf("unsupported option %s", flag)
+		}
+	}
+	var statement string
+	switch target {
+	case "":
+		return "", errors.New("rule has no target")
+	case targetLog:
+		statement = "log"
+		if logPrefix != "" {
+			statement = fmt.Sprintf("log prefix %q", logPrefix)
+		}
+	default:
+		var ok bool
+		if statement, ok = nftVerdicts[target]; !ok {
+			statement = "jump " + target
+		}
+	}
+	matches = append(matches, "counter", statement)
+	if comment != "" {
+		matches = append(matches, fmt.Sprintf("comment %q", comment))
+	}
+	return strings.Join(matches, " "), nil
+}
+
+// nftAddrFamily - the nft protocol of an address match, ip6 for ipv6 addresses
+func nftAddrFamily(addrs string) string {
+	if strings.Contains(addrs, ":") {
+		return "ip6"
+	}
+	return "ip"
+}
+
+// nftAddrs - an address match value, comma separated addresses become an anonymous set
+func nftAddrs(addrs string) string {
+	if !strings.Contains(addrs, ",") {
+		return addrs
+	}
+	return "{ " + strings.Join(strings.Split(addrs, ","), ", ") + " }"
+}
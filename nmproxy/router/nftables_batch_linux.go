@@ -0,0 +1,56 @@
+package router
+
+import (
+	"fmt"
+
+	"github.com/google/nftables"
+	"github.com/gravitl/netmaker/logger"
+)
+
+// nfRuleBatch - nftables rules staged to be inserted with a single flush
+type nfRuleBatch []stagedNfRule
+
+// stagedNfRule - a staged rule and the bookkeeping to run once it is in the kernel
+type stagedNfRule struct {
+	info    ruleInfo
+	onAdded func(ruleInfo)
+}
+
+// nfRuleBatch.stage - queues a rule for insertion, onAdded is called with the rule if it lands and may be nil
+func (b *nfRuleBatch) stage(info ruleInfo, onAdded func(ruleInfo)) {
+	*b = append(*b, stagedNfRule{info: info, onAdded: onAdded})
+}
+
+// nftables.insertBatch - inserts the staged rules with a single flush, if it fails the rules are
+// retried with a flush each so the ones that land are still recorded, returns errRulesNotAdded if any did not
+func (n *nftablesManager) insertBatch(batch nfRuleBatch) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	for _, staged := range batch {
+		n.conn.InsertRule(staged.info.nfRule.(*nftables.Rule))
+	}
+	err := n.flush()
+	if err == nil {
+		for _, staged := range batch {
+			if staged.onAdded != nil {
+				staged.onAdded(staged.info)
+			}
+		}
+		return nil
+	}
+	logger.Log(0, fmt.Sprintf("failed to add %d rules in one batch, retrying each rule, Err: %v", len(batch), err.Error()))
+	failed := 0
+	for _, staged := range batch {
+		n.conn.InsertRule(staged.info.nfRule.(*nftables.Rule))
+		if err := n.flush(); err != nil {
+			logger.Log(0, fmt.Sprintf("failed to add rule: %v, Err: %v ", staged.info.rule, err.Error()))
+			failed++
+			continue
+		}
+		if staged.onAdded != nil {
+			staged.onAdded(staged.info)
+		}
+	}
+	return rulesNotAdded(failed)
+}
@@ -0,0 +1,77 @@
+package router
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/google/nftables"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nltest"
+	"golang.org/x/sys/unix"
+)
+
+// failingRuleDial - acknowledges every batch except those inserting a rule with the given user data,
+// counting the batches sent
+func failingRuleDial(failKey string, batches *int) nftables.ConnOption {
+	return nftables.WithTestDial(func(req []netlink.Message) ([]netlink.Message, error) {
+		if req == nil {
+			return nil, nil
+		}
+		*batches++
+		for _, m := range req {
+			if failKey != "" && bytes.Contains(m.Data, []byte(failKey)) {
+				return nltest.Error(int(unix.EPERM), req)
+			}
+		}
+		return req, nil
+	})
+}
+
+func TestInsertBatch(t *testing.T) {
+	stageRules := func(batch *nfRuleBatch, added *[]string, keys ...string) {
+		for _, key := range keys {
+			batch.stage(ruleInfo{
+				nfRule: &nftables.Rule{
+					Table:    filterTable,
+					Chain:    &nftables.Chain{Name: netmakerFilterChain, Table: filterTable},
+					UserData: []byte(key),
+				},
+				rule:  []string{key},
+				chain: netmakerFilterChain,
+				table: defaultIpTable,
+			}, func(r ruleInfo) {
+				*added = append(*added, r.rule[0])
+			})
+		}
+	}
+
+	var batches int
+	n := newTestNftManager(t, failingRuleDial("", &batches))
+	var batch nfRuleBatch
+	var added []string
+	stageRules(&batch, &added, "peer1", "peer2", "peer3")
+	if err := n.insertBatch(batch); err != nil {
+		t.Errorf("expected the batch to land, got %v", err)
+	}
+	if batches != 1 {
+		t.Errorf("expected a single flush, got %d", batches)
+	}
+	if len(added) != 3 || added[0] != "peer1" || added[2] != "peer3" {
+		t.Errorf("unexpected rules recorded: %v", added)
+	}
+
+	batches = 0
+	n = newTestNftManager(t, failingRuleDial("peer2", &batches))
+	batch, added = nil, nil
+	stageRules(&batch, &added, "peer1", "peer2", "peer3")
+	if err := n.insertBatch(batch); !errors.Is(err, errRulesNotAdded) {
+		t.Errorf("expected the failed rule to be reported, got %v", err)
+	}
+	if batches != 4 {
+		t.Errorf("expected the batch and a flush per rule, got %d flushes", batches)
+	}
+	if len(added) != 2 || added[0] != "peer1" || added[1] != "peer3" {
+		t.Errorf("expected only the rules that landed to be recorded, got %v", added)
+	}
+}
@@ -0,0 +1,52 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/nftables"
+	"github.com/gravitl/netclient/config"
+	"github.com/mdlayher/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// nftConnOptions - options of the netlink connection used by the nftables manager
+type nftConnOptions struct {
+	// lasting - reuse one netlink socket across operations instead of dialing one per Flush
+	lasting bool
+}
+
+// nftConnOptionsFromConfig - returns the nftables connection options set in the netclient config
+func nftConnOptionsFromConfig() nftConnOptions {
+	return nftConnOptions{
+		lasting: config.Netclient().NftablesLastingConn,
+	}
+}
+
+// newNftConn - creates the nftables connection with the given options
+func newNftConn(opts nftConnOptions, extra ...nftables.ConnOption) (*nftables.Conn, error) {
+	connOpts := []nftables.ConnOption{}
+	if opts.lasting {
+		connOpts = append(connOpts, nftables.AsLasting())
+	}
+	return nftables.New(append(connOpts, extra...)...)
+}
+
+// netlinkErr - adds the errno name to a netlink error, the kernel's extended ack isn't requested by the nftables
+// library so only the errno is known
+func netlinkErr(err error) error {
+	var opErr *netlink.OpError
+	if err == nil || !errors.As(err, &opErr) {
+		return err
+	}
+	var errno unix.Errno
+	if !errors.As(opErr.Err, &errno) {
+		return err
+	}
+	return fmt.Errorf("%w [%s]", err, unix.ErrnoName(errno))
+}
+
+// flush - sends the buffered nftables commands, returning netlink errors with their errno name
+func (n *nftablesManager) flush() error {
+	return netlinkErr(n.conn.Flush())
+}
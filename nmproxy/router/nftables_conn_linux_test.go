@@ -0,0 +1,41 @@
+package router
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/nftables"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nltest"
+	"golang.org/x/sys/unix"
+)
+
+func TestNewNftConnOptions(t *testing.T) {
+	noop := nftables.WithTestDial(func(req []netlink.Message) ([]netlink.Message, error) { return req, nil })
+	for _, lasting := range []bool{false, true} {
+		conn, err := newNftConn(nftConnOptions{lasting: lasting}, noop)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := reflect.ValueOf(conn).Elem().FieldByName("lasting").Bool(); got != lasting {
+			t.Errorf("expected lasting %v, got %v", lasting, got)
+		}
+	}
+}
+
+func TestFlushPropagatesNetlinkErrors(t *testing.T) {
+	conn, err := newNftConn(nftConnOptions{lasting: true}, nftables.WithTestDial(func(req []netlink.Message) ([]netlink.Message, error) {
+		return nltest.Error(int(unix.ENOENT), req)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := &nftablesManager{conn: conn}
+	n.conn.AddTable(&nftables.Table{Name: "test", Family: nftables.TableFamilyINet})
+	err = n.flush()
+	if !errors.Is(err, unix.ENOENT) || !strings.Contains(err.Error(), "ENOENT") {
+		t.Errorf("expected ENOENT with its name, got %v", err)
+	}
+}
@@ -0,0 +1,31 @@
+package router
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNftDeleteRecordedRuleAlreadyRemoved(t *testing.T) {
+	chains := [][2]string{{defaultIpTable, netmakerFilterChain}}
+	gone := []string{"-s", "10.0.0.2", "-d", "10.0.0.3", "-j", "ACCEPT"}
+	present := []string{"-s", "10.0.0.2", "-d", "10.0.0.4", "-j", "ACCEPT"}
+	rules := []fakeNftRule{
+		{table: defaultIpTable, chain: netmakerFilterChain, handle: 7, userData: []byte(genRuleKey(present...))},
+	}
+	var delRules []uint64
+	var delChains []string
+	n := newTestNftManager(t, fakeFlushKernel(chains, rules, &delRules, &delChains))
+
+	goneRule := ruleInfo{table: defaultIpTable, chain: netmakerFilterChain, rule: gone}
+	if err := n.deleteRuleInfo(goneRule); !errors.Is(err, ErrRuleNotFound) {
+		t.Fatalf("expected ErrRuleNotFound, got %v", err)
+	}
+	for _, rule := range []ruleInfo{goneRule, {table: defaultIpTable, chain: netmakerFilterChain, rule: present}} {
+		if err := n.deleteRecordedRule(rule, "peer"); err != nil {
+			t.Fatalf("deleting %v: %v", rule.rule, err)
+		}
+	}
+	if len(delRules) != 1 || delRules[0] != 7 {
+		t.Errorf("expected the present rule to be deleted, deleted %v", delRules)
+	}
+}
@@ -0,0 +1,95 @@
+package router
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/nftables"
+)
+
+// nftables.ExportRules - returns an nft script recreating the netmaker chains, the rules recorded for the server,
+// or for all servers when server is empty, and the jump rules, built from the recorded rule specs without
+// reading the ruleset from the kernel
+func (n *nftablesManager) ExportRules(server string) (string, error) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	rules, err := recordedRules(server, n.ingRules, n.engressRules)
+	if err != nil {
+		return "", err
+	}
+	policy := "accept"
+	if forwardDropPolicy() {
+		policy = "drop"
+	}
+	lines := []string{
+		nftScriptHeader,
+		"add table inet " + defaultIpTable,
+		"add table inet " + defaultNatTable,
+		fmt.Sprintf("add chain inet %s %s { type filter hook forward priority filter; policy %s; }",
+			defaultIpTable, iptableFWDChain, policy),
+		fmt.Sprintf("add chain inet %s %s", defaultIpTable, netmakerFilterChain),
+		fmt.Sprintf("add chain inet %s %s { type nat hook postrouting priority srcnat; }", defaultNatTable, nattablePRTChain),
+		fmt.Sprintf("add chain inet %s %s", defaultNatTable, netmakerNatChain),
+	}
+	// the recorded rules are inserted on top of their chains in the order they were recorded, as the manager does,
+	// so the script ends up with the same order as the kernel, e.g. the accept of outbound only egress ahead of its drop
+	for _, rule := range rules {
+		line, err := nftRuleLine("insert", rule.table, rule.chain, rule.rule)
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, line)
+	}
+	// the jump rules are appended to the chains, below the recorded rules
+	for _, rule := range nfJumpRules {
+		r := rule.nfRule.(*nftables.Rule)
+		line, err := nftRuleLine("add", r.Table.Name, r.Chain.Name, rule.rule)
+		if err != nil {
+			return "", err
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// recordedRules - returns the ingress and egress rules recorded for the server, or for all servers when server
+// is empty, ordered by server, table, gateway and peer so the export is stable
+func recordedRules(server string, tables ...serverrulestable) ([]ruleInfo, error) {
+	found := map[string]bool{}
+	servers := []string{}
+	for _, table := range tables {
+		for name := range table {
+			if (server == "" || name == server) && !found[name] {
+				found[name] = true
+				servers = append(servers, name)
+			}
+		}
+	}
+	if server != "" && len(servers) == 0 {
+		return nil, fmt.Errorf("no rules recorded for server %s", server)
+	}
+	sort.Strings(servers)
+	rules := []ruleInfo{}
+	for _, name := range servers {
+		for _, table := range tables {
+			cfgs := table[name]
+			keys := make([]string, 0, len(cfgs))
+			for key := range cfgs {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				peers := make([]string, 0, len(cfgs[key].rulesMap))
+				for peer := range cfgs[key].rulesMap {
+					peers = append(peers, peer)
+				}
+				sort.Strings(peers)
+				for _, peer := range peers {
+					rules = append(rules, cfgs[key].rulesMap[peer]...)
+				}
+			}
+		}
+	}
+	return rules, nil
+}
@@ -0,0 +1,185 @@
+package router
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/nftables"
+	"github.com/gravitl/netclient/config"
+	"github.com/mdlayher/netlink"
+)
+
+// nftTokens - splits an nft statement into words, keeping quoted strings and anonymous sets whole
+func nftTokens(t *testing.T, statement string) []string {
+	tokens := []string{}
+	for statement = strings.TrimSpace(statement); statement != ""; statement = strings.TrimSpace(statement) {
+		end := strings.IndexByte(statement, ' ')
+		switch statement[0] {
+		case '"':
+			end = strings.IndexByte(statement[1:], '"') + 2
+		case '{':
+			end = strings.IndexByte(statement, '}') + 1
+		}
+		if end <= 0 {
+			end = len(statement)
+		}
+		token := statement[:end]
+		switch token[0] {
+		case '"':
+			unquoted, err := strconv.Unquote(token)
+			if err != nil {
+				t.Fatal(err)
+			}
+			token = unquoted
+		case '{':
+			token = strings.ReplaceAll(strings.Trim(token, "{} "), ", ", ",")
+		}
+		tokens = append(tokens, token)
+		statement = statement[end:]
+	}
+	return tokens
+}
+
+// nftRuleSpec - translates an exported nft rule back into the table, chain and iptables style rule spec
+func nftRuleSpec(t *testing.T, line string) []string {
+	line = strings.TrimPrefix(line, "insert rule inet ")
+	tokens := nftTokens(t, strings.TrimPrefix(line, "add rule inet "))
+	spec := []string{tokens[0], tokens[1]}
+	flags := map[string]string{
+		"saddr": "-s", "daddr": "-d", "iifname": "-i", "oifname": "-o", "l4proto": "-p", "dport": "--dport", "state": "--ctstate",
+	}
+	for i := 2; i < len(tokens); i++ {
+		switch token := tokens[i]; token {
+		case "ip", "ip6", "meta", "th", "ct", "counter":
+		case "accept", "drop", "return", "masquerade":
+			spec = append(spec, "-j", strings.ToUpper(token))
+		case "jump":
+			spec = append(spec, "-j", tokens[i+1])
+			i++
+		case "log":
+			spec = append(spec, "-j", targetLog)
+			if i+2 < len(tokens) && tokens[i+1] == "prefix" {
+				spec = append(spec, "--log-prefix", tokens[i+2])
+				i += 2
+			}
+		default:
+			flag, ok := flags[token]
+			if !ok {
+				t.Fatalf("unexpected %q in %s", token, line)
+			}
+			if tokens[i+1] == "!=" {
+				spec = append(spec, "!")
+				i++
+			}
+			value := tokens[i+1]
+			if flag == "--ctstate" {
+				value = strings.ToUpper(value)
+			}
+			spec = append(spec, flag, value)
+			i++
+		}
+	}
+	return spec
+}
+
+func TestExportRules(t *testing.T) {
+	defer func() {
+		config.Netclient().EgressDenyLog = false
+		nfJumpRules, nfFilterJumpRules, nfNatJumpRules = nil, nil, nil
+	}()
+	config.Netclient().EgressDenyLog = true
+	if err := buildNfJumpRules("nm-test"); err != nil {
+		t.Fatal(err)
+	}
+	accept, drop := outboundOnlyRuleSpecs("192.168.1.0/24", "nm-test")
+	recorded := []ruleInfo{
+		{table: defaultIpTable, chain: iptableFWDChain, rule: []string{"-s", "10.0.0.5", "!", "-d", "10.0.0.1", "-j", netmakerFilterChain}},
+		{table: defaultIpTable, chain: netmakerFilterChain, rule: []string{"-s", "10.0.0.5", "-d", "10.0.0.6", "-j", "ACCEPT"}},
+		{table: defaultIpTable, chain: iptableFWDChain, rule: []string{"-i", "nm-test", "-d", "192.168.1.0/24,192.168.2.0/24", "-j", netmakerFilterChain}},
+		{table: defaultIpTable, chain: iptableFWDChain, rule: []string{"-i", "nm-test", "-d", "fd00::/64", "-j", netmakerFilterChain}},
+		{table: defaultNatTable, chain: nattablePRTChain, rule: []string{"-s", "10.0.0.0/24", "-o", "eth0", "!", "-d", "192.168.1.0/24", "-j", "MASQUERADE"}},
+		// recorded in the order the manager inserts them
+		{table: defaultIpTable, chain: iptableFWDChain, rule: drop},
+		{table: defaultIpTable, chain: iptableFWDChain, rule: accept},
+	}
+	n := newTestNftManager(t, nftables.WithTestDial(func(req []netlink.Message) ([]netlink.Message, error) {
+		t.Error("expected the export not to touch the firewall")
+		return req, nil
+	}))
+	n.ingRules = serverrulestable{"netmaker": ruletable{
+		"ext": {isIpv4: true, rulesMap: map[string][]ruleInfo{"ext": recorded[:1], "peer": recorded[1:2]}},
+	}}
+	n.engressRules = serverrulestable{"netmaker": ruletable{
+		"egress": {isIpv4: true, rulesMap: map[string][]ruleInfo{"egress": recorded[2:]}},
+	}}
+
+	script, err := n.ExportRules("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// match modules have no nft counterpart, they are implied by their options
+	withoutModules := func(spec []string) []string {
+		stripped := []string{}
+		for i := 0; i < len(spec); i++ {
+			if spec[i] == "-m" {
+				i++
+				continue
+			}
+			stripped = append(stripped, spec[i])
+		}
+		return stripped
+	}
+	want := [][]string{}
+	for _, rule := range recorded {
+		want = append(want, append([]string{rule.table, rule.chain}, withoutModules(rule.rule)...))
+	}
+	for _, rule := range nfJumpRules {
+		r := rule.nfRule.(*nftables.Rule)
+		want = append(want, append([]string{r.Table.Name, r.Chain.Name}, rule.rule...))
+	}
+	got := [][]string{}
+	// the rules of each chain in the order nft leaves them in after running the script
+	chains := map[string][]string{}
+	for _, line := range strings.Split(script, "\n") {
+		if !strings.HasPrefix(line, "add rule ") && !strings.HasPrefix(line, "insert rule ") {
+			continue
+		}
+		spec := nftRuleSpec(t, line)
+		got = append(got, spec)
+		chain, key := spec[0]+" "+spec[1], genRuleKey(spec[2:]...)
+		if strings.HasPrefix(line, "insert rule ") {
+			chains[chain] = append([]string{key}, chains[chain]...)
+		} else {
+			chains[chain] = append(chains[chain], key)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected the exported rules to round-trip the rule specs\ngot  %v\nwant %v\nscript:\n%s", got, want, script)
+	}
+	position := func(chain string, spec []string) int {
+		for i, key := range chains[chain] {
+			if key == genRuleKey(withoutModules(spec)...) {
+				return i
+			}
+		}
+		t.Fatalf("rule %v not in chain %s", spec, chain)
+		return -1
+	}
+	fwd := defaultIpTable + " " + iptableFWDChain
+	if position(fwd, accept) > position(fwd, drop) {
+		t.Fatalf("expected the accept of return traffic ahead of the drop, forward chain %v", chains[fwd])
+	}
+	jump := nfForwardJumpRule("nm-test").rule
+	if position(fwd, recorded[0].rule) > position(fwd, jump) {
+		t.Fatalf("expected the recorded rules ahead of the jump rules, forward chain %v", chains[fwd])
+	}
+	if !strings.Contains(script, "add chain inet filter FORWARD { type filter hook forward priority filter; policy accept; }") {
+		t.Fatalf("expected the forward chain in the script, got\n%s", script)
+	}
+
+	if _, err := n.ExportRules("other"); err == nil {
+		t.Fatal("expected error for a server without rules")
+	}
+}
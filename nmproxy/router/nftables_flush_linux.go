@@ -0,0 +1,80 @@
+package router
+
+import (
+	"strings"
+
+	"github.com/google/nftables"
+)
+
+// egressSetPrefix - prefix of the names of the egress range sets
+const egressSetPrefix = "nmeg"
+
+// isNetmakerRuleKey - checks if the user data of a rule is a key generated by genRuleKey, i.e. netmaker added the rule,
+// comments of rules added by the nft tool are encoded as binary attributes
+func isNetmakerRuleKey(userData []byte) bool {
+	if !strings.Contains(string(userData), ":-j:") {
+		return false
+	}
+	for _, b := range userData {
+		if b < 0x20 || b > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// isNetmakerChain - checks if the chain was created by netmaker
+func isNetmakerChain(chain *nftables.Chain) bool {
+	return (chain.Table.Name == defaultIpTable && chain.Name == netmakerFilterChain) ||
+		(chain.Table.Name == defaultNatTable && chain.Name == netmakerNatChain)
+}
+
+// nftables.deleteNetmakerRules - deletes the rules netmaker added to its tables, leaving the rules of other tools in place
+func (n *nftablesManager) deleteNetmakerRules() error {
+	chains, err := n.conn.ListChainsOfTableFamily(nftables.TableFamilyINet)
+	if err != nil {
+		return err
+	}
+	for _, chain := range chains {
+		if chain.Table.Name != defaultIpTable && chain.Table.Name != defaultNatTable {
+			continue
+		}
+		rules, err := n.conn.GetRules(chain.Table, chain)
+		if err != nil {
+			return err
+		}
+		for _, rule := range rules {
+			if !isNetmakerRuleKey(rule.UserData) {
+				continue
+			}
+			if err := n.conn.DelRule(rule); err != nil {
+				return err
+			}
+		}
+	}
+	return n.flush()
+}
+
+// nftables.deleteNetmakerChains - deletes the netmaker chains and the egress range sets,
+// must be called once the rules jumping to or looking them up are deleted
+func (n *nftablesManager) deleteNetmakerChains() error {
+	chains, err := n.conn.ListChainsOfTableFamily(nftables.TableFamilyINet)
+	if err != nil {
+		return err
+	}
+	for _, chain := range chains {
+		if isNetmakerChain(chain) {
+			n.conn.DelChain(chain)
+		}
+	}
+	sets, err := n.conn.GetSets(filterTable)
+	if err != nil {
+		return err
+	}
+	for _, set := range sets {
+		if strings.HasPrefix(set.Name, egressSetPrefix) {
+			n.conn.DelSet(set)
+		}
+	}
+	return n.flush()
+}
@@ -0,0 +1,118 @@
+package router
+
+import (
+	"testing"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/binaryutil"
+	"github.com/mdlayher/netlink"
+	"golang.org/x/sys/unix"
+)
+
+type fakeNftRule struct {
+	table, chain string
+	handle       uint64
+	userData     []byte
+}
+
+// fakeFlushKernel - answers dumps with the given chains and rules, recording the handles of the deleted rules
+// and the names of the deleted chains
+func fakeFlushKernel(chains [][2]string, rules []fakeNftRule, delRules *[]uint64, delChains *[]string) nftables.ConnOption {
+	nftMsg := func(msgType int, req netlink.Message, attrs []netlink.Attribute) netlink.Message {
+		data, _ := netlink.MarshalAttributes(attrs)
+		return netlink.Message{
+			Header: netlink.Header{
+				Type:     netlink.HeaderType((unix.NFNL_SUBSYS_NFTABLES << 8) | msgType),
+				Sequence: req.Header.Sequence,
+				PID:      req.Header.PID,
+			},
+			Data: append([]byte{unix.NFPROTO_INET, 0, 0, 0}, data...),
+		}
+	}
+	attrs := func(m netlink.Message) map[uint16][]byte {
+		parsed := map[uint16][]byte{}
+		decoded, _ := netlink.UnmarshalAttributes(m.Data[4:])
+		for _, a := range decoded {
+			parsed[a.Type] = a.Data
+		}
+		return parsed
+	}
+	return nftables.WithTestDial(func(req []netlink.Message) ([]netlink.Message, error) {
+		var replies []netlink.Message
+		for _, m := range req {
+			switch int(m.Header.Type) & 0xff {
+			case unix.NFT_MSG_GETCHAIN:
+				for _, chain := range chains {
+					replies = append(replies, nftMsg(unix.NFT_MSG_NEWCHAIN, m, []netlink.Attribute{
+						{Type: unix.NFTA_CHAIN_TABLE, Data: []byte(chain[0] + "\x00")},
+						{Type: unix.NFTA_CHAIN_NAME, Data: []byte(chain[1] + "\x00")},
+					}))
+				}
+			case unix.NFT_MSG_GETRULE:
+				a := attrs(m)
+				for _, rule := range rules {
+					if string(a[unix.NFTA_RULE_TABLE]) != rule.table+"\x00" || string(a[unix.NFTA_RULE_CHAIN]) != rule.chain+"\x00" {
+						continue
+					}
+					replies = append(replies, nftMsg(unix.NFT_MSG_NEWRULE, m, []netlink.Attribute{
+						{Type: unix.NFTA_RULE_TABLE, Data: []byte(rule.table + "\x00")},
+						{Type: unix.NFTA_RULE_CHAIN, Data: []byte(rule.chain + "\x00")},
+						{Type: unix.NFTA_RULE_HANDLE, Data: binaryutil.BigEndian.PutUint64(rule.handle)},
+						{Type: unix.NFTA_RULE_USERDATA, Data: rule.userData},
+					}))
+				}
+			case unix.NFT_MSG_GETSET:
+			case unix.NFT_MSG_DELRULE:
+				*delRules = append(*delRules, binaryutil.BigEndian.Uint64(attrs(m)[unix.NFTA_RULE_HANDLE]))
+				replies = append(replies, m)
+			case unix.NFT_MSG_DELCHAIN:
+				name := attrs(m)[unix.NFTA_CHAIN_NAME]
+				*delChains = append(*delChains, string(name[:len(name)-1]))
+				replies = append(replies, m)
+			default:
+				replies = append(replies, m)
+			}
+		}
+		return replies, nil
+	})
+}
+
+func TestNftFlushAllKeepsForeignRules(t *testing.T) {
+	chains := [][2]string{
+		{defaultIpTable, iptableFWDChain},
+		{defaultIpTable, "INPUT"},
+		{defaultIpTable, netmakerFilterChain},
+		{defaultNatTable, netmakerNatChain},
+	}
+	// comment of a rule added with the nft tool, a binary attribute
+	nftComment := append([]byte{0, 12}, []byte("allow admin\x00")...)
+	rules := []fakeNftRule{
+		{table: defaultIpTable, chain: iptableFWDChain, handle: 1, userData: []byte(genRuleKey("-i", "netmaker", "-j", netmakerFilterChain))},
+		{table: defaultIpTable, chain: iptableFWDChain, handle: 2, userData: nftComment},
+		{table: defaultIpTable, chain: "INPUT", handle: 3},
+		{table: defaultIpTable, chain: netmakerFilterChain, handle: 4, userData: []byte(genRuleKey("-s", "10.0.0.2", "-d", "10.0.0.3", "-j", "ACCEPT"))},
+		{table: defaultNatTable, chain: netmakerNatChain, handle: 5, userData: []byte(genRuleKey("-s", "10.0.0.2", "-o", "netmaker", "-j", "MASQUERADE"))},
+	}
+	var delRules []uint64
+	var delChains []string
+	n := newTestNftManager(t, fakeFlushKernel(chains, rules, &delRules, &delChains))
+	n.FlushAll()
+
+	deleted := map[uint64]bool{}
+	for _, h := range delRules {
+		deleted[h] = true
+	}
+	for _, h := range []uint64{1, 4, 5} {
+		if !deleted[h] {
+			t.Errorf("netmaker rule %d should be deleted, deleted %v", h, delRules)
+		}
+	}
+	for _, h := range []uint64{2, 3} {
+		if deleted[h] {
+			t.Errorf("foreign rule %d should survive the flush", h)
+		}
+	}
+	if len(delChains) != 2 || delChains[0] != netmakerFilterChain || delChains[1] != netmakerNatChain {
+		t.Errorf("expected only the netmaker chains to be deleted, got %v", delChains)
+	}
+}
@@ -40,18 +40,33 @@ type nftablesManager struct {
 	mux          sync.Mutex
 }
 
-func init() {
-	nfJumpRules = append(nfJumpRules, nfFilterJumpRules...)
-	nfJumpRules = append(nfJumpRules, nfNatJumpRules...)
-}
-
 var (
 	filterTable = &nftables.Table{Name: defaultIpTable, Family: nftables.TableFamilyINet}
 	natTable    = &nftables.Table{Name: defaultNatTable, Family: nftables.TableFamilyINet}
 
 	nfJumpRules []ruleInfo
 	// filter table netmaker jump rules
-	nfFilterJumpRules = []ruleInfo{
+	nfFilterJumpRules []ruleInfo
+	// nat table nm jump rules
+	nfNatJumpRules []ruleInfo
+)
+
+// buildNfJumpRules - builds the netmaker jump rules for the interface
+func buildNfJumpRules(ifaceName string) error {
+	if err := validateIfaceName(ifaceName); err != nil {
+		return err
+	}
+	nfFilterJumpRules = withDenyLogRule(nfFilterJumpRulesFor(ifaceName), nfDenyLogRule(ifaceName))
+	nfNatJumpRules = nfNatJumpRulesFor(ifaceName)
+	nfJumpRules = []ruleInfo{}
+	nfJumpRules = append(nfJumpRules, nfFilterJumpRules...)
+	nfJumpRules = append(nfJumpRules, nfNatJumpRules...)
+	return nil
+}
+
+// nfFilterJumpRulesFor - filter table netmaker jump rules for the interface
+func nfFilterJumpRulesFor(ifaceName string) []ruleInfo {
+	return []ruleInfo{
 		{
 			nfRule: &nftables.Rule{
 				Table: filterTable,
@@ -61,14 +76,14 @@ var (
 					&expr.Cmp{
 						Op:       expr.CmpOpEq,
 						Register: 1,
-						Data:     []byte(ncutils.GetInterfaceName() + "\x00"),
+						Data:     []byte(ifaceName + "\x00"),
 					},
 					&expr.Counter{},
 					&expr.Verdict{Kind: expr.VerdictDrop},
 				},
-				UserData: []byte(genRuleKey("-i", ncutils.GetInterfaceName(), "-j", "DROP")),
+				UserData: []byte(genRuleKey("-i", ifaceName, "-j", "DROP")),
 			},
-			rule:  []string{"-i", ncutils.GetInterfaceName(), "-j", "DROP"},
+			rule:  []string{"-i", ifaceName, "-j", "DROP"},
 			table: defaultIpTable,
 			chain: netmakerFilterChain,
 		},
@@ -81,40 +96,62 @@ var (
 					&expr.Cmp{
 						Op:       expr.CmpOpEq,
 						Register: 1,
-						Data:     []byte(ncutils.GetInterfaceName() + "\x00"),
+						Data:     []byte(ifaceName + "\x00"),
 					},
 					&expr.Counter{},
 					&expr.Verdict{Kind: expr.VerdictReturn},
 				},
-				UserData: []byte(genRuleKey("-i", ncutils.GetInterfaceName(), "-j", "RETURN")),
+				UserData: []byte(genRuleKey("-i", ifaceName, "-j", "RETURN")),
 			},
-			rule:  []string{"-i", ncutils.GetInterfaceName(), "-j", "RETURN"},
+			rule:  []string{"-i", ifaceName, "-j", "RETURN"},
 			table: defaultIpTable,
 			chain: netmakerFilterChain,
 		},
-		{
-			nfRule: &nftables.Rule{
-				Table: filterTable,
-				Chain: &nftables.Chain{Name: iptableFWDChain},
-				Exprs: []expr.Any{
-					&expr.Meta{Key: expr.MetaKeyIIFNAME, Register: 1},
-					&expr.Cmp{
-						Op:       expr.CmpOpEq,
-						Register: 1,
-						Data:     []byte(ncutils.GetInterfaceName() + "\x00"),
-					},
-					&expr.Counter{},
-					&expr.Verdict{Kind: expr.VerdictJump, Chain: netmakerFilterChain},
+		nfForwardJumpRule(ifaceName),
+	}
+}
+
+// nfForwardJumpRule - jump from the forward chain to the netmaker filter chain for traffic from the interface
+func nfForwardJumpRule(ifaceName string) ruleInfo {
+	return ruleInfo{
+		nfRule: &nftables.Rule{
+			Table: filterTable,
+			Chain: &nftables.Chain{Name: iptableFWDChain},
+			Exprs: []expr.Any{
+				&expr.Meta{Key: expr.MetaKeyIIFNAME, Register: 1},
+				&expr.Cmp{
+					Op:       expr.CmpOpEq,
+					Register: 1,
+					Data:     []byte(ifaceName + "\x00"),
 				},
-				UserData: []byte(genRuleKey("-i", ncutils.GetInterfaceName(), "-j", netmakerFilterChain)),
+				&expr.Counter{},
+				&expr.Verdict{Kind: expr.VerdictJump, Chain: netmakerFilterChain},
 			},
-			rule:  []string{"-i", ncutils.GetInterfaceName(), "-j", netmakerFilterChain},
-			table: defaultIpTable,
-			chain: netmakerFilterChain,
+			UserData: []byte(genRuleKey("-i", ifaceName, "-j", netmakerFilterChain)),
 		},
+		rule:  []string{"-i", ifaceName, "-j", netmakerFilterChain},
+		table: defaultIpTable,
+		chain: netmakerFilterChain,
 	}
-	// nat table nm jump rules
-	nfNatJumpRules = []ruleInfo{
+}
+
+// nfForwardChain - the filter table forward chain with the policy
+func nfForwardChain(policy nftables.ChainPolicy) *nftables.Chain {
+	defaultForwardPolicy := new(nftables.ChainPolicy)
+	*defaultForwardPolicy = policy
+	return &nftables.Chain{
+		Name:     iptableFWDChain,
+		Table:    filterTable,
+		Type:     nftables.ChainTypeFilter,
+		Hooknum:  nftables.ChainHookForward,
+		Priority: nftables.ChainPriorityFilter,
+		Policy:   defaultForwardPolicy,
+	}
+}
+
+// nfNatJumpRulesFor - nat table nm jump rules for the interface
+func nfNatJumpRulesFor(ifaceName string) []ruleInfo {
+	return []ruleInfo{
 		{
 			nfRule: &nftables.Rule{
 				Table: natTable,
@@ -124,14 +161,14 @@ var (
 					&expr.Cmp{
 						Op:       expr.CmpOpEq,
 						Register: 1,
-						Data:     []byte(ncutils.GetInterfaceName() + "\x00"),
+						Data:     []byte(ifaceName + "\x00"),
 					},
 					&expr.Counter{},
 					&expr.Verdict{Kind: expr.VerdictJump, Chain: netmakerNatChain},
 				},
-				UserData: []byte(genRuleKey("-o", ncutils.GetInterfaceName(), "-j", netmakerNatChain)),
+				UserData: []byte(genRuleKey("-o", ifaceName, "-j", netmakerNatChain)),
 			},
-			rule:  []string{"-o", ncutils.GetInterfaceName(), "-j", netmakerNatChain},
+			rule:  []string{"-o", ifaceName, "-j", netmakerNatChain},
 			table: defaultNatTable,
 			chain: nattablePRTChain,
 		},
@@ -150,37 +187,37 @@ var (
 			chain: netmakerNatChain,
 		},
 	}
-)
+}
 
 // nftables.CreateChains - creates default chains and rules
 func (n *nftablesManager) CreateChains() error {
 	n.mux.Lock()
 	defer n.mux.Unlock()
+	if err := buildNfJumpRules(ncutils.GetInterfaceName()); err != nil {
+		return err
+	}
+	// accept in the forward chain while the chains are rebuilt, a drop policy left by a previous run
+	// would drop the traffic of the interface once its jump rules are removed
+	n.conn.AddTable(filterTable)
+	n.conn.AddChain(nfForwardChain(nftables.ChainPolicyAccept))
+	if err := n.flush(); err != nil {
+		return err
+	}
 	// remove jump rules
 	n.removeJumpRules()
+	n.removeStaleForwardJumps()
 
 	n.conn.AddTable(filterTable)
 	n.conn.AddTable(natTable)
 
-	if err := n.conn.Flush(); err != nil {
+	if err := n.flush(); err != nil {
 		return err
 	}
 
 	n.deleteChain(defaultIpTable, netmakerFilterChain)
 	n.deleteChain(defaultNatTable, netmakerNatChain)
 
-	defaultForwardPolicy := new(nftables.ChainPolicy)
-	*defaultForwardPolicy = nftables.ChainPolicyAccept
-
-	forwardChain := &nftables.Chain{
-		Name:     iptableFWDChain,
-		Table:    filterTable,
-		Type:     nftables.ChainTypeFilter,
-		Hooknum:  nftables.ChainHookForward,
-		Priority: nftables.ChainPriorityFilter,
-		Policy:   defaultForwardPolicy,
-	}
-	n.conn.AddChain(forwardChain)
+	n.conn.AddChain(nfForwardChain(nftables.ChainPolicyAccept))
 
 	n.conn.AddChain(&nftables.Chain{
 		Name:     "INPUT",
@@ -240,7 +277,7 @@ func (n *nftablesManager) CreateChains() error {
 	}
 	n.conn.AddChain(natChain)
 
-	if err := n.conn.Flush(); err != nil {
+	if err := n.flush(); err != nil {
 		return err
 	}
 	// add jump rules
@@ -248,26 +285,47 @@ func (n *nftablesManager) CreateChains() error {
 	return nil
 }
 
-// nftables.ForwardRule - forward netmaker traffic (not implemented)
+// nftables.ForwardRule - inserts the jump from the forward chain to the netmaker filter chain if it is missing,
+// the chains are only created when they do not exist so existing rules are left untouched
 func (n *nftablesManager) ForwardRule() error {
-	if err := n.CreateChains(); err != nil {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	ifaceName := ncutils.GetInterfaceName()
+	if err := validateIfaceName(ifaceName); err != nil {
 		return err
 	}
-	n.conn.AddRule(&nftables.Rule{
-		Table: filterTable,
-		Chain: &nftables.Chain{Name: iptableFWDChain},
+	if err := n.ensureForwardChains(); err != nil {
+		return fmt.Errorf("failed to create chains for forward rule: %w", err)
+	}
+	rule := nfForwardJumpRule(ifaceName).nfRule.(*nftables.Rule)
+	if _, err := n.getRule(defaultIpTable, iptableFWDChain, string(rule.UserData)); err == nil {
+		return nil
+	}
+	logger.Log(0, "adding forwarding rule")
+	n.conn.InsertRule(rule)
+	if err := n.flush(); err != nil {
+		return fmt.Errorf("failed to add forward rule: %w", err)
+	}
+	return nil
+}
 
-		Exprs: []expr.Any{
-			&expr.Meta{Key: expr.MetaKeyIIFNAME, Register: 1},
-			&expr.Cmp{
-				Op:       expr.CmpOpEq,
-				Register: 1,
-				Data:     []byte(ncutils.GetInterfaceName() + "\x00"),
-			},
-			&expr.Verdict{Kind: expr.VerdictJump, Chain: netmakerFilterChain},
-		},
-	})
-	return n.conn.Flush()
+// nftables.ensureForwardChains - creates the forward and netmaker filter chains if they do not exist
+func (n *nftablesManager) ensureForwardChains() error {
+	missing := false
+	if _, err := n.getChain(defaultIpTable, iptableFWDChain); err != nil {
+		n.conn.AddTable(filterTable)
+		n.conn.AddChain(nfForwardChain(nftables.ChainPolicyAccept))
+		missing = true
+	}
+	if _, err := n.getChain(defaultIpTable, netmakerFilterChain); err != nil {
+		n.conn.AddTable(filterTable)
+		n.conn.AddChain(&nftables.Chain{Name: netmakerFilterChain, Table: filterTable})
+		missing = true
+	}
+	if !missing {
+		return nil
+	}
+	return n.flush()
 }
 
 // nftables.CleanRoutingRules cleans existing nftable resources that we created by the agent
@@ -279,7 +337,9 @@ func (n *nftablesManager) CleanRoutingRules(server, ruleTableName string) {
 	for _, rulesCfg := range ruleTable {
 		for _, rules := range rulesCfg.rulesMap {
 			for _, rule := range rules {
-				if err := n.deleteRule(rule.table, rule.chain, genRuleKey(rule.rule...)); err != nil {
+				if err := n.deleteRuleInfo(rule); errors.Is(err, ErrRuleNotFound) {
+					logger.Log(2, "rule already removed: ", err.Error())
+				} else if err != nil {
 					logger.Log(0, "Error cleaning up rule: ", err.Error())
 				}
 			}
@@ -298,6 +358,7 @@ func (n *nftablesManager) DeleteRuleTable(server, ruleTableName string) {
 	case egressTable:
 		delete(n.engressRules, server)
 	}
+	n.persistRules()
 }
 
 // nftables.InsertEgressRoutingRules - inserts egress routes for the GW peers
@@ -311,116 +372,70 @@ func (n *nftablesManager) InsertEgressRoutingRules(server string, egressInfo mod
 		rule           *nftables.Rule
 		isIpv4         = isAddrIpv4(egressInfo.EgressGwAddr.String())
 		egressGwRoutes = []ruleInfo{}
+		// failed - rules that were not added, reported so the caller can retry the gateway
+		failed int
 	)
 	ruleTable[egressInfo.EgressID] = rulesCfg{
 		isIpv4:   isIpv4,
 		rulesMap: make(map[string][]ruleInfo),
 	}
+	// the ranges are matched through sets, falling back to a rule per range if they can't be added
+	rangeSetsAdded := false
+	if setRoutes, err := n.insertEgressRangeSets(egressInfo.EgressID, egressInfo.EgressGWCfg.Ranges); err != nil {
+		logger.Log(0, "failed to add egress range sets, adding a rule per range:", netlinkErr(err).Error())
+	} else {
+		rangeSetsAdded = true
+		egressGwRoutes = append(egressGwRoutes, setRoutes...)
+	}
 	for _, egressGwRange := range egressInfo.EgressGWCfg.Ranges {
 		egressIP, cidr, err := net.ParseCIDR(egressGwRange)
 		if err != nil {
 			logger.Log(0, "Invalid egress CIDR: ", cidr.String(), " Err: ", err.Error())
 			continue
 		}
-		ruleSpec := []string{"-i", ncutils.GetInterfaceName(), "-d", egressGwRange, "-j", netmakerFilterChain}
-		if isIpv4 {
-			rule = &nftables.Rule{
-				Table:    filterTable,
-				Chain:    &nftables.Chain{Name: iptableFWDChain, Table: filterTable},
-				UserData: []byte(genRuleKey(ruleSpec...)),
-				Exprs: []expr.Any{
-					&expr.Meta{Key: expr.MetaKeyNFPROTO, Register: 1},
-					&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{unix.NFPROTO_IPV4}},
-					&expr.Meta{Key: expr.MetaKeyIIFNAME, Register: 1},
-					&expr.Cmp{
-						Op:       expr.CmpOpEq,
-						Register: 1,
-						Data:     []byte(ncutils.GetInterfaceName() + "\x00"),
-					},
-					&expr.Payload{
-						DestRegister: 1,
-						Base:         expr.PayloadBaseNetworkHeader,
-						Offset:       ipv4DestOffset,
-						Len:          ipv4Len,
-					},
-					// for CIDR ranges
-					&expr.Bitwise{
-						DestRegister:   1,
-						SourceRegister: 1,
-						Len:            ipv4Len,
-						Mask:           cidr.Mask,
-						Xor:            zeroXor,
-					},
-					&expr.Cmp{
-						Register: 1,
-						Data:     egressIP.To4(),
-					},
-					&expr.Counter{},
-					&expr.Verdict{
-						Kind:  expr.VerdictJump,
-						Chain: netmakerFilterChain,
-					},
-				},
-			}
-		} else {
-			rule = &nftables.Rule{
-				Table:    filterTable,
-				Chain:    &nftables.Chain{Name: iptableFWDChain, Table: filterTable},
-				UserData: []byte(genRuleKey(ruleSpec...)),
-				Exprs: []expr.Any{
-					&expr.Meta{Key: expr.MetaKeyNFPROTO, Register: 1},
-					&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{unix.NFPROTO_IPV6}},
-					&expr.Meta{Key: expr.MetaKeyIIFNAME, Register: 1},
-					&expr.Cmp{
-						Op:       expr.CmpOpEq,
-						Register: 1,
-						Data:     []byte(ncutils.GetInterfaceName() + "\x00"),
-					},
-					&expr.Payload{
-						DestRegister: 1,
-						Base:         expr.PayloadBaseNetworkHeader,
-						Offset:       ipv6DestOffset,
-						Len:          ipv6Len,
-					},
-					// for CIDR ranges
-					&expr.Bitwise{
-						DestRegister:   1,
-						SourceRegister: 1,
-						Len:            ipv6Len,
-						Mask:           cidr.Mask,
-						Xor:            zeroXor6,
-					},
-					&expr.Cmp{
-						Register: 1,
-						Data:     egressIP.To16(),
-					},
-					&expr.Counter{},
-					&expr.Verdict{
-						Kind:  expr.VerdictJump,
-						Chain: netmakerFilterChain,
-					},
-				},
+		if !rangeSetsAdded {
+			ruleSpec := []string{"-i", ncutils.GetInterfaceName(), "-d", egressGwRange, "-j", netmakerFilterChain}
+			rule = nfEgressRangeJumpRule(ruleSpec, egressIP, cidr, isIpv4)
+			n.conn.InsertRule(rule)
+			if err := n.flush(); err != nil {
+				ruleLog.Log(0, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
+				failed++
+			} else {
+				egressGwRoutes = append(egressGwRoutes, ruleInfo{
+					nfRule: rule,
+					table:  defaultIpTable,
+					chain:  iptableFWDChain,
+					rule:   ruleSpec,
+				})
 			}
 		}
-		n.conn.InsertRule(rule)
-		if err := n.conn.Flush(); err != nil {
-			logger.Log(0, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
-		} else {
-			egressGwRoutes = append(egressGwRoutes, ruleInfo{
-				nfRule: rule,
-				table:  defaultIpTable,
-				chain:  iptableFWDChain,
-				rule:   ruleSpec,
-			})
+		if outboundOnlyEgress() {
+			accept, drop := outboundOnlyRuleSpecs(egressGwRange, ncutils.GetInterfaceName())
+			// drop is inserted first so the accept of return traffic ends up ahead of it
+			for _, ruleSpec := range [][]string{drop, accept} {
+				rule := nfOutboundOnlyRule(ruleSpec, egressIP, cidr, ncutils.GetInterfaceName(), isIpv4, ruleTarget(ruleSpec) == targetAccept)
+				n.conn.InsertRule(rule)
+				if err := n.flush(); err != nil {
+					ruleLog.Log(0, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
+					failed++
+					continue
+				}
+				egressGwRoutes = append(egressGwRoutes, ruleInfo{
+					nfRule: rule,
+					table:  defaultIpTable,
+					chain:  iptableFWDChain,
+					rule:   ruleSpec,
+				})
+			}
 		}
 
 		if egressInfo.EgressGWCfg.NatEnabled == "yes" {
 			if egressRangeIface, err := getInterfaceName(config.ToIPNet(egressGwRange)); err != nil {
 				logger.Log(0, "failed to get interface name: ", egressRangeIface, err.Error())
 			} else {
-				ruleSpec := []string{"-s", egressInfo.Network.String(), "-o", egressRangeIface, "-j", "MASQUERADE"}
-				// to avoid duplicate iface route rule,delete if exists
-				n.deleteRule(defaultNatTable, nattablePRTChain, genRuleKey(ruleSpec...))
+				excluded := masqExcludedRanges(isIpv4)
+				ruleSpec := []string{"-s", egressInfo.Network.String(), "-o", egressRangeIface}
+				ruleSpec = append(append(ruleSpec, masqExclusionSpec(excluded)...), "-j", "MASQUERADE")
 				if isIpv4 {
 					rule = &nftables.Rule{
 						Table:    natTable,
@@ -453,10 +468,10 @@ func (n *nftablesManager) InsertEgressRoutingRules(server string, egressInfo mod
 								Register: 1,
 								Data:     egressInfo.Network.IP.To4(),
 							},
-							&expr.Counter{},
-							&expr.Masq{},
 						},
 					}
+					rule.Exprs = append(rule.Exprs, nfMasqExclusionExprs(excluded, isIpv4)...)
+					rule.Exprs = append(rule.Exprs, &expr.Counter{}, &expr.Masq{})
 				} else {
 					rule = &nftables.Rule{
 						Table:    natTable,
@@ -489,14 +504,14 @@ func (n *nftablesManager) InsertEgressRoutingRules(server string, egressInfo mod
 								Register: 1,
 								Data:     egressInfo.Network.IP.To16(),
 							},
-							&expr.Counter{},
-							&expr.Masq{},
 						},
 					}
+					rule.Exprs = append(rule.Exprs, nfMasqExclusionExprs(excluded, isIpv4)...)
+					rule.Exprs = append(rule.Exprs, &expr.Counter{}, &expr.Masq{})
 				}
-				n.conn.InsertRule(rule)
-				if err := n.conn.Flush(); err != nil {
-					logger.Log(0, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
+				if err := n.insertEgressNatRule(ruleTable, egressInfo.EgressID, rule, ruleSpec); err != nil {
+					ruleLog.Log(0, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
+					failed++
 				} else {
 					egressGwRoutes = append(egressGwRoutes, ruleInfo{
 						nfRule: rule,
@@ -506,7 +521,6 @@ func (n *nftablesManager) InsertEgressRoutingRules(server string, egressInfo mod
 					})
 				}
 				ruleSpec = []string{"-d", egressInfo.Network.String(), "-o", egressRangeIface, "-j", "MASQUERADE"}
-				n.deleteRule(defaultNatTable, nattablePRTChain, genRuleKey(ruleSpec...))
 				if isIpv4 {
 					rule = &nftables.Rule{
 						Table:    natTable,
@@ -580,9 +594,9 @@ func (n *nftablesManager) InsertEgressRoutingRules(server string, egressInfo mod
 						},
 					}
 				}
-				n.conn.InsertRule(rule)
-				if err := n.conn.Flush(); err != nil {
-					logger.Log(0, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
+				if err := n.insertEgressNatRule(ruleTable, egressInfo.EgressID, rule, ruleSpec); err != nil {
+					ruleLog.Log(0, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
+					failed++
 				} else {
 					egressGwRoutes = append(egressGwRoutes, ruleInfo{
 						nfRule: rule,
@@ -695,8 +709,9 @@ func (n *nftablesManager) InsertEgressRoutingRules(server string, egressInfo mod
 				}
 			}
 			n.conn.InsertRule(rule)
-			if err := n.conn.Flush(); err != nil {
-				logger.Log(0, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
+			if err := n.flush(); err != nil {
+				ruleLog.Log(0, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
+				failed++
 			} else {
 				ruleTable[egressInfo.EgressID].rulesMap[peer.PeerKey] = append(ruleTable[egressInfo.EgressID].rulesMap[peer.PeerKey],
 					ruleInfo{
@@ -710,7 +725,100 @@ func (n *nftablesManager) InsertEgressRoutingRules(server string, egressInfo mod
 	}
 	ruleTable[egressInfo.EgressID].rulesMap[egressInfo.EgressID] = egressGwRoutes
 
-	return nil
+	return rulesNotAdded(failed)
+}
+
+// nfEgressRangeJumpRule - jump to the netmaker filter chain for traffic from the interface to the egress range
+func nfEgressRangeJumpRule(ruleSpec []string, egressIP net.IP, cidr *net.IPNet, isIpv4 bool) *nftables.Rule {
+	if isIpv4 {
+		return &nftables.Rule{
+			Table:    filterTable,
+			Chain:    &nftables.Chain{Name: iptableFWDChain, Table: filterTable},
+			UserData: []byte(genRuleKey(ruleSpec...)),
+			Exprs: []expr.Any{
+				&expr.Meta{Key: expr.MetaKeyNFPROTO, Register: 1},
+				&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{unix.NFPROTO_IPV4}},
+				&expr.Meta{Key: expr.MetaKeyIIFNAME, Register: 1},
+				&expr.Cmp{
+					Op:       expr.CmpOpEq,
+					Register: 1,
+					Data:     []byte(ncutils.GetInterfaceName() + "\x00"),
+				},
+				&expr.Payload{
+					DestRegister: 1,
+					Base:         expr.PayloadBaseNetworkHeader,
+					Offset:       ipv4DestOffset,
+					Len:          ipv4Len,
+				},
+				// for CIDR ranges
+				&expr.Bitwise{
+					DestRegister:   1,
+					SourceRegister: 1,
+					Len:            ipv4Len,
+					Mask:           cidr.Mask,
+					Xor:            zeroXor,
+				},
+				&expr.Cmp{
+					Register: 1,
+					Data:     egressIP.To4(),
+				},
+				&expr.Counter{},
+				&expr.Verdict{
+					Kind:  expr.VerdictJump,
+					Chain: netmakerFilterChain,
+				},
+			},
+		}
+	}
+	return &nftables.Rule{
+		Table:    filterTable,
+		Chain:    &nftables.Chain{Name: iptableFWDChain, Table: filterTable},
+		UserData: []byte(genRuleKey(ruleSpec...)),
+		Exprs: []expr.Any{
+			&expr.Meta{Key: expr.MetaKeyNFPROTO, Register: 1},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{unix.NFPROTO_IPV6}},
+			&expr.Meta{Key: expr.MetaKeyIIFNAME, Register: 1},
+			&expr.Cmp{
+				Op:       expr.CmpOpEq,
+				Register: 1,
+				Data:     []byte(ncutils.GetInterfaceName() + "\x00"),
+			},
+			&expr.Payload{
+				DestRegister: 1,
+				Base:         expr.PayloadBaseNetworkHeader,
+				Offset:       ipv6DestOffset,
+				Len:          ipv6Len,
+			},
+			// for CIDR ranges
+			&expr.Bitwise{
+				DestRegister:   1,
+				SourceRegister: 1,
+				Len:            ipv6Len,
+				Mask:           cidr.Mask,
+				Xor:            zeroXor6,
+			},
+			&expr.Cmp{
+				Register: 1,
+				Data:     egressIP.To16(),
+			},
+			&expr.Counter{},
+			&expr.Verdict{
+				Kind:  expr.VerdictJump,
+				Chain: netmakerFilterChain,
+			},
+		},
+	}
+}
+
+// nftables.insertEgressNatRule - inserts a nat rule of an egress gateway unless another gateway already holds it
+func (n *nftablesManager) insertEgressNatRule(ruleTable ruletable, egressID string, rule *nftables.Rule, ruleSpec []string) error {
+	if sharedNatRule(ruleTable, egressID, ruleInfo{table: defaultNatTable, chain: nattablePRTChain, rule: ruleSpec}) {
+		return nil
+	}
+	// to avoid duplicate iface route rule,delete if exists
+	n.deleteRule(defaultNatTable, nattablePRTChain, genRuleKey(ruleSpec...))
+	n.conn.InsertRule(rule)
+	return n.flush()
 }
 
 // nftables.AddEgressRoutingRule - inserts an nftable rule for gateway peer
@@ -821,8 +929,8 @@ func (n *nftablesManager) AddEgressRoutingRule(server string, egressInfo models.
 			}
 		}
 		n.conn.InsertRule(rule)
-		if err := n.conn.Flush(); err != nil {
-			logger.Log(0, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
+		if err := n.flush(); err != nil {
+			ruleLog.Log(0, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
 		} else {
 			ruleTable[egressInfo.EgressID].rulesMap[peer.PeerKey] = append(ruleTable[egressInfo.EgressID].rulesMap[peer.PeerKey],
 				ruleInfo{
@@ -920,8 +1028,8 @@ func (n *nftablesManager) AddIngressRoutingRule(server, extPeerKey, extPeerAddr
 		}
 	}
 	n.conn.InsertRule(rule)
-	if err := n.conn.Flush(); err != nil {
-		logger.Log(0, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
+	if err := n.flush(); err != nil {
+		ruleLog.Log(0, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
 	}
 	ruleTable[extPeerKey].rulesMap[peerInfo.PeerKey] = []ruleInfo{
 		{
@@ -1026,16 +1134,15 @@ func (n *nftablesManager) InsertIngressRoutingRules(server string, extinfo model
 		rulesMap: make(map[string][]ruleInfo),
 	}
 	logger.Log(0, fmt.Sprintf("-----> adding rule: %+v", ruleSpec))
-	n.conn.InsertRule(rule)
-	if err := n.conn.Flush(); err != nil {
-		logger.Log(0, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
-	}
 	fwdJumpRule := ruleInfo{
 		nfRule: rule,
 		rule:   ruleSpec,
 		chain:  iptableFWDChain,
 		table:  defaultIpTable,
 	}
+	// rules are staged and inserted with a single flush once all of them are built
+	var batch nfRuleBatch
+	batch.stage(fwdJumpRule, nil)
 	nfJumpRules = append(nfJumpRules, fwdJumpRule)
 
 	ruleSpec = []string{"-s", extinfo.Network.String(), "-d", extinfo.ExtPeerAddr.String(), "-j", "ACCEPT"}
@@ -1087,22 +1194,17 @@ func (n *nftablesManager) InsertIngressRoutingRules(server string, extinfo model
 		}
 	}
 	logger.Log(0, fmt.Sprintf("-----> adding rule: %+v", ruleSpec))
-	n.conn.InsertRule(rule)
-	if err := n.conn.Flush(); err != nil {
-		logger.Log(0, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
-	}
-	ruleTable[extinfo.ExtPeerKey].rulesMap[extinfo.ExtPeerKey] = []ruleInfo{
-		fwdJumpRule,
-		{
-			nfRule: rule,
-			rule:   ruleSpec,
-			chain:  netmakerFilterChain,
-			table:  defaultIpTable,
-		},
+	extAcceptRule := ruleInfo{
+		nfRule: rule,
+		rule:   ruleSpec,
+		chain:  netmakerFilterChain,
+		table:  defaultIpTable,
 	}
-	routes := ruleTable[extinfo.ExtPeerKey].rulesMap[extinfo.ExtPeerKey]
+	batch.stage(extAcceptRule, nil)
+	routes := []ruleInfo{fwdJumpRule, extAcceptRule}
 	for _, peerInfo := range extinfo.Peers {
-		if !peerInfo.Allow || peerInfo.PeerKey == extinfo.ExtPeerKey {
+		if !peerInfo.Allow || peerInfo.PeerKey == extinfo.ExtPeerKey ||
+			isolatedPeer(server, peerInfo.PeerKey) {
 			continue
 		}
 		if err != nil {
@@ -1180,19 +1282,18 @@ func (n *nftablesManager) InsertIngressRoutingRules(server string, extinfo model
 			}
 		}
 		logger.Log(0, fmt.Sprintf("-----> adding rule: %+v", ruleSpec))
-		n.conn.InsertRule(rule)
-		if err := n.conn.Flush(); err != nil {
-			logger.Log(0, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
-			continue
-		}
-		ruleTable[extinfo.ExtPeerKey].rulesMap[peerInfo.PeerKey] = []ruleInfo{
-			{
-				nfRule: rule,
-				rule:   ruleSpec,
-				chain:  netmakerFilterChain,
-				table:  defaultIpTable,
-			},
-		}
+		peerKey := peerInfo.PeerKey
+		batch.stage(ruleInfo{
+			nfRule: rule,
+			rule:   ruleSpec,
+			chain:  netmakerFilterChain,
+			table:  defaultIpTable,
+		}, func(r ruleInfo) {
+			ruleTable[extinfo.ExtPeerKey].rulesMap[peerKey] = []ruleInfo{r}
+		})
+	}
+	addRoute := func(r ruleInfo) {
+		routes = append(routes, r)
 	}
 	for _, egressRangeI := range egressRanges {
 		ruleSpec := []string{"-s", extinfo.ExtPeerAddr.String(), "-d", egressRangeI, "-j", "ACCEPT"}
@@ -1283,19 +1384,13 @@ func (n *nftablesManager) InsertIngressRoutingRules(server string, extinfo model
 				},
 			}
 		}
-		n.conn.InsertRule(rule)
-		if err := n.conn.Flush(); err != nil {
-			logger.Log(0, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
-			continue
-		} else {
-			routes = append(routes, ruleInfo{
-				rule:          ruleSpec,
-				nfRule:        rule,
-				chain:         netmakerFilterChain,
-				table:         defaultIpTable,
-				egressExtRule: true,
-			})
-		}
+		batch.stage(ruleInfo{
+			rule:          ruleSpec,
+			nfRule:        rule,
+			chain:         netmakerFilterChain,
+			table:         defaultIpTable,
+			egressExtRule: true,
+		}, addRoute)
 
 		ruleSpec = []string{"-s", egressRangeI, "-d", extinfo.ExtPeerAddr.String(), "-j", "ACCEPT"}
 		logger.Log(0, fmt.Sprintf("-----> adding rule: %+v", ruleSpec))
@@ -1380,25 +1475,30 @@ func (n *nftablesManager) InsertIngressRoutingRules(server string, extinfo model
 				},
 			}
 		}
-		n.conn.InsertRule(rule)
-		if err := n.conn.Flush(); err != nil {
-			logger.Log(0, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
-			continue
-		} else {
-			routes = append(routes, ruleInfo{
-				rule:          ruleSpec,
-				nfRule:        rule,
-				chain:         netmakerFilterChain,
-				table:         defaultIpTable,
-				egressExtRule: true,
-			})
-		}
+		batch.stage(ruleInfo{
+			rule:          ruleSpec,
+			nfRule:        rule,
+			chain:         netmakerFilterChain,
+			table:         defaultIpTable,
+			egressExtRule: true,
+		}, addRoute)
 	}
-	ruleTable[extinfo.ExtPeerKey].rulesMap[extinfo.ExtPeerKey] = routes
-	if !extinfo.Masquerade {
-		return nil
+	// drop rules are staged last, so they are inserted on top of the accept rules of the chain
+	for _, pair := range extIsolationPairs(extinfo.ExtPeerAddr, isolatedExtClients(server, extinfo)) {
+		ruleSpec := extIsolationRuleSpec(pair[0], pair[1])
+		logger.Log(0, fmt.Sprintf("-----> adding rule: %+v", ruleSpec))
+		batch.stage(ruleInfo{
+			nfRule: nfExtIsolationRule(ruleSpec, pair[0].IP, pair[1].IP, isIpv4),
+			rule:   ruleSpec,
+			chain:  netmakerFilterChain,
+			table:  defaultIpTable,
+		}, addRoute)
+	}
+	if !ingressMasquerade(extinfo) {
+		err = n.insertBatch(batch)
+		ruleTable[extinfo.ExtPeerKey].rulesMap[extinfo.ExtPeerKey] = routes
+		return err
 	}
-	routes = ruleTable[extinfo.ExtPeerKey].rulesMap[extinfo.ExtPeerKey]
 	ruleSpec = []string{"-s", extinfo.ExtPeerAddr.String(), "-o", ncutils.GetInterfaceName(), "-j", "MASQUERADE"}
 	logger.Log(0, fmt.Sprintf("----->[NAT] adding rule: %+v", ruleSpec))
 	if isIpv4 {
@@ -1460,17 +1560,12 @@ func (n *nftablesManager) InsertIngressRoutingRules(server string, extinfo model
 			},
 		}
 	}
-	n.conn.InsertRule(rule)
-	if err := n.conn.Flush(); err != nil {
-		logger.Log(0, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
-	} else {
-		routes = append(routes, ruleInfo{
-			nfRule: rule,
-			rule:   ruleSpec,
-			table:  defaultNatTable,
-			chain:  netmakerNatChain,
-		})
-	}
+	batch.stage(ruleInfo{
+		nfRule: rule,
+		rule:   ruleSpec,
+		table:  defaultNatTable,
+		chain:  netmakerNatChain,
+	}, addRoute)
 
 	ruleSpec = []string{"-d", extinfo.ExtPeerAddr.String(), "-o", ncutils.GetInterfaceName(), "-j", "MASQUERADE"}
 	logger.Log(0, fmt.Sprintf("----->[NAT] adding rule: %+v", ruleSpec))
@@ -1533,19 +1628,15 @@ func (n *nftablesManager) InsertIngressRoutingRules(server string, extinfo model
 			},
 		}
 	}
-	n.conn.InsertRule(rule)
-	if err := n.conn.Flush(); err != nil {
-		logger.Log(0, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
-	} else {
-		routes = append(routes, ruleInfo{
-			nfRule: rule,
-			rule:   ruleSpec,
-			table:  defaultNatTable,
-			chain:  netmakerNatChain,
-		})
-	}
+	batch.stage(ruleInfo{
+		nfRule: rule,
+		rule:   ruleSpec,
+		table:  defaultNatTable,
+		chain:  netmakerNatChain,
+	}, addRoute)
+	err = n.insertBatch(batch)
 	ruleTable[extinfo.ExtPeerKey].rulesMap[extinfo.ExtPeerKey] = routes
-	return nil
+	return err
 }
 
 // nftables.RefreshEgressRangesOnIngressGw - deletes/adds rules for egress ranges for ext clients on the ingressGW
@@ -1566,7 +1657,9 @@ func (n *nftablesManager) RefreshEgressRangesOnIngressGw(server string, ingressU
 				updatedRules := []ruleInfo{}
 				for _, rule := range extRules {
 					if rule.egressExtRule {
-						if err := n.deleteRule(rule.table, rule.chain, genRuleKey(rule.rule...)); err != nil {
+						if err := n.deleteRule(rule.table, rule.chain, genRuleKey(rule.rule...)); errors.Is(err, ErrRuleNotFound) {
+							logger.Log(2, "rule already removed: ", err.Error())
+						} else if err != nil {
 							return fmt.Errorf("nftables: error while removing existing %s rules [%v] for %s: %v",
 								rule.table, rule.rule, extKey, err)
 						}
@@ -1684,8 +1777,8 @@ func (n *nftablesManager) RefreshEgressRangesOnIngressGw(server string, ingressU
 				}
 			}
 			n.conn.InsertRule(rule)
-			if err := n.conn.Flush(); err != nil {
-				logger.Log(0, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
+			if err := n.flush(); err != nil {
+				ruleLog.Log(0, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
 				continue
 			} else {
 				routes = append(routes, ruleInfo{
@@ -1781,8 +1874,8 @@ func (n *nftablesManager) RefreshEgressRangesOnIngressGw(server string, ingressU
 				}
 			}
 			n.conn.InsertRule(rule)
-			if err := n.conn.Flush(); err != nil {
-				logger.Log(0, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
+			if err := n.flush(); err != nil {
+				ruleLog.Log(0, fmt.Sprintf("failed to add rule: %v, Err: %v ", ruleSpec, err.Error()))
 				continue
 			} else {
 				routes = append(routes, ruleInfo{
@@ -1830,6 +1923,84 @@ func (n *nftablesManager) SaveRules(server, tableName string, rules ruletable) {
 	case egressTable:
 		n.engressRules[server] = rules
 	}
+	n.persistRules()
+}
+
+// nftables.RestoreRules - restores the persisted rule tables, rebuilding each rule from its spec and inserting
+// it again into the chains recreated by CreateChains, rules that can't be inserted are dropped from the tables
+func (n *nftablesManager) RestoreRules() error {
+	return n.restoreRules(ruleStateFile())
+}
+
+// nftables.restoreRules - restores the rule tables persisted in file
+func (n *nftablesManager) restoreRules(file string) error {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	ingRules, egressRules, err := loadRuleState(file, n.restoredRule)
+	if err != nil {
+		return err
+	}
+	n.ingRules = ingRules
+	n.engressRules = egressRules
+	// the sets of the egress range rules, the rules using a set that failed are dropped below
+	if err := n.flush(); err != nil {
+		logger.Log(0, "failed to restore egress range sets: ", err.Error())
+	}
+	forgotten := 0
+	for _, tables := range []serverrulestable{n.ingRules, n.engressRules} {
+		for _, ruleTable := range tables {
+			summary, err := n.reconcile(ruleTable)
+			if err != nil {
+				return err
+			}
+			forgotten += summary.Forgotten
+		}
+	}
+	// index the restored ext client jumps as InsertIngressRoutingRules does, so removeJumpRules removes them
+	for _, ruleTable := range n.ingRules {
+		for _, cfg := range ruleTable {
+			for _, rules := range cfg.rulesMap {
+				for _, rule := range rules {
+					if rule.chain == iptableFWDChain && isNetmakerJumpKey(genRuleKey(rule.rule...)) {
+						nfJumpRules = append(nfJumpRules, rule)
+					}
+				}
+			}
+		}
+	}
+	if forgotten > 0 {
+		logger.Log(0, fmt.Sprintf("dropped %d persisted rules which could not be restored", forgotten))
+		n.persistRules()
+	}
+	return nil
+}
+
+// nftables.MissingRules - returns the recorded rules of a peer which are not present in nftables
+func (n *nftablesManager) MissingRules(server, ruletableName, peerKey string) []ruleInfo {
+	rulesTable := n.FetchRuleTable(server, ruletableName)
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	return missingRules(rulesTable[peerKey], func(isIpv4 bool, rule ruleInfo) bool {
+		_, err := n.getRule(rule.table, rule.chain, genRuleKey(rule.rule...))
+		return err == nil
+	})
+}
+
+// nftables.RuleState - returns a copy of the rule tables, taken under the lock
+func (n *nftablesManager) RuleState() savedRuleState {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	return savedRuleState{
+		Ingress: toSavedServerRules(n.ingRules),
+		Egress:  toSavedServerRules(n.engressRules),
+	}
+}
+
+// nftables.persistRules - writes the rule tables to disk, must be called with the lock held
+func (n *nftablesManager) persistRules() {
+	if err := saveRuleState(ruleStateFile(), n.ingRules, n.engressRules); err != nil {
+		logger.Log(0, "failed to persist firewall rules: ", err.Error())
+	}
 }
 
 // nftables.RemoveRoutingRules removes an nfatbles rules related to a peer
@@ -1843,9 +2014,11 @@ func (n *nftablesManager) RemoveRoutingRules(server, ruletableName, peerKey stri
 	}
 	for _, rules := range rulesTable[peerKey].rulesMap {
 		for _, rule := range rules {
-			if err := n.deleteRule(rule.table, rule.chain, genRuleKey(rule.rule...)); err != nil {
-				return fmt.Errorf("nftables: error while removing existing %s rules [%v] for %s: %v",
-					rule.table, rule.rule, peerKey, err)
+			if sharedNatRule(rulesTable, peerKey, rule) {
+				continue
+			}
+			if err := n.deleteRecordedRule(rule, peerKey); err != nil {
+				return err
 			}
 		}
 	}
@@ -1853,6 +2026,21 @@ func (n *nftablesManager) RemoveRoutingRules(server, ruletableName, peerKey stri
 	return nil
 }
 
+// nftables.deleteRecordedRule - deletes a rule recorded for a peer, a rule that is already gone is skipped
+// so the remaining rules of the peer are still cleaned up
+func (n *nftablesManager) deleteRecordedRule(rule ruleInfo, peerKey string) error {
+	err := n.deleteRuleInfo(rule)
+	if errors.Is(err, ErrRuleNotFound) {
+		logger.Log(2, "rule already removed: ", err.Error())
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("nftables: error while removing existing %s rules [%v] for %s: %v",
+			rule.table, rule.rule, peerKey, err)
+	}
+	return nil
+}
+
 // nftables.DeleteRoutingRule - removes an nftables rule pair from forwarding and nat chains
 func (n *nftablesManager) DeleteRoutingRule(server, ruletableName, srcPeerKey, dstPeerKey string) error {
 	rulesTable := n.FetchRuleTable(server, ruletableName)
@@ -1864,25 +2052,36 @@ func (n *nftablesManager) DeleteRoutingRule(server, ruletableName, srcPeerKey, d
 	}
 	if rules, ok := rulesTable[srcPeerKey].rulesMap[dstPeerKey]; ok {
 		for _, rule := range rules {
-			if err := n.deleteRule(rule.table, rule.chain, genRuleKey(rule.rule...)); err != nil {
-				return fmt.Errorf("nftables: error while removing existing %s rules [%v] for %s: %v",
-					rule.table, rule.rule, srcPeerKey, err)
+			if err := n.deleteRecordedRule(rule, srcPeerKey); err != nil {
+				return err
 			}
 		}
+		delete(rulesTable[srcPeerKey].rulesMap, dstPeerKey)
 	} else {
 		return errors.New("rules not found for: " + dstPeerKey)
 	}
 	return nil
 }
 
-// nftables.FlushAll - removes all the rules added by netmaker and deletes the netmaker chains
+// nftables.FlushAll - removes all the rules added by netmaker and deletes the netmaker chains,
+// the rules other tools added to the tables are kept
 func (n *nftablesManager) FlushAll() {
 	n.mux.Lock()
 	defer n.mux.Unlock()
-	n.conn.FlushTable(filterTable)
-	n.conn.FlushTable(natTable)
-	if err := n.conn.Flush(); err != nil {
-		logger.Log(0, "Error flushing tables: ", err.Error())
+	// accept before the jump rules are removed, a drop policy left in place would drop all the forwarded
+	// traffic of the host once netclient is stopped
+	n.conn.AddChain(nfForwardChain(nftables.ChainPolicyAccept))
+	if err := n.flush(); err != nil {
+		logger.Log(0, "failed to reset the forward chain policy: ", err.Error())
+	}
+	if err := n.deleteNetmakerRules(); err != nil {
+		logger.Log(0, "Error flushing netmaker rules: ", err.Error())
+	}
+	if err := n.deleteNetmakerChains(); err != nil {
+		logger.Log(0, "Error deleting netmaker chains: ", err.Error())
+	}
+	if err := n.conn.CloseLasting(); err != nil {
+		logger.Log(0, "failed to close nftables connection: ", err.Error())
 	}
 }
 
@@ -1927,7 +2126,7 @@ func (n *nftablesManager) getRule(tableName, chainName, ruleKey string) (*nftabl
 			return rules[idx], nil
 		}
 	}
-	return nil, errors.New("No such rule exists: " + ruleKey)
+	return nil, fmt.Errorf("%w: %s", ErrRuleNotFound, ruleKey)
 }
 
 func (n *nftablesManager) deleteChain(table, chain string) {
@@ -1937,7 +2136,7 @@ func (n *nftablesManager) deleteChain(table, chain string) {
 		return
 	}
 	n.conn.DelChain(chainObj)
-	if err := n.conn.Flush(); err != nil {
+	if err := n.flush(); err != nil {
 		logger.Log(0, fmt.Sprintf("failed to delete chain: %s", err.Error()))
 	}
 }
@@ -1950,7 +2149,7 @@ func (n *nftablesManager) deleteRule(tableName, chainName, ruleKey string) error
 	if err := n.conn.DelRule(rule); err != nil {
 		return err
 	}
-	return n.conn.Flush()
+	return n.flush()
 }
 
 func (n *nftablesManager) addJumpRules() {
@@ -1960,7 +2159,11 @@ func (n *nftablesManager) addJumpRules() {
 	for _, rule := range nfNatJumpRules {
 		n.conn.AddRule(rule.nfRule.(*nftables.Rule))
 	}
-	if err := n.conn.Flush(); err != nil {
+	if forwardDropPolicy() {
+		// flipped in the batch of the jump rules, the drop policy never applies without them
+		n.conn.AddChain(nfForwardChain(nftables.ChainPolicyDrop))
+	}
+	if err := n.flush(); err != nil {
 		logger.Log(0, fmt.Sprintf("failed to add jump rules, Err: %s", err.Error()))
 	}
 }
@@ -1974,6 +2177,31 @@ func (n *nftablesManager) removeJumpRules() {
 	}
 }
 
+// nftables.removeStaleForwardJumps - removes the jumps to the netmaker chain left in the forward chain by a previous run,
+// like the ext client jumps which nfJumpRules no longer holds once it is rebuilt, the ones still needed are restored by RestoreRules
+func (n *nftablesManager) removeStaleForwardJumps() {
+	rules, err := n.conn.GetRules(filterTable, &nftables.Chain{Name: iptableFWDChain})
+	if err != nil {
+		logger.Log(0, "failed to list forward rules: ", err.Error())
+		return
+	}
+	for _, rule := range rules {
+		if isNetmakerJumpKey(string(rule.UserData)) {
+			if err := n.conn.DelRule(rule); err != nil {
+				logger.Log(0, "failed to rm stale jump rule: ", string(rule.UserData), err.Error())
+			}
+		}
+	}
+	if err := n.flush(); err != nil {
+		logger.Log(0, "failed to remove stale jump rules: ", err.Error())
+	}
+}
+
+// isNetmakerJumpKey - reports whether a rule key is the one of a rule jumping to the netmaker chain
+func isNetmakerJumpKey(key string) bool {
+	return isNetmakerRuleKey([]byte(key)) && strings.HasSuffix(key, ":"+genRuleKey("-j", netmakerFilterChain))
+}
+
 func genRuleKey(rule ...string) string {
 	return strings.Join(rule, ":")
 }
@@ -0,0 +1,174 @@
+package router
+
+import (
+	"fmt"
+
+	"github.com/google/nftables"
+)
+
+// ReconcileSummary - rules changed while reconciling a rule table with the kernel
+type ReconcileSummary struct {
+	// Added - recorded rules missing from the kernel which were inserted again
+	Added int `json:"added"`
+	// Removed - netmaker rules in the kernel no rule table records, which were deleted
+	Removed int `json:"removed"`
+	// Forgotten - recorded rules which could not be inserted again and were dropped from the rule table
+	Forgotten int `json:"forgotten"`
+}
+
+// nftables.Reconcile - brings the kernel and the rule table back in line after the rules were changed outside of netclient,
+// e.g. by a flushed ruleset or a crash: recorded rules missing from the kernel are inserted again, or dropped from the
+// table if that fails, and netmaker rules no rule table records are deleted. It is safe to call periodically
+func (n *nftablesManager) Reconcile(server, ruleTableName string) (ReconcileSummary, error) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	var ruleTable ruletable
+	switch ruleTableName {
+	case ingressTable:
+		ruleTable = n.ingRules[server]
+	case egressTable:
+		ruleTable = n.engressRules[server]
+	default:
+		return ReconcileSummary{}, fmt.Errorf("unknown rule table %s", ruleTableName)
+	}
+	summary, err := n.reconcile(ruleTable)
+	if summary.Forgotten > 0 {
+		n.persistRules()
+	}
+	return summary, err
+}
+
+// nftables.reconcileTargets - returns the server and rule table name of every rule table
+func (n *nftablesManager) reconcileTargets() [][2]string {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	targets := [][2]string{}
+	for server := range n.ingRules {
+		targets = append(targets, [2]string{server, ingressTable})
+	}
+	for server := range n.engressRules {
+		targets = append(targets, [2]string{server, egressTable})
+	}
+	return targets
+}
+
+// nfLiveRuleKey - identifies a rule in the kernel by its table and the key it was added with
+type nfLiveRuleKey struct {
+	table, key string
+}
+
+// nftables.reconcile - reconciles the rule table with the kernel, must be called with the lock held
+func (n *nftablesManager) reconcile(ruleTable ruletable) (ReconcileSummary, error) {
+	summary := ReconcileSummary{}
+	live, err := n.liveNetmakerRules()
+	if err != nil {
+		return summary, fmt.Errorf("failed to list the rules in the kernel: %w", netlinkErr(err))
+	}
+	// recorded rules missing from the kernel, dropped from the table unless they are inserted again
+	type ruleRef struct {
+		peerKey, dstKey string
+		idx             int
+	}
+	missing := make(map[ruleRef]bool)
+	var batch nfRuleBatch
+	for peerKey, cfg := range ruleTable {
+		for dstKey, rules := range cfg.rulesMap {
+			for idx, rule := range rules {
+				if _, ok := live[nfLiveRuleKey{table: rule.table, key: genRuleKey(rule.rule...)}]; ok {
+					continue
+				}
+				ref := ruleRef{peerKey: peerKey, dstKey: dstKey, idx: idx}
+				missing[ref] = true
+				if nfRule, ok := rule.nfRule.(*nftables.Rule); !ok || nfRule == nil {
+					continue
+				}
+				batch.stage(rule, func(ruleInfo) {
+					delete(missing, ref)
+					summary.Added++
+				})
+			}
+		}
+	}
+	n.insertBatch(batch)
+	for peerKey, cfg := range ruleTable {
+		for dstKey, rules := range cfg.rulesMap {
+			kept := []ruleInfo{}
+			for idx, rule := range rules {
+				if missing[ruleRef{peerKey: peerKey, dstKey: dstKey, idx: idx}] {
+					summary.Forgotten++
+					continue
+				}
+				kept = append(kept, rule)
+			}
+			cfg.rulesMap[dstKey] = kept
+		}
+	}
+
+	recorded := n.recordedRuleKeys()
+	for key, rules := range live {
+		if recorded[key] {
+			continue
+		}
+		for _, rule := range rules {
+			if err := n.conn.DelRule(rule); err != nil {
+				return summary, err
+			}
+			summary.Removed++
+		}
+	}
+	if summary.Removed > 0 {
+		if err := n.flush(); err != nil {
+			return summary, fmt.Errorf("failed to delete unrecorded rules: %w", err)
+		}
+	}
+	return summary, nil
+}
+
+// nftables.liveNetmakerRules - returns the rules netmaker added to its tables in the kernel
+func (n *nftablesManager) liveNetmakerRules() (map[nfLiveRuleKey][]*nftables.Rule, error) {
+	chains, err := n.conn.ListChainsOfTableFamily(nftables.TableFamilyINet)
+	if err != nil {
+		return nil, err
+	}
+	live := make(map[nfLiveRuleKey][]*nftables.Rule)
+	for _, chain := range chains {
+		if chain.Table.Name != defaultIpTable && chain.Table.Name != defaultNatTable {
+			continue
+		}
+		rules, err := n.conn.GetRules(chain.Table, chain)
+		if err != nil {
+			return nil, err
+		}
+		for _, rule := range rules {
+			if !isNetmakerRuleKey(rule.UserData) {
+				continue
+			}
+			key := nfLiveRuleKey{table: chain.Table.Name, key: string(rule.UserData)}
+			live[key] = append(live[key], rule)
+		}
+	}
+	return live, nil
+}
+
+// nftables.recordedRuleKeys - returns the keys of the rules of all the rule tables and the jump rules
+func (n *nftablesManager) recordedRuleKeys() map[nfLiveRuleKey]bool {
+	recorded := make(map[nfLiveRuleKey]bool)
+	record := func(rules []ruleInfo) {
+		for _, rule := range rules {
+			recorded[nfLiveRuleKey{table: rule.table, key: genRuleKey(rule.rule...)}] = true
+		}
+	}
+	record(nfJumpRules)
+	record(nfFilterJumpRules)
+	record(nfNatJumpRules)
+	for _, tables := range []serverrulestable{n.ingRules, n.engressRules} {
+		for _, ruleTable := range tables {
+			for _, cfg := range ruleTable {
+				for _, rules := range cfg.rulesMap {
+					record(rules)
+				}
+			}
+		}
+	}
+	return recorded
+}
@@ -0,0 +1,68 @@
+package router
+
+import (
+	"testing"
+
+	"github.com/google/nftables"
+)
+
+func TestNftReconcile(t *testing.T) {
+	chains := [][2]string{
+		{defaultIpTable, iptableFWDChain},
+		{defaultIpTable, netmakerFilterChain},
+	}
+	present := []string{"-s", "10.0.0.2", "-d", "10.0.0.3", "-j", "ACCEPT"}
+	missing := []string{"-s", "10.0.0.2", "-d", "10.0.0.4", "-j", "ACCEPT"}
+	lost := []string{"-s", "10.0.0.2", "-d", "10.0.0.5", "-j", "ACCEPT"}
+	stale := []string{"-s", "10.0.0.9", "-d", "10.0.0.3", "-j", "ACCEPT"}
+	rules := []fakeNftRule{
+		{table: defaultIpTable, chain: netmakerFilterChain, handle: 1, userData: []byte(genRuleKey(present...))},
+		{table: defaultIpTable, chain: netmakerFilterChain, handle: 2, userData: []byte(genRuleKey(stale...))},
+		{table: defaultIpTable, chain: iptableFWDChain, handle: 3, userData: []byte{0, 4, 'f', 'o', 'o', 0}},
+	}
+	var delRules []uint64
+	var delChains []string
+	n := newTestNftManager(t, fakeFlushKernel(chains, rules, &delRules, &delChains))
+
+	filterRule := func(spec []string) ruleInfo {
+		return ruleInfo{
+			table: defaultIpTable,
+			chain: netmakerFilterChain,
+			rule:  spec,
+			nfRule: &nftables.Rule{
+				Table:    filterTable,
+				Chain:    &nftables.Chain{Name: netmakerFilterChain, Table: filterTable},
+				UserData: []byte(genRuleKey(spec...)),
+			},
+		}
+	}
+	// rules restored from disk without a kernel counterpart have no nftables rule to insert again
+	lostRule := ruleInfo{table: defaultIpTable, chain: netmakerFilterChain, rule: lost}
+	ruleTable := ruletable{
+		"ext": {isIpv4: true, rulesMap: map[string][]ruleInfo{
+			"ext":   {filterRule(present), lostRule},
+			"peer1": {filterRule(missing)},
+		}},
+	}
+	n.ingRules = serverrulestable{"server": ruleTable}
+	n.engressRules = serverrulestable{}
+
+	summary, err := n.reconcile(ruleTable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ReconcileSummary{Added: 1, Removed: 1, Forgotten: 1}
+	if summary != want {
+		t.Errorf("got %+v, want %+v", summary, want)
+	}
+	if len(delRules) != 1 || delRules[0] != 2 {
+		t.Errorf("only the unrecorded netmaker rule should be deleted, deleted %v", delRules)
+	}
+	extRules := ruleTable["ext"].rulesMap["ext"]
+	if len(extRules) != 1 || genRuleKey(extRules[0].rule...) != genRuleKey(present...) {
+		t.Errorf("the rule that could not be inserted again should be dropped from the table, got %+v", extRules)
+	}
+	if len(ruleTable["ext"].rulesMap["peer1"]) != 1 {
+		t.Error("the rule inserted again should stay recorded")
+	}
+}
@@ -0,0 +1,225 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/binaryutil"
+	"github.com/google/nftables/expr"
+	"golang.org/x/sys/unix"
+)
+
+// nfCtStateBits - conntrack state bits of the iptables --ctstate values
+var nfCtStateBits = map[string]uint32{
+	"NEW":         expr.CtStateBitNEW,
+	"ESTABLISHED": expr.CtStateBitESTABLISHED,
+	"RELATED":     expr.CtStateBitRELATED,
+	"INVALID":     expr.CtStateBitINVALID,
+}
+
+// nftables.restoredRule - rebuilds the live rule of a persisted rule from its spec, the rule of an egress
+// range set gets its set queued again, to be added with the next flush
+func (n *nftablesManager) restoredRule(isIpv4 bool, rule ruleInfo) (any, error) {
+	if rule.set == "" {
+		return nfRuleFromSpec(rule.table, rule.chain, rule.rule)
+	}
+	cidrs := []*net.IPNet{}
+	for i := 0; i < len(rule.rule)-1; i++ {
+		if rule.rule[i] != "-d" {
+			continue
+		}
+		for _, value := range strings.Split(rule.rule[i+1], ",") {
+			_, cidr, err := net.ParseCIDR(value)
+			if err != nil {
+				return nil, err
+			}
+			cidrs = append(cidrs, cidr)
+		}
+	}
+	if len(cidrs) == 0 {
+		return nil, errors.New("set rule has no ranges")
+	}
+	isIpv4 = cidrs[0].IP.To4() != nil
+	keyType := nftables.TypeIP6Addr
+	if isIpv4 {
+		keyType = nftables.TypeIPAddr
+	}
+	set := &nftables.Set{
+		Table:    filterTable,
+		Name:     rule.set,
+		KeyType:  keyType,
+		Interval: true,
+	}
+	n.stageEgressRangeSetRemoval(set.Name)
+	if err := n.conn.AddSet(set, nfIntervalElements(cidrs, isIpv4)); err != nil {
+		return nil, err
+	}
+	return nfEgressSetRule(set, rule.rule, isIpv4), nil
+}
+
+// nfRuleFromSpec - builds the nftables rule of an iptables style rule spec, keyed by the spec
+// so it is found again like the rule it was recorded for
+func nfRuleFromSpec(tableName, chainName string, spec []string) (*nftables.Rule, error) {
+	table := filterTable
+	if tableName == defaultNatTable {
+		table = natTable
+	}
+	var (
+		matches, statement []expr.Any
+		proto              byte
+		negate             bool
+		logPrefix          string
+	)
+	for i := 0; i < len(spec); i++ {
+		flag := spec[i]
+		if flag == "!" {
+			negate = true
+			continue
+		}
+		if i+1 >= len(spec) {
+			return nil, fmt.Errorf("%s has no value", flag)
+		}
+		value := spec[i+1]
+		i++
+		op := expr.CmpOpEq
+		if negate {
+			op = expr.CmpOpNeq
+		}
+		negate = false
+		switch flag {
+		case "-s", "-d":
+			exprs, family, err := nfAddrMatch(value, flag == "-s", op)
+			if err != nil {
+				return nil, err
+			}
+			if proto != 0 && proto != family {
+				return nil, errors.New("rule mixes ipv4 and ipv6 addresses")
+			}
+			proto = family
+			matches = append(matches, exprs...)
+		case "-i", "-o":
+			key := expr.MetaKeyIIFNAME
+			if flag == "-o" {
+				key = expr.MetaKeyOIFNAME
+			}
+			matches = append(matches,
+				&expr.Meta{Key: key, Register: 1},
+				&expr.Cmp{Op: op, Register: 1, Data: []byte(value + "\x00")},
+			)
+		case "--ctstate":
+			var bits uint32
+			for _, state := range strings.Split(value, ",") {
+				bit, ok := nfCtStateBits[state]
+				if !ok {
+					return nil, fmt.Errorf("unsupported conntrack state %s", state)
+				}
+				bits |= bit
+			}
+			// a negated state matches when none of the bits are set
+			stateOp := expr.CmpOpNeq
+			if op == expr.CmpOpNeq {
+				stateOp = expr.CmpOpEq
+			}
+			matches = append(matches,
+				&expr.Ct{Register: 1, Key: expr.CtKeySTATE},
+				&expr.Bitwise{
+					SourceRegister: 1,
+					DestRegister:   1,
+					Len:            4,
+					Mask:           binaryutil.NativeEndian.PutUint32(bits),
+					Xor:            []byte{0, 0, 0, 0},
+				},
+				&expr.Cmp{Op: stateOp, Register: 1, Data: []byte{0, 0, 0, 0}},
+			)
+		case "-m", "--comment":
+			// match modules are implied by their options, comments are not kept in the kernel rule
+		case "--log-prefix":
+			logPrefix = value
+		case "-j":
+			switch value {
+			case targetAccept:
+				statement = []expr.Any{&expr.Verdict{Kind: expr.VerdictAccept}}
+			case targetDrop:
+				statement = []expr.Any{&expr.Verdict{Kind: expr.VerdictDrop}}
+			case targetReturn:
+				statement = []expr.Any{&expr.Verdict{Kind: expr.VerdictReturn}}
+			case targetMasquerade:
+				statement = []expr.Any{&expr.Masq{}}
+			case targetLog:
+				statement = []expr.Any{&expr.Log{}}
+			default:
+				statement = []expr.Any{&expr.Verdict{Kind: expr.VerdictJump, Chain: value}}
+			}
+		default:
+			return nil, fmt.Errorf("unsupported option %s", flag)
+		}
+	}
+	if len(statement) == 0 {
+		return nil, errors.New("rule has no target")
+	}
+	if log, ok := statement[0].(*expr.Log); ok && logPrefix != "" {
+		log.Key = 1 << unix.NFTA_LOG_PREFIX
+		log.Data = []byte(logPrefix)
+	}
+	exprs := []expr.Any{}
+	if proto != 0 {
+		exprs = append(exprs,
+			&expr.Meta{Key: expr.MetaKeyNFPROTO, Register: 1},
+			&expr.Cmp{Op: expr.CmpOpEq, Register: 1, Data: []byte{proto}},
+		)
+	}
+	exprs = append(exprs, matches...)
+	exprs = append(exprs, &expr.Counter{})
+	exprs = append(exprs, statement...)
+	return &nftables.Rule{
+		Table:    table,
+		Chain:    &nftables.Chain{Name: chainName, Table: table},
+		UserData: []byte(genRuleKey(spec...)),
+		Exprs:    exprs,
+	}, nil
+}
+
+// nfAddrMatch - returns the expressions matching the source or destination address against an address or a network,
+// and the nf protocol of its family
+func nfAddrMatch(value string, src bool, op expr.CmpOp) ([]expr.Any, byte, error) {
+	ip, cidr := net.ParseIP(value), (*net.IPNet)(nil)
+	if ip == nil {
+		var err error
+		if ip, cidr, err = net.ParseCIDR(value); err != nil {
+			return nil, 0, fmt.Errorf("invalid address %s", value)
+		}
+		ip = cidr.IP
+	}
+	proto, offset, length, xor, addr := byte(unix.NFPROTO_IPV4), uint32(ipv4DestOffset), uint32(ipv4Len), zeroXor, []byte(ip.To4())
+	if src {
+		offset = ipv4SrcOffset
+	}
+	if ip.To4() == nil {
+		proto, offset, length, xor, addr = unix.NFPROTO_IPV6, ipv6DestOffset, ipv6Len, zeroXor6, ip.To16()
+		if src {
+			offset = ipv6SrcOffset
+		}
+	}
+	exprs := []expr.Any{
+		&expr.Payload{
+			DestRegister: 1,
+			Base:         expr.PayloadBaseNetworkHeader,
+			Offset:       offset,
+			Len:          length,
+		},
+	}
+	if cidr != nil {
+		exprs = append(exprs, &expr.Bitwise{
+			DestRegister:   1,
+			SourceRegister: 1,
+			Len:            length,
+			Mask:           cidr.Mask,
+			Xor:            xor,
+		})
+	}
+	exprs = append(exprs, &expr.Cmp{Op: op, Register: 1, Data: addr})
+	return exprs, proto, nil
+}
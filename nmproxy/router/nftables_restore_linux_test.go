@@ -0,0 +1,268 @@
+package router
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/binaryutil"
+	"github.com/google/nftables/expr"
+	"github.com/mdlayher/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// fakeRuleKernel - a kernel keeping the chains and rules added to it, deleting a chain deletes its rules
+type fakeRuleKernel struct {
+	mu     sync.Mutex
+	chains map[[2]string]bool
+	rules  []fakeNftRule
+	handle uint64
+}
+
+// fakeRuleKernel.keys - returns the user data of the rules in the chain, in the order of the chain
+func (k *fakeRuleKernel) keys(table, chain string) []string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	keys := []string{}
+	for _, rule := range k.rules {
+		if rule.table == table && rule.chain == chain {
+			keys = append(keys, string(rule.userData))
+		}
+	}
+	return keys
+}
+
+func (k *fakeRuleKernel) dial() nftables.ConnOption {
+	nftMsg := func(msgType int, req netlink.Message, attrs []netlink.Attribute) netlink.Message {
+		data, _ := netlink.MarshalAttributes(attrs)
+		return netlink.Message{
+			Header: netlink.Header{
+				Type:     netlink.HeaderType((unix.NFNL_SUBSYS_NFTABLES << 8) | msgType),
+				Sequence: req.Header.Sequence,
+				PID:      req.Header.PID,
+			},
+			Data: append([]byte{unix.NFPROTO_INET, 0, 0, 0}, data...),
+		}
+	}
+	attrs := func(m netlink.Message) map[uint16]string {
+		parsed := map[uint16]string{}
+		decoded, _ := netlink.UnmarshalAttributes(m.Data[4:])
+		for _, a := range decoded {
+			parsed[a.Type] = string(a.Data)
+		}
+		return parsed
+	}
+	trim := func(s string) string {
+		if len(s) > 0 && s[len(s)-1] == 0 {
+			return s[:len(s)-1]
+		}
+		return s
+	}
+	return nftables.WithTestDial(func(req []netlink.Message) ([]netlink.Message, error) {
+		k.mu.Lock()
+		defer k.mu.Unlock()
+		var replies []netlink.Message
+		for _, m := range req {
+			a := attrs(m)
+			switch int(m.Header.Type) & 0xff {
+			case unix.NFT_MSG_GETCHAIN:
+				for chain := range k.chains {
+					replies = append(replies, nftMsg(unix.NFT_MSG_NEWCHAIN, m, []netlink.Attribute{
+						{Type: unix.NFTA_CHAIN_TABLE, Data: []byte(chain[0] + "\x00")},
+						{Type: unix.NFTA_CHAIN_NAME, Data: []byte(chain[1] + "\x00")},
+					}))
+				}
+			case unix.NFT_MSG_GETRULE:
+				for _, rule := range k.rules {
+					if trim(a[unix.NFTA_RULE_TABLE]) != rule.table || trim(a[unix.NFTA_RULE_CHAIN]) != rule.chain {
+						continue
+					}
+					replies = append(replies, nftMsg(unix.NFT_MSG_NEWRULE, m, []netlink.Attribute{
+						{Type: unix.NFTA_RULE_TABLE, Data: []byte(rule.table + "\x00")},
+						{Type: unix.NFTA_RULE_CHAIN, Data: []byte(rule.chain + "\x00")},
+						{Type: unix.NFTA_RULE_HANDLE, Data: binaryutil.BigEndian.PutUint64(rule.handle)},
+						{Type: unix.NFTA_RULE_USERDATA, Data: rule.userData},
+					}))
+				}
+			case unix.NFT_MSG_GETSET:
+			case unix.NFT_MSG_NEWCHAIN:
+				k.chains[[2]string{trim(a[unix.NFTA_CHAIN_TABLE]), trim(a[unix.NFTA_CHAIN_NAME])}] = true
+				replies = append(replies, m)
+			case unix.NFT_MSG_DELCHAIN:
+				table, chain := trim(a[unix.NFTA_CHAIN_TABLE]), trim(a[unix.NFTA_CHAIN_NAME])
+				delete(k.chains, [2]string{table, chain})
+				kept := []fakeNftRule{}
+				for _, rule := range k.rules {
+					if rule.table != table || rule.chain != chain {
+						kept = append(kept, rule)
+					}
+				}
+				k.rules = kept
+				replies = append(replies, m)
+			case unix.NFT_MSG_NEWRULE:
+				k.handle++
+				rule := fakeNftRule{
+					table:    trim(a[unix.NFTA_RULE_TABLE]),
+					chain:    trim(a[unix.NFTA_RULE_CHAIN]),
+					handle:   k.handle,
+					userData: []byte(a[unix.NFTA_RULE_USERDATA]),
+				}
+				if m.Header.Flags&netlink.Append != 0 {
+					k.rules = append(k.rules, rule)
+				} else {
+					k.rules = append([]fakeNftRule{rule}, k.rules...)
+				}
+				replies = append(replies, m)
+			case unix.NFT_MSG_DELRULE:
+				handle := binaryutil.BigEndian.Uint64([]byte(a[unix.NFTA_RULE_HANDLE]))
+				kept := []fakeNftRule{}
+				for _, rule := range k.rules {
+					if rule.handle != handle {
+						kept = append(kept, rule)
+					}
+				}
+				k.rules = kept
+				replies = append(replies, m)
+			default:
+				replies = append(replies, m)
+			}
+		}
+		return replies, nil
+	})
+}
+
+func TestNftRestoreRulesAfterCreateChains(t *testing.T) {
+	defer func() {
+		nfJumpRules, nfFilterJumpRules, nfNatJumpRules = nil, nil, nil
+	}()
+	file := filepath.Join(t.TempDir(), "firewall_rules.json")
+	extRule := ruleInfo{
+		rule:  []string{"-s", "10.0.0.5", "!", "-d", "10.0.0.1", "-j", netmakerFilterChain},
+		table: defaultIpTable,
+		chain: iptableFWDChain,
+	}
+	peerRule := ruleInfo{
+		rule:  []string{"-s", "10.0.0.5", "-d", "10.0.0.6", "-j", targetAccept},
+		table: defaultIpTable,
+		chain: netmakerFilterChain,
+	}
+	returnRule := ruleInfo{
+		rule:  []string{"-s", "10.0.0.0/24", "-o", "eth0", "-m", "conntrack", "--ctstate", ctReturnStates, "-j", targetAccept},
+		table: defaultIpTable,
+		chain: iptableFWDChain,
+	}
+	masqRule := ruleInfo{
+		rule:  []string{"-s", "fd00::/64", "-o", "eth0", "-j", targetMasquerade},
+		table: defaultNatTable,
+		chain: netmakerNatChain,
+	}
+	setRule := ruleInfo{
+		rule:  []string{"-i", "nm-test", "-d", "192.168.1.0/24,192.168.2.0/24", "-j", netmakerFilterChain},
+		table: defaultIpTable,
+		chain: iptableFWDChain,
+		set:   egressSetName("egress", true),
+	}
+	ingRules := serverrulestable{"server": ruletable{
+		"ext": {isIpv4: true, rulesMap: map[string][]ruleInfo{"ext": {extRule}, "peer": {peerRule}}},
+	}}
+	egressRules := serverrulestable{"server": ruletable{
+		"egress": {isIpv4: true, rulesMap: map[string][]ruleInfo{"egress": {returnRule, masqRule, setRule}}},
+	}}
+	if err := saveRuleState(file, ingRules, egressRules); err != nil {
+		t.Fatal(err)
+	}
+
+	// the rules of the previous run are gone once the chains are recreated, along with the jump of an ext client
+	// removed while the daemon was down
+	staleJump := genRuleKey("-s", "10.0.0.9", "!", "-d", "10.0.0.1", "-j", netmakerFilterChain)
+	userRule := "user rule"
+	kernel := &fakeRuleKernel{
+		chains: map[[2]string]bool{{defaultIpTable, iptableFWDChain}: true},
+		rules: []fakeNftRule{
+			{table: defaultIpTable, chain: iptableFWDChain, handle: 1, userData: []byte(staleJump)},
+			{table: defaultIpTable, chain: iptableFWDChain, handle: 2, userData: []byte(userRule)},
+		},
+		handle: 2,
+	}
+	n := newTestNftManager(t, kernel.dial())
+	if err := n.CreateChains(); err != nil {
+		t.Fatal(err)
+	}
+	if fwd := kernel.keys(defaultIpTable, iptableFWDChain); fwd[0] != userRule {
+		t.Errorf("expected the stale jump to be removed with the chains, got %v", fwd)
+	}
+	if err := n.restoreRules(file); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, rule := range []ruleInfo{extRule, peerRule, returnRule, masqRule, setRule} {
+		found := false
+		for _, key := range kernel.keys(rule.table, rule.chain) {
+			found = found || key == genRuleKey(rule.rule...)
+		}
+		if !found {
+			t.Errorf("expected rule %v to be restored into %s %s, kernel has %v", rule.rule, rule.table, rule.chain, kernel.keys(rule.table, rule.chain))
+		}
+	}
+	fwd := kernel.keys(defaultIpTable, iptableFWDChain)
+	kept := false
+	for _, key := range fwd {
+		kept = kept || key == userRule
+		if key == staleJump {
+			t.Errorf("expected the stale jump to stay removed, got %v", fwd)
+		}
+	}
+	if !kept {
+		t.Errorf("expected the rules not added by netmaker to be kept, got %v", fwd)
+	}
+	indexed := false
+	for _, rule := range nfJumpRules {
+		indexed = indexed || genRuleKey(rule.rule...) == genRuleKey(extRule.rule...)
+	}
+	if !indexed {
+		t.Errorf("expected the restored ext client jump to be indexed in the jump rules")
+	}
+	if last := fwd[len(fwd)-1]; last == genRuleKey(extRule.rule...) {
+		t.Errorf("expected the restored rules above the jump rules, got %v", fwd)
+	}
+	restored := n.engressRules["server"]["egress"].rulesMap["egress"]
+	if len(restored) != 3 || len(n.ingRules["server"]["ext"].rulesMap["peer"]) != 1 {
+		t.Fatalf("expected the restored rules to stay recorded, got %+v %+v", n.ingRules, n.engressRules)
+	}
+	if set := restored[2].nfRule.(*nftables.Rule); set.Exprs[len(set.Exprs)-1].(*expr.Verdict).Chain != netmakerFilterChain {
+		t.Errorf("expected the set rule to jump to the filter chain, got %v", set.Exprs)
+	}
+}
+
+func TestNfRuleFromSpec(t *testing.T) {
+	rule, err := nfRuleFromSpec(defaultNatTable, netmakerNatChain, []string{"-s", "10.0.0.0/24", "-o", "eth0", "!", "-d", "192.168.1.0/24", "-j", targetMasquerade})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule.Table != natTable || rule.Chain.Name != netmakerNatChain || string(rule.UserData) != genRuleKey("-s", "10.0.0.0/24", "-o", "eth0", "!", "-d", "192.168.1.0/24", "-j", targetMasquerade) {
+		t.Errorf("unexpected rule %+v", rule)
+	}
+	var negated bool
+	for _, e := range rule.Exprs {
+		if cmp, ok := e.(*expr.Cmp); ok && cmp.Op == expr.CmpOpNeq {
+			negated = string(cmp.Data) == string([]byte{192, 168, 1, 0})
+		}
+	}
+	if !negated {
+		t.Errorf("expected a negated destination match, got %v", rule.Exprs)
+	}
+	if _, ok := rule.Exprs[len(rule.Exprs)-1].(*expr.Masq); !ok {
+		t.Errorf("expected a masquerade statement, got %v", rule.Exprs)
+	}
+	for _, spec := range [][]string{
+		{"-s", "10.0.0.5"},
+		{"-s", "bogus", "-j", targetAccept},
+		{"-s", "10.0.0.5", "-d", "fd00::1", "-j", targetAccept},
+		{"-p", "tcp", "-j", targetAccept},
+	} {
+		if _, err := nfRuleFromSpec(defaultIpTable, netmakerFilterChain, spec); err == nil {
+			t.Errorf("expected an error for %v", spec)
+		}
+	}
+}
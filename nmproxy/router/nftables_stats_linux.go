@@ -0,0 +1,73 @@
+package router
+
+import (
+	"fmt"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+)
+
+// RuleStats - packet and byte counters of a firewall rule
+type RuleStats struct {
+	Packets uint64 `json:"packets"`
+	Bytes   uint64 `json:"bytes"`
+}
+
+// nftables.GetRuleStats - returns the counters of the rules stored for the peer, indexed by rule key,
+// rules missing from the kernel are left out and an error is returned if one of their chains no longer exists
+func (n *nftablesManager) GetRuleStats(server, ruleTableName, peerKey string) (map[string]RuleStats, error) {
+	ruleTable := n.FetchRuleTable(server, ruleTableName)
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	rulesCfg, ok := ruleTable[peerKey]
+	if !ok {
+		return nil, fmt.Errorf("no rules found for peer %s", peerKey)
+	}
+	// the stored rules grouped by the table and chain they are in, to list each chain once
+	type tableChain struct{ table, chain string }
+	wanted := make(map[tableChain]map[string]struct{})
+	for _, rules := range rulesCfg.rulesMap {
+		for _, rule := range rules {
+			nfRule, ok := rule.nfRule.(*nftables.Rule)
+			if !ok || nfRule.Table == nil || nfRule.Chain == nil {
+				continue
+			}
+			key := tableChain{table: nfRule.Table.Name, chain: nfRule.Chain.Name}
+			if wanted[key] == nil {
+				wanted[key] = make(map[string]struct{})
+			}
+			wanted[key][string(nfRule.UserData)] = struct{}{}
+		}
+	}
+	stats := make(map[string]RuleStats)
+	for key, ruleKeys := range wanted {
+		if _, err := n.getChain(key.table, key.chain); err != nil {
+			return nil, fmt.Errorf("chain %s of table %s no longer exists: %w", key.chain, key.table, err)
+		}
+		rules, err := n.conn.GetRules(
+			&nftables.Table{Name: key.table, Family: nftables.TableFamilyINet},
+			&nftables.Chain{Name: key.chain})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list rules of chain %s of table %s: %w", key.chain, key.table, netlinkErr(err))
+		}
+		for _, rule := range rules {
+			if _, ok := ruleKeys[string(rule.UserData)]; !ok {
+				continue
+			}
+			stats[string(rule.UserData)] = ruleCounters(rule)
+		}
+	}
+	return stats, nil
+}
+
+// ruleCounters - sums the counter expressions of a rule
+func ruleCounters(rule *nftables.Rule) RuleStats {
+	var stats RuleStats
+	for _, e := range rule.Exprs {
+		if counter, ok := e.(*expr.Counter); ok {
+			stats.Packets += counter.Packets
+			stats.Bytes += counter.Bytes
+		}
+	}
+	return stats
+}
@@ -0,0 +1,144 @@
+package router
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/nftables"
+	"github.com/google/nftables/expr"
+	"github.com/mdlayher/netlink"
+	"golang.org/x/sys/unix"
+)
+
+// countedRule - a rule listed by fakeCounterKernel with its counters
+type countedRule struct {
+	key            string
+	packets, bytes uint64
+}
+
+// fakeCounterKernel - lists the given chains and, per chain name, rules carrying a counter
+func fakeCounterKernel(t *testing.T, chains map[string]string, rules map[string][]countedRule) nftables.ConnOption {
+	reply := func(msgType int, req netlink.Message, attrs []netlink.Attribute) netlink.Message {
+		data, err := netlink.MarshalAttributes(attrs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return netlink.Message{
+			Header: netlink.Header{
+				Type:     netlink.HeaderType((unix.NFNL_SUBSYS_NFTABLES << 8) | msgType),
+				Sequence: req.Header.Sequence,
+				PID:      req.Header.PID,
+			},
+			Data: append([]byte{unix.NFPROTO_INET, 0, 0, 0}, data...),
+		}
+	}
+	return nftables.WithTestDial(func(req []netlink.Message) ([]netlink.Message, error) {
+		var replies []netlink.Message
+		for _, m := range req {
+			switch int(m.Header.Type) & 0xff {
+			case unix.NFT_MSG_GETCHAIN:
+				for chain, table := range chains {
+					replies = append(replies, reply(unix.NFT_MSG_NEWCHAIN, m, []netlink.Attribute{
+						{Type: unix.NFTA_CHAIN_TABLE, Data: []byte(table + "\x00")},
+						{Type: unix.NFTA_CHAIN_NAME, Data: []byte(chain + "\x00")},
+					}))
+				}
+			case unix.NFT_MSG_GETRULE:
+				ad, err := netlink.NewAttributeDecoder(m.Data[4:])
+				if err != nil {
+					t.Fatal(err)
+				}
+				var table, chain string
+				for ad.Next() {
+					switch ad.Type() {
+					case unix.NFTA_RULE_TABLE:
+						table = ad.String()
+					case unix.NFTA_RULE_CHAIN:
+						chain = ad.String()
+					}
+				}
+				for _, rule := range rules[chain] {
+					counter, err := expr.Marshal(unix.NFPROTO_INET, &expr.Counter{Packets: rule.packets, Bytes: rule.bytes})
+					if err != nil {
+						t.Fatal(err)
+					}
+					exprs, err := netlink.MarshalAttributes([]netlink.Attribute{
+						{Type: unix.NLA_F_NESTED | unix.NFTA_LIST_ELEM, Data: counter},
+					})
+					if err != nil {
+						t.Fatal(err)
+					}
+					replies = append(replies, reply(unix.NFT_MSG_NEWRULE, m, []netlink.Attribute{
+						{Type: unix.NFTA_RULE_TABLE, Data: []byte(table + "\x00")},
+						{Type: unix.NFTA_RULE_CHAIN, Data: []byte(chain + "\x00")},
+						{Type: unix.NLA_F_NESTED | unix.NFTA_RULE_EXPRESSIONS, Data: exprs},
+						{Type: unix.NFTA_RULE_USERDATA, Data: []byte(rule.key)},
+					}))
+				}
+			default:
+				replies = append(replies, m)
+			}
+		}
+		return replies, nil
+	})
+}
+
+func TestGetRuleStats(t *testing.T) {
+	fwdKey := genRuleKey("-s", "10.0.0.5/32", "-j", netmakerFilterChain)
+	acceptKey := genRuleKey("-s", "10.0.0.5/32", "-d", "10.0.0.2/32", "-j", "ACCEPT")
+	masqKey := genRuleKey("-s", "10.0.0.5/32", "-o", "netmaker", "-j", "MASQUERADE")
+	stored := func(table *nftables.Table, chain, key string) ruleInfo {
+		return ruleInfo{
+			nfRule: &nftables.Rule{Table: table, Chain: &nftables.Chain{Name: chain, Table: table}, UserData: []byte(key)},
+			rule:   strings.Split(key, ":"),
+			chain:  chain,
+		}
+	}
+	newManager := func(opt nftables.ConnOption) *nftablesManager {
+		n := newTestNftManager(t, opt)
+		n.ingRules = serverrulestable{"server": ruletable{"ext": rulesCfg{
+			isIpv4: true,
+			rulesMap: map[string][]ruleInfo{
+				"ext": {
+					stored(filterTable, iptableFWDChain, fwdKey),
+					stored(natTable, netmakerNatChain, masqKey),
+				},
+				"peer": {stored(filterTable, netmakerFilterChain, acceptKey)},
+			},
+		}}}
+		return n
+	}
+
+	n := newManager(fakeCounterKernel(t,
+		map[string]string{iptableFWDChain: defaultIpTable, netmakerFilterChain: defaultIpTable, netmakerNatChain: defaultNatTable},
+		map[string][]countedRule{
+			iptableFWDChain:     {{key: fwdKey, packets: 10, bytes: 1000}, {key: "unrelated", packets: 1, bytes: 1}},
+			netmakerFilterChain: {{key: acceptKey, packets: 4, bytes: 400}},
+			netmakerNatChain:    {{key: masqKey, packets: 2, bytes: 120}},
+		}))
+	stats, err := n.GetRuleStats("server", ingressTable, "ext")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]RuleStats{
+		fwdKey:    {Packets: 10, Bytes: 1000},
+		acceptKey: {Packets: 4, Bytes: 400},
+		masqKey:   {Packets: 2, Bytes: 120},
+	}
+	if len(stats) != len(want) {
+		t.Fatalf("expected %d rules, got %v", len(want), stats)
+	}
+	for key, s := range want {
+		if stats[key] != s {
+			t.Errorf("rule %s: expected %+v, got %+v", key, s, stats[key])
+		}
+	}
+
+	n = newManager(fakeCounterKernel(t, map[string]string{iptableFWDChain: defaultIpTable}, nil))
+	if _, err := n.GetRuleStats("server", ingressTable, "ext"); err == nil || !strings.Contains(err.Error(), "no longer exists") {
+		t.Errorf("expected a missing chain error, got %v", err)
+	}
+	if _, err := n.GetRuleStats("server", ingressTable, "unknown"); err == nil {
+		t.Error("expected an error for a peer without rules")
+	}
+}
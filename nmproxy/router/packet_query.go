@@ -0,0 +1,230 @@
+package router
+
+import (
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netclient/ncutils"
+)
+
+// verdicts of a packet query
+const (
+	// PacketAccept - packet is accepted by the netmaker rules or not filtered by them
+	PacketAccept = "accept"
+	// PacketDrop - packet is dropped by the netmaker rules
+	PacketDrop = "drop"
+)
+
+// names of the chains and targets the packet query evaluates
+const (
+	filterChain      = "netmakerfilter"
+	forwardChain     = "FORWARD"
+	targetAccept     = "ACCEPT"
+	targetDrop       = "DROP"
+	targetMasquerade = "MASQUERADE"
+	targetReturn     = "RETURN"
+)
+
+// PacketQuery - packet to evaluate against the netmaker rules
+type PacketQuery struct {
+	Src      string `json:"src"`
+	Dst      string `json:"dst"`
+	InIface  string `json:"iif,omitempty"`
+	OutIface string `json:"oif,omitempty"`
+	Proto    string `json:"proto,omitempty"`
+	Port     int    `json:"port,omitempty"`
+	// Established - the packet belongs to an established connection, otherwise it starts a new one
+	Established bool `json:"established,omitempty"`
+}
+
+// PacketVerdict - result of a packet query and the rules which decided it
+type PacketVerdict struct {
+	Verdict    string `json:"verdict"`
+	Rule       string `json:"rule,omitempty"`
+	Chain      string `json:"chain,omitempty"`
+	Reason     string `json:"reason"`
+	Masquerade bool   `json:"masquerade"`
+	MasqRule   string `json:"masq_rule,omitempty"`
+}
+
+// QueryPacket - reports whether the packet would be accepted, dropped or masqueraded by the current rules
+func QueryPacket(q PacketQuery) (PacketVerdict, error) {
+	if fwCrtl == nil {
+		return PacketVerdict{}, errors.New("firewall is not initialized on this host")
+	}
+	if net.ParseIP(q.Src) == nil || net.ParseIP(q.Dst) == nil {
+		return PacketVerdict{}, errors.New("src and dst must be valid ip addresses")
+	}
+	if q.InIface == "" {
+		// forwarded traffic of peers arrives on the netmaker interface
+		q.InIface = ncutils.GetInterfaceName()
+	}
+	rules := []ruleInfo{}
+	for _, server := range config.GetServers() {
+		for _, tableName := range []string{ingressTable, egressTable} {
+			for _, cfg := range fwCrtl.FetchRuleTable(server, tableName) {
+				for _, peerRules := range cfg.rulesMap {
+					rules = append(rules, peerRules...)
+				}
+			}
+		}
+	}
+	return evaluatePacket(rules, q, ncutils.GetInterfaceName()), nil
+}
+
+// evaluatePacket - walks the rules the way the netmaker chains are laid out: traffic from the interface
+// jumps to the filter chain, where accept rules are checked before the drop at the end of the chain
+func evaluatePacket(rules []ruleInfo, q PacketQuery, ifaceName string) PacketVerdict {
+	verdict := PacketVerdict{Verdict: PacketAccept}
+	// forward rules accepting or dropping directly, e.g. for outbound only egress, precede the jump rules
+	for _, target := range []string{targetAccept, targetDrop} {
+		for _, rule := range rules {
+			if rule.chain == forwardChain && ruleTarget(rule.rule) == target && matchRule(rule.rule, q) {
+				verdict.Rule = strings.Join(rule.rule, " ")
+				verdict.Chain = forwardChain
+				if target == targetDrop {
+					verdict.Verdict = PacketDrop
+					verdict.Reason = "dropped by rule"
+					return verdict
+				}
+				verdict.Reason = "accepted by rule"
+				return verdict
+			}
+		}
+	}
+	jumpRule := []string{"-i", ifaceName, "-j", filterChain}
+	entered := matchRule(jumpRule, q)
+	if entered {
+		verdict.Rule = strings.Join(jumpRule, " ")
+	}
+	for _, rule := range rules {
+		if !entered && rule.chain == forwardChain && ruleTarget(rule.rule) == filterChain && matchRule(rule.rule, q) {
+			entered = true
+			verdict.Rule = strings.Join(rule.rule, " ")
+		}
+	}
+	if !entered {
+		verdict.Reason = "packet is not filtered by netmaker rules"
+	} else {
+		verdict.Verdict = PacketDrop
+		verdict.Chain = filterChain
+		verdict.Reason = "no accept rule matched, dropped at the end of " + filterChain
+		verdict.Rule = "-j " + targetDrop
+		// drop rules, e.g. isolating ext. clients, sit on top of the accept rules
+		for _, target := range []string{targetDrop, targetAccept} {
+			matched := false
+			for _, rule := range rules {
+				if rule.chain == filterChain && ruleTarget(rule.rule) == target && matchRule(rule.rule, q) {
+					verdict.Rule = strings.Join(rule.rule, " ")
+					if target == targetAccept {
+						verdict.Verdict = PacketAccept
+						verdict.Reason = "accepted by rule"
+					} else {
+						verdict.Reason = "dropped by rule"
+					}
+					matched = true
+					break
+				}
+			}
+			if matched {
+				break
+			}
+		}
+	}
+	if verdict.Verdict == PacketAccept {
+		for _, rule := range rules {
+			// return rules precede the masquerade rules, excluding destinations from masquerading
+			if ruleTarget(rule.rule) == targetReturn && matchRule(rule.rule, q) {
+				return verdict
+			}
+		}
+		for _, rule := range rules {
+			if ruleTarget(rule.rule) == targetMasquerade && matchRule(rule.rule, q) {
+				verdict.Masquerade = true
+				verdict.MasqRule = strings.Join(rule.rule, " ")
+				break
+			}
+		}
+	}
+	return verdict
+}
+
+// ruleTarget - returns the jump target of a rule spec
+func ruleTarget(spec []string) string {
+	for i := 0; i < len(spec)-1; i++ {
+		if spec[i] == "-j" {
+			return spec[i+1]
+		}
+	}
+	return ""
+}
+
+// matchRule - checks if the packet matches all the conditions of an iptables style rule spec,
+// conditions on fields not set in the query don't match
+func matchRule(spec []string, q PacketQuery) bool {
+	negate := false
+	for i := 0; i < len(spec); i++ {
+		flag := spec[i]
+		if flag == "!" {
+			negate = true
+			continue
+		}
+		if i+1 >= len(spec) {
+			return false
+		}
+		value := spec[i+1]
+		var matched bool
+		switch flag {
+		case "-s":
+			matched = matchAddr(value, q.Src)
+		case "-d":
+			matched = matchAddr(value, q.Dst)
+		case "-i":
+			matched = q.InIface != "" && q.InIface == value
+		case "-o":
+			matched = q.OutIface != "" && q.OutIface == value
+		case "-p":
+			matched = q.Proto != "" && strings.EqualFold(q.Proto, value)
+		case "--dport":
+			matched = q.Port != 0 && strconv.Itoa(q.Port) == value
+		case "--ctstate":
+			matched = q.Established
+		case "-j", "-m", "--comment":
+			i++
+			negate = false
+			continue
+		default:
+			// unknown conditions can't be evaluated
+			return false
+		}
+		if matched == negate {
+			return false
+		}
+		negate = false
+		i++
+	}
+	return true
+}
+
+// matchAddr - checks if the ip is within any of the comma separated addresses or ranges
+func matchAddr(addrs, ip string) bool {
+	parsedIP := net.ParseIP(ip)
+	if parsedIP == nil {
+		return false
+	}
+	for _, addr := range strings.Split(addrs, ",") {
+		if _, cidr, err := net.ParseCIDR(addr); err == nil {
+			if cidr.Contains(parsedIP) {
+				return true
+			}
+			continue
+		}
+		if other := net.ParseIP(addr); other != nil && other.Equal(parsedIP) {
+			return true
+		}
+	}
+	return false
+}
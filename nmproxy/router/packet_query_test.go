@@ -0,0 +1,48 @@
+package router
+
+import "testing"
+
+func TestEvaluatePacket(t *testing.T) {
+	rules := []ruleInfo{
+		{table: "filter", chain: "netmakerfilter", rule: []string{"-s", "10.0.0.2", "-d", "192.168.10.0/24,192.168.20.0/24", "-j", "ACCEPT"}},
+		{table: "filter", chain: "netmakerfilter", rule: []string{"-s", "10.10.0.5", "-d", "10.0.0.3", "-j", "ACCEPT"}},
+		{table: "filter", chain: "netmakerfilter", rule: []string{"!", "-p", "tcp", "-s", "10.0.0.7", "-d", "10.0.0.8", "-j", "ACCEPT"}},
+		{table: "nat", chain: "netmakernat", rule: []string{"-s", "10.0.0.0/24", "-o", "eth0", "-j", "MASQUERADE"}},
+	}
+	cases := []struct {
+		name       string
+		q          PacketQuery
+		verdict    string
+		rule       string
+		masquerade bool
+	}{
+		{"egress accept", PacketQuery{Src: "10.0.0.2", Dst: "192.168.20.4"}, PacketAccept, "-s 10.0.0.2 -d 192.168.10.0/24,192.168.20.0/24 -j ACCEPT", false},
+		{"egress accept masqueraded", PacketQuery{Src: "10.0.0.2", Dst: "192.168.10.4", OutIface: "eth0"}, PacketAccept, "-s 10.0.0.2 -d 192.168.10.0/24,192.168.20.0/24 -j ACCEPT", true},
+		{"ext client accept", PacketQuery{Src: "10.10.0.5", Dst: "10.0.0.3"}, PacketAccept, "-s 10.10.0.5 -d 10.0.0.3 -j ACCEPT", false},
+		{"ext client to other peer dropped", PacketQuery{Src: "10.10.0.5", Dst: "10.0.0.4"}, PacketDrop, "-j DROP", false},
+		{"negated proto accept", PacketQuery{Src: "10.0.0.7", Dst: "10.0.0.8", Proto: "udp"}, PacketAccept, "! -p tcp -s 10.0.0.7 -d 10.0.0.8 -j ACCEPT", false},
+		{"negated proto dropped", PacketQuery{Src: "10.0.0.7", Dst: "10.0.0.8", Proto: "tcp"}, PacketDrop, "-j DROP", false},
+		{"not filtered", PacketQuery{Src: "10.0.0.2", Dst: "192.168.10.4", InIface: "eth1"}, PacketAccept, "", false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.q.InIface == "" {
+				tc.q.InIface = "netmaker"
+			}
+			v := evaluatePacket(rules, tc.q, "netmaker")
+			if v.Verdict != tc.verdict || v.Rule != tc.rule || v.Masquerade != tc.masquerade {
+				t.Errorf("got %+v, want verdict %s rule %q masquerade %v", v, tc.verdict, tc.rule, tc.masquerade)
+			}
+		})
+	}
+}
+
+func TestEvaluatePacketForwardJump(t *testing.T) {
+	rules := []ruleInfo{
+		{table: "filter", chain: "FORWARD", rule: []string{"-i", "netmaker", "-d", "192.168.10.0/24", "-j", "netmakerfilter"}},
+	}
+	v := evaluatePacket(rules, PacketQuery{Src: "10.0.0.2", Dst: "192.168.10.4", InIface: "netmaker"}, "nm-other")
+	if v.Verdict != PacketDrop {
+		t.Errorf("expected packet entering through the forward rule to be dropped, got %+v", v)
+	}
+}
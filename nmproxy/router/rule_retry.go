@@ -0,0 +1,145 @@
+package router
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gravitl/netmaker/logger"
+)
+
+const (
+	// RuleRetryInterval - how often the queued retries of failed firewall operations are checked
+	RuleRetryInterval = ruleRetryMin
+	// ruleRetryMin - delay before the first retry of a failed firewall operation
+	ruleRetryMin = 5 * time.Second
+	// ruleRetryMax - cap of the backoff between retries of a failed firewall operation
+	ruleRetryMax = 5 * time.Minute
+)
+
+// errRulesNotAdded - returned when some of the rules of a gateway or ext. client failed to be added
+var errRulesNotAdded = errors.New("firewall rules were not added")
+
+// errGatewayRemoved - returned by a retry when the latest update no longer has the gateway or ext. client
+var errGatewayRemoved = errors.New("gateway is not in the latest update")
+
+// rulesNotAdded - returns errRulesNotAdded with the number of rules that failed, nil if none did
+func rulesNotAdded(failed int) error {
+	if failed == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %d failed", errRulesNotAdded, failed)
+}
+
+// ruleRetry - a firewall operation that failed to add all of its rules, re-attempted in the background
+type ruleRetry struct {
+	server   string
+	table    string
+	key      string
+	apply    func() error
+	attempts int
+	delay    time.Duration
+	next     time.Time
+}
+
+// ruleRetries - queued retries by server, rule table and peer key
+var ruleRetries = struct {
+	mutex sync.Mutex
+	queue map[string]*ruleRetry
+}{
+	queue: make(map[string]*ruleRetry),
+}
+
+func ruleRetryKey(server, table, key string) string {
+	return server + "/" + table + "/" + key
+}
+
+// queueRuleRetry - queues apply to be re-run for the peer's rules, a retry already queued for the peer
+// keeps its backoff and runs the latest apply
+func queueRuleRetry(server, table, key string, apply func() error, now time.Time) {
+	ruleRetries.mutex.Lock()
+	defer ruleRetries.mutex.Unlock()
+	id := ruleRetryKey(server, table, key)
+	if retry, ok := ruleRetries.queue[id]; ok {
+		retry.apply = apply
+		return
+	}
+	logger.Log(0, "queueing retry of firewall rules for: ", key)
+	ruleRetries.queue[id] = &ruleRetry{
+		server: server,
+		table:  table,
+		key:    key,
+		apply:  apply,
+		delay:  ruleRetryMin,
+		next:   now.Add(ruleRetryMin),
+	}
+}
+
+// forgetRuleRetry - drops the queued retry of the peer's rules, the peer's rules are removed
+func forgetRuleRetry(server, table, key string) {
+	ruleRetries.mutex.Lock()
+	defer ruleRetries.mutex.Unlock()
+	delete(ruleRetries.queue, ruleRetryKey(server, table, key))
+}
+
+// forgetRuleRetries - drops the queued retries of a rule table of the server
+func forgetRuleRetries(server, table string) {
+	ruleRetries.mutex.Lock()
+	defer ruleRetries.mutex.Unlock()
+	for id, retry := range ruleRetries.queue {
+		if retry.server == server && retry.table == table {
+			delete(ruleRetries.queue, id)
+		}
+	}
+}
+
+// queueIfRulesNotAdded - queues a retry when err reports rules that failed to be added
+func queueIfRulesNotAdded(err error, server, table, key string, apply func() error) {
+	if errors.Is(err, errRulesNotAdded) {
+		queueRuleRetry(server, table, key, apply, time.Now())
+	}
+}
+
+// retryFailedRules - re-runs the queued operations that are due, the rules that landed are removed first
+// so the operation starts from a clean slate, operations that fail again back off up to ruleRetryMax
+// and operations failing for any other reason are dropped
+func retryFailedRules(now time.Time) {
+	ruleRetries.mutex.Lock()
+	defer ruleRetries.mutex.Unlock()
+	for id, retry := range ruleRetries.queue {
+		if now.Before(retry.next) {
+			continue
+		}
+		retry.attempts++
+		if err := fwCrtl.RemoveRoutingRules(retry.server, retry.table, retry.key); err != nil {
+			logger.Log(2, "no rules to remove before retry: ", err.Error())
+		}
+		err := retry.apply()
+		if err == nil {
+			logger.Log(0, fmt.Sprintf("firewall rules for %s added after %d retries", retry.key, retry.attempts))
+			delete(ruleRetries.queue, id)
+			continue
+		}
+		if !errors.Is(err, errRulesNotAdded) {
+			logger.Log(0, "giving up retrying firewall rules for ", retry.key, ": ", err.Error())
+			delete(ruleRetries.queue, id)
+			continue
+		}
+		ruleLog.Log(0, fmt.Sprintf("retry %d of firewall rules for %s failed, Err: %v", retry.attempts, retry.key, err.Error()))
+		retry.delay *= 2
+		if retry.delay > ruleRetryMax {
+			retry.delay = ruleRetryMax
+		}
+		retry.next = now.Add(retry.delay)
+	}
+}
+
+// RetryFailedRules - re-runs the queued firewall operations that are due, called from the proxy manager loop
+// so the retries don't race with the firewall updates
+func RetryFailedRules(now time.Time) {
+	if fwCrtl == nil {
+		return
+	}
+	retryFailedRules(now)
+}
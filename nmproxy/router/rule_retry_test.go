@@ -0,0 +1,135 @@
+package router
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gravitl/netmaker/models"
+)
+
+// flakyFirewall - ingress rule table whose inserts fail to add a rule until failures runs out
+type flakyFirewall struct {
+	firewallController
+	rules    ruletable
+	failures int
+	removed  int
+	ranges   []string
+}
+
+func (f *flakyFirewall) FetchRuleTable(server, tableName string) ruletable {
+	return f.rules
+}
+
+func (f *flakyFirewall) RefreshEgressRangesOnIngressGw(server string, ingressUpdate models.IngressInfo) error {
+	return nil
+}
+
+func (f *flakyFirewall) RemoveRoutingRules(server, tableName, peerKey string) error {
+	delete(f.rules, peerKey)
+	f.removed++
+	return nil
+}
+
+func (f *flakyFirewall) InsertIngressRoutingRules(server string, extInfo models.ExtClientInfo, egressRanges []string) error {
+	f.rules[extInfo.ExtPeerKey] = rulesCfg{rulesMap: map[string][]ruleInfo{}}
+	f.ranges = egressRanges
+	if f.failures > 0 {
+		f.failures--
+		return rulesNotAdded(1)
+	}
+	f.rules[extInfo.ExtPeerKey].rulesMap[extInfo.ExtPeerKey] = []ruleInfo{{rule: []string{"-j", "ACCEPT"}}}
+	return nil
+}
+
+func TestRetryFailedRules(t *testing.T) {
+	fake := &flakyFirewall{rules: ruletable{}, failures: 3}
+	prev := fwCrtl
+	fwCrtl = fake
+	defer func() {
+		fwCrtl = prev
+		forgetRuleRetries("server", ingressTable)
+	}()
+	info := models.ExtClientInfo{ExtPeerKey: "ext"}
+	apply := func() error { return fake.InsertIngressRoutingRules("server", info, nil) }
+	queueIfRulesNotAdded(apply(), "server", ingressTable, info.ExtPeerKey, apply)
+	// a failure of the same ext client from a later update is not queued twice
+	queueIfRulesNotAdded(apply(), "server", ingressTable, info.ExtPeerKey, apply)
+	if len(ruleRetries.queue) != 1 {
+		t.Fatalf("expected a single queued retry, got %d", len(ruleRetries.queue))
+	}
+	now := time.Now()
+
+	retryFailedRules(now)
+	if fake.removed != 0 {
+		t.Fatal("expected no retry before the backoff elapsed")
+	}
+	// first retry fails again and backs off, the next one lands
+	retryFailedRules(now.Add(ruleRetryMin))
+	if len(ruleRetries.queue) != 1 || fake.removed != 1 {
+		t.Fatalf("expected the failed retry to stay queued, queued %d removed %d", len(ruleRetries.queue), fake.removed)
+	}
+	retryFailedRules(now.Add(ruleRetryMin * 2))
+	if fake.removed != 1 {
+		t.Fatal("expected the retry to back off after failing again")
+	}
+	retryFailedRules(now.Add(ruleRetryMin * 3))
+	if len(ruleRetries.queue) != 0 {
+		t.Fatalf("expected the retry to be dequeued once the rules landed, got %d", len(ruleRetries.queue))
+	}
+	if len(fake.rules["ext"].rulesMap["ext"]) != 1 || fake.removed != 2 {
+		t.Errorf("expected the rules to land after retrying, got %+v", fake.rules)
+	}
+}
+
+func TestForgetRuleRetries(t *testing.T) {
+	apply := func() error { return nil }
+	queueRuleRetry("server", ingressTable, "ext", apply, time.Now())
+	queueRuleRetry("server", egressTable, "egress", apply, time.Now())
+	forgetRuleRetries("server", ingressTable)
+	if _, ok := ruleRetries.queue[ruleRetryKey("server", ingressTable, "ext")]; ok {
+		t.Error("expected the ingress retry to be dropped")
+	}
+	forgetRuleRetry("server", egressTable, "egress")
+	if len(ruleRetries.queue) != 0 {
+		t.Errorf("expected no queued retries, got %d", len(ruleRetries.queue))
+	}
+}
+
+func TestRetryUsesLatestUpdate(t *testing.T) {
+	fake := &flakyFirewall{rules: ruletable{}, failures: 1}
+	prev := fwCrtl
+	fwCrtl = fake
+	defer func() {
+		fwCrtl = prev
+		forgetGatewayUpdates("server", ingressTable)
+		forgetRuleRetries("server", ingressTable)
+		pruneExtClients("server", nil)
+	}()
+	ext := models.ExtClientInfo{ExtPeerKey: "ext", ExtPeerAddr: net.IPNet{IP: net.ParseIP("10.0.0.5"), Mask: net.CIDRMask(32, 32)}}
+	update := func(ranges ...string) models.IngressInfo {
+		return models.IngressInfo{ExtPeers: map[string]models.ExtClientInfo{"ext": ext}, EgressRanges: ranges}
+	}
+	SetIngressRoutes("server", update("10.10.0.0/16"))
+	if len(ruleRetries.queue) != 1 {
+		t.Fatalf("expected the failed ext client to be queued, got %d", len(ruleRetries.queue))
+	}
+	// a later update changes the egress ranges before the retry runs
+	storeIngressUpdate("server", update("10.20.0.0/16"))
+	retryFailedRules(time.Now().Add(ruleRetryMin))
+	if len(ruleRetries.queue) != 0 || !reflect.DeepEqual(fake.ranges, []string{"10.20.0.0/16"}) {
+		t.Fatalf("expected the retry to add the rules of the latest update, got %v queued %d", fake.ranges, len(ruleRetries.queue))
+	}
+
+	// a retry of an ext client the latest update removed is dropped
+	fake.failures = 1
+	delete(fake.rules, "ext")
+	SetIngressRoutes("server", update("10.10.0.0/16"))
+	storeIngressUpdate("server", models.IngressInfo{})
+	removed := fake.removed
+	retryFailedRules(time.Now().Add(ruleRetryMin))
+	if len(ruleRetries.queue) != 0 || len(fake.rules["ext"].rulesMap["ext"]) != 0 || fake.removed != removed+1 {
+		t.Fatalf("expected the retry of the removed ext client to be dropped, queued %d rules %+v", len(ruleRetries.queue), fake.rules)
+	}
+}
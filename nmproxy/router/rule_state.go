@@ -0,0 +1,136 @@
+package router
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netclient/ncutils"
+	"github.com/gravitl/netmaker/logger"
+)
+
+// savedRule - serializable form of ruleInfo
+type savedRule struct {
+	Table         string   `json:"table"`
+	Chain         string   `json:"chain"`
+	Rule          []string `json:"rule"`
+	EgressExtRule bool     `json:"egress_ext_rule,omitempty"`
+	Set           string   `json:"set,omitempty"`
+}
+
+// savedRulesCfg - serializable form of rulesCfg
+type savedRulesCfg struct {
+	IsIpv4   bool                   `json:"is_ipv4"`
+	RulesMap map[string][]savedRule `json:"rules_map"`
+}
+
+// savedServerRules - serializable form of serverrulestable
+type savedServerRules map[string]map[string]savedRulesCfg
+
+// savedRuleState - the ingress and egress rule tables as persisted on disk
+type savedRuleState struct {
+	Ingress savedServerRules `json:"ingress"`
+	Egress  savedServerRules `json:"egress"`
+}
+
+// rebuildRuleFunc - returns the live rule for a restored ruleInfo, an error drops the rule
+type rebuildRuleFunc func(isIpv4 bool, rule ruleInfo) (any, error)
+
+// ruleStateFile - path of the file holding the persisted rule tables
+func ruleStateFile() string {
+	return config.GetNetclientPath() + "firewall_rules.json"
+}
+
+func toSavedServerRules(tables serverrulestable) savedServerRules {
+	saved := make(savedServerRules)
+	for server, table := range tables {
+		saved[server] = make(map[string]savedRulesCfg)
+		for key, cfg := range table {
+			savedCfg := savedRulesCfg{
+				IsIpv4:   cfg.isIpv4,
+				RulesMap: make(map[string][]savedRule),
+			}
+			for peer, rules := range cfg.rulesMap {
+				for _, rule := range rules {
+					savedCfg.RulesMap[peer] = append(savedCfg.RulesMap[peer], savedRule{
+						Table:         rule.table,
+						Chain:         rule.chain,
+						Rule:          rule.rule,
+						EgressExtRule: rule.egressExtRule,
+						Set:           rule.set,
+					})
+				}
+			}
+			saved[server][key] = savedCfg
+		}
+	}
+	return saved
+}
+
+func fromSavedServerRules(saved savedServerRules, rebuild rebuildRuleFunc) serverrulestable {
+	tables := make(serverrulestable)
+	for server, savedTable := range saved {
+		tables[server] = make(ruletable)
+		for key, savedCfg := range savedTable {
+			cfg := rulesCfg{
+				isIpv4:   savedCfg.IsIpv4,
+				rulesMap: make(map[string][]ruleInfo),
+			}
+			for peer, rules := range savedCfg.RulesMap {
+				for _, r := range rules {
+					rule := ruleInfo{
+						rule:          r.Rule,
+						table:         r.Table,
+						chain:         r.Chain,
+						egressExtRule: r.EgressExtRule,
+						set:           r.Set,
+					}
+					nfRule, err := rebuild(savedCfg.IsIpv4, rule)
+					if err != nil {
+						logger.Log(1, "dropping persisted rule", r.Table, r.Chain, strings.Join(r.Rule, " "), err.Error())
+						continue
+					}
+					rule.nfRule = nfRule
+					cfg.rulesMap[peer] = append(cfg.rulesMap[peer], rule)
+				}
+			}
+			tables[server][key] = cfg
+		}
+	}
+	return tables
+}
+
+// saveRuleState - writes the ingress and egress rule tables to file
+func saveRuleState(file string, ingRules, egressRules serverrulestable) error {
+	data, err := json.Marshal(savedRuleState{
+		Ingress: toSavedServerRules(ingRules),
+		Egress:  toSavedServerRules(egressRules),
+	})
+	if err != nil {
+		return err
+	}
+	return ncutils.WriteFileAtomic(file, 0600, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// loadRuleState - reads the ingress and egress rule tables from file,
+// rebuilding the live rule of every entry
+func loadRuleState(file string, rebuild rebuildRuleFunc) (serverrulestable, serverrulestable, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return make(serverrulestable), make(serverrulestable), nil
+		}
+		return nil, nil, err
+	}
+	var state savedRuleState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, nil, err
+	}
+	return fromSavedServerRules(state.Ingress, rebuild), fromSavedServerRules(state.Egress, rebuild), nil
+}
@@ -0,0 +1,64 @@
+package router
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRuleStateRoundTrip(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "firewall_rules.json")
+	kept := ruleInfo{
+		rule:  []string{"-i", "netmaker", "-d", "10.0.0.0/24", "-j", netmakerFilterChain},
+		table: defaultIpTable,
+		chain: iptableFWDChain,
+	}
+	extRule := ruleInfo{
+		rule:          []string{"-s", "10.0.0.5", "-d", "192.168.1.0/24", "-j", "ACCEPT"},
+		table:         defaultIpTable,
+		chain:         netmakerFilterChain,
+		egressExtRule: true,
+	}
+	gone := ruleInfo{
+		rule:  []string{"-s", "10.0.0.0/24", "-o", "eth0", "-j", "MASQUERADE"},
+		table: defaultNatTable,
+		chain: netmakerNatChain,
+	}
+	ingRules := serverrulestable{
+		"server": ruletable{
+			"ext": rulesCfg{isIpv4: true, rulesMap: map[string][]ruleInfo{"ext": {extRule}}},
+		},
+	}
+	egressRules := serverrulestable{
+		"server": ruletable{
+			"egress": rulesCfg{isIpv4: false, rulesMap: map[string][]ruleInfo{"egress": {kept, gone}}},
+		},
+	}
+	if err := saveRuleState(file, ingRules, egressRules); err != nil {
+		t.Fatal(err)
+	}
+	rebuild := func(isIpv4 bool, rule ruleInfo) (any, error) {
+		if rule.table == defaultNatTable {
+			return nil, errors.New("rule not found")
+		}
+		return genRuleKey(rule.rule...), nil
+	}
+	gotIng, gotEgress, err := loadRuleState(file, rebuild)
+	if err != nil {
+		t.Fatal(err)
+	}
+	extRule.nfRule = genRuleKey(extRule.rule...)
+	if !reflect.DeepEqual(gotIng["server"]["ext"].rulesMap["ext"], []ruleInfo{extRule}) || !gotIng["server"]["ext"].isIpv4 {
+		t.Fatalf("unexpected ingress rules: %+v", gotIng)
+	}
+	kept.nfRule = genRuleKey(kept.rule...)
+	if !reflect.DeepEqual(gotEgress["server"]["egress"].rulesMap["egress"], []ruleInfo{kept}) || gotEgress["server"]["egress"].isIpv4 {
+		t.Fatalf("unexpected egress rules, missing rule should be dropped: %+v", gotEgress)
+	}
+	// no persisted state yields empty tables
+	gotIng, gotEgress, err = loadRuleState(filepath.Join(t.TempDir(), "missing.json"), rebuild)
+	if err != nil || len(gotIng) != 0 || len(gotEgress) != 0 {
+		t.Fatalf("expected empty tables, got %v %v %v", gotIng, gotEgress, err)
+	}
+}
@@ -0,0 +1,266 @@
+package router
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netclient/ncutils"
+)
+
+// SupportBundle - firewall state of a host, with peer keys, server names and addresses redacted
+type SupportBundle struct {
+	Version   string         `json:"version"`
+	CreatedAt time.Time      `json:"created_at"`
+	Backend   string         `json:"backend"`
+	Interface string         `json:"interface"`
+	Rules     savedRuleState `json:"rules"`
+	// Ruleset - live capture of the netmaker firewall tables, empty if it could not be captured
+	Ruleset      string `json:"ruleset,omitempty"`
+	RulesetError string `json:"ruleset_error,omitempty"`
+}
+
+// RuleAnalysis - rule tables loaded from a support bundle, never applied to the host firewall
+type RuleAnalysis struct {
+	Bundle      SupportBundle
+	ingRules    serverrulestable
+	egressRules serverrulestable
+}
+
+// ExportSupportBundle - writes the rule tables of the running firewall controller and a live capture
+// of the ruleset to w
+func ExportSupportBundle(w io.Writer) error {
+	if fwCrtl == nil {
+		return errors.New("firewall is not initialised")
+	}
+	bundle := newSupportBundle(fwCrtl.RuleState())
+	var err error
+	bundle.Ruleset, err = captureRuleset()
+	if err != nil {
+		bundle.RulesetError = err.Error()
+	}
+	return writeSupportBundle(w, bundle)
+}
+
+// newSupportBundle - returns a bundle holding the rule tables
+func newSupportBundle(rules savedRuleState) SupportBundle {
+	return SupportBundle{
+		Version:   config.Version,
+		CreatedAt: time.Now().UTC(),
+		Backend:   firewallBackend(),
+		Interface: ncutils.GetInterfaceName(),
+		Rules:     rules,
+	}
+}
+
+// writeSupportBundle - redacts the bundle and writes it to w
+func writeSupportBundle(w io.Writer, bundle SupportBundle) error {
+	redactBundle(&bundle)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(bundle)
+}
+
+// redactBundle - replaces server names and peer keys with stable placeholders and addresses with placeholder
+// addresses, in the rule tables and the ruleset
+func redactBundle(bundle *SupportBundle) {
+	servers := map[string]string{}
+	peers := map[string]string{}
+	addrs := &addrRedactor{placeholders: map[string]string{}}
+	for _, tables := range []savedServerRules{bundle.Rules.Ingress, bundle.Rules.Egress} {
+		for server, table := range tables {
+			servers[server] = ""
+			for key, cfg := range table {
+				peers[key] = ""
+				for peer, rules := range cfg.RulesMap {
+					peers[peer] = ""
+					for _, rule := range rules {
+						for _, arg := range rule.Rule {
+							addrs.collect(arg)
+						}
+					}
+				}
+			}
+		}
+	}
+	assignPlaceholders(servers, "server")
+	assignPlaceholders(peers, "peer")
+	replacements := []string{}
+	for name, placeholder := range servers {
+		replacements = append(replacements, name, placeholder)
+	}
+	for key, placeholder := range peers {
+		replacements = append(replacements, key, placeholder)
+	}
+	bundle.Rules.Ingress = redactServerRules(bundle.Rules.Ingress, servers, peers)
+	bundle.Rules.Egress = redactServerRules(bundle.Rules.Egress, servers, peers)
+	if len(replacements) > 0 {
+		bundle.Ruleset = strings.NewReplacer(replacements...).Replace(bundle.Ruleset)
+	}
+	addrs.collect(bundle.Ruleset)
+	addrs.assign()
+	for _, tables := range []savedServerRules{bundle.Rules.Ingress, bundle.Rules.Egress} {
+		for _, table := range tables {
+			for _, cfg := range table {
+				for _, rules := range cfg.RulesMap {
+					for i := range rules {
+						// the rule args may be shared with the live rule tables
+						redacted := make([]string, len(rules[i].Rule))
+						for j, arg := range rules[i].Rule {
+							redacted[j] = addrs.redact(arg)
+						}
+						rules[i].Rule = redacted
+					}
+				}
+			}
+		}
+	}
+	bundle.Ruleset = addrs.redact(bundle.Ruleset)
+}
+
+// addrPattern - ipv4 and ipv6 addresses, with an optional prefix length, the matches are verified with net.ParseIP
+var addrPattern = regexp.MustCompile(`(?:\d{1,3}\.){3}\d{1,3}|[0-9a-fA-F]{0,4}(?::[0-9a-fA-F]{0,4}){2,7}`)
+
+// addrRedactor - replaces addresses with placeholders from the benchmarking (198.18.0.0/15) and documentation
+// (2001:db8::/32) ranges, the same address always gets the same placeholder and prefix lengths are kept,
+// so the redacted rules can still be analysed
+type addrRedactor struct {
+	placeholders map[string]string
+}
+
+// addrRedactor.collect - records the addresses found in s
+func (r *addrRedactor) collect(s string) {
+	for _, match := range addrPattern.FindAllString(s, -1) {
+		if redactableAddr(match) {
+			r.placeholders[match] = ""
+		}
+	}
+}
+
+// addrRedactor.assign - assigns the placeholders, in sorted order so the result is stable
+func (r *addrRedactor) assign() {
+	addrs := make([]string, 0, len(r.placeholders))
+	for addr := range r.placeholders {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	var n4, n6 uint32
+	for _, addr := range addrs {
+		if net.ParseIP(addr).To4() != nil {
+			n4++
+			ip := make(net.IP, 4)
+			binary.BigEndian.PutUint32(ip, (198<<24|18<<16)+n4)
+			r.placeholders[addr] = ip.String()
+			continue
+		}
+		n6++
+		ip := net.ParseIP("2001:db8::")
+		binary.BigEndian.PutUint32(ip[12:], n6)
+		r.placeholders[addr] = ip.String()
+	}
+}
+
+// addrRedactor.redact - replaces the addresses collected in s with their placeholders
+func (r *addrRedactor) redact(s string) string {
+	return addrPattern.ReplaceAllStringFunc(s, func(match string) string {
+		if placeholder, ok := r.placeholders[match]; ok {
+			return placeholder
+		}
+		return match
+	})
+}
+
+// redactableAddr - checks if the match is an address which identifies the host or its peers,
+// unspecified and loopback addresses are kept
+func redactableAddr(match string) bool {
+	ip := net.ParseIP(match)
+	return ip != nil && !ip.IsUnspecified() && !ip.IsLoopback()
+}
+
+// assignPlaceholders - names every entry of the set <prefix>-<n>, in sorted order so the result is stable
+func assignPlaceholders(set map[string]string, prefix string) {
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		set[name] = fmt.Sprintf("%s-%d", prefix, i+1)
+	}
+}
+
+func redactServerRules(tables savedServerRules, servers, peers map[string]string) savedServerRules {
+	if tables == nil {
+		return nil
+	}
+	redacted := make(savedServerRules)
+	for server, table := range tables {
+		redactedTable := make(map[string]savedRulesCfg)
+		for key, cfg := range table {
+			rulesMap := make(map[string][]savedRule)
+			for peer, rules := range cfg.RulesMap {
+				rulesMap[peers[peer]] = rules
+			}
+			redactedTable[peers[key]] = savedRulesCfg{IsIpv4: cfg.IsIpv4, RulesMap: rulesMap}
+		}
+		redacted[servers[server]] = redactedTable
+	}
+	return redacted
+}
+
+// ImportSupportBundle - loads the rule tables of a support bundle for analysis, nothing is applied to the firewall
+func ImportSupportBundle(r io.Reader) (*RuleAnalysis, error) {
+	var bundle SupportBundle
+	if err := json.NewDecoder(r).Decode(&bundle); err != nil {
+		return nil, fmt.Errorf("invalid support bundle %w", err)
+	}
+	keepRule := func(isIpv4 bool, rule ruleInfo) (any, error) {
+		return nil, nil
+	}
+	return &RuleAnalysis{
+		Bundle:      bundle,
+		ingRules:    fromSavedServerRules(bundle.Rules.Ingress, keepRule),
+		egressRules: fromSavedServerRules(bundle.Rules.Egress, keepRule),
+	}, nil
+}
+
+// rules - returns all the rules of the analysed rule tables
+func (a *RuleAnalysis) rules() []ruleInfo {
+	rules := []ruleInfo{}
+	for _, tables := range []serverrulestable{a.ingRules, a.egressRules} {
+		for _, table := range tables {
+			for _, cfg := range table {
+				for _, peerRules := range cfg.rulesMap {
+					rules = append(rules, peerRules...)
+				}
+			}
+		}
+	}
+	return rules
+}
+
+// RuleSpecs - returns the analysed rules as "<table> <chain> <rule spec>" lines, sorted
+func (a *RuleAnalysis) RuleSpecs() []string {
+	specs := []string{}
+	for _, rule := range a.rules() {
+		specs = append(specs, fmt.Sprintf("%s %s %s", rule.table, rule.chain, strings.Join(rule.rule, " ")))
+	}
+	sort.Strings(specs)
+	return specs
+}
+
+// Query - evaluates a packet against the analysed rules, as QueryPacket does on a live host
+func (a *RuleAnalysis) Query(q PacketQuery) PacketVerdict {
+	if q.InIface == "" {
+		q.InIface = a.Bundle.Interface
+	}
+	return evaluatePacket(a.rules(), q, a.Bundle.Interface)
+}
@@ -0,0 +1,75 @@
+package router
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// stateFirewall - firewall controller holding fixed rule tables
+type stateFirewall struct {
+	firewallController
+	state savedRuleState
+}
+
+func (f *stateFirewall) RuleState() savedRuleState {
+	return f.state
+}
+
+func TestSupportBundleRoundTrip(t *testing.T) {
+	const (
+		server  = "netmaker.example.com"
+		extPeer = "bXlleHRjbGllbnRrZXk="
+		peer    = "bXlwZWVya2V5"
+	)
+	ingress := serverrulestable{server: ruletable{extPeer: rulesCfg{
+		isIpv4: true,
+		rulesMap: map[string][]ruleInfo{
+			peer:    {{table: "filter", chain: "netmakerfilter", rule: []string{"-s", "10.10.0.5", "-d", "10.0.0.3", "-j", "ACCEPT"}}},
+			extPeer: {{table: "nat", chain: "netmakernat", rule: []string{"-s", "10.10.0.5", "-o", "netmaker", "-j", "MASQUERADE"}}},
+		},
+	}}}
+	prev := fwCrtl
+	fwCrtl = &stateFirewall{state: savedRuleState{Ingress: toSavedServerRules(ingress), Egress: savedServerRules{}}}
+	defer func() { fwCrtl = prev }()
+	bundle := newSupportBundle(fwCrtl.RuleState())
+	bundle.Interface = "netmaker"
+	bundle.Ruleset = "table inet filter { comment \"" + server + " " + peer + "\"; ip saddr 10.10.0.0/16 ip daddr 127.0.0.1 }"
+	var buf bytes.Buffer
+	if err := writeSupportBundle(&buf, bundle); err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{server, extPeer, peer, "10.10.0.5", "10.0.0.3", "10.10.0.0"} {
+		if strings.Contains(buf.String(), secret) {
+			t.Errorf("bundle contains unredacted %s", secret)
+		}
+	}
+	if !strings.Contains(buf.String(), "198.18.0.2/16") || !strings.Contains(buf.String(), "127.0.0.1") {
+		t.Errorf("expected addresses to be replaced keeping prefixes and loopback, got %s", buf.String())
+	}
+	// the live rule tables are left untouched by the redaction
+	if got := ingress[server][extPeer].rulesMap[peer][0].rule[1]; got != "10.10.0.5" {
+		t.Errorf("expected live rules to keep their addresses, got %s", got)
+	}
+
+	analysis, err := ImportSupportBundle(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// addresses are assigned placeholders in sorted order, 10.0.0.3 first
+	want := []string{
+		"filter netmakerfilter -s 198.18.0.3 -d 198.18.0.1 -j ACCEPT",
+		"nat netmakernat -s 198.18.0.3 -o netmaker -j MASQUERADE",
+	}
+	if got := analysis.RuleSpecs(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected rules %v, got %v", want, got)
+	}
+	if _, ok := analysis.ingRules["server-1"]["peer-1"]; !ok {
+		t.Errorf("expected redacted server and ext client placeholders, got %v", analysis.ingRules)
+	}
+	verdict := analysis.Query(PacketQuery{Src: "198.18.0.3", Dst: "198.18.0.1", OutIface: "netmaker"})
+	if verdict.Verdict != PacketAccept || !verdict.Masquerade {
+		t.Errorf("expected accepted and masqueraded packet, got %+v", verdict)
+	}
+}
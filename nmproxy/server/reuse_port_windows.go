@@ -0,0 +1,17 @@
+package server
+
+import (
+	"syscall"
+)
+
+// reusePort - lets a second listener bind the port of the proxy while the first one is open
+func reusePort(network, address string, c syscall.RawConn) error {
+	var sockErr error
+	err := c.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptInt(syscall.Handle(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
@@ -3,11 +3,16 @@ package server
 import (
 	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"net"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	nc_config "github.com/gravitl/netclient/config"
+	"github.com/gravitl/netclient/ncutils"
 	"github.com/gravitl/netclient/nmproxy/config"
 	"github.com/gravitl/netclient/nmproxy/models"
 	"github.com/gravitl/netclient/nmproxy/packet"
@@ -19,6 +24,8 @@ import (
 var (
 	// NmProxyServer - proxy server for global access
 	NmProxyServer = &ProxyServer{}
+	// packetLog - collapses the repeated per packet failures of the proxy server
+	packetLog = ncutils.NewLogLimiter(time.Minute)
 )
 
 // Config - struct for proxy server config
@@ -31,6 +38,9 @@ type Config struct {
 type ProxyServer struct {
 	Config Config
 	Server *net.UDPConn
+	mutex  sync.RWMutex
+	// rebinding - a rebind after a failed write is in progress
+	rebinding atomic.Bool
 }
 
 // ProxyServer.Close - closes the proxy server
@@ -61,7 +71,7 @@ func (p *ProxyServer) Close() {
 		}
 	}
 	// close server connection
-	NmProxyServer.Server.Close()
+	NmProxyServer.GetConn().Close()
 }
 
 // Proxy.Listen - begins listening for packets
@@ -75,9 +85,14 @@ func (p *ProxyServer) Listen(ctx context.Context) {
 	}()
 	for {
 		// Read Packet
-		n, source, err := p.Server.ReadFromUDP(buffer)
+		conn := p.GetConn()
+		n, source, err := conn.ReadFromUDP(buffer)
 		if err != nil {
-			logger.Log(3, "failed to read from server: ", err.Error())
+			if p.GetConn() != conn {
+				// server was rebound, continue on the new connection
+				continue
+			}
+			packetLog.Log(3, "failed to read from server: ", err.Error())
 			return
 		}
 		if source == nil {
@@ -139,9 +154,10 @@ func handleMsgs(buffer []byte, n int, source string) {
 				}
 				sourceUdp, err := net.ResolveUDPAddr("udp", source)
 				if err == nil {
-					_, err = NmProxyServer.Server.WriteToUDP(buffer[:n], sourceUdp)
+					_, err = NmProxyServer.GetConn().WriteToUDP(buffer[:n], sourceUdp)
 					if err != nil {
-						logger.Log(0, "Failed to send metric packet to remote: ", err.Error())
+						packetLog.Log(0, "Failed to send metric packet to remote: ", err.Error())
+						NmProxyServer.CheckWriteErr(err)
 					}
 				}
 
@@ -211,9 +227,10 @@ func relayPacket(buffer []byte, source string, n int, srcPeerKeyHash, dstPeerKey
 			logger.Log(3, fmt.Sprintf("--------> Relaying PKT [ Source: %s ], [ SourceKeyHash: %s ], [ DstIP: %s ], [ DstHashKey: %s ] \n",
 				source, srcPeerKeyHash, remotePeer.Endpoint.String(), dstPeerKeyHash))
 		}
-		_, err := NmProxyServer.Server.WriteToUDP(buffer[:n], remotePeer.Endpoint)
+		_, err := NmProxyServer.GetConn().WriteToUDP(buffer[:n], remotePeer.Endpoint)
 		if err != nil {
-			logger.Log(1, "Failed to relay to remote: ", err.Error())
+			packetLog.Log(1, "Failed to relay to remote: ", err.Error())
+			NmProxyServer.CheckWriteErr(err)
 		}
 		return
 	}
@@ -234,10 +251,13 @@ func proxyIncomingPacket(buffer []byte, source string, n int, srcPeerKeyHash, ds
 				peerInfo.LocalConn.RemoteAddr(), peerInfo.LocalConn.LocalAddr(),
 				source, srcPeerKeyHash, dstPeerKeyHash, source))
 		}
-		_, err = peerInfo.LocalConn.Write(buffer[:n])
-		if err != nil {
-			logger.Log(1, "Failed to proxy to Wg local interface: ", err.Error())
-			//continue
+		if peerInfo.Deliver != nil {
+			// written to the interface by the peer's proxy
+			if !peerInfo.Deliver(buffer[:n]) {
+				packetLog.Log(1, "dropping packet from peer, its proxy is closed or busy: ", peerInfo.PeerKey)
+			}
+		} else if _, err = packet.WritePacket(peerInfo.LocalConn, buffer[:n]); err != nil {
+			packetLog.Log(1, "Failed to proxy to Wg local interface: ", err.Error())
 		}
 
 		go func(n int, peerKey string) {
@@ -265,16 +285,66 @@ func (p *ProxyServer) CreateProxyServer(port, bodySize int, addr string) (err er
 	p.Config.Port = port
 	p.Config.BodySize = bodySize
 	p.setDefaults()
-	p.Server, err = net.ListenUDP("udp", &net.UDPAddr{
-		Port: p.Config.Port,
-		IP:   net.ParseIP("0.0.0.0"),
-	})
+	p.Server, err = listenUDP(p.Config.Port)
 	return
 }
 
+// ProxyServer.Rebind - replaces the proxy listener with a fresh one on all addresses, used when the address
+// the proxy was reached on is lost, e.g. when the host roams between networks; the new listener is bound
+// alongside the old one, which is only closed once replaced and is kept if the bind fails
+func (p *ProxyServer) Rebind() error {
+	conn, err := listenUDP(p.Config.Port)
+	if err != nil {
+		return err
+	}
+	p.mutex.Lock()
+	old := p.Server
+	p.Server = conn
+	p.mutex.Unlock()
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+// ProxyServer.CheckWriteErr - rebinds the proxy when a write failed as the address it was sent from
+// is no longer available, one rebind runs at a time
+func (p *ProxyServer) CheckWriteErr(err error) {
+	if !errors.Is(err, syscall.EADDRNOTAVAIL) || !p.rebinding.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer p.rebinding.Store(false)
+		logger.Log(0, "proxy address is not available, rebinding")
+		if err := p.Rebind(); err != nil {
+			logger.Log(0, "failed to rebind proxy: ", err.Error())
+			return
+		}
+		config.GetCfg().SetServerConn(p.GetConn())
+	}()
+}
+
+// ProxyServer.GetConn - returns the current proxy listener, which is replaced when the proxy is rebound
+func (p *ProxyServer) GetConn() *net.UDPConn {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return p.Server
+}
+
+// listenUDP - listens on the port on all addresses, with the port reusable so the proxy can be rebound
+// while the current listener is still open
+var listenUDP = func(port int) (*net.UDPConn, error) {
+	lc := net.ListenConfig{Control: reusePort}
+	conn, err := lc.ListenPacket(context.Background(), "udp", fmt.Sprintf("0.0.0.0:%d", port))
+	if err != nil {
+		return nil, err
+	}
+	return conn.(*net.UDPConn), nil
+}
+
 func (p *ProxyServer) KeepAlive(ip string, port int) {
 	for {
-		_, _ = p.Server.WriteToUDP([]byte("hello-proxy"), &net.UDPAddr{
+		_, _ = p.GetConn().WriteToUDP([]byte("hello-proxy"), &net.UDPAddr{
 			IP:   net.ParseIP(ip),
 			Port: port,
 		})
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gravitl/netclient/ncutils"
+)
+
+// receives - checks the conn receives a packet sent to the port on localhost
+func receives(t *testing.T, conn *net.UDPConn, port int) bool {
+	t.Helper()
+	sender, err := net.DialUDP("udp", nil, &net.UDPAddr{IP: net.ParseIP("127.0.0.1"), Port: port})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sender.Close()
+	if _, err := sender.Write([]byte("hello-proxy")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 32)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFromUDP(buf)
+	return err == nil && string(buf[:n]) == "hello-proxy"
+}
+
+func TestRebind(t *testing.T) {
+	port, err := ncutils.GetFreePort(43000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &ProxyServer{Config: Config{Port: port}}
+	if p.Server, err = listenUDP(port); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { p.GetConn().Close() }()
+
+	// the new listener is bound while the old one still holds the port
+	old := p.GetConn()
+	if err := p.Rebind(); err != nil {
+		t.Fatal(err)
+	}
+	if p.GetConn() == old {
+		t.Fatal("expected the proxy to be rebound")
+	}
+	if _, _, err := old.ReadFromUDP(make([]byte, 1)); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("expected the replaced listener to be closed, got %v", err)
+	}
+	if !receives(t, p.GetConn(), port) {
+		t.Fatal("expected the rebound proxy to receive packets")
+	}
+
+	// a failed bind keeps the current listener
+	listen := listenUDP
+	defer func() { listenUDP = listen }()
+	listenUDP = func(port int) (*net.UDPConn, error) { return nil, errors.New("address in use") }
+	current := p.GetConn()
+	if err := p.Rebind(); err == nil {
+		t.Fatal("expected the rebind to fail")
+	}
+	if p.GetConn() != current || !receives(t, current, port) {
+		t.Fatal("expected the proxy to keep listening on the current listener")
+	}
+}
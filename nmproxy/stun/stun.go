@@ -1,11 +1,14 @@
 package stun
 
 import (
-	"fmt"
+	"errors"
 	"net"
 	"strconv"
-	"strings"
+	"sync"
+	"time"
 
+	ncconfig "github.com/gravitl/netclient/config"
+	"github.com/gravitl/netclient/ncutils"
 	"github.com/gravitl/netclient/nmproxy/models"
 	"github.com/gravitl/netmaker/logger"
 	nmmodels "github.com/gravitl/netmaker/models"
@@ -42,74 +45,206 @@ func DoesIPExistLocally(ip net.IP) bool {
 	return false
 }
 
+const (
+	// defaultQueryTimeout - time a stun server has to answer a query, when not configured
+	defaultQueryTimeout = 5 * time.Second
+	// defaultQueryConcurrency - stun servers queried at the same time, when not configured
+	defaultQueryConcurrency = 2
+	// natTypeAnswers - answers from different stun servers needed to determine the nat type
+	natTypeAnswers = 2
+)
+
+// errQueryTimeout - the stun server did not answer within the query timeout
+var errQueryTimeout = errors.New("stun query timed out")
+
+// errQueryStopped - enough stun servers answered before this one did
+var errQueryStopped = errors.New("stun query stopped")
+
+// stunAnswer - the address a stun server saw a query from the local address come from
+type stunAnswer struct {
+	server nmmodels.StunServer
+	local  *net.UDPAddr
+	addr   stun.XORMappedAddress
+}
+
+// QueryTimeout - returns the time a stun server has to answer a query
+func QueryTimeout() time.Duration {
+	if timeout := ncconfig.Netclient().StunTimeout; timeout > 0 {
+		return time.Duration(timeout) * time.Second
+	}
+	return defaultQueryTimeout
+}
+
+// queryConcurrency - returns the number of stun servers queried at the same time
+func queryConcurrency() int {
+	if concurrency := ncconfig.Netclient().StunConcurrency; concurrency > 0 {
+		return concurrency
+	}
+	return defaultQueryConcurrency
+}
+
 // GetHostNatInfo - calls stun server for udp hole punch and fetches host info
 func GetHostNatInfo(stunList []nmmodels.StunServer, currentPublicIP string, stunPort int) (info *models.HostInfo) {
 
 	info = &models.HostInfo{
 		PublicIp: net.ParseIP(currentPublicIP),
 	}
-	// need to store results from two different stun servers to determine nat type
-	endpointList := []stun.XORMappedAddress{}
 	info.NatType = nmmodels.NAT_Types.Double
 
-	// traverse through stun servers, continue if any error is encountered
-	for _, stunServer := range stunList {
-		stunServer := stunServer
-		s, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", stunServer.Domain, stunServer.Port))
-		if err != nil {
-			logger.Log(1, "failed to resolve udp addr: ", err.Error())
-			continue
-		}
-		l := &net.UDPAddr{
-			IP:   net.ParseIP(""),
-			Port: stunPort,
-		}
-		conn, err := net.DialUDP("udp", l, s)
-		if err != nil {
-			logger.Log(0, "failed to dial: ", err.Error())
-			continue
-		}
-		defer conn.Close()
-		c, err := stun.NewClient(conn)
-		if err != nil {
-			logger.Log(1, "failed to create stun client: ", err.Error())
-			conn.Close()
-			continue
-		}
-		defer c.Close()
-		re := strings.Split(conn.LocalAddr().String(), ":")
-		info.PrivIp = net.ParseIP(re[0])
-		info.PrivPort, _ = strconv.Atoi(re[1])
-		// Building binding request with random transaction id.
-		message := stun.MustBuild(stun.TransactionID, stun.BindingRequest)
-		// Sending request to STUN server, waiting for response message.
-		if err := c.Do(message, func(res stun.Event) {
-			if res.Error != nil {
-				logger.Log(1, "0:stun error: ", res.Error.Error())
-				return
-			}
-			// Decoding XOR-MAPPED-ADDRESS attribute from message.
-			var xorAddr stun.XORMappedAddress
-			if err := xorAddr.GetFrom(res.Message); err != nil {
-				logger.Log(1, "1:stun error: ", res.Error.Error())
-				return
-			}
-			info.PublicIp = xorAddr.IP
-			info.PubPort = xorAddr.Port
-			endpointList = append(endpointList, xorAddr)
-		}); err != nil {
-			logger.Log(1, "2:stun error: ", err.Error())
-			conn.Close()
-			continue
+	// need to store results from two different stun servers to determine nat type,
+	// starting with the one that answered last
+	timeout, concurrency := QueryTimeout(), queryConcurrency()
+	logger.Log(1, "querying stun servers with a timeout of", timeout.String(), "and concurrency of", strconv.Itoa(concurrency))
+	answers := queryServers(preferLastAnswered(stunList), stunPort, timeout, concurrency, natTypeAnswers)
+	if len(answers) == 0 {
+		return
+	}
+	recordAnswered(answers[0].server)
+	endpointList := []stun.XORMappedAddress{}
+	for _, answer := range answers {
+		info.PrivIp = answer.local.IP
+		info.PrivPort = answer.local.Port
+		info.PublicIp = answer.addr.IP
+		info.PubPort = answer.addr.Port
+		endpointList = append(endpointList, answer.addr)
+	}
+	if len(endpointList) > 1 {
+		info.NatType = getNatType(endpointList, currentPublicIP, stunPort)
+	}
+	return
+}
+
+// queryServers - queries up to concurrency stun servers at a time, in list order, until want of them answered,
+// returns the answers in the order they arrived, a server failing or not answering within timeout is skipped,
+// the queries still running are stopped and waited for on return so the stun port is free for the proxy
+func queryServers(servers []nmmodels.StunServer, stunPort int, timeout time.Duration, concurrency, want int) []stunAnswer {
+	answers := []stunAnswer{}
+	stop := make(chan struct{})
+	// buffered so queries still running once enough servers answered don't block
+	done := make(chan *stunAnswer, len(servers))
+	var wg sync.WaitGroup
+	next, pending := 0, 0
+	for len(answers) < want && (next < len(servers) || pending > 0) {
+		for pending < concurrency && next < len(servers) {
+			server := servers[next]
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				done <- queryServer(server, stunPort, timeout, stop)
+			}()
+			next++
+			pending++
 		}
-		if len(endpointList) > 1 {
-			info.NatType = getNatType(endpointList[:], currentPublicIP, stunPort)
-			conn.Close()
-			break
+		if answer := <-done; answer != nil {
+			answers = append(answers, *answer)
 		}
+		pending--
+	}
+	close(stop)
+	wg.Wait()
+	return answers
+}
+
+// queryServer - queries the stun server from the stun port, nil if it failed, did not answer within timeout
+// or was stopped
+func queryServer(server nmmodels.StunServer, stunPort int, timeout time.Duration, stop <-chan struct{}) *stunAnswer {
+	s, err := net.ResolveUDPAddr("udp", stunAddr(server))
+	if err != nil {
+		logger.Log(1, "failed to resolve udp addr: ", err.Error())
+		return nil
+	}
+	l := &net.UDPAddr{
+		IP:   net.ParseIP(""),
+		Port: stunPort,
+	}
+	conn, err := dialStable(l, s)
+	if err != nil {
+		logger.Log(0, "failed to dial: ", err.Error())
+		return nil
+	}
+	defer conn.Close()
+	xorAddr, err := queryWithTimeout(conn, timeout, stop)
+	if err != nil {
+		logger.Log(1, "stun error: ", stunAddr(server), err.Error())
+		return nil
+	}
+	return &stunAnswer{server: server, local: conn.LocalAddr().(*net.UDPAddr), addr: xorAddr}
+}
+
+// queryWithTimeout - queries the stun server on the conn, closing the conn if it does not answer within timeout
+// or the query is stopped
+func queryWithTimeout(conn net.Conn, timeout time.Duration, stop <-chan struct{}) (stun.XORMappedAddress, error) {
+	type result struct {
+		addr stun.XORMappedAddress
+		err  error
+	}
+	_ = conn.SetDeadline(time.Now().Add(timeout))
+	res := make(chan result, 1)
+	go func() {
+		addr, err := queryStun(conn)
+		res <- result{addr: addr, err: err}
+	}()
+	select {
+	case r := <-res:
+		return r.addr, r.err
+	case <-time.After(timeout):
 		conn.Close()
+		return stun.XORMappedAddress{}, errQueryTimeout
+	case <-stop:
+		conn.Close()
+		return stun.XORMappedAddress{}, errQueryStopped
 	}
-	return
+}
+
+// queryStun - sends a binding request on the conn and returns the address the stun server saw,
+// tests replace it to inject a fake stun responder
+var queryStun = func(conn net.Conn) (stun.XORMappedAddress, error) {
+	var xorAddr stun.XORMappedAddress
+	c, err := stun.NewClient(conn)
+	if err != nil {
+		return xorAddr, err
+	}
+	defer c.Close()
+	// Building binding request with random transaction id.
+	message := stun.MustBuild(stun.TransactionID, stun.BindingRequest)
+	var resErr error
+	// Sending request to STUN server, waiting for response message.
+	if err := c.Do(message, func(res stun.Event) {
+		if res.Error != nil {
+			resErr = res.Error
+			return
+		}
+		// Decoding XOR-MAPPED-ADDRESS attribute from message.
+		resErr = xorAddr.GetFrom(res.Message)
+	}); err != nil {
+		return xorAddr, err
+	}
+	return xorAddr, resErr
+}
+
+// dialStable - dials the stun server, binding a stable address when the system picked a temporary ipv6 one
+func dialStable(l, s *net.UDPAddr) (*net.UDPConn, error) {
+	conn, err := dialShared(l, s)
+	if err != nil || ncconfig.Netclient().AllowTemporaryIPv6 {
+		return conn, err
+	}
+	src := conn.LocalAddr().(*net.UDPAddr).IP
+	stable := ncutils.StableSourceIP(src, ncconfig.Netclient().StableIPv6)
+	if stable.Equal(src) {
+		return conn, nil
+	}
+	conn.Close()
+	return dialShared(&net.UDPAddr{IP: stable, Port: l.Port}, s)
+}
+
+// dialShared - dials the stun server from a local address the concurrent queries can share
+func dialShared(l, s *net.UDPAddr) (*net.UDPConn, error) {
+	dialer := net.Dialer{LocalAddr: l, Control: reuseAddr}
+	conn, err := dialer.Dial("udp", s.String())
+	if err != nil {
+		return nil, err
+	}
+	return conn.(*net.UDPConn), nil
 }
 
 // compare ports and endpoints between stun results to determine nat type
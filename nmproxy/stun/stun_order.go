@@ -0,0 +1,50 @@
+package stun
+
+import (
+	"fmt"
+	"sync"
+
+	nmmodels "github.com/gravitl/netmaker/models"
+)
+
+// lastAnswered - the stun server that last answered, tried first by the next detection
+var lastAnswered = struct {
+	mutex sync.Mutex
+	addr  string
+}{}
+
+func stunAddr(server nmmodels.StunServer) string {
+	return fmt.Sprintf("%s:%d", server.Domain, server.Port)
+}
+
+// recordAnswered - records the stun server as the one to try first next time
+func recordAnswered(server nmmodels.StunServer) {
+	lastAnswered.mutex.Lock()
+	defer lastAnswered.mutex.Unlock()
+	lastAnswered.addr = stunAddr(server)
+}
+
+// LastAnswered - returns the address of the stun server that last answered, empty if none did
+func LastAnswered() string {
+	lastAnswered.mutex.Lock()
+	defer lastAnswered.mutex.Unlock()
+	return lastAnswered.addr
+}
+
+// preferLastAnswered - returns the stun list with the server that last answered moved to the front,
+// the remaining servers keep their order
+func preferLastAnswered(stunList []nmmodels.StunServer) []nmmodels.StunServer {
+	last := LastAnswered()
+	ordered := make([]nmmodels.StunServer, 0, len(stunList))
+	for _, server := range stunList {
+		if stunAddr(server) == last {
+			ordered = append(ordered, server)
+		}
+	}
+	for _, server := range stunList {
+		if stunAddr(server) != last {
+			ordered = append(ordered, server)
+		}
+	}
+	return ordered
+}
@@ -0,0 +1,119 @@
+package stun
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	ncconfig "github.com/gravitl/netclient/config"
+	nmmodels "github.com/gravitl/netmaker/models"
+	"gortc.io/stun"
+)
+
+// fakeResponder - answers binding requests for the stun servers on the given ports with a fixed public address,
+// recording the ports queried in order
+func fakeResponder(answering map[int]bool, queried *[]int) func(conn net.Conn) (stun.XORMappedAddress, error) {
+	return func(conn net.Conn) (stun.XORMappedAddress, error) {
+		port := conn.RemoteAddr().(*net.UDPAddr).Port
+		*queried = append(*queried, port)
+		if !answering[port] {
+			return stun.XORMappedAddress{}, errors.New("no response")
+		}
+		return stun.XORMappedAddress{IP: net.ParseIP("198.51.100.7"), Port: 40000}, nil
+	}
+}
+
+func TestGetHostNatInfoFailover(t *testing.T) {
+	prev := queryStun
+	defer func() {
+		queryStun = prev
+		lastAnswered.addr = ""
+		ncconfig.Netclient().StunConcurrency = 0
+	}()
+	// one query at a time, so the servers are queried in order
+	ncconfig.Netclient().StunConcurrency = 1
+	stunList := []nmmodels.StunServer{
+		{Domain: "127.0.0.1", Port: 3478},
+		{Domain: "127.0.0.1", Port: 3479},
+		{Domain: "127.0.0.1", Port: 3480},
+	}
+	queried := []int{}
+	queryStun = fakeResponder(map[int]bool{3479: true, 3480: true}, &queried)
+	info := GetHostNatInfo(stunList, "", 0)
+	if info.PubPort != 40000 || !info.PublicIp.Equal(net.ParseIP("198.51.100.7")) {
+		t.Fatalf("expected the answering stun servers to be used, got %+v", info)
+	}
+	if LastAnswered() != "127.0.0.1:3479" {
+		t.Fatalf("expected the first answering server to be recorded, got %q", LastAnswered())
+	}
+
+	// the server that answered is tried first next time
+	queried = nil
+	queryStun = fakeResponder(map[int]bool{3480: true}, &queried)
+	info = GetHostNatInfo(stunList, "", 0)
+	if len(queried) != 3 || queried[0] != 3479 || queried[1] != 3478 || queried[2] != 3480 {
+		t.Fatalf("expected the last answering server to be queried first, got %v", queried)
+	}
+	if info.PubPort != 40000 || LastAnswered() != "127.0.0.1:3480" {
+		t.Fatalf("expected detection to go on to the next answering server, got %+v last %q", info, LastAnswered())
+	}
+}
+
+func TestGetHostNatInfoSlowServers(t *testing.T) {
+	prev := queryStun
+	defer func() {
+		queryStun = prev
+		lastAnswered.addr = ""
+		ncconfig.Netclient().StunConcurrency = 0
+		ncconfig.Netclient().StunTimeout = 0
+	}()
+	// 3478 hangs until its conn is closed, 3479 fails at once, 3480 and 3481 answer
+	hung := make(chan net.Conn, 2)
+	queryStun = func(conn net.Conn) (stun.XORMappedAddress, error) {
+		switch conn.RemoteAddr().(*net.UDPAddr).Port {
+		case 3478:
+			hung <- conn
+			_, err := conn.Read(make([]byte, 1))
+			return stun.XORMappedAddress{}, err
+		case 3479:
+			return stun.XORMappedAddress{}, errors.New("unreachable")
+		}
+		return stun.XORMappedAddress{IP: net.ParseIP("198.51.100.7"), Port: 40000}, nil
+	}
+	stunList := []nmmodels.StunServer{
+		{Domain: "127.0.0.1", Port: 3478},
+		{Domain: "127.0.0.1", Port: 3479},
+		{Domain: "127.0.0.1", Port: 3480},
+		{Domain: "127.0.0.1", Port: 3481},
+	}
+
+	// the answering servers are queried alongside the hanging one
+	ncconfig.Netclient().StunTimeout = 30
+	ncconfig.Netclient().StunConcurrency = 4
+	start := time.Now()
+	info := GetHostNatInfo(stunList, "", 0)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected detection to return once two servers answered, took %v", elapsed)
+	}
+	if info.PubPort != 40000 || LastAnswered() == "127.0.0.1:3478" {
+		t.Fatalf("expected the answering servers to be used, got %+v last %q", info, LastAnswered())
+	}
+	// the hanging query is stopped before detection returns rather than left holding the stun port until its timeout
+	if _, err := (<-hung).Write([]byte{0}); !errors.Is(err, net.ErrClosed) {
+		t.Fatalf("expected the conn of the hanging query to be closed once detection returned, got %v", err)
+	}
+
+	// queried one at a time, the hanging server is given up on after the timeout
+	lastAnswered.addr = ""
+	ncconfig.Netclient().StunTimeout = 1
+	ncconfig.Netclient().StunConcurrency = 1
+	start = time.Now()
+	info = GetHostNatInfo(stunList, "", 0)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected the hanging server to time out, took %v", elapsed)
+	}
+	if info.PubPort != 40000 || LastAnswered() != "127.0.0.1:3480" {
+		t.Fatalf("expected detection to go on past the hanging server, got %+v last %q", info, LastAnswered())
+	}
+}
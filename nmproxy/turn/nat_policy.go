@@ -0,0 +1,61 @@
+package turn
+
+import (
+	ncconfig "github.com/gravitl/netclient/config"
+	"github.com/gravitl/netmaker/logger"
+	nm_models "github.com/gravitl/netmaker/models"
+)
+
+// connections to a peer decided by the nat types of the host and peer
+const (
+	// NatDirect - peers reach each other directly
+	NatDirect = "direct"
+	// NatProxy - peers reach each other through the proxy
+	NatProxy = "proxy"
+	// NatTurn - peers reach each other through turn
+	NatTurn = "turn"
+)
+
+// natTypes - the known nat types, in the order used for the policy keys
+var natTypes = []string{
+	nm_models.NAT_Types.Public,
+	nm_models.NAT_Types.Symmetric,
+	nm_models.NAT_Types.Asymmetric,
+	nm_models.NAT_Types.Double,
+}
+
+// NatDecision - returns the connection to use with a peer by the nat types of the host and peer,
+// the configured nat policy is consulted before the default
+func NatDecision(hostNat, peerNat string) string {
+	return natDecision(ncconfig.Netclient().NatPolicy, hostNat, peerNat)
+}
+
+// HostMayUseTurn - checks if the host uses turn with peers of any nat type
+func HostMayUseTurn(hostNat string) bool {
+	for _, peerNat := range natTypes {
+		if NatDecision(hostNat, peerNat) == NatTurn {
+			return true
+		}
+	}
+	return false
+}
+
+// natDecision - looks up the nat types in the policy in either order, falling back to the default
+func natDecision(policy map[string]string, hostNat, peerNat string) string {
+	for _, key := range []string{hostNat + ":" + peerNat, peerNat + ":" + hostNat} {
+		decision, ok := policy[key]
+		if !ok {
+			continue
+		}
+		switch decision {
+		case NatDirect, NatProxy, NatTurn:
+			return decision
+		default:
+			logger.Log(0, "unknown nat policy", decision, "for", key, "using the default")
+		}
+	}
+	if ShouldUseTurn(hostNat) && ShouldUseTurn(peerNat) {
+		return NatTurn
+	}
+	return NatDirect
+}
@@ -0,0 +1,40 @@
+package turn
+
+import (
+	"testing"
+
+	nm_models "github.com/gravitl/netmaker/models"
+	"github.com/matryer/is"
+)
+
+func TestNatDecision(t *testing.T) {
+	is := is.New(t)
+	public, sym, asym, double := nm_models.NAT_Types.Public, nm_models.NAT_Types.Symmetric,
+		nm_models.NAT_Types.Asymmetric, nm_models.NAT_Types.Double
+	t.Run("default", func(t *testing.T) {
+		for _, c := range []struct{ host, peer, want string }{
+			{public, public, NatDirect},
+			{public, asym, NatDirect},
+			{sym, sym, NatDirect},
+			{sym, double, NatDirect},
+			{asym, asym, NatTurn},
+			{asym, double, NatTurn},
+			{double, asym, NatTurn},
+			{"", asym, NatDirect},
+		} {
+			is.Equal(natDecision(nil, c.host, c.peer), c.want)
+		}
+	})
+	t.Run("configured", func(t *testing.T) {
+		policy := map[string]string{
+			sym + ":" + asym:    NatProxy,
+			asym + ":" + double: NatDirect,
+			public + ":" + sym:  "bogus",
+		}
+		is.Equal(natDecision(policy, sym, asym), NatProxy)
+		is.Equal(natDecision(policy, asym, sym), NatProxy)
+		is.Equal(natDecision(policy, double, asym), NatDirect)
+		is.Equal(natDecision(policy, asym, asym), NatTurn)
+		is.Equal(natDecision(policy, public, sym), NatDirect)
+	})
+}
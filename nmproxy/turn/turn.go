@@ -30,11 +30,10 @@ import (
 // Init - start's the turn client for all the present turn configs
 func Init(ctx context.Context, wg *sync.WaitGroup, turnCfgs []ncconfig.TurnConfig) {
 	for _, turnCfgI := range turnCfgs {
-		if turnCfgI.Server == "" || turnCfgI.Domain == "" || turnCfgI.Port == 0 {
+		if turnCfgI.Server == "" {
 			continue
 		}
-		err := startClient(turnCfgI.Server, turnCfgI.Domain, turnCfgI.Port)
-		if err != nil {
+		if _, err := selectTurnServer(turnCfgI.Server); err != nil {
 			logger.Log(0, "failed to start turn client: ", err.Error())
 			continue
 		}
@@ -46,6 +45,50 @@ func Init(ctx context.Context, wg *sync.WaitGroup, turnCfgs []ncconfig.TurnConfi
 	}
 }
 
+// startTurnClient - registers with a turn server, replaced in tests
+var startTurnClient = startClient
+
+// selectTurnServer - starts the turn client on the first reachable turn server configured for the server,
+// healthy turn servers are preferred in their configured order
+func selectTurnServer(serverName string) (ncconfig.TurnConfig, error) {
+	candidates := ncconfig.GetTurnServers(serverName)
+	if len(candidates) == 0 {
+		return ncconfig.TurnConfig{}, errors.New("no turn servers configured for " + serverName)
+	}
+	var err error
+	for _, candidate := range orderTurnServers(candidates) {
+		if err = startTurnClient(serverName, candidate.Domain, candidate.Port); err != nil {
+			logger.Log(0, "failed to start turn client on", turnKey(candidate), err.Error())
+			markTurnFailure(candidate)
+			continue
+		}
+		setActiveTurnServer(serverName, candidate)
+		logger.Log(0, "using turn server", turnKey(candidate), "for", serverName)
+		return candidate, nil
+	}
+	return ncconfig.TurnConfig{}, err
+}
+
+// failoverTurn - closes the turn client on the current turn server and registers with the next healthy one
+func failoverTurn(serverName string) {
+	if t, ok := config.GetCfg().GetTurnCfg(serverName); ok {
+		t.Mutex.Lock()
+		if t.TurnConn != nil {
+			t.TurnConn.Close()
+		}
+		if t.Client != nil {
+			t.Client.Close()
+		}
+		if t.Cfg != nil && t.Cfg.Conn != nil {
+			t.Cfg.Conn.Close()
+		}
+		t.Mutex.Unlock()
+	}
+	if _, err := selectTurnServer(serverName); err != nil {
+		logger.Log(0, "failed to fail over turn server for", serverName, err.Error())
+	}
+}
+
 // startClient - starts the turn client and allocates itself address on the turn server provided
 func startClient(server, turnDomain string, turnPort int) error {
 	conn, err := net.ListenPacket("udp", "0.0.0.0:0")
@@ -176,9 +219,20 @@ func startTurnListener(ctx context.Context, wg *sync.WaitGroup, serverName strin
 	if err != nil {
 		logger.Log(0, "failed to allocate addr on turn: ", err.Error())
 		t.Status = false
+		if active, ok := getActiveTurnServer(serverName); ok {
+			markTurnFailure(active)
+		}
+		go func() {
+			// retry the allocation, on repeated failures the turn server is switched
+			time.Sleep(time.Second * 30)
+			resetCh <- struct{}{}
+		}()
 	} else {
 		t.TurnConn = turnConn
 		t.Status = true
+		if active, ok := getActiveTurnServer(serverName); ok {
+			markTurnSuccess(active)
+		}
 	}
 	config.GetCfg().SetTurnCfg(serverName, t)
 	t.Mutex.Unlock()
@@ -203,12 +257,20 @@ func startTurnListener(ctx context.Context, wg *sync.WaitGroup, serverName strin
 		case <-ctx.Done():
 			return
 		case <-resetCh:
+			active, hasActive := getActiveTurnServer(serverName)
+			if hasActive && !isTurnHealthy(active) {
+				logger.Log(0, "turn server", turnKey(active), "is unhealthy, failing over")
+				failoverTurn(serverName)
+				active, hasActive = getActiveTurnServer(serverName)
+			}
 			t, ok := config.GetCfg().GetTurnCfg(serverName)
 			if !ok {
 				continue
 			}
 			t.Mutex.Lock()
-			t.TurnConn.Close()
+			if t.TurnConn != nil {
+				t.TurnConn.Close()
+			}
 			// reallocate addr and signal all the peers
 			logger.Log(0, "## Reintializing Turn Endpoint on server:", serverName)
 			if t.Client == nil {
@@ -219,6 +281,9 @@ func startTurnListener(ctx context.Context, wg *sync.WaitGroup, serverName strin
 			if err != nil {
 				logger.Log(0, "failed to allocate addr on turn: ", err.Error())
 				t.Status = false
+				if hasActive {
+					markTurnFailure(active)
+				}
 				config.GetCfg().SetTurnCfg(serverName, t)
 				go func() {
 					// need to retry to allocate addr again on turn server
@@ -232,6 +297,9 @@ func startTurnListener(ctx context.Context, wg *sync.WaitGroup, serverName strin
 			}
 			t.TurnConn = turnConn
 			t.Status = true
+			if hasActive {
+				markTurnSuccess(active)
+			}
 			config.GetCfg().SetTurnCfg(serverName, t)
 			t.Mutex.Unlock()
 			turnPeersMap := config.GetCfg().GetAllTurnPeersCfg(serverName)
@@ -5,7 +5,9 @@ import (
 	"fmt"
 	"net"
 	"sync"
+	"time"
 
+	ncconfig "github.com/gravitl/netclient/config"
 	"github.com/gravitl/netclient/ncutils"
 	"github.com/gravitl/netclient/nmproxy/config"
 	"github.com/gravitl/netclient/nmproxy/models"
@@ -59,13 +61,22 @@ func WatchPeerSignals(ctx context.Context, wg *sync.WaitGroup) {
 
 					peer, err := wireguard.GetPeer(ncutils.GetInterfaceName(), signal.FromHostPubKey)
 					if err == nil {
-						peerpkg.AddNew(t.Server, wgtypes.PeerConfig{
+						err = peerpkg.AddNew(t.Server, wgtypes.PeerConfig{
 							PublicKey:                   peer.PublicKey,
 							PresharedKey:                &peer.PresharedKey,
 							Endpoint:                    peer.Endpoint,
 							PersistentKeepaliveInterval: &peer.PersistentKeepaliveInterval,
 							AllowedIPs:                  peer.AllowedIPs,
 						}, t.PeerConf, false, peerTurnEndpoint, true)
+						if err == nil {
+							config.NotifyConnModeChange(models.ConnModeChange{
+								Server:  signal.Server,
+								PeerKey: signal.FromHostPubKey,
+								OldMode: models.ConnModeDirect,
+								NewMode: models.ConnModeTurn,
+								Reason:  "peer is behind a nat that requires turn",
+							})
+						}
 					}
 
 				}
@@ -105,3 +116,86 @@ func ShouldUseTurn(natType string) bool {
 	}
 	return false
 }
+
+const (
+	// turnFailureThreshold - consecutive failures after which a turn server is considered unhealthy
+	turnFailureThreshold = 3
+	// turnRetryInterval - time after which an unhealthy turn server is tried again
+	turnRetryInterval = time.Minute * 5
+)
+
+type turnHealth struct {
+	failures    int
+	lastFailure time.Time
+}
+
+var (
+	turnHealthMap     = make(map[string]*turnHealth)
+	activeTurnServers = make(map[string]ncconfig.TurnConfig)
+	turnHealthMutex   = &sync.Mutex{}
+)
+
+func turnKey(turnCfg ncconfig.TurnConfig) string {
+	return fmt.Sprintf("%s:%d", turnCfg.Domain, turnCfg.Port)
+}
+
+// markTurnFailure - records a failed registration/allocation on the turn server
+func markTurnFailure(turnCfg ncconfig.TurnConfig) {
+	turnHealthMutex.Lock()
+	defer turnHealthMutex.Unlock()
+	h, ok := turnHealthMap[turnKey(turnCfg)]
+	if !ok {
+		h = &turnHealth{}
+		turnHealthMap[turnKey(turnCfg)] = h
+	}
+	h.failures++
+	h.lastFailure = time.Now()
+}
+
+// markTurnSuccess - resets the failure count of the turn server
+func markTurnSuccess(turnCfg ncconfig.TurnConfig) {
+	turnHealthMutex.Lock()
+	defer turnHealthMutex.Unlock()
+	delete(turnHealthMap, turnKey(turnCfg))
+}
+
+// isTurnHealthy - checks if the turn server is usable, unhealthy servers are retried after turnRetryInterval
+func isTurnHealthy(turnCfg ncconfig.TurnConfig) bool {
+	turnHealthMutex.Lock()
+	defer turnHealthMutex.Unlock()
+	h, ok := turnHealthMap[turnKey(turnCfg)]
+	if !ok {
+		return true
+	}
+	return h.failures < turnFailureThreshold || time.Since(h.lastFailure) > turnRetryInterval
+}
+
+// orderTurnServers - orders the turn servers for selection, healthy servers first in their configured order
+// followed by the unhealthy ones
+func orderTurnServers(candidates []ncconfig.TurnConfig) []ncconfig.TurnConfig {
+	healthy := []ncconfig.TurnConfig{}
+	unhealthy := []ncconfig.TurnConfig{}
+	for _, candidate := range candidates {
+		if isTurnHealthy(candidate) {
+			healthy = append(healthy, candidate)
+		} else {
+			unhealthy = append(unhealthy, candidate)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+// setActiveTurnServer - records the turn server in use for the server
+func setActiveTurnServer(serverName string, turnCfg ncconfig.TurnConfig) {
+	turnHealthMutex.Lock()
+	defer turnHealthMutex.Unlock()
+	activeTurnServers[serverName] = turnCfg
+}
+
+// getActiveTurnServer - fetches the turn server in use for the server
+func getActiveTurnServer(serverName string) (ncconfig.TurnConfig, bool) {
+	turnHealthMutex.Lock()
+	defer turnHealthMutex.Unlock()
+	turnCfg, ok := activeTurnServers[serverName]
+	return turnCfg, ok
+}
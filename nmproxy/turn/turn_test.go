@@ -0,0 +1,98 @@
+package turn
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	ncconfig "github.com/gravitl/netclient/config"
+	"github.com/gravitl/netclient/nmproxy/config"
+	"github.com/gravitl/netclient/nmproxy/models"
+	"github.com/matryer/is"
+)
+
+// resetTurnHealth - clears the turn server health and selection for the test and after it
+func resetTurnHealth(t *testing.T) {
+	reset := func() {
+		turnHealthMutex.Lock()
+		defer turnHealthMutex.Unlock()
+		turnHealthMap = make(map[string]*turnHealth)
+		activeTurnServers = make(map[string]ncconfig.TurnConfig)
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+func TestOrderTurnServers(t *testing.T) {
+	primary := ncconfig.TurnConfig{Server: "server", Domain: "turn1.example.com", Port: 3479}
+	fallback := ncconfig.TurnConfig{Server: "server", Domain: "turn2.example.com", Port: 3479}
+	t.Run("all healthy", func(t *testing.T) {
+		is := is.New(t)
+		resetTurnHealth(t)
+		ordered := orderTurnServers([]ncconfig.TurnConfig{primary, fallback})
+		is.Equal(ordered[0], primary)
+		is.Equal(ordered[1], fallback)
+	})
+	t.Run("primary failing", func(t *testing.T) {
+		is := is.New(t)
+		resetTurnHealth(t)
+		for i := 0; i < turnFailureThreshold; i++ {
+			markTurnFailure(primary)
+		}
+		is.True(!isTurnHealthy(primary))
+		ordered := orderTurnServers([]ncconfig.TurnConfig{primary, fallback})
+		is.Equal(ordered[0], fallback)
+		is.Equal(ordered[1], primary)
+	})
+	t.Run("primary recovered", func(t *testing.T) {
+		is := is.New(t)
+		resetTurnHealth(t)
+		for i := 0; i < turnFailureThreshold; i++ {
+			markTurnFailure(primary)
+		}
+		markTurnSuccess(primary)
+		is.True(isTurnHealthy(primary))
+		ordered := orderTurnServers([]ncconfig.TurnConfig{primary, fallback})
+		is.Equal(ordered[0], primary)
+	})
+}
+
+func TestFailoverTurn(t *testing.T) {
+	is := is.New(t)
+	resetTurnHealth(t)
+	config.InitializeCfg()
+	server := ncconfig.Server{TurnFallbacks: []ncconfig.TurnConfig{{Domain: "turn2.example.com", Port: 3479}}}
+	server.Name = "server"
+	server.UseTurn = true
+	server.TurnDomain = "turn1.example.com"
+	server.TurnPort = 3479
+	ncconfig.UpdateServer("server", server)
+	defer delete(ncconfig.Servers, "server")
+	prev := startTurnClient
+	defer func() { startTurnClient = prev }()
+	primaryDown := false
+	registered := []string{}
+	startTurnClient = func(server, turnDomain string, turnPort int) error {
+		if primaryDown && turnDomain == "turn1.example.com" {
+			return errors.New("turn server unreachable")
+		}
+		registered = append(registered, turnDomain)
+		config.GetCfg().SetTurnCfg(server, models.TurnCfg{Mutex: &sync.RWMutex{}, Status: true})
+		return nil
+	}
+
+	active, err := selectTurnServer("server")
+	is.NoErr(err)
+	is.Equal(active.Domain, "turn1.example.com")
+
+	// the primary stops answering, its allocations fail until it is considered unhealthy
+	primaryDown = true
+	for i := 0; i < turnFailureThreshold; i++ {
+		markTurnFailure(active)
+	}
+	failoverTurn("server")
+	active, ok := getActiveTurnServer("server")
+	is.True(ok)
+	is.Equal(active.Domain, "turn2.example.com")
+	is.Equal(registered, []string{"turn1.example.com", "turn2.example.com"}) // registered again with the fallback
+}
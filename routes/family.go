@@ -0,0 +1,36 @@
+package routes
+
+import (
+	"net"
+	"sync/atomic"
+)
+
+// public addresses the default route of each family is looked up with
+var (
+	defaultRouteProbe4 = net.ParseIP("1.1.1.1")
+	defaultRouteProbe6 = net.ParseIP("2606:4700:4700::1111")
+)
+
+// defaultRouteProbe - returns the address the default route of the family is looked up with
+func defaultRouteProbe(v6 bool) net.IP {
+	if v6 {
+		return defaultRouteProbe6
+	}
+	return defaultRouteProbe4
+}
+
+// ipv6Disabled - set while the host has no ipv6 connectivity, ipv6 routes are not added then
+var ipv6Disabled atomic.Bool
+
+// SetIPv6Enabled - enables or disables adding routes to ipv6 addresses
+func SetIPv6Enabled(enabled bool) {
+	ipv6Disabled.Store(!enabled)
+}
+
+// familyEnabled - checks if routes to the address's family may be added
+func familyEnabled(ip net.IP) bool {
+	if ip == nil || ip.To4() != nil {
+		return true
+	}
+	return !ipv6Disabled.Load()
+}
@@ -0,0 +1,45 @@
+package routes
+
+import (
+	"sync"
+
+	"github.com/gravitl/netclient/config"
+)
+
+var (
+	inetGWMU       sync.Mutex
+	inetGWSelected bool // set once the internet gateways in use have been selected
+	inetGW4InUse   bool // the ipv4 default route goes through the ipv4 internet gateway peer
+	inetGW6InUse   bool // the ipv6 default route goes through the ipv6 internet gateway peer
+)
+
+// SetInetGatewaysInUse - sets the families whose default route goes through an internet gateway peer,
+// server and peer endpoint routes are only set for those families
+func SetInetGatewaysInUse(gw4, gw6 bool) {
+	inetGWMU.Lock()
+	defer inetGWMU.Unlock()
+	inetGWSelected = true
+	inetGW4InUse, inetGW6InUse = gw4, gw6
+}
+
+// inetGatewayInUse - checks if the default route of the family goes through an internet gateway peer,
+// the detected gateways are taken to be in use until the gateways are selected
+func inetGatewayInUse(v6 bool) bool {
+	inetGWMU.Lock()
+	defer inetGWMU.Unlock()
+	if !inetGWSelected {
+		if v6 {
+			return config.GW6PeerDetected
+		}
+		return config.GW4PeerDetected
+	}
+	if v6 {
+		return inetGW6InUse
+	}
+	return inetGW4InUse
+}
+
+// inetGatewaysInUse - checks if the default route of any family goes through an internet gateway peer
+func inetGatewaysInUse() bool {
+	return inetGatewayInUse(false) || inetGatewayInUse(true)
+}
@@ -0,0 +1,162 @@
+package routes
+
+import (
+	"net"
+
+	"github.com/gravitl/netclient/config"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// scopes of the routes to peer endpoints
+const (
+	// PeerRouteScopeHost - a host route per peer endpoint, the default
+	PeerRouteScopeHost = "host"
+	// PeerRouteScopeSubnet - endpoints sharing a subnet and gateway are routed through one covering route
+	PeerRouteScopeSubnet = "subnet"
+)
+
+// prefix lengths of the covering routes of the subnet scope
+const (
+	subnetRouteBits4 = 24
+	subnetRouteBits6 = 64
+)
+
+// endpointRoute - route to peer endpoints through a gateway
+type endpointRoute struct {
+	dst net.IPNet
+	gw  net.IP
+}
+
+// peerRouteScope - the configured scope of the peer endpoint routes, host unless subnet is set
+func peerRouteScope() string {
+	if config.Netclient().PeerEndpointRouteScope == PeerRouteScopeSubnet {
+		return PeerRouteScopeSubnet
+	}
+	return PeerRouteScopeHost
+}
+
+// peerEndpointRoutes - returns the routes to the endpoints of the peers through the gateway of their family
+// in the configured scope, endpoints skip reports true for are left out
+func peerEndpointRoutes(peers []wgtypes.PeerConfig, gw4, gw6 net.IP, skip func(net.IP) bool) []endpointRoute {
+	scope := peerRouteScope()
+	var onLink []net.IPNet
+	if scope == PeerRouteScopeSubnet {
+		onLink = onLinkSubnets()
+	}
+	return scopeEndpointRoutes(hostEndpointRoutes(peers, gw4, gw6, skip), scope, onLink)
+}
+
+// onLinkSubnets - returns the subnets of the addresses of the local interfaces
+func onLinkSubnets() []net.IPNet {
+	subnets := []net.IPNet{}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return subnets
+	}
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok {
+			subnets = append(subnets, net.IPNet{IP: ipNet.IP.Mask(ipNet.Mask), Mask: ipNet.Mask})
+		}
+	}
+	return subnets
+}
+
+// aggregatable - checks if the endpoint may be routed through a covering route, private, link-local and on-link
+// endpoints keep their host route so the covering route doesn't take over the traffic to a local network
+func aggregatable(ip net.IP, onLink []net.IPNet) bool {
+	if ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+		return false
+	}
+	for _, subnet := range onLink {
+		if subnet.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// overlapsAny - checks if the subnet overlaps any of the others
+func overlapsAny(subnet net.IPNet, others []net.IPNet) bool {
+	for _, other := range others {
+		if other.Contains(subnet.IP) || subnet.Contains(other.IP) {
+			return true
+		}
+	}
+	return false
+}
+
+// hostEndpointRoutes - returns a host route through the gateway of its family per distinct endpoint of the peers,
+// leaving out removed peers, peers without an endpoint and endpoints of a disabled family or a family without gateway
+func hostEndpointRoutes(peers []wgtypes.PeerConfig, gw4, gw6 net.IP, skip func(net.IP) bool) []endpointRoute {
+	routes := []endpointRoute{}
+	seen := map[string]bool{}
+	for _, peer := range peers {
+		if peer.Remove || peer.Endpoint == nil || peer.Endpoint.IP == nil || !familyEnabled(peer.Endpoint.IP) {
+			continue
+		}
+		ip := peer.Endpoint.IP
+		if skip != nil && skip(ip) {
+			continue
+		}
+		dst := net.IPNet{IP: ip.To4(), Mask: net.CIDRMask(32, 32)}
+		gw := gw4
+		if dst.IP == nil {
+			dst = net.IPNet{IP: ip.To16(), Mask: net.CIDRMask(128, 128)}
+			gw = gw6
+		}
+		if gw == nil {
+			continue
+		}
+		if seen[dst.String()] {
+			continue
+		}
+		seen[dst.String()] = true
+		routes = append(routes, endpointRoute{dst: dst, gw: gw})
+	}
+	return routes
+}
+
+// scopeEndpointRoutes - with the subnet scope, replaces the host routes of endpoints sharing a subnet and a gateway
+// with one covering route, endpoints alone in their subnet, endpoints that are not aggregatable and endpoints whose
+// covering route would overlap an on-link subnet keep their host route; routes keep the order they first
+// appear in, removing the returned routes removes the routes to all the endpoints
+func scopeEndpointRoutes(routes []endpointRoute, scope string, onLink []net.IPNet) []endpointRoute {
+	if scope != PeerRouteScopeSubnet {
+		return routes
+	}
+	type subnetRoutes struct {
+		subnet endpointRoute
+		hosts  []endpointRoute
+	}
+	order := []string{}
+	subnets := map[string]*subnetRoutes{}
+	for _, route := range routes {
+		mask := net.CIDRMask(subnetRouteBits4, 32)
+		if route.dst.IP.To4() == nil {
+			mask = net.CIDRMask(subnetRouteBits6, 128)
+		}
+		subnet := net.IPNet{IP: route.dst.IP.Mask(mask), Mask: mask}
+		if !aggregatable(route.dst.IP, onLink) || overlapsAny(subnet, onLink) {
+			subnet = route.dst
+		}
+		// only endpoints with the same next hop share a covering route
+		key := route.gw.String() + " " + subnet.String()
+		group, ok := subnets[key]
+		if !ok {
+			group = &subnetRoutes{subnet: endpointRoute{dst: subnet, gw: route.gw}}
+			subnets[key] = group
+			order = append(order, key)
+		}
+		group.hosts = append(group.hosts, route)
+	}
+	scoped := []endpointRoute{}
+	for _, key := range order {
+		group := subnets[key]
+		if len(group.hosts) > 1 {
+			scoped = append(scoped, group.subnet)
+			continue
+		}
+		scoped = append(scoped, group.hosts...)
+	}
+	return scoped
+}
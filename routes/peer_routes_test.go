@@ -0,0 +1,79 @@
+package routes
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestScopeEndpointRoutes(t *testing.T) {
+	gw := net.ParseIP("192.168.1.1")
+	gw6 := net.ParseIP("2001:db8:ffff::1")
+	// 10 subnets of 100 peers each, 20 peers alone in their subnet and an ipv6 pair
+	peers := []wgtypes.PeerConfig{}
+	addPeer := func(ip string) {
+		peers = append(peers, wgtypes.PeerConfig{Endpoint: &net.UDPAddr{IP: net.ParseIP(ip), Port: 51821}})
+	}
+	for subnet := 0; subnet < 10; subnet++ {
+		for host := 1; host <= 100; host++ {
+			addPeer(fmt.Sprintf("198.51.%d.%d", subnet, host))
+		}
+	}
+	for subnet := 0; subnet < 20; subnet++ {
+		addPeer(fmt.Sprintf("203.0.%d.10", subnet))
+	}
+	addPeer("2001:db8::1")
+	addPeer("2001:db8::2")
+	// a duplicate endpoint and a removed peer get no route of their own
+	addPeer("198.51.0.1")
+	peers = append(peers, wgtypes.PeerConfig{Endpoint: &net.UDPAddr{IP: net.ParseIP("100.64.0.1")}, Remove: true})
+
+	hostRoutes := hostEndpointRoutes(peers, gw, gw6, nil)
+	if len(hostRoutes) != 1022 {
+		t.Fatalf("expected a host route per endpoint, got %d routes", len(hostRoutes))
+	}
+	if scoped := scopeEndpointRoutes(hostRoutes, PeerRouteScopeHost, nil); len(scoped) != len(hostRoutes) {
+		t.Fatalf("expected the host scope to keep the host routes, got %d routes", len(scoped))
+	}
+	scoped := scopeEndpointRoutes(hostRoutes, PeerRouteScopeSubnet, nil)
+	if len(scoped) != 31 {
+		t.Fatalf("expected 10 subnet routes, 20 host routes and 1 ipv6 subnet route, got %d routes", len(scoped))
+	}
+	// every endpoint is still routed through its gateway
+	for _, host := range hostRoutes {
+		covered := 0
+		for _, route := range scoped {
+			if route.dst.Contains(host.dst.IP) {
+				covered++
+				if !route.gw.Equal(host.gw) {
+					t.Fatalf("endpoint %s routed through %s instead of %s", host.dst.IP, route.gw, host.gw)
+				}
+			}
+		}
+		if covered != 1 {
+			t.Fatalf("expected endpoint %s to be covered by one route, got %d", host.dst.IP, covered)
+		}
+	}
+
+	// endpoints of a subnet behind different gateways are not aggregated
+	other := endpointRoute{dst: net.IPNet{IP: net.ParseIP("198.51.0.200").To4(), Mask: net.CIDRMask(32, 32)}, gw: net.ParseIP("192.168.1.2")}
+	mixed := scopeEndpointRoutes([]endpointRoute{hostRoutes[0], other}, PeerRouteScopeSubnet, nil)
+	if len(mixed) != 2 || mixed[0].dst.String() != "198.51.0.1/32" || mixed[1].dst.String() != "198.51.0.200/32" {
+		t.Fatalf("expected host routes for endpoints with different gateways, got %v", mixed)
+	}
+
+	// private and on-link endpoints, and endpoints whose covering route overlaps an on-link subnet, keep their host routes
+	local := []endpointRoute{}
+	for _, ip := range []string{"192.168.1.10", "192.168.1.11", "198.51.200.10", "198.51.200.11", "198.51.201.10", "198.51.201.20"} {
+		local = append(local, endpointRoute{dst: net.IPNet{IP: net.ParseIP(ip).To4(), Mask: net.CIDRMask(32, 32)}, gw: gw})
+	}
+	onLink := []net.IPNet{
+		{IP: net.ParseIP("198.51.200.0").To4(), Mask: net.CIDRMask(24, 32)},
+		{IP: net.ParseIP("198.51.201.0").To4(), Mask: net.CIDRMask(28, 32)},
+	}
+	if scoped := scopeEndpointRoutes(local, PeerRouteScopeSubnet, onLink); len(scoped) != len(local) {
+		t.Fatalf("expected host routes for the private and on-link endpoints, got %v", scoped)
+	}
+}
@@ -0,0 +1,83 @@
+package routes
+
+import (
+	"fmt"
+	"net"
+)
+
+// ServerRouteState - routes of a server through the host's default gateway and any problems with them
+type ServerRouteState struct {
+	// Required - server routes are needed because an internet gateway is in use
+	Required  bool     `json:"required"`
+	Gateway   string   `json:"gateway,omitempty"`
+	Installed []string `json:"installed,omitempty"`
+	Failed    []string `json:"failed,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	Conflicts []string `json:"conflicts,omitempty"`
+}
+
+// serverRouteRecords - outcome of setting the routes of each server, guarded by serverRouteMU
+var serverRouteRecords = make(map[string]*serverRouteRecord)
+
+// serverRouteRecord - outcome of the last attempt to set the routes of a server
+type serverRouteRecord struct {
+	installed []net.IPNet
+	failed    []net.IPNet
+	failures  []string // reason each failed route could not be set
+	gateway   net.IP
+	err       error
+}
+
+// getServerRouteRecord - returns the record of the server, must be called with serverRouteMU held
+func getServerRouteRecord(server string) *serverRouteRecord {
+	record, ok := serverRouteRecords[server]
+	if !ok {
+		record = &serverRouteRecord{}
+		serverRouteRecords[server] = record
+	}
+	return record
+}
+
+// GetServerRouteState - reports the routes set for the server and conflicts with the host's current default route
+func GetServerRouteState(server string) ServerRouteState {
+	required := inetGatewaysInUse()
+	serverRouteMU.Lock()
+	record := serverRouteRecords[server]
+	var snapshot serverRouteRecord
+	if record != nil {
+		snapshot = *record
+	}
+	serverRouteMU.Unlock()
+	var currentGW net.IP
+	if required && snapshot.gateway != nil {
+		currentGW, _ = getDefaultGwIP(isIPv6(snapshot.gateway))
+	}
+	return buildServerRouteState(required, snapshot, currentGW)
+}
+
+// buildServerRouteState - builds the route state of a server from its record and the current default gateway
+func buildServerRouteState(required bool, record serverRouteRecord, currentGW net.IP) ServerRouteState {
+	state := ServerRouteState{Required: required}
+	if record.gateway != nil {
+		state.Gateway = record.gateway.String()
+	}
+	for _, route := range record.installed {
+		state.Installed = append(state.Installed, route.String())
+	}
+	for _, route := range record.failed {
+		state.Failed = append(state.Failed, route.String())
+		state.Conflicts = append(state.Conflicts,
+			fmt.Sprintf("route to server address %s could not be set, server traffic follows the internet gateway", route.String()))
+	}
+	if record.err != nil {
+		state.Error = record.err.Error()
+	}
+	if !required {
+		return state
+	}
+	if record.gateway != nil && currentGW != nil && !currentGW.Equal(record.gateway) {
+		state.Conflicts = append(state.Conflicts,
+			fmt.Sprintf("server routes use gateway %s but the host default gateway is %s", record.gateway, currentGW))
+	}
+	return state
+}
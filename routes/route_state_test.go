@@ -0,0 +1,73 @@
+package routes
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/gravitl/netclient/config"
+)
+
+func TestServerRouteState(t *testing.T) {
+	resetServerRoutes()
+	defer resetServerRoutes()
+	_, installed, _ := net.ParseCIDR("203.0.113.10/32")
+	_, failed, _ := net.ParseCIDR("203.0.113.11/32")
+	addServerRoute("netmaker.example.com", *installed)
+	addFailedServerRoute("netmaker.example.com", *failed, errors.New("network is unreachable"))
+	addServerRoute("other.example.com", *failed)
+
+	state := GetServerRouteState("netmaker.example.com")
+	if len(state.Installed) != 1 || state.Installed[0] != "203.0.113.10/32" {
+		t.Errorf("expected installed route to be reported, got %v", state.Installed)
+	}
+	if len(state.Failed) != 1 || len(state.Conflicts) != 1 || !strings.Contains(state.Conflicts[0], "203.0.113.11/32") {
+		t.Errorf("expected failed route to be reported as a conflict, got %+v", state)
+	}
+	if state := GetServerRouteState("unknown.example.com"); len(state.Installed)+len(state.Failed)+len(state.Conflicts) != 0 {
+		t.Errorf("expected empty state for unknown server, got %+v", state)
+	}
+}
+
+func TestServerRouteStateGatewayConflict(t *testing.T) {
+	_, route, _ := net.ParseCIDR("203.0.113.10/32")
+	record := serverRouteRecord{
+		installed: []net.IPNet{*route},
+		gateway:   net.ParseIP("192.168.1.1"),
+	}
+	if state := buildServerRouteState(true, record, net.ParseIP("192.168.1.1")); len(state.Conflicts) != 0 {
+		t.Errorf("expected no conflicts with unchanged gateway, got %v", state.Conflicts)
+	}
+	state := buildServerRouteState(true, record, net.ParseIP("10.10.0.1"))
+	if len(state.Conflicts) != 1 || !strings.Contains(state.Conflicts[0], "10.10.0.1") {
+		t.Errorf("expected conflict with the host default gateway, got %v", state.Conflicts)
+	}
+	record.err = errors.New("no gateway found")
+	if state := buildServerRouteState(true, record, nil); state.Error != "no gateway found" || !state.Required {
+		t.Errorf("expected route error to be reported, got %+v", state)
+	}
+}
+
+func TestServerRouteRecordReset(t *testing.T) {
+	resetServerRoutes()
+	defer resetServerRoutes()
+	defer resetInetGatewaySelection()
+	_, route, _ := net.ParseCIDR("203.0.113.10/32")
+	// a route set again and a failed route that is set on retry are recorded once, as installed
+	addFailedServerRoute("netmaker.example.com", *route, errors.New("network is unreachable"))
+	addServerRoute("netmaker.example.com", *route)
+	addServerRoute("netmaker.example.com", *route)
+	state := GetServerRouteState("netmaker.example.com")
+	if len(state.Installed) != 1 || len(state.Failed) != 0 || len(currentServerRoutes) != 1 {
+		t.Fatalf("expected the route to be recorded once as installed, got %+v", state)
+	}
+	// each attempt starts from a fresh record
+	SetInetGatewaysInUse(false, false)
+	if err := SetNetmakerServerRoutes("eth0", &config.Server{Name: "netmaker.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if state := GetServerRouteState("netmaker.example.com"); len(state.Installed)+len(state.Failed) != 0 {
+		t.Fatalf("expected the record of the previous attempt to be reset, got %+v", state)
+	}
+}
@@ -3,6 +3,7 @@ package routes
 import (
 	"fmt"
 	"net"
+	"strings"
 	"sync"
 
 	"github.com/gravitl/netclient/config"
@@ -20,26 +21,99 @@ var (
 	peerRouteMU         sync.Mutex
 	currentServerRoutes = []net.IPNet{} // list of current server IPs routed to default gateway
 	currentPeerRoutes   = []net.IPNet{} // list of current peer endpoint IPs routed to default gateway
-	defaultGWRoute      net.IP          // indicates the ip which traffic should be routed
+	gwRouteMU           sync.Mutex
+	defaultGWRoute      net.IP // indicates the ip which ipv4 traffic should be routed, guarded by gwRouteMU
+	defaultGWRoute6     net.IP // indicates the ip which ipv6 traffic should be routed, guarded by gwRouteMU
+	// resolveDefaultGw - looks up the host's current default gateway of a family
+	resolveDefaultGw = getDefaultGwIP
 )
 
 // HasGatewayChanged - informs called if the
 // gateway address has changed
 func HasGatewayChanged() bool {
-	if defaultGWRoute == nil {
-		return false
+	for _, v6 := range []bool{false, true} {
+		current := getGWRoute(v6)
+		if current == nil {
+			continue
+		}
+		gw, err := getDefaultGwIP(v6)
+		if err != nil {
+			continue
+		}
+		if !gw.Equal(current) {
+			return true
+		}
 	}
-	gw, err := getDefaultGwIP()
-	if err != nil {
-		return false
+	return false
+}
+
+// gwRouteOf - returns the gateway variable of the family, must be called with gwRouteMU held
+func gwRouteOf(v6 bool) *net.IP {
+	if v6 {
+		return &defaultGWRoute6
 	}
+	return &defaultGWRoute
+}
 
-	return !gw.Equal(defaultGWRoute)
+// getGWRoute - returns the gateway routes of the family are set through, nil until resolved
+func getGWRoute(v6 bool) net.IP {
+	gwRouteMU.Lock()
+	defer gwRouteMU.Unlock()
+	return *gwRouteOf(v6)
+}
+
+// resetGWRoute - forgets the resolved gateways so they are looked up again when routes are next set
+func resetGWRoute() {
+	gwRouteMU.Lock()
+	defer gwRouteMU.Unlock()
+	defaultGWRoute = nil
+	defaultGWRoute6 = nil
+}
+
+// setDefaultGatewayRoute - resolves the default gateway of the family once and returns it,
+// servers set up at the same time wait for and share the same gateway
+func setDefaultGatewayRoute(v6 bool) (net.IP, error) {
+	gwRouteMU.Lock()
+	defer gwRouteMU.Unlock()
+	route := gwRouteOf(v6)
+	if *route == nil {
+		gw, err := resolveDefaultGw(v6)
+		if err != nil {
+			return nil, err
+		}
+		if err = ensureNotNodeAddr(gw); err != nil {
+			return nil, err
+		}
+		*route = gw
+	}
+	return *route, nil
+}
+
+// setDefaultGatewayRoutes - resolves the default gateways of both families and returns those of the families
+// using an internet gateway, a family without a default gateway is left nil, it fails only if neither family has one
+func setDefaultGatewayRoutes() (gw4, gw6 net.IP, err error) {
+	gw4, err4 := setDefaultGatewayRoute(false)
+	gw6, err6 := setDefaultGatewayRoute(true)
+	if err4 != nil && err6 != nil {
+		return nil, nil, err4
+	}
+	if !inetGatewayInUse(false) {
+		gw4 = nil
+	}
+	if !inetGatewayInUse(true) {
+		gw6 = nil
+	}
+	return gw4, gw6, nil
+}
+
+// isIPv6 - checks if the address is an ipv6 address
+func isIPv6(ip net.IP) bool {
+	return ip != nil && ip.To4() == nil
 }
 
 // CleanUp - calls for client to clean routes of peers and servers
-func CleanUp(defaultInterface string, gwAddr *net.IPNet) error {
-	defer func() { defaultGWRoute = nil }()
+func CleanUp(defaultInterface string, gwAddrs ...*net.IPNet) error {
+	defer resetGWRoute()
 
 	if err := RemoveServerRoutes(defaultInterface); err != nil {
 		logger.Log(0, "error occurred when removing server routes -", err.Error())
@@ -47,24 +121,93 @@ func CleanUp(defaultInterface string, gwAddr *net.IPNet) error {
 	if err := RemovePeerRoutes(defaultInterface); err != nil {
 		logger.Log(0, "error occurred when removing peer routes -", err.Error())
 	}
-	if config.GW4PeerDetected || config.GW6PeerDetected {
-		if err := RemoveDefaultGW(gwAddr); err != nil {
-			logger.Log(0, "error occurred when removing default GW -", err.Error())
+	if inetGatewaysInUse() {
+		for _, gwAddr := range gwAddrs {
+			if err := RemoveDefaultGW(gwAddr); err != nil {
+				logger.Log(0, "error occurred when removing default GW -", err.Error())
+			}
 		}
 	}
 	return nil
 }
 
-func addServerRoute(route net.IPNet) {
+// SetNetmakerServerRoutes - sets necessary routes to servers through default gateway & peer endpoints,
+// recording the outcome for the server's route state, routes that could not be set are returned as one error
+func SetNetmakerServerRoutes(defaultInterface string, server *config.Server) error {
+	if server != nil {
+		// the record only reflects this attempt
+		serverRouteMU.Lock()
+		delete(serverRouteRecords, server.Name)
+		serverRouteMU.Unlock()
+	}
+	err := setNetmakerServerRoutes(defaultInterface, server)
+	if server != nil {
+		gw := getGWRoute(false)
+		if gw == nil {
+			gw = getGWRoute(true)
+		}
+		serverRouteMU.Lock()
+		record := getServerRouteRecord(server.Name)
+		if err == nil && len(record.failures) > 0 {
+			err = fmt.Errorf("failed to set server route(s) %s", strings.Join(record.failures, ", "))
+		}
+		record.err = err
+		record.gateway = gw
+		serverRouteMU.Unlock()
+	}
+	return err
+}
+
+// containsRoute - checks if the route is in the list
+func containsRoute(routes []net.IPNet, route net.IPNet) bool {
+	for i := range routes {
+		if routes[i].String() == route.String() {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutRoute - returns the list without the route
+func withoutRoute(routes []net.IPNet, route net.IPNet) []net.IPNet {
+	kept := []net.IPNet{}
+	for i := range routes {
+		if routes[i].String() != route.String() {
+			kept = append(kept, routes[i])
+		}
+	}
+	return kept
+}
+
+func addServerRoute(server string, route net.IPNet) {
+	serverRouteMU.Lock()
+	defer serverRouteMU.Unlock()
+	if !containsRoute(currentServerRoutes, route) {
+		currentServerRoutes = append(currentServerRoutes, route)
+	}
+	record := getServerRouteRecord(server)
+	record.failed = withoutRoute(record.failed, route)
+	if !containsRoute(record.installed, route) {
+		record.installed = append(record.installed, route)
+	}
+}
+
+func addFailedServerRoute(server string, route net.IPNet, err error) {
 	serverRouteMU.Lock()
 	defer serverRouteMU.Unlock()
-	currentServerRoutes = append(currentServerRoutes, route)
+	record := getServerRouteRecord(server)
+	record.installed = withoutRoute(record.installed, route)
+	if !containsRoute(record.failed, route) {
+		record.failed = append(record.failed, route)
+		record.failures = append(record.failures, fmt.Sprintf("%s: %v", route.String(), err))
+	}
 }
 
 func resetServerRoutes() {
 	serverRouteMU.Lock()
 	defer serverRouteMU.Unlock()
 	currentServerRoutes = []net.IPNet{}
+	serverRouteRecords = make(map[string]*serverRouteRecord)
 }
 
 func addPeerRoute(route net.IPNet) {
@@ -1,7 +1,6 @@
 package routes
 
 import (
-	"errors"
 	"fmt"
 	"net"
 	"strings"
@@ -13,9 +12,9 @@ import (
 	"github.com/vishvananda/netlink"
 )
 
-// SetNetmakerServerRoutes - sets necessary routes to servers through default gateway & peer endpoints
-func SetNetmakerServerRoutes(defaultInterface string, server *config.Server) error {
-	if !(config.GW4PeerDetected || config.GW6PeerDetected) {
+// setNetmakerServerRoutes - sets necessary routes to servers through default gateway & peer endpoints
+func setNetmakerServerRoutes(defaultInterface string, server *config.Server) error {
+	if !inetGatewaysInUse() {
 		// no internet gateway --- skip
 		return nil
 	}
@@ -28,43 +27,34 @@ func SetNetmakerServerRoutes(defaultInterface string, server *config.Server) err
 		return err
 	}
 
-	if err = setDefaultGatewayRoute(); err != nil {
-		if errors.Is(err, fmt.Errorf("no gateway found")) {
-			l, err := netlink.LinkByName(ncutils.GetInterfaceName())
-			if err == nil {
-				_ = netlink.RouteDel(&netlink.Route{
-					Dst:       nil,
-					LinkIndex: l.Attrs().Index,
-				})
-				if err = setDefaultGatewayRoute(); err != nil {
-					return err
-				}
-			} else {
-				return err
-			}
-		} else {
-			return err
-		}
-	}
-
 	addrs := networking.GetServerAddrs(server.Name)
 	for i := range addrs {
 		addr := addrs[i]
+		if !familyEnabled(addr.IP) {
+			continue
+		}
 		if addr.IP == nil {
 			continue
 		}
-		if addr.IP.IsPrivate() {
+		if addr.IP.IsPrivate() || !inetGatewayInUse(isIPv6(addr.IP)) {
+			continue
+		}
+		gw, err := setDefaultGatewayRoute(isIPv6(addr.IP))
+		if err != nil {
+			logger.Log(2, "no default gateway to route", addr.String(), "through", err.Error())
+			addFailedServerRoute(server.Name, addr, err)
 			continue
 		}
 		if err = netlink.RouteAdd(&netlink.Route{
 			Dst:       &addr,
 			LinkIndex: defaultLink.Attrs().Index,
-			Gw:        defaultGWRoute,
+			Gw:        gw,
 		}); err != nil && !strings.Contains(err.Error(), "file exists") {
-			logger.Log(2, "failed to set route", addr.String(), "to gw", defaultGWRoute.String())
+			logger.Log(2, "failed to set route", addr.String(), "to gw", gw.String(), err.Error())
+			addFailedServerRoute(server.Name, addr, err)
 			continue
 		}
-		addServerRoute(addr)
+		addServerRoute(server.Name, addr)
 		logger.Log(0, "added server route for interface", defaultInterface)
 	}
 
@@ -73,7 +63,7 @@ func SetNetmakerServerRoutes(defaultInterface string, server *config.Server) err
 
 // SetNetmakerPeerEndpointRoutes - set peer endpoint routes through original default interface
 func SetNetmakerPeerEndpointRoutes(defaultInterface string) error {
-	if !(config.GW4PeerDetected || config.GW6PeerDetected) {
+	if !inetGatewaysInUse() {
 		// no internet gateway --- skip
 		return nil
 	}
@@ -88,36 +78,22 @@ func SetNetmakerPeerEndpointRoutes(defaultInterface string) error {
 		return err
 	}
 
-	if err = setDefaultGatewayRoute(); err != nil {
+	gw4, gw6, err := setDefaultGatewayRoutes()
+	if err != nil {
 		return err
 	}
 
-	currentPeers := config.GetHostPeerList()
-	for i := range currentPeers {
-		peer := currentPeers[i]
-		if peer.Endpoint == nil {
-			continue
-		}
-		if peer.Endpoint.IP.IsPrivate() {
+	isPrivate := func(ip net.IP) bool { return ip.IsPrivate() }
+	for _, route := range peerEndpointRoutes(config.GetHostPeerList(), gw4, gw6, isPrivate) {
+		route := route
+		if err = netlink.RouteAdd(&netlink.Route{
+			Dst:       &route.dst,
+			LinkIndex: defaultLink.Attrs().Index,
+			Gw:        route.gw,
+		}); err != nil {
 			continue
 		}
-		if !peer.Remove && peer.Endpoint != nil {
-			mask := 32
-			if peer.Endpoint.IP.To4() == nil && peer.Endpoint.IP.To16() != nil {
-				mask = 128
-			}
-			_, cidr, err := net.ParseCIDR(fmt.Sprintf("%s/%d", peer.Endpoint.IP.String(), mask))
-			if err == nil && cidr != nil {
-				if err = netlink.RouteAdd(&netlink.Route{
-					Dst:       cidr,
-					LinkIndex: defaultLink.Attrs().Index,
-					Gw:        defaultGWRoute,
-				}); err != nil {
-					continue
-				}
-				addPeerRoute(*cidr)
-			}
-		}
+		addPeerRoute(route.dst)
 	}
 	return nil
 }
@@ -176,23 +152,23 @@ func RemovePeerRoutes(defaultInterface string) error {
 	return nil
 }
 
-// SetDefaultGateway - sets netmaker as the default gateway
+// SetDefaultGateway - sets netmaker as the default gateway of the family of the gateway address
 func SetDefaultGateway(gwAddress *net.IPNet) error {
-	if defaultGWRoute == nil {
-		return fmt.Errorf("old gateway not found, can not set default gateway")
-	}
-
 	if gwAddress == nil || gwAddress.IP == nil {
 		return nil
 	}
 
+	if getGWRoute(isIPv6(gwAddress.IP)) == nil {
+		return fmt.Errorf("old gateway not found, can not set default gateway")
+	}
+
 	netmakerLink, err := netlink.LinkByName(ncutils.GetInterfaceName())
 	if err != nil {
 		return err
 	}
 
 	return netlink.RouteAdd(&netlink.Route{
-		Dst:       nil,
+		Dst:       defaultDst(gwAddress.IP),
 		Gw:        gwAddress.IP,
 		LinkIndex: netmakerLink.Attrs().Index,
 	})
@@ -210,32 +186,26 @@ func RemoveDefaultGW(gwAddress *net.IPNet) error {
 	}
 
 	return netlink.RouteDel(&netlink.Route{
-		Dst:       nil,
+		Dst:       defaultDst(gwAddress.IP),
 		Gw:        gwAddress.IP,
 		LinkIndex: src.Attrs().Index,
 	})
 }
 
-func setDefaultGatewayRoute() error {
-	if defaultGWRoute == nil {
-		gw, err := getDefaultGwIP()
-		if err != nil {
-			return err
-		}
-		if err = ensureNotNodeAddr(gw); err != nil {
-			return err
-		}
-		defaultGWRoute = gw
+// defaultDst - the default route destination of the family of the address
+func defaultDst(ip net.IP) *net.IPNet {
+	if isIPv6(ip) {
+		return &net.IPNet{IP: net.IPv6zero, Mask: net.CIDRMask(0, 128)}
 	}
-	return nil
+	return &net.IPNet{IP: net.IPv4zero.To4(), Mask: net.CIDRMask(0, 32)}
 }
 
-func getDefaultGwIP() (net.IP, error) {
-	routes, err := netlink.RouteGet(net.ParseIP("1.1.1.1"))
+func getDefaultGwIP(v6 bool) (net.IP, error) {
+	routes, err := netlink.RouteGet(defaultRouteProbe(v6))
 	if err != nil {
 		return nil, err
 	}
-	if routes[0].Gw == nil {
+	if len(routes) == 0 || routes[0].Gw == nil {
 		return nil, fmt.Errorf("no gateway found")
 	}
 	return routes[0].Gw, nil
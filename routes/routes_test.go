@@ -0,0 +1,127 @@
+package routes
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gravitl/netclient/config"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestConcurrentServerRoutes(t *testing.T) {
+	var lookups int32
+	resolveDefaultGw = func(bool) (net.IP, error) {
+		atomic.AddInt32(&lookups, 1)
+		time.Sleep(10 * time.Millisecond)
+		return net.ParseIP("192.0.2.1"), nil
+	}
+	defer func() {
+		resolveDefaultGw = getDefaultGwIP
+		resetGWRoute()
+		resetServerRoutes()
+	}()
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			server := &config.Server{}
+			server.Name = fmt.Sprintf("server%d.example.com", i)
+			gw, err := setDefaultGatewayRoute(false)
+			if err != nil || !gw.Equal(net.ParseIP("192.0.2.1")) {
+				t.Errorf("unexpected gateway %s, %v", gw, err)
+				return
+			}
+			_ = SetNetmakerServerRoutes("", server)
+			addServerRoute(server.Name, net.IPNet{IP: net.IPv4(203, 0, 113, byte(i)), Mask: net.CIDRMask(32, 32)})
+			_ = GetServerRouteState(server.Name)
+		}(i)
+	}
+	wg.Wait()
+	if lookups != 1 {
+		t.Fatalf("expected the gateway to be looked up once, got %d lookups", lookups)
+	}
+	for i := 0; i < 8; i++ {
+		state := GetServerRouteState(fmt.Sprintf("server%d.example.com", i))
+		if state.Gateway != "192.0.2.1" || len(state.Installed) != 1 {
+			t.Fatalf("unexpected route state %+v", state)
+		}
+	}
+}
+
+func TestIPv6OnlyGateway(t *testing.T) {
+	gw6 := net.ParseIP("fe80::1")
+	resolveDefaultGw = func(v6 bool) (net.IP, error) {
+		if !v6 {
+			return nil, errors.New("no gateway found")
+		}
+		return gw6, nil
+	}
+	SetInetGatewaysInUse(false, true)
+	defer func() {
+		resolveDefaultGw = getDefaultGwIP
+		resetGWRoute()
+		resetInetGatewaySelection()
+	}()
+	gw4, gw, err := setDefaultGatewayRoutes()
+	if err != nil || gw4 != nil || !gw.Equal(gw6) {
+		t.Fatalf("expected only the ipv6 gateway, got %s %s %v", gw4, gw, err)
+	}
+	if _, err := setDefaultGatewayRoute(false); err == nil {
+		t.Fatal("expected no ipv4 gateway")
+	}
+	peers := []wgtypes.PeerConfig{
+		{Endpoint: &net.UDPAddr{IP: net.ParseIP("198.51.100.10"), Port: 51821}},
+		{Endpoint: &net.UDPAddr{IP: net.ParseIP("2001:db8::10"), Port: 51821}},
+	}
+	routes := peerEndpointRoutes(peers, gw4, gw, nil)
+	if len(routes) != 1 || routes[0].dst.String() != "2001:db8::10/128" || !routes[0].gw.Equal(gw6) {
+		t.Fatalf("expected only the ipv6 endpoint to be routed through the ipv6 gateway, got %v", routes)
+	}
+	if dst := defaultRouteProbe(true); !isIPv6(dst) {
+		t.Fatalf("expected the ipv6 default route to be looked up with an ipv6 address, got %s", dst)
+	}
+}
+
+func TestInetGatewaysInUse(t *testing.T) {
+	resolveDefaultGw = func(v6 bool) (net.IP, error) {
+		if v6 {
+			return net.ParseIP("fe80::1"), nil
+		}
+		return net.ParseIP("192.0.2.1"), nil
+	}
+	defer func() {
+		resolveDefaultGw = getDefaultGwIP
+		resetGWRoute()
+		resetInetGatewaySelection()
+	}()
+	SetInetGatewaysInUse(false, true)
+	gw4, gw6, err := setDefaultGatewayRoutes()
+	if err != nil || gw4 != nil || gw6 == nil {
+		t.Fatalf("expected only the gateway of the family in use, got %s %s %v", gw4, gw6, err)
+	}
+	// the gateway of the other family is still resolved so its default route can be switched later
+	if getGWRoute(false) == nil {
+		t.Fatal("expected the ipv4 gateway to be resolved")
+	}
+	SetInetGatewaysInUse(true, true)
+	if gw4, gw6, _ = setDefaultGatewayRoutes(); gw4 == nil || gw6 == nil {
+		t.Fatalf("expected the gateways of both families, got %s %s", gw4, gw6)
+	}
+	SetInetGatewaysInUse(false, false)
+	if inetGatewaysInUse() {
+		t.Fatal("expected no internet gateway in use")
+	}
+}
+
+// resetInetGatewaySelection - forgets the selected internet gateways
+func resetInetGatewaySelection() {
+	inetGWMU.Lock()
+	defer inetGWMU.Unlock()
+	inetGWSelected, inetGW4InUse, inetGW6InUse = false, false, false
+}
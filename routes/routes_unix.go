@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"net"
 	"os/exec"
+	"strings"
 
 	"github.com/gravitl/netclient/config"
 	"github.com/gravitl/netclient/networking"
@@ -15,9 +16,9 @@ import (
 	"golang.org/x/net/route"
 )
 
-// SetNetmakerServerRoutes - sets necessary routes to servers through default gateway & peer endpoints
-func SetNetmakerServerRoutes(defaultInterface string, server *config.Server) error {
-	if !(config.GW4PeerDetected || config.GW6PeerDetected) {
+// setNetmakerServerRoutes - sets necessary routes to servers through default gateway & peer endpoints
+func setNetmakerServerRoutes(defaultInterface string, server *config.Server) error {
+	if !inetGatewaysInUse() {
 		// no internet gateway --- skip
 		return nil
 	}
@@ -28,29 +29,36 @@ func SetNetmakerServerRoutes(defaultInterface string, server *config.Server) err
 	if err != nil {
 		return errors.New("failed to get default interface: " + err.Error())
 	}
-	if err = setDefaultGatewayRoute(); err != nil {
-		return err
-	}
-
 	addrs := networking.GetServerAddrs(server.Name)
 	for i := range addrs {
 		addr := addrs[i]
+		if !familyEnabled(addr.IP) || !inetGatewayInUse(isIPv6(addr.IP)) {
+			continue
+		}
 		if addr.IP != nil {
+			gw, err := setDefaultGatewayRoute(isIPv6(addr.IP))
+			if err != nil {
+				logger.Log(0, "no default gateway to route", addr.String(), "through", err.Error())
+				addFailedServerRoute(server.Name, addr, err)
+				continue
+			}
 			if addr.IP.To4() != nil {
-				cmd := exec.Command("route", "-n", "add", "-net", "-inet", addr.String(), defaultGWRoute.String())
+				cmd := exec.Command("route", "-n", "add", "-net", "-inet", addr.String(), gw.String())
 				if out, err := cmd.CombinedOutput(); err != nil {
 					logger.Log(0, fmt.Sprintf("failed to add route with command %s - %v", cmd.String(), out))
+					addFailedServerRoute(server.Name, addr, fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out))))
 					continue
 				}
 			} else {
-				cmd := exec.Command("route", "-n", "add", "-net", "-inet6", addr.String(), defaultGWRoute.String())
+				cmd := exec.Command("route", "-n", "add", "-net", "-inet6", addr.String(), gw.String())
 				if out, err := cmd.CombinedOutput(); err != nil {
 					logger.Log(0, fmt.Sprintf("failed to add route with command %s - %v", cmd.String(), out))
+					addFailedServerRoute(server.Name, addr, fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out))))
 					continue
 				}
 			}
 		}
-		addServerRoute(addr)
+		addServerRoute(server.Name, addr)
 		logger.Log(0, "added server route for interface", defaultInterface)
 	}
 
@@ -59,7 +67,7 @@ func SetNetmakerServerRoutes(defaultInterface string, server *config.Server) err
 
 // SetNetmakerPeerEndpointRoutes - set peer endpoint routes through original default interface
 func SetNetmakerPeerEndpointRoutes(defaultInterface string) error {
-	if !(config.GW4PeerDetected || config.GW6PeerDetected) {
+	if !inetGatewaysInUse() {
 		// no internet gateway --- skip
 		return nil
 	}
@@ -74,38 +82,22 @@ func SetNetmakerPeerEndpointRoutes(defaultInterface string) error {
 		return errors.New("failed to get default interface: " + err.Error())
 	}
 
-	if err = setDefaultGatewayRoute(); err != nil {
+	gw4, gw6, err := setDefaultGatewayRoutes()
+	if err != nil {
 		return err
 	}
 
-	currentPeers := config.GetHostPeerList()
-	for i := range currentPeers {
-		peer := currentPeers[i]
-		if !peer.Remove && peer.Endpoint != nil {
-			mask := 32
-			if peer.Endpoint.IP.To4() == nil && peer.Endpoint.IP.To16() != nil {
-				mask = 128
-			}
-			_, cidr, err := net.ParseCIDR(fmt.Sprintf("%s/%d", peer.Endpoint.IP.String(), mask))
-			if err == nil && cidr != nil {
-				if cidr.IP != nil {
-					if cidr.IP.To4() != nil {
-						cmd := exec.Command("route", "-n", "add", "-net", "-inet", cidr.String(), defaultGWRoute.String())
-						if out, err := cmd.CombinedOutput(); err != nil {
-							logger.Log(0, fmt.Sprintf("failed to add route with command %s - %v", cmd.String(), out))
-							continue
-						}
-					} else {
-						cmd := exec.Command("route", "-n", "add", "-net", "-inet6", cidr.String(), defaultGWRoute.String())
-						if out, err := cmd.CombinedOutput(); err != nil {
-							logger.Log(0, fmt.Sprintf("failed to add route with command %s - %v", cmd.String(), out))
-							continue
-						}
-					}
-				}
-				addPeerRoute(*cidr)
-			}
+	for _, route := range peerEndpointRoutes(config.GetHostPeerList(), gw4, gw6, nil) {
+		family := "-inet"
+		if route.dst.IP.To4() == nil {
+			family = "-inet6"
+		}
+		cmd := exec.Command("route", "-n", "add", "-net", family, route.dst.String(), route.gw.String())
+		if out, err := cmd.CombinedOutput(); err != nil {
+			logger.Log(0, fmt.Sprintf("failed to add route with command %s - %v", cmd.String(), out))
+			continue
 		}
+		addPeerRoute(route.dst)
 	}
 	return nil
 }
@@ -180,13 +172,13 @@ func RemovePeerRoutes(defaultInterface string) error {
 
 // SetDefaultGateway - sets netmaker as the default gateway
 func SetDefaultGateway(gwAddress *net.IPNet) error {
-	if defaultGWRoute == nil {
-		return fmt.Errorf("old gateway not found, can not set default gateway")
-	}
 	if gwAddress == nil || gwAddress.IP == nil {
 		return nil
 	}
-	cmd := exec.Command("route", "change", "default", gwAddress.IP.String())
+	if getGWRoute(isIPv6(gwAddress.IP)) == nil {
+		return fmt.Errorf("old gateway not found, can not set default gateway")
+	}
+	cmd := exec.Command("route", "change", routeFamily(gwAddress.IP), "default", gwAddress.IP.String())
 	if out, err := cmd.CombinedOutput(); err != nil {
 		logger.Log(1, fmt.Sprintf("failed to add default gateway with command %s - %v", cmd.String(), string(out)))
 		return err
@@ -196,16 +188,20 @@ func SetDefaultGateway(gwAddress *net.IPNet) error {
 
 // RemoveDefaultGW - removes the default gateway
 func RemoveDefaultGW(gwAddress *net.IPNet) error {
-	if defaultGWRoute == nil || (gwAddress == nil || gwAddress.IP == nil) {
+	if gwAddress == nil || gwAddress.IP == nil {
+		return nil
+	}
+	gw := getGWRoute(isIPv6(gwAddress.IP))
+	if gw == nil {
 		return nil
 	}
 	// == best effort to reset on mac ==
-	cmd := exec.Command("route", "change", "default", defaultGWRoute.String())
+	cmd := exec.Command("route", "change", routeFamily(gw), "default", gw.String())
 	if out, err := cmd.CombinedOutput(); err != nil {
 		logger.Log(2, fmt.Sprintf("failed to change default gateway with command %s - %v", cmd.String(), string(out)))
 		return err
 	}
-	cmd = exec.Command("route", "add", "default", defaultGWRoute.String())
+	cmd = exec.Command("route", "add", routeFamily(gw), "default", gw.String())
 	if out, err := cmd.CombinedOutput(); err != nil {
 		logger.Log(2, fmt.Sprintf("failed to add default gateway with command %s - %v", cmd.String(), string(out)))
 		return err
@@ -213,21 +209,15 @@ func RemoveDefaultGW(gwAddress *net.IPNet) error {
 	return nil
 }
 
-func setDefaultGatewayRoute() error {
-	if defaultGWRoute == nil {
-		ip, err := getDefaultGwIP()
-		if err != nil {
-			return err
-		}
-		if err = ensureNotNodeAddr(ip); err != nil {
-			return err
-		}
-		defaultGWRoute = ip
+// routeFamily - the route command option selecting the family of the address
+func routeFamily(ip net.IP) string {
+	if isIPv6(ip) {
+		return "-inet6"
 	}
-	return nil
+	return "-inet"
 }
 
-func getDefaultGwIP() (net.IP, error) {
+func getDefaultGwIP(v6 bool) (net.IP, error) {
 	rib, _ := route.FetchRIB(0, route.RIBTypeRoute, 0)
 	messages, err := route.ParseRIB(route.RIBTypeRoute, rib)
 	if err != nil {
@@ -239,7 +229,10 @@ func getDefaultGwIP() (net.IP, error) {
 		if len(addresses) < 2 {
 			continue
 		}
-		if gateway, ok := addresses[1].(*route.Inet4Addr); ok {
+		if gateway, ok := addresses[1].(*route.Inet4Addr); ok && !v6 {
+			return net.IP(gateway.IP[:]), nil
+		}
+		if gateway, ok := addresses[1].(*route.Inet6Addr); ok && v6 {
 			return net.IP(gateway.IP[:]), nil
 		}
 	}
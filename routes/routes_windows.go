@@ -10,9 +10,9 @@ import (
 	"github.com/gravitl/netmaker/logger"
 )
 
-// SetNetmakerServerRoutes - sets necessary routes to servers through default gateway & peer endpoints
-func SetNetmakerServerRoutes(defaultInterface string, server *config.Server) error {
-	if !(config.GW4PeerDetected || config.GW6PeerDetected) {
+// setNetmakerServerRoutes - sets necessary routes to servers through default gateway & peer endpoints
+func setNetmakerServerRoutes(defaultInterface string, server *config.Server) error {
+	if !inetGatewaysInUse() {
 		// no internet gateway --- skip
 		return nil
 	}
@@ -25,22 +25,27 @@ func SetNetmakerServerRoutes(defaultInterface string, server *config.Server) err
 		return err
 	}
 
-	if err = setDefaultGatewayRoute(); err != nil {
-		return err
-	}
-
 	addrs := networking.GetServerAddrs(server.Name)
 	for i := range addrs {
 		addr := addrs[i]
+		if !familyEnabled(addr.IP) || !inetGatewayInUse(isIPv6(addr.IP)) {
+			continue
+		}
+		gw, err := setDefaultGatewayRoute(isIPv6(addr.IP))
+		if err != nil {
+			addFailedServerRoute(server.Name, addr, err)
+			continue
+		}
 		mask := net.IP(addr.Mask)
 		cmd := fmt.Sprintf("route -p add %s MASK %v %s", addr.IP.String(),
 			mask,
-			defaultGWRoute.String())
-		_, err := ncutils.RunCmd(cmd, false)
+			gw.String())
+		_, err = ncutils.RunCmd(cmd, false)
 		if err != nil {
+			addFailedServerRoute(server.Name, addr, err)
 			continue
 		}
-		addServerRoute(addr)
+		addServerRoute(server.Name, addr)
 	}
 
 	return nil
@@ -48,7 +53,7 @@ func SetNetmakerServerRoutes(defaultInterface string, server *config.Server) err
 
 // SetNetmakerPeerEndpointRoutes - set peer endpoint routes through original default interface
 func SetNetmakerPeerEndpointRoutes(defaultInterface string) error {
-	if !(config.GW4PeerDetected || config.GW6PeerDetected) {
+	if !inetGatewaysInUse() {
 		// no internet gateway --- skip
 		return nil
 	}
@@ -63,31 +68,20 @@ func SetNetmakerPeerEndpointRoutes(defaultInterface string) error {
 		return err
 	}
 
-	if err = setDefaultGatewayRoute(); err != nil {
+	gw4, gw6, err := setDefaultGatewayRoutes()
+	if err != nil {
 		return err
 	}
 
-	currentPeers := config.GetHostPeerList()
-	for i := range currentPeers {
-		peer := currentPeers[i]
-		if !peer.Remove && peer.Endpoint != nil {
-			mask := 32
-			if peer.Endpoint.IP.To4() == nil && peer.Endpoint.IP.To16() != nil {
-				mask = 128
-			}
-			_, cidr, err := net.ParseCIDR(fmt.Sprintf("%s/%d", peer.Endpoint.IP.String(), mask))
-			if err == nil && cidr != nil {
-				mask := net.IP(cidr.Mask)
-				cmd := fmt.Sprintf("route -p add %s MASK %v %s", cidr.IP.String(),
-					mask,
-					defaultGWRoute.String())
-				_, err := ncutils.RunCmd(cmd, false)
-				if err != nil {
-					return err
-				}
-				addPeerRoute(*cidr)
-			}
+	for _, route := range peerEndpointRoutes(config.GetHostPeerList(), gw4, gw6, nil) {
+		cmd := fmt.Sprintf("route -p add %s MASK %v %s", route.dst.IP.String(),
+			net.IP(route.dst.Mask),
+			route.gw.String())
+		_, err := ncutils.RunCmd(cmd, false)
+		if err != nil {
+			return err
 		}
+		addPeerRoute(route.dst)
 	}
 	return nil
 }
@@ -144,21 +138,25 @@ func RemovePeerRoutes(defaultInterface string) error {
 
 // SetDefaultGateway - sets netmaker as the default gateway
 func SetDefaultGateway(gwAddress *net.IPNet) error {
-	if defaultGWRoute == nil {
-		return fmt.Errorf("old gateway not found, can not set default gateway")
-	}
-
 	if gwAddress == nil || gwAddress.IP == nil {
 		return nil
 	}
 
+	if isIPv6(gwAddress.IP) {
+		return errNoIPv6Gateway
+	}
+	gw := getGWRoute(false)
+	if gw == nil {
+		return fmt.Errorf("old gateway not found, can not set default gateway")
+	}
+
 	cmd := fmt.Sprintf("route add 0.0.0.0 mask 0.0.0.0 %s metric 2", gwAddress.IP.String())
 	_, err := ncutils.RunCmd(cmd, false)
 	if err != nil {
 		return err
 	}
 
-	cmd = fmt.Sprintf("route delete 0.0.0.0 mask 0.0.0.0 %s", defaultGWRoute.String())
+	cmd = fmt.Sprintf("route delete 0.0.0.0 mask 0.0.0.0 %s", gw.String())
 	_, err = ncutils.RunCmd(cmd, false)
 	if err != nil {
 		return err
@@ -173,10 +171,14 @@ func RemoveDefaultGW(gwAddress *net.IPNet) error {
 		return nil
 	}
 
-	cmd := fmt.Sprintf("route add 0.0.0.0 mask 0.0.0.0 %s metric 26", defaultGWRoute.String())
+	if isIPv6(gwAddress.IP) {
+		return errNoIPv6Gateway
+	}
+	gw := getGWRoute(false)
+	cmd := fmt.Sprintf("route add 0.0.0.0 mask 0.0.0.0 %s metric 26", gw.String())
 	out, err := ncutils.RunCmd(cmd, false)
 	if err != nil {
-		logger.Log(0, "failed to add default gateway route", defaultGWRoute.String(), err.Error(), out)
+		logger.Log(0, "failed to add default gateway route", gw.String(), err.Error(), out)
 		return err
 	}
 
@@ -190,20 +192,9 @@ func RemoveDefaultGW(gwAddress *net.IPNet) error {
 	return nil
 }
 
-func setDefaultGatewayRoute() error {
-	if defaultGWRoute == nil {
-		gw, err := getWindowsGateway()
-		if err != nil {
-			return err
-		}
-		if err = ensureNotNodeAddr(gw); err != nil {
-			return err
-		}
-		defaultGWRoute = gw
+func getDefaultGwIP(v6 bool) (net.IP, error) {
+	if v6 {
+		return nil, errNoIPv6Gateway
 	}
-	return nil
-}
-
-func getDefaultGwIP() (net.IP, error) {
 	return getWindowsGateway()
 }
@@ -11,6 +11,8 @@ import (
 var (
 	errCantParse = fmt.Errorf("can't parse")
 	errNoGateway = fmt.Errorf("no gateway")
+	// errNoIPv6Gateway - ipv6 default gateways are not managed on windows
+	errNoIPv6Gateway = fmt.Errorf("ipv6 default gateway is not supported on windows")
 )
 
 type windowsCmdRoute struct {
@@ -0,0 +1,96 @@
+package wireguard
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netmaker/logger"
+)
+
+// minIfaceMTU - smallest MTU the interface is clamped to, the minimum MTU of ipv6
+const minIfaceMTU = 1280
+
+// IfaceMTU - the MTU configured for the netmaker interface and the MTU the interface accepted
+type IfaceMTU struct {
+	Configured int    `json:"configured"`
+	Effective  int    `json:"effective"`
+	Error      string `json:"error,omitempty"`
+}
+
+// ifaceMTUSetter - sets the MTU of the netmaker interface
+type ifaceMTUSetter func(mtu int) error
+
+var (
+	ifaceMTU      IfaceMTU
+	ifaceMTUMutex sync.Mutex
+)
+
+// GetIfaceMTU - returns the MTU configured for the netmaker interface and the MTU applied on the last configure
+func GetIfaceMTU() IfaceMTU {
+	ifaceMTUMutex.Lock()
+	defer ifaceMTUMutex.Unlock()
+	return ifaceMTU
+}
+
+// effectiveMTU - the MTU applied to the netmaker interface, the configured MTU until the interface is configured
+func effectiveMTU() int {
+	if mtu := GetIfaceMTU(); mtu.Effective != 0 {
+		return mtu.Effective
+	}
+	return config.Netclient().MTU
+}
+
+// NCIface.applyMTU - sets the configured MTU of the interface, clamping it to the largest MTU the interface
+// accepts if it is rejected, unless StrictMTU is set
+func (n *NCIface) applyMTU() error {
+	configured := n.MTU
+	set := func(mtu int) error {
+		n.MTU = mtu
+		return n.SetMTU()
+	}
+	result, err := clampMTU(configured, set, config.Netclient().StrictMTU)
+	ifaceMTUMutex.Lock()
+	ifaceMTU = result
+	ifaceMTUMutex.Unlock()
+	if err != nil {
+		n.MTU = configured
+		return err
+	}
+	n.MTU = result.Effective
+	return nil
+}
+
+// clampMTU - sets the configured MTU, on failure probes downward for the largest MTU the interface accepts and
+// applies it, when strict or no MTU is accepted the error setting the configured MTU is returned
+func clampMTU(configured int, set ifaceMTUSetter, strict bool) (IfaceMTU, error) {
+	result := IfaceMTU{Configured: configured}
+	err := set(configured)
+	if err == nil {
+		result.Effective = configured
+		return result, nil
+	}
+	result.Error = err.Error()
+	if strict || configured <= minIfaceMTU || set(minIfaceMTU) != nil {
+		logger.Log(0, "failed to set interface MTU", strconv.Itoa(configured), err.Error())
+		return result, fmt.Errorf("failed to set interface MTU %d: %w", configured, err)
+	}
+	low, high := minIfaceMTU, configured
+	for high-low > 1 {
+		mid := (low + high) / 2
+		if set(mid) == nil {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+	// leave the interface at the largest accepted MTU rather than the last one probed
+	if err := set(low); err != nil {
+		result.Error = err.Error()
+		return result, fmt.Errorf("failed to set interface MTU %d: %w", low, err)
+	}
+	result.Effective = low
+	logger.Log(0, "interface rejected MTU", strconv.Itoa(configured), "-", err.Error(), "- effective MTU is", strconv.Itoa(low))
+	return result, nil
+}
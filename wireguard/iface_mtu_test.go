@@ -0,0 +1,40 @@
+package wireguard
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestClampMTU(t *testing.T) {
+	// the interface rejects MTUs above 1400
+	applied := 0
+	set := func(mtu int) error {
+		if mtu > 1400 {
+			return errors.New("invalid argument")
+		}
+		applied = mtu
+		return nil
+	}
+
+	result, err := clampMTU(1420, set, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Effective != 1400 || applied != 1400 || result.Configured != 1420 || result.Error == "" {
+		t.Fatalf("expected the MTU to be clamped to 1400, got %+v with %d applied", result, applied)
+	}
+
+	applied = 0
+	if result, err = clampMTU(1380, set, false); err != nil || result.Effective != 1380 || applied != 1380 {
+		t.Fatalf("expected an accepted MTU to be applied as is, got %+v, %v", result, err)
+	}
+
+	applied = 0
+	if _, err = clampMTU(1420, set, true); err == nil || applied != 0 {
+		t.Fatalf("expected strict mode to fail without clamping, got %v with %d applied", err, applied)
+	}
+
+	if _, err = clampMTU(1420, func(int) error { return errors.New("no such device") }, false); err == nil {
+		t.Fatal("expected error when the interface accepts no MTU")
+	}
+}
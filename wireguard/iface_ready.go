@@ -0,0 +1,70 @@
+package wireguard
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/gravitl/netclient/config"
+)
+
+const (
+	// defaultIfaceUpWait - seconds to wait for the interface to be ready, when not configured
+	defaultIfaceUpWait = 10
+	// ifaceReadyPoll - interval the interface state is checked at while waiting for it to be ready
+	ifaceReadyPoll = 100 * time.Millisecond
+)
+
+var errIfaceNotReady = errors.New("interface is not ready")
+
+// NCIface.WaitReady - waits for the interface to be up with the addresses of the nodes assigned,
+// up to the configured interface up wait
+func (nc *NCIface) WaitReady() error {
+	wait := config.Netclient().IfaceUpWait
+	if wait <= 0 {
+		wait = defaultIfaceUpWait
+	}
+	return waitReady(func() bool {
+		return ifaceReady(nc.Name, nc.Addresses)
+	}, time.Duration(wait)*time.Second, ifaceReadyPoll)
+}
+
+// ifaceReady - checks the interface is up and has all the given addresses assigned
+func ifaceReady(name string, addrs []ifaceAddress) bool {
+	iface, err := net.InterfaceByName(name)
+	if err != nil || iface.Flags&net.FlagUp == 0 {
+		return false
+	}
+	assigned, err := iface.Addrs()
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		if !addrAssigned(addr.IP, assigned) {
+			return false
+		}
+	}
+	return true
+}
+
+// addrAssigned - checks if the ip is one of the assigned interface addresses
+func addrAssigned(ip net.IP, assigned []net.Addr) bool {
+	for _, a := range assigned {
+		if ipNet, ok := a.(*net.IPNet); ok && ipNet.IP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// waitReady - polls ready until it reports true, returns errIfaceNotReady if it doesn't within the timeout
+func waitReady(ready func() bool, timeout, poll time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for !ready() {
+		if time.Now().After(deadline) {
+			return errIfaceNotReady
+		}
+		time.Sleep(poll)
+	}
+	return nil
+}
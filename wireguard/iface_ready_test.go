@@ -0,0 +1,42 @@
+package wireguard
+
+import (
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWaitReady(t *testing.T) {
+	t.Run("ready after delay", func(t *testing.T) {
+		var up, polls int32
+		time.AfterFunc(30*time.Millisecond, func() { atomic.StoreInt32(&up, 1) })
+		ready := func() bool {
+			atomic.AddInt32(&polls, 1)
+			return atomic.LoadInt32(&up) == 1
+		}
+		if err := waitReady(ready, time.Second, 5*time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+		if atomic.LoadInt32(&polls) < 2 {
+			t.Fatalf("expected the interface to be polled until ready, polled %d times", polls)
+		}
+	})
+	t.Run("never ready", func(t *testing.T) {
+		err := waitReady(func() bool { return false }, 20*time.Millisecond, 5*time.Millisecond)
+		if !errors.Is(err, errIfaceNotReady) {
+			t.Fatalf("expected errIfaceNotReady, got %v", err)
+		}
+	})
+}
+
+func TestAddrAssigned(t *testing.T) {
+	assigned := []net.Addr{&net.IPNet{IP: net.ParseIP("10.0.0.2"), Mask: net.CIDRMask(24, 32)}}
+	if !addrAssigned(net.ParseIP("10.0.0.2"), assigned) {
+		t.Error("expected 10.0.0.2 to be assigned")
+	}
+	if addrAssigned(net.ParseIP("fd00::2"), assigned) {
+		t.Error("expected fd00::2 not to be assigned")
+	}
+}
@@ -0,0 +1,51 @@
+package wireguard
+
+import (
+	"time"
+
+	"github.com/gravitl/netclient/config"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// applyServerKeepalive - sets the persistent keepalive the server configured for the network of a peer
+// on peers without their own keepalive interval, a keepalive set on the peer itself is kept
+func applyServerKeepalive(peers []wgtypes.PeerConfig, nodes config.NodeMap) {
+	for i := range peers {
+		if peers[i].PersistentKeepaliveInterval != nil {
+			continue
+		}
+		if keepalive, ok := networkKeepalive(peers[i], nodes); ok {
+			peers[i].PersistentKeepaliveInterval = &keepalive
+		}
+	}
+}
+
+// networkKeepalive - the keepalive of the networks the peer belongs to, going by its allowed ips,
+// the shortest one is used when the peer is in several networks
+func networkKeepalive(peer wgtypes.PeerConfig, nodes config.NodeMap) (time.Duration, bool) {
+	var keepalive time.Duration
+	found := false
+	for _, node := range nodes {
+		if node.PersistentKeepalive <= 0 || !peerInNetwork(peer, &node) {
+			continue
+		}
+		if !found || node.PersistentKeepalive < keepalive {
+			keepalive = node.PersistentKeepalive
+			found = true
+		}
+	}
+	return keepalive, found
+}
+
+// peerInNetwork - checks if any of the allowed ips of the peer is in the network range of the node
+func peerInNetwork(peer wgtypes.PeerConfig, node *config.Node) bool {
+	for _, allowed := range peer.AllowedIPs {
+		if node.NetworkRange.IP != nil && node.NetworkRange.Contains(allowed.IP) {
+			return true
+		}
+		if node.NetworkRange6.IP != nil && node.NetworkRange6.Contains(allowed.IP) {
+			return true
+		}
+	}
+	return false
+}
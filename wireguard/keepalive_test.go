@@ -0,0 +1,57 @@
+package wireguard
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netmaker/models"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestApplyServerKeepalive(t *testing.T) {
+	nodes := config.NodeMap{
+		"net1": config.Node{CommonNode: models.CommonNode{
+			Network:             "net1",
+			NetworkRange:        net.IPNet{IP: net.ParseIP("100.64.0.0"), Mask: net.CIDRMask(16, 32)},
+			PersistentKeepalive: 15 * time.Second,
+		}},
+		"net2": config.Node{CommonNode: models.CommonNode{
+			Network:             "net2",
+			NetworkRange:        net.IPNet{IP: net.ParseIP("10.20.0.0"), Mask: net.CIDRMask(16, 32)},
+			PersistentKeepalive: 0,
+		}},
+	}
+	override := 40 * time.Second
+	peers := []wgtypes.PeerConfig{
+		{AllowedIPs: []net.IPNet{{IP: net.ParseIP("100.64.0.2"), Mask: net.CIDRMask(32, 32)}}},
+		{
+			AllowedIPs:                  []net.IPNet{{IP: net.ParseIP("100.64.0.3"), Mask: net.CIDRMask(32, 32)}},
+			PersistentKeepaliveInterval: &override,
+		},
+		{AllowedIPs: []net.IPNet{{IP: net.ParseIP("10.20.0.2"), Mask: net.CIDRMask(32, 32)}}},
+	}
+	applyServerKeepalive(peers, nodes)
+	if peers[0].PersistentKeepaliveInterval == nil || *peers[0].PersistentKeepaliveInterval != 15*time.Second {
+		t.Errorf("expected the server keepalive of 15s, got %v", peers[0].PersistentKeepaliveInterval)
+	}
+	if *peers[1].PersistentKeepaliveInterval != override {
+		t.Errorf("expected the peer keepalive of %v to win, got %v", override, *peers[1].PersistentKeepaliveInterval)
+	}
+	if peers[2].PersistentKeepaliveInterval != nil {
+		t.Errorf("expected no keepalive for a network without one, got %v", *peers[2].PersistentKeepaliveInterval)
+	}
+}
+
+func TestNetworkKeepaliveShortest(t *testing.T) {
+	rng := net.IPNet{IP: net.ParseIP("100.64.0.0"), Mask: net.CIDRMask(16, 32)}
+	nodes := config.NodeMap{
+		"net1": config.Node{CommonNode: models.CommonNode{NetworkRange: rng, PersistentKeepalive: 25 * time.Second}},
+		"net2": config.Node{CommonNode: models.CommonNode{NetworkRange: rng, PersistentKeepalive: 10 * time.Second}},
+	}
+	peer := wgtypes.PeerConfig{AllowedIPs: []net.IPNet{{IP: net.ParseIP("100.64.1.1"), Mask: net.CIDRMask(32, 32)}}}
+	if keepalive, ok := networkKeepalive(peer, nodes); !ok || keepalive != 10*time.Second {
+		t.Errorf("expected the shortest keepalive of 10s, got %v %v", keepalive, ok)
+	}
+}
@@ -0,0 +1,204 @@
+package wireguard
+
+import (
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netmaker/logger"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// minProbeMTU - smallest MTU probed, peers that don't pass it are considered unreachable rather than mismatched
+const minProbeMTU = 1280
+
+const (
+	// mtuProbeWorkers - number of peers probed at once
+	mtuProbeWorkers = 4
+	// pathMTUCacheTTL - time the path MTU found for a peer endpoint is used before probing it again
+	pathMTUCacheTTL = 30 * time.Minute
+)
+
+// MTUMismatch - peer whose path MTU over the netmaker interface is below the local MTU
+type MTUMismatch struct {
+	PeerKey  string `json:"peer_key"`
+	Addr     string `json:"addr"`
+	LocalMTU int    `json:"local_mtu"`
+	PathMTU  int    `json:"path_mtu"`
+	Repaired bool   `json:"repaired"`
+	Error    string `json:"error,omitempty"`
+}
+
+// mtuProber - reports if a packet of the given size, including ip headers, reaches the address without fragmenting
+type mtuProber func(addr net.IP, size int) bool
+
+// routeMTUSetter - sets the MTU of the route to the address over the netmaker interface
+type routeMTUSetter func(addr *net.IPNet, mtu int) error
+
+var (
+	mtuMismatches      = []MTUMismatch{}
+	mtuMismatchesMutex sync.Mutex
+)
+
+// peerPathMTUs - path MTUs found on the previous checks, indexed by peer endpoint
+var peerPathMTUs = newPathMTUCache()
+
+// pathMTUResult - path MTU found for an endpoint with the local MTU it was probed for
+type pathMTUResult struct {
+	localMTU  int
+	pathMTU   int
+	checkedAt time.Time
+}
+
+// pathMTUCache - path MTU results of the peer endpoints
+type pathMTUCache struct {
+	mutex   sync.Mutex
+	results map[string]pathMTUResult
+}
+
+func newPathMTUCache() *pathMTUCache {
+	return &pathMTUCache{results: make(map[string]pathMTUResult)}
+}
+
+// pathMTUCache.get - returns the path MTU of the endpoint if it was probed for the local MTU within the ttl
+func (c *pathMTUCache) get(endpoint string, localMTU int, now time.Time) (int, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	result, ok := c.results[endpoint]
+	if !ok || result.localMTU != localMTU || now.Sub(result.checkedAt) > pathMTUCacheTTL {
+		return 0, false
+	}
+	return result.pathMTU, true
+}
+
+// pathMTUCache.set - records the path MTU probed for the endpoint
+func (c *pathMTUCache) set(endpoint string, localMTU, pathMTU int, now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.results[endpoint] = pathMTUResult{localMTU: localMTU, pathMTU: pathMTU, checkedAt: now}
+}
+
+// pathMTUCache.retain - forgets the endpoints that are no longer used by a peer
+func (c *pathMTUCache) retain(endpoints map[string]struct{}) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for endpoint := range c.results {
+		if _, ok := endpoints[endpoint]; !ok {
+			delete(c.results, endpoint)
+		}
+	}
+}
+
+// GetMTUMismatches - returns the peers found with a path MTU below the local MTU on the last check
+func GetMTUMismatches() []MTUMismatch {
+	mtuMismatchesMutex.Lock()
+	defer mtuMismatchesMutex.Unlock()
+	return append([]MTUMismatch{}, mtuMismatches...)
+}
+
+// CheckPeerMTUs - probes the path MTU to every peer and lowers the MTU of the route to peers below the local MTU,
+// endpoints probed recently are not probed again
+func CheckPeerMTUs() {
+	mismatches := checkPeerMTUs(config.GetHostPeerList(), effectiveMTU(), probeMTU, setPeerRouteMTU,
+		peerPathMTUs, time.Now())
+	mtuMismatchesMutex.Lock()
+	mtuMismatches = mismatches
+	mtuMismatchesMutex.Unlock()
+}
+
+func checkPeerMTUs(peers []wgtypes.PeerConfig, localMTU int, probe mtuProber, setRouteMTU routeMTUSetter,
+	cache *pathMTUCache, now time.Time) []MTUMismatch {
+	addrs := make([]*net.IPNet, len(peers))
+	pathMTUs := make([]int, len(peers))
+	endpoints := make(map[string]struct{})
+	sem := make(chan struct{}, mtuProbeWorkers)
+	var wg sync.WaitGroup
+	for i, peer := range peers {
+		addr := peerHostAddr(peer)
+		if addr == nil {
+			continue
+		}
+		addrs[i] = addr
+		endpoint := peerEndpointKey(peer, addr)
+		endpoints[endpoint] = struct{}{}
+		if pathMTU, ok := cache.get(endpoint, localMTU, now); ok {
+			pathMTUs[i] = pathMTU
+			continue
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, endpoint string, addr net.IP) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			pathMTUs[i] = probePathMTU(addr, localMTU, probe)
+			cache.set(endpoint, localMTU, pathMTUs[i], now)
+		}(i, endpoint, addr.IP)
+	}
+	wg.Wait()
+	cache.retain(endpoints)
+
+	mismatches := []MTUMismatch{}
+	for i, peer := range peers {
+		addr, pathMTU := addrs[i], pathMTUs[i]
+		if addr == nil || pathMTU == 0 || pathMTU >= localMTU {
+			continue
+		}
+		mismatch := MTUMismatch{
+			PeerKey:  peer.PublicKey.String(),
+			Addr:     addr.IP.String(),
+			LocalMTU: localMTU,
+			PathMTU:  pathMTU,
+		}
+		logger.Log(0, "path MTU to peer", mismatch.PeerKey, mismatch.Addr, "is", strconv.Itoa(pathMTU),
+			"below local MTU", strconv.Itoa(localMTU))
+		if err := setRouteMTU(addr, pathMTU); err != nil {
+			logger.Log(0, "failed to lower route MTU for peer", mismatch.Addr, err.Error())
+			mismatch.Error = err.Error()
+		} else {
+			mismatch.Repaired = true
+		}
+		mismatches = append(mismatches, mismatch)
+	}
+	return mismatches
+}
+
+// peerEndpointKey - the path MTU of a peer depends on its endpoint, peers without one are keyed by their address
+func peerEndpointKey(peer wgtypes.PeerConfig, addr *net.IPNet) string {
+	if peer.Endpoint != nil {
+		return peer.Endpoint.String()
+	}
+	return addr.IP.String()
+}
+
+// probePathMTU - returns the largest packet size up to localMTU that reaches the address, 0 if none does
+func probePathMTU(addr net.IP, localMTU int, probe mtuProber) int {
+	if localMTU < minProbeMTU || !probe(addr, minProbeMTU) {
+		return 0
+	}
+	if probe(addr, localMTU) {
+		return localMTU
+	}
+	low, high := minProbeMTU, localMTU
+	for high-low > 1 {
+		mid := (low + high) / 2
+		if probe(addr, mid) {
+			low = mid
+		} else {
+			high = mid
+		}
+	}
+	return low
+}
+
+// peerHostAddr - returns the single host address among the allowed ips of the peer, its netmaker address
+func peerHostAddr(peer wgtypes.PeerConfig) *net.IPNet {
+	for i := range peer.AllowedIPs {
+		ones, bits := peer.AllowedIPs[i].Mask.Size()
+		if ones == bits && bits != 0 {
+			return &peer.AllowedIPs[i]
+		}
+	}
+	return nil
+}
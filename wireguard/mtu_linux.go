@@ -0,0 +1,33 @@
+package wireguard
+
+import (
+	"net"
+	"os/exec"
+	"strconv"
+
+	"github.com/gravitl/netclient/ncutils"
+	"github.com/vishvananda/netlink"
+)
+
+// probeMTU - pings the address with the don't fragment flag set and a packet of the given size
+func probeMTU(addr net.IP, size int) bool {
+	headers := 28 // ipv4 + icmp
+	if addr.To4() == nil {
+		headers = 48 // ipv6 + icmpv6
+	}
+	cmd := exec.Command("ping", "-M", "do", "-c", "1", "-W", "1", "-s", strconv.Itoa(size-headers), addr.String())
+	return cmd.Run() == nil
+}
+
+// setPeerRouteMTU - routes the address over the netmaker interface with the given MTU
+func setPeerRouteMTU(addr *net.IPNet, mtu int) error {
+	l, err := netlink.LinkByName(ncutils.GetInterfaceName())
+	if err != nil {
+		return err
+	}
+	return netlink.RouteReplace(&netlink.Route{
+		LinkIndex: l.Attrs().Index,
+		Dst:       addr,
+		MTU:       mtu,
+	})
+}
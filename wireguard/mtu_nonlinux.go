@@ -0,0 +1,18 @@
+//go:build !linux
+// +build !linux
+
+package wireguard
+
+import (
+	"errors"
+	"net"
+)
+
+// probeMTU - path MTU probing is only supported on linux, every size passes so no mismatch is reported
+func probeMTU(addr net.IP, size int) bool {
+	return true
+}
+
+func setPeerRouteMTU(addr *net.IPNet, mtu int) error {
+	return errors.New("setting route MTU is not supported on this platform")
+}
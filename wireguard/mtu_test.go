@@ -0,0 +1,124 @@
+package wireguard
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestCheckPeerMTUs(t *testing.T) {
+	mismatched, err := wgtypes.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	matched, err := wgtypes.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	peers := []wgtypes.PeerConfig{
+		{
+			PublicKey: mismatched.PublicKey(),
+			AllowedIPs: []net.IPNet{
+				{IP: net.ParseIP("10.0.0.0"), Mask: net.CIDRMask(16, 32)},
+				{IP: net.ParseIP("100.64.0.2"), Mask: net.CIDRMask(32, 32)},
+			},
+		},
+		{
+			PublicKey:  matched.PublicKey(),
+			AllowedIPs: []net.IPNet{{IP: net.ParseIP("100.64.0.3"), Mask: net.CIDRMask(32, 32)}},
+		},
+	}
+	// the path to the first peer only carries 1380 byte packets
+	probe := func(addr net.IP, size int) bool {
+		return !addr.Equal(net.ParseIP("100.64.0.2")) || size <= 1380
+	}
+	repaired := map[string]int{}
+	setRouteMTU := func(addr *net.IPNet, mtu int) error {
+		repaired[addr.String()] = mtu
+		return nil
+	}
+	mismatches := checkPeerMTUs(peers, 1420, probe, setRouteMTU, newPathMTUCache(), time.Now())
+	if len(mismatches) != 1 {
+		t.Fatalf("expected 1 mismatch, got %+v", mismatches)
+	}
+	if mismatches[0].PathMTU != 1380 || !mismatches[0].Repaired || mismatches[0].PeerKey != mismatched.PublicKey().String() {
+		t.Fatalf("unexpected mismatch %+v", mismatches[0])
+	}
+	if len(repaired) != 1 || repaired["100.64.0.2/32"] != 1380 {
+		t.Fatalf("unexpected route MTU changes %v", repaired)
+	}
+}
+
+func TestProbePathMTUUnreachable(t *testing.T) {
+	if mtu := probePathMTU(net.ParseIP("100.64.0.2"), 1420, func(net.IP, int) bool { return false }); mtu != 0 {
+		t.Fatalf("expected 0 for unreachable peer, got %d", mtu)
+	}
+}
+
+func TestCheckPeerMTUsCachesEndpoints(t *testing.T) {
+	peers := []wgtypes.PeerConfig{}
+	for i := 0; i < 10; i++ {
+		key, err := wgtypes.GeneratePrivateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		peers = append(peers, wgtypes.PeerConfig{
+			PublicKey:  key.PublicKey(),
+			Endpoint:   &net.UDPAddr{IP: net.IPv4(198, 51, 100, byte(i+1)), Port: 51821},
+			AllowedIPs: []net.IPNet{{IP: net.IPv4(100, 64, 0, byte(i+1)), Mask: net.CIDRMask(32, 32)}},
+		})
+	}
+	var mutex sync.Mutex
+	probed := map[string]int{}
+	inFlight, maxInFlight := 0, 0
+	probe := func(addr net.IP, size int) bool {
+		mutex.Lock()
+		probed[addr.String()]++
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mutex.Unlock()
+		time.Sleep(time.Millisecond)
+		mutex.Lock()
+		inFlight--
+		mutex.Unlock()
+		return size <= 1380
+	}
+	setRouteMTU := func(addr *net.IPNet, mtu int) error { return nil }
+	cache := newPathMTUCache()
+	now := time.Now()
+	if mismatches := checkPeerMTUs(peers, 1420, probe, setRouteMTU, cache, now); len(mismatches) != 10 {
+		t.Fatalf("expected 10 mismatches, got %d", len(mismatches))
+	}
+	if maxInFlight > mtuProbeWorkers {
+		t.Fatalf("expected at most %d concurrent probes, got %d", mtuProbeWorkers, maxInFlight)
+	}
+	total := 0
+	for _, count := range probed {
+		total += count
+	}
+	// the cached endpoints are not probed again, the repair is still applied
+	mismatches := checkPeerMTUs(peers, 1420, probe, setRouteMTU, cache, now.Add(time.Minute))
+	after := 0
+	for _, count := range probed {
+		after += count
+	}
+	if after != total || len(mismatches) != 10 || mismatches[0].PathMTU != 1380 {
+		t.Fatalf("expected cached path MTUs to be used, probes %d -> %d, %+v", total, after, mismatches)
+	}
+	// an endpoint change or an expired result is probed again
+	peers[0].Endpoint = &net.UDPAddr{IP: net.IPv4(203, 0, 113, 1), Port: 51821}
+	before := probed["100.64.0.1"]
+	checkPeerMTUs(peers, 1420, probe, setRouteMTU, cache, now.Add(time.Minute))
+	if probed["100.64.0.1"] == before || probed["100.64.0.2"] != total/10 || len(cache.results) != 10 {
+		t.Fatalf("expected only the changed endpoint to be probed again, %v", probed)
+	}
+	checkPeerMTUs(peers, 1420, probe, setRouteMTU, cache, now.Add(pathMTUCacheTTL+time.Second))
+	if probed["100.64.0.2"] == total/10 {
+		t.Fatalf("expected the endpoints to be probed again, %v", probed)
+	}
+}
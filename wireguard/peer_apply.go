@@ -0,0 +1,117 @@
+package wireguard
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netclient/ncutils"
+	"github.com/gravitl/netmaker/logger"
+	"golang.zx2c4.com/wireguard/wgctrl"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+// defaultPeerApplyTimeout - seconds a peer update may take to apply, when not configured
+const defaultPeerApplyTimeout = 30
+
+// ErrPeersRolledBack - the peer update was not applied and the previous peers were restored
+var ErrPeersRolledBack = errors.New("peer update rolled back")
+
+// peerApplyMutex - serializes the peer updates with the rollbacks of the updates before them
+var peerApplyMutex sync.Mutex
+
+// applyPeers - applies the peer update to the interface, restoring the previous peers if it fails
+// or does not complete within the configured timeout
+func applyPeers(c *wgtypes.Config) error {
+	timeout := config.Netclient().PeerApplyTimeout
+	if timeout <= 0 {
+		timeout = defaultPeerApplyTimeout
+	}
+	return applyWithRollback(*c, time.Duration(timeout)*time.Second, currentPeers, func(c wgtypes.Config) error {
+		return apply(&c)
+	})
+}
+
+// currentPeers - returns the peers currently set on the interface
+func currentPeers() ([]wgtypes.Peer, error) {
+	wg, err := wgctrl.New()
+	if err != nil {
+		return nil, err
+	}
+	defer wg.Close()
+	dev, err := wg.Device(ncutils.GetInterfaceName())
+	if err != nil {
+		return nil, err
+	}
+	return dev.Peers, nil
+}
+
+// applyWithRollback - snapshots the peers, applies the config and restores the snapshot if the apply fails
+// or times out; a timed out apply can't be cancelled, it is rolled back once it completes and the updates
+// after it wait for the rollback
+func applyWithRollback(c wgtypes.Config, timeout time.Duration, snapshot func() ([]wgtypes.Peer, error),
+	configure func(wgtypes.Config) error) error {
+	peerApplyMutex.Lock()
+	prior, err := snapshot()
+	if err != nil {
+		defer peerApplyMutex.Unlock()
+		logger.Log(1, "failed to snapshot peers, applying peer update without rollback:", err.Error())
+		return configure(c)
+	}
+	result := make(chan error, 1)
+	go func() {
+		result <- configure(c)
+	}()
+	select {
+	case err = <-result:
+		if err == nil {
+			peerApplyMutex.Unlock()
+			return nil
+		}
+	case <-time.After(timeout):
+		err = fmt.Errorf("timed out after %s", timeout)
+		go func() {
+			defer peerApplyMutex.Unlock()
+			<-result
+			if rollbackErr := rollbackPeers(configure, prior, err); rollbackErr != nil {
+				logger.Log(0, rollbackErr.Error())
+			}
+		}()
+		return fmt.Errorf("%w: %s, previous peers are restored once the update completes", ErrPeersRolledBack, err)
+	}
+	defer peerApplyMutex.Unlock()
+	return rollbackPeers(configure, prior, err)
+}
+
+// rollbackPeers - replaces the peers with the prior ones after the peer update failed with err
+func rollbackPeers(configure func(wgtypes.Config) error, prior []wgtypes.Peer, err error) error {
+	logger.Log(0, "failed to apply peer update, restoring", fmt.Sprint(len(prior)), "previous peers:", err.Error())
+	if rollbackErr := configure(wgtypes.Config{ReplacePeers: true, Peers: peerConfigs(prior)}); rollbackErr != nil {
+		return fmt.Errorf("%w: %s, restoring previous peers failed: %s", ErrPeersRolledBack, err, rollbackErr)
+	}
+	return fmt.Errorf("%w: %s", ErrPeersRolledBack, err)
+}
+
+// peerConfigs - converts the peers of an interface to the configs that set them again
+func peerConfigs(peers []wgtypes.Peer) []wgtypes.PeerConfig {
+	configs := make([]wgtypes.PeerConfig, 0, len(peers))
+	for i := range peers {
+		peer := peers[i]
+		peerConfig := wgtypes.PeerConfig{
+			PublicKey:         peer.PublicKey,
+			Endpoint:          peer.Endpoint,
+			ReplaceAllowedIPs: true,
+			AllowedIPs:        peer.AllowedIPs,
+		}
+		if peer.PresharedKey != (wgtypes.Key{}) {
+			peerConfig.PresharedKey = &peer.PresharedKey
+		}
+		if peer.PersistentKeepaliveInterval > 0 {
+			peerConfig.PersistentKeepaliveInterval = &peer.PersistentKeepaliveInterval
+		}
+		configs = append(configs, peerConfig)
+	}
+	return configs
+}
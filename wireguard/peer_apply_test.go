@@ -0,0 +1,94 @@
+package wireguard
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestApplyWithRollback(t *testing.T) {
+	key, _ := wgtypes.GeneratePrivateKey()
+	prior := []wgtypes.Peer{{
+		PublicKey:                   key.PublicKey(),
+		Endpoint:                    &net.UDPAddr{IP: net.ParseIP("203.0.113.1"), Port: 51821},
+		AllowedIPs:                  []net.IPNet{{IP: net.ParseIP("10.0.0.1"), Mask: net.CIDRMask(32, 32)}},
+		PersistentKeepaliveInterval: 20 * time.Second,
+	}}
+	snapshot := func() ([]wgtypes.Peer, error) { return prior, nil }
+	newKey, _ := wgtypes.GeneratePrivateKey()
+	update := wgtypes.Config{Peers: []wgtypes.PeerConfig{{PublicKey: newKey.PublicKey()}}}
+
+	t.Run("applied", func(t *testing.T) {
+		applied := []wgtypes.Config{}
+		err := applyWithRollback(update, time.Second, snapshot, func(c wgtypes.Config) error {
+			applied = append(applied, c)
+			return nil
+		})
+		if err != nil || len(applied) != 1 {
+			t.Fatalf("expected the update to be applied once, got %v after %d applies", err, len(applied))
+		}
+	})
+	t.Run("failing mid-apply", func(t *testing.T) {
+		applied := []wgtypes.Config{}
+		err := applyWithRollback(update, time.Second, snapshot, func(c wgtypes.Config) error {
+			applied = append(applied, c)
+			if len(applied) == 1 {
+				return errors.New("device busy")
+			}
+			return nil
+		})
+		if !errors.Is(err, ErrPeersRolledBack) {
+			t.Fatalf("expected ErrPeersRolledBack, got %v", err)
+		}
+		assertRollback(t, applied, prior)
+	})
+	t.Run("timed out", func(t *testing.T) {
+		applied := make(chan wgtypes.Config, 3)
+		block := make(chan struct{})
+		configure := func(c wgtypes.Config) error {
+			applied <- c
+			if !c.ReplacePeers && len(c.Peers) == 1 && c.Peers[0].PublicKey == newKey.PublicKey() {
+				<-block
+			}
+			return nil
+		}
+		err := applyWithRollback(update, 20*time.Millisecond, snapshot, configure)
+		if !errors.Is(err, ErrPeersRolledBack) {
+			t.Fatalf("expected ErrPeersRolledBack, got %v", err)
+		}
+		// the next update waits for the timed out one to complete and be rolled back
+		next := wgtypes.Config{Peers: []wgtypes.PeerConfig{{PublicKey: key.PublicKey()}}}
+		done := make(chan error)
+		go func() {
+			done <- applyWithRollback(next, time.Second, snapshot, configure)
+		}()
+		time.Sleep(20 * time.Millisecond)
+		close(block)
+		if err := <-done; err != nil {
+			t.Fatalf("expected the next update to be applied, got %v", err)
+		}
+		assertRollback(t, []wgtypes.Config{<-applied, <-applied}, prior)
+		if last := <-applied; last.ReplacePeers || last.Peers[0].PublicKey != key.PublicKey() {
+			t.Fatalf("expected the next update after the rollback, got %+v", last)
+		}
+	})
+}
+
+func assertRollback(t *testing.T, applied []wgtypes.Config, prior []wgtypes.Peer) {
+	t.Helper()
+	if len(applied) != 2 {
+		t.Fatalf("expected the update and a rollback, got %d applies", len(applied))
+	}
+	rollback := applied[1]
+	if !rollback.ReplacePeers || len(rollback.Peers) != len(prior) {
+		t.Fatalf("expected the rollback to replace the peers with the %d previous ones, got %+v", len(prior), rollback)
+	}
+	peer := rollback.Peers[0]
+	if peer.PublicKey != prior[0].PublicKey || peer.Endpoint.String() != prior[0].Endpoint.String() ||
+		len(peer.AllowedIPs) != 1 || *peer.PersistentKeepaliveInterval != prior[0].PersistentKeepaliveInterval {
+		t.Fatalf("rollback does not restore the previous peer: %+v", peer)
+	}
+}
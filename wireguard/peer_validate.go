@@ -0,0 +1,42 @@
+package wireguard
+
+import (
+	"errors"
+
+	"github.com/gravitl/netclient/config"
+	"github.com/gravitl/netmaker/logger"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+var (
+	errZeroPeerKey     = errors.New("public key is zero")
+	errNoPeerAllowedIP = errors.New("no allowed ips")
+)
+
+// validatePeer - checks the peer has a non zero public key and, unless it is being removed, allowed ips
+func validatePeer(peer wgtypes.PeerConfig) error {
+	if peer.PublicKey == (wgtypes.Key{}) {
+		return errZeroPeerKey
+	}
+	if !peer.Remove && len(peer.AllowedIPs) == 0 {
+		return errNoPeerAllowedIP
+	}
+	return nil
+}
+
+// validPeers - returns the peers which pass validation, logging the ones skipped,
+// all peers are returned when the host skips peer validation
+func validPeers(peers []wgtypes.PeerConfig) []wgtypes.PeerConfig {
+	if config.Netclient().SkipPeerValidation {
+		return peers
+	}
+	valid := make([]wgtypes.PeerConfig, 0, len(peers))
+	for _, peer := range peers {
+		if err := validatePeer(peer); err != nil {
+			logger.Log(0, "skipping invalid peer", peer.PublicKey.String(), ":", err.Error())
+			continue
+		}
+		valid = append(valid, peer)
+	}
+	return valid
+}
@@ -0,0 +1,36 @@
+package wireguard
+
+import (
+	"net"
+	"testing"
+
+	"github.com/gravitl/netclient/config"
+	"golang.zx2c4.com/wireguard/wgctrl/wgtypes"
+)
+
+func TestValidPeers(t *testing.T) {
+	newKey := func() wgtypes.Key {
+		key, _ := wgtypes.GeneratePrivateKey()
+		return key.PublicKey()
+	}
+	allowed := []net.IPNet{{IP: net.ParseIP("10.0.0.2"), Mask: net.CIDRMask(32, 32)}}
+	valid := wgtypes.PeerConfig{PublicKey: newKey(), AllowedIPs: allowed}
+	removed := wgtypes.PeerConfig{PublicKey: newKey(), Remove: true}
+	peers := []wgtypes.PeerConfig{
+		valid,
+		{PublicKey: wgtypes.Key{}, AllowedIPs: allowed},
+		{PublicKey: newKey()},
+		removed,
+		{Remove: true},
+	}
+	got := validPeers(peers)
+	if len(got) != 2 || got[0].PublicKey != valid.PublicKey || got[1].PublicKey != removed.PublicKey {
+		t.Fatalf("expected only the valid and the removed peer, got %+v", got)
+	}
+
+	config.Netclient().SkipPeerValidation = true
+	defer func() { config.Netclient().SkipPeerValidation = false }()
+	if got := validPeers(peers); len(got) != len(peers) {
+		t.Fatalf("expected all peers when validation is skipped, got %d", len(got))
+	}
+}
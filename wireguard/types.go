@@ -87,7 +87,7 @@ func (n *NCIface) Configure() error {
 	if err := n.ApplyAddrs(false); err != nil {
 		return err
 	}
-	if err := n.SetMTU(); err != nil {
+	if err := n.applyMTU(); err != nil {
 		return err
 	}
 	return apply(&n.Config)
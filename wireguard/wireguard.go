@@ -18,20 +18,21 @@ import (
 // SetPeers - sets peers on netmaker WireGuard interface
 func SetPeers() error {
 
-	peers := config.GetHostPeerList()
+	peers := validPeers(config.GetHostPeerList())
 	for i := range peers {
 		peer := peers[i]
 		if checkForBetterEndpoint(&peer) {
 			peers[i] = peer
 		}
 	}
+	applyServerKeepalive(peers, config.GetNodes())
 	GetInterface().Config.Peers = peers
 	peers = peer.SetPeersEndpointToProxy(peers)
 	config := wgtypes.Config{
 		ReplacePeers: false,
 		Peers:        peers,
 	}
-	return apply(&config)
+	return applyPeers(&config)
 }
 
 // RemovePeers - removes all peers from a given node config
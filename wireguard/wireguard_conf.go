@@ -84,7 +84,7 @@ func UpdateKeepAlive(keepalive int) error {
 
 // UpdateWgPeers updates the peers section of wg conf file with a new set of peers
 func UpdateWgPeers() (*net.UDPAddr, error) {
-	peers := config.GetHostPeerList()
+	peers := validPeers(config.GetHostPeerList())
 	var internetGateway *net.UDPAddr
 	options := ini.LoadOptions{
 		AllowNonUniqueSections: true,